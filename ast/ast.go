@@ -79,6 +79,7 @@ const (
 	OperatorExtendedAnd                        // and
 	OperatorExtendedOr                         // or
 	OperatorExtendedNot                        // not
+	OperatorTilde                              // ~
 )
 
 // String returns the string representation of the operator type.
@@ -87,7 +88,7 @@ func (op OperatorType) String() string {
 	// compiler.internalOperatorNotZero.
 	return []string{"==", "!=", "<", "<=", ">", ">=", "!", "&", "|", "&&", "||",
 		"+", "-", "*", "/", "%", "^", "&^", "<<", ">>", "contains", "not contains",
-		"<-", "&", "*", "and", "or", "not", "", ""}[op]
+		"<-", "&", "*", "and", "or", "not", "~", "", ""}[op]
 }
 
 // AssignmentType represents a type of assignment.
@@ -122,6 +123,9 @@ const (
 	FormatJS
 	FormatJSON
 	FormatMarkdown
+	FormatXML
+	FormatYAML
+	FormatTOML
 )
 
 // String returns the name of the format.
@@ -139,6 +143,12 @@ func (format Format) String() string {
 		return "JSON"
 	case FormatMarkdown:
 		return "Markdown"
+	case FormatXML:
+		return "XML"
+	case FormatYAML:
+		return "YAML"
+	case FormatTOML:
+		return "TOML"
 	}
 	panic("invalid format")
 }
@@ -153,6 +163,9 @@ const (
 	ContextJS
 	ContextJSON
 	ContextMarkdown
+	ContextXML
+	ContextYAML
+	ContextTOML
 	ContextTag
 	ContextQuotedAttr
 	ContextUnquotedAttr
@@ -161,6 +174,19 @@ const (
 	ContextJSONString
 	ContextTabCodeBlock
 	ContextSpacesCodeBlock
+	ContextJSAttr
+	ContextJSStringAttr
+	ContextCSSAttr
+	ContextCSSStringAttr
+	ContextXMLAttr
+	ContextXMLCDATA
+	ContextYAMLPlain
+	ContextYAMLDoubleQuoted
+	ContextYAMLSingleQuoted
+	ContextYAMLBlock
+	ContextTOMLString
+	ContextTOMLLiteralString
+	ContextYAMLFlowPlain
 )
 
 // String returns the name of the context.
@@ -178,6 +204,12 @@ func (ctx Context) String() string {
 		return "JSON"
 	case ContextMarkdown:
 		return "Markdown"
+	case ContextXML:
+		return "XML"
+	case ContextYAML:
+		return "YAML"
+	case ContextTOML:
+		return "TOML"
 	case ContextTag:
 		return "tag"
 	case ContextQuotedAttr:
@@ -194,6 +226,32 @@ func (ctx Context) String() string {
 		return "tab code block"
 	case ContextSpacesCodeBlock:
 		return "spaces code block"
+	case ContextJSAttr:
+		return "JavaScript attribute"
+	case ContextJSStringAttr:
+		return "JavaScript string attribute"
+	case ContextCSSAttr:
+		return "CSS attribute"
+	case ContextCSSStringAttr:
+		return "CSS string attribute"
+	case ContextXMLAttr:
+		return "XML attribute"
+	case ContextXMLCDATA:
+		return "XML CDATA section"
+	case ContextYAMLPlain:
+		return "YAML plain scalar"
+	case ContextYAMLDoubleQuoted:
+		return "YAML double-quoted scalar"
+	case ContextYAMLSingleQuoted:
+		return "YAML single-quoted scalar"
+	case ContextYAMLBlock:
+		return "YAML block scalar"
+	case ContextTOMLString:
+		return "TOML string"
+	case ContextTOMLLiteralString:
+		return "TOML literal string"
+	case ContextYAMLFlowPlain:
+		return "YAML flow plain scalar"
 	}
 	panic("invalid context")
 }
@@ -826,17 +884,19 @@ func NewForRange(pos *Position, assignment *Assignment, body []Node) *ForRange {
 type Func struct {
 	expression
 	*Position
-	Ident    *Identifier // name, nil for function literals.
-	Type     *FuncType   // type.
-	Body     *Block      // body.
-	DistFree bool        // reports whether it is distraction free.
-	Upvars   []Upvar     // Upvars of func.
-	Format   Format      // macro format.
+	Ident      *Identifier  // name, nil for function literals.
+	Recv       *Parameter   // receiver, nil for functions and macros.
+	TypeParams []*Parameter // type parameters, nil if not generic.
+	Type       *FuncType    // type.
+	Body       *Block       // body.
+	DistFree   bool         // reports whether it is distraction free.
+	Upvars     []Upvar      // Upvars of func.
+	Format     Format       // macro format.
 }
 
 // NewFunc returns a new Func node.
 func NewFunc(pos *Position, name *Identifier, typ *FuncType, body *Block, distFree bool, format Format) *Func {
-	return &Func{expression{}, pos, name, typ, body, distFree, nil, format}
+	return &Func{expression{}, pos, name, nil, nil, typ, body, distFree, nil, format}
 }
 
 // String returns the string representation of n.
@@ -847,6 +907,9 @@ func (n *Func) String() string {
 	if n.Ident == nil {
 		return "func literal"
 	}
+	if n.Recv != nil {
+		return "method declaration"
+	}
 	return "func declaration"
 }
 
@@ -900,11 +963,12 @@ func (n *FuncType) String() string {
 type Go struct {
 	*Position            // position in the source.
 	Call      Expression // function or method call (should be a Call node).
+	Context   Context    // context.
 }
 
 // NewGo returns a new Go node.
-func NewGo(pos *Position, call Expression) *Go {
-	return &Go{pos, call}
+func NewGo(pos *Position, call Expression, ctx Context) *Go {
+	return &Go{pos, call, ctx}
 }
 
 // String returns the string representation of n.
@@ -1018,20 +1082,69 @@ func (n *Index) String() string {
 	return n.Expr.String() + "[" + n.Index.String() + "]"
 }
 
+// Instantiation node represents the instantiation of a generic function or
+// type with two or more type arguments, as in F[A, B]. An instantiation with
+// only one type argument is represented by an Index node.
+type Instantiation struct {
+	*expression
+	*Position              // position in the source.
+	Expr      Expression   // generic function or type.
+	TypeArgs  []Expression // type arguments.
+}
+
+// NewInstantiation returns a new Instantiation node.
+func NewInstantiation(pos *Position, expr Expression, typeArgs []Expression) *Instantiation {
+	return &Instantiation{&expression{}, pos, expr, typeArgs}
+}
+
+// String returns the string representation of n.
+func (n *Instantiation) String() string {
+	var b strings.Builder
+	b.WriteString(n.Expr.String())
+	b.WriteString("[")
+	for i, arg := range n.TypeArgs {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(arg.String())
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
 // Interface node represents an interface type.
 type Interface struct {
 	*expression
-	*Position // position in the source.
+	*Position          // position in the source.
+	Methods   []*Field // methods and embedded interfaces.
 }
 
 // NewInterface returns a new Interface node.
-func NewInterface(pos *Position) *Interface {
-	return &Interface{&expression{}, pos}
+//
+// Every element of methods is either a method, with one identifier and a
+// FuncType as type, or an embedded interface, with no identifiers.
+func NewInterface(pos *Position, methods []*Field) *Interface {
+	return &Interface{&expression{}, pos, methods}
 }
 
 // String returns the string representation of n.
 func (n *Interface) String() string {
-	return "interface{}"
+	if len(n.Methods) == 0 {
+		return "interface{}"
+	}
+	s := "interface { "
+	for i, m := range n.Methods {
+		if i > 0 {
+			s += "; "
+		}
+		if m.Idents == nil {
+			s += m.Type.String()
+			continue
+		}
+		s += m.Idents[0].Name + strings.TrimPrefix(m.Type.String(), "func")
+	}
+	s += " }"
+	return s
 }
 
 // KeyValue represents a key value pair in a slice, map or struct composite literal.
@@ -1084,6 +1197,10 @@ type Package struct {
 	Name         string // name.
 	Declarations []Node
 
+	// Files maps the declarations of a program package to the path of the
+	// file in which they are declared. It is nil for templates and scripts.
+	Files map[Node]string
+
 	IR struct {
 		// IteaNameToVarIdents maps the name of the transformed 'itea'
 		// identifier to the identifiers on the left side of a 'var'
@@ -1392,11 +1509,16 @@ type Text struct {
 	*Position        // position in the source.
 	Text      []byte // text.
 	Cut       Cut    // cut.
+
+	// NonceOffsets contains the offsets in Text immediately after the names
+	// of the script and style start tags, where a nonce attribute can be
+	// inserted.
+	NonceOffsets []int
 }
 
 // NewText returns a new Text node.
 func NewText(pos *Position, text []byte, cut Cut) *Text {
-	return &Text{pos, text, cut}
+	return &Text{pos, text, cut, nil}
 }
 
 // String returns the string representation of n.
@@ -1450,15 +1572,16 @@ func (n *TypeAssertion) String() string {
 // TypeDeclaration node represents a type declaration, that is an alias
 // declaration or a type definition.
 type TypeDeclaration struct {
-	*Position                      // position in the source.
-	Ident              *Identifier // identifier of the type.
-	Type               Expression  // expression representing the type.
-	IsAliasDeclaration bool        // reports whether it is an alias declaration or a type definition.
+	*Position                       // position in the source.
+	Ident              *Identifier  // identifier of the type.
+	TypeParams         []*Parameter // type parameters, nil if not generic.
+	Type               Expression   // expression representing the type.
+	IsAliasDeclaration bool         // reports whether it is an alias declaration or a type definition.
 }
 
 // NewTypeDeclaration returns a new TypeDeclaration node.
 func NewTypeDeclaration(pos *Position, ident *Identifier, typ Expression, isAliasDeclaration bool) *TypeDeclaration {
-	return &TypeDeclaration{pos, ident, typ, isAliasDeclaration}
+	return &TypeDeclaration{pos, ident, nil, typ, isAliasDeclaration}
 }
 
 // String returns the string representation of n.
@@ -1466,6 +1589,16 @@ func (n *TypeDeclaration) String() string {
 	if n.IsAliasDeclaration {
 		return fmt.Sprintf("type %s = %s", n.Ident.Name, n.Type.String())
 	}
+	if n.TypeParams != nil {
+		s := "type " + n.Ident.Name + "["
+		for i, param := range n.TypeParams {
+			if i > 0 {
+				s += ", "
+			}
+			s += param.String()
+		}
+		return s + "] " + n.Type.String()
+	}
 	return fmt.Sprintf("type %s %s", n.Ident.Name, n.Type.String())
 }
 
@@ -21,13 +21,19 @@ func CloneNode(node ast.Node) ast.Node {
 	switch n := node.(type) {
 
 	case *ast.Assignment:
-		variables := make([]ast.Expression, len(n.Lhs))
-		for i, v := range n.Lhs {
-			variables[i] = CloneExpression(v)
+		var variables []ast.Expression
+		if n.Lhs != nil {
+			variables = make([]ast.Expression, len(n.Lhs))
+			for i, v := range n.Lhs {
+				variables[i] = CloneExpression(v)
+			}
 		}
-		values := make([]ast.Expression, len(n.Rhs))
-		for i, v := range n.Rhs {
-			variables[i] = CloneExpression(v)
+		var values []ast.Expression
+		if n.Rhs != nil {
+			values = make([]ast.Expression, len(n.Rhs))
+			for i, v := range n.Rhs {
+				values[i] = CloneExpression(v)
+			}
 		}
 		return ast.NewAssignment(ClonePosition(n.Position), variables, n.Type, values)
 
@@ -42,8 +48,7 @@ func CloneNode(node ast.Node) ast.Node {
 		return ast.NewBlock(ClonePosition(n.Position), nodes)
 
 	case *ast.Break:
-		label := CloneExpression(n.Label).(*ast.Identifier)
-		return ast.NewBreak(ClonePosition(n.Position), label)
+		return ast.NewBreak(ClonePosition(n.Position), cloneIdentifier(n.Label))
 
 	case *ast.Case:
 		var expressions []ast.Expression
@@ -78,8 +83,7 @@ func CloneNode(node ast.Node) ast.Node {
 		return ast.NewConst(ClonePosition(n.Position), idents, typ, values, n.Index)
 
 	case *ast.Continue:
-		label := CloneExpression(n.Label).(*ast.Identifier)
-		return ast.NewContinue(ClonePosition(n.Position), label)
+		return ast.NewContinue(ClonePosition(n.Position), cloneIdentifier(n.Label))
 
 	case *ast.Defer:
 		return ast.NewDefer(ClonePosition(n.Position), CloneExpression(n.Call))
@@ -128,19 +132,11 @@ func CloneNode(node ast.Node) ast.Node {
 		assignment := CloneNode(n.Assignment).(*ast.Assignment)
 		return ast.NewForRange(ClonePosition(n.Position), assignment, body)
 
-	case *ast.Func:
-		var ident *ast.Identifier
-		if n.Ident != nil {
-			ident = ast.NewIdentifier(ClonePosition(n.Ident.Position), n.Ident.Name)
-		}
-		typ := CloneExpression(n.Type).(*ast.FuncType)
-		return ast.NewFunc(ClonePosition(n.Position), ident, typ, CloneNode(n.Body).(*ast.Block), n.DistFree, n.Format)
-
 	case *ast.Go:
-		return ast.NewGo(ClonePosition(n.Position), CloneExpression(n.Call))
+		return ast.NewGo(ClonePosition(n.Position), CloneExpression(n.Call), n.Context)
 
 	case *ast.Goto:
-		return ast.NewGoto(ClonePosition(n.Position), CloneExpression(n.Label).(*ast.Identifier))
+		return ast.NewGoto(ClonePosition(n.Position), cloneIdentifier(n.Label))
 
 	case *ast.If:
 		var init ast.Node
@@ -176,14 +172,38 @@ func CloneNode(node ast.Node) ast.Node {
 		return imp
 
 	case *ast.Label:
-		return ast.NewLabel(ClonePosition(n.Position), CloneExpression(n.Ident).(*ast.Identifier), CloneNode(n.Statement))
+		var statement ast.Node
+		if n.Statement != nil {
+			statement = CloneNode(n.Statement)
+		}
+		return ast.NewLabel(ClonePosition(n.Position), cloneIdentifier(n.Ident), statement)
 
 	case *ast.Package:
 		var nn = make([]ast.Node, 0, len(n.Declarations))
-		for _, n := range n.Declarations {
-			nn = append(nn, CloneNode(n))
+		var files map[ast.Node]string
+		if n.Files != nil {
+			files = make(map[ast.Node]string, len(n.Files))
+		}
+		for _, d := range n.Declarations {
+			c := CloneNode(d)
+			if path, ok := n.Files[d]; ok {
+				files[c] = path
+			}
+			nn = append(nn, c)
+		}
+		pkg := ast.NewPackage(ClonePosition(n.Position), n.Name, nn)
+		pkg.Files = files
+		return pkg
+
+	case *ast.Return:
+		var values []ast.Expression
+		if n.Values != nil {
+			values = make([]ast.Expression, len(n.Values))
+			for i, v := range n.Values {
+				values[i] = CloneExpression(v)
+			}
 		}
-		return ast.NewPackage(ClonePosition(n.Position), n.Name, nn)
+		return ast.NewReturn(ClonePosition(n.Position), values)
 
 	case *ast.Raw:
 		return ast.NewRaw(ClonePosition(n.Position), n.Marker, n.Tag, CloneNode(n.Text).(*ast.Text))
@@ -236,27 +256,6 @@ func CloneNode(node ast.Node) ast.Node {
 		}
 		return ast.NewStatements(ClonePosition(n.Position), nodes)
 
-	case *ast.StructType:
-		var fields []*ast.Field
-		if n.Fields != nil {
-			fields = make([]*ast.Field, len(n.Fields))
-			for i, field := range n.Fields {
-				var idents []*ast.Identifier
-				if field.Idents != nil {
-					idents = make([]*ast.Identifier, len(field.Idents))
-					for j, ident := range field.Idents {
-						idents[j] = CloneExpression(ident).(*ast.Identifier)
-					}
-				}
-				var typ ast.Expression
-				if field.Type != nil {
-					typ = CloneExpression(field.Type)
-				}
-				fields[i] = ast.NewField(idents, typ, field.Tag)
-			}
-		}
-		return ast.NewStructType(ClonePosition(n.Position), fields)
-
 	case *ast.Switch:
 		var init ast.Node
 		if n.Init != nil {
@@ -281,7 +280,17 @@ func CloneNode(node ast.Node) ast.Node {
 			text = make([]byte, len(n.Text))
 			copy(text, n.Text)
 		}
-		return ast.NewText(ClonePosition(n.Position), text, n.Cut)
+		nt := ast.NewText(ClonePosition(n.Position), text, n.Cut)
+		if n.NonceOffsets != nil {
+			nt.NonceOffsets = make([]int, len(n.NonceOffsets))
+			copy(nt.NonceOffsets, n.NonceOffsets)
+		}
+		return nt
+
+	case *ast.TypeDeclaration:
+		td := ast.NewTypeDeclaration(ClonePosition(n.Position), cloneIdentifier(n.Ident), CloneExpression(n.Type), n.IsAliasDeclaration)
+		td.TypeParams = cloneParameters(n.TypeParams)
+		return td
 
 	case *ast.TypeSwitch:
 		var init ast.Node
@@ -395,7 +404,16 @@ func CloneExpression(expr ast.Expression) ast.Expression {
 			ident = ast.NewIdentifier(ClonePosition(e.Ident.Position), e.Ident.Name)
 		}
 		typ := CloneExpression(e.Type).(*ast.FuncType)
-		expr2 = ast.NewFunc(ClonePosition(e.Position), ident, typ, CloneNode(e.Body).(*ast.Block), false, e.Format)
+		var body *ast.Block
+		if e.Body != nil {
+			body = CloneNode(e.Body).(*ast.Block)
+		}
+		fn := ast.NewFunc(ClonePosition(e.Position), ident, typ, body, e.DistFree, e.Format)
+		if e.Recv != nil {
+			fn.Recv = ast.NewParameter(cloneIdentifier(e.Recv.Ident), CloneExpression(e.Recv.Type))
+		}
+		fn.TypeParams = cloneParameters(e.TypeParams)
+		expr2 = fn
 
 	case *ast.FuncType:
 		var parameters []*ast.Parameter
@@ -428,12 +446,36 @@ func CloneExpression(expr ast.Expression) ast.Expression {
 	case *ast.Index:
 		expr2 = ast.NewIndex(ClonePosition(e.Position), CloneExpression(e.Expr), CloneExpression(e.Index))
 
+	case *ast.Instantiation:
+		typeArgs := make([]ast.Expression, len(e.TypeArgs))
+		for i, arg := range e.TypeArgs {
+			typeArgs[i] = CloneExpression(arg)
+		}
+		expr2 = ast.NewInstantiation(ClonePosition(e.Position), CloneExpression(e.Expr), typeArgs)
+
 	case *ast.Interface:
-		expr2 = ast.NewInterface(ClonePosition(e.Pos()))
+		var methods []*ast.Field
+		if e.Methods != nil {
+			methods = make([]*ast.Field, len(e.Methods))
+			for i, m := range e.Methods {
+				var idents []*ast.Identifier
+				if m.Idents != nil {
+					idents = make([]*ast.Identifier, len(m.Idents))
+					for j, ident := range m.Idents {
+						idents[j] = CloneExpression(ident).(*ast.Identifier)
+					}
+				}
+				methods[i] = ast.NewField(idents, CloneExpression(m.Type), "")
+			}
+		}
+		expr2 = ast.NewInterface(ClonePosition(e.Position), methods)
 
 	case *ast.MapType:
 		expr2 = ast.NewMapType(ClonePosition(e.Pos()), CloneExpression(e.KeyType), CloneExpression(e.ValueType))
 
+	case *ast.Placeholder:
+		expr2 = ast.NewPlaceholder()
+
 	case *ast.Render:
 		n := ast.NewRender(ClonePosition(e.Position), e.Path)
 		if e.Tree != nil {
@@ -451,6 +493,27 @@ func CloneExpression(expr ast.Expression) ast.Expression {
 		expr2 = ast.NewSlicing(ClonePosition(e.Position), CloneExpression(e.Expr), CloneExpression(e.Low),
 			CloneExpression(e.High), CloneExpression(e.Max), e.IsFull)
 
+	case *ast.StructType:
+		var fields []*ast.Field
+		if e.Fields != nil {
+			fields = make([]*ast.Field, len(e.Fields))
+			for i, field := range e.Fields {
+				var idents []*ast.Identifier
+				if field.Idents != nil {
+					idents = make([]*ast.Identifier, len(field.Idents))
+					for j, ident := range field.Idents {
+						idents[j] = CloneExpression(ident).(*ast.Identifier)
+					}
+				}
+				var typ ast.Expression
+				if field.Type != nil {
+					typ = CloneExpression(field.Type)
+				}
+				fields[i] = ast.NewField(idents, typ, field.Tag)
+			}
+		}
+		expr2 = ast.NewStructType(ClonePosition(e.Position), fields)
+
 	case *ast.TypeAssertion:
 		expr2 = ast.NewTypeAssertion(ClonePosition(e.Position), CloneExpression(e.Expr), CloneExpression(e.Type))
 
@@ -466,7 +529,31 @@ func CloneExpression(expr ast.Expression) ast.Expression {
 	return expr2
 }
 
-// ClonePosition returns a copy of position pos.
+// ClonePosition returns a copy of position pos. If pos is nil, it returns
+// nil.
 func ClonePosition(pos *ast.Position) *ast.Position {
+	if pos == nil {
+		return nil
+	}
 	return &ast.Position{Line: pos.Line, Column: pos.Column, Start: pos.Start, End: pos.End}
 }
+
+// cloneIdentifier returns a copy of ident. If ident is nil, it returns nil.
+func cloneIdentifier(ident *ast.Identifier) *ast.Identifier {
+	if ident == nil {
+		return nil
+	}
+	return ast.NewIdentifier(ClonePosition(ident.Position), ident.Name)
+}
+
+// cloneParameters returns a copy of the parameters params.
+func cloneParameters(params []*ast.Parameter) []*ast.Parameter {
+	if params == nil {
+		return nil
+	}
+	clone := make([]*ast.Parameter, len(params))
+	for i, param := range params {
+		clone[i] = ast.NewParameter(cloneIdentifier(param.Ident), CloneExpression(param.Type))
+	}
+	return clone
+}
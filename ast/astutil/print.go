@@ -0,0 +1,1001 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package astutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/open2b/scriggo/ast"
+)
+
+// Fprint writes to w the source of the template tree in the canonical format.
+// tree must be a tree returned by the parser, not expanded.
+//
+// The code in {{ }}, {% %} and {%% %%} is written with normalized spacing and
+// a statement alone in its line is indented with tabs, according to its
+// nesting in if, for, switch, select, macro, using and raw statements. Texts
+// and comments are written unchanged, except for the spaces around a
+// statement alone in its line, that are not rendered.
+//
+// Formatting the source written by Fprint does not change it.
+func Fprint(w io.Writer, tree *ast.Tree) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(printError)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	p := &printer{}
+	p.nodes(tree.Nodes, 0)
+	_, err = w.Write(p.source())
+	return err
+}
+
+// printError is the error returned by Fprint if the tree contains a node
+// that cannot be printed.
+type printError struct {
+	node ast.Node
+}
+
+func (e printError) Error() string {
+	return fmt.Sprintf("astutil: cannot print node of type %T", e.node)
+}
+
+// segment is a text or a tag of a template.
+type segment struct {
+	text  *ast.Text // text, or nil for a tag.
+	tag   string    // tag, as "{% end %}".
+	depth int       // nesting depth of the tag.
+}
+
+// printer prints a template tree as a sequence of segments.
+type printer struct {
+	segments []segment
+}
+
+// addTag adds a tag with the given nesting depth.
+func (p *printer) addTag(tag string, depth int) {
+	p.segments = append(p.segments, segment{tag: tag, depth: depth})
+}
+
+// addText adds a text. It does nothing if text is nil.
+func (p *printer) addText(text *ast.Text) {
+	if text != nil {
+		p.segments = append(p.segments, segment{text: text})
+	}
+}
+
+// nodes adds the segments of the template nodes nested at the given depth.
+func (p *printer) nodes(nodes []ast.Node, depth int) {
+	for i := 0; i < len(nodes); i++ {
+		switch n := nodes[i].(type) {
+		case *ast.Text:
+			p.addText(n)
+		case *ast.URL:
+			p.nodes(n.Value, depth)
+		case *ast.Comment:
+			p.addTag("{#"+n.Text+"#}", depth)
+		case *ast.Show:
+			c := &code{inline: true}
+			if len(n.Expressions) == 1 && (n.Position == nil || n.End > n.Expressions[0].Pos().End) {
+				c.expr(n.Expressions[0])
+				p.addTag("{{ "+c.String()+" }}", depth)
+				continue
+			}
+			c.stmt(n)
+			p.addTag("{% "+c.String()+" %}", depth)
+		case *ast.Statements:
+			p.statements(n, depth)
+		case *ast.Var, *ast.Const, *ast.TypeDeclaration:
+			j := declGroupEnd(nodes, i)
+			c := &code{inline: true}
+			c.decls(nodes[i:j])
+			p.addTag("{% "+c.String()+" %}", depth)
+			i = j - 1
+		default:
+			p.node(n, depth, "")
+		}
+	}
+}
+
+// node adds the segments of a template statement nested at the given depth.
+// label, if not empty, is the label of the statement followed by a colon.
+func (p *printer) node(node ast.Node, depth int, label string) {
+	c := &code{inline: true}
+	switch n := node.(type) {
+	case *ast.If:
+		c.ifHeader(n)
+		p.addTag("{% "+label+"if "+c.String()+" %}", depth)
+		p.nodes(n.Then.Nodes, depth+1)
+		for n.Else != nil {
+			if e, ok := n.Else.(*ast.If); ok {
+				c = &code{inline: true}
+				c.ifHeader(e)
+				p.addTag("{% else if "+c.String()+" %}", depth)
+				p.nodes(e.Then.Nodes, depth+1)
+				n = e
+				continue
+			}
+			p.addTag("{% else %}", depth)
+			p.nodes(n.Else.(*ast.Block).Nodes, depth+1)
+			break
+		}
+		p.addTag("{% end %}", depth)
+	case *ast.For, *ast.ForIn, *ast.ForRange:
+		body := c.forHeader(n)
+		p.addTag("{% "+label+c.String()+" %}", depth)
+		p.nodes(body, depth+1)
+		p.addTag("{% end %}", depth)
+	case *ast.Switch:
+		c.switchHeader(n.Init, n.Expr)
+		p.addTag("{% "+label+c.String()+" %}", depth)
+		p.addText(n.LeadingText)
+		for _, cas := range n.Cases {
+			p.caseClause(cas, depth)
+		}
+		p.addTag("{% end %}", depth)
+	case *ast.TypeSwitch:
+		c.switchHeader(n.Init, n.Assignment)
+		p.addTag("{% "+label+c.String()+" %}", depth)
+		p.addText(n.LeadingText)
+		for _, cas := range n.Cases {
+			p.caseClause(cas, depth)
+		}
+		p.addTag("{% end %}", depth)
+	case *ast.Select:
+		p.addTag("{% "+label+"select %}", depth)
+		p.addText(n.LeadingText)
+		for _, cas := range n.Cases {
+			if cas.Comm == nil {
+				p.addTag("{% default %}", depth)
+			} else {
+				c = &code{inline: true}
+				c.stmt(cas.Comm)
+				p.addTag("{% case "+c.String()+" %}", depth)
+			}
+			p.nodes(cas.Body, depth+1)
+		}
+		p.addTag("{% end %}", depth)
+	case *ast.Label:
+		p.node(n.Statement, depth, label+n.Ident.Name+": ")
+	case *ast.Func:
+		if !n.Type.Macro {
+			c.stmt(n)
+			p.addTag("{% "+c.String()+" %}", depth)
+			return
+		}
+		if n.DistFree {
+			p.addTag("{% "+n.Ident.Name+" %}", depth)
+			p.nodes(n.Body.Nodes, depth)
+			return
+		}
+		c.WriteString("macro " + n.Ident.Name)
+		c.signature(n.Type)
+		p.addTag("{% "+c.String()+" %}", depth)
+		p.nodes(n.Body.Nodes, depth+1)
+		p.addTag("{% end %}", depth)
+	case *ast.Using:
+		c.stmt(n.Statement)
+		c.WriteString("; using")
+		if n.Type != nil {
+			c.WriteString(" ")
+			c.expr(n.Type)
+		}
+		p.addTag("{% "+c.String()+" %}", depth)
+		p.nodes(n.Body.Nodes, depth+1)
+		p.addTag("{% end %}", depth)
+	case *ast.Raw:
+		c.WriteString("raw")
+		if n.Marker != "" {
+			c.WriteString(" " + n.Marker)
+		}
+		if n.Tag != "" {
+			c.WriteString(" " + strconv.Quote(n.Tag))
+		}
+		p.addTag("{% "+c.String()+" %}", depth)
+		p.addText(n.Text)
+		if n.Marker != "" {
+			p.addTag("{% end raw "+n.Marker+" %}", depth)
+		} else {
+			p.addTag("{% end %}", depth)
+		}
+	default:
+		c.stmt(n)
+		p.addTag("{% "+label+c.String()+" %}", depth)
+	}
+}
+
+// caseClause adds the segments of a case clause of a switch statement nested
+// at the given depth.
+func (p *printer) caseClause(cas *ast.Case, depth int) {
+	if cas.Expressions == nil {
+		p.addTag("{% default %}", depth)
+	} else {
+		c := &code{inline: true}
+		c.exprs(cas.Expressions)
+		p.addTag("{% case "+c.String()+" %}", depth)
+	}
+	p.nodes(cas.Body, depth+1)
+}
+
+// statements adds the tag of a {%% %%} statement nested at the given depth.
+// The statements are written on a single line only if they are on a single
+// line in the source.
+func (p *printer) statements(n *ast.Statements, depth int) {
+	if len(n.Nodes) == 0 {
+		p.addTag("{%% %%}", depth)
+		return
+	}
+	inline := true
+	if n.Position != nil {
+		Inspect(n, func(node ast.Node) bool {
+			if node == nil {
+				return false
+			}
+			if pos := node.Pos(); pos != nil && pos.Line != n.Line {
+				inline = false
+			}
+			return inline
+		})
+	}
+	if inline {
+		c := &code{inline: true}
+		c.stmts(n.Nodes)
+		p.addTag("{%% "+c.String()+" %%}", depth)
+		return
+	}
+	c := &code{indent: depth + 1}
+	c.WriteString("{%%")
+	c.stmts(n.Nodes)
+	c.WriteString("\n" + strings.Repeat("\t", depth) + "%%}")
+	p.addTag(c.String(), depth)
+}
+
+// source returns the source of the segments.
+//
+// A tag is alone in its line if the spaces around it are cut. In this case,
+// the spaces before the tag are replaced with a tab for each nesting level
+// and the spaces after the tag, up to the end of the line, are removed.
+func (p *printer) source() []byte {
+	var b bytes.Buffer
+	for i, s := range p.segments {
+		if s.text == nil {
+			b.WriteString(s.tag)
+			continue
+		}
+		txt := s.text.Text
+		left, right := s.text.Cut.Left, s.text.Cut.Right
+		if left+right > len(txt) {
+			b.Write(txt)
+			continue
+		}
+		if left > 0 && i > 0 && p.segments[i-1].text == nil {
+			b.Write(lineEnd(txt[:left]))
+		} else {
+			b.Write(txt[:left])
+		}
+		end := len(txt) - right
+		b.Write(txt[left:end])
+		if end > 0 && txt[end-1] == '\n' && p.alone(i+1) {
+			b.WriteString(strings.Repeat("\t", p.segments[i+1].depth))
+		} else {
+			b.Write(txt[end:])
+		}
+	}
+	return b.Bytes()
+}
+
+// alone reports whether the segment with index i is a tag alone in its line.
+func (p *printer) alone(i int) bool {
+	if i >= len(p.segments) || p.segments[i].text != nil {
+		return false
+	}
+	if prev := p.segments[i-1].text; prev != nil && prev.Cut.Right > 0 {
+		return true
+	}
+	if i+1 < len(p.segments) {
+		if next := p.segments[i+1].text; next != nil && next.Cut.Left > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// lineEnd returns the line ending of s, that contains only spaces, or an
+// empty slice if s has no line ending.
+func lineEnd(s []byte) []byte {
+	if bytes.HasSuffix(s, []byte("\r\n")) {
+		return []byte("\r\n")
+	}
+	if bytes.HasSuffix(s, []byte("\n")) {
+		return []byte("\n")
+	}
+	return nil
+}
+
+// declGroupEnd returns the index of the first node, after nodes[i], that
+// does not belong to the declaration group of nodes[i]. The declarations of
+// a group share the same position.
+func declGroupEnd(nodes []ast.Node, i int) int {
+	pos := nodes[i].Pos()
+	j := i + 1
+	if pos == nil {
+		return j
+	}
+	for ; j < len(nodes); j++ {
+		switch nodes[j].(type) {
+		case *ast.Var, *ast.Const, *ast.TypeDeclaration:
+			if nodes[j].Pos() == pos {
+				continue
+			}
+		}
+		break
+	}
+	return j
+}
+
+// code is a buffer in which code is printed.
+type code struct {
+	strings.Builder
+	inline bool // reports whether the statements are printed on a single line.
+	indent int  // indentation of the statements printed on multiple lines.
+}
+
+// stmts prints a list of statements.
+func (c *code) stmts(nodes []ast.Node) {
+	for i := 0; i < len(nodes); i++ {
+		if !c.inline {
+			c.WriteString("\n" + strings.Repeat("\t", c.indent))
+		} else if i > 0 {
+			c.WriteString("; ")
+		}
+		switch nodes[i].(type) {
+		case *ast.Var, *ast.Const, *ast.TypeDeclaration:
+			j := declGroupEnd(nodes, i)
+			c.decls(nodes[i:j])
+			i = j - 1
+		default:
+			c.stmt(nodes[i])
+		}
+	}
+}
+
+// block prints a block with the statements nodes.
+func (c *code) block(nodes []ast.Node) {
+	if len(nodes) == 0 {
+		c.WriteString("{}")
+		return
+	}
+	if c.inline {
+		c.WriteString("{ ")
+		c.stmts(nodes)
+		c.WriteString(" }")
+		return
+	}
+	c.WriteString("{")
+	c.indent++
+	c.stmts(nodes)
+	c.indent--
+	c.WriteString("\n" + strings.Repeat("\t", c.indent) + "}")
+}
+
+// stmt prints a statement.
+func (c *code) stmt(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.Assignment:
+		c.assignment(n)
+	case *ast.Block:
+		c.block(n.Nodes)
+	case *ast.Break:
+		c.WriteString("break")
+		c.label(n.Label)
+	case *ast.Continue:
+		c.WriteString("continue")
+		c.label(n.Label)
+	case *ast.Defer:
+		c.WriteString("defer ")
+		c.expr(n.Call)
+	case *ast.Extends:
+		c.WriteString("extends " + strconv.Quote(n.Path))
+	case *ast.Fallthrough:
+		c.WriteString("fallthrough")
+	case *ast.For, *ast.ForIn, *ast.ForRange:
+		body := c.forHeader(n)
+		c.WriteString(" ")
+		c.block(body)
+	case *ast.Func:
+		if n.Ident == nil {
+			c.expr(n)
+			return
+		}
+		c.WriteString("func ")
+		if n.Recv != nil {
+			c.WriteString("(")
+			c.params([]*ast.Parameter{n.Recv}, false)
+			c.WriteString(") ")
+		}
+		c.WriteString(n.Ident.Name)
+		if n.TypeParams != nil {
+			c.WriteString("[")
+			c.params(n.TypeParams, false)
+			c.WriteString("]")
+		}
+		c.signature(n.Type)
+		if n.Body != nil {
+			c.WriteString(" ")
+			c.block(n.Body.Nodes)
+		}
+	case *ast.Go:
+		c.WriteString("go ")
+		c.expr(n.Call)
+	case *ast.Goto:
+		c.WriteString("goto")
+		c.label(n.Label)
+	case *ast.If:
+		c.WriteString("if ")
+		c.ifHeader(n)
+		c.WriteString(" ")
+		c.block(n.Then.Nodes)
+		if n.Else != nil {
+			c.WriteString(" else ")
+			c.stmt(n.Else)
+		}
+	case *ast.Import:
+		c.WriteString("import ")
+		if n.Ident != nil {
+			c.WriteString(n.Ident.Name + " ")
+		}
+		c.WriteString(strconv.Quote(n.Path))
+		for i, ident := range n.For {
+			if i == 0 {
+				c.WriteString(" for ")
+			} else {
+				c.WriteString(", ")
+			}
+			c.WriteString(ident.Name)
+		}
+	case *ast.Label:
+		c.WriteString(n.Ident.Name + ":")
+		if n.Statement != nil {
+			c.WriteString(" ")
+			c.stmt(n.Statement)
+		}
+	case *ast.Return:
+		c.WriteString("return")
+		if len(n.Values) > 0 {
+			c.WriteString(" ")
+			c.exprs(n.Values)
+		}
+	case *ast.Select:
+		c.WriteString("select {")
+		for i, cas := range n.Cases {
+			var head string
+			if cas.Comm == nil {
+				head = "default"
+			} else {
+				cc := &code{inline: true}
+				cc.stmt(cas.Comm)
+				head = "case " + cc.String()
+			}
+			c.caseClause(head, cas.Body, i > 0 && len(n.Cases[i-1].Body) > 0)
+		}
+		c.closeCases(len(n.Cases) > 0)
+	case *ast.Send:
+		c.expr(n.Channel)
+		c.WriteString(" <- ")
+		c.expr(n.Value)
+	case *ast.Show:
+		c.WriteString("show ")
+		c.exprs(n.Expressions)
+	case *ast.Switch:
+		c.switchHeader(n.Init, n.Expr)
+		c.switchCases(n.Cases)
+	case *ast.TypeSwitch:
+		c.switchHeader(n.Init, n.Assignment)
+		c.switchCases(n.Cases)
+	case *ast.Var, *ast.Const, *ast.TypeDeclaration:
+		c.decls([]ast.Node{n})
+	case ast.Expression:
+		c.expr(n)
+	default:
+		panic(printError{node})
+	}
+}
+
+// label prints the label of a break, continue or goto statement.
+func (c *code) label(label *ast.Identifier) {
+	if label != nil {
+		c.WriteString(" " + label.Name)
+	}
+}
+
+// assignment prints an assignment.
+func (c *code) assignment(n *ast.Assignment) {
+	c.exprs(n.Lhs)
+	switch n.Type {
+	case ast.AssignmentIncrement:
+		c.WriteString("++")
+		return
+	case ast.AssignmentDecrement:
+		c.WriteString("--")
+		return
+	}
+	if len(n.Lhs) > 0 {
+		c.WriteString(" " + assignmentOperators[n.Type] + " ")
+	}
+	c.exprs(n.Rhs)
+}
+
+// assignmentOperators contains the operators of the assignments, indexed by
+// the assignment type.
+var assignmentOperators = [...]string{
+	ast.AssignmentSimple:         "=",
+	ast.AssignmentDeclaration:    ":=",
+	ast.AssignmentAddition:       "+=",
+	ast.AssignmentSubtraction:    "-=",
+	ast.AssignmentMultiplication: "*=",
+	ast.AssignmentDivision:       "/=",
+	ast.AssignmentModulo:         "%=",
+	ast.AssignmentAnd:            "&=",
+	ast.AssignmentOr:             "|=",
+	ast.AssignmentXor:            "^=",
+	ast.AssignmentAndNot:         "&^=",
+	ast.AssignmentLeftShift:      "<<=",
+	ast.AssignmentRightShift:     ">>=",
+}
+
+// ifHeader prints the header of an if statement, without the 'if' keyword.
+func (c *code) ifHeader(n *ast.If) {
+	if n.Init != nil {
+		c.stmt(n.Init)
+		c.WriteString("; ")
+	}
+	c.expr(n.Condition)
+}
+
+// forHeader prints the header of a for statement and returns its body.
+func (c *code) forHeader(node ast.Node) []ast.Node {
+	c.WriteString("for")
+	switch n := node.(type) {
+	case *ast.For:
+		if n.Init == nil && n.Post == nil {
+			if n.Condition != nil {
+				c.WriteString(" ")
+				c.expr(n.Condition)
+			}
+			return n.Body
+		}
+		c.WriteString(" ")
+		if n.Init != nil {
+			c.stmt(n.Init)
+		}
+		c.WriteString("; ")
+		if n.Condition != nil {
+			c.expr(n.Condition)
+		}
+		c.WriteString(";")
+		if n.Post != nil {
+			c.WriteString(" ")
+			c.stmt(n.Post)
+		}
+		return n.Body
+	case *ast.ForIn:
+		c.WriteString(" " + n.Ident.Name + " in ")
+		c.expr(n.Expr)
+		return n.Body
+	case *ast.ForRange:
+		c.WriteString(" ")
+		if a := n.Assignment; len(a.Lhs) > 0 {
+			c.exprs(a.Lhs)
+			c.WriteString(" " + assignmentOperators[a.Type] + " ")
+		}
+		c.WriteString("range ")
+		c.expr(n.Assignment.Rhs[0])
+		return n.Body
+	}
+	return nil
+}
+
+// switchHeader prints the header of a switch statement with the init
+// statement init and the expression, or the type switch guard, guard.
+func (c *code) switchHeader(init, guard ast.Node) {
+	c.WriteString("switch")
+	if init != nil {
+		c.WriteString(" ")
+		c.stmt(init)
+		c.WriteString(";")
+	}
+	if guard != nil {
+		c.WriteString(" ")
+		// The parser represents the guard "x.(type)" as "_ = x.(type)",
+		// with the identifier at the same position of the assertion.
+		if a, ok := guard.(*ast.Assignment); ok && len(a.Lhs) == 1 && a.Lhs[0].Pos() == a.Rhs[0].Pos() {
+			guard = a.Rhs[0]
+		}
+		c.stmt(guard)
+	}
+}
+
+// switchCases prints the case clauses of a switch statement in a block.
+func (c *code) switchCases(cases []*ast.Case) {
+	c.WriteString(" {")
+	for i, cas := range cases {
+		head := "default"
+		if cas.Expressions != nil {
+			cc := &code{inline: true}
+			cc.exprs(cas.Expressions)
+			head = "case " + cc.String()
+		}
+		c.caseClause(head, cas.Body, i > 0 && len(cases[i-1].Body) > 0)
+	}
+	c.closeCases(len(cases) > 0)
+}
+
+// caseClause prints a case clause with the given head and body. sep reports
+// whether, on a single line, the clause must be separated from the body of
+// the previous clause.
+func (c *code) caseClause(head string, body []ast.Node, sep bool) {
+	if c.inline {
+		if sep {
+			c.WriteString(";")
+		}
+		c.WriteString(" " + head + ":")
+		if len(body) > 0 {
+			c.WriteString(" ")
+			c.stmts(body)
+		}
+		return
+	}
+	c.WriteString("\n" + strings.Repeat("\t", c.indent) + head + ":")
+	c.indent++
+	c.stmts(body)
+	c.indent--
+}
+
+// closeCases closes the block of the case clauses.
+func (c *code) closeCases(hasCases bool) {
+	switch {
+	case !hasCases:
+		c.WriteString("}")
+	case c.inline:
+		c.WriteString(" }")
+	default:
+		c.WriteString("\n" + strings.Repeat("\t", c.indent) + "}")
+	}
+}
+
+// decls prints the declarations nodes, of the same group.
+func (c *code) decls(nodes []ast.Node) {
+	switch nodes[0].(type) {
+	case *ast.Var:
+		c.WriteString("var ")
+	case *ast.Const:
+		c.WriteString("const ")
+	case *ast.TypeDeclaration:
+		c.WriteString("type ")
+	}
+	if len(nodes) == 1 {
+		c.spec(nodes[0], "", "")
+		return
+	}
+	c.WriteString("(")
+	var typ, values string
+	for i, node := range nodes {
+		if !c.inline {
+			c.WriteString("\n" + strings.Repeat("\t", c.indent+1))
+		} else if i > 0 {
+			c.WriteString("; ")
+		} else {
+			c.WriteString(" ")
+		}
+		typ, values = c.spec(node, typ, values)
+	}
+	if c.inline {
+		c.WriteString(" )")
+		return
+	}
+	c.WriteString("\n" + strings.Repeat("\t", c.indent) + ")")
+}
+
+// spec prints the specification of a var, const or type declaration. For a
+// constant in a group, typ and values are the type and the values of the
+// previous constant of the group: if they are the same, they are omitted.
+// It returns the type and the values of the declaration.
+func (c *code) spec(node ast.Node, typ, values string) (string, string) {
+	switch n := node.(type) {
+	case *ast.Var:
+		c.idents(n.Lhs)
+		typ, values = c.typeAndValues(n.Type, n.Rhs)
+		c.WriteString(typ + values)
+	case *ast.Const:
+		c.idents(n.Lhs)
+		prevType, prevValues := typ, values
+		typ, values = c.typeAndValues(n.Type, n.Rhs)
+		if n.Index == 0 || typ != prevType || values != prevValues {
+			c.WriteString(typ + values)
+		}
+	case *ast.TypeDeclaration:
+		c.WriteString(n.Ident.Name)
+		if n.TypeParams != nil {
+			c.WriteString("[")
+			c.params(n.TypeParams, false)
+			c.WriteString("]")
+		}
+		if n.IsAliasDeclaration {
+			c.WriteString(" =")
+		}
+		c.WriteString(" ")
+		c.expr(n.Type)
+	}
+	return typ, values
+}
+
+// typeAndValues returns the type and the values of a var or const
+// declaration, as printed after the identifiers.
+func (c *code) typeAndValues(typ ast.Expression, values []ast.Expression) (string, string) {
+	var t, v string
+	if typ != nil {
+		cc := &code{inline: c.inline, indent: c.indent}
+		cc.expr(typ)
+		t = " " + cc.String()
+	}
+	if values != nil {
+		cc := &code{inline: c.inline, indent: c.indent}
+		cc.exprs(values)
+		v = " = " + cc.String()
+	}
+	return t, v
+}
+
+// idents prints a list of identifiers.
+func (c *code) idents(idents []*ast.Identifier) {
+	for i, ident := range idents {
+		if i > 0 {
+			c.WriteString(", ")
+		}
+		c.WriteString(ident.Name)
+	}
+}
+
+// exprs prints a list of expressions.
+func (c *code) exprs(exprs []ast.Expression) {
+	for i, expr := range exprs {
+		if i > 0 {
+			c.WriteString(", ")
+		}
+		c.expr(expr)
+	}
+}
+
+// signature prints the parameters and the result of a function or macro
+// type. The parameters of a macro without parameters are not printed.
+func (c *code) signature(typ *ast.FuncType) {
+	if typ.Parameters != nil || !typ.Macro {
+		c.WriteString("(")
+		c.params(typ.Parameters, typ.IsVariadic)
+		c.WriteString(")")
+	}
+	switch {
+	case len(typ.Result) == 0:
+	case len(typ.Result) == 1 && typ.Result[0].Ident == nil:
+		c.WriteString(" ")
+		c.expr(typ.Result[0].Type)
+	default:
+		c.WriteString(" (")
+		c.params(typ.Result, false)
+		c.WriteString(")")
+	}
+}
+
+// params prints a list of parameters. isVariadic reports whether the last
+// parameter is variadic.
+func (c *code) params(params []*ast.Parameter, isVariadic bool) {
+	for i, param := range params {
+		if i > 0 {
+			c.WriteString(", ")
+		}
+		if param.Ident != nil {
+			c.WriteString(param.Ident.Name)
+			if param.Type == nil {
+				continue
+			}
+			c.WriteString(" ")
+		}
+		if isVariadic && i == len(params)-1 {
+			c.WriteString("...")
+		}
+		c.expr(param.Type)
+	}
+}
+
+// fields prints the fields of a struct type or the methods of an interface
+// type.
+func (c *code) fields(fields []*ast.Field, isInterface bool) {
+	if len(fields) == 0 {
+		c.WriteString("{}")
+		return
+	}
+	c.WriteString("{ ")
+	for i, field := range fields {
+		if i > 0 {
+			c.WriteString("; ")
+		}
+		if isInterface && field.Idents != nil {
+			c.WriteString(field.Idents[0].Name)
+			c.signature(field.Type.(*ast.FuncType))
+			continue
+		}
+		if field.Idents != nil {
+			c.idents(field.Idents)
+			c.WriteString(" ")
+		}
+		c.expr(field.Type)
+		if field.Tag != "" {
+			if strconv.CanBackquote(field.Tag) {
+				c.WriteString(" `" + field.Tag + "`")
+			} else {
+				c.WriteString(" " + strconv.Quote(field.Tag))
+			}
+		}
+	}
+	c.WriteString(" }")
+}
+
+// expr prints an expression with its parenthesis.
+func (c *code) expr(expr ast.Expression) {
+	n := expr.Parenthesis()
+	for i := 0; i < n; i++ {
+		c.WriteString("(")
+	}
+	switch e := expr.(type) {
+	case *ast.ArrayType:
+		c.WriteString("[")
+		if e.Len == nil {
+			c.WriteString("...")
+		} else {
+			c.expr(e.Len)
+		}
+		c.WriteString("]")
+		c.expr(e.ElementType)
+	case *ast.BasicLiteral:
+		c.WriteString(e.Value)
+	case *ast.BinaryOperator:
+		c.expr(e.Expr1)
+		c.WriteString(" " + e.Op.String() + " ")
+		c.expr(e.Expr2)
+	case *ast.Call:
+		c.expr(e.Func)
+		c.WriteString("(")
+		c.exprs(e.Args)
+		if e.IsVariadic {
+			c.WriteString("...")
+		}
+		c.WriteString(")")
+	case *ast.ChanType:
+		switch e.Direction {
+		case ast.ReceiveDirection:
+			c.WriteString("<-chan ")
+		case ast.SendDirection:
+			c.WriteString("chan<- ")
+		default:
+			c.WriteString("chan ")
+		}
+		c.expr(e.ElementType)
+	case *ast.CompositeLiteral:
+		if e.Type != nil {
+			c.expr(e.Type)
+		}
+		c.WriteString("{")
+		for i, kv := range e.KeyValues {
+			if i > 0 {
+				c.WriteString(", ")
+			}
+			if kv.Key != nil {
+				c.expr(kv.Key)
+				c.WriteString(": ")
+			}
+			c.expr(kv.Value)
+		}
+		c.WriteString("}")
+	case *ast.Default:
+		c.expr(e.Expr1)
+		c.WriteString(" default ")
+		c.expr(e.Expr2)
+	case *ast.DollarIdentifier:
+		c.WriteString("$" + e.Ident.Name)
+	case *ast.Func:
+		c.WriteString("func")
+		c.signature(e.Type)
+		c.WriteString(" ")
+		c.block(e.Body.Nodes)
+	case *ast.FuncType:
+		if e.Macro {
+			c.WriteString("macro")
+		} else {
+			c.WriteString("func")
+		}
+		c.signature(e)
+	case *ast.Identifier:
+		c.WriteString(e.Name)
+	case *ast.Index:
+		c.expr(e.Expr)
+		c.WriteString("[")
+		c.expr(e.Index)
+		c.WriteString("]")
+	case *ast.Instantiation:
+		c.expr(e.Expr)
+		c.WriteString("[")
+		c.exprs(e.TypeArgs)
+		c.WriteString("]")
+	case *ast.Interface:
+		c.WriteString("interface")
+		c.fields(e.Methods, true)
+	case *ast.MapType:
+		c.WriteString("map[")
+		c.expr(e.KeyType)
+		c.WriteString("]")
+		c.expr(e.ValueType)
+	case *ast.Render:
+		c.WriteString("render " + strconv.Quote(e.Path))
+	case *ast.Selector:
+		c.expr(e.Expr)
+		c.WriteString("." + e.Ident)
+	case *ast.SliceType:
+		c.WriteString("[]")
+		c.expr(e.ElementType)
+	case *ast.Slicing:
+		c.expr(e.Expr)
+		c.WriteString("[")
+		if e.Low != nil {
+			c.expr(e.Low)
+		}
+		c.WriteString(":")
+		if e.High != nil {
+			c.expr(e.High)
+		}
+		if e.IsFull {
+			c.WriteString(":")
+			c.expr(e.Max)
+		}
+		c.WriteString("]")
+	case *ast.StructType:
+		c.WriteString("struct")
+		c.fields(e.Fields, false)
+	case *ast.TypeAssertion:
+		c.expr(e.Expr)
+		if e.Type == nil {
+			c.WriteString(".(type)")
+		} else {
+			c.WriteString(".(")
+			c.expr(e.Type)
+			c.WriteString(")")
+		}
+	case *ast.UnaryOperator:
+		op := e.Op.String()
+		if e.Op == ast.OperatorExtendedNot {
+			op += " "
+		}
+		operand := &code{inline: c.inline, indent: c.indent}
+		operand.expr(e.Expr)
+		s := operand.String()
+		if s != "" && op[len(op)-1] == s[0] {
+			// Avoid that the operators form a single token, as in "- -a".
+			op += " "
+		}
+		c.WriteString(op + s)
+	default:
+		panic(printError{expr})
+	}
+	for i := 0; i < n; i++ {
+		c.WriteString(")")
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package astutil_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/open2b/scriggo"
+	"github.com/open2b/scriggo/ast"
+	"github.com/open2b/scriggo/ast/astutil"
+	"github.com/open2b/scriggo/internal/compiler"
+)
+
+var printTests = []struct {
+	src      string
+	expected string
+}{
+	// Spacing.
+	{"{%var a=1%}{{a+2}}{{(a+1)*3}}{{-a}}{{ - -a }}", "{% var a = 1 %}{{ a + 2 }}{{ (a + 1) * 3 }}{{ -a }}{{ - -a }}"},
+	{"{%  var s = []int{1,2,  3}  %}{{ len(s[1:2]) }}{{len( s )}}", "{% var s = []int{1, 2, 3} %}{{ len(s[1:2]) }}{{ len(s) }}"},
+	{"{% var m = map[string]int{\"a\":1} %}{{ m[\"a\"] }}{{y  default 3}}", "{% var m = map[string]int{\"a\": 1} %}{{ m[\"a\"] }}{{ y default 3 }}"},
+	{"{% const ( a = iota; b; c ) %}{{ a }}{{ b }}{{ c }}", "{% const ( a = iota; b; c ) %}{{ a }}{{ b }}{{ c }}"},
+	{"{% var f = func(a, b int, c ...int) (int, error) { if a > b { return a, nil }; return b, nil } %}{{ f(1, 2) }}", "{% var f = func(a, b int, c ...int) (int, error) { if a > b { return a, nil }; return b, nil } %}{{ f(1, 2) }}"},
+	{"{% var x interface{} = 5 %}{% if v, ok := x.(int); ok && not false %}{{ v }}{% end %}", "{% var x interface{} = 5 %}{% if v, ok := x.(int); ok && not false %}{{ v }}{% end %}"},
+	{"{% show 1, \"a\" %}{% show 2 %}", "{% show 1, \"a\" %}{% show 2 %}"},
+
+	// Indentation.
+	{"<ul>\n{% for i := 0; i < 3; i++ %}\n  {% if i > 0 %}\n<li>{{ i }}</li>\n    {% else if i == 1 %}\none\n{%else%}\n\t\t {# comment #}  \n  {% end if %}\n{% end for %}\n</ul>\n", "<ul>\n{% for i := 0; i < 3; i++ %}\n\t{% if i > 0 %}\n<li>{{ i }}</li>\n\t{% else if i == 1 %}\none\n\t{% else %}\n\t\t{# comment #}\n\t{% end %}\n{% end %}\n</ul>\n"},
+	{"{% for _, v := range []int{1, 2} %}{% if v > 1 %}\n<b>{{ v }}</b>\n{% end %}{% end %}\n", "{% for _, v := range []int{1, 2} %}{% if v > 1 %}\n<b>{{ v }}</b>\n{% end %}{% end %}\n"},
+	{"{% switch x := 2; x %}\n  {% case 1, 2 %}\n    one or two\n      {% default %}\n    other\n{% end %}\n", "{% switch x := 2; x %}\n{% case 1, 2 %}\n    one or two\n{% default %}\n    other\n{% end %}\n"},
+	{"{% if true %}\n{% switch 2 %}\n{% case 1 %}\none\n{% default %}\nother\n{% end %}\n{% end %}", "{% if true %}\n\t{% switch 2 %}\n\t{% case 1 %}\none\n\t{% default %}\nother\n\t{% end %}\n{% end %}"},
+	{"{% if true %}\n  {% switch interface{}(1).(type) %}\n    {% case int %}\n  int\n    {% case string %}\n  string\n  {% end %}\n{% end %}", "{% if true %}\n\t{% switch interface{}(1).(type) %}\n\t{% case int %}\n  int\n\t{% case string %}\n  string\n\t{% end %}\n{% end %}"},
+	{"{% switch 1 %}  {% case 1 %}one{% end %}", "{% switch 1 %}  {% case 1 %}one{% end %}"},
+	{"{% macro M(s string) %}\n    <p>{{ s }}</p>\n    {% end macro %}\n{{ M(\"a\") }}\n", "{% macro M(s string) %}\n    <p>{{ s }}</p>\n{% end %}\n{{ M(\"a\") }}\n"},
+	{"{% macro B %}\n  {%% for i := 0; i < 2; i++ {\n      show i\n  } %%}\n  {%% var b = 2; show b %%}\n{% end %}\n{{ B() }}{% var t = itea; using %}\n   text\n  {% end %}{{ t }}\n", "{% macro B %}\n\t{%%\n\t\tfor i := 0; i < 2; i++ {\n\t\t\tshow i\n\t\t}\n\t%%}\n  {%% var b = 2; show b %%}\n{% end %}\n{{ B() }}{% var t = itea; using %}\n   text\n  {% end %}{{ t }}\n"},
+	{"{% raw code %}\n  {{ a }}\n    {% end raw code %}\n", "{% raw code %}\n  {{ a }}\n{% end raw code %}\n"},
+	{"a\r\n  {% if true %}  \r\n  b\r\n  {% end %}\r\n", "a\r\n{% if true %}\r\n  b\r\n{% end %}\r\n"},
+	{"{% if true %}\n  a   \n{% end %}   ", "{% if true %}\n  a   \n{% end %}"},
+}
+
+// fprint parses src and prints its tree with astutil.Fprint.
+func fprint(t *testing.T, src string) string {
+	t.Helper()
+	tree, _, err := compiler.ParseTemplateSource([]byte(src), ast.FormatHTML, false, false, false, false)
+	if err != nil {
+		t.Fatalf("source %q: %s", src, err)
+	}
+	var b strings.Builder
+	err = astutil.Fprint(&b, tree)
+	if err != nil {
+		t.Fatalf("source %q: %s", src, err)
+	}
+	return b.String()
+}
+
+// render builds and runs the template with source src.
+func render(t *testing.T, src string) string {
+	t.Helper()
+	fsys := fstest.MapFS{"index.html": {Data: []byte(src)}}
+	template, err := scriggo.BuildTemplate(fsys, "index.html", nil)
+	if err != nil {
+		t.Fatalf("source %q: %s", src, err)
+	}
+	var b strings.Builder
+	err = template.Run(&b, nil, nil)
+	if err != nil {
+		t.Fatalf("source %q: %s", src, err)
+	}
+	return b.String()
+}
+
+func TestFprint(t *testing.T) {
+	for _, test := range printTests {
+		got := fprint(t, test.src)
+		if got != test.expected {
+			t.Errorf("source %q:\nexpecting %q\ngot       %q", test.src, test.expected, got)
+		}
+		if again := fprint(t, got); again != got {
+			t.Errorf("source %q: formatting is not idempotent, got %q", test.src, again)
+		}
+		if expected, got := render(t, test.src), render(t, got); got != expected {
+			t.Errorf("source %q: formatted source renders %q, expecting %q", test.src, got, expected)
+		}
+	}
+}
@@ -178,6 +178,12 @@ func Walk(v Visitor, node ast.Node) {
 		Walk(v, n.Expr)
 		Walk(v, n.Index)
 
+	case *ast.Instantiation:
+		Walk(v, n.Expr)
+		for _, arg := range n.TypeArgs {
+			Walk(v, arg)
+		}
+
 	case *ast.Label:
 		Walk(v, n.Ident)
 		Walk(v, n.Statement)
@@ -239,6 +245,11 @@ func Walk(v Visitor, node ast.Node) {
 			Walk(v, child)
 		}
 
+	case *ast.StructType:
+		for _, field := range n.Fields {
+			Walk(v, field.Type)
+		}
+
 	case *ast.Switch:
 		Walk(v, n.Init)
 		Walk(v, n.Expr)
@@ -251,6 +262,9 @@ func Walk(v Visitor, node ast.Node) {
 			Walk(v, child)
 		}
 
+	case *ast.TypeDeclaration:
+		Walk(v, n.Type)
+
 	case *ast.TypeAssertion:
 		Walk(v, n.Expr)
 
@@ -278,6 +292,11 @@ func Walk(v Visitor, node ast.Node) {
 			Walk(v, value)
 		}
 
+	case *ast.Interface:
+		for _, m := range n.Methods {
+			Walk(v, m.Type)
+		}
+
 	case *ast.Extends:
 	case *ast.Import:
 	case *ast.Render:
@@ -290,7 +309,6 @@ func Walk(v Visitor, node ast.Node) {
 		*ast.Text,
 		*ast.Raw,
 		*ast.Placeholder,
-		*ast.Interface,
 		*ast.Fallthrough:
 		// Nothing to do
 
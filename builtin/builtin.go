@@ -52,6 +52,14 @@
 //
 //  	// html
 //  	"htmlEscape": builtin.HtmlEscape,
+//  	"nonce":      builtin.Nonce,
+//
+//  	// i18n
+//  	"formatDate":       builtin.FormatDate,
+//  	"formatNumber":     builtin.FormatNumber,
+//  	"translate":        builtin.Translate,
+//  	"translateContext": builtin.TranslateContext,
+//  	"translatePlural":  builtin.TranslatePlural,
 //
 //  	// math
 //  	"abs": builtin.Abs,
@@ -62,6 +70,7 @@
 //  	// net
 //  	"File":        reflect.TypeOf((*builtin.File)(nil)).Elem(),
 //  	"FormData":    reflect.TypeOf(builtin.FormData{}),
+//  	"flush":       builtin.Flush,
 //  	"form":        (*builtin.FormData)(nil),
 //  	"queryEscape": builtin.QueryEscape,
 //
@@ -270,6 +279,54 @@ func Date(year, month, day, hour, min, sec, nsec int, location string) (Time, er
 	return NewTime(time.Date(year, time.Month(month), day, hour, min, sec, nsec, loc)), nil
 }
 
+// Flush flushes the output rendered so far, so that it can be sent to the
+// client before the rest of the template is rendered. It has effect only if
+// the template output has a Flush method, as an http.ResponseWriter that
+// implements http.Flusher.
+//
+// For example, to send the head of a page before loading slow data
+//
+//    <head>...</head>
+//    {% flush() %}
+//    <body>{{ slowData() }}</body>
+//
+func Flush(env native.Env) {
+	if env, ok := env.(native.RenderEnv); ok {
+		env.Flush()
+	}
+}
+
+// FormatDate formats the date of t in the style of the locale of the
+// template execution, that is the Locale option passed to the Run method of
+// the template. The style is one of "short", "medium", "long" or "full".
+//
+// For example, in the "en-US" locale
+//
+//    short:  1/2/06
+//    medium: Jan 2, 2006
+//    long:   January 2, 2006
+//    full:   Monday, January 2, 2006
+//
+// The supported languages are Chinese (zh), Dutch (nl), English (en and
+// en-GB), French (fr), German (de), Italian (it), Japanese (ja), Korean (ko),
+// Polish (pl), Portuguese (pt), Russian (ru), Spanish (es), Swedish (sv) and
+// Turkish (tr). If no locale is set, it uses the "en-US" locale.
+//
+// If the locale is not supported or the style is not valid, FormatDate
+// panics.
+func FormatDate(env native.Env, t Time, style string) string {
+	locale := envLocale(env)
+	l, ok := lookupLocale(locale)
+	if !ok {
+		panic("formatDate: unsupported locale " + strconv.Quote(locale))
+	}
+	s, ok := l.formatDate(t.t, style)
+	if !ok {
+		panic("formatDate: invalid style " + strconv.Quote(style))
+	}
+	return s
+}
+
 // FormatFloat converts the floating-point number f to a string, according to
 // the given format and precision. It can round the result.
 //
@@ -309,6 +366,27 @@ func FormatInt(i int, base int) string {
 	return strconv.FormatInt(int64(i), base)
 }
 
+// FormatNumber formats number with the given number of decimals, using the
+// decimal and group separators of the locale of the template execution, that
+// is the Locale option passed to the Run method of the template. If decimals
+// is negative, it uses the smallest number of decimals necessary to represent
+// number exactly.
+//
+// For example, formatNumber(1234.5, 2) returns "1,234.50" in the "en-US"
+// locale and "1.234,50" in the "it-IT" locale.
+//
+// The supported locales are the same as FormatDate. If no locale is set, it
+// uses the "en-US" locale. If the locale is not supported, FormatNumber
+// panics.
+func FormatNumber(env native.Env, number float64, decimals int) string {
+	locale := envLocale(env)
+	l, ok := lookupLocale(locale)
+	if !ok {
+		panic("formatNumber: unsupported locale " + strconv.Quote(locale))
+	}
+	return l.formatNumber(number, decimals)
+}
+
 // HasPrefix tests whether the string s begins with prefix.
 func HasPrefix(s, prefix string) bool {
 	return strings.HasPrefix(s, prefix)
@@ -435,6 +513,20 @@ func Min(x, y int) int {
 	return x
 }
 
+// Nonce returns the nonce of the script and style elements, that is the
+// nonce passed to the Run method of the template as the Nonce option.
+//
+// For example, to use it in a tag added by a template
+//
+//    <link rel="preload" href="/app.js" as="script" nonce="{{ nonce() }}">
+//
+func Nonce(env native.Env) string {
+	if env, ok := env.(native.RenderEnv); ok {
+		return env.Nonce()
+	}
+	return ""
+}
+
 // Now returns the current local time.
 func Now() Time {
 	return NewTime(time.Now())
@@ -793,6 +885,55 @@ func ToUpper(s string) string {
 	return strings.ToUpper(s)
 }
 
+// Translate returns the translation of msg in the catalog of the template
+// execution, that is the Catalog option passed to the Run method of the
+// template. If there is no catalog or msg has no translation, it returns msg.
+//
+// For example
+//
+//    {{ translate("Add to cart") }}
+//
+func Translate(env native.Env, msg string) string {
+	if c := envCatalog(env); c != nil {
+		return c.Translate("", msg, "", 1)
+	}
+	return msg
+}
+
+// TranslateContext is like Translate but translates msg in the context ctx.
+// The context distinguishes the messages with the same text but different
+// meanings.
+//
+// For example
+//
+//    {{ translateContext("month name", "May") }}
+//
+func TranslateContext(env native.Env, ctx, msg string) string {
+	if c := envCatalog(env); c != nil {
+		return c.Translate(ctx, msg, "", 1)
+	}
+	return msg
+}
+
+// TranslatePlural returns the translation of msg, with plural form plural, in
+// the catalog of the template execution, selecting the plural form of the
+// translation with n. If there is no catalog or the message has no
+// translation, it returns msg if n is 1 and plural otherwise.
+//
+// For example
+//
+//    {{ sprintf(translatePlural("%d item", "%d items", n), n) }}
+//
+func TranslatePlural(env native.Env, msg, plural string, n int) string {
+	if c := envCatalog(env); c != nil {
+		return c.Translate("", msg, plural, n)
+	}
+	if n == 1 {
+		return msg
+	}
+	return plural
+}
+
 // Trim returns a slice of the string s with all leading and
 // trailing Unicode code points contained in cutset removed.
 func Trim(s, cutset string) string {
@@ -917,3 +1058,21 @@ func onlyJSONWhitespace(s string) bool {
 func replacePrefix(err error, old, new string) error {
 	return errors.New(new + ": " + strings.TrimPrefix(err.Error(), old+": "))
 }
+
+// envCatalog returns the message catalog of env, or nil if env does not
+// implement native.RenderEnv.
+func envCatalog(env native.Env) native.Catalog {
+	if env, ok := env.(native.RenderEnv); ok {
+		return env.Catalog()
+	}
+	return nil
+}
+
+// envLocale returns the locale of env, or the empty string if env does not
+// implement native.RenderEnv.
+func envLocale(env native.Env) string {
+	if env, ok := env.(native.RenderEnv); ok {
+		return env.Locale()
+	}
+	return ""
+}
@@ -89,6 +89,34 @@ var tests = []struct {
 		return t.Format(time.RFC3339Nano)
 	}(), "2021-03-27T17:18:51+01:00"},
 
+	// formatDate
+	{FormatDate(localeEnv("en-US", nil), NewTime(testDate), "short"), "1/2/06"},
+	{FormatDate(localeEnv("en-US", nil), NewTime(testDate), "medium"), "Jan 2, 2006"},
+	{FormatDate(localeEnv("en-US", nil), NewTime(testDate), "long"), "January 2, 2006"},
+	{FormatDate(localeEnv("en-US", nil), NewTime(testDate), "full"), "Monday, January 2, 2006"},
+	{FormatDate(localeEnv("en-GB", nil), NewTime(testDate), "short"), "02/01/2006"},
+	{FormatDate(localeEnv("it_IT", nil), NewTime(testDate), "short"), "02/01/06"},
+	{FormatDate(localeEnv("it-IT", nil), NewTime(testDate), "full"), "lunedì 2 gennaio 2006"},
+	{FormatDate(localeEnv("de", nil), NewTime(testDate), "long"), "2. Januar 2006"},
+	{FormatDate(localeEnv("es-ES", nil), NewTime(testDate), "long"), "2 de enero de 2006"},
+	{FormatDate(localeEnv("ja-JP", nil), NewTime(testDate), "full"), "2006年1月2日月曜日"},
+	{FormatDate(localeEnv("ko", nil), NewTime(testDate), "long"), "2006년 1월 2일"},
+	{FormatDate(localeEnv("pl-PL", nil), NewTime(testDate), "long"), "2 stycznia 2006"},
+	{FormatDate(localeEnv("ru", nil), NewTime(testDate), "long"), "2 января 2006 г."},
+	{FormatDate(localeEnv("sv-SE", nil), NewTime(testDate), "short"), "2006-01-02"},
+	{FormatDate(localeEnv("tr", nil), NewTime(testDate), "full"), "2 Ocak 2006 Pazartesi"},
+	{FormatDate(localeEnv("zh-CN", nil), NewTime(testDate), "full"), "2006年1月2日星期一"},
+	{FormatDate(localeEnv("", nil), NewTime(testDate), "medium"), "Jan 2, 2006"},
+	{FormatDate(plainEnv{}, NewTime(testDate), "medium"), "Jan 2, 2006"},
+	{func() (s string) {
+		defer func() { s = sp(recover()) }()
+		return FormatDate(localeEnv("en", nil), NewTime(testDate), "tiny")
+	}(), `formatDate: invalid style "tiny"`},
+	{func() (s string) {
+		defer func() { s = sp(recover()) }()
+		return FormatDate(localeEnv("xx", nil), NewTime(testDate), "medium")
+	}(), `formatDate: unsupported locale "xx"`},
+
 	// formatFloat
 	{spf(FormatFloat(0, "f", -1)), "0"},
 	{spf(FormatFloat(5.2307, "f", -1)), "5.2307"},
@@ -100,6 +128,23 @@ var tests = []struct {
 	{sp(FormatInt(-22, 10)), "-22"},
 	{sp(FormatInt(334, 16)), "14e"},
 
+	// formatNumber
+	{FormatNumber(localeEnv("en-US", nil), 0, 0), "0"},
+	{FormatNumber(localeEnv("en-US", nil), 1234.5, 2), "1,234.50"},
+	{FormatNumber(localeEnv("en-US", nil), -1234567.891, -1), "-1,234,567.891"},
+	{FormatNumber(localeEnv("en-US", nil), 123, -1), "123"},
+	{FormatNumber(localeEnv("it-IT", nil), 1234.5, 2), "1.234,50"},
+	{FormatNumber(localeEnv("fr", nil), 1234.5, 1), "1\u202f234,5"},
+	{FormatNumber(localeEnv("de", nil), 999.996, 2), "1.000,00"},
+	{FormatNumber(localeEnv("en", nil), math.Inf(1), 2), "+Inf"},
+	{FormatNumber(plainEnv{}, 1234.5, 2), "1,234.50"},
+	{FormatNumber(localeEnv("ru-RU", nil), 1234.5, 2), "1\u00a0234,50"},
+	{FormatNumber(localeEnv("zh-TW", nil), 1234.5, 2), "1,234.50"},
+	{func() (s string) {
+		defer func() { s = sp(recover()) }()
+		return FormatNumber(localeEnv("fi-FI", nil), 1234.5, 2)
+	}(), `formatNumber: unsupported locale "fi-FI"`},
+
 	// htmlEscape
 	{spf("%s", HtmlEscape(``)), ""},
 	{spf("%s", HtmlEscape(`a`)), "a"},
@@ -160,6 +205,9 @@ var tests = []struct {
 	{spf("%d", Min(-7, 5)), "-7"},
 	{spf("%d", Min(7, -5)), "-5"},
 
+	// nonce
+	{Nonce(plainEnv{}), ""},
+
 	// now
 	{spf("%t", func() bool {
 		t1 := NewTime(time.Now())
@@ -294,6 +342,23 @@ var tests = []struct {
 	{ToKebab("€€AB"), "ab"},
 	{ToKebab("AB€€"), "ab"},
 
+	// translate
+	{Translate(localeEnv("it", nil), "Hello"), "Hello"},
+	{Translate(localeEnv("it", testCatalog), "Hello"), "Ciao"},
+	{Translate(localeEnv("it", testCatalog), "Goodbye"), "Goodbye"},
+	{Translate(plainEnv{}, "Hello"), "Hello"},
+
+	// translateContext
+	{TranslateContext(localeEnv("it", nil), "month", "May"), "May"},
+	{TranslateContext(localeEnv("it", testCatalog), "month", "May"), "Maggio"},
+	{TranslateContext(localeEnv("it", testCatalog), "", "May"), "May"},
+
+	// translatePlural
+	{TranslatePlural(localeEnv("it", nil), "%d apple", "%d apples", 1), "%d apple"},
+	{TranslatePlural(localeEnv("it", nil), "%d apple", "%d apples", 0), "%d apples"},
+	{TranslatePlural(localeEnv("it", testCatalog), "%d apple", "%d apples", 1), "%d mela"},
+	{TranslatePlural(localeEnv("it", testCatalog), "%d apple", "%d apples", 3), "%d mele"},
+
 	// unixTime
 	{UnixTime(0, 0).UTC().Format(time.RFC3339Nano), "1970-01-01T00:00:00Z"},
 	{UnixTime(1616964058, 0).UTC().Format(time.RFC3339Nano), "2021-03-28T20:40:58Z"},
@@ -316,6 +381,53 @@ var tests = []struct {
 	{string(toMarkdown("# a title")), "# a title"},
 }
 
+var testDate = time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+
+// testEnv implements the Locale and Catalog methods of native.RenderEnv.
+type testEnv struct {
+	native.RenderEnv
+	locale  string
+	catalog native.Catalog
+}
+
+func (env testEnv) Locale() string          { return env.locale }
+func (env testEnv) Catalog() native.Catalog { return env.catalog }
+
+func localeEnv(locale string, catalog native.Catalog) native.Env {
+	return testEnv{locale: locale, catalog: catalog}
+}
+
+// plainEnv implements native.Env but not native.RenderEnv.
+type plainEnv struct {
+	native.Env
+}
+
+// testCatalog is a catalog with some Italian translations.
+var testCatalog = mapCatalog{
+	"Hello":     {"Ciao"},
+	"month|May": {"Maggio"},
+	"%d apple":  {"%d mela", "%d mele"},
+}
+
+type mapCatalog map[string][]string
+
+func (c mapCatalog) Translate(ctx, msg, plural string, n int) string {
+	key := msg
+	if ctx != "" {
+		key = ctx + "|" + msg
+	}
+	if tr, ok := c[key]; ok {
+		if plural != "" && n != 1 {
+			return tr[1]
+		}
+		return tr[0]
+	}
+	if plural != "" && n != 1 {
+		return plural
+	}
+	return msg
+}
+
 func TestBuiltins(t *testing.T) {
 	for _, expr := range tests {
 		if expr.got != expr.expected {
@@ -0,0 +1,270 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package builtin
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// localeData contains the data of a locale used to format dates and numbers.
+type localeData struct {
+	months      [12]string
+	shortMonths [12]string
+	weekdays    [7]string // from Sunday.
+	dateFormats [4]string // short, medium, long and full date patterns.
+	decimal     string    // decimal separator.
+	group       string    // group separator.
+}
+
+// locales contains the data of the supported locales, indexed by language or
+// by language and region.
+//
+// The date patterns use the letters d, dd (day), M, MM, MMM, MMMM (month),
+// y, yy (year) and EEEE (weekday). Text between single quotes is copied as
+// is.
+var locales = map[string]*localeData{
+	"en": {
+		months:      [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		shortMonths: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		weekdays:    [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		dateFormats: [4]string{"M/d/yy", "MMM d, y", "MMMM d, y", "EEEE, MMMM d, y"},
+		decimal:     ".",
+		group:       ",",
+	},
+	"en-gb": {
+		months:      [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		shortMonths: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sept", "Oct", "Nov", "Dec"},
+		weekdays:    [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		dateFormats: [4]string{"dd/MM/y", "d MMM y", "d MMMM y", "EEEE d MMMM y"},
+		decimal:     ".",
+		group:       ",",
+	},
+	"de": {
+		months:      [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		shortMonths: [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		weekdays:    [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		dateFormats: [4]string{"dd.MM.yy", "dd.MM.y", "d. MMMM y", "EEEE, d. MMMM y"},
+		decimal:     ",",
+		group:       ".",
+	},
+	"es": {
+		months:      [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		shortMonths: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		weekdays:    [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		dateFormats: [4]string{"d/M/yy", "d MMM y", "d 'de' MMMM 'de' y", "EEEE, d 'de' MMMM 'de' y"},
+		decimal:     ",",
+		group:       ".",
+	},
+	"fr": {
+		months:      [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		shortMonths: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		weekdays:    [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		dateFormats: [4]string{"dd/MM/y", "d MMM y", "d MMMM y", "EEEE d MMMM y"},
+		decimal:     ",",
+		group:       "\u202f",
+	},
+	"it": {
+		months:      [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		shortMonths: [12]string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		weekdays:    [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		dateFormats: [4]string{"dd/MM/yy", "d MMM y", "d MMMM y", "EEEE d MMMM y"},
+		decimal:     ",",
+		group:       ".",
+	},
+	"ja": {
+		months:      [12]string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		shortMonths: [12]string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		weekdays:    [7]string{"日曜日", "月曜日", "火曜日", "水曜日", "木曜日", "金曜日", "土曜日"},
+		dateFormats: [4]string{"y/MM/dd", "y/MM/dd", "y年M月d日", "y年M月d日EEEE"},
+		decimal:     ".",
+		group:       ",",
+	},
+	"ko": {
+		months:      [12]string{"1월", "2월", "3월", "4월", "5월", "6월", "7월", "8월", "9월", "10월", "11월", "12월"},
+		shortMonths: [12]string{"1월", "2월", "3월", "4월", "5월", "6월", "7월", "8월", "9월", "10월", "11월", "12월"},
+		weekdays:    [7]string{"일요일", "월요일", "화요일", "수요일", "목요일", "금요일", "토요일"},
+		dateFormats: [4]string{"yy. M. d.", "y. M. d.", "y년 M월 d일", "y년 M월 d일 EEEE"},
+		decimal:     ".",
+		group:       ",",
+	},
+	"nl": {
+		months:      [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		shortMonths: [12]string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		weekdays:    [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		dateFormats: [4]string{"dd-MM-y", "d MMM y", "d MMMM y", "EEEE d MMMM y"},
+		decimal:     ",",
+		group:       ".",
+	},
+	"pl": {
+		months:      [12]string{"stycznia", "lutego", "marca", "kwietnia", "maja", "czerwca", "lipca", "sierpnia", "września", "października", "listopada", "grudnia"},
+		shortMonths: [12]string{"sty", "lut", "mar", "kwi", "maj", "cze", "lip", "sie", "wrz", "paź", "lis", "gru"},
+		weekdays:    [7]string{"niedziela", "poniedziałek", "wtorek", "środa", "czwartek", "piątek", "sobota"},
+		dateFormats: [4]string{"d.MM.y", "d MMM y", "d MMMM y", "EEEE, d MMMM y"},
+		decimal:     ",",
+		group:       "\u00a0",
+	},
+	"pt": {
+		months:      [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		shortMonths: [12]string{"jan.", "fev.", "mar.", "abr.", "mai.", "jun.", "jul.", "ago.", "set.", "out.", "nov.", "dez."},
+		weekdays:    [7]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"},
+		dateFormats: [4]string{"dd/MM/y", "d 'de' MMM 'de' y", "d 'de' MMMM 'de' y", "EEEE, d 'de' MMMM 'de' y"},
+		decimal:     ",",
+		group:       ".",
+	},
+	"ru": {
+		months:      [12]string{"января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"},
+		shortMonths: [12]string{"янв.", "февр.", "мар.", "апр.", "мая", "июн.", "июл.", "авг.", "сент.", "окт.", "нояб.", "дек."},
+		weekdays:    [7]string{"воскресенье", "понедельник", "вторник", "среда", "четверг", "пятница", "суббота"},
+		dateFormats: [4]string{"dd.MM.y", "d MMM y 'г'.", "d MMMM y 'г'.", "EEEE, d MMMM y 'г'."},
+		decimal:     ",",
+		group:       "\u00a0",
+	},
+	"sv": {
+		months:      [12]string{"januari", "februari", "mars", "april", "maj", "juni", "juli", "augusti", "september", "oktober", "november", "december"},
+		shortMonths: [12]string{"jan.", "feb.", "mars", "apr.", "maj", "juni", "juli", "aug.", "sep.", "okt.", "nov.", "dec."},
+		weekdays:    [7]string{"söndag", "måndag", "tisdag", "onsdag", "torsdag", "fredag", "lördag"},
+		dateFormats: [4]string{"y-MM-dd", "d MMM y", "d MMMM y", "EEEE d MMMM y"},
+		decimal:     ",",
+		group:       "\u00a0",
+	},
+	"tr": {
+		months:      [12]string{"Ocak", "Şubat", "Mart", "Nisan", "Mayıs", "Haziran", "Temmuz", "Ağustos", "Eylül", "Ekim", "Kasım", "Aralık"},
+		shortMonths: [12]string{"Oca", "Şub", "Mar", "Nis", "May", "Haz", "Tem", "Ağu", "Eyl", "Eki", "Kas", "Ara"},
+		weekdays:    [7]string{"Pazar", "Pazartesi", "Salı", "Çarşamba", "Perşembe", "Cuma", "Cumartesi"},
+		dateFormats: [4]string{"d.MM.y", "d MMM y", "d MMMM y", "d MMMM y EEEE"},
+		decimal:     ",",
+		group:       ".",
+	},
+	"zh": {
+		months:      [12]string{"一月", "二月", "三月", "四月", "五月", "六月", "七月", "八月", "九月", "十月", "十一月", "十二月"},
+		shortMonths: [12]string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		weekdays:    [7]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"},
+		dateFormats: [4]string{"y/M/d", "y年M月d日", "y年M月d日", "y年M月d日EEEE"},
+		decimal:     ".",
+		group:       ",",
+	},
+}
+
+// dateStyles contains the date styles accepted by FormatDate, in the order of
+// the patterns in localeData.dateFormats.
+var dateStyles = [4]string{"short", "medium", "long", "full"}
+
+// lookupLocale returns the data of the locale with the given tag, as "it" or
+// "en-GB", and true. If the tag is empty, it returns the data of the "en"
+// locale. If the locale is not supported, it returns nil and false.
+func lookupLocale(tag string) (*localeData, bool) {
+	if tag == "" {
+		return locales["en"], true
+	}
+	tag = strings.ToLower(strings.ReplaceAll(tag, "_", "-"))
+	if l, ok := locales[tag]; ok {
+		return l, true
+	}
+	if i := strings.IndexByte(tag, '-'); i > 0 {
+		if l, ok := locales[tag[:i]]; ok {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// formatDate formats t with the date pattern of the given style of the
+// locale l. It returns false if the style is not valid.
+func (l *localeData) formatDate(t time.Time, style string) (string, bool) {
+	var pattern string
+	for i, s := range dateStyles {
+		if s == style {
+			pattern = l.dateFormats[i]
+			break
+		}
+	}
+	if pattern == "" {
+		return "", false
+	}
+	var b strings.Builder
+	for i := 0; i < len(pattern); {
+		c := pattern[i]
+		if c == '\'' {
+			j := strings.IndexByte(pattern[i+1:], '\'')
+			b.WriteString(pattern[i+1 : i+1+j])
+			i += j + 2
+			continue
+		}
+		n := 1
+		for i+n < len(pattern) && pattern[i+n] == c {
+			n++
+		}
+		i += n
+		switch c {
+		case 'd':
+			writePadded(&b, t.Day(), n)
+		case 'M':
+			switch n {
+			case 1, 2:
+				writePadded(&b, int(t.Month()), n)
+			case 3:
+				b.WriteString(l.shortMonths[t.Month()-1])
+			default:
+				b.WriteString(l.months[t.Month()-1])
+			}
+		case 'y':
+			if n == 2 {
+				writePadded(&b, t.Year()%100, 2)
+			} else {
+				b.WriteString(strconv.Itoa(t.Year()))
+			}
+		case 'E':
+			b.WriteString(l.weekdays[t.Weekday()])
+		default:
+			b.WriteString(pattern[i-n : i])
+		}
+	}
+	return b.String(), true
+}
+
+// formatNumber formats number with the given number of decimals and the
+// separators of the locale l. If decimals is negative, it uses the smallest
+// number of decimals necessary to represent number exactly.
+func (l *localeData) formatNumber(number float64, decimals int) string {
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	if decimals < 0 {
+		decimals = -1
+	}
+	s := strconv.FormatFloat(number, 'f', decimals, 64)
+	var b strings.Builder
+	if s[0] == '-' {
+		b.WriteByte('-')
+		s = s[1:]
+	}
+	integer, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		integer, fraction = s[:i], s[i+1:]
+	}
+	for i := 0; i < len(integer); i++ {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(l.group)
+		}
+		b.WriteByte(integer[i])
+	}
+	if fraction != "" {
+		b.WriteString(l.decimal)
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
+
+// writePadded writes n to b, padded with zeros to width.
+func writePadded(b *strings.Builder, n, width int) {
+	s := strconv.Itoa(n)
+	for i := len(s); i < width; i++ {
+		b.WriteByte('0')
+	}
+	b.WriteString(s)
+}
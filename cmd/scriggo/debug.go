@@ -0,0 +1,648 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/open2b/scriggo"
+)
+
+// dapMaxChildren is the maximum number of children, elements of slices and
+// arrays and entries of maps, returned for a variable.
+const dapMaxChildren = 100
+
+// _debug executes the sub command "debug":
+//
+//		scriggo debug
+//
+// It serves the Debug Adapter Protocol on the standard input and output, so
+// that the template or the program name can be debugged from an editor. If
+// name is empty, it is read from the 'program' attribute of the launch
+// request.
+func _debug(name string, flags buildFlags) error {
+	srv := newDAPServer(os.Stdin, os.Stdout, name, flags)
+	return srv.serve()
+}
+
+// dapServer implements a Debug Adapter Protocol server that debugs a
+// template or a program. It also implements the scriggo.Debugger interface.
+type dapServer struct {
+	r     *bufio.Reader
+	name  string
+	flags buildFlags
+
+	// wmu protects w and seq.
+	wmu sync.Mutex
+	w   io.Writer
+	seq int
+
+	template *scriggo.Template
+	program  *scriggo.Program
+	root     string // absolute path of the root directory of the template or of the program.
+	cancel   context.CancelFunc
+	resume   chan scriggo.StepMode
+
+	// mu protects the following fields, that are accessed also by the
+	// goroutine that runs the template.
+	mu          sync.Mutex
+	breakpoints map[string]map[int]bool // lines with a breakpoint by path.
+	stopOnEntry bool                    // stop at the first line.
+	pause       bool                    // pause at the next line.
+	reason      string                  // reason of the next stop.
+	state       *scriggo.DebugState     // state, if the execution is stopped.
+	refs        []dapRef                // variables references.
+}
+
+// dapRef is a variables reference. It references the local variables of a
+// frame, the global variables or the children of a value.
+type dapRef struct {
+	scope int // 0: value, 1: local variables, 2: global variables.
+	frame int
+	value reflect.Value
+}
+
+// dapRequest is a request of the Debug Adapter Protocol.
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// dapResponse is a response of the Debug Adapter Protocol.
+type dapResponse struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+// dapEvent is an event of the Debug Adapter Protocol.
+type dapEvent struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+// dapVariable is a variable of the Debug Adapter Protocol.
+type dapVariable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+// newDAPServer returns a new DAP server that reads requests from r and writes
+// responses and events to w.
+func newDAPServer(r io.Reader, w io.Writer, name string, flags buildFlags) *dapServer {
+	return &dapServer{
+		r:           bufio.NewReader(r),
+		w:           w,
+		name:        name,
+		flags:       flags,
+		resume:      make(chan scriggo.StepMode),
+		breakpoints: map[string]map[int]bool{},
+	}
+}
+
+// serve serves the requests until the client disconnects.
+func (srv *dapServer) serve() error {
+	for {
+		req, err := srv.read()
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			srv.terminate()
+			return err
+		}
+		if req.Type != "request" {
+			continue
+		}
+		if srv.handle(req) {
+			return nil
+		}
+	}
+}
+
+// handle handles a request. It returns true if the client has disconnected.
+func (srv *dapServer) handle(req *dapRequest) bool {
+	switch req.Command {
+	case "initialize":
+		srv.respond(req, map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+		}, nil)
+		srv.send("event", "initialized", nil)
+	case "launch":
+		var args struct {
+			Program     string `json:"program"`
+			StopOnEntry bool   `json:"stopOnEntry"`
+		}
+		_ = json.Unmarshal(req.Arguments, &args)
+		err := srv.launch(args.Program, args.StopOnEntry)
+		srv.respond(req, nil, err)
+	case "setBreakpoints":
+		var args struct {
+			Source struct {
+				Path string `json:"path"`
+			} `json:"source"`
+			Breakpoints []struct {
+				Line int `json:"line"`
+			} `json:"breakpoints"`
+		}
+		_ = json.Unmarshal(req.Arguments, &args)
+		lines := map[int]bool{}
+		breakpoints := make([]map[string]interface{}, len(args.Breakpoints))
+		for i, bp := range args.Breakpoints {
+			lines[bp.Line] = true
+			breakpoints[i] = map[string]interface{}{"verified": true, "line": bp.Line}
+		}
+		srv.mu.Lock()
+		srv.breakpoints[srv.templatePath(args.Source.Path)] = lines
+		srv.mu.Unlock()
+		srv.respond(req, map[string]interface{}{"breakpoints": breakpoints}, nil)
+	case "setExceptionBreakpoints":
+		srv.respond(req, nil, nil)
+	case "configurationDone":
+		if srv.template == nil && srv.program == nil {
+			srv.respond(req, nil, errors.New("template or program has not been launched"))
+			break
+		}
+		srv.respond(req, nil, nil)
+		srv.run()
+	case "threads":
+		srv.respond(req, map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": 1, "name": "main"}},
+		}, nil)
+	case "stackTrace":
+		srv.respond(req, map[string]interface{}{"stackFrames": srv.stackFrames()}, nil)
+	case "scopes":
+		var args struct {
+			FrameID int `json:"frameId"`
+		}
+		_ = json.Unmarshal(req.Arguments, &args)
+		srv.respond(req, map[string]interface{}{"scopes": srv.scopes(args.FrameID)}, nil)
+	case "variables":
+		var args struct {
+			VariablesReference int `json:"variablesReference"`
+		}
+		_ = json.Unmarshal(req.Arguments, &args)
+		srv.respond(req, map[string]interface{}{"variables": srv.variables(args.VariablesReference)}, nil)
+	case "evaluate":
+		var args struct {
+			Expression string `json:"expression"`
+			FrameID    int    `json:"frameId"`
+		}
+		_ = json.Unmarshal(req.Arguments, &args)
+		v, err := srv.evaluate(args.FrameID, strings.TrimSpace(args.Expression))
+		if err != nil {
+			srv.respond(req, nil, err)
+			break
+		}
+		srv.respond(req, map[string]interface{}{
+			"result":             v.Value,
+			"type":               v.Type,
+			"variablesReference": v.VariablesReference,
+		}, nil)
+	case "continue":
+		srv.respond(req, map[string]bool{"allThreadsContinued": true}, nil)
+		srv.step(scriggo.StepContinue)
+	case "next":
+		srv.respond(req, nil, nil)
+		srv.step(scriggo.StepOver)
+	case "stepIn":
+		srv.respond(req, nil, nil)
+		srv.step(scriggo.StepIn)
+	case "stepOut":
+		srv.respond(req, nil, nil)
+		srv.step(scriggo.StepOut)
+	case "pause":
+		srv.mu.Lock()
+		srv.pause = true
+		srv.mu.Unlock()
+		srv.respond(req, nil, nil)
+	case "disconnect", "terminate":
+		srv.terminate()
+		srv.respond(req, nil, nil)
+		return true
+	default:
+		srv.respond(req, nil, fmt.Errorf("unsupported request %q", req.Command))
+	}
+	return false
+}
+
+// launch builds the template or, if the file has the ".go" extension, the
+// program. If the server has not been started with a file name, program is
+// the name of the file.
+func (srv *dapServer) launch(program string, stopOnEntry bool) error {
+	if srv.template != nil || srv.program != nil {
+		return errors.New("already launched")
+	}
+	name := srv.name
+	if name == "" {
+		name = program
+	}
+	if name == "" {
+		return errors.New("missing file name")
+	}
+	if filepath.Ext(name) == ".go" {
+		return srv.launchProgram(name, stopOnEntry)
+	}
+	root := srv.flags.root
+	if root == "" {
+		root = filepath.Dir(name)
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	fsys, name, err := runFS(name, srv.flags)
+	if err != nil {
+		return err
+	}
+	opts, err := runBuildOptions(name, srv.flags)
+	if err != nil {
+		return err
+	}
+	template, err := scriggo.BuildTemplate(fsys, name, opts)
+	if err != nil {
+		return err
+	}
+	srv.template = template
+	srv.root = root
+	srv.stopOnEntry = stopOnEntry
+	return nil
+}
+
+// launchProgram builds the program made of the Go files in the directory of
+// the file name.
+func (srv *dapServer) launchProgram(name string, stopOnEntry bool) error {
+	root, err := filepath.Abs(filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+	program, err := scriggo.Build(os.DirFS(root), &scriggo.BuildOptions{AllowGoStmt: true})
+	if err != nil {
+		return err
+	}
+	srv.program = program
+	srv.root = root
+	srv.stopOnEntry = stopOnEntry
+	return nil
+}
+
+// run runs the template or the program in a new goroutine. When the execution
+// terminates, it sends the exited and terminated events.
+func (srv *dapServer) run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	srv.cancel = cancel
+	go func() {
+		var err error
+		if srv.program != nil {
+			// As in a compiled program, print and println write to the
+			// standard error.
+			stderr := dapOutput{srv: srv, category: "stderr"}
+			err = srv.program.Run(&scriggo.RunOptions{
+				Context:  ctx,
+				Debugger: srv,
+				Print:    func(v interface{}) { _, _ = fmt.Fprint(stderr, v) },
+			})
+		} else {
+			out := dapOutput{srv: srv, category: "stdout"}
+			err = srv.template.Run(out, nil, &scriggo.RunOptions{Context: ctx, Debugger: srv})
+		}
+		exitCode := 0
+		if err != nil && err != context.Canceled {
+			exitCode = 1
+			if e, ok := err.(*scriggo.ExitError); ok {
+				exitCode = e.Code
+			}
+			_, _ = dapOutput{srv: srv, category: "stderr"}.Write([]byte(err.Error() + "\n"))
+		}
+		srv.send("event", "exited", map[string]int{"exitCode": exitCode})
+		srv.send("event", "terminated", nil)
+	}()
+}
+
+// step resumes the stopped execution with the given step mode. If the
+// execution is not stopped, it does nothing.
+func (srv *dapServer) step(mode scriggo.StepMode) {
+	srv.mu.Lock()
+	stopped := srv.state != nil
+	srv.mu.Unlock()
+	if stopped {
+		srv.resume <- mode
+	}
+}
+
+// terminate terminates the execution, if it is running.
+func (srv *dapServer) terminate() {
+	if srv.cancel == nil {
+		return
+	}
+	srv.cancel()
+	srv.mu.Lock()
+	srv.breakpoints = map[string]map[int]bool{}
+	srv.pause = false
+	srv.mu.Unlock()
+	srv.step(scriggo.StepContinue)
+}
+
+// Breakpoint implements the Breakpoint method of scriggo.Debugger.
+func (srv *dapServer) Breakpoint(path string, line int) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	switch {
+	case srv.stopOnEntry:
+		srv.stopOnEntry = false
+		srv.reason = "entry"
+	case srv.pause:
+		srv.pause = false
+		srv.reason = "pause"
+	case srv.breakpoints[path][line]:
+		srv.reason = "breakpoint"
+	default:
+		return false
+	}
+	return true
+}
+
+// Stop implements the Stop method of scriggo.Debugger.
+func (srv *dapServer) Stop(state *scriggo.DebugState) scriggo.StepMode {
+	srv.mu.Lock()
+	reason := "step"
+	if state.Breakpoint() {
+		reason = srv.reason
+	}
+	srv.state = state
+	srv.refs = nil
+	srv.mu.Unlock()
+	srv.send("event", "stopped", map[string]interface{}{
+		"reason":            reason,
+		"threadId":          1,
+		"allThreadsStopped": true,
+	})
+	mode := <-srv.resume
+	srv.mu.Lock()
+	srv.state = nil
+	srv.refs = nil
+	srv.mu.Unlock()
+	return mode
+}
+
+// stackFrames returns the stack frames of the stopped execution.
+func (srv *dapServer) stackFrames() []map[string]interface{} {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	stackFrames := []map[string]interface{}{}
+	if srv.state == nil {
+		return stackFrames
+	}
+	for i, frame := range srv.state.Frames() {
+		name := frame.Function
+		if name == "" {
+			name = "func literal"
+		}
+		path := srv.sourcePath(frame.Path)
+		stackFrames = append(stackFrames, map[string]interface{}{
+			"id":     i,
+			"name":   name,
+			"source": map[string]string{"name": filepath.Base(path), "path": path},
+			"line":   frame.Position.Line,
+			"column": frame.Position.Column,
+		})
+	}
+	return stackFrames
+}
+
+// scopes returns the scopes of the frame with the given index.
+func (srv *dapServer) scopes(frame int) []map[string]interface{} {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.state == nil || frame < 0 || frame >= len(srv.state.Frames()) {
+		return []map[string]interface{}{}
+	}
+	srv.refs = append(srv.refs, dapRef{scope: 1, frame: frame}, dapRef{scope: 2})
+	n := len(srv.refs)
+	return []map[string]interface{}{
+		{"name": "Locals", "variablesReference": n - 1, "expensive": false},
+		{"name": "Globals", "variablesReference": n, "expensive": false},
+	}
+}
+
+// variables returns the variables with the given variables reference.
+func (srv *dapServer) variables(ref int) []dapVariable {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	variables := []dapVariable{}
+	if srv.state == nil || ref <= 0 || ref > len(srv.refs) {
+		return variables
+	}
+	r := srv.refs[ref-1]
+	switch r.scope {
+	case 0:
+		v := r.value
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			elem := v.Elem()
+			variables = append(variables, srv.variable("*", elem.Type().String(), elem))
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				field := v.Type().Field(i)
+				variables = append(variables, srv.variable(field.Name, field.Type.String(), v.Field(i)))
+			}
+		case reflect.Array, reflect.Slice:
+			for i := 0; i < v.Len() && i < dapMaxChildren; i++ {
+				elem := v.Index(i)
+				variables = append(variables, srv.variable("["+strconv.Itoa(i)+"]", elem.Type().String(), elem))
+			}
+		case reflect.Map:
+			iter := v.MapRange()
+			for i := 0; i < dapMaxChildren && iter.Next(); i++ {
+				key := dapFormat(iter.Key())
+				value := iter.Value()
+				variables = append(variables, srv.variable("["+key+"]", value.Type().String(), value))
+			}
+		}
+	case 1:
+		for _, v := range srv.state.Locals(r.frame) {
+			variables = append(variables, srv.variable(v.Name, v.Type, reflect.ValueOf(v.Value)))
+		}
+	case 2:
+		for _, v := range srv.state.Globals() {
+			variables = append(variables, srv.variable(v.Name, v.Type, reflect.ValueOf(v.Value)))
+		}
+	}
+	return variables
+}
+
+// evaluate evaluates an expression in the frame with the given index. Only
+// variable names are supported as expressions.
+func (srv *dapServer) evaluate(frame int, expr string) (dapVariable, error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.state == nil || frame < 0 || frame >= len(srv.state.Frames()) {
+		return dapVariable{}, errors.New("execution is not stopped")
+	}
+	v, ok := srv.state.Lookup(frame, expr)
+	if !ok {
+		return dapVariable{}, fmt.Errorf("undefined: %s", expr)
+	}
+	return srv.variable(v.Name, v.Type, reflect.ValueOf(v.Value)), nil
+}
+
+// variable returns a DAP variable with the given name, type and value. If the
+// value has children, it adds a variables reference to its children.
+// srv.mu must be held.
+func (srv *dapServer) variable(name, typ string, v reflect.Value) dapVariable {
+	variable := dapVariable{Name: name, Value: dapFormat(v), Type: typ}
+	var hasChildren bool
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		hasChildren = !v.IsNil()
+	case reflect.Struct:
+		hasChildren = v.NumField() > 0
+	case reflect.Array, reflect.Slice, reflect.Map:
+		hasChildren = v.Len() > 0
+	}
+	if hasChildren {
+		srv.refs = append(srv.refs, dapRef{value: v})
+		variable.VariablesReference = len(srv.refs)
+	}
+	return variable
+}
+
+// templatePath returns the path of a template or program file given its path
+// in the file system.
+func (srv *dapServer) templatePath(path string) string {
+	if rel, err := filepath.Rel(srv.root, path); err == nil {
+		path = rel
+	}
+	return filepath.ToSlash(path)
+}
+
+// sourcePath returns the path in the file system of a template or program
+// file given its path.
+func (srv *dapServer) sourcePath(path string) string {
+	return filepath.Join(srv.root, filepath.FromSlash(strings.TrimPrefix(path, "/")))
+}
+
+// read reads a request.
+func (srv *dapServer) read() (*dapRequest, error) {
+	length := -1
+	for {
+		line, err := srv.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if v := strings.TrimPrefix(line, "Content-Length:"); v != line {
+			length, err = strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return nil, errors.New("invalid Content-Length header")
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("missing Content-Length header")
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(srv.r, body)
+	if err != nil {
+		return nil, err
+	}
+	req := &dapRequest{}
+	err = json.Unmarshal(body, req)
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// respond sends the response to the request req. If err is not nil, it
+// responds with an error.
+func (srv *dapServer) respond(req *dapRequest, body interface{}, err error) {
+	res := &dapResponse{
+		Type:       "response",
+		RequestSeq: req.Seq,
+		Success:    err == nil,
+		Command:    req.Command,
+		Body:       body,
+	}
+	if err != nil {
+		res.Message = err.Error()
+	}
+	srv.write(func(seq int) interface{} {
+		res.Seq = seq
+		return res
+	})
+}
+
+// send sends an event.
+func (srv *dapServer) send(typ, event string, body interface{}) {
+	srv.write(func(seq int) interface{} {
+		return &dapEvent{Seq: seq, Type: typ, Event: event, Body: body}
+	})
+}
+
+// write writes the message returned by msg, called with the next sequence
+// number.
+func (srv *dapServer) write(msg func(seq int) interface{}) {
+	srv.wmu.Lock()
+	defer srv.wmu.Unlock()
+	srv.seq++
+	data, err := json.Marshal(msg(srv.seq))
+	if err != nil {
+		panic(err)
+	}
+	_, _ = fmt.Fprintf(srv.w, "Content-Length: %d\r\n\r\n", len(data))
+	_, _ = srv.w.Write(data)
+}
+
+// dapOutput is an io.Writer that sends the written bytes as output events.
+type dapOutput struct {
+	srv      *dapServer
+	category string
+}
+
+func (out dapOutput) Write(p []byte) (int, error) {
+	out.srv.send("event", "output", map[string]string{"category": out.category, "output": string(p)})
+	return len(p), nil
+}
+
+// dapFormat formats a value to be shown by the client.
+func dapFormat(v reflect.Value) string {
+	if !v.IsValid() {
+		return "nil"
+	}
+	if v.Kind() == reflect.String {
+		return strconv.Quote(v.String())
+	}
+	s := fmt.Sprint(v)
+	if len(s) > 200 {
+		s = s[:200] + "..."
+	}
+	return s
+}
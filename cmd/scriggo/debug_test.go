@@ -0,0 +1,339 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dapClient is a DAP client used to test the DAP server.
+type dapClient struct {
+	t   *testing.T
+	w   io.Writer
+	r   *bufio.Reader
+	seq int
+}
+
+// dapMessage is a response or an event read by the DAP client.
+type dapMessage struct {
+	Type    string          `json:"type"`
+	Command string          `json:"command"`
+	Event   string          `json:"event"`
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// request sends a request.
+func (c *dapClient) request(command string, arguments interface{}) {
+	c.seq++
+	data, err := json.Marshal(map[string]interface{}{
+		"seq":       c.seq,
+		"type":      "request",
+		"command":   command,
+		"arguments": arguments,
+	})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	_, err = fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// read reads a message.
+func (c *dapClient) read() dapMessage {
+	length := -1
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		length, _ = strconv.Atoi(strings.TrimPrefix(line, "Content-Length: "))
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(c.r, data)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var msg dapMessage
+	err = json.Unmarshal(data, &msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// expect reads messages until it reads the response to command, if typ is
+// "response", or the event with name command, if typ is "event". It fails if
+// the message is a failed response. If body is not nil, the body of the
+// message is unmarshalled in body.
+func (c *dapClient) expect(typ, command string, body interface{}) {
+	for {
+		msg := c.read()
+		if msg.Type != typ || msg.Command+msg.Event != command {
+			continue
+		}
+		if typ == "response" && !msg.Success {
+			c.t.Fatalf("request %q failed: %s", command, msg.Message)
+		}
+		if body != nil {
+			err := json.Unmarshal(msg.Body, body)
+			if err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return
+	}
+}
+
+func TestDebug(t *testing.T) {
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "index.txt")
+	err := os.WriteFile(name, []byte("{% s := \"a\" %}\n{% n := 5 %}\n{{ s }}{{ n }}"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	srv := newDAPServer(inR, outW, "", buildFlags{})
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.serve()
+	}()
+	c := &dapClient{t: t, w: inW, r: bufio.NewReader(outR)}
+
+	c.request("initialize", map[string]string{"adapterID": "scriggo"})
+	c.expect("response", "initialize", nil)
+	c.expect("event", "initialized", nil)
+	c.request("launch", map[string]string{"program": name})
+	c.expect("response", "launch", nil)
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": name},
+		"breakpoints": []map[string]int{{"line": 2}},
+	})
+	c.expect("response", "setBreakpoints", nil)
+	c.request("configurationDone", nil)
+	c.expect("response", "configurationDone", nil)
+
+	var stopped struct {
+		Reason string `json:"reason"`
+	}
+	c.expect("event", "stopped", &stopped)
+	if stopped.Reason != "breakpoint" {
+		t.Fatalf("expected reason %q, got %q", "breakpoint", stopped.Reason)
+	}
+
+	var trace struct {
+		StackFrames []struct {
+			Line   int `json:"line"`
+			Source struct {
+				Path string `json:"path"`
+			} `json:"source"`
+		} `json:"stackFrames"`
+	}
+	c.request("stackTrace", map[string]int{"threadId": 1})
+	c.expect("response", "stackTrace", &trace)
+	if len(trace.StackFrames) != 1 {
+		t.Fatalf("expected 1 stack frame, got %d", len(trace.StackFrames))
+	}
+	if frame := trace.StackFrames[0]; frame.Line != 2 || frame.Source.Path != name {
+		t.Fatalf("expected frame %s:2, got %s:%d", name, frame.Source.Path, frame.Line)
+	}
+
+	var scopes struct {
+		Scopes []struct {
+			Name               string `json:"name"`
+			VariablesReference int    `json:"variablesReference"`
+		} `json:"scopes"`
+	}
+	c.request("scopes", map[string]int{"frameId": 0})
+	c.expect("response", "scopes", &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" {
+		t.Fatalf("unexpected scopes %v", scopes.Scopes)
+	}
+
+	var variables struct {
+		Variables []dapVariable `json:"variables"`
+	}
+	c.request("variables", map[string]int{"variablesReference": scopes.Scopes[0].VariablesReference})
+	c.expect("response", "variables", &variables)
+	if len(variables.Variables) != 1 {
+		t.Fatalf("expected 1 variable, got %d", len(variables.Variables))
+	}
+	if v := variables.Variables[0]; v.Name != "s" || v.Value != `"a"` || v.Type != "string" {
+		t.Fatalf("unexpected variable %v", v)
+	}
+
+	c.request("next", map[string]int{"threadId": 1})
+	c.expect("response", "next", nil)
+	c.expect("event", "stopped", &stopped)
+	if stopped.Reason != "step" {
+		t.Fatalf("expected reason %q, got %q", "step", stopped.Reason)
+	}
+
+	var result struct {
+		Result string `json:"result"`
+	}
+	c.request("evaluate", map[string]interface{}{"expression": "n", "frameId": 0})
+	c.expect("response", "evaluate", &result)
+	if result.Result != "5" {
+		t.Fatalf("expected result %q, got %q", "5", result.Result)
+	}
+
+	c.request("continue", map[string]int{"threadId": 1})
+	c.expect("response", "continue", nil)
+	var output string
+	for {
+		msg := c.read()
+		if msg.Event == "exited" {
+			break
+		}
+		if msg.Event == "output" {
+			var body struct {
+				Output string `json:"output"`
+			}
+			_ = json.Unmarshal(msg.Body, &body)
+			output += body.Output
+		}
+	}
+	if output != "a5" {
+		t.Fatalf("expected output %q, got %q", "a5", output)
+	}
+	c.expect("event", "terminated", nil)
+
+	c.request("disconnect", nil)
+	c.expect("response", "disconnect", nil)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not terminate")
+	}
+}
+
+func TestDebugProgram(t *testing.T) {
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "main.go")
+	src := "package main\n\nfunc main() {\n\ts := \"a\"\n\tprintln(s, 5)\n}\n"
+	err := os.WriteFile(name, []byte(src), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	srv := newDAPServer(inR, outW, name, buildFlags{})
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.serve()
+	}()
+	c := &dapClient{t: t, w: inW, r: bufio.NewReader(outR)}
+
+	c.request("initialize", map[string]string{"adapterID": "scriggo"})
+	c.expect("response", "initialize", nil)
+	c.expect("event", "initialized", nil)
+	c.request("launch", nil)
+	c.expect("response", "launch", nil)
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": name},
+		"breakpoints": []map[string]int{{"line": 5}},
+	})
+	c.expect("response", "setBreakpoints", nil)
+	c.request("configurationDone", nil)
+	c.expect("response", "configurationDone", nil)
+
+	var stopped struct {
+		Reason string `json:"reason"`
+	}
+	c.expect("event", "stopped", &stopped)
+	if stopped.Reason != "breakpoint" {
+		t.Fatalf("expected reason %q, got %q", "breakpoint", stopped.Reason)
+	}
+
+	var trace struct {
+		StackFrames []struct {
+			Name   string `json:"name"`
+			Line   int    `json:"line"`
+			Source struct {
+				Path string `json:"path"`
+			} `json:"source"`
+		} `json:"stackFrames"`
+	}
+	c.request("stackTrace", map[string]int{"threadId": 1})
+	c.expect("response", "stackTrace", &trace)
+	if len(trace.StackFrames) != 1 {
+		t.Fatalf("expected 1 stack frame, got %d", len(trace.StackFrames))
+	}
+	if frame := trace.StackFrames[0]; frame.Line != 5 || frame.Source.Path != name {
+		t.Fatalf("expected frame %s:5, got %s:%d", name, frame.Source.Path, frame.Line)
+	}
+
+	var result struct {
+		Result string `json:"result"`
+	}
+	c.request("evaluate", map[string]interface{}{"expression": "s", "frameId": 0})
+	c.expect("response", "evaluate", &result)
+	if result.Result != `"a"` {
+		t.Fatalf("expected result %q, got %q", `"a"`, result.Result)
+	}
+
+	c.request("continue", map[string]int{"threadId": 1})
+	c.expect("response", "continue", nil)
+	var output string
+	for {
+		msg := c.read()
+		if msg.Event == "exited" {
+			break
+		}
+		if msg.Event == "output" {
+			var body struct {
+				Category string `json:"category"`
+				Output   string `json:"output"`
+			}
+			_ = json.Unmarshal(msg.Body, &body)
+			if body.Category != "stderr" {
+				t.Fatalf("expected output category %q, got %q", "stderr", body.Category)
+			}
+			output += body.Output
+		}
+	}
+	if output != "a 5\n" {
+		t.Fatalf("expected output %q, got %q", "a 5\n", output)
+	}
+	c.expect("event", "terminated", nil)
+
+	c.request("disconnect", nil)
+	c.expect("response", "disconnect", nil)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not terminate")
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/open2b/scriggo/i18n"
+	"github.com/open2b/scriggo/internal/compiler"
+)
+
+// extract executes the sub command "extract":
+//
+//		scriggo extract [-o output] [extract flags] file...
+//
+// It extracts the translatable messages of the template files names and of
+// their extended, imported and rendered files, and writes them as a POT file.
+func extract(names []string, funcs []string, text bool, flags buildFlags) error {
+
+	fns := map[string]i18n.Func{}
+	for name, fn := range i18n.DefaultFuncs {
+		fns[name] = fn
+	}
+	for _, spec := range funcs {
+		name, fn, err := parseFuncSpec(spec)
+		if err != nil {
+			return err
+		}
+		fns[name] = fn
+	}
+
+	ex := i18n.NewExtractor(fns)
+	ex.Text = text
+	for _, name := range names {
+		fsys, name, err := runFS(name, flags)
+		if err != nil {
+			return err
+		}
+		tree, err := compiler.ParseTemplate(fsys, name, false, false)
+		if err != nil {
+			return err
+		}
+		ex.Extract(tree)
+	}
+
+	if flags.o == "" {
+		return i18n.WritePOT(os.Stdout, ex.Messages())
+	}
+	fi, err := os.OpenFile(flags.o, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	err = i18n.WritePOT(fi, ex.Messages())
+	if err2 := fi.Close(); err == nil {
+		err = err2
+	}
+
+	return err
+}
+
+// parseFuncSpec parses the specification of a translation function passed
+// to the -func flag of the extract command and returns the function name and
+// the indexes of its parameters.
+//
+// The specification has the form 'name' or 'name:args', where args is a comma
+// separated list of parameter numbers, starting from 1. The first number is
+// the parameter of the message, the second, if present, is the parameter of
+// the plural form. A number followed by 'c' is the parameter of the context.
+// For example 'translateContext:1c,2'. If args is missing, the message is the
+// first parameter.
+func parseFuncSpec(spec string) (string, i18n.Func, error) {
+	fn := i18n.Func{Context: -1, Msg: 0, Plural: -1}
+	name, args := spec, ""
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		name, args = spec[:i], spec[i+1:]
+	}
+	if name == "" {
+		return "", fn, fmt.Errorf("invalid function %q: missing name", spec)
+	}
+	if args == "" {
+		return name, fn, nil
+	}
+	fn.Msg = -1
+	for _, arg := range strings.Split(args, ",") {
+		isContext := strings.HasSuffix(arg, "c")
+		n, err := strconv.Atoi(strings.TrimSuffix(arg, "c"))
+		if err != nil || n < 1 {
+			return "", fn, fmt.Errorf("invalid function %q: invalid parameter number %q", spec, arg)
+		}
+		switch {
+		case isContext && fn.Context == -1:
+			fn.Context = n - 1
+		case !isContext && fn.Msg == -1:
+			fn.Msg = n - 1
+		case !isContext && fn.Plural == -1:
+			fn.Plural = n - 1
+		default:
+			return "", fn, fmt.Errorf("invalid function %q: too many parameters", spec)
+		}
+	}
+	if fn.Msg == -1 {
+		return "", fn, fmt.Errorf("invalid function %q: missing message parameter", spec)
+	}
+	return name, fn, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/open2b/scriggo/i18n"
+)
+
+var parseFuncSpecTests = []struct {
+	spec string
+	name string
+	fn   i18n.Func
+	err  string
+}{
+	{"T", "T", i18n.Func{Context: -1, Msg: 0, Plural: -1}, ""},
+	{"T:2", "T", i18n.Func{Context: -1, Msg: 1, Plural: -1}, ""},
+	{"TN:1,2", "TN", i18n.Func{Context: -1, Msg: 0, Plural: 1}, ""},
+	{"TC:1c,2", "TC", i18n.Func{Context: 0, Msg: 1, Plural: -1}, ""},
+	{"TNC:2,3,1c", "TNC", i18n.Func{Context: 0, Msg: 1, Plural: 2}, ""},
+	{":1", "", i18n.Func{}, `invalid function ":1": missing name`},
+	{"T:0", "", i18n.Func{}, `invalid function "T:0": invalid parameter number "0"`},
+	{"T:a", "", i18n.Func{}, `invalid function "T:a": invalid parameter number "a"`},
+	{"T:1c", "", i18n.Func{}, `invalid function "T:1c": missing message parameter`},
+	{"T:1,2,3", "", i18n.Func{}, `invalid function "T:1,2,3": too many parameters`},
+	{"T:1c,2c", "", i18n.Func{}, `invalid function "T:1c,2c": too many parameters`},
+}
+
+// TestParseFuncSpec tests the parseFuncSpec function.
+func TestParseFuncSpec(t *testing.T) {
+	for _, test := range parseFuncSpecTests {
+		name, fn, err := parseFuncSpec(test.spec)
+		if err != nil {
+			if test.err == "" {
+				t.Errorf("spec %q: unexpected error %q", test.spec, err)
+			} else if err.Error() != test.err {
+				t.Errorf("spec %q: expected error %q, got %q", test.spec, test.err, err)
+			}
+			continue
+		}
+		if test.err != "" {
+			t.Errorf("spec %q: expected error %q, got no error", test.spec, test.err)
+			continue
+		}
+		if name != test.name || fn != test.fn {
+			t.Errorf("spec %q: expected %s %v, got %s %v", test.spec, test.name, test.fn, name, fn)
+		}
+	}
+}
+
+// TestExtract tests the extract function.
+func TestExtract(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":    "{% extends \"layout.html\" %}\n{% import \"imported.html\" %}\n{% macro Body %}\n  <p>Welcome</p>\n  {{ T(\"Hello\") }}\n  {{ render \"partial.html\" }}\n{% end %}",
+		"layout.html":   "<body>{{ Body() }}</body>",
+		"imported.html": "{% macro M %}{{ translateContext(\"month\", \"May\") }}{% end %}",
+		"partial.html":  "{{ translate(\"Hello\") }}{{ translatePlural(\"%d apple\", \"%d apples\", 2) }}",
+	}
+	for name, src := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	output := filepath.Join(dir, "messages.pot")
+	err := extract([]string{filepath.Join(dir, "index.html")}, []string{"T"}, false, buildFlags{o: output})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pot, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=UTF-8\n"
+"Content-Transfer-Encoding: 8bit\n"
+
+#: index.html:5 partial.html:1
+msgid "Hello"
+msgstr ""
+
+#: imported.html:1
+msgctxt "month"
+msgid "May"
+msgstr ""
+
+#: partial.html:1
+msgid "%d apple"
+msgid_plural "%d apples"
+msgstr[0] ""
+msgstr[1] ""
+`
+	if string(pot) != expected {
+		t.Fatalf("unexpected POT file:\n%s\nexpecting:\n%s", pot, expected)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/open2b/scriggo/ast/astutil"
+	"github.com/open2b/scriggo/internal/compiler"
+)
+
+// _fmt executes the sub command "fmt":
+//
+//		scriggo fmt [-l] [-w] [-format format] file...
+//
+// It formats the template files names. If flags.w is true, it writes the
+// result to the files, otherwise to the standard output. If list is true, it
+// prints the names of the files whose formatting differs from the canonical
+// one.
+func _fmt(names []string, list bool, flags buildFlags) error {
+	for _, name := range names {
+		fsys, base, err := runFS(name, flags)
+		if err != nil {
+			return err
+		}
+		src, format, err := compiler.ReadTemplateFile(fsys, base)
+		if err != nil {
+			return err
+		}
+		// Comments in code are not retained in the tree.
+		if pos := compiler.CodeComment(src, format, true); pos != nil {
+			return fmt.Errorf("%s:%s: cannot format a file with comments in code", name, pos)
+		}
+		tree, _, err := compiler.ParseTemplateSource(src, format, false, false, false, true)
+		if err != nil {
+			if e, ok := err.(*compiler.SyntaxError); ok {
+				return fmt.Errorf("%s:%s: syntax error: %s", name, e.Position(), e.Message())
+			}
+			return err
+		}
+		var b bytes.Buffer
+		err = astutil.Fprint(&b, tree)
+		if err != nil {
+			return err
+		}
+		res := b.Bytes()
+		changed := !bytes.Equal(src, res)
+		if list && changed {
+			fmt.Println(name)
+		}
+		if flags.w {
+			if changed {
+				fi, err := os.Stat(name)
+				if err != nil {
+					return err
+				}
+				err = os.WriteFile(name, res, fi.Mode().Perm())
+				if err != nil {
+					return err
+				}
+			}
+		} else if !list {
+			_, err = os.Stdout.Write(res)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
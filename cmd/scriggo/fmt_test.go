@@ -0,0 +1,50 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFmt tests the _fmt function.
+func TestFmt(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":   "{%if a%}\n  {%for v in a%}\n      {{v}}\n        {%end%}\n{%end if%}\n",
+		"comment.html": "{% var a = 1 // one %}\n",
+		"invalid.html": "{% if %}",
+	}
+	for name, src := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	name := filepath.Join(dir, "index.html")
+	err := _fmt([]string{name}, false, buildFlags{w: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "{% if a %}\n\t{% for v in a %}\n      {{ v }}\n\t{% end %}\n{% end %}\n"
+	if string(src) != expected {
+		t.Fatalf("unexpected formatted file %q, expecting %q", src, expected)
+	}
+	name = filepath.Join(dir, "comment.html")
+	err = _fmt([]string{name}, false, buildFlags{w: true})
+	if err == nil || err.Error() != name+":1:14: cannot format a file with comments in code" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	name = filepath.Join(dir, "invalid.html")
+	err = _fmt([]string{name}, false, buildFlags{w: true})
+	if err == nil || err.Error() != name+":1:7: syntax error: missing condition in if statement" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
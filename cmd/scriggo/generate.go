@@ -51,14 +51,24 @@ func renderPackages(w io.Writer, dir string, sf *scriggofile, goos string, flags
 
 	mustImportReflect := false
 	packages := map[string]*packageType{}
+	var signatures []*types.Signature
 	for i, imp := range sf.imports {
 		if flags.v {
 			_, _ = fmt.Fprintf(os.Stderr, "%s\n", imp.path)
 		}
-		pkgName, decls, refToImport, refToReflect, err := loadGoPackage(imp.path, dir, goos, flags, imp.including, imp.excluding, cache)
+		pkgName, decls, sigs, refToImport, refToReflect, err := loadGoPackage(imp.path, dir, goos, flags, imp.including, imp.excluding, cache)
 		if err != nil {
 			return err
 		}
+	Signatures:
+		for _, sig := range sigs {
+			for _, s := range signatures {
+				if types.Identical(sig, s) {
+					continue Signatures
+				}
+			}
+			signatures = append(signatures, sig)
+		}
 		uniqueName := cache.uniquePackageName(imp.path, pkgName)
 		if uniqueName != pkgName {
 			explicitImports = append(explicitImports, struct{ Name, Path string }{uniqueName, imp.path})
@@ -134,6 +144,12 @@ func renderPackages(w io.Writer, dir string, sf *scriggofile, goos string, flags
 
 	}
 
+	// Trampolines are registered with types and values of the 'reflect'
+	// package.
+	if len(signatures) > 0 {
+		mustImportReflect = true
+	}
+
 	// If the 'reflect' package has already been imported from the Scriggofile,
 	// it must not be added twice, even if the value of some declarations of
 	// another package refers to it.
@@ -233,9 +249,23 @@ func init() {
 	}
 
 	{{- end}}
+
+	{{- range .Trampolines}}
+	native.RegisterTrampoline(reflect.TypeOf(({{.Type}})(nil)), func(f interface{}, r native.Registers) {
+		{{.Body}}
+	})
+	{{- end}}
 }
 `
 
+	trampolines := make([]trampoline, len(signatures))
+	for i, sig := range signatures {
+		trampolines[i] = renderTrampoline(sig)
+	}
+	sort.Slice(trampolines, func(i, j int) bool {
+		return trampolines[i].Type < trampolines[j].Type
+	})
+
 	pkgOutput := map[string]interface{}{
 		"GOOS":              goos,
 		"BaseVersion":       goBaseVersion(runtime.Version()),
@@ -245,6 +275,7 @@ func init() {
 		"MustImportReflect": mustImportReflect,
 		"Variable":          sf.variable,
 		"PkgContent":        allPkgsContent,
+		"Trampolines":       trampolines,
 	}
 
 	t := template.Must(template.New("packages").Parse(pkgsSkeleton))
@@ -263,7 +294,10 @@ func init() {
 // refToScriggo reports whether at least one of the declarations refers to the
 // package 'scriggo', while refToReflect reports whether at least one of the
 // declarations refers to the package 'reflect'.
-func loadGoPackage(path, dir, goos string, flags buildFlags, including, excluding []string, cache packageNameCache) (name string, decl map[string]string, refToImport, refToReflect bool, err error) {
+//
+// sigs contains the signatures of the exported functions for which a
+// trampoline can be generated.
+func loadGoPackage(path, dir, goos string, flags buildFlags, including, excluding []string, cache packageNameCache) (name string, decl map[string]string, sigs []*types.Signature, refToImport, refToReflect bool, err error) {
 
 	allowed := func(n string) bool {
 		if len(including) > 0 {
@@ -301,32 +335,33 @@ func loadGoPackage(path, dir, goos string, flags buildFlags, including, excludin
 	if dir != "" {
 		cwd, err := os.Getwd()
 		if err != nil {
-			return "", nil, false, false, fmt.Errorf("scriggo: can't get current directory: %s", err)
+			return "", nil, nil, false, false, fmt.Errorf("scriggo: can't get current directory: %s", err)
 		}
 		err = os.Chdir(dir)
 		if err != nil {
-			return "", nil, false, false, fmt.Errorf("scriggo: can't change current directory: %s", err)
+			return "", nil, nil, false, false, fmt.Errorf("scriggo: can't change current directory: %s", err)
 		}
 		defer func() {
 			err = os.Chdir(cwd)
 			if err != nil {
 				name = ""
 				decl = nil
+				sigs = nil
 				err = fmt.Errorf("scriggo: can't change current directory: %s", err)
 			}
 		}()
 	}
 	packages, err := pkgs.Load(conf, path)
 	if err != nil {
-		return "", nil, false, false, err
+		return "", nil, nil, false, false, err
 	}
 
 	if pkgs.PrintErrors(packages) > 0 {
-		return "", nil, false, false, errors.New("error")
+		return "", nil, nil, false, false, errors.New("error")
 	}
 
 	if len(packages) > 1 {
-		return "", nil, false, false, errors.New("package query returned more than one package")
+		return "", nil, nil, false, false, errors.New("package query returned more than one package")
 	}
 
 	if len(packages) != 1 {
@@ -391,8 +426,11 @@ func loadGoPackage(path, dir, goos string, flags buildFlags, including, excludin
 				decl[v.Name()] = fmt.Sprintf("%s.%s", pkgBase, v.Name())
 			}
 		case *types.Func:
-			if v.Type().(*types.Signature).Recv() == nil {
+			if sig := v.Type().(*types.Signature); sig.Recv() == nil {
 				decl[v.Name()] = fmt.Sprintf("%s.%s", pkgBase, v.Name())
+				if isTrampolineSignature(sig) {
+					sigs = append(sigs, sig)
+				}
 			}
 		case *types.Var:
 			if !v.Embedded() && !v.IsField() {
@@ -406,5 +444,5 @@ func loadGoPackage(path, dir, goos string, flags buildFlags, including, excludin
 
 	refToImport = len(decl) > numUntyped
 
-	return name, decl, refToImport, refToReflect, nil
+	return name, decl, sigs, refToImport, refToReflect, nil
 }
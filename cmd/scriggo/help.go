@@ -20,6 +20,15 @@ The commands are:
     serve       run a web server and serve the template rooted at the current
                 directory
 
+    debug       debug a template from an editor with the Debug Adapter Protocol
+
+    extract     extract the translatable messages of a template into a POT
+                file
+
+    fmt         format template files
+
+    vet         report likely mistakes in template files
+
     init        initialize an interpreter for Go programs
 
     import      generate the source for an importer used by Scriggo to import 
@@ -117,6 +126,11 @@ The importer in the generated Go file have type native.Importer and it is
 assigned to a variable named 'packages'. The variable can be used as an
 argument to the Build and BuildTemplate functions in the scriggo package.
 
+For the imported functions whose parameters and results have only predeclared
+types, and that are not variadic, the generated code also registers typed
+trampolines, with native.RegisterTrampoline, so that these functions are
+called without using the reflection.
+
 To give a different name to the variable use the instruction SET VARIABLE in
 the Scriggofile:
 
@@ -168,9 +182,17 @@ The run flags are:
 		literal, a number literal, true or false. There can be multiple
 		name=value pairs.
 	-format format
-		use the named file format: Text, HTML, Markdown, CSS, JS or JSON.
+		use the named file format: Text, HTML, Markdown, CSS, JS, JSON, XML,
+		YAML or TOML.
 	-metrics
 		print metrics about execution time.
+	-profile file
+		write the profile of the execution to the named file. The profile
+		is in the pprof format and can be read with 'go tool pprof'.
+	-coverprofile file
+		write the coverage profile of the execution to the named file. The
+		profile is in the Go coverage format and can be read with
+		'go tool cover'.
 	-S n
 		print the assembly code of the executed file to the standard error.
 		n determines the maximum length, in runes, of disassembled Text
@@ -192,6 +214,181 @@ Examples:
 
 	scriggo run -o ./public ./sources/index.html
 
+	scriggo run -profile run.prof index.html
+	go tool pprof -top run.prof
+
+	scriggo run -coverprofile coverage.out index.html
+	go tool cover -html=coverage.out
+
+`
+
+const helpDebug = `
+usage: scriggo debug [debug flags] [file]
+
+Debug debugs a template file and its extended, imported and rendered files, or
+a program. It implements the Debug Adapter Protocol (DAP) on the standard
+input and output, so it can be used as debug adapter by editors that support
+the protocol.
+
+If the file has the .go extension, the program made of the Go files in the
+directory of the file is debugged. As the debug command has no native
+packages, the program cannot import native packages, such as "fmt".
+
+If the file is not given, it is read from the 'program' attribute of the
+launch request. If the 'stopOnEntry' attribute is true, the execution stops
+at the first line.
+
+Debug supports breakpoints, step in, step over, step out, pause and reading
+the local and global variables. The result of the template, what the program
+prints with the print and println builtins and the errors are sent to the
+editor as output events.
+
+The debug flags are:
+
+	-root dir
+		set the root directory to dir instead of the file's directory.
+	-const name=value
+		run the template file with a global constant with the given name and
+		value. There can be multiple name=value pairs.
+	-format format
+		use the named file format: Text, HTML, Markdown, CSS, JS, JSON, XML,
+		YAML or TOML.
+
+The debug flags apply only to templates.
+
+Examples:
+
+	scriggo debug
+
+	scriggo debug -root . docs/article.html
+
+	scriggo debug cmd/main.go
+
+`
+
+const helpExtract = `
+usage: scriggo extract [-o output] [extract flags] file...
+
+Extract extracts the translatable messages of template files and of their
+extended, imported and rendered files, and writes them to the standard output
+as a gettext POT file. Every file is visited only once.
+
+The messages are the texts of the files and the string literal arguments of
+calls to the translation functions translate, translateContext and
+translatePlural. Every message is preceded by a comment with the files and
+the lines where it is found.
+
+Only the texts that contain letters, outside of the markup for HTML, Markdown
+and XML files, are extracted. The texts are not translated when a template
+is executed; they can be used to translate the files themselves.
+
+The -o flag writes the POT file to the named output file, instead to the
+standard output.
+
+The extract flags are:
+
+	-root dir
+		set the root directory to dir instead of the file's directory.
+	-func name[:args]
+		extract the messages passed to the named translation function.
+		args is a comma separated list of parameter numbers, starting from
+		1: the first number is the parameter of the message and the
+		second, if present, the parameter of the plural form. A number
+		followed by 'c' is the parameter of the context. If args is not
+		given, the message is the first parameter. There can be multiple
+		-func flags.
+	-format format
+		use the named file format: Text, HTML, Markdown, CSS, JS, JSON, XML,
+		YAML or TOML.
+	-text=false
+		do not extract the texts of the files.
+
+Examples:
+
+	scriggo extract -o messages.pot index.html
+
+	scriggo extract -root . -func T -func TN:1,2 -func TC:1c,2 pages/*.html
+
+`
+
+const helpFmt = `
+usage: scriggo fmt [-l] [-w] [-format format] file...
+
+Fmt formats template files and writes the result to the standard output.
+
+The code in {{ }}, {% %} and {%% %%} is formatted with a canonical spacing,
+and a statement alone in its line is indented with tabs according to its
+nesting in the if, for, switch, select, macro, using and raw statements. The
+texts and the comments are not changed, except for the spaces around a
+statement alone in its line, that are not rendered. Formatting a formatted
+file does not change it.
+
+Files with comments in code, as // and /* */, are not formatted, because
+these comments would be lost.
+
+The fmt flags are:
+
+	-l
+		do not print the result, print the names of the files whose
+		formatting differs from the canonical one.
+	-w
+		do not print the result, write it to the files.
+	-format format
+		use the named file format: Text, HTML, Markdown, CSS, JS, JSON, XML,
+		YAML or TOML.
+
+Examples:
+
+	scriggo fmt index.html
+
+	scriggo fmt -l -w pages/*.html
+
+`
+
+const helpVet = `
+usage: scriggo vet [vet flags] file...
+
+Vet type checks template files and their extended, imported and rendered
+files, without running them, and reports the code that is valid but is
+probably a mistake:
+
+* macros declared and not used; exported macros are reported only if they
+  are declared in a file that extends another file
+* imports whose names are not used
+* declarations that shadow a global
+* show of empty strings and of macros with an empty body, that render
+  nothing
+* calls to sprintf whose format does not match the arguments, that render
+  as %!verb(...)
+* unreachable code after a return statement in a macro
+* URLs built by string concatenation, whose parts are not escaped
+* raw statements containing template syntax, that is not executed
+
+The problems are written to the standard error, and the exit status is 1 if
+there are problems. A problem in a file shared by several files is reported
+only once.
+
+The globals are the same as the run command.
+
+The vet flags are:
+
+	-root dir
+		set the root directory to dir instead of the file's directory.
+	-const name=value
+		vet the template files with a global constant with the given name
+		and value. name should be a Go identifier and value should be a
+		string literal, a number literal, true or false. There can be
+		multiple name=value pairs.
+	-format format
+		use the named file format: Text, HTML, Markdown, CSS, JS, JSON, XML,
+		YAML or TOML.
+
+Examples:
+
+	scriggo vet index.html
+
+	scriggo vet -root . pages/*.html
+
 `
 
 const helpServe = `
@@ -326,12 +523,9 @@ Limitations
     under development. To check the state of a limitation please refer to the
     Github issue linked in the list below.
 
-    * methods declarations (issue #458)
-    * interface types definition (issue #218)
     * assigning to non-variables in 'for range' statements (issue #182)
     * importing the "unsafe" package from Scriggo (issue #288)
     * importing the "runtime" package from Scriggo (issue #524)
-    * labeled continue and break statements (issue #83)
     * some kinds of pointer shorthands (issue #383)
     * compilation of non-main packages without importing them (issue #521)
 
@@ -375,15 +569,15 @@ Arbitrary limitations
     These limitations have been arbitrarily added to Scriggo to enhance
     performances:
 
-    * 127 registers of a given type (integer, floating point, string or
+    * 32767 registers of a given type (integer, floating point, string or
       general) per function
-    * 256 function literal declarations plus unique functions calls per
+    * 65536 function literal declarations plus unique functions calls per
       function
-    * 256 types available per function
-    * 256 unique native functions per function
+    * 65536 types available per function
+    * 65536 unique native functions per function
     * 16384 integer values per function
-    * 256 string values per function
+    * 65536 string values per function
     * 16384 floating-point values per function
-    * 256 general values per function
+    * 65536 general values per function
 
 `
@@ -114,6 +114,15 @@ var commandsHelp = map[string]func(){
 			`The report includes useful system information.`,
 		)
 	},
+	"debug": func() {
+		txtToHelp(helpDebug)
+	},
+	"extract": func() {
+		txtToHelp(helpExtract)
+	},
+	"fmt": func() {
+		txtToHelp(helpFmt)
+	},
 	"import": func() {
 		txtToHelp(helpImport)
 	},
@@ -123,6 +132,9 @@ var commandsHelp = map[string]func(){
 	"run": func() {
 		txtToHelp(helpRun)
 	},
+	"vet": func() {
+		txtToHelp(helpVet)
+	},
 	"serve": func() {
 		txtToHelp(helpServe)
 	},
@@ -156,6 +168,66 @@ var commands = map[string]func(){
 		fmt.Fprintf(os.Stdout, "If you encountered an issue, report it at:\n\n\thttps://github.com/open2b/scriggo/issues/new\n\n")
 		exit(0)
 	},
+	"debug": func() {
+		flag.Usage = commandsHelp["debug"]
+		root := flag.String("root", "", "set the root directory to named dir instead of the file's directory.")
+		var consts []string
+		flag.Func("const", "run with global constants with the given names and values.", func(s string) error {
+			consts = append(consts, s)
+			return nil
+		})
+		format := flag.String("format", "", "force debug to use the named file format.")
+		flag.Parse()
+		var name string
+		switch len(flag.Args()) {
+		case 0:
+		case 1:
+			name = flag.Arg(0)
+		default:
+			exitError("%s", "too many file names")
+		}
+		err := _debug(name, buildFlags{consts: consts, format: *format, root: *root})
+		if err != nil {
+			exitError("%s", err)
+		}
+		exit(0)
+	},
+	"extract": func() {
+		flag.Usage = commandsHelp["extract"]
+		root := flag.String("root", "", "set the root directory to named dir instead of the file's directory.")
+		var funcs []string
+		flag.Func("func", "extract the messages passed to the named translation function.", func(s string) error {
+			funcs = append(funcs, s)
+			return nil
+		})
+		format := flag.String("format", "", "force extract to use the named file format.")
+		text := flag.Bool("text", true, "extract the texts of the files.")
+		o := flag.String("o", "", "write the POT file to the named file instead of stdout.")
+		flag.Parse()
+		if len(flag.Args()) == 0 {
+			exitError("%s", "missing file name")
+		}
+		err := extract(flag.Args(), funcs, *text, buildFlags{format: *format, o: *o, root: *root})
+		if err != nil {
+			exitError("%s", err)
+		}
+		exit(0)
+	},
+	"fmt": func() {
+		flag.Usage = commandsHelp["fmt"]
+		l := flag.Bool("l", false, "list the files whose formatting differs from the canonical one.")
+		w := flag.Bool("w", false, "write the result to the files instead of stdout.")
+		format := flag.String("format", "", "force fmt to use the named file format.")
+		flag.Parse()
+		if len(flag.Args()) == 0 {
+			exitError("%s", "missing file name")
+		}
+		err := _fmt(flag.Args(), *l, buildFlags{format: *format, w: *w})
+		if err != nil {
+			exitError("%s", err)
+		}
+		exit(0)
+	},
 	"init": func() {
 		flag.Usage = commandsHelp["init"]
 		f := flag.String("f", "", "path of the Scriggofile.")
@@ -209,6 +281,8 @@ var commands = map[string]func(){
 		format := flag.String("format", "", "force run to use the named file format.")
 		s := flag.Int("S", 0, "print assembly listing. n determines the length of Text instructions.")
 		metrics := flag.Bool("metrics", false, "print metrics about file execution.")
+		profile := flag.String("profile", "", "write the profile of the execution to the named file.")
+		coverprofile := flag.String("coverprofile", "", "write the coverage profile of the execution to the named file.")
 		o := flag.String("o", "", "write the resulting code to the named file or directory instead of stdout.")
 		flag.Parse()
 		asm := -2 // -2: no assembler
@@ -229,12 +303,35 @@ var commands = map[string]func(){
 		default:
 			exitError("%s", "too many file names")
 		}
-		err := run(name, buildFlags{consts: consts, format: *format, metrics: *metrics, o: *o, root: *root, s: asm})
+		err := run(name, buildFlags{consts: consts, coverprofile: *coverprofile, format: *format, metrics: *metrics, o: *o, profile: *profile, root: *root, s: asm})
 		if err != nil {
 			exitError("%s", err)
 		}
 		exit(0)
 	},
+	"vet": func() {
+		flag.Usage = commandsHelp["vet"]
+		root := flag.String("root", "", "set the root directory to named dir instead of the file's directory.")
+		var consts []string
+		flag.Func("const", "vet with global constants with the given names and values.", func(s string) error {
+			consts = append(consts, s)
+			return nil
+		})
+		format := flag.String("format", "", "force vet to use the named file format.")
+		flag.Parse()
+		if len(flag.Args()) == 0 {
+			exitError("%s", "missing file name")
+		}
+		n, err := vet(os.Stderr, flag.Args(), buildFlags{consts: consts, format: *format, root: *root})
+		if err != nil {
+			exitError("%s", err)
+		}
+		if n > 0 {
+			exit(1)
+			return
+		}
+		exit(0)
+	},
 	"serve": func() {
 		flag.Usage = commandsHelp["serve"]
 		s := flag.Int("S", 0, "print assembly listing. n determines the length of Text instructions.")
@@ -420,6 +517,7 @@ func _import(path string, flags buildFlags) (err error) {
 type buildFlags struct {
 	metrics, work, v, x, w bool
 	f, format, o, root     string
+	profile, coverprofile  string
 	consts                 []string
 	s                      int
 }
@@ -37,56 +37,21 @@ const runBufSize = 65536
 //
 func run(name string, flags buildFlags) (err error) {
 
-	var fsys fs.FS
-	if flags.root == "" {
-		fsys = os.DirFS(filepath.Dir(name))
-		name = filepath.Base(name)
-	} else {
-		root, err := filepath.Abs(flags.root)
-		if err != nil {
-			return err
-		}
-		nameAbs, err := filepath.Abs(name)
-		if err != nil {
-			return err
-		}
-		name, err = filepath.Rel(root, nameAbs)
-		if err != nil {
-			return err
-		}
-		fsys = os.DirFS(root)
+	root := flags.root
+	if root == "" {
+		root = filepath.Dir(name)
 	}
 
-	// Handle "-format" option.
-	if flags.f != "" {
-		format, err := parseFormat(flags.f)
-		if err != nil {
-			return err
-		}
-		fsys = formatFS{FS: fsys, format: format}
-	}
-
-	md := goldmark.New(
-		goldmark.WithRendererOptions(html.WithUnsafe()),
-		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
-		goldmark.WithExtensions(extension.GFM))
-
-	opts := &scriggo.BuildOptions{
-		AllowGoStmt: true,
-		Globals:     globals,
-		MarkdownConverter: func(src []byte, out io.Writer) error {
-			return md.Convert(src, out)
-		},
+	fsys, name, err := runFS(name, flags)
+	if err != nil {
+		return err
 	}
-	opts.Globals["filepath"] = strings.TrimSuffix(name, path.Ext(name))
 
-	// Handle "-const" option.
-	for _, consts := range flags.consts {
-		err = parseConstants(consts, opts.Globals)
-		if err != nil {
-			return err
-		}
+	opts, err := runBuildOptions(name, flags)
+	if err != nil {
+		return err
 	}
+	opts.Coverage = flags.coverprofile != ""
 
 	var start time.Time
 	if flags.metrics {
@@ -125,12 +90,37 @@ func run(name string, flags buildFlags) (err error) {
 
 	buf := bufio.NewWriterSize(out, runBufSize)
 
+	// Handle "-profile" option.
+	var options *scriggo.RunOptions
+	if flags.profile != "" {
+		fi, err := os.Create(flags.profile)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err2 := fi.Close(); err == nil {
+				err = err2
+			}
+		}()
+		options = &scriggo.RunOptions{Profile: fi}
+	}
+
+	// Handle "-coverprofile" option.
+	var coverage *scriggo.Coverage
+	if flags.coverprofile != "" {
+		coverage = &scriggo.Coverage{}
+		if options == nil {
+			options = &scriggo.RunOptions{}
+		}
+		options.Coverage = coverage
+	}
+
 	if flags.metrics {
 		start = time.Now()
 	}
 
 	// Run the template.
-	err = template.Run(buf, nil, nil)
+	err = template.Run(buf, nil, options)
 
 	if flags.metrics {
 		runTime := time.Since(start)
@@ -143,9 +133,94 @@ func run(name string, flags buildFlags) (err error) {
 		err = buf.Flush()
 	}
 
+	if coverage != nil {
+		if err2 := writeCoverProfile(flags.coverprofile, coverage, root); err == nil {
+			err = err2
+		}
+	}
+
+	return err
+}
+
+// writeCoverProfile writes the coverage profile to the named file. root is
+// the root directory of the template files.
+func writeCoverProfile(name string, coverage *scriggo.Coverage, root string) error {
+	fi, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = coverage.WriteProfile(fi, root)
+	if err2 := fi.Close(); err == nil {
+		err = err2
+	}
 	return err
 }
 
+// runFS returns the file system with the template file name and the name
+// of the file in the file system. The file system is rooted at the directory
+// of the file or, if it is not empty, at flags.root.
+func runFS(name string, flags buildFlags) (fs.FS, string, error) {
+
+	var fsys fs.FS
+	if flags.root == "" {
+		fsys = os.DirFS(filepath.Dir(name))
+		name = filepath.Base(name)
+	} else {
+		root, err := filepath.Abs(flags.root)
+		if err != nil {
+			return nil, "", err
+		}
+		nameAbs, err := filepath.Abs(name)
+		if err != nil {
+			return nil, "", err
+		}
+		name, err = filepath.Rel(root, nameAbs)
+		if err != nil {
+			return nil, "", err
+		}
+		fsys = os.DirFS(root)
+	}
+
+	// Handle "-format" option.
+	if flags.format != "" {
+		format, err := parseFormat(flags.format)
+		if err != nil {
+			return nil, "", err
+		}
+		fsys = formatFS{FS: fsys, format: format}
+	}
+
+	return fsys, name, nil
+}
+
+// runBuildOptions returns the options to build the template file name.
+func runBuildOptions(name string, flags buildFlags) (*scriggo.BuildOptions, error) {
+
+	md := goldmark.New(
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		goldmark.WithExtensions(extension.GFM))
+
+	opts := &scriggo.BuildOptions{
+		AllowGoStmt: true,
+		Globals:     globals,
+		MarkdownConverter: func(src []byte, out io.Writer) error {
+			return md.Convert(src, out)
+		},
+	}
+	opts.Globals["filepath"] = strings.TrimSuffix(name, path.Ext(name))
+
+	// Handle "-const" option.
+	for _, consts := range flags.consts {
+		err := parseConstants(consts, opts.Globals)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return opts, nil
+}
+
 // parseFormat parses and returns a format.
 func parseFormat(s string) (scriggo.Format, error) {
 	switch s {
@@ -161,8 +236,14 @@ func parseFormat(s string) (scriggo.Format, error) {
 		return scriggo.FormatJS, nil
 	case "JSON":
 		return scriggo.FormatJSON, nil
+	case "XML":
+		return scriggo.FormatXML, nil
+	case "YAML":
+		return scriggo.FormatYAML, nil
+	case "TOML":
+		return scriggo.FormatTOML, nil
 	}
-	return 0, fmt.Errorf("invalid format %s, format can be Text, HTML, Markdown, CSS, JS or JSON", s)
+	return 0, fmt.Errorf("invalid format %s, format can be Text, HTML, Markdown, CSS, JS, JSON, XML, YAML or TOML", s)
 }
 
 // formatFS is a file system that implements scriggo.FormatFS.
@@ -19,11 +19,10 @@ import (
 	"time"
 
 	"github.com/open2b/scriggo"
-	"github.com/open2b/scriggo/ast"
 	"github.com/open2b/scriggo/builtin"
 	"github.com/open2b/scriggo/native"
+	"github.com/open2b/scriggo/templatecache"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
@@ -42,63 +41,39 @@ import (
 //
 func serve(asm int, metrics bool) error {
 
-	fsys, err := newTemplateFS(".")
-	if err != nil {
-		return err
-	}
-	defer fsys.Close()
-
 	md := goldmark.New(
 		goldmark.WithRendererOptions(html.WithUnsafe()),
 		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
 		goldmark.WithExtensions(extension.GFM))
 
-	srv := &server{
-		fsys:   fsys,
-		static: http.FileServer(http.Dir(".")),
-		mdConverter: func(src []byte, out io.Writer) error {
+	opts := scriggo.BuildOptions{
+		AllowGoStmt: true,
+		MarkdownConverter: func(src []byte, out io.Writer) error {
 			return md.Convert(src, out)
 		},
-		templates:             map[string]*scriggo.Template{},
-		templatesDependencies: map[string]map[string]struct{}{},
-		asm:                   asm,
+		Globals: make(native.Declarations, len(globals)+1),
+	}
+	for n, v := range globals {
+		opts.Globals[n] = v
+	}
+	opts.Globals["filepath"] = (*string)(nil)
+
+	templates, err := templatecache.NewDir(".", &templatecache.Options{BuildOptions: &opts})
+	if err != nil {
+		return err
+	}
+	defer templates.Close()
+
+	srv := &server{
+		fsys:      os.DirFS("."),
+		static:    http.FileServer(http.Dir(".")),
+		templates: templates,
+		asm:       asm,
 	}
 	if metrics {
 		srv.metrics.active = true
 		srv.metrics.header = true
 	}
-	go func() {
-		for {
-			select {
-			case name := <-fsys.Changed:
-				srv.Lock()
-				if _, ok := srv.templates[name]; ok {
-					delete(srv.templates, name)
-					for _, dependents := range srv.templatesDependencies {
-						delete(dependents, name)
-					}
-				} else {
-					var invalidatedFiles []string
-					for dependency, dependents := range srv.templatesDependencies {
-						if dependency == name {
-							for d := range dependents {
-								delete(srv.templates, d)
-								invalidatedFiles = append(invalidatedFiles, d)
-							}
-						}
-					}
-					for _, invalidated := range invalidatedFiles {
-						for _, dependents := range srv.templatesDependencies {
-							delete(dependents, invalidated)
-						}
-					}
-				}
-				srv.Unlock()
-			case err := <-fsys.Errors:
-				srv.logf("%v", err)
-			}
-		}
-	}()
 
 	s := &http.Server{
 		Addr:           ":8080",
@@ -114,58 +89,15 @@ func serve(asm int, metrics bool) error {
 	return s.ListenAndServe()
 }
 
-func (srv *server) updateTemplateDependencies(tree *ast.Tree) error {
-
-	var treeNavigation func(*ast.Tree, bool) []string
-
-	treeNavigation = func(tree *ast.Tree, includeRoot bool) []string {
-		var dependencies []string
-		if includeRoot {
-			dependencies = []string{tree.Path}
-		}
-		for _, n := range tree.Nodes {
-			switch node := n.(type) {
-			case *ast.Show:
-				for _, e := range node.Expressions {
-					switch expr := e.(type) {
-					case *ast.Render:
-						dependencies = append(dependencies, treeNavigation(expr.Tree, true)...)
-					}
-				}
-			case *ast.Import:
-				dependencies = append(dependencies, treeNavigation(node.Tree, true)...)
-			case *ast.Extends:
-				dependencies = append(dependencies, treeNavigation(node.Tree, true)...)
-			default:
-			}
-		}
-		return dependencies
-	}
-
-	dependencies := treeNavigation(tree, false)
-	srv.Lock()
-	for _, dependency := range dependencies {
-		if _, ok := srv.templatesDependencies[dependency]; !ok {
-			srv.templatesDependencies[dependency] = map[string]struct{}{}
-		}
-		srv.templatesDependencies[dependency][tree.Path] = struct{}{}
-	}
-	srv.Unlock()
-
-	return nil
-}
-
 type server struct {
-	fsys        *templateFS
-	static      http.Handler
-	mdConverter scriggo.Converter
-	runOptions  *scriggo.RunOptions
-	asm         int
+	fsys       fs.FS
+	static     http.Handler
+	templates  *templatecache.Cache
+	runOptions *scriggo.RunOptions
+	asm        int
 
 	sync.Mutex
-	templates             map[string]*scriggo.Template
-	templatesDependencies map[string]map[string]struct{}
-	metrics               struct {
+	metrics struct {
 		active bool
 		header bool
 	}
@@ -205,47 +137,30 @@ func (srv *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var err error
-	var buildTime time.Duration
-	srv.Lock()
-	template, ok := srv.templates[name]
-	srv.Unlock()
 	start := time.Now()
-	if !ok {
-		opts := scriggo.BuildOptions{
-			AllowGoStmt:       true,
-			MarkdownConverter: srv.mdConverter,
-			Globals:           make(native.Declarations, len(globals)+1),
-			TreeTransformer:   srv.updateTemplateDependencies,
-		}
-		for n, v := range globals {
-			opts.Globals[n] = v
+	template, err := srv.templates.Get(name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.NotFound(w, r)
+			return
 		}
-		opts.Globals["filepath"] = strings.TrimSuffix(name, path.Ext(name))
-		template, err = scriggo.BuildTemplate(srv.fsys, name, &opts)
-		if err != nil {
-			if errors.Is(err, os.ErrNotExist) {
-				http.NotFound(w, r)
-				return
-			}
-			if err, ok := err.(*scriggo.BuildError); ok {
-				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-				w.WriteHeader(500)
-				fmt.Fprintf(w, "%s", err)
-				return
-			}
-			http.Error(w, "Internal Server Error", 500)
-			srv.logf("%s", err)
+		if err, ok := err.(*scriggo.BuildError); ok {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(500)
+			fmt.Fprintf(w, "%s", err)
 			return
 		}
-		buildTime = time.Since(start)
-		srv.Lock()
-		srv.templates[name] = template
-		srv.Unlock()
-		start = time.Now()
+		http.Error(w, "Internal Server Error", 500)
+		srv.logf("%s", err)
+		return
 	}
+	buildTime := time.Since(start)
+	start = time.Now()
 	b := bytes.Buffer{}
-	vars := map[string]interface{}{"form": builtin.NewFormData(r, 10)}
+	vars := map[string]interface{}{
+		"filepath": strings.TrimSuffix(name, path.Ext(name)),
+		"form":     builtin.NewFormData(r, 10),
+	}
 	err = template.Run(&b, vars, srv.runOptions)
 	if err != nil {
 		switch err {
@@ -278,11 +193,7 @@ func (srv *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			srv.metrics.header = false
 			srv.Unlock()
 		}
-		if buildTime == 0 {
-			fmt.Fprintf(os.Stderr, "     %12s  %12s  %12s  %s\n", "-", runTime, buildTime+runTime, name)
-		} else {
-			fmt.Fprintf(os.Stderr, "     %12s  %12s  %12s  %s\n", buildTime, runTime, buildTime+runTime, name)
-		}
+		fmt.Fprintf(os.Stderr, "     %12s  %12s  %12s  %s\n", buildTime, runTime, buildTime+runTime, name)
 	}
 
 	if srv.asm >= -1 {
@@ -309,83 +220,6 @@ func (srv *server) logf(format string, a ...interface{}) {
 	srv.metrics.header = true
 }
 
-// templateFS implements a file system that reads the files in a directory.
-type templateFS struct {
-	fsys    fs.FS
-	watcher *fsnotify.Watcher
-	watched map[string]bool
-	Errors  chan error
-
-	sync.Mutex
-	Changed chan string
-}
-
-func newTemplateFS(root string) (*templateFS, error) {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return nil, err
-	}
-	dir := &templateFS{
-		fsys:    os.DirFS(root),
-		watcher: watcher,
-		watched: map[string]bool{},
-		Changed: make(chan string),
-	}
-	go func() {
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				if event.Op&fsnotify.Write == fsnotify.Write {
-					dir.Changed <- strings.ReplaceAll(event.Name, "\\", "/")
-				}
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				dir.Errors <- err
-			}
-		}
-	}()
-	return dir, nil
-}
-
-func (t *templateFS) Open(name string) (fs.File, error) {
-	err := t.watch(name)
-	if err != nil {
-		return nil, err
-	}
-	return t.fsys.Open(name)
-}
-
-func (t *templateFS) ReadFile(name string) ([]byte, error) {
-	err := t.watch(name)
-	if err != nil {
-		return nil, err
-	}
-	return fs.ReadFile(t.fsys, name)
-}
-
-func (t *templateFS) Close() error {
-	return t.watcher.Close()
-}
-
-func (t *templateFS) watch(name string) error {
-	t.Lock()
-	if !t.watched[name] {
-		err := t.watcher.Add(name)
-		if err != nil {
-			t.Unlock()
-			return err
-		}
-		t.watched[name] = true
-	}
-	t.Unlock()
-	return nil
-}
-
 var globals = native.Declarations{
 	// crypto
 	"hmacSHA1":   builtin.HmacSHA1,
@@ -0,0 +1,196 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// trampoline represents the trampoline, rendered as Go code, of the native
+// functions with a given type. A trampoline calls a native function without
+// using the reflection.
+type trampoline struct {
+	Type string // function type.
+	Body string // body of the trampoline function.
+}
+
+// isTrampolineSignature reports whether a trampoline can be generated for
+// the functions with signature sig.
+func isTrampolineSignature(sig *types.Signature) bool {
+	if sig.Variadic() {
+		return false
+	}
+	for _, tuple := range []*types.Tuple{sig.Params(), sig.Results()} {
+		for i := 0; i < tuple.Len(); i++ {
+			if !isTrampolineType(tuple.At(i).Type()) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isTrampolineType reports whether t can be the type of a parameter or a
+// result of a function with a trampoline. t can only be composed of
+// predeclared types and it cannot be, or contain, a function, a struct or a
+// non-empty interface type, except the error type.
+func isTrampolineType(t types.Type) bool {
+	switch t := t.(type) {
+	case *types.Basic:
+		k := t.Kind()
+		return k != types.Invalid && k != types.UnsafePointer && t.Info()&types.IsUntyped == 0
+	case *types.Named:
+		return t.Obj().Pkg() == nil && t.Obj().Name() == "error"
+	case *types.Interface:
+		return t.Empty()
+	case *types.Pointer:
+		return isTrampolineType(t.Elem())
+	case *types.Slice:
+		return isTrampolineType(t.Elem())
+	case *types.Array:
+		return isTrampolineType(t.Elem())
+	case *types.Chan:
+		return isTrampolineType(t.Elem())
+	case *types.Map:
+		return isTrampolineType(t.Key()) && isTrampolineType(t.Elem())
+	}
+	return false
+}
+
+// trampolineRegister returns the name of the field of native.Registers that
+// holds the values of type t.
+func trampolineRegister(t types.Type) string {
+	if t, ok := t.(*types.Basic); ok {
+		info := t.Info()
+		switch {
+		case info&(types.IsBoolean|types.IsInteger) != 0:
+			return "Int"
+		case info&types.IsFloat != 0:
+			return "Float"
+		case info&types.IsString != 0:
+			return "String"
+		}
+	}
+	return "General"
+}
+
+// renderTrampoline renders the trampoline of the functions with signature
+// sig. sig must be a signature for which isTrampolineSignature returns true.
+func renderTrampoline(sig *types.Signature) trampoline {
+
+	params, results := sig.Params(), sig.Results()
+
+	typ := func(t types.Type) string {
+		return types.TypeString(t, nil)
+	}
+	join := func(tuple *types.Tuple, f func(i int, t types.Type) string) string {
+		s := make([]string, tuple.Len())
+		for i := range s {
+			s[i] = f(i, tuple.At(i).Type())
+		}
+		return strings.Join(s, ", ")
+	}
+
+	// Render the function type.
+	funcType := "func(" + join(params, func(_ int, t types.Type) string { return typ(t) }) + ")"
+	switch results.Len() {
+	case 0:
+	case 1:
+		funcType += " " + typ(results.At(0).Type())
+	default:
+		funcType += " (" + join(results, func(_ int, t types.Type) string { return typ(t) }) + ")"
+	}
+
+	// register returns the next register of the kind of the values of type t.
+	var next = map[string]int{}
+	register := func(t types.Type) string {
+		field := trampolineRegister(t)
+		r := "r." + field + "[" + strconv.Itoa(next[field]) + "]"
+		next[field]++
+		return r
+	}
+
+	var body []string
+
+	// Render the arguments. They follow the results in the registers.
+	for i := 0; i < results.Len(); i++ {
+		next[trampolineRegister(results.At(i).Type())]++
+	}
+	args := join(params, func(i int, t types.Type) string {
+		r := register(t)
+		switch t := t.Underlying().(type) {
+		case *types.Basic:
+			switch k := t.Kind(); {
+			case k == types.Bool:
+				return r + " > 0"
+			case k == types.Int64, k == types.Float64, k == types.String:
+				return r
+			case t.Info()&types.IsComplex == 0:
+				return typ(t) + "(" + r + ")"
+			}
+		case *types.Interface:
+			// A nil interface value is stored as the zero Value.
+			arg := "a" + strconv.Itoa(i)
+			value := "v.Interface()"
+			if !t.Empty() {
+				value += ".(" + typ(params.At(i).Type()) + ")"
+			}
+			body = append(body,
+				"var "+arg+" "+typ(params.At(i).Type()),
+				"if v := "+r+"; v.IsValid() {",
+				"\t"+arg+" = "+value,
+				"}")
+			return arg
+		}
+		return r + ".Interface().(" + typ(t) + ")"
+	})
+
+	// Render the call.
+	call := "f.(" + funcType + ")(" + args + ")"
+	if results.Len() == 0 {
+		body = append(body, call)
+	} else {
+		body = append(body, join(results, func(i int, _ types.Type) string {
+			return "r" + strconv.Itoa(i)
+		})+" := "+call)
+	}
+
+	// Render the results.
+	next = map[string]int{}
+	for i := 0; i < results.Len(); i++ {
+		t := results.At(i).Type()
+		r := register(t)
+		res := "r" + strconv.Itoa(i)
+		if t, ok := t.(*types.Basic); ok {
+			switch k := t.Kind(); {
+			case k == types.Bool:
+				body = append(body,
+					"if "+res+" {",
+					"\t"+r+" = 1",
+					"} else {",
+					"\t"+r+" = 0",
+					"}")
+				continue
+			case k == types.Int64, k == types.Float64, k == types.String:
+				body = append(body, r+" = "+res)
+				continue
+			case t.Info()&types.IsInteger != 0:
+				body = append(body, r+" = int64("+res+")")
+				continue
+			case t.Info()&types.IsFloat != 0:
+				body = append(body, r+" = float64("+res+")")
+				continue
+			}
+		}
+		body = append(body, r+" = reflect.ValueOf("+res+")")
+	}
+
+	return trampoline{
+		Type: funcType,
+		Body: strings.Join(body, "\n\t\t"),
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+var trampolineTests = []struct {
+	decl        string
+	typ         string
+	body        string
+	unsupported bool
+}{
+	{"func F()", "func()", "f.(func())()", false},
+	{"func F(s string) int", "func(string) int", "r0 := f.(func(string) int)(r.String[0])\n\t\tr.Int[0] = int64(r0)", false},
+	{"func F(s string, n int) (string, error)", "func(string, int) (string, error)",
+		"r0, r1 := f.(func(string, int) (string, error))(r.String[1], int(r.Int[0]))\n\t\tr.String[0] = r0\n\t\tr.General[0] = reflect.ValueOf(r1)", false},
+	{"func F(b bool, f float32) bool", "func(bool, float32) bool",
+		"r0 := f.(func(bool, float32) bool)(r.Int[1] > 0, float32(r.Float[0]))\n\t\tif r0 {\n\t\t\tr.Int[0] = 1\n\t\t} else {\n\t\t\tr.Int[0] = 0\n\t\t}", false},
+	{"func F(v interface{}, e error) []byte", "func(interface{}, error) []byte",
+		"var a0 interface{}\n\t\tif v := r.General[1]; v.IsValid() {\n\t\t\ta0 = v.Interface()\n\t\t}\n\t\t" +
+			"var a1 error\n\t\tif v := r.General[2]; v.IsValid() {\n\t\t\ta1 = v.Interface().(error)\n\t\t}\n\t\t" +
+			"r0 := f.(func(interface{}, error) []byte)(a0, a1)\n\t\tr.General[0] = reflect.ValueOf(r0)", false},
+	{"func F(m map[string][]int, c complex128) (uint8, float64)", "func(map[string][]int, complex128) (uint8, float64)",
+		"r0, r1 := f.(func(map[string][]int, complex128) (uint8, float64))(r.General[0].Interface().(map[string][]int), r.General[1].Interface().(complex128))\n\t\tr.Int[0] = int64(r0)\n\t\tr.Float[0] = r1", false},
+	{"func F(s ...string)", "", "", true},
+	{"func F(f func())", "", "", true},
+	{"func F(t T)", "", "", true},
+	{"func F() *T", "", "", true},
+	{"func F(s struct{})", "", "", true},
+	{"func F(s Stringer)", "", "", true},
+}
+
+func Test_renderTrampoline(t *testing.T) {
+	for _, test := range trampolineTests {
+		t.Run(test.decl, func(t *testing.T) {
+			src := "package p\n\ntype T int\n\ntype Stringer interface{ String() string }\n\n" + test.decl + " { panic(0) }\n"
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "p.go", src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var conf types.Config
+			pkg, err := conf.Check("p", fset, []*ast.File{file}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig := pkg.Scope().Lookup("F").Type().(*types.Signature)
+			if ok := isTrampolineSignature(sig); ok == test.unsupported {
+				t.Fatalf("expecting isTrampolineSignature to return %t, got %t", !test.unsupported, ok)
+			}
+			if test.unsupported {
+				return
+			}
+			got := renderTrampoline(sig)
+			if got.Type != test.typ {
+				t.Fatalf("expecting type %q, got %q", test.typ, got.Type)
+			}
+			if got.Body != test.body {
+				t.Fatalf("expecting body:\n%s\ngot:\n%s", test.body, got.Body)
+			}
+		})
+	}
+}
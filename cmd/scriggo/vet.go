@@ -0,0 +1,56 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/open2b/scriggo"
+)
+
+// vet executes the sub command "vet":
+//
+//		scriggo vet [-root dir] [-const name=value] [-format format] file...
+//
+// It type checks the template files names, without running them, and writes
+// to out the problems found. A problem in a file shared by several files is
+// written only once. It returns the number of problems.
+func vet(out io.Writer, names []string, flags buildFlags) (int, error) {
+	n := 0
+	written := map[string]bool{}
+	for _, name := range names {
+		fsys, base, err := runFS(name, flags)
+		if err != nil {
+			return n, err
+		}
+		opts, err := runBuildOptions(base, flags)
+		if err != nil {
+			return n, err
+		}
+		errs, err := scriggo.VetTemplate(fsys, base, opts)
+		if err != nil {
+			return n, err
+		}
+		root := flags.root
+		if root == "" {
+			root = filepath.Dir(name)
+		}
+		for _, e := range errs {
+			problem := fmt.Sprintf("%s:%s: %s\n", filepath.Join(root, e.Path()), e.Position(), e.Message())
+			if written[problem] {
+				continue
+			}
+			_, err = io.WriteString(out, problem)
+			if err != nil {
+				return n, err
+			}
+			written[problem] = true
+			n++
+		}
+	}
+	return n, nil
+}
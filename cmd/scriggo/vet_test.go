@@ -0,0 +1,45 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestVet tests the vet function.
+func TestVet(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":   "{% import \"imp.html\" %}\n{% macro m %}a{% end %}\n",
+		"page.html":    "{% import \"imp.html\" %}{{ M() }}",
+		"imp.html":     "{% macro M %}a{% return %}b{% end %}",
+		"invalid.html": "{% if %}",
+	}
+	for name, src := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var b strings.Builder
+	names := []string{filepath.Join(dir, "index.html"), filepath.Join(dir, "page.html")}
+	n, err := vet(&b, names, buildFlags{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := filepath.Join(dir, "imp.html") + ":1:27: unreachable code\n" +
+		filepath.Join(dir, "index.html") + ":1:11: imported and not used: \"imp.html\"\n" +
+		filepath.Join(dir, "index.html") + ":2:10: macro m declared but not used\n"
+	if n != 3 || b.String() != expected {
+		t.Fatalf("unexpected %d problems:\n%s\nexpecting:\n%s", n, b.String(), expected)
+	}
+	_, err = vet(&b, []string{filepath.Join(dir, "invalid.html")}, buildFlags{})
+	if err == nil || err.Error() != "invalid.html:1:7: syntax error: missing condition in if statement" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
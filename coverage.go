@@ -0,0 +1,102 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scriggo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/open2b/scriggo/internal/runtime"
+)
+
+// Coverage collects the coverage of the executions of templates and programs
+// built with the Coverage option. It counts how many times each block of
+// code is executed, where a block is the body of an if, switch, select or
+// for statement, or the body of a function or macro.
+//
+// A Coverage can collect the coverage of many executions, also of different
+// templates and programs and also concurrently. The counts of the same block
+// are summed. The zero value of Coverage is an empty coverage ready to use.
+type Coverage struct {
+	mu     sync.Mutex
+	counts map[runtime.CoverBlock]uint64
+}
+
+// add adds to the coverage the counters of an execution of a code with the
+// given blocks.
+func (c *Coverage) add(blocks []runtime.CoverBlock, counters []uint32) {
+	c.mu.Lock()
+	if c.counts == nil {
+		c.counts = map[runtime.CoverBlock]uint64{}
+	}
+	for i, block := range blocks {
+		c.counts[block] += uint64(atomic.LoadUint32(&counters[i]))
+	}
+	c.mu.Unlock()
+}
+
+// WriteProfile writes the coverage to w as a Go coverage profile, in the
+// "count" mode, so that it can be read by the 'go tool cover' command. For
+// example
+//
+//	go tool cover -html=coverage.out
+//
+// shows the templates and the programs with their covered blocks.
+//
+// As 'go tool cover' reads the files from the paths in the profile, the
+// paths of the files are joined to dir, that should be the directory, on
+// the local file system, of the file system from which the templates and
+// the programs have been built. A relative path is written with the "./"
+// prefix so that it is read relative to the current directory.
+func (c *Coverage) WriteProfile(w io.Writer, dir string) error {
+	c.mu.Lock()
+	blocks := make([]runtime.CoverBlock, 0, len(c.counts))
+	for block := range c.counts {
+		blocks = append(blocks, block)
+	}
+	counts := make([]uint64, len(blocks))
+	sort.Slice(blocks, func(i, j int) bool {
+		b1, b2 := blocks[i], blocks[j]
+		if b1.Path != b2.Path {
+			return b1.Path < b2.Path
+		}
+		if b1.StartLine != b2.StartLine {
+			return b1.StartLine < b2.StartLine
+		}
+		if b1.StartCol != b2.StartCol {
+			return b1.StartCol < b2.StartCol
+		}
+		if b1.EndLine != b2.EndLine {
+			return b1.EndLine < b2.EndLine
+		}
+		return b1.EndCol < b2.EndCol
+	})
+	for i, block := range blocks {
+		counts[i] = c.counts[block]
+	}
+	c.mu.Unlock()
+	paths := map[string]string{}
+	bw := bufio.NewWriter(w)
+	_, _ = bw.WriteString("mode: count\n")
+	for i, block := range blocks {
+		name, ok := paths[block.Path]
+		if !ok {
+			name = filepath.Join(dir, filepath.FromSlash(block.Path))
+			if !filepath.IsAbs(name) && !strings.HasPrefix(name, ".") {
+				name = "." + string(filepath.Separator) + name
+			}
+			paths[block.Path] = name
+		}
+		_, _ = fmt.Fprintf(bw, "%s:%d.%d,%d.%d %d %d\n", name, block.StartLine, block.StartCol,
+			block.EndLine, block.EndCol, block.NumStmt, counts[i])
+	}
+	return bw.Flush()
+}
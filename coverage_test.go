@@ -0,0 +1,142 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scriggo
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/open2b/scriggo/internal/fstest"
+)
+
+// coverageTemplateFiles are the files of the template used to test the
+// coverage.
+var coverageTemplateFiles = fstest.Files{
+	"index.html": "{% extends \"layout.html\" %}\n{% macro Item(i int) %}\n<li>{{ i }}</li>\n{% end %}\n{% macro Unused %}unused{% end %}",
+	"layout.html": "<ul>\n{% for i := 0; i < 5; i++ %}\n" +
+		"{% if i%2 == 0 %}\n{{ Item(i) }}\n{% else if i > 10 %}\nnever\n{% else %}\nodd\n{% end %}\n" +
+		"{% end %}\n</ul>\n" +
+		"{% switch len(\"ab\") %}\n{% case 1 %}one\n{% case 2 %}two\n{% default %}other\n{% end %}",
+}
+
+// coverageTemplateProfile is the profile of two executions of the template
+// in coverageTemplateFiles.
+const coverageTemplateProfile = `mode: count
+./index.html:2.24,4.1 3 6
+./index.html:5.19,5.25 1 0
+./layout.html:2.29,10.1 3 10
+./layout.html:3.18,5.1 3 6
+./layout.html:5.21,7.1 1 0
+./layout.html:7.11,9.1 1 4
+./layout.html:13.13,14.1 1 0
+./layout.html:14.13,15.1 1 2
+./layout.html:15.14,16.1 1 0
+`
+
+func TestCoverageTemplate(t *testing.T) {
+	for _, optimize := range []bool{false, true} {
+		template, err := BuildTemplate(coverageTemplateFiles, "index.html", &BuildOptions{Coverage: true, Optimize: optimize})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var coverage Coverage
+		for i := 0; i < 2; i++ {
+			err = template.Run(io.Discard, nil, &RunOptions{Coverage: &coverage})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		var b strings.Builder
+		err = coverage.WriteProfile(&b, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != coverageTemplateProfile {
+			t.Fatalf("optimize %t: unexpected profile:\n%s\nexpected:\n%s", optimize, got, coverageTemplateProfile)
+		}
+	}
+}
+
+func TestCoverageProgram(t *testing.T) {
+	src := "package main\n\nfunc even(n int) bool {\n\treturn n%2 == 0\n}\n\nfunc main() {\n\tn := 0\n" +
+		"\tfor i := 0; i < 3; i++ {\n\t\tif even(i) {\n\t\t\tn++\n\t\t}\n\t}\n\tswitch n {\n\tcase 0:\n\t\tpanic(\"zero\")\n\t}\n}\n"
+	program, err := Build(fstest.Files{"main.go": src}, &BuildOptions{Coverage: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	coverage := &Coverage{}
+	err = program.Run(&RunOptions{Coverage: coverage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	err = coverage.WriteProfile(&b, "/src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "mode: count\n" +
+		"/src/main.go:4.2,4.17 1 3\n" +
+		"/src/main.go:8.2,17.3 3 1\n" +
+		"/src/main.go:10.3,12.4 1 3\n" +
+		"/src/main.go:11.4,11.7 1 2\n" +
+		"/src/main.go:16.3,16.16 1 0\n"
+	if got := b.String(); got != expected {
+		t.Fatalf("unexpected profile:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+func TestCoverageNotInstrumented(t *testing.T) {
+	template, err := BuildTemplate(coverageTemplateFiles, "index.html", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(template.Disassemble(0)), "Cover") {
+		t.Fatal("unexpected Cover instruction")
+	}
+	var coverage Coverage
+	err = template.Run(io.Discard, nil, &RunOptions{Coverage: &coverage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	err = coverage.WriteProfile(&b, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != "mode: count\n" {
+		t.Fatalf("unexpected profile:\n%s", got)
+	}
+}
+
+func TestCoverageMarshalBinary(t *testing.T) {
+	template, err := BuildTemplate(coverageTemplateFiles, "index.html", &BuildOptions{Coverage: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := template.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadTemplate(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var coverage Coverage
+	for _, tmpl := range []*Template{template, loaded} {
+		err = tmpl.Run(io.Discard, nil, &RunOptions{Coverage: &coverage})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var b strings.Builder
+	err = coverage.WriteProfile(&b, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != coverageTemplateProfile {
+		t.Fatalf("unexpected profile:\n%s\nexpected:\n%s", got, coverageTemplateProfile)
+	}
+}
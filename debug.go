@@ -0,0 +1,162 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scriggo
+
+import (
+	"reflect"
+
+	"github.com/open2b/scriggo/internal/compiler"
+	"github.com/open2b/scriggo/internal/runtime"
+)
+
+// Debugger is implemented by debuggers that control the execution of programs
+// and templates. A debugger is set with the Debugger field of RunOptions.
+//
+// Only the main goroutine is debugged and the execution does not stop in
+// functions called by native code.
+type Debugger interface {
+
+	// Breakpoint reports whether the execution must stop before executing the
+	// statement at the given line of the file with the given path. It is
+	// called only when the execution reaches a new line.
+	//
+	// Breakpoint is called by the goroutine that runs the code and can be
+	// used also to pause the execution at the next line.
+	Breakpoint(path string, line int) bool
+
+	// Stop is called when the execution stops at a breakpoint or at the end
+	// of a step. The execution is suspended until Stop returns and then it is
+	// resumed as indicated by the returned step mode.
+	Stop(state *DebugState) StepMode
+}
+
+// StepMode indicates how the execution is resumed after a stop.
+type StepMode int
+
+const (
+	StepContinue StepMode = iota // continue until the next breakpoint.
+	StepIn                       // stop at the next line, also in a called function.
+	StepOver                     // stop at the next line of the current function.
+	StepOut                      // stop at the next line of the calling function.
+)
+
+// DebugFrame represents a frame of the call stack of a stopped execution.
+type DebugFrame struct {
+	Package  string   // path of the package.
+	Function string   // name of the function; empty for function literals.
+	Path     string   // path of the file where the execution is in.
+	Position Position // position in the file where the execution is in.
+}
+
+// DebugVariable represents a variable read by a debugger.
+type DebugVariable struct {
+	Name  string      // name of the variable.
+	Type  string      // type of the variable, as it is written in the code.
+	Value interface{} // value of the variable.
+}
+
+// DebugState represents the state of a stopped execution. It is valid only
+// until the Stop method of the debugger returns.
+type DebugState struct {
+	state   *runtime.DebugState
+	frames  []runtime.DebugFrame
+	globals []compiler.Global
+}
+
+// Breakpoint reports whether the execution is stopped at a breakpoint.
+func (s *DebugState) Breakpoint() bool {
+	return s.state.Breakpoint()
+}
+
+// Frames returns the frames of the call stack. The first frame is the frame
+// of the function where the execution is stopped.
+func (s *DebugState) Frames() []DebugFrame {
+	frames := make([]DebugFrame, len(s.frames))
+	for i, f := range s.frames {
+		frames[i] = DebugFrame{
+			Package:  f.Func.Pkg,
+			Function: f.Func.Name,
+			Path:     f.Path,
+			Position: Position{Line: f.Position.Line, Column: f.Position.Column, Start: f.Position.Start, End: f.Position.End},
+		}
+	}
+	return frames
+}
+
+// Locals returns the local variables in scope in the frame with index frame,
+// as returned by the Frames method. It panics if frame is out of range.
+func (s *DebugState) Locals(frame int) []DebugVariable {
+	locals, values := s.state.Locals(s.frames[frame])
+	vars := make([]DebugVariable, len(locals))
+	for i, local := range locals {
+		vars[i] = newDebugVariable(local.Name, local.Type, values[i])
+	}
+	return vars
+}
+
+// Globals returns the package level variables, of programs, and the global
+// variables, of templates. Variables of packages other than the main package
+// have the name qualified by the package name, for example "pkg.V".
+func (s *DebugState) Globals() []DebugVariable {
+	values := s.state.Globals()
+	vars := make([]DebugVariable, 0, len(s.globals))
+	for i, global := range s.globals {
+		if global.Type.Kind() == reflect.Func {
+			continue
+		}
+		name := global.Name
+		if global.Pkg != "" && global.Pkg != "main" {
+			name = global.Pkg + "." + name
+		}
+		vars = append(vars, newDebugVariable(name, global.Type, values[i]))
+	}
+	return vars
+}
+
+// Lookup looks up a variable with the given name, first in the local
+// variables of the frame with index frame, as returned by the Frames method,
+// and then in the global variables, as returned by the Globals method. If the
+// variable exists it returns the variable and true, otherwise it returns
+// false. It panics if frame is out of range.
+func (s *DebugState) Lookup(frame int, name string) (DebugVariable, bool) {
+	for _, v := range s.Locals(frame) {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	for _, v := range s.Globals() {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return DebugVariable{}, false
+}
+
+// newDebugVariable returns a new debug variable with the given name, type and
+// value.
+func newDebugVariable(name string, typ reflect.Type, value reflect.Value) DebugVariable {
+	v := DebugVariable{Name: name, Type: typ.String()}
+	if value.IsValid() && value.CanInterface() {
+		v.Value = value.Interface()
+	}
+	return v
+}
+
+// debugger adapts a Debugger to the runtime.Debugger interface.
+type debugger struct {
+	Debugger
+	globals []compiler.Global
+}
+
+// newDebugger returns a runtime debugger that calls d.
+func newDebugger(d Debugger, globals []compiler.Global) runtime.Debugger {
+	return debugger{Debugger: d, globals: globals}
+}
+
+// Stop implements the Stop method of the runtime.Debugger interface.
+func (d debugger) Stop(state *runtime.DebugState) runtime.StepMode {
+	s := &DebugState{state: state, frames: state.Frames(), globals: d.globals}
+	return runtime.StepMode(d.Debugger.Stop(s))
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scriggo
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/open2b/scriggo/internal/fstest"
+)
+
+// testDebugger is a debugger that stops at the lines in breakpoints, records
+// the stops and resumes the execution with the step modes in steps.
+type testDebugger struct {
+	breakpoints map[int]bool
+	steps       []StepMode
+	stops       []string
+	state       func(s *DebugState)
+}
+
+func (d *testDebugger) Breakpoint(path string, line int) bool {
+	return d.breakpoints[line]
+}
+
+func (d *testDebugger) Stop(s *DebugState) StepMode {
+	frames := s.Frames()
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s:%d %s", frames[0].Path, frames[0].Position.Line, frames[0].Function)
+	for _, v := range s.Locals(0) {
+		fmt.Fprintf(&b, " %s=%v", v.Name, v.Value)
+	}
+	d.stops = append(d.stops, b.String())
+	if d.state != nil {
+		d.state(s)
+	}
+	if len(d.steps) == 0 {
+		return StepContinue
+	}
+	step := d.steps[0]
+	d.steps = d.steps[1:]
+	return step
+}
+
+var debugProgramFiles = fstest.Files{
+	"go.mod": "module example.com/main",
+	"main.go": `package main
+
+var G = 5
+
+func inc(n int) int {
+	r := n + 1
+	return r
+}
+
+func main() {
+	a := 1
+	s := "x"
+	for i := 0; i < 2; i++ {
+		a = inc(a)
+	}
+	f := func() { a++ }
+	f()
+	print(a, s, G)
+}`,
+}
+
+func TestDebuggerSteps(t *testing.T) {
+	tests := []struct {
+		breakpoints map[int]bool
+		steps       []StepMode
+		stops       []string
+	}{
+		{
+			breakpoints: map[int]bool{6: true},
+			stops:       []string{"main.go:6 inc n=1", "main.go:6 inc n=2"},
+		},
+		{
+			breakpoints: map[int]bool{11: true},
+			steps:       []StepMode{StepOver, StepOver, StepOver, StepOver},
+			stops: []string{
+				"main.go:11 main",
+				"main.go:12 main a=1",
+				"main.go:13 main a=1 s=x",
+				"main.go:14 main a=1 s=x i=0",
+				"main.go:13 main a=2 s=x i=0",
+			},
+		},
+		{
+			breakpoints: map[int]bool{14: true},
+			steps:       []StepMode{StepIn, StepIn, StepOut, StepContinue},
+			stops: []string{
+				"main.go:14 main a=1 s=x i=0",
+				"main.go:6 inc n=1",
+				"main.go:7 inc n=1 r=2",
+				"main.go:13 main a=2 s=x i=0",
+				"main.go:14 main a=2 s=x i=1",
+			},
+		},
+		{
+			breakpoints: map[int]bool{17: true},
+			steps:       []StepMode{StepIn, StepOver},
+			stops: []string{
+				"main.go:17 main a=3 s=x f=<nil>",
+				"main.go:16 ",
+				"main.go:18 main a=4 s=x f=<nil>",
+			},
+		},
+	}
+	program, err := Build(debugProgramFiles, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		d := &testDebugger{breakpoints: test.breakpoints, steps: test.steps}
+		err = program.Run(&RunOptions{Debugger: d, Print: func(interface{}) {}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(d.stops) != len(test.stops) {
+			t.Fatalf("expected stops %q, got %q", test.stops, d.stops)
+		}
+		for i, stop := range test.stops {
+			if d.stops[i] != stop {
+				t.Fatalf("expected stop %q, got %q", stop, d.stops[i])
+			}
+		}
+	}
+}
+
+func TestDebuggerState(t *testing.T) {
+	files := fstest.Files{
+		"index.html": "{% import \"imp.html\" %}\n{% x := 1 %}{% macro M(s string) %}\n{{ s }}\n{% end %}\n{{ M(\"a\") }}",
+		"imp.html":   "{% var Y = 3 %}",
+	}
+	template, err := BuildTemplate(files, "index.html", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var checked bool
+	d := &testDebugger{breakpoints: map[int]bool{3: true}}
+	d.state = func(s *DebugState) {
+		checked = true
+		if s.Breakpoint() != true {
+			t.Fatal("expected breakpoint, got false")
+		}
+		frames := s.Frames()
+		if len(frames) != 2 {
+			t.Fatalf("expected 2 frames, got %d", len(frames))
+		}
+		if frames[1].Path != "index.html" || frames[1].Position.Line != 5 {
+			t.Fatalf("unexpected caller frame %s:%s", frames[1].Path, frames[1].Position)
+		}
+		tests := []struct {
+			frame int
+			name  string
+			value interface{}
+			ok    bool
+		}{
+			{0, "s", "a", true},
+			{0, "x", nil, false},
+			{0, "Y", 3, true},
+			{1, "x", 1, true},
+			{1, "s", nil, false},
+		}
+		for _, test := range tests {
+			v, ok := s.Lookup(test.frame, test.name)
+			if ok != test.ok {
+				t.Fatalf("%s: expected %t, got %t", test.name, test.ok, ok)
+			}
+			if v.Value != test.value {
+				t.Fatalf("%s: expected value %v, got %v", test.name, test.value, v.Value)
+			}
+		}
+	}
+	err = template.Run(io.Discard, nil, &RunOptions{Debugger: d})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checked {
+		t.Fatal("expected a stop at line 3")
+	}
+}
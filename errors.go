@@ -122,3 +122,32 @@ func (p *PanicError) Position() Position {
 	pos := p.p.Position()
 	return Position{Line: pos.Line, Column: pos.Column, Start: pos.Start, End: pos.End}
 }
+
+// LimitError represents the error that occurs when an executed program or
+// template exceeds one of the limits set in the run options.
+type LimitError struct {
+	err *runtime.LimitError
+}
+
+// Error returns a string representation of the error.
+func (err *LimitError) Error() string {
+	return err.err.Error()
+}
+
+// Limit returns the name of the exceeded limit: "instructions", "call depth",
+// "allocated bytes" or "output bytes".
+func (err *LimitError) Limit() string {
+	return err.err.Limit()
+}
+
+// Path returns the path of the file where the limit has been exceeded.
+func (err *LimitError) Path() string {
+	return err.err.Path()
+}
+
+// Position returns the position in the file where the limit has been
+// exceeded.
+func (err *LimitError) Position() Position {
+	pos := err.err.Position()
+	return Position{Line: pos.Line, Column: pos.Column, Start: pos.Start, End: pos.End}
+}
@@ -0,0 +1,287 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package i18n
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/open2b/scriggo/ast"
+	"github.com/open2b/scriggo/ast/astutil"
+)
+
+// Func describes a translation function. Its fields are the indexes of the
+// parameters of the function with the context, the message and the plural
+// form of the message. An index is -1 if the function has no such parameter.
+type Func struct {
+	Context int
+	Msg     int
+	Plural  int
+}
+
+// DefaultFuncs are the translation functions of the builtin package.
+var DefaultFuncs = map[string]Func{
+	"translate":        {Context: -1, Msg: 0, Plural: -1},
+	"translateContext": {Context: 0, Msg: 1, Plural: -1},
+	"translatePlural":  {Context: -1, Msg: 0, Plural: 1},
+}
+
+// Message is a translatable message extracted from a tree.
+type Message struct {
+	Context   string      // context, empty if the message has no context.
+	ID        string      // message identifier.
+	Plural    string      // plural form, empty if the message has no plural.
+	Positions []Reference // positions in the source.
+}
+
+// Reference is the position of a message in the source.
+type Reference struct {
+	Path string // path of the file.
+	Line int    // line starting from 1.
+}
+
+// Extractor extracts the translatable messages from trees.
+type Extractor struct {
+	// Text reports whether the texts of the trees, trimmed of leading and
+	// trailing white space, are extracted as messages. Texts without
+	// letters, as white space and, in HTML, Markdown and XML files, markup
+	// only, are not extracted.
+	//
+	// The texts are not translated when a template is executed, only the
+	// messages passed to the translation functions are. The extracted texts
+	// can be used to translate the files themselves, for example to write a
+	// copy of the template files for each language.
+	Text bool
+
+	funcs    map[string]Func
+	messages []*Message
+	index    map[messageKey]*Message
+	visited  map[string]bool
+}
+
+// NewExtractor returns a new extractor that extracts the messages passed,
+// as string literals, to the translation functions funcs. If funcs is nil,
+// DefaultFuncs is used.
+func NewExtractor(funcs map[string]Func) *Extractor {
+	if funcs == nil {
+		funcs = DefaultFuncs
+	}
+	return &Extractor{
+		funcs:   funcs,
+		index:   map[messageKey]*Message{},
+		visited: map[string]bool{},
+	}
+}
+
+// Extract extracts the messages from tree and from the trees of the files it
+// extends, imports and renders, as expanded by the parser. Every file is
+// visited only once, also by subsequent calls to Extract.
+func (ex *Extractor) Extract(tree *ast.Tree) {
+	if ex.visited[tree.Path] {
+		return
+	}
+	ex.visited[tree.Path] = true
+	var trees []*ast.Tree
+	astutil.Inspect(tree, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Text:
+			if ex.Text {
+				text := string(n.Text[:len(n.Text)-n.Cut.Right])
+				trimmed := strings.TrimLeftFunc(text[n.Cut.Left:], unicode.IsSpace)
+				if msg := strings.TrimRightFunc(trimmed, unicode.IsSpace); hasLetters(msg, tree.Format) {
+					// The line of the first non-space character.
+					line := n.Pos().Line + strings.Count(text[:len(text)-len(trimmed)], "\n")
+					ex.Add("", msg, "", Reference{Path: tree.Path, Line: line})
+				}
+			}
+		case *ast.Extends:
+			trees = append(trees, n.Tree)
+		case *ast.Import:
+			trees = append(trees, n.Tree)
+		case *ast.Render:
+			trees = append(trees, n.Tree)
+		case *ast.Call:
+			ex.extractCall(tree.Path, n)
+		}
+		return true
+	})
+	for _, t := range trees {
+		if t != nil {
+			ex.Extract(t)
+		}
+	}
+}
+
+// hasLetters reports whether the text s, of a file with the given format,
+// contains letters. The letters in the markup of HTML, Markdown and XML
+// files are not considered.
+func hasLetters(s string, format ast.Format) bool {
+	markup := format == ast.FormatHTML || format == ast.FormatMarkdown || format == ast.FormatXML
+	inTag := false
+	for _, r := range s {
+		switch {
+		case markup && r == '<':
+			inTag = true
+		case markup && r == '>':
+			inTag = false
+		case !inTag && unicode.IsLetter(r):
+			return true
+		}
+	}
+	return false
+}
+
+// extractCall extracts the message of call, if it is a call to a translation
+// function with string literal arguments. path is the path of the file of
+// call.
+func (ex *Extractor) extractCall(path string, call *ast.Call) {
+	ident, ok := call.Func.(*ast.Identifier)
+	if !ok {
+		return
+	}
+	fn, ok := ex.funcs[ident.Name]
+	if !ok {
+		return
+	}
+	var ctx, plural string
+	id, ok := stringArg(call, fn.Msg)
+	if ok && fn.Context >= 0 {
+		ctx, ok = stringArg(call, fn.Context)
+	}
+	if ok && fn.Plural >= 0 {
+		plural, ok = stringArg(call, fn.Plural)
+	}
+	if ok {
+		ex.Add(ctx, id, plural, Reference{Path: path, Line: call.Pos().Line})
+	}
+}
+
+// Add adds a message with the given context, identifier and plural form,
+// referenced in the source at position pos. If the message has already been
+// added, pos is added to its positions.
+func (ex *Extractor) Add(ctx, id, plural string, pos Reference) {
+	if id == "" {
+		return
+	}
+	key := messageKey{ctx, id}
+	msg, ok := ex.index[key]
+	if !ok {
+		msg = &Message{Context: ctx, ID: id, Plural: plural}
+		ex.index[key] = msg
+		ex.messages = append(ex.messages, msg)
+	} else if msg.Plural == "" {
+		msg.Plural = plural
+	}
+	for _, p := range msg.Positions {
+		if p == pos {
+			return
+		}
+	}
+	msg.Positions = append(msg.Positions, pos)
+}
+
+// Messages returns the extracted messages in order of first appearance.
+func (ex *Extractor) Messages() []*Message {
+	return ex.messages
+}
+
+// stringArg returns the value of the argument with index i of call, if it is
+// a string literal.
+func stringArg(call *ast.Call, i int) (string, bool) {
+	if i < 0 || i >= len(call.Args) {
+		return "", false
+	}
+	lit, ok := call.Args[i].(*ast.BasicLiteral)
+	if !ok || lit.Type != ast.StringLiteral {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// potHeader is the header of a POT file.
+const potHeader = `msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=UTF-8\n"
+"Content-Transfer-Encoding: 8bit\n"
+`
+
+// WritePOT writes messages to w as a gettext POT file, the template from
+// which the PO files of the translations are created.
+func WritePOT(w io.Writer, messages []*Message) error {
+	b := bufio.NewWriter(w)
+	_, _ = b.WriteString(potHeader)
+	for _, msg := range messages {
+		_ = b.WriteByte('\n')
+		if len(msg.Positions) > 0 {
+			_, _ = b.WriteString("#:")
+			for _, pos := range msg.Positions {
+				_, _ = b.WriteString(" " + pos.Path + ":" + strconv.Itoa(pos.Line))
+			}
+			_ = b.WriteByte('\n')
+		}
+		if msg.Context != "" {
+			writePOString(b, "msgctxt", msg.Context)
+		}
+		writePOString(b, "msgid", msg.ID)
+		if msg.Plural == "" {
+			_, _ = b.WriteString("msgstr \"\"\n")
+			continue
+		}
+		writePOString(b, "msgid_plural", msg.Plural)
+		_, _ = b.WriteString("msgstr[0] \"\"\nmsgstr[1] \"\"\n")
+	}
+	return b.Flush()
+}
+
+// writePOString writes the keyword and the quoted string s to b. If s
+// contains new lines, each line is written quoted on a separate line.
+func writePOString(b *bufio.Writer, keyword, s string) {
+	_, _ = b.WriteString(keyword)
+	_ = b.WriteByte(' ')
+	i := strings.IndexByte(s, '\n')
+	if i < 0 || i == len(s)-1 {
+		writePOQuoted(b, s)
+		_ = b.WriteByte('\n')
+		return
+	}
+	_, _ = b.WriteString("\"\"\n")
+	for s != "" {
+		i := strings.IndexByte(s, '\n') + 1
+		if i == 0 {
+			i = len(s)
+		}
+		writePOQuoted(b, s[:i])
+		_ = b.WriteByte('\n')
+		s = s[i:]
+	}
+}
+
+// writePOQuoted writes s to b as a quoted string of a PO file.
+func writePOQuoted(b *bufio.Writer, s string) {
+	_ = b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			_ = b.WriteByte('\\')
+			_ = b.WriteByte(c)
+		case '\n':
+			_, _ = b.WriteString(`\n`)
+		case '\r':
+			_, _ = b.WriteString(`\r`)
+		case '\t':
+			_, _ = b.WriteString(`\t`)
+		default:
+			_ = b.WriteByte(c)
+		}
+	}
+	_ = b.WriteByte('"')
+}
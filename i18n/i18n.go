@@ -0,0 +1,342 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package i18n provides message catalogs, read from gettext PO files, to
+// translate the messages of templates.
+//
+// For example, to render a template in Italian, with the messages translated
+// by the catalog in the file "it.po"
+//
+//    f, err := os.Open("it.po")
+//    if err != nil {
+//        log.Fatal(err)
+//    }
+//    catalog, err := i18n.ParsePO(f)
+//    f.Close()
+//    if err != nil {
+//        log.Fatal(err)
+//    }
+//    opts := &scriggo.RunOptions{
+//        Locale:  "it-IT",
+//        Catalog: catalog,
+//    }
+//    err = template.Run(w, nil, opts)
+//
+// The messages are translated in templates with the translation builtins of
+// the builtin package
+//
+//    {{ translate("Hello") }}
+//    {{ translatePlural("%d apple", "%d apples", n) }}
+//    {{ translateContext("month", "May") }}
+//
+package i18n
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Catalog is a message catalog read from a gettext PO file. It implements
+// the native.Catalog interface and it is safe for concurrent use.
+type Catalog struct {
+	nplurals int
+	plural   pluralExpr
+	messages map[messageKey][]string
+}
+
+// messageKey is the key of a message in a catalog.
+type messageKey struct {
+	ctx string // context.
+	id  string // message identifier.
+}
+
+// Translate returns the translation of the message msg in the context ctx,
+// that is empty for messages without a context. If plural is not empty, msg
+// and plural are the singular and the plural form of the message and n
+// selects the plural form of the translation, as defined by the Plural-Forms
+// header of the PO file.
+//
+// If the message has no translation, Translate returns msg, or plural if it
+// is not empty and n is not 1.
+func (c *Catalog) Translate(ctx, msg, plural string, n int) string {
+	if tr, ok := c.messages[messageKey{ctx, msg}]; ok {
+		i := 0
+		if plural != "" {
+			i = c.plural.eval(n)
+		}
+		if 0 <= i && i < len(tr) && tr[i] != "" {
+			return tr[i]
+		}
+	}
+	if plural != "" && n != 1 {
+		return plural
+	}
+	return msg
+}
+
+// A SyntaxError is returned by ParsePO when the PO file is not valid.
+type SyntaxError struct {
+	Line int    // line of the error.
+	Msg  string // description of the error.
+}
+
+func (err *SyntaxError) Error() string {
+	return "i18n: syntax error at line " + strconv.Itoa(err.Line) + ": " + err.Msg
+}
+
+// ParsePO parses a gettext PO file read from r and returns its catalog.
+// Fuzzy and obsolete entries, and entries without translation, are ignored.
+//
+// The plural forms of the translations are selected with the expression of
+// the Plural-Forms header of the file. If there is no Plural-Forms header,
+// the expression "n != 1" is used.
+//
+// If the file is not valid, ParsePO returns a *SyntaxError.
+func ParsePO(r io.Reader) (*Catalog, error) {
+
+	c := &Catalog{
+		nplurals: 2,
+		plural:   defaultPluralExpr,
+		messages: map[messageKey][]string{},
+	}
+
+	var e poEntry
+	var last *string // last string, to which the continuation lines are appended.
+	var lastKeyword string
+
+	add := func() error {
+		defer func() { e = poEntry{}; last = nil; lastKeyword = "" }()
+		if !e.hasID {
+			if e.hasCtx || e.hasPlural || len(e.msgstr) > 0 {
+				return errors.New("missing msgid")
+			}
+			return nil
+		}
+		if len(e.msgstr) == 0 {
+			return errors.New("missing msgstr")
+		}
+		if e.fuzzy && e.id != "" {
+			return nil
+		}
+		if e.id == "" && e.ctx == "" {
+			return c.parseHeader(e.msgstr[0])
+		}
+		translated := false
+		for _, s := range e.msgstr {
+			if s != "" {
+				translated = true
+				break
+			}
+		}
+		if translated {
+			c.messages[messageKey{e.ctx, e.id}] = e.msgstr
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" {
+			if err := add(); err != nil {
+				return nil, &SyntaxError{line, err.Error()}
+			}
+			continue
+		}
+		if s[0] == '#' {
+			if strings.HasPrefix(s, "#~") {
+				// Obsolete entry.
+				continue
+			}
+			if strings.HasPrefix(s, "#,") {
+				for _, flag := range strings.Split(s[2:], ",") {
+					if strings.TrimSpace(flag) == "fuzzy" {
+						e.fuzzy = true
+					}
+				}
+			}
+			continue
+		}
+		if s[0] == '"' {
+			if last == nil {
+				return nil, &SyntaxError{line, "unexpected string"}
+			}
+			str, err := unquote(s)
+			if err != nil {
+				return nil, &SyntaxError{line, err.Error()}
+			}
+			*last += str
+			continue
+		}
+		i := strings.IndexAny(s, " \t")
+		if i < 0 {
+			return nil, &SyntaxError{line, "missing string after " + s}
+		}
+		keyword := s[:i]
+		str, err := unquote(strings.TrimSpace(s[i:]))
+		if err != nil {
+			return nil, &SyntaxError{line, err.Error()}
+		}
+		// An entry ends when a new entry starts without a blank line.
+		if (keyword == "msgctxt" || keyword == "msgid") && len(e.msgstr) > 0 {
+			if err := add(); err != nil {
+				return nil, &SyntaxError{line, err.Error()}
+			}
+		}
+		switch {
+		case keyword == "msgctxt":
+			if e.hasCtx || e.hasID {
+				return nil, &SyntaxError{line, "unexpected msgctxt"}
+			}
+			e.ctx, e.hasCtx = str, true
+			last = &e.ctx
+		case keyword == "msgid":
+			if e.hasID {
+				return nil, &SyntaxError{line, "unexpected msgid"}
+			}
+			e.id, e.hasID = str, true
+			last = &e.id
+		case keyword == "msgid_plural":
+			if !e.hasID || e.hasPlural || len(e.msgstr) > 0 {
+				return nil, &SyntaxError{line, "unexpected msgid_plural"}
+			}
+			e.plural, e.hasPlural = str, true
+			last = &e.plural
+		case keyword == "msgstr":
+			if !e.hasID || e.hasPlural || len(e.msgstr) > 0 {
+				return nil, &SyntaxError{line, "unexpected msgstr"}
+			}
+			e.msgstr = append(e.msgstr, str)
+			last = &e.msgstr[0]
+		case strings.HasPrefix(keyword, "msgstr["):
+			n, err := strconv.Atoi(strings.TrimSuffix(keyword[7:], "]"))
+			if err != nil || !strings.HasSuffix(keyword, "]") {
+				return nil, &SyntaxError{line, "invalid keyword " + keyword}
+			}
+			if !e.hasPlural || n != len(e.msgstr) || lastKeyword == "msgstr" {
+				return nil, &SyntaxError{line, "unexpected " + keyword}
+			}
+			e.msgstr = append(e.msgstr, str)
+			last = &e.msgstr[n]
+			keyword = "msgstr[]"
+		default:
+			return nil, &SyntaxError{line, "unknown keyword " + keyword}
+		}
+		lastKeyword = keyword
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := add(); err != nil {
+		return nil, &SyntaxError{line, err.Error()}
+	}
+
+	return c, nil
+}
+
+// poEntry is an entry of a PO file.
+type poEntry struct {
+	ctx       string
+	id        string
+	plural    string
+	msgstr    []string
+	hasCtx    bool
+	hasID     bool
+	hasPlural bool
+	fuzzy     bool
+}
+
+// parseHeader parses the header of a PO file.
+func (c *Catalog) parseHeader(header string) error {
+	for _, line := range strings.Split(header, "\n") {
+		i := strings.IndexByte(line, ':')
+		if i < 0 || !strings.EqualFold(strings.TrimSpace(line[:i]), "Plural-Forms") {
+			continue
+		}
+		var nplurals int
+		var plural string
+		for _, field := range strings.Split(line[i+1:], ";") {
+			j := strings.IndexByte(field, '=')
+			if j < 0 {
+				continue
+			}
+			switch strings.TrimSpace(field[:j]) {
+			case "nplurals":
+				n, err := strconv.Atoi(strings.TrimSpace(field[j+1:]))
+				if err != nil || n < 1 {
+					return fmt.Errorf("invalid nplurals in Plural-Forms header")
+				}
+				nplurals = n
+			case "plural":
+				plural = field[j+1:]
+			}
+		}
+		if nplurals == 0 || plural == "" {
+			return fmt.Errorf("invalid Plural-Forms header")
+		}
+		expr, err := parsePluralExpr(plural)
+		if err != nil {
+			return fmt.Errorf("invalid plural expression in Plural-Forms header: %s", err)
+		}
+		c.nplurals = nplurals
+		c.plural = expr
+	}
+	return nil
+}
+
+// unquote unquotes a quoted string of a PO file.
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", errors.New("invalid string " + s)
+	}
+	s = s[1 : len(s)-1]
+	if strings.IndexByte(s, '\\') < 0 {
+		if strings.IndexByte(s, '"') >= 0 {
+			return "", errors.New("unescaped quote in string")
+		}
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' {
+			return "", errors.New("unescaped quote in string")
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", errors.New("invalid escape at end of string")
+		}
+		switch s[i] {
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case '\\', '"', '\'', '?':
+			b.WriteByte(s[i])
+		default:
+			return "", fmt.Errorf("unknown escape sequence \\%c", s[i])
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,297 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package i18n
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/open2b/scriggo/ast"
+)
+
+const testPO = `# Italian translations.
+msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=UTF-8\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+#: index.html:3
+msgid "Hello"
+msgstr "Ciao"
+
+msgctxt "month"
+msgid "May"
+msgstr "Maggio"
+
+msgid "%d apple"
+msgid_plural "%d apples"
+msgstr[0] "%d mela"
+msgstr[1] "%d mele"
+
+msgid ""
+"multi "
+"line"
+msgstr "su "
+"più righe\t\"escaped\""
+
+#, fuzzy
+msgid "Fuzzy"
+msgstr "Sfocato"
+
+msgid "Untranslated"
+msgstr ""
+
+#~ msgid "Obsolete"
+#~ msgstr "Obsoleto"
+msgid "No blank line"
+msgstr "Nessuna riga vuota"
+msgid "Last"
+msgstr "Ultimo"`
+
+func TestParsePO(t *testing.T) {
+	c, err := ParsePO(strings.NewReader(testPO))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ctx, msg, plural string
+		n                int
+		expected         string
+	}{
+		{"", "Hello", "", 1, "Ciao"},
+		{"", "May", "", 1, "May"},
+		{"month", "May", "", 1, "Maggio"},
+		{"", "%d apple", "%d apples", 1, "%d mela"},
+		{"", "%d apple", "%d apples", 0, "%d mele"},
+		{"", "%d apple", "%d apples", 2, "%d mele"},
+		{"", "multi line", "", 1, "su più righe\t\"escaped\""},
+		{"", "Fuzzy", "", 1, "Fuzzy"},
+		{"", "Untranslated", "", 1, "Untranslated"},
+		{"", "Obsolete", "", 1, "Obsolete"},
+		{"", "No blank line", "", 1, "Nessuna riga vuota"},
+		{"", "Last", "", 1, "Ultimo"},
+		{"", "%d pear", "%d pears", 1, "%d pear"},
+		{"", "%d pear", "%d pears", 5, "%d pears"},
+	}
+	for _, test := range tests {
+		got := c.Translate(test.ctx, test.msg, test.plural, test.n)
+		if got != test.expected {
+			t.Errorf("ctx %q, msg %q, n %d: expecting %q, got %q", test.ctx, test.msg, test.n, test.expected, got)
+		}
+	}
+}
+
+func TestParsePOPluralForms(t *testing.T) {
+	po := `msgid ""
+msgstr "Plural-Forms: nplurals=3; plural=(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);\n"
+
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d plik"
+msgstr[1] "%d pliki"
+msgstr[2] "%d plików"
+`
+	c, err := ParsePO(strings.NewReader(po))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[int]string{1: "%d plik", 2: "%d pliki", 4: "%d pliki", 5: "%d plików",
+		12: "%d plików", 22: "%d pliki", 25: "%d plików", 0: "%d plików"}
+	for n, e := range expected {
+		if got := c.Translate("", "%d file", "%d files", n); got != e {
+			t.Errorf("n %d: expecting %q, got %q", n, e, got)
+		}
+	}
+}
+
+var parsePOErrorTests = []struct {
+	src string
+	err string
+}{
+	{`msgstr "a"`, "i18n: syntax error at line 1: unexpected msgstr"},
+	{"msgid \"a\"\n\n", "i18n: syntax error at line 2: missing msgstr"},
+	{"msgid \"a\"\nmsgid \"b\"", "i18n: syntax error at line 2: unexpected msgid"},
+	{"msgid \"a\"\nmsgstr[0] \"b\"", "i18n: syntax error at line 2: unexpected msgstr[0]"},
+	{"msgid \"a\"\nmsgid_plural \"b\"\nmsgstr[1] \"c\"", "i18n: syntax error at line 3: unexpected msgstr[1]"},
+	{"msgid \"a\"\nmsgstr \"b\\x\"", "i18n: syntax error at line 2: unknown escape sequence \\x"},
+	{"msgid \"a\"\nmsgstr \"b\"c\"", "i18n: syntax error at line 2: unescaped quote in string"},
+	{"msgid \"a\"\nmsgstr b", "i18n: syntax error at line 2: invalid string b"},
+	{"\"a\"", "i18n: syntax error at line 1: unexpected string"},
+	{"msgfoo \"a\"", "i18n: syntax error at line 1: unknown keyword msgfoo"},
+	{"msgid \"\"\nmsgstr \"Plural-Forms: nplurals=2; plural=n >;\"", "i18n: syntax error at line 2: invalid plural expression in Plural-Forms header: unexpected end of expression"},
+	{"msgid \"\"\nmsgstr \"Plural-Forms: nplurals=x; plural=n != 1;\"", "i18n: syntax error at line 2: invalid nplurals in Plural-Forms header"},
+}
+
+func TestParsePOErrors(t *testing.T) {
+	for _, test := range parsePOErrorTests {
+		_, err := ParsePO(strings.NewReader(test.src))
+		if err == nil {
+			t.Errorf("source %q: expecting error %q, got no error", test.src, test.err)
+			continue
+		}
+		if _, ok := err.(*SyntaxError); !ok {
+			t.Errorf("source %q: expecting a *SyntaxError, got %T", test.src, err)
+		}
+		if err.Error() != test.err {
+			t.Errorf("source %q: expecting error %q, got %q", test.src, test.err, err)
+		}
+	}
+}
+
+var pluralExprTests = []struct {
+	expr     string
+	values   []int
+	expected []int
+}{
+	{"0", []int{0, 1, 2}, []int{0, 0, 0}},
+	{"n != 1", []int{0, 1, 2}, []int{1, 0, 1}},
+	{"n > 1", []int{0, 1, 2}, []int{0, 0, 1}},
+	{"n%10==1 && n%100!=11 ? 0 : n != 0 ? 1 : 2", []int{0, 1, 2, 11, 21}, []int{2, 0, 1, 1, 0}},
+	{"(n==1) ? 0 : (n>=2 && n<=4) ? 1 : 2", []int{1, 2, 4, 5}, []int{0, 1, 1, 2}},
+	{"!(n - 1)", []int{0, 1, 2}, []int{0, 1, 0}},
+	{"n * 2 + 1 / 1 - n", []int{0, 3}, []int{1, 4}},
+	{"n / 0 + n % 0", []int{5}, []int{0}},
+	{"n == 0 || n == 1", []int{0, 1, 2}, []int{1, 1, 0}},
+}
+
+func TestPluralExpr(t *testing.T) {
+	for _, test := range pluralExprTests {
+		expr, err := parsePluralExpr(test.expr)
+		if err != nil {
+			t.Errorf("expression %q: unexpected error %q", test.expr, err)
+			continue
+		}
+		for i, n := range test.values {
+			if got := expr.eval(n); got != test.expected[i] {
+				t.Errorf("expression %q, n = %d: expecting %d, got %d", test.expr, n, test.expected[i], got)
+			}
+		}
+	}
+	for _, expr := range []string{"", "n ?", "n ? 1", "(n", "n )", "x", "n === 1"} {
+		if _, err := parsePluralExpr(expr); err == nil {
+			t.Errorf("expression %q: expecting error, got no error", expr)
+		}
+	}
+}
+
+func TestExtractor(t *testing.T) {
+	str := func(line int, s string) *ast.BasicLiteral {
+		return ast.NewBasicLiteral(&ast.Position{Line: line}, ast.StringLiteral, s)
+	}
+	call := func(line int, name string, args ...ast.Expression) *ast.Show {
+		pos := &ast.Position{Line: line}
+		c := ast.NewCall(pos, ast.NewIdentifier(pos, name), args, false)
+		return ast.NewShow(pos, []ast.Expression{c}, ast.ContextHTML)
+	}
+	tree := ast.NewTree("index.html", []ast.Node{
+		call(1, "translate", str(1, `"Hello"`)),
+		call(2, "translateContext", str(2, `"month"`), str(2, "`May`")),
+		call(3, "translatePlural", str(3, `"%d apple"`), str(3, `"%d apples"`), ast.NewIdentifier(&ast.Position{Line: 3}, "n")),
+		call(4, "translate", ast.NewIdentifier(&ast.Position{Line: 4}, "s")),
+		call(5, "sprint", str(5, `"Not translatable"`)),
+		call(6, "translate", str(6, `"Hello"`)),
+	}, ast.FormatHTML)
+	ex := NewExtractor(nil)
+	ex.Extract(tree)
+	ex.Extract(tree)
+	ex.Add("", "Hello", "", Reference{Path: "other.html", Line: 10})
+	expected := []*Message{
+		{ID: "Hello", Positions: []Reference{{"index.html", 1}, {"index.html", 6}, {"other.html", 10}}},
+		{Context: "month", ID: "May", Positions: []Reference{{"index.html", 2}}},
+		{ID: "%d apple", Plural: "%d apples", Positions: []Reference{{"index.html", 3}}},
+	}
+	if got := ex.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected messages")
+		for _, m := range got {
+			t.Logf("got %#v", m)
+		}
+	}
+}
+
+func TestWritePOT(t *testing.T) {
+	messages := []*Message{
+		{ID: "Hello", Positions: []Reference{{"index.html", 1}, {"partial.html", 3}}},
+		{Context: "month", ID: "May", Positions: []Reference{{"index.html", 2}}},
+		{ID: "%d apple", Plural: "%d apples"},
+		{ID: "first line\nsecond \"line\"\n"},
+		{ID: "one line\n"},
+	}
+	expected := potHeader + `
+#: index.html:1 partial.html:3
+msgid "Hello"
+msgstr ""
+
+#: index.html:2
+msgctxt "month"
+msgid "May"
+msgstr ""
+
+msgid "%d apple"
+msgid_plural "%d apples"
+msgstr[0] ""
+msgstr[1] ""
+
+msgid ""
+"first line\n"
+"second \"line\"\n"
+msgstr ""
+
+msgid "one line\n"
+msgstr ""
+`
+	var b strings.Builder
+	err := WritePOT(&b, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != expected {
+		t.Fatalf("unexpected POT file:\n%s\nexpecting:\n%s", got, expected)
+	}
+	if _, err := ParsePO(strings.NewReader(b.String())); err != nil {
+		t.Fatalf("cannot parse the POT file: %s", err)
+	}
+}
+
+func TestExtractorText(t *testing.T) {
+	text := func(line int, s string, cut ast.Cut) *ast.Text {
+		return ast.NewText(&ast.Position{Line: line}, []byte(s), cut)
+	}
+	partial := ast.NewTree("partial.html", []ast.Node{text(1, "Footer", ast.Cut{})}, ast.FormatHTML)
+	render := ast.NewRender(&ast.Position{Line: 4}, "partial.html")
+	render.Tree = partial
+	tree := ast.NewTree("index.html", []ast.Node{
+		text(1, "\n  <p>Hello</p>\n", ast.Cut{}),
+		text(3, " \n\t ", ast.Cut{}),
+		text(3, "<html>\n  <body class=\"main\">", ast.Cut{}),
+		text(3, "<p> 1, 2 </p>\n</html>\n", ast.Cut{}),
+		text(3, "x\n Hello \ny", ast.Cut{Left: 2, Right: 2}),
+		ast.NewShow(&ast.Position{Line: 4}, []ast.Expression{render}, ast.ContextHTML),
+		ast.NewShow(&ast.Position{Line: 5}, []ast.Expression{render}, ast.ContextHTML),
+	}, ast.FormatHTML)
+	ex := NewExtractor(nil)
+	ex.Text = true
+	ex.Extract(tree)
+	expected := []*Message{
+		{ID: "<p>Hello</p>", Positions: []Reference{{"index.html", 2}}},
+		{ID: "Hello", Positions: []Reference{{"index.html", 4}}},
+		{ID: "Footer", Positions: []Reference{{"partial.html", 1}}},
+	}
+	if got := ex.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected messages")
+		for _, m := range got {
+			t.Logf("got %#v", m)
+		}
+	}
+	tree = ast.NewTree("index.txt", []ast.Node{text(1, "<b>", ast.Cut{}), text(1, "1, 2", ast.Cut{})}, ast.FormatText)
+	ex.Extract(tree)
+	expected = append(expected, &Message{ID: "<b>", Positions: []Reference{{"index.txt", 1}}})
+	if got := ex.Messages(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected messages")
+		for _, m := range got {
+			t.Logf("got %#v", m)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package i18n
+
+import (
+	"errors"
+	"strconv"
+)
+
+// pluralExpr is a plural expression of the Plural-Forms header of a PO file,
+// as "n != 1" or "n==1 ? 0 : n%10>=2 && n%10<=4 ? 1 : 2". It has the syntax
+// and the semantic of a C expression with the only variable n.
+type pluralExpr interface {
+	eval(n int) int
+}
+
+// defaultPluralExpr is the plural expression "n != 1".
+var defaultPluralExpr pluralExpr = binaryExpr{op: "!=", x: varExpr{}, y: numberExpr(1)}
+
+type (
+	varExpr    struct{}
+	numberExpr int
+	notExpr    struct{ x pluralExpr }
+	binaryExpr struct {
+		op   string
+		x, y pluralExpr
+	}
+	condExpr struct{ cond, x, y pluralExpr }
+)
+
+func (varExpr) eval(n int) int {
+	return n
+}
+
+func (e numberExpr) eval(int) int {
+	return int(e)
+}
+
+func (e notExpr) eval(n int) int {
+	return boolToInt(e.x.eval(n) == 0)
+}
+
+func (e binaryExpr) eval(n int) int {
+	x := e.x.eval(n)
+	switch e.op {
+	case "||":
+		return boolToInt(x != 0 || e.y.eval(n) != 0)
+	case "&&":
+		return boolToInt(x != 0 && e.y.eval(n) != 0)
+	}
+	y := e.y.eval(n)
+	switch e.op {
+	case "==":
+		return boolToInt(x == y)
+	case "!=":
+		return boolToInt(x != y)
+	case "<":
+		return boolToInt(x < y)
+	case "<=":
+		return boolToInt(x <= y)
+	case ">":
+		return boolToInt(x > y)
+	case ">=":
+		return boolToInt(x >= y)
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	case "*":
+		return x * y
+	case "/", "%":
+		if y == 0 {
+			return 0
+		}
+		if e.op == "/" {
+			return x / y
+		}
+		return x % y
+	}
+	panic("unexpected operator " + e.op)
+}
+
+func (e condExpr) eval(n int) int {
+	if e.cond.eval(n) != 0 {
+		return e.x.eval(n)
+	}
+	return e.y.eval(n)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// binaryOperators contains the binary operators, by precedence from the
+// lowest to the highest.
+var binaryOperators = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// parsePluralExpr parses a plural expression.
+func parsePluralExpr(src string) (pluralExpr, error) {
+	p := &pluralParser{src: src}
+	p.next()
+	expr, err := p.parseCond()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != "" {
+		return nil, errors.New("unexpected " + p.tok)
+	}
+	return expr, nil
+}
+
+// pluralParser is a parser of plural expressions.
+type pluralParser struct {
+	src string
+	tok string // current token; empty at the end of the expression.
+}
+
+// next reads the next token.
+func (p *pluralParser) next() {
+	i := 0
+	for i < len(p.src) && (p.src[i] == ' ' || p.src[i] == '\t') {
+		i++
+	}
+	p.src = p.src[i:]
+	if p.src == "" {
+		p.tok = ""
+		return
+	}
+	n := 1
+	switch c := p.src[0]; {
+	case '0' <= c && c <= '9':
+		for n < len(p.src) && '0' <= p.src[n] && p.src[n] <= '9' {
+			n++
+		}
+	case c == '|' || c == '&':
+		if len(p.src) > 1 && p.src[1] == c {
+			n = 2
+		}
+	case c == '=' || c == '!' || c == '<' || c == '>':
+		if len(p.src) > 1 && p.src[1] == '=' {
+			n = 2
+		}
+	}
+	p.tok = p.src[:n]
+	p.src = p.src[n:]
+}
+
+// parseCond parses a conditional expression.
+func (p *pluralParser) parseCond() (pluralExpr, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil || p.tok != "?" {
+		return cond, err
+	}
+	p.next()
+	x, err := p.parseCond()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != ":" {
+		return nil, errors.New("missing : in conditional expression")
+	}
+	p.next()
+	y, err := p.parseCond()
+	if err != nil {
+		return nil, err
+	}
+	return condExpr{cond, x, y}, nil
+}
+
+// parseBinary parses a binary expression with operators with precedence
+// prec or higher.
+func (p *pluralParser) parseBinary(prec int) (pluralExpr, error) {
+	if prec == len(binaryOperators) {
+		return p.parseUnary()
+	}
+	x, err := p.parseBinary(prec + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.tok
+		found := false
+		for _, o := range binaryOperators[prec] {
+			if o == op {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return x, nil
+		}
+		p.next()
+		y, err := p.parseBinary(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = binaryExpr{op: op, x: x, y: y}
+	}
+}
+
+// parseUnary parses an unary expression.
+func (p *pluralParser) parseUnary() (pluralExpr, error) {
+	switch tok := p.tok; {
+	case tok == "":
+		return nil, errors.New("unexpected end of expression")
+	case tok == "n":
+		p.next()
+		return varExpr{}, nil
+	case tok == "!":
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{x}, nil
+	case tok == "(":
+		p.next()
+		x, err := p.parseCond()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok != ")" {
+			return nil, errors.New("missing )")
+		}
+		p.next()
+		return x, nil
+	case '0' <= tok[0] && tok[0] <= '9':
+		n, err := strconv.Atoi(tok)
+		if err != nil {
+			return nil, errors.New("invalid number " + tok)
+		}
+		p.next()
+		return numberExpr(n), nil
+	}
+	return nil, errors.New("unexpected " + p.tok)
+}
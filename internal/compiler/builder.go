@@ -15,22 +15,29 @@ import (
 )
 
 // Define some constants that define limits of the implementation.
+//
+// The indexes of native functions, Scriggo functions, field indexes, types,
+// string values and general values are uint16 values. They are stored in the
+// int16 operands of the instructions and the VM reads them as uint16 values.
 const (
 	// Functions.
-	maxRegistersCount        = 127
-	maxNativeFunctionsCount  = 256
-	maxScriggoFunctionsCount = 256
-	maxFieldIndexesCount     = 256
+	maxRegistersCount        = 1<<15 - 1 // 32767
+	maxNativeFunctionsCount  = 1 << 16   // 65536
+	maxScriggoFunctionsCount = 1 << 16   // 65536
+	maxFieldIndexesCount     = 1 << 16   // 65536
 	maxSelectCasesCount      = 65536
 
 	// Types.
-	maxTypesCount = 256
+	maxTypesCount = 1 << 16 // 65536
 
 	// Values.
 	maxIntValuesCount     = 1 << 14 // 16384
 	maxFloatValuesCount   = 1 << 14 // 16384
-	maxStringValuesCount  = 256
-	maxGeneralValuesCount = 256
+	maxStringValuesCount  = 1 << 16 // 65536
+	maxGeneralValuesCount = 1 << 16 // 65536
+
+	// Coverage.
+	maxCoverBlocksCount = 1 << 24 // 16777216
 )
 
 var intType = reflect.TypeOf(0)
@@ -58,7 +65,7 @@ func encodeRenderContext(ctx ast.Context, inURL, isURLSet bool) runtime.Context
 
 // decodeRenderContext decodes a runtime.Context.
 func decodeRenderContext(c runtime.Context) (ast.Context, bool, bool) {
-	ctx := ast.Context(c & 0b00001111)
+	ctx := ast.Context(c & 0b00111111)
 	inURL := c&0b10000000 != 0
 	isURLSet := false
 	if inURL {
@@ -67,48 +74,48 @@ func decodeRenderContext(c runtime.Context) (ast.Context, bool, bool) {
 	return ctx, inURL, isURLSet
 }
 
-func encodeInt16(v int16) (a, b int8) {
-	a = int8(v >> 8)
-	b = int8(v)
+func encodeInt16(v int16) (a, b int16) {
+	a = int16(int8(v >> 8))
+	b = int16(int8(v))
 	return
 }
 
-func decodeInt16(a, b int8) int16 {
+func decodeInt16(a, b int16) int16 {
 	return int16(int(a)<<8 | int(uint8(b)))
 }
 
-func encodeUint16(v uint16) (a, b int8) {
-	a = int8(uint8(v >> 8))
-	b = int8(uint8(v))
+func encodeUint16(v uint16) (a, b int16) {
+	a = int16(uint8(v >> 8))
+	b = int16(uint8(v))
 	return
 }
 
-func decodeUint16(a, b int8) uint16 {
+func decodeUint16(a, b int16) uint16 {
 	return uint16(uint8(a))<<8 | uint16(uint8(b))
 }
 
-func encodeUint24(v uint32) (a, b, c int8) {
-	a = int8(uint8(v >> 16))
-	b = int8(uint8(v >> 8))
-	c = int8(uint8(v))
+func encodeUint24(v uint32) (a, b, c int16) {
+	a = int16(uint8(v >> 16))
+	b = int16(uint8(v >> 8))
+	c = int16(uint8(v))
 	return
 }
 
-func decodeUint24(a, b, c int8) uint32 {
+func decodeUint24(a, b, c int16) uint32 {
 	return uint32(uint8(a))<<16 | uint32(uint8(b))<<8 | uint32(uint8(c))
 }
 
 // encodeValueIndex encodes a value index in the Function.Values slices of the
 // runtime.
-func encodeValueIndex(t registerType, i int) (a, b int8) {
+func encodeValueIndex(t registerType, i int) (a, b int16) {
 	a, b = encodeInt16(int16(i))
-	a |= int8(t << 6)
+	a |= int16(int8(t << 6))
 	return a, b
 }
 
 // decodeValueIndex decodes a value index in the Function.Values slices of the
 // runtime.
-func decodeValueIndex(a, b int8) (t registerType, i int) {
+func decodeValueIndex(a, b int16) (t registerType, i int) {
 	return registerType(uint8(a) >> 6), int(decodeUint16(a, b) &^ (3 << 14))
 }
 
@@ -166,18 +173,23 @@ type functionBuilder struct {
 	fn                     *runtime.Function
 	labelAddrs             []runtime.Addr // addresses of the labels; the address of the label n is labelAddrs[n-1]
 	gotos                  map[runtime.Addr]label
-	maxRegs                map[registerType]int8 // max number of registers allocated at the same time.
-	numRegs                map[registerType]int8
-	scopes                 []map[string]int8
+	maxRegs                map[registerType]int16 // max number of registers allocated at the same time.
+	numRegs                map[registerType]int16
+	scopes                 []map[string]int16
+	scopeLocals            []map[string]int // indexes in fn.Locals of the variables declared in the scopes.
 	scopeShifts            []runtime.StackShift
-	complexBinaryOpIndexes map[ast.OperatorType]int8 // indexes of complex binary op. functions.
-	complexUnaryOpIndex    int8                      // index of complex negation function.
+	complexBinaryOpIndexes map[ast.OperatorType]uint16 // indexes of complex binary op. functions.
+	complexUnaryOpIndex    int                         // index of complex negation function, -1 if not added.
+	typeIndexes            map[reflect.Type]int        // indexes of the types in fn.Types.
+	stringIndexes          map[string]uint16           // indexes of the values in fn.Values.String.
+	generalIndexes         map[interface{}]uint16      // indexes of the values in fn.Values.General.
 
 	// text refers to the latest emitted Text instruction with its text to be flushed into the function.
 	text struct {
 		addr  runtime.Addr
 		txt   [][]byte
 		inURL bool
+		nonce bool
 	}
 
 	// path of the current file. For example, when emitting a "render <path>"
@@ -193,11 +205,14 @@ func newBuilder(fn *runtime.Function, path string) *functionBuilder {
 	builder := &functionBuilder{
 		fn:                     fn,
 		gotos:                  map[runtime.Addr]label{},
-		maxRegs:                map[registerType]int8{},
-		numRegs:                map[registerType]int8{},
-		scopes:                 []map[string]int8{},
-		complexBinaryOpIndexes: map[ast.OperatorType]int8{},
+		maxRegs:                map[registerType]int16{},
+		numRegs:                map[registerType]int16{},
+		scopes:                 []map[string]int16{},
+		complexBinaryOpIndexes: map[ast.OperatorType]uint16{},
 		complexUnaryOpIndex:    -1,
+		typeIndexes:            map[reflect.Type]int{},
+		stringIndexes:          map[string]uint16{},
+		generalIndexes:         map[interface{}]uint16{},
 		path:                   path,
 	}
 	return builder
@@ -216,14 +231,21 @@ func (fb *functionBuilder) currentStackShift() runtime.StackShift {
 // enterScope enters a new scope.
 // Every enterScope call must be paired with a corresponding exitScope call.
 func (fb *functionBuilder) enterScope() {
-	fb.scopes = append(fb.scopes, map[string]int8{})
+	fb.scopes = append(fb.scopes, map[string]int16{})
+	fb.scopeLocals = append(fb.scopeLocals, nil)
 	fb.enterStack()
 }
 
 // exitScope exits last scope.
 // Every exitScope call must be paired with a corresponding enterScope call.
 func (fb *functionBuilder) exitScope() {
-	fb.scopes = fb.scopes[:len(fb.scopes)-1]
+	last := len(fb.scopes) - 1
+	pc := runtime.Addr(len(fb.fn.Body))
+	for _, i := range fb.scopeLocals[last] {
+		fb.fn.Locals[i].End = pc
+	}
+	fb.scopes = fb.scopes[:last]
+	fb.scopeLocals = fb.scopeLocals[:last]
 	fb.exitStack()
 }
 
@@ -235,13 +257,12 @@ func (fb *functionBuilder) exitScope() {
 //
 // Usage:
 //
-// 		e.fb.enterStack()
-// 		tmp := e.fb.newRegister(..)
-// 		// use tmp in some way
-// 		// move tmp content to externally-defined reg
-// 		e.fb.exitStack()
+//		e.fb.enterStack()
+//		tmp := e.fb.newRegister(..)
+//		// use tmp in some way
+//		// move tmp content to externally-defined reg
+//		e.fb.exitStack()
 //	    // tmp location is now available for reusing
-//
 func (fb *functionBuilder) enterStack() {
 	scopeShift := fb.currentStackShift()
 	fb.scopeShifts = append(fb.scopeShifts, scopeShift)
@@ -261,7 +282,7 @@ func (fb *functionBuilder) exitStack() {
 }
 
 // newRegister makes a new register of a given kind.
-func (fb *functionBuilder) newRegister(kind reflect.Kind) int8 {
+func (fb *functionBuilder) newRegister(kind reflect.Kind) int16 {
 	t := kindToType(kind)
 	num := fb.numRegs[t]
 	if num == maxRegistersCount {
@@ -272,20 +293,36 @@ func (fb *functionBuilder) newRegister(kind reflect.Kind) int8 {
 }
 
 // newIndirectRegister allocates a new indirect register.
-func (fb *functionBuilder) newIndirectRegister() int8 {
+func (fb *functionBuilder) newIndirectRegister() int16 {
 	return -fb.newRegister(reflect.Interface)
 }
 
 // bindVarReg binds name with register reg. To create a new variable, use
 // VariableRegister in conjunction with bindVarReg.
-func (fb *functionBuilder) bindVarReg(name string, reg int8) {
-	fb.scopes[len(fb.scopes)-1][name] = reg
+//
+// typ is the type of the variable and it is used to add the variable to the
+// locals of the function, so that it can be read by a debugger. If typ is nil
+// or name is an internal name, starting with '$', the variable is not added.
+func (fb *functionBuilder) bindVarReg(name string, reg int16, typ reflect.Type) {
+	last := len(fb.scopes) - 1
+	fb.scopes[last][name] = reg
+	if typ == nil || name[0] == '$' {
+		return
+	}
+	pc := runtime.Addr(len(fb.fn.Body))
+	if i, ok := fb.scopeLocals[last][name]; ok {
+		fb.fn.Locals[i].End = pc
+	} else if fb.scopeLocals[last] == nil {
+		fb.scopeLocals[last] = map[string]int{}
+	}
+	fb.scopeLocals[last][name] = len(fb.fn.Locals)
+	fb.fn.Locals = append(fb.fn.Locals, runtime.Local{Name: name, Type: typ, Reg: reg, Start: pc})
 }
 
 // declaredInCurrentScope returns the register where v is stored and true in
 // case of v is a variable declared in the current scope, else returns 0 and
 // false.
-func (fb *functionBuilder) declaredInCurrentScope(v string) (int8, bool) {
+func (fb *functionBuilder) declaredInCurrentScope(v string) (int16, bool) {
 	reg, ok := fb.scopes[len(fb.scopes)-1][v]
 	return reg, ok
 }
@@ -302,7 +339,7 @@ func (fb *functionBuilder) declaredInFunc(v string) bool {
 }
 
 // scopeLookup returns n's register.
-func (fb *functionBuilder) scopeLookup(n string) int8 {
+func (fb *functionBuilder) scopeLookup(n string) int16 {
 	for i := len(fb.scopes) - 1; i >= 0; i-- {
 		reg, ok := fb.scopes[i][n]
 		if ok {
@@ -331,6 +368,32 @@ func (fb *functionBuilder) addPosAndPath(pos *ast.Position) {
 	fb.fn.DebugInfo[pc] = debugInfo
 }
 
+// addStatement marks the instruction at address addr as the first instruction
+// of a statement at position pos. If the instruction has no position, pos
+// becomes its position. It is used by debuggers to stop before a statement.
+// If pos is nil, the instruction is not marked.
+func (fb *functionBuilder) addStatement(addr runtime.Addr, pos *ast.Position) {
+	if pos == nil {
+		return
+	}
+	if fb.fn.DebugInfo == nil {
+		fb.fn.DebugInfo = map[runtime.Addr]runtime.DebugInfo{}
+	}
+	debugInfo := fb.fn.DebugInfo[addr]
+	if debugInfo.Statement {
+		return
+	}
+	if debugInfo.Position.Line == 0 {
+		debugInfo.Position.Line = pos.Line
+		debugInfo.Position.Column = pos.Column
+		debugInfo.Position.Start = pos.Start
+		debugInfo.Position.End = pos.End
+		debugInfo.Path = fb.path
+	}
+	debugInfo.Statement = true
+	fb.fn.DebugInfo[addr] = debugInfo
+}
+
 // addOperandKinds adds the kind of the three operands of the next instruction.
 // If an operand has no kind (or if that kind is not meaningful) it is legal to
 // pass the zero of reflect.Kind for such operand.
@@ -386,55 +449,53 @@ func (fb *functionBuilder) addType(typ reflect.Type, preserveType bool) int {
 			typ = st.GoType()
 		}
 	}
-	fn := fb.fn
-	for i, t := range fn.Types {
-		if t == typ {
-			return i
-		}
+	if index, ok := fb.typeIndexes[typ]; ok {
+		return index
 	}
+	fn := fb.fn
 	index := len(fn.Types)
 	if index == maxTypesCount {
 		panic(newLimitExceededError(fb.fn.Pos, fb.path, "types count exceeded %d", maxTypesCount))
 	}
 	fn.Types = append(fn.Types, typ)
+	fb.typeIndexes[typ] = index
 	return index
 }
 
 // addNativeFunction adds a native function to the builder's function.
-func (fb *functionBuilder) addNativeFunction(f *runtime.NativeFunction) int8 {
+func (fb *functionBuilder) addNativeFunction(f *runtime.NativeFunction) uint16 {
 	fn := fb.fn
 	r := len(fn.NativeFunctions)
 	if r == maxNativeFunctionsCount {
 		panic(newLimitExceededError(fb.fn.Pos, fb.path, "native functions count exceeded %d", maxNativeFunctionsCount))
 	}
 	fn.NativeFunctions = append(fn.NativeFunctions, f)
-	return int8(r)
+	return uint16(r)
 }
 
 // addFunction adds a function to the builder's function.
-func (fb *functionBuilder) addFunction(f *runtime.Function) int8 {
+func (fb *functionBuilder) addFunction(f *runtime.Function) uint16 {
 	fn := fb.fn
 	r := len(fn.Functions)
 	if r == maxScriggoFunctionsCount {
 		panic(newLimitExceededError(fb.fn.Pos, fb.path, "Scriggo functions count exceeded %d", maxScriggoFunctionsCount))
 	}
 	fn.Functions = append(fn.Functions, f)
-	return int8(r)
+	return uint16(r)
 }
 
 // makeStringValue makes a new string value, returning it's index.
-func (fb *functionBuilder) makeStringValue(v string) int8 {
-	for i, vv := range fb.fn.Values.String {
-		if v == vv {
-			return int8(i)
-		}
+func (fb *functionBuilder) makeStringValue(v string) uint16 {
+	if index, ok := fb.stringIndexes[v]; ok {
+		return index
 	}
 	r := len(fb.fn.Values.String)
 	if r == maxStringValuesCount {
 		panic(newLimitExceededError(fb.fn.Pos, fb.path, "string values count exceeded %d", maxStringValuesCount))
 	}
 	fb.fn.Values.String = append(fb.fn.Values.String, v)
-	return int8(r)
+	fb.stringIndexes[v] = uint16(r)
+	return uint16(r)
 }
 
 // makeGeneralValue makes a new general value, returning it's index.
@@ -444,37 +505,34 @@ func (fb *functionBuilder) makeStringValue(v string) int8 {
 // If the VM's internal representation of v is different from the external, v
 // must always have the external representation. Any conversion, if needed,
 // will be internally handled.
-func (fb *functionBuilder) makeGeneralValue(v reflect.Value) int8 {
-	// Check if v has already been added to the general Values slice.
+func (fb *functionBuilder) makeGeneralValue(v reflect.Value) uint16 {
+	// Check if v has already been added to the general Values slice. Values
+	// with a non-comparable type, that are zero values, and the nil value
+	// are identified by their type.
+	var key interface{} = zeroValueKey{}
 	if v.IsValid() {
-		t := v.Type()
-		if t.Comparable() {
-			vi := v.Interface()
-			for i, vv := range fb.fn.Values.General {
-				if vv.IsValid() && vi == vv.Interface() {
-					return int8(i)
-				}
-			}
+		if t := v.Type(); t.Comparable() {
+			key = v.Interface()
 		} else {
-			for i, vv := range fb.fn.Values.General {
-				if vv.IsValid() && t == vv.Type() {
-					return int8(i)
-				}
-			}
-		}
-	} else {
-		for i, vv := range fb.fn.Values.General {
-			if !vv.IsValid() {
-				return int8(i)
-			}
+			key = zeroValueKey{t}
 		}
 	}
+	if index, ok := fb.generalIndexes[key]; ok {
+		return index
+	}
 	r := len(fb.fn.Values.General)
 	if r == maxGeneralValuesCount {
 		panic(newLimitExceededError(fb.fn.Pos, fb.path, "general values count exceeded %d", maxGeneralValuesCount))
 	}
 	fb.fn.Values.General = append(fb.fn.Values.General, v)
-	return int8(r)
+	fb.generalIndexes[key] = uint16(r)
+	return uint16(r)
+}
+
+// zeroValueKey is the key, in the generalIndexes map of a function builder,
+// of a zero value with a non-comparable type or of the nil value.
+type zeroValueKey struct {
+	typ reflect.Type
 }
 
 // makeFloatValue makes a new float value, returning it's index.
@@ -521,10 +579,10 @@ func sameFieldIndex(i1, i2 []int) bool {
 }
 
 // makeFieldIndex makes a new field index, returning it's index.
-func (fb *functionBuilder) makeFieldIndex(index []int) int8 {
+func (fb *functionBuilder) makeFieldIndex(index []int) uint16 {
 	for i, index2 := range fb.fn.FieldIndexes {
 		if sameFieldIndex(index, index2) {
-			return int8(i)
+			return uint16(i)
 		}
 	}
 	r := len(fb.fn.FieldIndexes)
@@ -532,7 +590,7 @@ func (fb *functionBuilder) makeFieldIndex(index []int) int8 {
 		panic(newLimitExceededError(fb.fn.Pos, fb.path, "field indexes count exceeded %d", maxFieldIndexesCount))
 	}
 	fb.fn.FieldIndexes = append(fb.fn.FieldIndexes, index)
-	return int8(r)
+	return uint16(r)
 }
 
 // currentAddr returns builder's current address.
@@ -580,6 +638,11 @@ func (fb *functionBuilder) end() {
 	if int64(len(fn.Body)) > math.MaxUint32 {
 		panic(newLimitExceededError(fn.Pos, fn.File, "instructions count exceeded %d", uint32(math.MaxUint32)))
 	}
+	for _, locals := range fb.scopeLocals {
+		for _, i := range locals {
+			fn.Locals[i].End = runtime.Addr(len(fn.Body))
+		}
+	}
 	for addr, label := range fb.gotos {
 		i := fn.Body[addr]
 		i.A, i.B, i.C = encodeUint24(uint32(fb.labelAddrs[label-1]))
@@ -593,7 +656,7 @@ func (fb *functionBuilder) end() {
 	}
 }
 
-func (fb *functionBuilder) allocRegister(typ registerType, reg int8) {
+func (fb *functionBuilder) allocRegister(typ registerType, reg int16) {
 	if max, ok := fb.maxRegs[typ]; !ok || reg > max {
 		fb.maxRegs[typ] = reg
 	}
@@ -604,14 +667,14 @@ func (fb *functionBuilder) allocRegister(typ registerType, reg int8) {
 
 // complexOperationIndex returns the index of the function which performs the
 // binary or unary operation specified by op.
-func (fb *functionBuilder) complexOperationIndex(op ast.OperatorType, unary bool) int8 {
+func (fb *functionBuilder) complexOperationIndex(op ast.OperatorType, unary bool) uint16 {
 	if unary {
 		if fb.complexUnaryOpIndex != -1 {
-			return fb.complexUnaryOpIndex
+			return uint16(fb.complexUnaryOpIndex)
 		}
 		fn := newNativeFunction("scriggo.complex", "neg", negComplex)
 		index := fb.addNativeFunction(fn)
-		fb.complexUnaryOpIndex = index
+		fb.complexUnaryOpIndex = int(index)
 		return index
 	}
 	if index, ok := fb.complexBinaryOpIndexes[op]; ok {
@@ -22,7 +22,7 @@ func (fb *functionBuilder) emitNop() {
 //
 //     z = x + y
 //
-func (fb *functionBuilder) emitAdd(k bool, x, y, z int8, kind reflect.Kind) {
+func (fb *functionBuilder) emitAdd(k bool, x, y, z int16, kind reflect.Kind) {
 	var op runtime.Operation
 	switch kind {
 	case reflect.Int:
@@ -33,7 +33,7 @@ func (fb *functionBuilder) emitAdd(k bool, x, y, z int8, kind reflect.Kind) {
 		if z != x {
 			panic(fmt.Errorf("z must be == x for kind %s", kind))
 		}
-		x = int8(flattenIntegerKind(kind))
+		x = int16(flattenIntegerKind(kind))
 		op = runtime.OpAdd
 	}
 	if k {
@@ -47,7 +47,7 @@ func (fb *functionBuilder) emitAdd(k bool, x, y, z int8, kind reflect.Kind) {
 // 	   dest = &expr.Field
 // 	   dest = &expr[index]
 //
-func (fb *functionBuilder) emitAddr(expr, index, dest int8, pos *ast.Position) {
+func (fb *functionBuilder) emitAddr(expr, index, dest int16, pos *ast.Position) {
 	fb.addPosAndPath(pos)
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpAddr, A: expr, B: index, C: dest})
 }
@@ -56,7 +56,7 @@ func (fb *functionBuilder) emitAddr(expr, index, dest int8, pos *ast.Position) {
 //
 //     z = x & y
 //
-func (fb *functionBuilder) emitAnd(k bool, x, y, z int8, kind reflect.Kind) {
+func (fb *functionBuilder) emitAnd(k bool, x, y, z int16, kind reflect.Kind) {
 	op := runtime.OpAnd
 	if k {
 		op = -op
@@ -68,7 +68,7 @@ func (fb *functionBuilder) emitAnd(k bool, x, y, z int8, kind reflect.Kind) {
 //
 //     z = x &^ y
 //
-func (fb *functionBuilder) emitAndNot(k bool, x, y, z int8, kind reflect.Kind) {
+func (fb *functionBuilder) emitAndNot(k bool, x, y, z int16, kind reflect.Kind) {
 	op := runtime.OpAndNot
 	if k {
 		op = -op
@@ -78,7 +78,7 @@ func (fb *functionBuilder) emitAndNot(k bool, x, y, z int8, kind reflect.Kind) {
 
 // emitAppend appends a new "Append" instruction to the function body.
 //
-func (fb *functionBuilder) emitAppend(start, end, s int8, elementsKind reflect.Kind) {
+func (fb *functionBuilder) emitAppend(start, end, s int16, elementsKind reflect.Kind) {
 	fb.addOperandKinds(elementsKind, elementsKind, 0)
 	fn := fb.fn
 	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpAppend, A: start, B: end, C: s})
@@ -88,7 +88,7 @@ func (fb *functionBuilder) emitAppend(start, end, s int8, elementsKind reflect.K
 //
 //     s = append(s, t)
 //
-func (fb *functionBuilder) emitAppendSlice(t, s int8, pos *ast.Position) {
+func (fb *functionBuilder) emitAppendSlice(t, s int16, pos *ast.Position) {
 	fb.addPosAndPath(pos)
 	fn := fb.fn
 	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpAppendSlice, A: t, C: s})
@@ -98,9 +98,9 @@ func (fb *functionBuilder) emitAppendSlice(t, s int8, pos *ast.Position) {
 //
 //     z = e.(t)
 //
-func (fb *functionBuilder) emitAssert(e int8, typ reflect.Type, z int8) {
+func (fb *functionBuilder) emitAssert(e int16, typ reflect.Type, z int16) {
 	t := fb.addType(typ, true)
-	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpAssert, A: e, B: int8(t), C: z})
+	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpAssert, A: e, B: int16(t), C: z})
 }
 
 // emitBreak appends a new "Break" instruction to the function body.
@@ -117,10 +117,10 @@ func (fb *functionBuilder) emitBreak(lab label) {
 //
 //     p.f()
 //
-func (fb *functionBuilder) emitCallFunc(f int8, shift runtime.StackShift, pos *ast.Position) {
+func (fb *functionBuilder) emitCallFunc(f uint16, shift runtime.StackShift, pos *ast.Position) {
 	fb.addPosAndPath(pos)
 	fn := fb.fn
-	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpCallFunc, A: f})
+	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpCallFunc, A: int16(f)})
 	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.Operation(shift[0]), A: shift[1], B: shift[2], C: shift[3]})
 }
 
@@ -128,10 +128,10 @@ func (fb *functionBuilder) emitCallFunc(f int8, shift runtime.StackShift, pos *a
 //
 //     p.m()
 //
-func (fb *functionBuilder) emitCallMacro(f int8, shift runtime.StackShift, pos *ast.Position, toFormat ast.Format) {
+func (fb *functionBuilder) emitCallMacro(f uint16, shift runtime.StackShift, pos *ast.Position, toFormat ast.Format) {
 	fb.addPosAndPath(pos)
 	fn := fb.fn
-	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpCallMacro, A: f, B: int8(toFormat)})
+	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpCallMacro, A: int16(f), B: int16(toFormat)})
 	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.Operation(shift[0]), A: shift[1], B: shift[2], C: shift[3]})
 }
 
@@ -139,11 +139,11 @@ func (fb *functionBuilder) emitCallMacro(f int8, shift runtime.StackShift, pos *
 //
 //     f()
 //
-func (fb *functionBuilder) emitCallIndirect(f int8, numVariadic int8, shift runtime.StackShift, pos *ast.Position, funcType reflect.Type, toFormat ast.Format) {
+func (fb *functionBuilder) emitCallIndirect(f int16, numVariadic int16, shift runtime.StackShift, pos *ast.Position, funcType reflect.Type, toFormat ast.Format) {
 	fb.addPosAndPath(pos)
 	fb.addFunctionType(funcType)
 	fn := fb.fn
-	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpCallIndirect, A: f, B: int8(toFormat), C: numVariadic})
+	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpCallIndirect, A: f, B: int16(toFormat), C: numVariadic})
 	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.Operation(shift[0]), A: shift[1], B: shift[2], C: shift[3]})
 }
 
@@ -151,10 +151,10 @@ func (fb *functionBuilder) emitCallIndirect(f int8, numVariadic int8, shift runt
 //
 //     p.F()
 //
-func (fb *functionBuilder) emitCallNative(f int8, numVariadic int8, shift runtime.StackShift, pos *ast.Position) {
+func (fb *functionBuilder) emitCallNative(f uint16, numVariadic int16, shift runtime.StackShift, pos *ast.Position) {
 	fb.addPosAndPath(pos)
 	fn := fb.fn
-	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpCallNative, A: f, C: numVariadic})
+	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpCallNative, A: int16(f), C: numVariadic})
 	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.Operation(shift[0]), A: shift[1], B: shift[2], C: shift[3]})
 }
 
@@ -162,7 +162,7 @@ func (fb *functionBuilder) emitCallNative(f int8, numVariadic int8, shift runtim
 //
 //     z = cap(s)
 //
-func (fb *functionBuilder) emitCap(s, z int8) {
+func (fb *functionBuilder) emitCap(s, z int16) {
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpCap, A: s, C: z})
 }
 
@@ -172,8 +172,8 @@ func (fb *functionBuilder) emitCap(s, z int8) {
 //     case value = <-ch
 //     default
 //
-func (fb *functionBuilder) emitCase(kvalue bool, dir reflect.SelectDir, value, ch int8) {
-	in := runtime.Instruction{Op: runtime.OpCase, A: int8(dir)}
+func (fb *functionBuilder) emitCase(kvalue bool, dir reflect.SelectDir, value, ch int16) {
+	in := runtime.Instruction{Op: runtime.OpCase, A: int16(dir)}
 	if kvalue {
 		in.Op = -in.Op
 	}
@@ -192,7 +192,7 @@ func (fb *functionBuilder) emitCase(kvalue bool, dir reflect.SelectDir, value, c
 //
 //     close(ch)
 //
-func (fb *functionBuilder) emitClose(ch int8, pos *ast.Position) {
+func (fb *functionBuilder) emitClose(ch int16, pos *ast.Position) {
 	fb.addPosAndPath(pos)
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpClose, A: ch})
 }
@@ -201,7 +201,7 @@ func (fb *functionBuilder) emitClose(ch int8, pos *ast.Position) {
 //
 //	z = complex(x, y)
 //
-func (fb *functionBuilder) emitComplex(x, y, z int8, kind reflect.Kind) {
+func (fb *functionBuilder) emitComplex(x, y, z int16, kind reflect.Kind) {
 	op := runtime.OpComplex128
 	if kind == reflect.Complex64 {
 		op = runtime.OpComplex64
@@ -213,7 +213,7 @@ func (fb *functionBuilder) emitComplex(x, y, z int8, kind reflect.Kind) {
 //
 //     z = concat(s, t)
 //
-func (fb *functionBuilder) emitConcat(s, t, z int8) {
+func (fb *functionBuilder) emitConcat(s, t, z int16) {
 	fn := fb.fn
 	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpConcat, A: s, B: t, C: z})
 }
@@ -232,7 +232,7 @@ func (fb *functionBuilder) emitContinue(lab label) {
 //
 // 	 dst = typ(src)
 //
-func (fb *functionBuilder) emitConvert(src int8, typ reflect.Type, dst int8, srcKind reflect.Kind) {
+func (fb *functionBuilder) emitConvert(src int16, typ reflect.Type, dst int16, srcKind reflect.Kind) {
 	fn := fb.fn
 	regType := fb.addType(typ, false)
 	var op runtime.Operation
@@ -256,7 +256,7 @@ func (fb *functionBuilder) emitConvert(src int8, typ reflect.Type, dst int8, src
 	case floatRegister:
 		op = runtime.OpConvertFloat
 	}
-	fn.Body = append(fn.Body, runtime.Instruction{Op: op, A: src, B: int8(regType), C: dst})
+	fn.Body = append(fn.Body, runtime.Instruction{Op: op, A: src, B: int16(regType), C: dst})
 }
 
 // emitCopy appends a new "Copy" instruction to the function body.
@@ -264,15 +264,28 @@ func (fb *functionBuilder) emitConvert(src int8, typ reflect.Type, dst int8, src
 //     n == 0:   copy(dst, src)
 // 	 n != 0:   n := copy(dst, src)
 //
-func (fb *functionBuilder) emitCopy(dst, src, n int8) {
+func (fb *functionBuilder) emitCopy(dst, src, n int16) {
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpCopy, A: src, B: n, C: dst})
 }
 
+// emitCover appends a new "Cover" instruction to the function body that
+// counts the executions of the coverage block with index i.
+//
+//     cover i
+//
+func (fb *functionBuilder) emitCover(i int) {
+	if i >= maxCoverBlocksCount {
+		panic(newLimitExceededError(fb.fn.Pos, fb.path, "coverage blocks count exceeded %d", maxCoverBlocksCount))
+	}
+	a, b, c := encodeUint24(uint32(i))
+	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpCover, A: a, B: b, C: c})
+}
+
 // emitDefer appends a new "Defer" instruction to the function body.
 //
 //     defer
 //
-func (fb *functionBuilder) emitDefer(f int8, numVariadic int8, off, arg runtime.StackShift, funcType reflect.Type) {
+func (fb *functionBuilder) emitDefer(f int16, numVariadic int16, off, arg runtime.StackShift, funcType reflect.Type) {
 	fb.addFunctionType(funcType)
 	fn := fb.fn
 	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpDefer, A: f, C: numVariadic})
@@ -284,7 +297,7 @@ func (fb *functionBuilder) emitDefer(f int8, numVariadic int8, off, arg runtime.
 //
 //     delete(m, k)
 //
-func (fb *functionBuilder) emitDelete(m, k int8) {
+func (fb *functionBuilder) emitDelete(m, k int16) {
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpDelete, A: m, B: k})
 }
 
@@ -292,7 +305,7 @@ func (fb *functionBuilder) emitDelete(m, k int8) {
 //
 //     z = x / y
 //
-func (fb *functionBuilder) emitDiv(ky bool, x, y, z int8, kind reflect.Kind, pos *ast.Position) {
+func (fb *functionBuilder) emitDiv(ky bool, x, y, z int16, kind reflect.Kind, pos *ast.Position) {
 	var op runtime.Operation
 	switch kind {
 	case reflect.Int:
@@ -303,7 +316,7 @@ func (fb *functionBuilder) emitDiv(ky bool, x, y, z int8, kind reflect.Kind, pos
 		if z != x {
 			panic(fmt.Errorf("z must be == x for kind %s", kind))
 		}
-		x = int8(flattenIntegerKind(kind))
+		x = int16(flattenIntegerKind(kind))
 		op = runtime.OpDiv
 	}
 	fb.addPosAndPath(pos)
@@ -317,16 +330,16 @@ func (fb *functionBuilder) emitDiv(ky bool, x, y, z int8, kind reflect.Kind, pos
 //
 //  c = a.field
 //
-func (fb *functionBuilder) emitField(a, field, c int8, dstKind reflect.Kind) {
+func (fb *functionBuilder) emitField(a int16, field uint16, c int16, dstKind reflect.Kind) {
 	fb.addOperandKinds(0, 0, dstKind)
-	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpField, A: a, B: field, C: c})
+	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpField, A: a, B: int16(field), C: c})
 }
 
 // emitGetVar appends a new "GetVar" instruction to the function body.
 //
 //     r = v
 //
-func (fb *functionBuilder) emitGetVar(v int, r int8, varKind reflect.Kind) {
+func (fb *functionBuilder) emitGetVar(v int, r int16, varKind reflect.Kind) {
 	a, b := encodeInt16(int16(v))
 	fb.addOperandKinds(0, 0, varKind)
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpGetVar, A: a, B: b, C: r})
@@ -336,7 +349,7 @@ func (fb *functionBuilder) emitGetVar(v int, r int8, varKind reflect.Kind) {
 //
 //	   r = &v
 //
-func (fb *functionBuilder) emitGetVarAddr(v int, r int8) {
+func (fb *functionBuilder) emitGetVarAddr(v int, r int16) {
 	a, b := encodeInt16(int16(v))
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpGetVarAddr, A: a, B: b, C: r})
 }
@@ -389,7 +402,7 @@ func (fb *functionBuilder) emitGoto(lab label) {
 //     len(x) >= y
 //     x contains y
 //
-func (fb *functionBuilder) emitIf(ky bool, x int8, o runtime.Condition, y int8, kind reflect.Kind, pos *ast.Position) {
+func (fb *functionBuilder) emitIf(ky bool, x int16, o runtime.Condition, y int16, kind reflect.Kind, pos *ast.Position) {
 	fb.addPosAndPath(pos)
 	var op runtime.Operation
 	switch kindToType(kind) {
@@ -405,7 +418,7 @@ func (fb *functionBuilder) emitIf(ky bool, x int8, o runtime.Condition, y int8,
 	if ky {
 		op = -op
 	}
-	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: op, A: x, B: int8(o), C: y})
+	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: op, A: x, B: int16(o), C: y})
 }
 
 // emitIndex appends a new "Index", "IndexRef", "MapIndex", or "IndexString"
@@ -418,7 +431,7 @@ func (fb *functionBuilder) emitIf(ky bool, x int8, o runtime.Condition, y int8,
 //
 // TODO: consider splitting emitIndex in two methods removing the 'ref bool'
 // argument.
-func (fb *functionBuilder) emitIndex(ki bool, expr, i, dst int8, t reflect.Type, pos *ast.Position, ref bool) {
+func (fb *functionBuilder) emitIndex(ki bool, expr, i, dst int16, t reflect.Type, pos *ast.Position, ref bool) {
 	fb.addPosAndPath(pos)
 	fn := fb.fn
 	kind := t.Kind()
@@ -453,36 +466,36 @@ func (fb *functionBuilder) emitIndex(ki bool, expr, i, dst int8, t reflect.Type,
 //
 //     l = len(s)
 //
-func (fb *functionBuilder) emitLen(s, l int8, t reflect.Type) {
+func (fb *functionBuilder) emitLen(s, l int16, t reflect.Type) {
 	a := stringRegister
 	if t.Kind() != reflect.String {
 		a = generalRegister
 	}
-	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpLen, A: int8(a), B: s, C: l})
+	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpLen, A: int16(a), B: s, C: l})
 }
 
 // emitLoadFunc appends a new "LoadFunc" instruction to the function body.
 //
 //     z = p.f
 //
-func (fb *functionBuilder) emitLoadFunc(native bool, f int8, z int8) {
+func (fb *functionBuilder) emitLoadFunc(native bool, f uint16, z int16) {
 	fn := fb.fn
-	var a int8
+	var a int16
 	if native {
 		a = 1
 	}
-	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpLoadFunc, A: a, B: f, C: z})
+	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpLoadFunc, A: a, B: int16(f), C: z})
 }
 
 // emitLoad appends a new "Load" instruction to the function body.
 //
-func (fb *functionBuilder) emitLoad(index int, dst int8, kind reflect.Kind) {
+func (fb *functionBuilder) emitLoad(index int, dst int16, kind reflect.Kind) {
 	a, b := encodeValueIndex(kindToType(kind), index)
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpLoad, A: a, B: b, C: dst})
 }
 
 // emitMakeArray appends a new "MakeArray" instruction to the function body.
-func (fb *functionBuilder) emitMakeArray(typ reflect.Type, dst int8) {
+func (fb *functionBuilder) emitMakeArray(typ reflect.Type, dst int16) {
 	if typ.Kind() != reflect.Array {
 		panic(internalError("%s is not an array type", typ))
 	}
@@ -491,14 +504,14 @@ func (fb *functionBuilder) emitMakeArray(typ reflect.Type, dst int8) {
 	// the other methods.
 	fn := fb.fn
 	b := fb.addType(typ, false)
-	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpMakeArray, B: int8(b), C: dst})
+	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpMakeArray, B: int16(b), C: dst})
 }
 
 // emitMakeChan appends a new "MakeChan" instruction to the function body.
 //
 //     dst = make(typ, capacity)
 //
-func (fb *functionBuilder) emitMakeChan(typ reflect.Type, kCapacity bool, capacity int8, dst int8, pos *ast.Position) {
+func (fb *functionBuilder) emitMakeChan(typ reflect.Type, kCapacity bool, capacity int16, dst int16, pos *ast.Position) {
 	fb.addPosAndPath(pos)
 	fn := fb.fn
 	t := fb.addType(typ, false)
@@ -506,32 +519,32 @@ func (fb *functionBuilder) emitMakeChan(typ reflect.Type, kCapacity bool, capaci
 	if kCapacity {
 		op = -op
 	}
-	fn.Body = append(fn.Body, runtime.Instruction{Op: op, A: int8(t), B: capacity, C: dst})
+	fn.Body = append(fn.Body, runtime.Instruction{Op: op, A: int16(t), B: capacity, C: dst})
 }
 
 // emitMakeMap appends a new "MakeMap" instruction to the function body.
 //
 //     dst = make(typ, size)
 //
-func (fb *functionBuilder) emitMakeMap(typ reflect.Type, kSize bool, size int8, dst int8) {
+func (fb *functionBuilder) emitMakeMap(typ reflect.Type, kSize bool, size int16, dst int16) {
 	fn := fb.fn
 	t := fb.addType(typ, false)
 	op := runtime.OpMakeMap
 	if kSize {
 		op = -op
 	}
-	fn.Body = append(fn.Body, runtime.Instruction{Op: op, A: int8(t), B: size, C: dst})
+	fn.Body = append(fn.Body, runtime.Instruction{Op: op, A: int16(t), B: size, C: dst})
 }
 
 // emitMakeSlice appends a new "MakeSlice" instruction to the function body.
 //
 //     make(sliceType, len, cap)
 //
-func (fb *functionBuilder) emitMakeSlice(kLen, kCap bool, sliceType reflect.Type, len, cap, dst int8, pos *ast.Position) {
+func (fb *functionBuilder) emitMakeSlice(kLen, kCap bool, sliceType reflect.Type, len, cap, dst int16, pos *ast.Position) {
 	fb.addPosAndPath(pos)
 	fn := fb.fn
 	t := fb.addType(sliceType, false)
-	var k int8
+	var k int16
 	if len == 0 && cap == 0 {
 		k = 0
 	} else {
@@ -543,14 +556,14 @@ func (fb *functionBuilder) emitMakeSlice(kLen, kCap bool, sliceType reflect.Type
 			k |= 1 << 2
 		}
 	}
-	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpMakeSlice, A: int8(t), B: k, C: dst})
+	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpMakeSlice, A: int16(t), B: k, C: dst})
 	if k > 0 {
 		fn.Body = append(fn.Body, runtime.Instruction{A: len, B: cap})
 	}
 }
 
 // emitMakeStruct appends a new "MakeStruct" instruction to the function body.
-func (fb *functionBuilder) emitMakeStruct(typ reflect.Type, dst int8) {
+func (fb *functionBuilder) emitMakeStruct(typ reflect.Type, dst int16) {
 	if typ.Kind() != reflect.Struct {
 		panic(internalError("%s is not a struct type", typ.Kind()))
 	}
@@ -559,28 +572,28 @@ func (fb *functionBuilder) emitMakeStruct(typ reflect.Type, dst int8) {
 	// the other methods.
 	fn := fb.fn
 	b := fb.addType(typ, false)
-	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpMakeStruct, B: int8(b), C: dst})
+	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpMakeStruct, B: int16(b), C: dst})
 }
 
 // emitMethodValue appends a new "MethodValue" instruction to the function body.
 //
 //     dst = receiver.name
 //
-func (fb *functionBuilder) emitMethodValue(name int8, receiver int8, dst int8, pos *ast.Position) {
+func (fb *functionBuilder) emitMethodValue(name uint16, receiver int16, dst int16, pos *ast.Position) {
 	fb.addPosAndPath(pos)
-	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpMethodValue, A: receiver, B: name, C: dst})
+	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpMethodValue, A: receiver, B: int16(name), C: dst})
 }
 
 // emitMove appends a new "Move" instruction to the function body.
 //
 //     z = x
 //
-func (fb *functionBuilder) emitMove(k bool, x, z int8, kind reflect.Kind) {
+func (fb *functionBuilder) emitMove(k bool, x, z int16, kind reflect.Kind) {
 	op := runtime.OpMove
 	if k {
 		op = -op
 	}
-	a := int8(kindToType(kind))
+	a := int16(kindToType(kind))
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: op, A: a, B: x, C: z})
 }
 
@@ -588,7 +601,7 @@ func (fb *functionBuilder) emitMove(k bool, x, z int8, kind reflect.Kind) {
 //
 //     z = x * y
 //
-func (fb *functionBuilder) emitMul(ky bool, x, y, z int8, kind reflect.Kind) {
+func (fb *functionBuilder) emitMul(ky bool, x, y, z int16, kind reflect.Kind) {
 	var op runtime.Operation
 	switch kind {
 	case reflect.Int:
@@ -599,7 +612,7 @@ func (fb *functionBuilder) emitMul(ky bool, x, y, z int8, kind reflect.Kind) {
 		if z != x {
 			panic(fmt.Errorf("z must be == x for kind %s", kind))
 		}
-		x = int8(flattenIntegerKind(kind))
+		x = int16(flattenIntegerKind(kind))
 		op = runtime.OpMul
 	}
 	if ky {
@@ -612,8 +625,8 @@ func (fb *functionBuilder) emitMul(ky bool, x, y, z int8, kind reflect.Kind) {
 //
 //     z = -y
 //
-func (fb *functionBuilder) emitNeg(y, z int8, kind reflect.Kind) {
-	x := int8(flattenIntegerKind(kind))
+func (fb *functionBuilder) emitNeg(y, z int16, kind reflect.Kind) {
+	x := int16(flattenIntegerKind(kind))
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpNeg, A: x, B: y, C: z})
 }
 
@@ -621,18 +634,18 @@ func (fb *functionBuilder) emitNeg(y, z int8, kind reflect.Kind) {
 //
 //     z = new(t)
 //
-func (fb *functionBuilder) emitNew(typ reflect.Type, z int8) {
+func (fb *functionBuilder) emitNew(typ reflect.Type, z int16) {
 	// NOTE: the code of emitMakeArray, emitMakeStruct and emitNew is very
 	// similar. If you change this code remember to review/change the code of
 	// the other methods.
 	fn := fb.fn
 	b := fb.addType(typ, false)
-	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpNew, B: int8(b), C: z})
+	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpNew, B: int16(b), C: z})
 }
 
 // emitNotZero appends a new "NotZero" instruction to the function body.
-func (fb *functionBuilder) emitNotZero(kind reflect.Kind, dst, src int8) {
-	regType := int8(kindToType(kind))
+func (fb *functionBuilder) emitNotZero(kind reflect.Kind, dst, src int16) {
+	regType := int16(kindToType(kind))
 	regType += 10 // to distinguish "NotZero" from "Zero".
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpZero, A: regType, B: src, C: dst})
 }
@@ -641,7 +654,7 @@ func (fb *functionBuilder) emitNotZero(kind reflect.Kind, dst, src int8) {
 //
 //     z = x | y
 //
-func (fb *functionBuilder) emitOr(k bool, x, y, z int8, kind reflect.Kind) {
+func (fb *functionBuilder) emitOr(k bool, x, y, z int16, kind reflect.Kind) {
 	op := runtime.OpOr
 	if k {
 		op = -op
@@ -656,12 +669,12 @@ func (fb *functionBuilder) emitOr(k bool, x, y, z int8, kind reflect.Kind) {
 //
 //     panic(v)
 //
-func (fb *functionBuilder) emitPanic(v int8, typ reflect.Type, pos *ast.Position) {
+func (fb *functionBuilder) emitPanic(v int16, typ reflect.Type, pos *ast.Position) {
 	fb.addPosAndPath(pos)
 	fn := fb.fn
 	in := runtime.Instruction{Op: runtime.OpPanic, A: v}
 	if typ != nil {
-		in.C = int8(fb.addType(typ, true))
+		in.C = int16(fb.addType(typ, true))
 	}
 	fn.Body = append(fn.Body, in)
 }
@@ -670,7 +683,7 @@ func (fb *functionBuilder) emitPanic(v int8, typ reflect.Type, pos *ast.Position
 //
 //     print(arg)
 //
-func (fb *functionBuilder) emitPrint(arg int8) {
+func (fb *functionBuilder) emitPrint(arg int16) {
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpPrint, A: arg})
 }
 
@@ -678,7 +691,7 @@ func (fb *functionBuilder) emitPrint(arg int8) {
 //
 //	for i, e := range s
 //
-func (fb *functionBuilder) emitRange(k bool, s, i, e int8, kind reflect.Kind) {
+func (fb *functionBuilder) emitRange(k bool, s, i, e int16, kind reflect.Kind) {
 	fn := fb.fn
 	var op runtime.Operation
 	switch kind {
@@ -700,7 +713,7 @@ func (fb *functionBuilder) emitRange(k bool, s, i, e int8, kind reflect.Kind) {
 //
 //	y, z = real(x), imag(x)
 //
-func (fb *functionBuilder) emitRealImag(k bool, x, y, z int8) {
+func (fb *functionBuilder) emitRealImag(k bool, x, y, z int16) {
 	op := runtime.OpRealImag
 	if k {
 		op = -op
@@ -714,7 +727,7 @@ func (fb *functionBuilder) emitRealImag(k bool, x, y, z int8) {
 //
 //	dst, ok = <- ch
 //
-func (fb *functionBuilder) emitReceive(ch, ok, dst int8) {
+func (fb *functionBuilder) emitReceive(ch, ok, dst int16) {
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpReceive, A: ch, B: ok, C: dst})
 }
 
@@ -723,8 +736,8 @@ func (fb *functionBuilder) emitReceive(ch, ok, dst int8) {
 //     recover()
 //     defer recover()
 //
-func (fb *functionBuilder) emitRecover(r int8, down bool) {
-	var a int8
+func (fb *functionBuilder) emitRecover(r int16, down bool) {
+	var a int16
 	if down {
 		// Recover down the stack.
 		a = 1
@@ -736,7 +749,7 @@ func (fb *functionBuilder) emitRecover(r int8, down bool) {
 //
 //     z = x % y
 //
-func (fb *functionBuilder) emitRem(ky bool, x, y, z int8, kind reflect.Kind, pos *ast.Position) {
+func (fb *functionBuilder) emitRem(ky bool, x, y, z int16, kind reflect.Kind, pos *ast.Position) {
 	fb.addPosAndPath(pos)
 	var op runtime.Operation
 	switch kind {
@@ -746,7 +759,7 @@ func (fb *functionBuilder) emitRem(ky bool, x, y, z int8, kind reflect.Kind, pos
 		if z != x {
 			panic(fmt.Errorf("z must be == x for kind %s", kind))
 		}
-		x = int8(flattenIntegerKind(kind))
+		x = int16(flattenIntegerKind(kind))
 		op = runtime.OpRem
 	}
 	if ky {
@@ -775,7 +788,7 @@ func (fb *functionBuilder) emitSelect() {
 //
 //	ch <- v
 //
-func (fb *functionBuilder) emitSend(ch, v int8, pos *ast.Position, chanElemKind reflect.Kind) {
+func (fb *functionBuilder) emitSend(ch, v int16, pos *ast.Position, chanElemKind reflect.Kind) {
 	fb.addPosAndPath(pos)
 	fb.addOperandKinds(chanElemKind, 0, 0)
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpSend, A: v, C: ch})
@@ -785,20 +798,20 @@ func (fb *functionBuilder) emitSend(ch, v int8, pos *ast.Position, chanElemKind
 //
 //     s.field = v
 //
-func (fb *functionBuilder) emitSetField(k bool, s, field, v int8, fieldKind reflect.Kind) {
+func (fb *functionBuilder) emitSetField(k bool, s int16, field uint16, v int16, fieldKind reflect.Kind) {
 	fb.addOperandKinds(fieldKind, 0, 0)
 	op := runtime.OpSetField
 	if k {
 		op = -op
 	}
-	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: op, A: v, B: s, C: field})
+	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: op, A: v, B: s, C: int16(field)})
 }
 
 // emitSetMap appends a new "SetMap" instruction to the function body.
 //
 //	m[key] = value
 //
-func (fb *functionBuilder) emitSetMap(k bool, m, value, key int8, mapType reflect.Type, pos *ast.Position) {
+func (fb *functionBuilder) emitSetMap(k bool, m, value, key int16, mapType reflect.Type, pos *ast.Position) {
 	keyType := mapType.Key()
 	valueType := mapType.Elem()
 	fb.addPosAndPath(pos)
@@ -815,7 +828,7 @@ func (fb *functionBuilder) emitSetMap(k bool, m, value, key int8, mapType reflec
 //
 //	slice[index] = value
 //
-func (fb *functionBuilder) emitSetSlice(k bool, slice, value, index int8, pos *ast.Position, sliceElemKind reflect.Kind) {
+func (fb *functionBuilder) emitSetSlice(k bool, slice, value, index int16, pos *ast.Position, sliceElemKind reflect.Kind) {
 	fb.addPosAndPath(pos)
 	fb.addOperandKinds(sliceElemKind, 0, 0)
 	in := runtime.Instruction{Op: runtime.OpSetSlice, A: value, B: slice, C: index}
@@ -829,20 +842,21 @@ func (fb *functionBuilder) emitSetSlice(k bool, slice, value, index int8, pos *a
 //
 //     v = r
 //
-func (fb *functionBuilder) emitSetVar(k bool, r int8, v int, dstKind reflect.Kind) {
+func (fb *functionBuilder) emitSetVar(k bool, r int16, v int, dstKind reflect.Kind) {
 	fb.addOperandKinds(dstKind, 0, 0)
 	op := runtime.OpSetVar
 	if k {
 		op = -op
 	}
-	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: op, A: r, B: int8(v >> 8), C: int8(v)})
+	b, c := encodeInt16(int16(v))
+	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: op, A: r, B: b, C: c})
 }
 
 // emitShl appends a new "Shl" instruction to the function body.
 //
 //     z = x << y
 //
-func (fb *functionBuilder) emitShl(k bool, x, y, z int8, kind reflect.Kind) {
+func (fb *functionBuilder) emitShl(k bool, x, y, z int16, kind reflect.Kind) {
 	var op runtime.Operation
 	switch kind {
 	case reflect.Int:
@@ -851,7 +865,7 @@ func (fb *functionBuilder) emitShl(k bool, x, y, z int8, kind reflect.Kind) {
 		if z != x {
 			panic(fmt.Errorf("z must be == x for kind %s", kind))
 		}
-		x = int8(flattenIntegerKind(kind))
+		x = int16(flattenIntegerKind(kind))
 		op = runtime.OpShl
 	}
 	if k {
@@ -864,17 +878,17 @@ func (fb *functionBuilder) emitShl(k bool, x, y, z int8, kind reflect.Kind) {
 //
 //     show(type, value, ctx)
 //
-func (fb *functionBuilder) emitShow(typ reflect.Type, v int8, ctx ast.Context, inURL, isURLSet bool) {
+func (fb *functionBuilder) emitShow(typ reflect.Type, v int16, ctx ast.Context, inURL, isURLSet bool) {
 	t := fb.addType(typ, true)
 	c := encodeRenderContext(ctx, inURL, isURLSet)
-	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpShow, A: int8(t), B: v, C: int8(c)})
+	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpShow, A: int16(t), B: v, C: int16(c)})
 }
 
 // emitShr appends a new "Shr" instruction to the function body.
 //
 //     z = x >> y
 //
-func (fb *functionBuilder) emitShr(k bool, x, y, z int8, kind reflect.Kind) {
+func (fb *functionBuilder) emitShr(k bool, x, y, z int16, kind reflect.Kind) {
 	var op runtime.Operation
 	switch kind {
 	case reflect.Int:
@@ -883,7 +897,7 @@ func (fb *functionBuilder) emitShr(k bool, x, y, z int8, kind reflect.Kind) {
 		if z != x {
 			panic(fmt.Errorf("z must be == x for kind %s", kind))
 		}
-		x = int8(flattenIntegerKind(kind))
+		x = int16(flattenIntegerKind(kind))
 		op = runtime.OpShr
 	}
 	if k {
@@ -896,10 +910,10 @@ func (fb *functionBuilder) emitShr(k bool, x, y, z int8, kind reflect.Kind) {
 //
 //	slice[low:high:max]
 //
-func (fb *functionBuilder) emitSlice(klow, khigh, kmax bool, src, dst, low, high, max int8, pos *ast.Position) {
+func (fb *functionBuilder) emitSlice(klow, khigh, kmax bool, src, dst, low, high, max int16, pos *ast.Position) {
 	fb.addPosAndPath(pos)
 	fn := fb.fn
-	var b int8
+	var b int16
 	if klow {
 		b = 1
 	}
@@ -917,10 +931,10 @@ func (fb *functionBuilder) emitSlice(klow, khigh, kmax bool, src, dst, low, high
 //
 //	string[low:high]
 //
-func (fb *functionBuilder) emitStringSlice(klow, khigh bool, src, dst, low, high int8, pos *ast.Position) {
+func (fb *functionBuilder) emitStringSlice(klow, khigh bool, src, dst, low, high int16, pos *ast.Position) {
 	fb.addPosAndPath(pos)
 	fn := fb.fn
-	var b int8
+	var b int16
 	if klow {
 		b = 1
 	}
@@ -935,7 +949,7 @@ func (fb *functionBuilder) emitStringSlice(klow, khigh bool, src, dst, low, high
 //
 //     z = x - y
 //
-func (fb *functionBuilder) emitSub(k bool, x, y, z int8, kind reflect.Kind) {
+func (fb *functionBuilder) emitSub(k bool, x, y, z int16, kind reflect.Kind) {
 	var op runtime.Operation
 	switch kind {
 	case reflect.Int:
@@ -946,7 +960,7 @@ func (fb *functionBuilder) emitSub(k bool, x, y, z int8, kind reflect.Kind) {
 		if z != x {
 			panic(fmt.Errorf("z must be == x for kind %s", kind))
 		}
-		x = int8(flattenIntegerKind(kind))
+		x = int16(flattenIntegerKind(kind))
 		op = runtime.OpSub
 	}
 	if k {
@@ -959,7 +973,7 @@ func (fb *functionBuilder) emitSub(k bool, x, y, z int8, kind reflect.Kind) {
 //
 //     z = y - x
 //
-func (fb *functionBuilder) emitSubInv(k bool, x, y, z int8, kind reflect.Kind) {
+func (fb *functionBuilder) emitSubInv(k bool, x, y, z int16, kind reflect.Kind) {
 	var op runtime.Operation
 	switch kind {
 	case reflect.Int:
@@ -970,7 +984,7 @@ func (fb *functionBuilder) emitSubInv(k bool, x, y, z int8, kind reflect.Kind) {
 		if z != x {
 			panic(fmt.Errorf("z must be == x for kind %s", kind))
 		}
-		x = int8(flattenIntegerKind(kind))
+		x = int16(flattenIntegerKind(kind))
 		op = runtime.OpSubInv
 	}
 	if k {
@@ -979,14 +993,15 @@ func (fb *functionBuilder) emitSubInv(k bool, x, y, z int8, kind reflect.Kind) {
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: op, A: x, B: y, C: z})
 }
 
-// emitText appends a new "Text" instruction to the function body.
+// emitText appends a new "Text" instruction to the function body. If nonce
+// is true, a nonce attribute can be inserted after the text.
 //
 //     text(txt, ctx)
 //
-func (fb *functionBuilder) emitText(txt []byte, inURL, isURLSet bool) {
+func (fb *functionBuilder) emitText(txt []byte, inURL, isURLSet, nonce bool) {
 	if len(fb.text.txt) > 0 {
 		addr := fb.currentAddr()
-		if addr == fb.text.addr+1 && inURL == fb.text.inURL {
+		if addr == fb.text.addr+1 && inURL == fb.text.inURL && !fb.text.nonce {
 			var hasLabel bool
 			for _, la := range fb.labelAddrs {
 				if addr == la {
@@ -996,6 +1011,10 @@ func (fb *functionBuilder) emitText(txt []byte, inURL, isURLSet bool) {
 			}
 			if !hasLabel {
 				fb.text.txt = append(fb.text.txt, txt)
+				if nonce {
+					fb.fn.Body[fb.text.addr].C |= 4
+					fb.text.nonce = true
+				}
 				return
 			}
 		}
@@ -1004,14 +1023,18 @@ func (fb *functionBuilder) emitText(txt []byte, inURL, isURLSet bool) {
 	fb.text.addr = fb.currentAddr()
 	fb.text.txt = append(fb.text.txt, txt)
 	fb.text.inURL = inURL
+	fb.text.nonce = nonce
 	a, b := encodeUint16(uint16(len(fb.fn.Text)))
-	var c int8
+	var c int16
 	if inURL {
 		c = 1
 		if isURLSet {
 			c = 2
 		}
 	}
+	if nonce {
+		c |= 4
+	}
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpText, A: a, B: b, C: c})
 }
 
@@ -1019,27 +1042,27 @@ func (fb *functionBuilder) emitText(txt []byte, inURL, isURLSet bool) {
 //
 //     f()
 //
-func (fb *functionBuilder) emitTailCall(f int8, pos *ast.Position) {
+func (fb *functionBuilder) emitTailCall(f int16, pos *ast.Position) {
 	fb.addPosAndPath(pos)
 	fn := fb.fn
 	fn.Body = append(fn.Body, runtime.Instruction{Op: runtime.OpTailCall, A: f})
 }
 
 // emitTypify appends a new "Typify" instruction to the function body.
-func (fb *functionBuilder) emitTypify(k bool, typ reflect.Type, x, z int8) {
+func (fb *functionBuilder) emitTypify(k bool, typ reflect.Type, x, z int16) {
 	t := fb.addType(typ, true)
 	op := runtime.OpTypify
 	if k {
 		op = -op
 	}
-	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: op, A: int8(t), B: x, C: z})
+	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: op, A: int16(t), B: x, C: z})
 }
 
 // emitXor appends a new "Xor" instruction to the function body.
 //
 //     z = x ^ y
 //
-func (fb *functionBuilder) emitXor(k bool, x, y, z int8, kind reflect.Kind) {
+func (fb *functionBuilder) emitXor(k bool, x, y, z int16, kind reflect.Kind) {
 	op := runtime.OpXor
 	if k {
 		op = -op
@@ -1048,7 +1071,7 @@ func (fb *functionBuilder) emitXor(k bool, x, y, z int8, kind reflect.Kind) {
 }
 
 // emitZero appends a new "Zero" instruction to the function body.
-func (fb *functionBuilder) emitZero(kind reflect.Kind, dst, src int8) {
-	regType := int8(kindToType(kind))
+func (fb *functionBuilder) emitZero(kind reflect.Kind, dst, src int16) {
+	regType := int16(kindToType(kind))
 	fb.fn.Body = append(fb.fn.Body, runtime.Instruction{Op: runtime.OpZero, A: regType, B: src, C: dst})
 }
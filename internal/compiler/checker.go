@@ -72,6 +72,10 @@ func typecheck(tree *ast.Tree, importer native.Importer, opts checkerOptions) (m
 	}
 
 	compilation := newCompilation(globalScope)
+	if opts.recordUses {
+		compilation.uses = map[ast.Expression]*ast.Identifier{}
+		compilation.usedImports = map[*ast.Import]bool{}
+	}
 	tc := newTypechecker(compilation, tree.Path, opts, importer)
 
 	// If tree extends another template file, transform it swapping the files
@@ -107,6 +111,8 @@ func typecheck(tree *ast.Tree, importer native.Importer, opts checkerOptions) (m
 	mainPkgInfo := &packageInfo{}
 	mainPkgInfo.IndirectVars = tc.compilation.indirectVars
 	mainPkgInfo.TypeInfos = tc.compilation.typeInfos
+	mainPkgInfo.Uses = tc.compilation.uses
+	mainPkgInfo.UsedImports = tc.compilation.usedImports
 	err = compilation.finalizeUsingStatements(tc)
 	if err != nil {
 		return nil, err
@@ -131,6 +137,10 @@ type checkerOptions struct {
 
 	// mdConverter converts a Markdown source code to HTML.
 	mdConverter Converter
+
+	// recordUses reports whether the declarations and the imports referred
+	// to by identifiers and selectors are recorded.
+	recordUses bool
 }
 
 // typechecker represents the state of the type checking.
@@ -142,6 +152,12 @@ type typechecker struct {
 
 	path string
 
+	// files maps the declarations of the package being checked to the paths
+	// of their files, and file is the path of the current file. files is nil,
+	// and file is empty, for templates and scripts.
+	files map[ast.Node]string
+	file  string
+
 	importer native.Importer
 
 	// scopes holds the universe block, global block, file/package block,
@@ -252,8 +268,11 @@ func (tc *typechecker) assignScope(name string, value *typeInfo, decl *ast.Ident
 	ok := tc.scopes.Declare(name, value, decl, impor)
 	if !ok && (isValidIdentifier(name, tc.opts.mod) || strings.HasPrefix(name, "$")) {
 		s := name + " redeclared in this block"
-		if i, ok := tc.scopes.LookupImport(name); ok {
-			s += fmt.Sprintf("\n\t%s:%s: previous declaration during %s", tc.path, i.Pos(), i)
+		if i, path, ok := tc.scopes.LookupImport(name); ok {
+			if path == "" {
+				path = tc.path
+			}
+			s += fmt.Sprintf("\n\t%s:%s: previous declaration during %s", path, i.Pos(), i)
 		} else {
 			_, decl, _ := tc.scopes.Lookup(name)
 			pos := decl.Pos()
@@ -273,11 +292,14 @@ func (tc *typechecker) declarePackageName(name string, ti *typeInfo, impor *ast.
 	ok := tc.scopes.Declare(name, ti, nil, impor)
 	if !ok && (isValidIdentifier(name, tc.opts.mod) || strings.HasPrefix(name, "$")) {
 		s := name + " redeclared as imported package name"
-		i, ok := tc.scopes.LookupImport(name)
+		i, path, ok := tc.scopes.LookupImport(name)
 		if !ok {
 			panic(internalError("unexpected failing LookupImport"))
 		}
-		s += fmt.Sprintf("\n\t%s:%s: previous declaration", tc.path, i.Pos())
+		if path == "" {
+			path = tc.path
+		}
+		s += fmt.Sprintf("\n\t%s:%s: previous declaration", path, i.Pos())
 		panic(tc.errorf(impor, s))
 	}
 }
@@ -358,7 +380,30 @@ func (tc *typechecker) getNestedFuncs(name string) []*ast.Func {
 //		}
 //
 func (tc *typechecker) errorf(nodeOrPos interface{}, format string, args ...interface{}) error {
-	return checkError(tc.path, nodeOrPos, format, args...)
+	path := tc.path
+	if tc.file != "" {
+		path = tc.file
+	}
+	return checkError(path, nodeOrPos, format, args...)
+}
+
+// setFile sets the file with the given path as the current file. The errors
+// are reported with the path of the current file and the imported names are
+// looked up in its file block. If the package being checked has no files, as
+// for templates and scripts, it does nothing.
+func (tc *typechecker) setFile(path string) {
+	if tc.files == nil {
+		return
+	}
+	tc.file = path
+	tc.scopes.SetFile(path)
+}
+
+// fileOf returns the path of the file in which the declaration decl of the
+// package being checked is declared. If the package has no files, it returns
+// an empty string.
+func (tc *typechecker) fileOf(decl ast.Node) string {
+	return tc.files[decl]
 }
 
 func checkError(path string, nodeOrPos interface{}, format string, args ...interface{}) error {
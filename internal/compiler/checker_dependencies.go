@@ -116,6 +116,18 @@ func (d *deps) analyzeGlobalDeclarationAssignment(n *ast.Assignment) {
 // analyzeGlobalFunc analyzes a global function declaration.
 func (d *deps) analyzeGlobalFunc(n *ast.Func) {
 	scopes := depScopes{map[string]struct{}{}}
+	for _, p := range n.TypeParams {
+		scopes = declareLocally(scopes, p.Ident.Name)
+	}
+	if n.Recv != nil {
+		// Type parameters of the receiver of a method of a generic type.
+		for _, p := range receiverTypeParams(n.Recv) {
+			scopes = declareLocally(scopes, p.Name)
+		}
+	}
+	for _, p := range n.TypeParams {
+		d.addDepsToGlobal(n.Ident, p.Type, scopes)
+	}
 	for _, f := range n.Type.Parameters {
 		if f.Ident != nil {
 			scopes = declareLocally(scopes, f.Ident.Name)
@@ -134,7 +146,17 @@ func (d *deps) analyzeGlobalFunc(n *ast.Func) {
 
 // analyzeGlobalTypeDeclaration analyzes a global type declaration.
 func (d *deps) analyzeGlobalTypeDeclaration(td *ast.TypeDeclaration) {
-	d.addDepsToGlobal(td.Ident, td.Type, nil)
+	var scopes depScopes
+	if td.TypeParams != nil {
+		scopes = depScopes{map[string]struct{}{}}
+		for _, p := range td.TypeParams {
+			scopes = declareLocally(scopes, p.Ident.Name)
+		}
+		for _, p := range td.TypeParams {
+			d.addDepsToGlobal(td.Ident, p.Type, scopes)
+		}
+	}
+	d.addDepsToGlobal(td.Ident, td.Type, scopes)
 }
 
 // analyzeTree analyzes tree returning a data structure holding all dependencies
@@ -379,8 +401,18 @@ func (d *deps) nodeDeps(n ast.Node, scopes depScopes) []*ast.Identifier {
 	case *ast.Index:
 		deps := d.nodeDeps(n.Expr, scopes)
 		return append(deps, d.nodeDeps(n.Index, scopes)...)
+	case *ast.Instantiation:
+		deps := d.nodeDeps(n.Expr, scopes)
+		for _, arg := range n.TypeArgs {
+			deps = append(deps, d.nodeDeps(arg, scopes)...)
+		}
+		return deps
 	case *ast.Interface:
-		return nil
+		deps := []*ast.Identifier{}
+		for _, m := range n.Methods {
+			deps = append(deps, d.nodeDeps(m.Type, scopes)...)
+		}
+		return deps
 	case *ast.Label:
 		return nil
 	case *ast.MapType:
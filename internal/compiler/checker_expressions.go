@@ -15,6 +15,7 @@ import (
 
 	"github.com/open2b/scriggo/ast"
 	"github.com/open2b/scriggo/internal/compiler/types"
+	"github.com/open2b/scriggo/internal/runtime"
 )
 
 var untypedBoolTypeInfo = &typeInfo{Type: boolType, Properties: propertyUntyped}
@@ -144,6 +145,17 @@ func (tc *typechecker) checkIdentifier(ident *ast.Identifier, used bool) *typeIn
 		tc.compilation.iteaToUsingCheck[ident.Name] = uc
 	}
 
+	// Record the declaration and the import referred to by the identifier.
+	if tc.compilation.uses != nil {
+		decl, impor := tc.scopes.Declaration(ident.Name)
+		if decl != nil && decl != ident {
+			tc.compilation.uses[ident] = decl
+		}
+		if impor != nil {
+			tc.compilation.usedImports[impor] = true
+		}
+	}
+
 	tc.compilation.typeInfos[ident] = ti
 	return ti
 }
@@ -182,6 +194,7 @@ func (tc *typechecker) checkArrayType(array *ast.ArrayType, length int) *typeInf
 // checkExpr type checks an expression and returns its type info.
 func (tc *typechecker) checkExpr(expr ast.Expression) *typeInfo {
 	ti := tc.typeof(expr, false)
+	tc.checkNotGeneric(expr, ti)
 	if ti.IsType() {
 		panic(tc.errorf(expr, "type %s is not an expression", ti))
 	}
@@ -217,9 +230,11 @@ func subExpr(expr ast.Expression, left bool) ast.Expression {
 // checkType type checks a type and returns its type info.
 func (tc *typechecker) checkType(expr ast.Expression) *typeInfo {
 	ti := tc.typeof(expr, true)
+	tc.checkNotGeneric(expr, ti)
 	if !ti.IsType() {
 		panic(tc.errorf(expr, "%s is not a type", expr))
 	}
+	tc.checkNotConstraint(expr, ti)
 	tc.compilation.typeInfos[expr] = ti
 	return ti
 }
@@ -228,6 +243,8 @@ func (tc *typechecker) checkType(expr ast.Expression) *typeInfo {
 // info.
 func (tc *typechecker) checkExprOrType(expr ast.Expression) *typeInfo {
 	ti := tc.typeof(expr, false)
+	tc.checkNotGeneric(expr, ti)
+	tc.checkNotConstraint(expr, ti)
 	tc.compilation.typeInfos[expr] = ti
 	return ti
 }
@@ -535,7 +552,15 @@ func (tc *typechecker) typeof(expr ast.Expression, typeExpected bool) *typeInfo
 		panic(tc.errorf(expr, "cannot use default expression in this context"))
 
 	case *ast.Interface:
-		return &typeInfo{Type: emptyInterfaceType, Properties: propertyIsType | propertyUniverse}
+		if len(expr.Methods) == 0 {
+			return &typeInfo{Type: emptyInterfaceType, Properties: propertyIsType | propertyUniverse}
+		}
+		typ, c := tc.checkInterfaceType(expr)
+		ti := &typeInfo{Type: typ, Properties: propertyIsType}
+		if c != nil {
+			ti.value = c
+		}
+		return ti
 
 	case *ast.FuncType:
 		tc.checkDuplicateParams(expr)
@@ -607,7 +632,16 @@ func (tc *typechecker) typeof(expr ast.Expression, typeExpected bool) *typeInfo
 		}
 		return tis[0]
 
+	case *ast.Instantiation:
+		if ti, ok := tc.checkInstantiation(expr, expr.Expr, expr.TypeArgs); ok {
+			return ti
+		}
+		panic(tc.errorf(expr, "%s is not a generic function or type", expr.Expr))
+
 	case *ast.Index:
+		if ti, ok := tc.checkInstantiation(expr, expr.Expr, []ast.Expression{expr.Index}); ok {
+			return ti
+		}
 		t := tc.checkExpr(expr.Expr)
 		if t.Nil() {
 			panic(tc.errorf(expr, "use of untyped nil"))
@@ -777,6 +811,71 @@ func (tc *typechecker) typeof(expr ast.Expression, typeExpected bool) *typeInfo
 	panic(fmt.Errorf("unexpected: %v (type %T)", expr, expr))
 }
 
+// checkInterfaceType checks an interface type, with at least one method or
+// embedded element, and returns its type. If the interface has type terms or
+// embeds comparable, so that it can only be used as a constraint, it also
+// returns its type set.
+func (tc *typechecker) checkInterfaceType(expr *ast.Interface) (reflect.Type, *constraint) {
+	var methods []reflect.Method
+	explicit := map[string]struct{}{}
+	// Check the explicitly declared methods.
+	for _, m := range expr.Methods {
+		if m.Idents == nil {
+			continue
+		}
+		ident := m.Idents[0]
+		if isBlankIdentifier(ident) {
+			panic(tc.errorf(ident, "methods must have a unique non-blank name"))
+		}
+		if _, ok := explicit[ident.Name]; ok {
+			panic(tc.errorf(ident, "duplicate method %s", ident.Name))
+		}
+		explicit[ident.Name] = struct{}{}
+		method := reflect.Method{Name: ident.Name, Type: tc.checkType(m.Type).Type}
+		if !isExported(ident.Name) {
+			method.PkgPath = tc.path
+		}
+		methods = append(methods, method)
+	}
+	// Add the methods of the embedded interfaces, and the type terms of the
+	// embedded elements. Methods with the same name must have identical types.
+	var set *constraint
+	for _, m := range expr.Methods {
+		if m.Idents != nil {
+			continue
+		}
+		c := tc.checkConstraint(m.Type)
+		if c.terms != nil || c.comparable {
+			if set == nil {
+				set = &constraint{}
+			}
+			set.intersect(c)
+		}
+		t := c.methods
+		if t == nil {
+			continue
+		}
+	Embedded:
+		for i := 0; i < t.NumMethod(); i++ {
+			method := t.Method(i)
+			for _, m2 := range methods {
+				if m2.Name == method.Name {
+					if m2.Type != method.Type {
+						panic(tc.errorf(m.Type, "duplicate method %s", method.Name))
+					}
+					continue Embedded
+				}
+			}
+			methods = append(methods, method)
+		}
+	}
+	typ := tc.types.InterfaceOf(methods)
+	if set != nil && len(methods) > 0 {
+		set.methods = typ
+	}
+	return typ, set
+}
+
 // checkIndex checks the type of expr as an index in a index or slice
 // expression. If it is a constant returns the integer value, otherwise
 // returns -1.
@@ -1597,6 +1696,10 @@ func (tc *typechecker) checkCallExpression(expr *ast.Call) []*typeInfo {
 		}
 	}
 
+	if tc.compilation.typeInfos[expr.Func] == nil {
+		tc.inferTypeArgs(expr)
+	}
+
 	t := tc.checkExprOrType(expr.Func)
 
 	switch t.MethodType {
@@ -1604,6 +1707,8 @@ func (tc *typechecker) checkCallExpression(expr *ast.Call) []*typeInfo {
 		t.MethodType = methodCallConcrete
 	case methodValueInterface:
 		t.MethodType = methodCallInterface
+	case methodValueScriggo:
+		t.MethodType = methodCallScriggo
 	}
 
 	if t.Nil() {
@@ -2253,7 +2358,7 @@ func (tc *typechecker) checkDollarIdentifier(expr *ast.DollarIdentifier) *typeIn
 	// expr.IR.Ident is set to "interface{}(x)" or "interface{}(nil)".
 	expr.IR.Ident = ast.NewCall(
 		pos,
-		ast.NewInterface(pos), // "interface{}"
+		ast.NewInterface(pos, nil), // "interface{}"
 		[]ast.Expression{arg}, // "x" or "nil"
 		false,
 	)
@@ -2505,6 +2610,16 @@ func (tc *typechecker) checkPackageSelector(expr *ast.Selector) (*typeInfo, bool
 	tc.compilation.typeInfos[expr] = ti
 	tc.scopes.Use(ident.Name)
 
+	// Record the declaration and the import referred to by the selector.
+	if tc.compilation.uses != nil {
+		if decl := pkg.value.(*packageInfo).DeclarationNodes[expr.Ident]; decl != nil {
+			tc.compilation.uses[expr] = decl
+		}
+		if _, impor := tc.scopes.Declaration(ident.Name); impor != nil {
+			tc.compilation.usedImports[impor] = true
+		}
+	}
+
 	return ti, true
 }
 
@@ -2513,6 +2628,38 @@ func (tc *typechecker) checkMethodExpression(t *typeInfo, expr *ast.Selector) *t
 
 	name := expr.Ident
 
+	// Method declared in Scriggo.
+	if m, ok := types.MethodByName(t.Type, name); ok {
+		isPtr := t.Type.Kind() == reflect.Ptr
+		if m.Pointer && !isPtr {
+			panic(tc.errorf(expr, "invalid method expression %s (needs pointer receiver: (*%s).%s)",
+				expr, expr.Expr, expr.Ident))
+		}
+		if !m.Pointer && isPtr && m.Index == nil {
+			panic(tc.errorf(expr, "invalid method expression %s (method %s has value receiver)", expr, name))
+		}
+		in := make([]reflect.Type, m.Type.NumIn()+1)
+		in[0] = t.Type
+		for i := 1; i < len(in); i++ {
+			in[i] = m.Type.In(i - 1)
+		}
+		out := make([]reflect.Type, m.Type.NumOut())
+		for i := range out {
+			out[i] = m.Type.Out(i)
+		}
+		typ := tc.types.FuncOf(in, out, m.Type.IsVariadic())
+		// A promoted method has no function, so the method expression is
+		// implemented with a function literal.
+		if m.Index != nil {
+			return tc.methodExprFuncLit(expr, typ)
+		}
+		return &typeInfo{
+			Type:       typ,
+			value:      m,
+			MethodType: methodExprScriggo,
+		}
+	}
+
 	method, ok := t.Type.MethodByName(name)
 	if !ok {
 		// Return a different error message if T is a defined non-pointer type
@@ -2529,23 +2676,32 @@ func (tc *typechecker) checkMethodExpression(t *typeInfo, expr *ast.Selector) *t
 	ti := &typeInfo{Properties: propertyIsNative | propertyHasValue}
 
 	if t.Type.Kind() == reflect.Interface {
+		// As the values of interface types declared in Scriggo code can be
+		// proxies, the method expression is implemented with a function
+		// literal.
+		if _, ok := t.Type.(runtime.ScriggoType); ok {
+			// Unexported methods of interfaces declared in Scriggo code can
+			// be referred to only in the package where the interface is
+			// declared.
+			if !isExported(name) && method.PkgPath != tc.path {
+				panic(tc.errorf(expr, "%s undefined (cannot refer to unexported field or method %s)", expr, name))
+			}
+			mt := method.Type
+			in := make([]reflect.Type, mt.NumIn()+1)
+			in[0] = t.Type
+			for i := 0; i < mt.NumIn(); i++ {
+				in[i+1] = mt.In(i)
+			}
+			out := make([]reflect.Type, mt.NumOut())
+			for i := range out {
+				out[i] = mt.Out(i)
+			}
+			return tc.methodExprFuncLit(expr, tc.types.FuncOf(in, out, mt.IsVariadic()))
+		}
 		if !isExported(name) {
 			panic(tc.errorf(expr, "%s undefined (cannot refer to unexported field or method %s)", expr, name))
 		}
-		mt := method.Type
-		in := make([]reflect.Type, mt.NumIn()+1)
-		in[0] = t.Type
-		for i := 0; i < mt.NumIn(); i++ {
-			in[i+1] = mt.In(i)
-		}
-		out := make([]reflect.Type, mt.NumOut())
-		for i := 0; i < mt.NumOut(); i++ {
-			out[i] = mt.Out(i)
-		}
-		f := func(args []reflect.Value) []reflect.Value {
-			return args[0].MethodByName(method.Name).Call(args[1:])
-		}
-		methExpr := reflect.MakeFunc(reflect.FuncOf(in, out, mt.IsVariadic()), f)
+		methExpr := interfaceMethodExpr(t.Type, method)
 		ti.Type = removeEnvArg(methExpr.Type(), false)
 		ti.value = methExpr
 	} else {
@@ -2556,6 +2712,68 @@ func (tc *typechecker) checkMethodExpression(t *typeInfo, expr *ast.Selector) *t
 	return ti
 }
 
+// methodExprFuncLit returns the type info of the method expression expr,
+// with type typ, implemented with a function literal that calls the method
+// on its first parameter. The function literal is the value of the returned
+// type info.
+func (tc *typechecker) methodExprFuncLit(expr *ast.Selector, typ reflect.Type) *typeInfo {
+	pos := expr.Pos()
+	typeExpr := func(t reflect.Type) ast.Expression {
+		ph := ast.NewPlaceholder()
+		tc.compilation.typeInfos[ph] = &typeInfo{Properties: propertyIsType, Type: t}
+		return ph
+	}
+	params := make([]*ast.Parameter, typ.NumIn())
+	args := make([]ast.Expression, typ.NumIn()-1)
+	for i := range params {
+		t := typ.In(i)
+		if i == len(params)-1 && typ.IsVariadic() {
+			t = t.Elem()
+		}
+		ident := ast.NewIdentifier(pos, "p"+strconv.Itoa(i))
+		params[i] = ast.NewParameter(ident, typeExpr(t))
+		if i > 0 {
+			args[i-1] = ast.NewIdentifier(pos, ident.Name)
+		}
+	}
+	result := make([]*ast.Parameter, typ.NumOut())
+	for i := range result {
+		result[i] = ast.NewParameter(nil, typeExpr(typ.Out(i)))
+	}
+	rcv := ast.NewIdentifier(pos, params[0].Ident.Name)
+	var body ast.Node = ast.NewCall(pos, ast.NewSelector(pos, rcv, expr.Ident), args, typ.IsVariadic())
+	if len(result) > 0 {
+		body = ast.NewReturn(pos, []ast.Expression{body.(ast.Expression)})
+	}
+	funcType := ast.NewFuncType(pos, false, params, result, typ.IsVariadic())
+	fn := ast.NewFunc(pos, nil, funcType, ast.NewBlock(pos, []ast.Node{body}), false, 0)
+	ti := tc.checkExpr(fn)
+	return &typeInfo{
+		Type:       ti.Type,
+		value:      fn,
+		MethodType: methodExprFuncLit,
+	}
+}
+
+// interfaceMethodExpr returns a function that calls the method of the
+// interface type t on its first argument.
+func interfaceMethodExpr(t reflect.Type, method reflect.Method) reflect.Value {
+	mt := method.Type
+	in := make([]reflect.Type, mt.NumIn()+1)
+	in[0] = t
+	for i := 0; i < mt.NumIn(); i++ {
+		in[i+1] = mt.In(i)
+	}
+	out := make([]reflect.Type, mt.NumOut())
+	for i := 0; i < mt.NumOut(); i++ {
+		out[i] = mt.Out(i)
+	}
+	f := func(args []reflect.Value) []reflect.Value {
+		return args[0].MethodByName(method.Name).Call(args[1:])
+	}
+	return reflect.MakeFunc(reflect.FuncOf(in, out, mt.IsVariadic()), f)
+}
+
 // checkMethodValue checks a method value. If the type has the method, it
 // returns the type info and true, otherwise returns nil and false.
 func (tc *typechecker) checkMethodValue(t *typeInfo, expr *ast.Selector) (*typeInfo, bool) {
@@ -2564,6 +2782,49 @@ func (tc *typechecker) checkMethodValue(t *typeInfo, expr *ast.Selector) (*typeI
 	typ := t.Type
 	kind := typ.Kind()
 
+	// Method declared in Scriggo.
+	if m, ok := types.MethodByName(typ, name); ok {
+		if m.Index != nil {
+			if m.Pointer && kind != reflect.Ptr && !t.Addressable() {
+				panic(tc.errorf(expr, "cannot call pointer method on %s", expr.Expr))
+			}
+			// Transform t.M, where M is promoted from the embedded field E,
+			// into t.E.M.
+			st := typ
+			for _, i := range m.Index {
+				if st.Kind() == reflect.Ptr {
+					st = st.Elem()
+				}
+				f := st.Field(i)
+				expr.Expr = ast.NewSelector(expr.Pos(), expr.Expr, decodeFieldName(f.Name))
+				st = f.Type
+			}
+			return tc.checkMethodValue(tc.checkExpr(expr.Expr), expr)
+		}
+		if m.Pointer && kind != reflect.Ptr {
+			if !t.Addressable() {
+				panic(tc.errorf(expr, "cannot call pointer method on %s", expr.Expr))
+			}
+			// Transform t.Mp into (&t).Mp.
+			if ident, ok := expr.Expr.(*ast.Identifier); ok {
+				if _, decl, ok := tc.scopes.LookupInFunc(ident.Name); ok {
+					tc.compilation.indirectVars[decl] = true
+				}
+			}
+			expr.Expr = ast.NewUnaryOperator(expr.Pos(), ast.OperatorAddress, expr.Expr)
+			tc.compilation.typeInfos[expr.Expr] = &typeInfo{Type: tc.types.PtrTo(typ)}
+		} else if !m.Pointer && kind == reflect.Ptr {
+			// Transform p.Mv into (*p).Mv.
+			expr.Expr = ast.NewUnaryOperator(expr.Pos(), ast.OperatorPointer, expr.Expr)
+			tc.compilation.typeInfos[expr.Expr] = &typeInfo{Type: typ.Elem(), Properties: propertyAddressable}
+		}
+		return &typeInfo{
+			Type:       m.Type,
+			value:      m,
+			MethodType: methodValueScriggo,
+		}, true
+	}
+
 	method, ok := t.Type.MethodByName(name)
 	if !ok {
 		if kind == reflect.Interface || kind == reflect.Ptr || !t.Addressable() {
@@ -2588,7 +2849,9 @@ func (tc *typechecker) checkMethodValue(t *typeInfo, expr *ast.Selector) (*typeI
 	}
 
 	if kind == reflect.Interface {
-		if !isExported(name) {
+		// Unexported methods of interfaces declared in Scriggo code can be
+		// referred to only in the package where the interface is declared.
+		if _, ok := typ.(runtime.ScriggoType); !isExported(name) && !(ok && method.PkgPath == tc.path) {
 			panic(tc.errorf(expr, "%s undefined (cannot refer to unexported field or method %s)", expr, name))
 		}
 		return &typeInfo{
@@ -2620,6 +2883,9 @@ func (tc *typechecker) checkFieldSelector(t *typeInfo, expr *ast.Selector) *type
 		panic(tc.errorf(expr, "%v undefined (type %s has no field or method %s)", expr, t.Type, name))
 	}
 
+	if types.AmbiguousMethod(typ, name) {
+		panic(tc.errorf(expr, "ambiguous selector %s", expr))
+	}
 	typ, _, encodedName := tc.findStructField(typ, expr)
 	if typ == nil {
 		panic(tc.errorf(expr, "%v undefined (type %s has no field or method %s)", expr, t.Type, name))
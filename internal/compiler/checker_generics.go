@@ -0,0 +1,1015 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compiler
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/open2b/scriggo/ast"
+	"github.com/open2b/scriggo/ast/astutil"
+	"github.com/open2b/scriggo/internal/compiler/types"
+)
+
+// maxInstantiationDepth is the maximum depth of nested instantiations of
+// generic functions and types.
+const maxInstantiationDepth = 100
+
+// Generic functions and types are implemented by instantiation at compile
+// time: every time a generic function or type is instantiated with a new list
+// of type arguments, its declaration is cloned, the type parameters are bound
+// to the type arguments, and the clone is type checked and emitted as a
+// non-generic declaration.
+//
+// The body of a generic function, or of a method of a generic type, is type
+// checked only when it is instantiated.
+
+// genericFunc represents a generic function declared in a package.
+type genericFunc struct {
+	decl      *ast.Func
+	tc        *typechecker
+	pkg       *ast.Package
+	instances []*funcInstance
+}
+
+// funcInstance represents an instance of a generic function.
+type funcInstance struct {
+	typeArgs []reflect.Type
+	decl     *ast.Func
+	pkg      *ast.Package
+	typ      reflect.Type
+}
+
+// genericType represents a generic type declared in a package.
+type genericType struct {
+	decl      *ast.TypeDeclaration
+	tc        *typechecker
+	pkg       *ast.Package
+	methods   []*ast.Func
+	instances []*typeInstance
+}
+
+// typeInstance represents an instance of a generic type.
+type typeInstance struct {
+	typeArgs []reflect.Type
+	typ      reflect.Type
+}
+
+// constraint represents the type set of an interface used as constraint.
+type constraint struct {
+	// methods is the interface type with the methods of the constraint. It is
+	// nil if the constraint has no methods.
+	methods reflect.Type
+	// terms are the type terms of the constraint. It is nil if the constraint
+	// has no type terms and it is not nil, but empty, if the type set is
+	// empty.
+	terms []constraintTerm
+	// comparable reports whether the constraint is, or embeds, comparable.
+	comparable bool
+}
+
+// constraintTerm is a type term of a constraint.
+type constraintTerm struct {
+	tilde bool
+	typ   reflect.Type
+}
+
+// String returns the string representation of the type terms of c.
+func (c *constraint) String() string {
+	var b strings.Builder
+	for i, term := range c.terms {
+		if i > 0 {
+			b.WriteString(" | ")
+		}
+		if term.tilde {
+			b.WriteString("~")
+		}
+		b.WriteString(term.typ.String())
+	}
+	return b.String()
+}
+
+// intersect intersects the type set of c with the type set of c2.
+func (c *constraint) intersect(c2 *constraint) {
+	c.comparable = c.comparable || c2.comparable
+	if c2.terms == nil {
+		return
+	}
+	if c.terms == nil {
+		c.terms = c2.terms
+		return
+	}
+	terms := []constraintTerm{}
+	for _, t1 := range c.terms {
+		for _, t2 := range c2.terms {
+			if t1.typ == t2.typ {
+				terms = append(terms, constraintTerm{tilde: t1.tilde && t2.tilde, typ: t1.typ})
+				break
+			}
+		}
+	}
+	c.terms = terms
+}
+
+// isGeneric reports whether ti is the type info of a generic function or of
+// a generic type.
+func (ti *typeInfo) isGeneric() bool {
+	switch ti.value.(type) {
+	case *genericFunc, *genericType:
+		return true
+	}
+	return false
+}
+
+// checkNotGeneric panics with an error if ti is the type info of a generic
+// function or type used without instantiation.
+func (tc *typechecker) checkNotGeneric(expr ast.Expression, ti *typeInfo) {
+	switch ti.value.(type) {
+	case *genericFunc:
+		panic(tc.errorf(expr, "cannot use generic function %s without instantiation", expr))
+	case *genericType:
+		panic(tc.errorf(expr, "cannot use generic type %s without instantiation", expr))
+	}
+}
+
+// checkNotConstraint panics with an error if ti is the type info of an
+// interface type that can only be used as a constraint.
+func (tc *typechecker) checkNotConstraint(expr ast.Expression, ti *typeInfo) {
+	if c, ok := ti.value.(*constraint); ok && ti.IsType() {
+		if c.terms == nil {
+			panic(tc.errorf(expr, "cannot use type %s outside a type constraint: interface is (or embeds) comparable", expr))
+		}
+		panic(tc.errorf(expr, "cannot use type %s outside a type constraint: interface contains type constraints", expr))
+	}
+}
+
+// receiverTypeParams returns the type parameters of the receiver recv of a
+// method of a generic type. If the receiver base type is not a generic type
+// instantiation, it returns nil.
+func receiverTypeParams(recv *ast.Parameter) []*ast.Identifier {
+	base := recv.Type
+	if op, ok := base.(*ast.UnaryOperator); ok && op.Op == ast.OperatorPointer {
+		base = op.Expr
+	}
+	var args []ast.Expression
+	switch b := base.(type) {
+	case *ast.Index:
+		args = []ast.Expression{b.Index}
+	case *ast.Instantiation:
+		args = b.TypeArgs
+	default:
+		return nil
+	}
+	params := make([]*ast.Identifier, 0, len(args))
+	for _, arg := range args {
+		if ident, ok := arg.(*ast.Identifier); ok {
+			params = append(params, ident)
+		}
+	}
+	return params
+}
+
+// receiverBaseName returns the name of the base type of the receiver of a
+// method of a generic type and true. If the receiver base type is not a
+// generic type instantiation, it returns an empty string and false.
+func receiverBaseName(recv *ast.Parameter) (string, bool) {
+	base := recv.Type
+	if op, ok := base.(*ast.UnaryOperator); ok && op.Op == ast.OperatorPointer {
+		base = op.Expr
+	}
+	var expr ast.Expression
+	switch b := base.(type) {
+	case *ast.Index:
+		expr = b.Expr
+	case *ast.Instantiation:
+		expr = b.Expr
+	default:
+		return "", false
+	}
+	if ident, ok := expr.(*ast.Identifier); ok {
+		return ident.Name, true
+	}
+	return "", true
+}
+
+// extractGenerics removes the generic functions, and the methods of the
+// generic types, from the declarations of pkg and declares the generic
+// functions in the file/package block. It returns the methods of the generic
+// types grouped by the name of their base type.
+func (tc *typechecker) extractGenerics(pkg *ast.Package) map[string][]*ast.Func {
+	var methods map[string][]*ast.Func
+	declarations := pkg.Declarations[:0]
+	for _, d := range pkg.Declarations {
+		f, ok := d.(*ast.Func)
+		if !ok {
+			declarations = append(declarations, d)
+			continue
+		}
+		tc.setFile(tc.fileOf(f))
+		if f.TypeParams != nil {
+			if tc.opts.mod != programMod {
+				panic(tc.errorf(f.Ident, "generic functions are only supported in programs"))
+			}
+			if f.Body == nil {
+				panic(tc.errorf(f.Ident.Pos(), "missing function body"))
+			}
+			if f.Ident.Name == "init" || f.Ident.Name == "main" {
+				panic(tc.errorf(f.Ident, "func %s must have no type parameters", f.Ident.Name))
+			}
+			tc.useImports(f)
+			if isBlankIdentifier(f.Ident) {
+				continue
+			}
+			g := &genericFunc{decl: f, tc: tc, pkg: pkg}
+			if !tc.scopes.Declare(f.Ident.Name, &typeInfo{value: g}, f.Ident, nil) {
+				panic(tc.errorf(f.Ident, "%s redeclared in this block", f.Ident.Name))
+			}
+			continue
+		}
+		if f.Recv != nil {
+			if name, ok := receiverBaseName(f.Recv); ok {
+				if name == "" {
+					panic(tc.errorf(f.Recv.Type, "invalid receiver %s", f.Recv.Type))
+				}
+				if f.Body == nil {
+					panic(tc.errorf(f.Ident.Pos(), "missing function body"))
+				}
+				tc.useImports(f)
+				if methods == nil {
+					methods = map[string][]*ast.Func{}
+				}
+				methods[name] = append(methods[name], f)
+				continue
+			}
+		}
+		declarations = append(declarations, d)
+	}
+	pkg.Declarations = declarations
+	return methods
+}
+
+// declareGenericType declares the generic type td, with methods methods, in
+// the file/package block.
+func (tc *typechecker) declareGenericType(pkg *ast.Package, td *ast.TypeDeclaration, methods []*ast.Func) {
+	if tc.opts.mod != programMod {
+		panic(tc.errorf(td.Ident, "generic types are only supported in programs"))
+	}
+	tc.useImports(td)
+	if isBlankIdentifier(td.Ident) {
+		return
+	}
+	g := &genericType{decl: td, tc: tc, pkg: pkg, methods: methods}
+	tc.assignScope(td.Ident.Name, &typeInfo{value: g}, td.Ident, nil)
+}
+
+// useImports marks as used the imported packages referred by the generic
+// declaration decl. The body of a generic function is checked only when the
+// function is instantiated, so the imported packages used only in generic
+// declarations are never reported as unused.
+func (tc *typechecker) useImports(decl ast.Node) {
+	var inspect func(node ast.Node) bool
+	inspect = func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Call:
+			// Walk does not visit the function of a call.
+			astutil.Inspect(n.Func, inspect)
+		case *ast.Selector:
+			if ident, ok := n.Expr.(*ast.Identifier); ok {
+				if _, _, ok := tc.scopes.LookupImport(ident.Name); ok {
+					tc.scopes.Use(ident.Name)
+				}
+			}
+		}
+		return true
+	}
+	var params []*ast.Parameter
+	switch decl := decl.(type) {
+	case *ast.Func:
+		params = decl.TypeParams
+		if decl.Recv != nil {
+			astutil.Inspect(decl.Recv.Type, inspect)
+		}
+		astutil.Inspect(decl.Type, inspect)
+		astutil.Inspect(decl.Body, inspect)
+	case *ast.TypeDeclaration:
+		params = decl.TypeParams
+		astutil.Inspect(decl.Type, inspect)
+	}
+	for _, p := range params {
+		if p.Type != nil {
+			astutil.Inspect(p.Type, inspect)
+		}
+	}
+}
+
+// genericOf returns the generic function or type, as a *genericFunc or
+// *genericType value, referred by expr. If expr does not refer to a generic
+// function or type, it returns nil.
+func (tc *typechecker) genericOf(expr ast.Expression) interface{} {
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		ti, _, ok := tc.scopes.Lookup(e.Name)
+		if !ok || !ti.isGeneric() {
+			return nil
+		}
+	case *ast.Selector:
+		ident, ok := e.Expr.(*ast.Identifier)
+		if !ok {
+			return nil
+		}
+		pkg, _, ok := tc.scopes.Lookup(ident.Name)
+		if !ok || !pkg.IsPackage() {
+			return nil
+		}
+		info, ok := pkg.value.(*packageInfo)
+		if !ok {
+			return nil
+		}
+		if ti, ok := info.Declarations[e.Ident]; !ok || !ti.isGeneric() {
+			return nil
+		}
+	default:
+		return nil
+	}
+	ti := tc.typeof(expr, false)
+	tc.compilation.typeInfos[expr] = ti
+	return ti.value
+}
+
+// checkInstantiation checks the instantiation, with type arguments args, of
+// the generic function or type expr. If expr is not a generic function or
+// type, it returns nil and false.
+func (tc *typechecker) checkInstantiation(node ast.Node, expr ast.Expression, args []ast.Expression) (*typeInfo, bool) {
+	g := tc.genericOf(expr)
+	if g == nil {
+		return nil, false
+	}
+	typeArgs := make([]reflect.Type, len(args))
+	for i, arg := range args {
+		typeArgs[i] = tc.checkType(arg).Type
+	}
+	switch g := g.(type) {
+	case *genericFunc:
+		if n := len(g.decl.TypeParams); len(typeArgs) != n {
+			if len(typeArgs) < n {
+				panic(tc.errorf(node, "cannot use generic function %s without instantiation", expr))
+			}
+			panic(tc.errorf(args[n], "got %d type arguments but %s has %d type parameters", len(args), expr, n))
+		}
+		inst := tc.instantiateFunc(node, g, typeArgs)
+		return &typeInfo{Type: inst.typ, value: inst}, true
+	case *genericType:
+		if n := len(g.decl.TypeParams); len(typeArgs) != n {
+			if len(typeArgs) < n {
+				panic(tc.errorf(node, "not enough type arguments for type %s: have %d, want %d", expr, len(args), n))
+			}
+			panic(tc.errorf(args[n], "too many type arguments for type %s: have %d, want %d", expr, len(args), n))
+		}
+		typ := tc.instantiateType(node, g, typeArgs)
+		return &typeInfo{Type: typ, Properties: propertyIsType}, true
+	}
+	return nil, false
+}
+
+// enterGeneric prepares tc to check the declaration decl of a generic
+// function or type, or of a method of a generic type, with the type
+// parameters bound as in bindings. It returns a function that restores the
+// previous state of tc.
+func (tc *typechecker) enterGeneric(decl ast.Node, bindings map[string]scopeName) func() {
+	file := tc.file
+	s := tc.scopes.s
+	typeParams := tc.scopes.typeParams
+	ancestors := tc.ancestors
+	terminating := tc.terminating
+	iota := tc.iota
+	withinUsingAffectedStmt := tc.withinUsingAffectedStmt
+	toBeEmitted := tc.toBeEmitted
+	tc.scopes.s = s[:4:4]
+	tc.scopes.typeParams = bindings
+	tc.ancestors = nil
+	tc.terminating = false
+	tc.iota = -1
+	tc.withinUsingAffectedStmt = false
+	tc.toBeEmitted = true
+	tc.setFile(tc.fileOf(decl))
+	tc.compilation.instantiationDepth++
+	return func() {
+		tc.compilation.instantiationDepth--
+		tc.setFile(file)
+		tc.scopes.s = s
+		tc.scopes.typeParams = typeParams
+		tc.ancestors = ancestors
+		tc.terminating = terminating
+		tc.iota = iota
+		tc.withinUsingAffectedStmt = withinUsingAffectedStmt
+		tc.toBeEmitted = toBeEmitted
+	}
+}
+
+// bindTypeParams returns the bindings of the type parameters params to the
+// type arguments typeArgs.
+func bindTypeParams(params []*ast.Identifier, typeArgs []reflect.Type) map[string]scopeName {
+	bindings := make(map[string]scopeName, len(params))
+	for i, p := range params {
+		if isBlankIdentifier(p) {
+			continue
+		}
+		ti := &typeInfo{Type: typeArgs[i], Properties: propertyIsType}
+		bindings[p.Name] = scopeName{ti: ti, decl: p, used: true}
+	}
+	return bindings
+}
+
+// typeParamNames returns the names of the type parameters params.
+func typeParamNames(params []*ast.Parameter) []*ast.Identifier {
+	names := make([]*ast.Identifier, len(params))
+	for i, p := range params {
+		names[i] = p.Ident
+	}
+	return names
+}
+
+// instanceName returns the name of the instance of the generic function or
+// type with the given name and type arguments.
+func instanceName(name string, typeArgs []reflect.Type) string {
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('[')
+	for i, t := range typeArgs {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(t.String())
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// sameTypes reports whether the type lists t1 and t2 are identical.
+func sameTypes(t1, t2 []reflect.Type) bool {
+	if len(t1) != len(t2) {
+		return false
+	}
+	for i := range t1 {
+		if t1[i] != t2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkTypeArgs checks that the type arguments typeArgs, of the
+// instantiation at node, satisfy the constraints of the type parameters
+// params of the generic declaration decl. gtc is the type checker of decl.
+func (tc *typechecker) checkTypeArgs(gtc *typechecker, decl, node ast.Node, params []*ast.Parameter, typeArgs []reflect.Type) {
+	restore := gtc.enterGeneric(decl, bindTypeParams(typeParamNames(params), typeArgs))
+	for i, t := range typeArgs {
+		expr := paramType(params, i)
+		c := gtc.checkConstraint(expr)
+		if ok, reason := gtc.satisfies(t, c); !ok {
+			restore()
+			panic(tc.errorf(node, "%s does not satisfy %s%s", t, expr, reason))
+		}
+	}
+	restore()
+}
+
+// satisfies reports whether the type t satisfies the constraint c. If it
+// does not, it also returns the reason, if any, to append to the error
+// message.
+func (tc *typechecker) satisfies(t reflect.Type, c *constraint) (bool, string) {
+	if c.terms != nil {
+		found := false
+		for _, term := range c.terms {
+			if term.typ == t || term.tilde && term.typ == tc.underlying(t) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			if len(c.terms) == 0 {
+				return false, " (empty type set)"
+			}
+			return false, " (" + t.String() + " missing in " + c.String() + ")"
+		}
+	}
+	if c.methods != nil && !types.Implements(t, c.methods) {
+		return false, " (missing method " + types.MissingMethod(t, c.methods) + ")"
+	}
+	if c.comparable && !t.Comparable() {
+		return false, ""
+	}
+	return true, ""
+}
+
+// underlying returns the underlying type of t.
+func (tc *typechecker) underlying(t reflect.Type) reflect.Type {
+	for {
+		d, ok := types.Definition(t)
+		if !ok {
+			break
+		}
+		t = d
+	}
+	if t.Name() == "" {
+		return t
+	}
+	switch k := t.Kind(); k {
+	case reflect.Array:
+		return tc.types.ArrayOf(t.Len(), t.Elem())
+	case reflect.Chan:
+		return tc.types.ChanOf(t.ChanDir(), t.Elem())
+	case reflect.Map:
+		return tc.types.MapOf(t.Key(), t.Elem())
+	case reflect.Ptr:
+		return tc.types.PtrTo(t.Elem())
+	case reflect.Slice:
+		return tc.types.SliceOf(t.Elem())
+	case reflect.Func, reflect.Interface, reflect.Struct, reflect.UnsafePointer:
+		return t
+	default:
+		return universe[k.String()].ti.Type
+	}
+}
+
+// checkConstraint checks the constraint expr and returns its type set.
+func (tc *typechecker) checkConstraint(expr ast.Expression) *constraint {
+	switch e := expr.(type) {
+	case *ast.BinaryOperator:
+		if e.Op == ast.OperatorBitOr {
+			c := &constraint{}
+			for _, operand := range []ast.Expression{e.Expr1, e.Expr2} {
+				c2 := tc.checkConstraint(operand)
+				if c2.methods != nil {
+					panic(tc.errorf(operand, "cannot use %s in union (%s contains methods)", operand, operand))
+				}
+				if c2.comparable {
+					panic(tc.errorf(operand, "cannot use comparable in union"))
+				}
+				if c2.terms == nil {
+					return &constraint{}
+				}
+				c.terms = append(c.terms, c2.terms...)
+			}
+			return c
+		}
+	case *ast.UnaryOperator:
+		if e.Op == ast.OperatorTilde {
+			t := tc.checkType(e.Expr).Type
+			if u := tc.underlying(t); u != t {
+				panic(tc.errorf(expr, "invalid use of ~ (underlying type of %s is %s)", e.Expr, u))
+			}
+			if t.Kind() == reflect.Interface {
+				panic(tc.errorf(expr, "invalid use of ~ (%s is an interface)", e.Expr))
+			}
+			return &constraint{terms: []constraintTerm{{tilde: true, typ: t}}}
+		}
+	}
+	ti := tc.typeof(expr, true)
+	tc.checkNotGeneric(expr, ti)
+	if !ti.IsType() {
+		panic(tc.errorf(expr, "%s is not a type", expr))
+	}
+	if c, ok := ti.value.(*constraint); ok {
+		cc := *c
+		return &cc
+	}
+	if ti.Type.Kind() == reflect.Interface {
+		if ti.Type.NumMethod() == 0 {
+			return &constraint{}
+		}
+		return &constraint{methods: ti.Type}
+	}
+	return &constraint{terms: []constraintTerm{{typ: ti.Type}}}
+}
+
+// instantiateFunc instantiates the generic function g with the type
+// arguments typeArgs at node, and returns the instance.
+func (tc *typechecker) instantiateFunc(node ast.Node, g *genericFunc, typeArgs []reflect.Type) *funcInstance {
+	for _, inst := range g.instances {
+		if sameTypes(inst.typeArgs, typeArgs) {
+			return inst
+		}
+	}
+	if tc.compilation.instantiationDepth >= maxInstantiationDepth {
+		panic(tc.errorf(node, "instantiation cycle in %s", g.decl.Ident))
+	}
+	gtc := g.tc
+	fn := astutil.CloneNode(g.decl).(*ast.Func)
+	tc.checkTypeArgs(gtc, g.decl, node, fn.TypeParams, typeArgs)
+	bindings := bindTypeParams(typeParamNames(fn.TypeParams), typeArgs)
+	restore := gtc.enterGeneric(g.decl, bindings)
+	defer restore()
+	fn.TypeParams = nil
+	fn.Ident.Name = instanceName(fn.Ident.Name, typeArgs)
+	for i := len(g.instances); ; i++ {
+		found := false
+		for _, inst := range g.instances {
+			if inst.decl.Ident.Name == fn.Ident.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+		fn.Ident.Name = instanceName(g.decl.Ident.Name, typeArgs) + "#" + strconv.Itoa(i)
+	}
+	typ := gtc.checkType(fn.Type).Type
+	inst := &funcInstance{typeArgs: typeArgs, decl: fn, pkg: g.pkg, typ: typ}
+	g.instances = append(g.instances, inst)
+	g.pkg.Declarations = append(g.pkg.Declarations, fn)
+	if g.pkg.Files != nil {
+		g.pkg.Files[fn] = g.pkg.Files[g.decl]
+	}
+	tc.compilation.instanceBodies = append(tc.compilation.instanceBodies, func() {
+		restore := gtc.enterGeneric(g.decl, bindings)
+		defer restore()
+		gtc.checkFunc(fn)
+	})
+	return inst
+}
+
+// instantiateType instantiates the generic type g with the type arguments
+// typeArgs at node, and returns the instance type.
+func (tc *typechecker) instantiateType(node ast.Node, g *genericType, typeArgs []reflect.Type) reflect.Type {
+	for _, inst := range g.instances {
+		if sameTypes(inst.typeArgs, typeArgs) {
+			return inst.typ
+		}
+	}
+	if tc.compilation.instantiationDepth >= maxInstantiationDepth {
+		panic(tc.errorf(node, "instantiation cycle in %s", g.decl.Ident))
+	}
+	gtc := g.tc
+	td := astutil.CloneNode(g.decl).(*ast.TypeDeclaration)
+	tc.checkTypeArgs(gtc, g.decl, node, td.TypeParams, typeArgs)
+	restore := gtc.enterGeneric(g.decl, bindTypeParams(typeParamNames(td.TypeParams), typeArgs))
+	defer restore()
+	typ := gtc.checkType(td.Type).Type
+	defType := gtc.types.DefinedOf(instanceName(td.Ident.Name, typeArgs), typ)
+	if defType.Kind() == reflect.Struct {
+		gtc.structDeclPkg[defType] = gtc.path
+	}
+	g.instances = append(g.instances, &typeInstance{typeArgs: typeArgs, typ: defType})
+	for _, m := range g.methods {
+		gtc.instantiateMethod(g, m, typeArgs)
+	}
+	return defType
+}
+
+// instantiateMethod instantiates the method m of the generic type g with
+// the type arguments typeArgs.
+func (tc *typechecker) instantiateMethod(g *genericType, m *ast.Func, typeArgs []reflect.Type) {
+	fn := astutil.CloneNode(m).(*ast.Func)
+	params := receiverTypeParams(fn.Recv)
+	if len(params) != len(typeArgs) {
+		panic(tc.errorf(fn.Recv.Type, "got %d type parameters, but receiver base type declares %d", len(params), len(typeArgs)))
+	}
+	bindings := bindTypeParams(params, typeArgs)
+	restore := tc.enterGeneric(m, bindings)
+	tc.checkMethodDeclaration(fn)
+	restore()
+	g.pkg.Declarations = append(g.pkg.Declarations, fn)
+	if g.pkg.Files != nil {
+		g.pkg.Files[fn] = g.pkg.Files[m]
+	}
+	tc.compilation.instanceBodies = append(tc.compilation.instanceBodies, func() {
+		restore := tc.enterGeneric(m, bindings)
+		defer restore()
+		tc.checkFunc(fn)
+	})
+}
+
+// checkInstanceBodies checks the bodies of the instantiated generic functions
+// and methods that have not yet been checked.
+func (tc *typechecker) checkInstanceBodies() {
+	for len(tc.compilation.instanceBodies) > 0 {
+		check := tc.compilation.instanceBodies[0]
+		tc.compilation.instanceBodies = tc.compilation.instanceBodies[1:]
+		check()
+	}
+}
+
+// inferTypeArgs infers the type arguments of the call expr of a generic
+// function, instantiates the function and stores its type info as the type
+// info of expr.Func. If expr.Func is not a generic function, or all the type
+// arguments are explicit, it does nothing.
+func (tc *typechecker) inferTypeArgs(expr *ast.Call) {
+
+	var explicit []ast.Expression
+	fun := expr.Func
+	switch f := fun.(type) {
+	case *ast.Index:
+		fun = f.Expr
+		explicit = []ast.Expression{f.Index}
+	case *ast.Instantiation:
+		fun = f.Expr
+		explicit = f.TypeArgs
+	}
+	g, ok := tc.genericOf(fun).(*genericFunc)
+	if !ok {
+		return
+	}
+	params := g.decl.TypeParams
+	if len(explicit) >= len(params) {
+		return
+	}
+
+	inf := &inference{
+		tc:       tc,
+		g:        g,
+		params:   make(map[string]int, len(params)),
+		typeArgs: make([]reflect.Type, len(params)),
+	}
+	for i, p := range params {
+		inf.params[p.Ident.Name] = i
+	}
+	for i, arg := range explicit {
+		inf.typeArgs[i] = tc.checkType(arg).Type
+	}
+
+	// Parameters of the generic function.
+	ft := g.decl.Type
+	in := make([]ast.Expression, len(ft.Parameters))
+	for i := len(ft.Parameters) - 1; i >= 0; i-- {
+		in[i] = ft.Parameters[i].Type
+		if in[i] == nil {
+			in[i] = in[i+1]
+		}
+	}
+
+	// Arguments of the call.
+	args := expr.Args
+	var argTypes []*typeInfo
+	if len(args) == 1 && len(in) > 1 && !expr.IsVariadic {
+		if call, ok := args[0].(*ast.Call); ok {
+			argTypes = tc.checkCallExpression(call)
+			args = make([]ast.Expression, len(argTypes))
+			for i := range args {
+				args[i] = call
+			}
+		}
+	}
+	if argTypes == nil {
+		argTypes = make([]*typeInfo, len(args))
+		for i, arg := range args {
+			argTypes[i] = tc.checkExpr(arg)
+		}
+	}
+	last := len(in) - 1
+	paramOf := func(i int) (ast.Expression, bool) {
+		if ft.IsVariadic && i >= last {
+			if expr.IsVariadic {
+				return in[last], true
+			}
+			return in[last], false
+		}
+		if i < len(in) {
+			return in[i], false
+		}
+		return nil, false
+	}
+
+	// Infer from the typed arguments.
+	for i, arg := range args {
+		ti := argTypes[i]
+		if ti.Untyped() || ti.Nil() {
+			continue
+		}
+		param, slice := paramOf(i)
+		if param == nil {
+			continue
+		}
+		t := ti.Type
+		if slice {
+			if t.Kind() != reflect.Slice {
+				continue
+			}
+			t = t.Elem()
+		}
+		if !inf.unify(param, t) {
+			p := inf.conflict
+			panic(tc.errorf(arg, "type %s of %s does not match inferred type %s for %s",
+				ti.Type, arg, inf.typeArgs[p], params[p].Ident))
+		}
+	}
+	inf.inferFromConstraints()
+
+	// Infer from the untyped constant arguments.
+	defaults := make([]reflect.Type, len(params))
+	for i := range args {
+		ti := argTypes[i]
+		if !ti.IsUntypedConstant() {
+			continue
+		}
+		param, slice := paramOf(i)
+		if ident, ok := param.(*ast.Identifier); ok && !slice {
+			if p, ok := inf.params[ident.Name]; ok && inf.typeArgs[p] == nil {
+				switch {
+				case defaults[p] == nil:
+					defaults[p] = ti.Type
+				case ti.IsNumeric() && isNumeric(defaults[p].Kind()):
+					if ti.Type.Kind() > defaults[p].Kind() {
+						defaults[p] = ti.Type
+					}
+				case ti.Type != defaults[p]:
+					panic(tc.errorf(args[i], "default type %s of %s does not match inferred type %s for %s",
+						ti.Type, args[i], defaults[p], params[p].Ident))
+				}
+			}
+		}
+	}
+	for p, t := range defaults {
+		if t != nil {
+			inf.typeArgs[p] = t
+		}
+	}
+	inf.inferFromConstraints()
+
+	for i, t := range inf.typeArgs {
+		if t == nil {
+			panic(tc.errorf(expr, "in call to %s, cannot infer %s", fun, params[i].Ident))
+		}
+	}
+
+	inst := tc.instantiateFunc(expr.Func, g, inf.typeArgs)
+	tc.compilation.typeInfos[expr.Func] = &typeInfo{Type: inst.typ, value: inst}
+}
+
+// inference represents the inference of the type arguments of a call to a
+// generic function.
+type inference struct {
+	tc       *typechecker
+	g        *genericFunc
+	params   map[string]int
+	typeArgs []reflect.Type
+	conflict int
+}
+
+// unify unifies the type expression expr, in the declaration of the generic
+// function, with the type t, inferring the type arguments of the type
+// parameters that appear in expr. It returns false if a type parameter has
+// already been inferred with a different type, setting inf.conflict to the
+// index of the type parameter.
+func (inf *inference) unify(expr ast.Expression, t reflect.Type) bool {
+	if ident, ok := expr.(*ast.Identifier); ok {
+		if p, ok := inf.params[ident.Name]; ok {
+			if inf.typeArgs[p] == nil {
+				inf.typeArgs[p] = t
+				return true
+			}
+			if inf.typeArgs[p] != t {
+				inf.conflict = p
+				return false
+			}
+		}
+		return true
+	}
+	switch e := expr.(type) {
+	case *ast.Index:
+		return inf.unifyInstance(e.Expr, []ast.Expression{e.Index}, t)
+	case *ast.Instantiation:
+		return inf.unifyInstance(e.Expr, e.TypeArgs, t)
+	}
+	if t.Name() != "" {
+		t = inf.tc.underlying(t)
+	}
+	switch e := expr.(type) {
+	case *ast.UnaryOperator:
+		if e.Op == ast.OperatorPointer && t.Kind() == reflect.Ptr {
+			return inf.unify(e.Expr, t.Elem())
+		}
+	case *ast.SliceType:
+		if t.Kind() == reflect.Slice {
+			return inf.unify(e.ElementType, t.Elem())
+		}
+	case *ast.ArrayType:
+		if t.Kind() == reflect.Array {
+			return inf.unify(e.ElementType, t.Elem())
+		}
+	case *ast.MapType:
+		if t.Kind() == reflect.Map {
+			return inf.unify(e.KeyType, t.Key()) && inf.unify(e.ValueType, t.Elem())
+		}
+	case *ast.ChanType:
+		if t.Kind() == reflect.Chan {
+			return inf.unify(e.ElementType, t.Elem())
+		}
+	case *ast.FuncType:
+		if t.Kind() != reflect.Func || len(e.Parameters) != t.NumIn() || len(e.Result) != t.NumOut() {
+			return true
+		}
+		for i := len(e.Parameters) - 1; i >= 0; i-- {
+			typ := t.In(i)
+			if e.IsVariadic && i == len(e.Parameters)-1 {
+				typ = typ.Elem()
+			}
+			if !inf.unify(paramType(e.Parameters, i), typ) {
+				return false
+			}
+		}
+		for i := range e.Result {
+			if !inf.unify(paramType(e.Result, i), t.Out(i)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// unifyInstance unifies the instantiation, with type arguments args, of the
+// generic type expr with the type t.
+func (inf *inference) unifyInstance(expr ast.Expression, args []ast.Expression, t reflect.Type) bool {
+	var ti *typeInfo
+	gtc := inf.g.tc
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		ti = gtc.scopes.s[3].names[e.Name].ti
+	case *ast.Selector:
+		if ident, ok := e.Expr.(*ast.Identifier); ok {
+			// Packages are declared in the file block of the generic function.
+			pkg := gtc.scopes.s[3].names[ident.Name].ti
+			if gtc.files != nil {
+				pkg = gtc.scopes.files[gtc.fileOf(inf.g.decl)][ident.Name].ti
+			}
+			if pkg != nil && pkg.IsPackage() {
+				if info, ok := pkg.value.(*packageInfo); ok {
+					ti = info.Declarations[e.Ident]
+				}
+			}
+		}
+	}
+	if ti == nil {
+		return true
+	}
+	g, ok := ti.value.(*genericType)
+	if !ok {
+		return true
+	}
+	for _, inst := range g.instances {
+		if inst.typ == t && len(inst.typeArgs) == len(args) {
+			for i, arg := range args {
+				if !inf.unify(arg, inst.typeArgs[i]) {
+					return false
+				}
+			}
+			break
+		}
+	}
+	return true
+}
+
+// inferFromConstraints infers the type arguments from the core types of the
+// constraints of the type parameters, for example the element type E of a
+// type parameter S with constraint ~[]E.
+func (inf *inference) inferFromConstraints() {
+	params := inf.g.decl.TypeParams
+	for changed := true; changed; {
+		changed = false
+		for i := range params {
+			t := inf.typeArgs[i]
+			if t == nil {
+				continue
+			}
+			core := paramType(params, i)
+			tilde := false
+			if op, ok := core.(*ast.UnaryOperator); ok && op.Op == ast.OperatorTilde {
+				core = op.Expr
+				tilde = true
+			}
+			switch core.(type) {
+			case *ast.Identifier, *ast.Selector, *ast.Interface, *ast.BinaryOperator:
+				continue
+			}
+			if tilde {
+				t = inf.tc.underlying(t)
+			}
+			n := 0
+			for _, t := range inf.typeArgs {
+				if t != nil {
+					n++
+				}
+			}
+			if inf.unify(core, t) {
+				for _, t := range inf.typeArgs {
+					if t != nil {
+						n--
+					}
+				}
+				changed = n < 0
+			}
+		}
+	}
+}
+
+// paramType returns the type of the i-th parameter in params. Parameters that
+// share the type with the next one have a nil type.
+func paramType(params []*ast.Parameter, i int) ast.Expression {
+	for ; params[i].Type == nil; i++ {
+	}
+	return params[i].Type
+}
@@ -11,6 +11,8 @@ import (
 	"strings"
 
 	"github.com/open2b/scriggo/ast"
+	"github.com/open2b/scriggo/internal/compiler/types"
+	"github.com/open2b/scriggo/internal/runtime"
 	"github.com/open2b/scriggo/native"
 )
 
@@ -153,6 +155,8 @@ type packageInfo struct {
 	DeclarationNodes map[string]*ast.Identifier
 	IndirectVars     map[*ast.Identifier]bool
 	TypeInfos        map[ast.Node]*typeInfo
+	Uses             map[ast.Expression]*ast.Identifier
+	UsedImports      map[*ast.Import]bool
 }
 
 func depsOf(name string, deps packageDeclsDeps) []*ast.Identifier {
@@ -253,6 +257,7 @@ func sortDeclarations(pkg *ast.Package) error {
 	vars := []*ast.Var{}
 	imports := []*ast.Import{}
 	funcs := []*ast.Func{}
+	methods := []*ast.Func{}
 
 	// Fragments global declarations.
 	for _, decl := range pkg.Declarations {
@@ -262,23 +267,35 @@ func sortDeclarations(pkg *ast.Package) error {
 		case *ast.Import:
 			imports = append(imports, decl)
 		case *ast.Func:
-			funcs = append(funcs, decl)
+			// Methods do not take part in the dependency analysis because
+			// they are not referenced by name.
+			if decl.Recv != nil {
+				methods = append(methods, decl)
+			} else {
+				funcs = append(funcs, decl)
+			}
 		case *ast.Const:
-			if len(decl.Rhs) == 0 {
-				for i := range decl.Lhs {
-					consts = append(consts, ast.NewConst(decl.Pos(), decl.Lhs[i:i+1], decl.Type, nil, decl.Index))
+			for i := range decl.Lhs {
+				var rhs []ast.Expression
+				if len(decl.Rhs) > 0 {
+					rhs = decl.Rhs[i : i+1]
 				}
-			} else {
-				for i := range decl.Lhs {
-					consts = append(consts, ast.NewConst(decl.Pos(), decl.Lhs[i:i+1], decl.Type, decl.Rhs[i:i+1], decl.Index))
+				c := ast.NewConst(decl.Pos(), decl.Lhs[i:i+1], decl.Type, rhs, decl.Index)
+				if pkg.Files != nil {
+					pkg.Files[c] = pkg.Files[decl]
 				}
+				consts = append(consts, c)
 			}
 		case *ast.TypeDeclaration:
 			types = append(types, decl)
 		case *ast.Var:
 			if len(decl.Lhs) == len(decl.Rhs) {
 				for i := range decl.Lhs {
-					vars = append(vars, ast.NewVar(decl.Pos(), decl.Lhs[i:i+1], decl.Type, decl.Rhs[i:i+1]))
+					v := ast.NewVar(decl.Pos(), decl.Lhs[i:i+1], decl.Type, decl.Rhs[i:i+1])
+					if pkg.Files != nil {
+						pkg.Files[v] = pkg.Files[decl]
+					}
+					vars = append(vars, v)
 				}
 			} else {
 				vars = append(vars, decl)
@@ -498,12 +515,65 @@ varsLoop:
 	for _, f := range funcs {
 		sorted = append(sorted, f)
 	}
+	for _, m := range methods {
+		sorted = append(sorted, m)
+	}
 	pkg.Declarations = sorted
 
 	return nil
 
 }
 
+// checkMethodDeclaration checks the receiver and the type of the method
+// declaration f and adds the method to the receiver base type.
+func (tc *typechecker) checkMethodDeclaration(f *ast.Func) {
+	base := f.Recv.Type
+	pointer := false
+	if op, ok := base.(*ast.UnaryOperator); ok && op.Op == ast.OperatorPointer {
+		base = op.Expr
+		pointer = true
+	}
+	// Instance of a method of a generic type.
+	switch b := base.(type) {
+	case *ast.Index:
+		base = b.Expr
+	case *ast.Instantiation:
+		base = b.Expr
+	}
+	ident, ok := base.(*ast.Identifier)
+	if !ok {
+		if _, ok := base.(*ast.Selector); ok {
+			panic(tc.errorf(f.Recv.Type, "cannot define new methods on non-local type %s", base))
+		}
+		panic(tc.errorf(f.Recv.Type, "invalid receiver %s", f.Recv.Type))
+	}
+	ti := tc.checkType(f.Recv.Type)
+	typ := ti.Type
+	if pointer {
+		typ = typ.Elem()
+	}
+	if _, ok := types.Definition(typ); !ok {
+		panic(tc.errorf(f.Recv.Type, "cannot define new methods on non-local type %s", ident))
+	}
+	if k := typ.Kind(); k == reflect.Ptr || k == reflect.Interface {
+		panic(tc.errorf(f.Recv.Type, "invalid receiver type %s (pointer or interface type)", ident))
+	}
+	methodType := tc.checkType(f.Type).Type
+	name := f.Ident.Name
+	if isBlankIdentifier(f.Ident) {
+		return
+	}
+	if _, ok := types.MethodByName(typ, name); ok {
+		panic(tc.errorf(f.Ident, "method %s.%s already declared", ident, name))
+	}
+	if typ.Kind() == reflect.Struct {
+		if _, ok := typ.FieldByName(name); ok {
+			panic(tc.errorf(f.Ident, "field and method with the same name %s", name))
+		}
+	}
+	tc.types.AddMethod(typ, &runtime.Method{Name: name, Type: methodType, Pointer: pointer})
+}
+
 // checkPackage type checks a package.
 //
 // extendingFile indicates whether the package pkg was originally a template
@@ -526,10 +596,12 @@ func checkPackage(compilation *compilation, pkg *ast.Package, path string, impor
 	}()
 
 	tc := newTypechecker(compilation, path, opts, importer)
+	tc.files = pkg.Files
 
 	// Check package level names for "init" and "main"
 	// and check that constant declarations are balanced.
 	for _, decl := range pkg.Declarations {
+		tc.setFile(tc.fileOf(decl))
 		switch decl := decl.(type) {
 		case *ast.Var:
 			for _, d := range decl.Lhs {
@@ -561,6 +633,7 @@ func checkPackage(compilation *compilation, pkg *ast.Package, path string, impor
 		err := sortDeclarations(pkg)
 		if err != nil {
 			loopErr := err.(initLoopError)
+			tc.setFile(tc.fileOf(loopErr.node))
 			return tc.errorf(loopErr.node, loopErr.msg)
 		}
 		compilation.alreadySortedPkgs[pkg] = true
@@ -569,6 +642,7 @@ func checkPackage(compilation *compilation, pkg *ast.Package, path string, impor
 	// First: import packages.
 	for _, d := range pkg.Declarations {
 		if d, ok := d.(*ast.Import); ok {
+			tc.setFile(tc.fileOf(d))
 			err := tc.checkImport(d)
 			if err != nil {
 				return err
@@ -576,9 +650,19 @@ func checkPackage(compilation *compilation, pkg *ast.Package, path string, impor
 		}
 	}
 
+	// Remove the generic functions, and the methods of the generic types,
+	// from the declarations. They are checked only when instantiated.
+	genericMethods := tc.extractGenerics(pkg)
+
 	// Second: check all type declarations.
 	for _, d := range pkg.Declarations {
 		if td, ok := d.(*ast.TypeDeclaration); ok {
+			tc.setFile(tc.fileOf(td))
+			if td.TypeParams != nil {
+				tc.declareGenericType(pkg, td, genericMethods[td.Ident.Name])
+				delete(genericMethods, td.Ident.Name)
+				continue
+			}
 			name, ti := tc.checkTypeDeclaration(td)
 			if ti != nil {
 				tc.assignScope(name, ti, td.Ident, nil)
@@ -586,13 +670,37 @@ func checkPackage(compilation *compilation, pkg *ast.Package, path string, impor
 		}
 	}
 
+	// Check that the base types of the methods with type parameters are
+	// generic types.
+	var method *ast.Func
+	for _, methods := range genericMethods {
+		for _, m := range methods {
+			if method == nil || m.Recv.Type.Pos().Start < method.Recv.Type.Pos().Start {
+				method = m
+			}
+		}
+	}
+	if method != nil {
+		tc.setFile(tc.fileOf(method))
+		name, _ := receiverBaseName(method.Recv)
+		if _, ok := tc.scopes.FilePackage(name); ok {
+			return tc.errorf(method.Recv.Type, "%s is not a generic type", name)
+		}
+		return tc.errorf(method.Recv.Type, "undefined: %s", name)
+	}
+
 	// Defines functions in file/package block before checking all
 	// declarations.
 	for _, d := range pkg.Declarations {
 		if f, ok := d.(*ast.Func); ok {
+			tc.setFile(tc.fileOf(f))
 			if f.Body == nil {
 				return tc.errorf(f.Ident.Pos(), "missing function body")
 			}
+			if f.Recv != nil {
+				tc.checkMethodDeclaration(f)
+				continue
+			}
 			if f.Ident.Name == "init" || f.Ident.Name == "main" {
 				if len(f.Type.Parameters) > 0 || len(f.Type.Result) > 0 {
 					return tc.errorf(f.Ident, "func %s must have no arguments and no return values", f.Ident.Name)
@@ -608,9 +716,6 @@ func checkPackage(compilation *compilation, pkg *ast.Package, path string, impor
 				// Do not add 'init' and '_' functions to the file/package block.
 				continue
 			}
-			if _, ok := tc.scopes.FilePackage(f.Ident.Name); ok {
-				return tc.errorf(f.Ident, "%s redeclared in this block", f.Ident.Name)
-			}
 			ti := &typeInfo{Type: funcType}
 			if f.Type.Macro {
 				ti.Properties |= propertyIsMacroDeclaration
@@ -618,12 +723,15 @@ func checkPackage(compilation *compilation, pkg *ast.Package, path string, impor
 					ti.Properties |= propertyMacroDeclaredInFileWithExtends
 				}
 			}
-			tc.scopes.Declare(f.Ident.Name, ti, f.Ident, nil)
+			if !tc.scopes.Declare(f.Ident.Name, ti, f.Ident, nil) {
+				return tc.errorf(f.Ident, "%s redeclared in this block", f.Ident.Name)
+			}
 		}
 	}
 
 	// Type check and defined functions, variables and constants.
 	for _, d := range pkg.Declarations {
+		tc.setFile(tc.fileOf(d))
 		switch d := d.(type) {
 		case *ast.Func:
 			tc.checkFunc(d)
@@ -634,9 +742,13 @@ func checkPackage(compilation *compilation, pkg *ast.Package, path string, impor
 		}
 	}
 
+	// Check the bodies of the instantiated generic functions and methods.
+	tc.checkInstanceBodies()
+
 	if tc.opts.mod != templateMod {
 		// Check that the imported packages have been used.
 		if node := tc.scopes.UnusedImport(); node != nil {
+			tc.setFile(tc.fileOf(node))
 			var s string
 			if node.Ident == nil || node.Ident.Name == "." {
 				s = fmt.Sprintf("%q", node.Path)
@@ -649,7 +761,7 @@ func checkPackage(compilation *compilation, pkg *ast.Package, path string, impor
 
 	if pkg.Name == "main" {
 		if _, ok := tc.scopes.FilePackage("main"); !ok {
-			return tc.errorf(new(ast.Position), "function main is undeclared in the main package")
+			return checkError(path, new(ast.Position), "function main is undeclared in the main package")
 		}
 	}
 
@@ -22,6 +22,14 @@ type scopes struct {
 	s           []scope
 	path        string
 	allowUnused bool
+	// typeParams are the type parameters, bound to the type arguments, of
+	// the instance of a generic function or type that is being checked.
+	typeParams map[string]scopeName
+	// files are the file blocks of a program package, by file path, and file
+	// is the current file block. Only the imported names are declared in the
+	// file blocks. files is nil for templates and scripts.
+	files map[string]map[string]scopeName
+	file  map[string]scopeName
 }
 
 // scope is a scope.
@@ -126,10 +134,28 @@ func (scopes *scopes) Global(name string) (*typeInfo, bool) {
 // FilePackage returns the type info of name as declared in the file/package
 // block and true. Otherwise it returns nil and false.
 func (scopes *scopes) FilePackage(name string) (*typeInfo, bool) {
+	if n, ok := scopes.file[name]; ok {
+		return n.ti, true
+	}
 	n, ok := scopes.s[3].names[name]
 	return n.ti, ok
 }
 
+// SetFile sets the file block of the file with the given path as the current
+// file block. The errors are reported with this path.
+func (scopes *scopes) SetFile(path string) {
+	block, ok := scopes.files[path]
+	if !ok {
+		block = map[string]scopeName{}
+		if scopes.files == nil {
+			scopes.files = map[string]map[string]scopeName{}
+		}
+		scopes.files[path] = block
+	}
+	scopes.path = path
+	scopes.file = block
+}
+
 // Current returns the identifier of name as declared in the current scope and
 // true. Otherwise it returns nil and false.
 func (scopes *scopes) Current(name string) (*ast.Identifier, bool) {
@@ -174,6 +200,24 @@ func (scopes *scopes) Declare(name string, ti *typeInfo, decl *ast.Identifier, i
 	n := scopeName{ti: ti}
 	n.decl = decl
 	n.impor = impor
+	if c == 3 && scopes.files != nil {
+		// A name cannot be declared in both the file and package blocks.
+		if _, ok := scopes.s[3].names[name]; ok {
+			return false
+		}
+		if impor != nil {
+			if _, ok := scopes.file[name]; ok {
+				return false
+			}
+			scopes.file[name] = n
+			return true
+		}
+		for _, block := range scopes.files {
+			if _, ok := block[name]; ok {
+				return false
+			}
+		}
+	}
 	if names := scopes.s[c].names; names == nil {
 		scopes.s[c].names = map[string]scopeName{name: n}
 	} else if _, ok := names[name]; ok {
@@ -236,14 +280,36 @@ func (scopes *scopes) Lookup(name string) (*typeInfo, ast.Node, bool) {
 	return n.ti, node, i != -1
 }
 
-// LookupImport returns the import declaration that imported name and true, if
-// name has been imported, otherwise returns nil and false.
-func (scopes *scopes) LookupImport(name string) (*ast.Import, bool) {
+// Declaration returns the identifier of the declaration of name and the
+// import declaration that imported name. The identifier is nil for native
+// names and packages, and the import declaration is nil for names that have
+// not been imported.
+func (scopes *scopes) Declaration(name string) (*ast.Identifier, *ast.Import) {
+	n, _ := scopes.lookup(name, 0)
+	return n.decl, n.impor
+}
+
+// LookupImport returns the import declaration that imported name, the path
+// of its file and true, if name has been imported, otherwise returns nil, an
+// empty string and false. The path is empty for templates and scripts. For a
+// program package, if name has not been imported in the current file, it
+// looks up name in the other files.
+func (scopes *scopes) LookupImport(name string) (*ast.Import, string, bool) {
 	n, _ := scopes.lookup(name, 3)
-	if n.impor == nil {
-		return nil, false
+	if n.impor != nil {
+		if scopes.files == nil {
+			return n.impor, "", true
+		}
+		return n.impor, scopes.path, true
+	}
+	var impor *ast.Import
+	var file string
+	for path, block := range scopes.files {
+		if n, ok := block[name]; ok && (impor == nil || path < file) {
+			impor, file = n.impor, path
+		}
 	}
-	return n.impor, true
+	return impor, file, impor != nil
 }
 
 // LookupInFunc lookups name in function scopes, including the main block in
@@ -270,6 +336,14 @@ func (scopes *scopes) LookupLabel(name string) (*ast.Label, bool) {
 // start is the index of the scope from which to start the lookup.
 func (scopes *scopes) lookup(name string, start int) (scopeName, int) {
 	for i := len(scopes.s) - 1; i >= start; i-- {
+		if i == 3 {
+			if n, ok := scopes.typeParams[name]; ok {
+				return n, i
+			}
+			if n, ok := scopes.file[name]; ok {
+				return n, i
+			}
+		}
 		if n, ok := scopes.s[i].names[name]; ok {
 			return n, i
 		}
@@ -283,7 +357,11 @@ func (scopes *scopes) Use(name string) bool {
 	n, i := scopes.lookup(name, 3)
 	if i != -1 && !n.used {
 		n.used = true
-		scopes.s[i].names[name] = n
+		if i == 3 && n.impor != nil && scopes.files != nil {
+			scopes.file[name] = n
+		} else {
+			scopes.s[i].names[name] = n
+		}
 		return false
 	}
 	return true
@@ -330,11 +408,29 @@ func (scopes *scopes) UseLabel(stmt string, ident *ast.Identifier) *ast.Label {
 	return lbl.node
 }
 
-// UnusedImport returns the declaration of the first unused import, by
-// position in the source. If all imports are used, it returns nil.
+// UnusedImport returns the declaration of the first unused import, by path
+// of the file and position in the source. If all imports are used, it returns
+// nil.
 func (scopes *scopes) UnusedImport() *ast.Import {
+	var node *ast.Import
+	var file string
+	if scopes.files == nil {
+		node = unusedImport(scopes.s[3].names)
+	}
+	for path, block := range scopes.files {
+		if im := unusedImport(block); im != nil && (node == nil || path < file) {
+			node, file = im, path
+		}
+	}
+	return node
+}
+
+// unusedImport returns the declaration of the first unused import, by
+// position in the source, in the block names. If all imports are used, it
+// returns nil.
+func unusedImport(names map[string]scopeName) *ast.Import {
 	unused := map[*ast.Import]bool{}
-	for _, n := range scopes.s[3].names {
+	for _, n := range names {
 		if n.impor == nil {
 			continue
 		}
@@ -479,10 +575,12 @@ var errorType = reflect.TypeOf((*error)(nil)).Elem()
 
 // universe is the universe scope.
 var universe = map[string]scopeName{
+	"any":        {ti: &typeInfo{Type: emptyInterfaceType, Properties: propertyIsType | propertyUniverse}},
 	"append":     {ti: &typeInfo{Properties: propertyUniverse}},
 	"cap":        {ti: &typeInfo{Properties: propertyUniverse}},
 	"close":      {ti: &typeInfo{Properties: propertyUniverse}},
 	"complex":    {ti: &typeInfo{Properties: propertyUniverse}},
+	"comparable": {ti: &typeInfo{Type: emptyInterfaceType, Properties: propertyIsType | propertyUniverse, value: &constraint{comparable: true}}},
 	"copy":       {ti: &typeInfo{Properties: propertyUniverse}},
 	"delete":     {ti: &typeInfo{Properties: propertyUniverse}},
 	"imag":       {ti: &typeInfo{Properties: propertyUniverse}},
@@ -33,6 +33,15 @@ var (
 
 	mdStringerType    = reflect.TypeOf((*native.MarkdownStringer)(nil)).Elem()
 	mdEnvStringerType = reflect.TypeOf((*native.MarkdownEnvStringer)(nil)).Elem()
+
+	xmlStringerType    = reflect.TypeOf((*native.XMLStringer)(nil)).Elem()
+	xmlEnvStringerType = reflect.TypeOf((*native.XMLEnvStringer)(nil)).Elem()
+
+	yamlStringerType    = reflect.TypeOf((*native.YAMLStringer)(nil)).Elem()
+	yamlEnvStringerType = reflect.TypeOf((*native.YAMLEnvStringer)(nil)).Elem()
+
+	tomlStringerType    = reflect.TypeOf((*native.TOMLStringer)(nil)).Elem()
+	tomlEnvStringerType = reflect.TypeOf((*native.TOMLEnvStringer)(nil)).Elem()
 )
 
 // templateFileToPackage transforms a tree of a declarations file to a package
@@ -259,29 +268,8 @@ nodesLoop:
 			tc.terminating = node.Condition == nil && !tc.hasBreak[node]
 
 		case *ast.ForIn:
-			// Check range expression.
-			expr := node.Expr
-			ti := tc.checkExpr(expr)
-			if ti.Nil() {
-				panic(tc.errorf(node, "cannot range over nil"))
-			}
-			ti.setValue(nil)
 			// Replace the node with a ForRange node.
-			ipos := node.Ident.Pos()
-			blank := ast.NewIdentifier(ipos.WithEnd(ipos.Start), "_")
-			aPos := ipos.WithEnd(node.Expr.Pos().End)
-			var lhs []ast.Expression
-			switch ti.Type.Kind() {
-			default:
-				lhs = []ast.Expression{blank, node.Ident}
-			case reflect.Map:
-				lhs = []ast.Expression{node.Ident, blank}
-			case reflect.Chan:
-				lhs = []ast.Expression{node.Ident}
-			}
-			assignment := ast.NewAssignment(aPos, lhs, ast.AssignmentDeclaration, []ast.Expression{expr})
-			assignment.End = node.Expr.Pos().End
-			nodes[i] = ast.NewForRange(node.Pos(), assignment, node.Body)
+			nodes[i] = tc.forInToForRange(node)
 			continue
 
 		case *ast.ForRange:
@@ -807,6 +795,9 @@ nodesLoop:
 			if !tc.opts.allowGoStmt {
 				panic(tc.errorf(node, "\"go\" statement not available"))
 			}
+			if tc.opts.mod == templateMod {
+				tc.checkGoMacro(node, call, ti.Type)
+			}
 			tc.terminating = false
 
 		case *ast.Send:
@@ -852,6 +843,9 @@ nodesLoop:
 			tc.scopes.UseLabel("goto", node.Label)
 
 		case *ast.Label:
+			if forIn, ok := node.Statement.(*ast.ForIn); ok {
+				node.Statement = tc.forInToForRange(forIn)
+			}
 			tc.scopes.DeclareLabel(node)
 			if node.Statement != nil {
 				_ = tc.checkNodes([]ast.Node{node.Statement})
@@ -880,6 +874,9 @@ nodesLoop:
 
 			// Handle function and macro declarations in scripts and templates.
 			if fun, ok := node.(*ast.Func); ok && fun.Ident != nil && tc.opts.mod != programMod {
+				if fun.TypeParams != nil {
+					panic(tc.errorf(fun.Ident, "generic functions are only supported in programs"))
+				}
 				if fun.Type.Macro && len(fun.Type.Result) == 0 {
 					tc.makeMacroResultExplicit(fun)
 				}
@@ -1124,7 +1121,12 @@ func (tc *typechecker) checkFunc(node *ast.Func) {
 	tc.scopes.Enter(node)
 	tc.addToAncestors(node)
 
-	// Adds parameters to the function body scope.
+	// Adds the receiver and the parameters to the function body scope.
+	if recv := node.Recv; recv != nil && recv.Ident != nil && !isBlankIdentifier(recv.Ident) {
+		t := tc.compilation.typeInfos[recv.Type].Type
+		tc.scopes.Declare(recv.Ident.Name, &typeInfo{Type: t, Properties: propertyAddressable}, recv.Ident, nil)
+		tc.scopes.Use(recv.Ident.Name)
+	}
 	t := node.Type.Reflect
 	for i := 0; i < t.NumIn(); i++ {
 		param := node.Type.Parameters[i]
@@ -1326,14 +1328,30 @@ func (tc *typechecker) checkReturn(node *ast.Return) ast.Node {
 //  type Int = int
 //
 func (tc *typechecker) checkTypeDeclaration(node *ast.TypeDeclaration) (string, *typeInfo) {
-	typ := tc.checkType(node.Type)
+	if node.TypeParams != nil {
+		if tc.opts.mod != programMod {
+			panic(tc.errorf(node.Ident, "generic types are only supported in programs"))
+		}
+		panic(tc.errorf(node.Ident, "generic type cannot be declared inside a function"))
+	}
+	// The type can also be an interface that can only be used as constraint.
+	typ := tc.typeof(node.Type, true)
+	tc.checkNotGeneric(node.Type, typ)
+	if !typ.IsType() {
+		panic(tc.errorf(node.Type, "%s is not a type", node.Type))
+	}
+	tc.compilation.typeInfos[node.Type] = typ
 	if isBlankIdentifier(node.Ident) {
 		return "", nil
 	}
 	name := node.Ident.Name
 	if node.IsAliasDeclaration {
 		// Return the base type.
-		return name, &typeInfo{Type: typ.Type, Alias: node.Ident.Name, Properties: typ.Properties}
+		ti := &typeInfo{Type: typ.Type, Alias: node.Ident.Name, Properties: typ.Properties}
+		if c, ok := typ.value.(*constraint); ok {
+			ti.value = c
+		}
+		return name, ti
 	}
 	// Create a new Scriggo type.
 	defType := tc.types.DefinedOf(name, typ.Type)
@@ -1345,10 +1363,37 @@ func (tc *typechecker) checkTypeDeclaration(node *ast.TypeDeclaration) (string,
 	if defType.Kind() == reflect.Struct {
 		tc.structDeclPkg[defType] = tc.path
 	}
-	return name, &typeInfo{
+	ti := &typeInfo{
 		Type:       defType,
 		Properties: propertyIsType,
 	}
+	if c, ok := typ.value.(*constraint); ok {
+		ti.value = c
+	}
+	return name, ti
+}
+
+// checkGoMacro checks a go statement, in a template, that calls the function
+// call of type t. If t returns a value of a format type, the call is a macro
+// call whose output is rendered asynchronously in the context of the go
+// statement, so the format must be the format of the context or Markdown in
+// an HTML context.
+func (tc *typechecker) checkGoMacro(node *ast.Go, call *ast.Call, t reflect.Type) {
+	if t == nil || t.Kind() != reflect.Func || t.NumOut() != 1 {
+		return
+	}
+	out := t.Out(0)
+	for format, typ := range tc.opts.formatTypes {
+		if typ != out {
+			continue
+		}
+		ctx := node.Context
+		if ctx > ast.ContextTOML || ast.Format(ctx) != format &&
+			!(format == ast.FormatMarkdown && ctx == ast.ContextHTML) {
+			panic(tc.errorf(node, "cannot render %s (type %s) asynchronously in %s", call, formatTypeName[format], ctx))
+		}
+		return
+	}
 }
 
 // explodeUsingStatement explodes an 'using' statement.
@@ -1412,11 +1457,14 @@ func checkShow(t reflect.Type, ctx ast.Context) error {
 	switch ctx {
 	case ast.ContextText, ast.ContextTag, ast.ContextQuotedAttr, ast.ContextUnquotedAttr,
 		ast.ContextCSSString, ast.ContextJSString, ast.ContextJSONString,
-		ast.ContextTabCodeBlock, ast.ContextSpacesCodeBlock:
+		ast.ContextTabCodeBlock, ast.ContextSpacesCodeBlock,
+		ast.ContextJSStringAttr, ast.ContextCSSStringAttr, ast.ContextXMLAttr, ast.ContextXMLCDATA,
+		ast.ContextYAMLPlain, ast.ContextYAMLFlowPlain, ast.ContextYAMLDoubleQuoted, ast.ContextYAMLSingleQuoted,
+		ast.ContextYAMLBlock, ast.ContextTOMLString, ast.ContextTOMLLiteralString:
 		switch {
 		case kind == reflect.String:
 		case reflect.Bool <= kind && kind <= reflect.Complex128:
-		case ctx == ast.ContextCSSString && t == byteSliceType:
+		case (ctx == ast.ContextCSSString || ctx == ast.ContextCSSStringAttr) && t == byteSliceType:
 		case t.Implements(stringerType):
 		case t.Implements(envStringerType):
 		case t.Implements(errorType):
@@ -1436,7 +1484,7 @@ func checkShow(t reflect.Type, ctx ast.Context) error {
 		default:
 			return fmt.Errorf("cannot show type %s as HTML", t)
 		}
-	case ast.ContextCSS:
+	case ast.ContextCSS, ast.ContextCSSAttr:
 		switch {
 		case kind == reflect.String:
 		case reflect.Int <= kind && kind <= reflect.Float64:
@@ -1449,13 +1497,13 @@ func checkShow(t reflect.Type, ctx ast.Context) error {
 		default:
 			return fmt.Errorf("cannot show type %s as CSS", t)
 		}
-	case ast.ContextJS:
+	case ast.ContextJS, ast.ContextJSAttr:
 		err := checkShowJS(t, nil)
 		if err != nil {
 			return err
 		}
-	case ast.ContextJSON:
-		err := checkShowJSON(t, nil)
+	case ast.ContextJSON, ast.ContextYAML, ast.ContextTOML:
+		err := checkShowData(t, ast.Format(ctx), nil)
 		if err != nil {
 			return err
 		}
@@ -1473,6 +1521,18 @@ func checkShow(t reflect.Type, ctx ast.Context) error {
 		default:
 			return fmt.Errorf("cannot show type %s as Markdown", t)
 		}
+	case ast.ContextXML:
+		switch {
+		case kind == reflect.String:
+		case reflect.Bool <= kind && kind <= reflect.Complex128:
+		case t.Implements(stringerType):
+		case t.Implements(envStringerType):
+		case t.Implements(xmlStringerType):
+		case t.Implements(xmlEnvStringerType):
+		case t.Implements(errorType):
+		default:
+			return fmt.Errorf("cannot show type %s as XML", t)
+		}
 	default:
 		panic("unexpected context")
 	}
@@ -1538,26 +1598,35 @@ func checkShowJS(t reflect.Type, types []reflect.Type) error {
 	return nil
 }
 
-// checkShowJSON reports whether a type can be shown as JSON. It returns an
-// error if the type cannot be shown.
-func checkShowJSON(t reflect.Type, types []reflect.Type) error {
+// dataStringerTypes contains, for the JSON, YAML and TOML formats, the
+// stringer types of the format.
+var dataStringerTypes = map[ast.Format][2]reflect.Type{
+	ast.FormatJSON: {jsonStringerType, jsonEnvStringerType},
+	ast.FormatYAML: {yamlStringerType, yamlEnvStringerType},
+	ast.FormatTOML: {tomlStringerType, tomlEnvStringerType},
+}
+
+// checkShowData reports whether a type can be shown as JSON, YAML or TOML,
+// as indicated by format. It returns an error if the type cannot be shown.
+func checkShowData(t reflect.Type, format ast.Format, types []reflect.Type) error {
 	for _, typ := range types {
 		if t == typ {
 			return nil
 		}
 	}
+	stringers := dataStringerTypes[format]
 	kind := t.Kind()
 	if reflect.Bool <= kind && kind <= reflect.Float64 || kind == reflect.String ||
 		t == timeType ||
-		t.Implements(jsonStringerType) ||
-		t.Implements(jsonEnvStringerType) ||
+		t.Implements(stringers[0]) ||
+		t.Implements(stringers[1]) ||
 		t.Implements(errorType) {
 		return nil
 	}
 	switch kind {
 	case reflect.Array:
-		if err := checkShowJSON(t.Elem(), append(types, t)); err != nil {
-			return fmt.Errorf("cannot show array of %s as JSON", t.Elem())
+		if err := checkShowData(t.Elem(), format, append(types, t)); err != nil {
+			return fmt.Errorf("cannot show array of %s as %s", t.Elem(), format)
 		}
 	case reflect.Interface:
 	case reflect.Map:
@@ -1568,30 +1637,57 @@ func checkShowJSON(t reflect.Type, types []reflect.Type) error {
 		case t.Implements(stringerType):
 		case t.Implements(envStringerType):
 		default:
-			return fmt.Errorf("cannot show map with %s key as JSON", t.Key())
+			return fmt.Errorf("cannot show map with %s key as %s", t.Key(), format)
 		}
-		err := checkShowJSON(t.Elem(), append(types, t))
+		err := checkShowData(t.Elem(), format, append(types, t))
 		if err != nil {
-			return fmt.Errorf("cannot show map with %s element as JSON", t.Elem())
+			return fmt.Errorf("cannot show map with %s element as %s", t.Elem(), format)
 		}
 	case reflect.Ptr, reflect.UnsafePointer:
-		return checkShowJSON(t.Elem(), append(types, t))
+		return checkShowData(t.Elem(), format, append(types, t))
 	case reflect.Slice:
-		if err := checkShowJSON(t.Elem(), append(types, t)); err != nil {
-			return fmt.Errorf("cannot show slice of %s as JSON", t.Elem())
+		if err := checkShowData(t.Elem(), format, append(types, t)); err != nil {
+			return fmt.Errorf("cannot show slice of %s as %s", t.Elem(), format)
 		}
 	case reflect.Struct:
 		n := t.NumField()
 		for i := 0; i < n; i++ {
 			field := t.Field(i)
 			if field.PkgPath == "" {
-				if err := checkShowJSON(field.Type, append(types, t)); err != nil {
-					return fmt.Errorf("cannot show struct containing %s as JSON", field.Type)
+				if err := checkShowData(field.Type, format, append(types, t)); err != nil {
+					return fmt.Errorf("cannot show struct containing %s as %s", field.Type, format)
 				}
 			}
 		}
 	default:
-		return fmt.Errorf("cannot show type %s as JSON", t)
+		return fmt.Errorf("cannot show type %s as %s", t, format)
 	}
 	return nil
 }
+
+// forInToForRange checks the range expression of a for in statement and
+// returns the equivalent for range statement.
+func (tc *typechecker) forInToForRange(node *ast.ForIn) *ast.ForRange {
+	// Check range expression.
+	expr := node.Expr
+	ti := tc.checkExpr(expr)
+	if ti.Nil() {
+		panic(tc.errorf(node, "cannot range over nil"))
+	}
+	ti.setValue(nil)
+	ipos := node.Ident.Pos()
+	blank := ast.NewIdentifier(ipos.WithEnd(ipos.Start), "_")
+	aPos := ipos.WithEnd(node.Expr.Pos().End)
+	var lhs []ast.Expression
+	switch ti.Type.Kind() {
+	default:
+		lhs = []ast.Expression{blank, node.Ident}
+	case reflect.Map:
+		lhs = []ast.Expression{node.Ident, blank}
+	case reflect.Chan:
+		lhs = []ast.Expression{node.Ident}
+	}
+	assignment := ast.NewAssignment(aPos, lhs, ast.AssignmentDeclaration, []ast.Expression{expr})
+	assignment.End = node.Expr.Pos().End
+	return ast.NewForRange(node.Pos(), assignment, node.Body)
+}
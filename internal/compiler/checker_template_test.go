@@ -688,18 +688,18 @@ func TestJSCycles(t *testing.T) {
 
 func TestJSONCycles(t *testing.T) {
 	for i := 0; i < 1; i++ {
-		err := checkShowJSON(reflect.TypeOf(S{}), nil)
+		err := checkShowData(reflect.TypeOf(S{}), ast.FormatJSON, nil)
 		if err != nil {
 			t.Fatalf("unexpected error %q showing S", err)
 		}
-		err = checkShowJSON(reflect.TypeOf(L{}), nil)
+		err = checkShowData(reflect.TypeOf(L{}), ast.FormatJSON, nil)
 		if err == nil {
 			t.Fatalf("unexpected nil error showing L")
 		}
 		if err.Error() != "cannot show struct containing complex64 as JSON" {
 			t.Fatalf("unexpected error %q showing L", err)
 		}
-		err = checkShowJSON(reflect.TypeOf(N{}), nil)
+		err = checkShowData(reflect.TypeOf(N{}), ast.FormatJSON, nil)
 		if err != nil {
 			t.Fatalf("unexpected error %q showing N", err)
 		}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/open2b/scriggo/ast"
 	"github.com/open2b/scriggo/internal/compiler/types"
+	"github.com/open2b/scriggo/internal/runtime"
 	"github.com/open2b/scriggo/native"
 )
 
@@ -551,6 +552,19 @@ func (tc *typechecker) errTypeAssertion(typ reflect.Type, iface reflect.Type) er
 	num := iface.NumMethod()
 	for i := 0; i < num; i++ {
 		mi := iface.Method(i)
+		if _, ok := typ.(runtime.ScriggoType); ok {
+			m, ok := types.MethodByName(typ, mi.Name)
+			if !ok {
+				return fmt.Errorf("%s (missing %s method)", msg, mi.Name)
+			}
+			if m.Pointer && typ.Kind() != reflect.Ptr {
+				return fmt.Errorf("%s (%s method has pointer receiver)", msg, mi.Name)
+			}
+			if m.Type != mi.Type {
+				return fmt.Errorf("%s (wrong type for %s method)\n\t\thave %s\n\t\twant %s", msg, mi.Name, m.Type, mi.Type)
+			}
+			continue
+		}
 		mt, ok := typ.MethodByName(mi.Name)
 		if !ok {
 			ptr := tc.types.PtrTo(typ)
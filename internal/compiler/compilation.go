@@ -77,6 +77,21 @@ type compilation struct {
 	// This information must be kept here because it becomes lost after
 	// transforming the tree in case of extends.
 	extendedTrees map[string]bool
+
+	// instanceBodies are the functions that check the bodies of the
+	// instantiated generic functions and methods, not yet checked.
+	instanceBodies []func()
+
+	// instantiationDepth is the current depth of nested instantiations of
+	// generic functions and types.
+	instantiationDepth int
+
+	// uses maps the identifiers and the selectors that refer to names
+	// declared in Scriggo to the identifiers of their declarations.
+	// usedImports contains the imports whose names have been used. They are
+	// nil if the uses are not recorded.
+	uses        map[ast.Expression]*ast.Identifier
+	usedImports map[*ast.Import]bool
 }
 
 type renderIR struct {
@@ -28,7 +28,7 @@ import (
 )
 
 // formatTypeName reports the type name for each format.
-var formatTypeName = [...]string{"string", "html", "css", "js", "json", "markdown"}
+var formatTypeName = [...]string{"string", "html", "css", "js", "json", "markdown", "xml", "yaml", "toml"}
 
 // internalOperatorZero and internalOperatorNotZero are two internal operators
 // that are inserted in the tree by the type checker and that are handled by the
@@ -38,7 +38,7 @@ var formatTypeName = [...]string{"string", "html", "css", "js", "json", "markdow
 // As a special case, if the operand is an interface type then its value is
 // compared with the zero of the dynamic type of the interface.
 const (
-	internalOperatorZero = ast.OperatorExtendedNot + iota + 1
+	internalOperatorZero = ast.OperatorTilde + iota + 1
 	internalOperatorNotZero
 )
 
@@ -80,6 +80,13 @@ type Options struct {
 	MDConverter Converter
 
 	TreeTransformer func(*ast.Tree) error
+
+	// Optimize, when true, optimizes the emitted bytecode.
+	Optimize bool
+
+	// Coverage, when true, instruments the emitted bytecode to count the
+	// executions of the blocks of code. Used for programs and templates only.
+	Coverage bool
 }
 
 // GoModError represents an error in a go.mod file.
@@ -112,7 +119,8 @@ func (e *GoModError) Position() ast.Position {
 // BuildProgram builds a Go program from the package in the root of fsys with
 // the given options, importing the imported packages from packages.
 //
-// Current limitation: fsys can contain only one Go file in its root.
+// The package is made of all the Go files in the root of fsys, except the
+// test files and the files excluded by the build constraints.
 //
 // If a compilation error occurs, it returns a CompilerError error.
 func BuildProgram(fsys fs.FS, opts Options) (*Code, error) {
@@ -137,7 +145,8 @@ func BuildProgram(fsys fs.FS, opts Options) (*Code, error) {
 		allowGoStmt: opts.AllowGoStmt,
 		globals:     opts.Globals,
 	}
-	tci, err := typecheck(tree, opts.Importer, checkerOpts)
+	importer := &importRecorder{importer: opts.Importer}
+	tci, err := typecheck(tree, importer, checkerOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -149,10 +158,24 @@ func BuildProgram(fsys fs.FS, opts Options) (*Code, error) {
 	}
 
 	// Emit the code.
-	code, err := emitProgram(tree.Nodes[0].(*ast.Package), typeInfos, tci["main"].IndirectVars)
+	var cover *coverage
+	if opts.Coverage {
+		cover = newCoverage(fsys)
+	}
+	code, err := emitProgram(tree.Nodes[0].(*ast.Package), typeInfos, tci["main"].IndirectVars, cover)
 	if err != nil {
 		return nil, err
 	}
+	if cover != nil {
+		code.Coverage, err = cover.coverBlocks()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.Optimize {
+		optimize(code)
+	}
+	code.NativePackages = importer.paths
 
 	return code, nil
 }
@@ -183,7 +206,8 @@ func BuildScript(r io.Reader, opts Options) (*Code, error) {
 		allowGoStmt: opts.AllowGoStmt,
 		globals:     opts.Globals,
 	}
-	tci, err := typecheck(tree, opts.Importer, checkerOpts)
+	importer := &importRecorder{importer: opts.Importer}
+	tci, err := typecheck(tree, importer, checkerOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -196,8 +220,15 @@ func BuildScript(r io.Reader, opts Options) (*Code, error) {
 
 	// Emit the code.
 	code, err := emitScript(tree, typeInfos, tci["main"].IndirectVars)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Optimize {
+		optimize(code)
+	}
+	code.NativePackages = importer.paths
 
-	return code, err
+	return code, nil
 }
 
 // BuildTemplate builds the named template file rooted at the given file
@@ -231,7 +262,8 @@ func BuildTemplate(fsys fs.FS, name string, opts Options) (*Code, error) {
 		mdConverter: opts.MDConverter,
 		mod:         templateMod,
 	}
-	tci, err := typecheck(tree, opts.Importer, checkerOpts)
+	importer := &importRecorder{importer: opts.Importer}
+	tci, err := typecheck(tree, importer, checkerOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -243,9 +275,26 @@ func BuildTemplate(fsys fs.FS, name string, opts Options) (*Code, error) {
 	}
 
 	// Emit the code.
-	code, err := emitTemplate(tree, typeInfos, tci["main"].IndirectVars, opts.FormatTypes)
+	var cover *coverage
+	if opts.Coverage {
+		cover = newCoverage(fsys)
+	}
+	code, err := emitTemplate(tree, typeInfos, tci["main"].IndirectVars, opts.FormatTypes, cover)
+	if err != nil {
+		return nil, err
+	}
+	if cover != nil {
+		code.Coverage, err = cover.coverBlocks()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.Optimize {
+		optimize(code)
+	}
+	code.NativePackages = importer.paths
 
-	return code, err
+	return code, nil
 }
 
 // CheckingError records a type checking error with the path and the position
@@ -297,12 +346,45 @@ type Code struct {
 	Main *runtime.Function
 	// TypeOf returns the type of a value, including new types defined in code.
 	TypeOf runtime.TypeOfFunc
+	// NativePackages contains the paths of the imported native packages.
+	NativePackages []string
+	// Coverage contains the blocks instrumented for the coverage, indexed by
+	// the operand of their Cover instructions. It is nil if the code has not
+	// been instrumented.
+	Coverage []runtime.CoverBlock
+}
+
+// importRecorder implements native.Importer recording the paths of the
+// imported packages.
+type importRecorder struct {
+	importer native.Importer
+	paths    []string
+}
+
+// Import imports the package with the given path and, if it exists, records
+// its path.
+func (r *importRecorder) Import(path string) (native.ImportablePackage, error) {
+	if r.importer == nil {
+		return nil, nil
+	}
+	pkg, err := r.importer.Import(path)
+	if pkg == nil || err != nil {
+		return pkg, err
+	}
+	for _, p := range r.paths {
+		if p == path {
+			return pkg, nil
+		}
+	}
+	r.paths = append(r.paths, path)
+	return pkg, nil
 }
 
 // emitProgram emits the code for a program given its ast node, the type info
 // and indirect variables. emitProgram returns an emittedPackage  instance
-// with the global variables and the main function.
-func emitProgram(pkgMain *ast.Package, typeInfos map[ast.Node]*typeInfo, indirectVars map[*ast.Identifier]bool) (_ *Code, err error) {
+// with the global variables and the main function. If cover is not nil, the
+// code is instrumented for the coverage.
+func emitProgram(pkgMain *ast.Package, typeInfos map[ast.Node]*typeInfo, indirectVars map[*ast.Identifier]bool, cover *coverage) (_ *Code, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if e, ok := r.(*LimitExceededError); ok {
@@ -313,6 +395,7 @@ func emitProgram(pkgMain *ast.Package, typeInfos map[ast.Node]*typeInfo, indirec
 		}
 	}()
 	e := newEmitter(typeInfos, nil, indirectVars)
+	e.cover = cover
 	functions, _, _ := e.emitPackage(pkgMain, false, "main")
 	main, _ := e.fnStore.availableScriggoFn(pkgMain, "main")
 	pkg := &Code{
@@ -348,8 +431,9 @@ func emitScript(tree *ast.Tree, typeInfos map[ast.Node]*typeInfo, indirectVars m
 
 // emitTemplate emits the code for a template given its tree, the type info and
 // indirect variables. emitTemplate returns a function that is the entry point
-// of the template and the global variables.
-func emitTemplate(tree *ast.Tree, typeInfos map[ast.Node]*typeInfo, indirectVars map[*ast.Identifier]bool, formatTypes map[ast.Format]reflect.Type) (_ *Code, err error) {
+// of the template and the global variables. If cover is not nil, the code is
+// instrumented for the coverage.
+func emitTemplate(tree *ast.Tree, typeInfos map[ast.Node]*typeInfo, indirectVars map[*ast.Identifier]bool, formatTypes map[ast.Format]reflect.Type, cover *coverage) (_ *Code, err error) {
 	// Recover and eventually return a LimitExceededError.
 	defer func() {
 		if r := recover(); r != nil {
@@ -361,6 +445,7 @@ func emitTemplate(tree *ast.Tree, typeInfos map[ast.Node]*typeInfo, indirectVars
 		}
 	}()
 	e := newEmitter(typeInfos, formatTypes, indirectVars)
+	e.cover = cover
 	e.pkg = &ast.Package{}
 	e.isTemplate = true
 	typ := reflect.FuncOf(nil, nil, false)
@@ -0,0 +1,143 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compiler
+
+import (
+	"bytes"
+	"io/fs"
+	"sort"
+
+	"github.com/open2b/scriggo/ast"
+	"github.com/open2b/scriggo/internal/runtime"
+)
+
+// coverage records the blocks instrumented by the emitter for the coverage.
+// The blocks are recorded as byte offsets in their files and are converted
+// to lines and columns, reading the files from fsys, when the emission is
+// terminated.
+type coverage struct {
+	fsys   fs.FS
+	blocks []coverBlock
+}
+
+// coverBlock is a block instrumented for the coverage.
+type coverBlock struct {
+	path       string // path of the file.
+	start, end int    // offsets of the first byte and of the byte after the last.
+	numStmt    int    // number of statements.
+}
+
+// newCoverage returns a new coverage for the code whose files are read from
+// fsys.
+func newCoverage(fsys fs.FS) *coverage {
+	return &coverage{fsys: fsys}
+}
+
+// emitCover instruments the block with the given nodes, emitting a Cover
+// instruction before them. It does nothing if the code is not instrumented
+// or if the block has no statements.
+//
+// The instrumented blocks are the bodies of the if, switch, type switch,
+// select and for statements and of the functions and macros.
+func (em *emitter) emitCover(nodes []ast.Node) {
+	if em.cover == nil {
+		return
+	}
+	var first, last *ast.Position
+	var numStmt int
+	for _, node := range nodes {
+		if _, ok := node.(*ast.Comment); ok {
+			continue
+		}
+		pos := node.Pos()
+		if pos == nil || pos.Line == 0 {
+			continue
+		}
+		if first == nil {
+			first = pos
+		}
+		last = pos
+		numStmt++
+	}
+	if first == nil {
+		return
+	}
+	em.fb.emitCover(len(em.cover.blocks))
+	em.cover.blocks = append(em.cover.blocks, coverBlock{
+		path:    em.fb.getPath(),
+		start:   first.Start,
+		end:     last.End + 1,
+		numStmt: numStmt,
+	})
+}
+
+// emitFuncCover instruments the body of the function or macro fn. The
+// assignments of the result parameters, added by the type checker at the
+// beginning of the body, are not part of the block.
+func (em *emitter) emitFuncCover(fn *ast.Func) {
+	if em.cover == nil {
+		return
+	}
+	nodes := fn.Body.Nodes
+	if body := fn.Body.Position; body != nil {
+		for len(nodes) > 0 && nodes[0].Pos() != nil && nodes[0].Pos().Start < body.Start {
+			nodes = nodes[1:]
+		}
+	}
+	em.emitCover(nodes)
+}
+
+// coverBlocks returns the instrumented blocks with their positions as lines
+// and columns, in the order in which they have been instrumented.
+func (c *coverage) coverBlocks() ([]runtime.CoverBlock, error) {
+	lines := map[string][]int{}
+	blocks := make([]runtime.CoverBlock, len(c.blocks))
+	for i, b := range c.blocks {
+		offsets, ok := lines[b.path]
+		if !ok {
+			src, err := fs.ReadFile(c.fsys, b.path)
+			if err != nil {
+				return nil, err
+			}
+			offsets = lineOffsets(src)
+			lines[b.path] = offsets
+		}
+		block := &blocks[i]
+		block.Path = b.path
+		block.StartLine, block.StartCol = lineColumn(offsets, b.start)
+		block.EndLine, block.EndCol = lineColumn(offsets, b.end)
+		block.NumStmt = b.numStmt
+	}
+	return blocks, nil
+}
+
+// lineOffsets returns the offsets of the first byte of the lines of src.
+// The last element is the length of src.
+func lineOffsets(src []byte) []int {
+	offsets := []int{0}
+	for i := 0; ; {
+		j := bytes.IndexByte(src[i:], '\n')
+		if j < 0 {
+			break
+		}
+		i += j + 1
+		offsets = append(offsets, i)
+	}
+	return append(offsets, len(src))
+}
+
+// lineColumn returns the line and the column, in bytes, of the byte with
+// the given offset, given the line offsets returned by lineOffsets.
+func lineColumn(offsets []int, offset int) (int, int) {
+	last := len(offsets) - 1
+	if offset > offsets[last] {
+		offset = offsets[last]
+	}
+	i := sort.Search(last, func(i int) bool { return offsets[i] > offset }) - 1
+	if i < 0 {
+		i = 0
+	}
+	return i + 1, offset - offsets[i] + 1
+}
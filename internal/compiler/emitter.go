@@ -36,22 +36,13 @@ type emitter struct {
 	// isTemplate reports whether the emitter is currently emitting a template.
 	isTemplate bool
 
-	// rangeLabels is a list of current active Ranges. First element is the
-	// Range address, second refers to the first instruction outside Range's
-	// body.
-	rangeLabels [][2]label
+	// breakables are the statements, enclosing the statement that is being
+	// emitted, that can be the target of a break or continue statement. The
+	// innermost is the last one.
+	breakables []breakable
 
-	// breakable is true if emitting a "breakable" statement (except ForRange,
-	// which implements his own "breaking" system).
-	breakable bool
-
-	// inForRange reports whether the emitter is currently emitting the body of
-	// a ForRange node.
-	inForRange bool
-
-	// breakLabel, if not nil, is the label to which pre-stated "breaks" must
-	// jump.
-	breakLabel *label
+	// stmtLabels are the names of the labels of the labeled statements.
+	stmtLabels map[ast.Node]string
 
 	// inURL indicates if the emitter is currently inside an *ast.URL node.
 	inURL bool
@@ -85,6 +76,10 @@ type emitter struct {
 	// alreadyInitializedTemplatePkgs keeps track of the template packages for
 	// which the initialization code has already been emitted.
 	alreadyInitializedTemplatePkgs map[string]bool
+
+	// cover records the blocks instrumented for the coverage. It is nil if
+	// the code is not instrumented.
+	cover *coverage
 }
 
 // newEmitter returns a new emitter with the given type infos, format types,
@@ -98,12 +93,88 @@ func newEmitter(typeInfos map[ast.Node]*typeInfo, formatTypes map[ast.Format]ref
 		alreadyEmittedFuncs:            map[*ast.Func]*runtime.Function{},
 		alreadyInitializedVars:         map[*ast.Identifier]int16{},
 		alreadyInitializedTemplatePkgs: map[string]bool{},
+		stmtLabels:                     map[ast.Node]string{},
 	}
 	em.fnStore = newFunctionStore(em)
 	em.varStore = newVarStore(em, indirectVars)
 	return em
 }
 
+// breakable represents a statement that can be the target of a break
+// statement: a for, for range, switch, type switch or select statement.
+type breakable struct {
+	node  ast.Node
+	label string // name of the label of the statement, if labeled.
+	next  label  // label of the next iteration, only for loops.
+	end   label  // label of the first instruction after the statement.
+}
+
+// enterBreakable enters the breakable statement node. next is the label of
+// the next iteration, if node is a loop, and end is the label of the first
+// instruction after the statement.
+func (em *emitter) enterBreakable(node ast.Node, next, end label) {
+	em.breakables = append(em.breakables, breakable{
+		node:  node,
+		label: em.stmtLabels[node],
+		next:  next,
+		end:   end,
+	})
+}
+
+// exitBreakable exits the innermost breakable statement.
+func (em *emitter) exitBreakable() {
+	em.breakables = em.breakables[:len(em.breakables)-1]
+}
+
+// emitBranch emits a break, if isBreak is true, or a continue statement with
+// label lab. lab is nil if the statement is not labeled.
+func (em *emitter) emitBranch(lab *ast.Identifier, isBreak bool) {
+	// Find the target statement.
+	t := len(em.breakables) - 1
+	for ; t >= 0; t-- {
+		b := em.breakables[t]
+		if lab != nil {
+			if b.label == lab.Name {
+				break
+			}
+			continue
+		}
+		if isBreak {
+			break
+		}
+		if _, ok := b.node.(*ast.For); ok {
+			break
+		}
+		if _, ok := b.node.(*ast.ForRange); ok {
+			break
+		}
+	}
+	target := em.breakables[t]
+	// Continue a for range statement.
+	if _, ok := target.node.(*ast.ForRange); ok && !isBreak {
+		em.fb.emitContinue(target.next)
+		return
+	}
+	// Break the outermost for range statement that is exited, if any.
+	// Execution continues after the Break instruction, so the following Goto
+	// instruction is executed outside the body of the for range.
+	i := t
+	if !isBreak {
+		i++
+	}
+	for ; i < len(em.breakables); i++ {
+		if _, ok := em.breakables[i].node.(*ast.ForRange); ok {
+			em.fb.emitBreak(em.breakables[i].next)
+			break
+		}
+	}
+	if isBreak {
+		em.fb.emitGoto(target.end)
+	} else {
+		em.fb.emitGoto(target.next)
+	}
+}
+
 // ti returns the type info of node n.
 func (em *emitter) ti(n ast.Node) *typeInfo {
 	if ti, ok := em.typeInfos[n]; ok {
@@ -167,6 +238,10 @@ func (em *emitter) emitPackage(pkg *ast.Package, extendingFile bool, path string
 		// their bodies: order of declaration doesn't matter at package level.
 		for _, dec := range pkg.Declarations {
 			if fun, ok := dec.(*ast.Func); ok {
+				if fun.Recv != nil {
+					em.declareMethod(fun, declPath(pkg, fun, path))
+					continue
+				}
 				var fn *runtime.Function
 				if emFn, ok := em.alreadyEmittedFuncs[fun]; ok {
 					fn = emFn
@@ -174,7 +249,7 @@ func (em *emitter) emitPackage(pkg *ast.Package, extendingFile bool, path string
 					if fun.Type.Macro {
 						fn = newMacro("main", fun.Ident.Name, fun.Type.Reflect, fun.Format, path, fun.Pos())
 					} else {
-						fn = newFunction("main", fun.Ident.Name, fun.Type.Reflect, path, fun.Pos())
+						fn = newFunction("main", fun.Ident.Name, fun.Type.Reflect, declPath(pkg, fun, path), fun.Pos())
 					}
 				}
 				if fun.Ident.Name == "init" {
@@ -204,13 +279,15 @@ func (em *emitter) emitPackage(pkg *ast.Package, extendingFile bool, path string
 			// of collision with Scriggo defined functions.
 			backupFb := em.fb
 			if initVarsFn == nil {
-				initVarsFn = newFunction("main", "$initvars", reflect.FuncOf(nil, nil, false), path, &ast.Position{})
+				initVarsFn = newFunction("main", "$initvars", reflect.FuncOf(nil, nil, false), declPath(pkg, n, path), &ast.Position{})
 				em.fnStore.makeAvailableScriggoFn(em.pkg, "$initvars", initVarsFn)
 				initVarsFb = newBuilder(initVarsFn, path)
 			}
 			em.fb = initVarsFb
+			// The variables can be declared in different files.
+			em.fb.changePath(declPath(pkg, n, path))
 			addresses := make([]address, len(n.Lhs))
-			pkgVarRegs := map[string]int8{}
+			pkgVarRegs := map[string]int16{}
 			pkgVarTypes := map[string]reflect.Type{}
 			for i, v := range n.Lhs {
 				if isBlankIdentifier(v) {
@@ -258,18 +335,21 @@ func (em *emitter) emitPackage(pkg *ast.Package, extendingFile bool, path string
 				// Function has already been emitted, nothing to do.
 				continue
 			}
-			if n.Ident.Name == "init" {
+			if n.Recv != nil {
+				m, _ := types.MethodByName(em.typ(n.Recv.Type), n.Ident.Name)
+				fn = m.Func
+			} else if n.Ident.Name == "init" {
 				fn = inits[initToBuild]
 				initToBuild++
 			} else {
 				fn, _ = em.fnStore.availableScriggoFn(em.pkg, n.Ident.Name)
 			}
-			em.fb = newBuilder(fn, path)
+			em.fb = newBuilder(fn, declPath(pkg, n, path))
 			em.fb.enterScope()
 			// If this is the main function, functions that initialize variables
 			// must be called before executing every other statement of the main
 			// function.
-			if n.Ident.Name == "main" {
+			if n.Recv == nil && n.Ident.Name == "main" {
 				// First: initialize the package variables.
 				if initVarsFn != nil {
 					iv, _ := em.fnStore.availableScriggoFn(em.pkg, "$initvars")
@@ -283,6 +363,7 @@ func (em *emitter) emitPackage(pkg *ast.Package, extendingFile bool, path string
 				}
 			}
 			em.prepareFunctionBodyParameters(n)
+			em.emitFuncCover(n)
 			em.emitNodes(n.Body.Nodes)
 			em.fb.end()
 			em.fb.exitScope()
@@ -305,6 +386,46 @@ func (em *emitter) emitPackage(pkg *ast.Package, extendingFile bool, path string
 
 }
 
+// declPath returns the path of the file in which the declaration decl of the
+// package pkg, with path path, is declared. If pkg has no files, as for
+// templates and scripts, it returns path.
+func declPath(pkg *ast.Package, decl ast.Node, path string) string {
+	if p, ok := pkg.Files[decl]; ok {
+		return p
+	}
+	return path
+}
+
+// declareMethod creates the function of the method declaration fun, in the
+// file with the given path, so that the method can be called before its body
+// is emitted.
+func (em *emitter) declareMethod(fun *ast.Func, path string) {
+	if isBlankIdentifier(fun.Ident) {
+		return
+	}
+	rcvType := em.typ(fun.Recv.Type)
+	m, _ := types.MethodByName(rcvType, fun.Ident.Name)
+	if fn, ok := em.alreadyEmittedFuncs[fun]; ok {
+		m.Func = fn
+		return
+	}
+	t := fun.Type.Reflect
+	in := make([]reflect.Type, t.NumIn()+1)
+	in[0] = rcvType
+	for i := 1; i < len(in); i++ {
+		in[i] = t.In(i - 1)
+	}
+	out := make([]reflect.Type, t.NumOut())
+	for i := range out {
+		out[i] = t.Out(i)
+	}
+	name := rcvType.String() + "." + m.Name
+	if m.Pointer {
+		name = "(" + rcvType.String() + ")." + m.Name
+	}
+	m.Func = newFunction("main", name, em.types.FuncOf(in, out, t.IsVariadic()), path, fun.Pos())
+}
+
 // callOptions holds information about a function call.
 type callOptions struct {
 	predefined    bool
@@ -320,11 +441,11 @@ type callOptions struct {
 //
 // Note that while prepareCallParameters is called before calling the function,
 // prepareFunctionBodyParameters is called before emitting its body.
-func (em *emitter) prepareCallParameters(fType reflect.Type, fArgs []ast.Expression, opts callOptions) ([]int8, []reflect.Type) {
+func (em *emitter) prepareCallParameters(fType reflect.Type, fArgs []ast.Expression, opts callOptions) ([]int16, []reflect.Type) {
 
 	fNumOut := fType.NumOut()
 	fNumIn := fType.NumIn()
-	fOutRegs := make([]int8, fNumOut)
+	fOutRegs := make([]int16, fNumOut)
 	fOutTypes := make([]reflect.Type, fNumOut)
 
 	// Reserve space for the output parameters.
@@ -353,13 +474,13 @@ func (em *emitter) prepareCallParameters(fType reflect.Type, fArgs []ast.Express
 			nonVarArgsCount := fNumIn - 1
 			varArgsCount := gOutCount - (fNumIn - 1)
 			// Reserve space for non variadic parameters.
-			var nonVarParamRegs []int8
+			var nonVarParamRegs []int16
 			for i := 0; i < nonVarArgsCount; i++ {
 				reg := em.fb.newRegister(fType.In(i).Kind())
 				nonVarParamRegs = append(nonVarParamRegs, reg)
 			}
 			// Reserve space for variadic parameters.
-			var varParamRegs []int8
+			var varParamRegs []int16
 			sliceType := fType.In(fNumIn - 1)
 			if opts.predefined {
 				// When calling a predefined variadic function, the variadic
@@ -372,7 +493,7 @@ func (em *emitter) prepareCallParameters(fType reflect.Type, fArgs []ast.Express
 			} else {
 				// When calling a non-predefined variadic function, the
 				// variadic parameters must be emitted inside a slice.
-				varParamRegs = []int8{em.fb.newRegister(reflect.Slice)}
+				varParamRegs = []int16{em.fb.newRegister(reflect.Slice)}
 			}
 			em.fb.enterStack()
 			gOutRegs, gOutTypes := em.emitCallNode(g, false, false, runtime.ReturnString)
@@ -393,15 +514,15 @@ func (em *emitter) prepareCallParameters(fType reflect.Type, fArgs []ast.Express
 				if varArgsCount == 0 {
 					// The slice must be nil, not empty.
 					c := em.fb.makeGeneralValue(reflect.Zero(sliceType))
-					em.changeRegister(true, c, slice, sliceType, sliceType)
+					em.changeRegister(true, int16(c), slice, sliceType, sliceType)
 				} else {
 					pos := fArgs[0].Pos()
-					em.fb.emitMakeSlice(true, true, sliceType, int8(varArgsCount), int8(varArgsCount), slice, pos)
+					em.fb.emitMakeSlice(true, true, sliceType, int16(varArgsCount), int16(varArgsCount), slice, pos)
 					for i := nonVarArgsCount; i < len(gOutRegs); i++ {
 						gArgReg := gOutRegs[i]
 						gArgType := gOutTypes[i]
 						index := em.fb.newRegister(reflect.Int)
-						em.changeRegister(true, int8(i-nonVarArgsCount), index, intType, intType)
+						em.changeRegister(true, int16(i-nonVarArgsCount), index, intType, intType)
 						if canEmitDirectly(gArgType.Kind(), sliceType.Elem().Kind()) {
 							em.fb.emitSetSlice(false, slice, gArgReg, index, pos, sliceType.Elem().Kind())
 						} else {
@@ -448,14 +569,14 @@ func (em *emitter) prepareCallParameters(fType reflect.Type, fArgs []ast.Express
 			}
 		} else {
 			slice := em.fb.newRegister(reflect.Slice)
-			em.fb.emitMakeSlice(true, true, fType.In(fNumIn-1), int8(varArgsCount), int8(varArgsCount), slice, nil) // TODO: fix pos.
+			em.fb.emitMakeSlice(true, true, fType.In(fNumIn-1), int16(varArgsCount), int16(varArgsCount), slice, nil) // TODO: fix pos.
 			for i := 0; i < varArgsCount; i++ {
 				tmp := em.fb.newRegister(t.Kind())
 				em.fb.enterStack()
 				em.emitExprR(fArgs[i+fNumIn-1], t, tmp)
 				em.fb.exitStack()
 				index := em.fb.newRegister(reflect.Int)
-				em.fb.emitMove(true, int8(i), index, reflect.Int)
+				em.fb.emitMove(true, int16(i), index, reflect.Int)
 				pos := fArgs[len(fArgs)-1].Pos()
 				em.fb.emitSetSlice(false, slice, tmp, index, pos, fType.In(fNumIn-1).Elem().Kind())
 			}
@@ -501,13 +622,25 @@ func (em *emitter) prepareFunctionBodyParameters(fn *ast.Func) {
 
 	// Reserve space for the return parameters and eventually bind them.
 	for _, out := range fn.Type.Result {
-		kind := em.typ(out.Type).Kind()
-		reg := em.fb.newRegister(kind)
+		typ := em.typ(out.Type)
+		reg := em.fb.newRegister(typ.Kind())
 		if out.Ident != nil && !isBlankIdentifier(out.Ident) {
-			em.fb.bindVarReg(out.Ident.Name, reg)
+			em.fb.bindVarReg(out.Ident.Name, reg, typ)
 		}
 	}
 
+	// Reserve space for the receiver, if fn is a method, and eventually bind
+	// it.
+	in := 0
+	if recv := fn.Recv; recv != nil {
+		typ := em.fb.fn.Type.In(0)
+		reg := em.fb.newRegister(typ.Kind())
+		if recv.Ident != nil && !isBlankIdentifier(recv.Ident) {
+			em.fb.bindVarReg(recv.Ident.Name, reg, typ)
+		}
+		in = 1
+	}
+
 	// Reserve space for the input parameters and eventually bind them.
 	for i, inParam := range fn.Type.Parameters {
 		kind := em.typ(inParam.Type).Kind()
@@ -524,7 +657,7 @@ func (em *emitter) prepareFunctionBodyParameters(fn *ast.Func) {
 			//
 			// Indirect input parameters are handled below.
 			arg := em.fb.newRegister(kind)
-			em.fb.bindVarReg(inParam.Ident.Name, arg)
+			em.fb.bindVarReg(inParam.Ident.Name, arg, em.fb.fn.Type.In(in+i))
 		}
 	}
 
@@ -534,14 +667,20 @@ func (em *emitter) prepareFunctionBodyParameters(fn *ast.Func) {
 		if out.Ident != nil && em.varStore.mustBeDeclaredAsIndirect(out.Ident) {
 			dst := em.fb.scopeLookup(out.Ident.Name)
 			reg := em.fb.newIndirectRegister()
-			em.fb.emitNew(em.typ(out.Type), -reg)
-			em.fb.bindVarReg(out.Ident.Name, reg)
-			em.fb.fn.FinalRegs = append(em.fb.fn.FinalRegs, [2]int8{-reg, dst})
+			typ := em.typ(out.Type)
+			em.fb.emitNew(typ, -reg)
+			em.fb.bindVarReg(out.Ident.Name, reg, typ)
+			em.fb.fn.FinalRegs = append(em.fb.fn.FinalRegs, [2]int16{-reg, dst})
 		}
 	}
 
-	// Rebind input parameters that should be declared as indirect.
-	for _, param := range fn.Type.Parameters {
+	// Rebind the receiver and the input parameters that should be declared
+	// as indirect.
+	params := fn.Type.Parameters
+	if fn.Recv != nil {
+		params = append([]*ast.Parameter{fn.Recv}, params...)
+	}
+	for _, param := range params {
 		if em.varStore.mustBeDeclaredAsIndirect(param.Ident) {
 			// reg is used only to read input parameters; after copying values
 			// into the indirect register it is not used anymore.
@@ -552,7 +691,7 @@ func (em *emitter) prepareFunctionBodyParameters(fn *ast.Func) {
 			typ := em.typ(param.Type)
 			em.fb.emitNew(typ, -indirect)
 			em.changeRegister(false, reg, indirect, typ, typ)
-			em.fb.bindVarReg(param.Ident.Name, indirect)
+			em.fb.bindVarReg(param.Ident.Name, indirect, typ)
 		}
 
 	}
@@ -563,7 +702,7 @@ func (em *emitter) prepareFunctionBodyParameters(fn *ast.Func) {
 // registers and the reflect types of the returned values.
 // goStmt indicates if the call node belongs to a 'go statement', while
 // deferStmt reports whether it must be deferred.
-func (em *emitter) emitCallNode(call *ast.Call, goStmt bool, deferStmt bool, toFormat ast.Format) ([]int8, []reflect.Type) {
+func (em *emitter) emitCallNode(call *ast.Call, goStmt bool, deferStmt bool, toFormat ast.Format) ([]int16, []reflect.Type) {
 
 	funTi := em.ti(call.Func)
 
@@ -581,22 +720,58 @@ func (em *emitter) emitCallNode(call *ast.Call, goStmt bool, deferStmt bool, toF
 		name := call.Func.(*ast.Selector).Ident
 		s := em.fb.makeStringValue(name)
 		em.fb.emitMethodValue(s, rcvr, method, call.Func.Pos())
-		call.Args = append([]ast.Expression{rcvrExpr}, call.Args...)
 		stackShift := em.fb.currentStackShift()
+		// The receiver is bound to the method value, so it is not passed as
+		// argument.
 		opts := callOptions{
-			predefined:    true,
-			receiverAsArg: true,
-			callHasDots:   call.IsVariadic,
+			predefined:  true,
+			callHasDots: call.IsVariadic,
 		}
 		regs, types := em.prepareCallParameters(funTi.Type, call.Args, opts)
-		// TODO(Gianluca): handle variadic method calls.
+		numVar := runtime.NoVariadicArgs
+		if funTi.Type.IsVariadic() && !call.IsVariadic {
+			numArgs := len(call.Args)
+			if len(call.Args) == 1 {
+				if callArg, ok := call.Args[0].(*ast.Call); ok {
+					if numOut, ok := em.numOut(callArg); ok {
+						numArgs = numOut
+					}
+				}
+			}
+			numVar = numArgs - (funTi.Type.NumIn() - 1)
+		}
 		if goStmt {
 			em.fb.emitGo()
 		}
 		if deferStmt {
 			panic(internalError("not implemented"))
 		}
-		em.fb.emitCallIndirect(method, 0, stackShift, call.Pos(), funTi.Type, toFormat)
+		em.fb.emitCallIndirect(method, int16(numVar), stackShift, call.Pos(), funTi.Type, toFormat)
+		return regs, types
+	}
+
+	// Method call of a method declared in Scriggo.
+	if funTi.MethodType == methodCallScriggo {
+		m := funTi.value.(*runtime.Method)
+		call.Args = append([]ast.Expression{call.Func.(*ast.Selector).Expr}, call.Args...)
+		stackShift := em.fb.currentStackShift()
+		opts := callOptions{
+			receiverAsArg: true,
+			callHasDots:   call.IsVariadic,
+		}
+		regs, types := em.prepareCallParameters(m.Type, call.Args, opts)
+		index := em.fnStore.scriggoFnIndex(m.Func)
+		if goStmt {
+			em.fb.emitGo()
+		}
+		if deferStmt {
+			args := stackDifference(em.fb.currentStackShift(), stackShift)
+			reg := em.fb.newRegister(reflect.Func)
+			em.fb.emitLoadFunc(false, index, reg)
+			em.fb.emitDefer(reg, runtime.NoVariadicArgs, stackShift, args, m.Func.Type)
+			return regs, types
+		}
+		em.fb.emitCallFunc(index, stackShift, call.Pos())
 		return regs, types
 	}
 
@@ -635,10 +810,30 @@ func (em *emitter) emitCallNode(call *ast.Call, goStmt bool, deferStmt bool, toF
 			args := em.fb.currentStackShift()
 			reg := em.fb.newRegister(reflect.Func)
 			em.fb.emitLoadFunc(true, index, reg)
-			em.fb.emitDefer(reg, int8(numVar), stackShift, args, funTi.Type)
+			em.fb.emitDefer(reg, int16(numVar), stackShift, args, funTi.Type)
+			return regs, types
+		}
+		em.fb.emitCallNative(index, int16(numVar), stackShift, call.Pos())
+		return regs, types
+	}
+
+	// Instance of a generic function.
+	if inst, ok := funTi.value.(*funcInstance); ok {
+		fn, _ := em.fnStore.availableScriggoFn(inst.pkg, inst.decl.Ident.Name)
+		stackShift := em.fb.currentStackShift()
+		regs, types := em.prepareCallParameters(fn.Type, call.Args, callOptions{callHasDots: call.IsVariadic})
+		index := em.fnStore.scriggoFnIndex(fn)
+		if goStmt {
+			em.fb.emitGo()
+		}
+		if deferStmt {
+			args := stackDifference(em.fb.currentStackShift(), stackShift)
+			reg := em.fb.newRegister(reflect.Func)
+			em.fb.emitLoadFunc(false, index, reg)
+			em.fb.emitDefer(reg, runtime.NoVariadicArgs, stackShift, args, fn.Type)
 			return regs, types
 		}
-		em.fb.emitCallNative(index, int8(numVar), stackShift, call.Pos())
+		em.fb.emitCallFunc(index, stackShift, call.Pos())
 		return regs, types
 	}
 
@@ -706,17 +901,17 @@ func (em *emitter) emitCallNode(call *ast.Call, goStmt bool, deferStmt bool, toF
 	}
 	if deferStmt {
 		args := stackDifference(em.fb.currentStackShift(), stackShift)
-		em.fb.emitDefer(reg, int8(runtime.NoVariadicArgs), stackShift, args, funTi.Type)
+		em.fb.emitDefer(reg, int16(runtime.NoVariadicArgs), stackShift, args, funTi.Type)
 		return regs, types
 	}
-	em.fb.emitCallIndirect(reg, int8(runtime.NoVariadicArgs), stackShift, call.Pos(), funTi.Type, toFormat)
+	em.fb.emitCallIndirect(reg, int16(runtime.NoVariadicArgs), stackShift, call.Pos(), funTi.Type, toFormat)
 
 	return regs, types
 }
 
 // emitBuiltin emits instructions for a builtin call, writing the result, if
 // necessary, into the register reg.
-func (em *emitter) emitBuiltin(call *ast.Call, reg int8, dstType reflect.Type) {
+func (em *emitter) emitBuiltin(call *ast.Call, reg int16, dstType reflect.Type) {
 	args := call.Args
 	switch call.Func.(*ast.Identifier).Name {
 	case "append":
@@ -741,7 +936,7 @@ func (em *emitter) emitBuiltin(call *ast.Call, reg int8, dstType reflect.Type) {
 		em.fb.enterStack()
 		tmp := em.fb.newRegister(sliceType.Kind())
 		em.changeRegister(false, slice, tmp, sliceType, sliceType)
-		elems := []int8{}
+		elems := []int16{}
 		for _, argExpr := range args[1:] {
 			elem := em.fb.newRegister(sliceType.Elem().Kind())
 			em.fb.enterStack()
@@ -751,7 +946,7 @@ func (em *emitter) emitBuiltin(call *ast.Call, reg int8, dstType reflect.Type) {
 		}
 		// TODO(Gianluca): if len(appendArgs) > 255 split in blocks
 		if len(elems) > 0 {
-			em.fb.emitAppend(elems[0], elems[0]+int8(len(elems)), tmp, sliceType.Elem().Kind())
+			em.fb.emitAppend(elems[0], elems[0]+int16(len(elems)), tmp, sliceType.Elem().Kind())
 		}
 		em.changeRegister(false, tmp, reg, sliceType, dstType)
 		em.fb.exitStack()
@@ -836,7 +1031,7 @@ func (em *emitter) emitBuiltin(call *ast.Call, reg int8, dstType reflect.Type) {
 			lenExpr := args[1]
 			lenn, kLen := em.emitExprK(lenExpr, intType)
 			var kCap bool
-			var capp int8
+			var capp int16
 			if len(args) == 3 {
 				capArg := args[2]
 				capp, kCap = em.emitExprK(capArg, intType)
@@ -847,7 +1042,7 @@ func (em *emitter) emitBuiltin(call *ast.Call, reg int8, dstType reflect.Type) {
 			em.fb.emitMakeSlice(kLen, kCap, typ, lenn, capp, reg, call.Pos())
 		case reflect.Chan:
 			var kCapacity bool
-			var capacity int8
+			var capacity int16
 			if len(args) == 1 {
 				capacity = 0
 				kCapacity = true
@@ -896,7 +1091,7 @@ func (em *emitter) emitBuiltin(call *ast.Call, reg int8, dstType reflect.Type) {
 				if i > 0 {
 					str := em.fb.makeStringValue(" ")
 					sep := em.fb.newRegister(reflect.Interface)
-					em.changeRegister(true, str, sep, stringType, emptyInterfaceType)
+					em.changeRegister(true, int16(str), sep, stringType, emptyInterfaceType)
 					em.fb.emitPrint(sep)
 				}
 				if canEmitDirectly(argTypes[i].Kind(), reflect.Interface) {
@@ -916,7 +1111,7 @@ func (em *emitter) emitBuiltin(call *ast.Call, reg int8, dstType reflect.Type) {
 					em.fb.enterStack()
 					str := em.fb.makeStringValue(" ")
 					sep := em.fb.newRegister(reflect.Interface)
-					em.changeRegister(true, str, sep, stringType, emptyInterfaceType)
+					em.changeRegister(true, int16(str), sep, stringType, emptyInterfaceType)
 					em.fb.emitPrint(sep)
 					em.fb.exitStack()
 				}
@@ -929,7 +1124,7 @@ func (em *emitter) emitBuiltin(call *ast.Call, reg int8, dstType reflect.Type) {
 		em.fb.enterStack()
 		str := em.fb.makeStringValue("\n")
 		sep := em.fb.newRegister(reflect.Interface)
-		em.changeRegister(true, str, sep, stringType, emptyInterfaceType)
+		em.changeRegister(true, int16(str), sep, stringType, emptyInterfaceType)
 		em.fb.emitPrint(sep)
 		em.fb.exitStack()
 	case "real", "imag":
@@ -988,7 +1183,7 @@ func (em *emitter) emitCondition(cond ast.Expression) {
 	if ti := em.ti(cond); ti != nil && ti.HasValue() && !ti.IsNative() {
 		// The condition of the 'if' instruction of VM is a binary operation,
 		// so the boolean constant expression 'x' is emitted as 'x == true'.
-		var c int8 = 0
+		var c int16 = 0
 		if ti.value.(int64) == 1 {
 			c = 1
 		}
@@ -1168,7 +1363,7 @@ func (em *emitter) emitCondition(cond ast.Expression) {
 
 // emitComplexOperation emits the operation on the given complex numbers putting
 // the result into the given register.
-func (em *emitter) emitComplexOperation(exprType reflect.Type, expr1 ast.Expression, op ast.OperatorType, expr2 ast.Expression, reg int8, dstType reflect.Type) {
+func (em *emitter) emitComplexOperation(exprType reflect.Type, expr1 ast.Expression, op ast.OperatorType, expr2 ast.Expression, reg int16, dstType reflect.Type) {
 	stackShift := em.fb.currentStackShift()
 	em.fb.enterScope()
 	index := em.fb.complexOperationIndex(op, false)
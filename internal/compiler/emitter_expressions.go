@@ -16,19 +16,19 @@ import (
 // emitExpr emits expr into a register of a given type. emitExpr tries to not
 // create a new register, but to use an existing one. The register used for
 // emission is returned.
-func (em *emitter) emitExpr(expr ast.Expression, dstType reflect.Type) int8 {
+func (em *emitter) emitExpr(expr ast.Expression, dstType reflect.Type) int16 {
 	reg, _ := em._emitExpr(expr, dstType, 0, false, false)
 	return reg
 }
 
 // emitExprK emits expr into a register of a given type. The boolean return
-// parameter reports whether the returned int8 is a constant or not.
-func (em *emitter) emitExprK(expr ast.Expression, dstType reflect.Type) (int8, bool) {
+// parameter reports whether the returned int16 is a constant or not.
+func (em *emitter) emitExprK(expr ast.Expression, dstType reflect.Type) (int16, bool) {
 	return em._emitExpr(expr, dstType, 0, false, true)
 }
 
 // emitExprR emits expr into register reg with the given type.
-func (em *emitter) emitExprR(expr ast.Expression, dstType reflect.Type, reg int8) {
+func (em *emitter) emitExprR(expr ast.Expression, dstType reflect.Type, reg int16) {
 	_, _ = em._emitExpr(expr, dstType, reg, true, false)
 }
 
@@ -44,7 +44,7 @@ func (em *emitter) emitExprR(expr ast.Expression, dstType reflect.Type, reg int8
 // _emitExpr is an internal support method, and should be called by emitExpr,
 // emitExprK and emitExprR exclusively.
 //
-func (em *emitter) _emitExpr(expr ast.Expression, dstType reflect.Type, reg int8, useGivenReg bool, allowK bool) (int8, bool) {
+func (em *emitter) _emitExpr(expr ast.Expression, dstType reflect.Type, reg int16, useGivenReg bool, allowK bool) (int16, bool) {
 
 	// Take the type info of the expression.
 	ti := em.ti(expr)
@@ -58,13 +58,13 @@ func (em *emitter) _emitExpr(expr ast.Expression, dstType reflect.Type, reg int8
 			case int64:
 				if canEmitDirectly(reflect.Int, dstType.Kind()) {
 					if -128 <= v && v <= 127 {
-						return int8(v), true
+						return int16(v), true
 					}
 				}
 			case float64:
 				if canEmitDirectly(reflect.Float64, dstType.Kind()) {
 					if math.Floor(v) == v && -128 <= v && v <= 127 {
-						return int8(v), true
+						return int16(v), true
 					}
 				}
 			}
@@ -93,6 +93,16 @@ func (em *emitter) _emitExpr(expr ast.Expression, dstType reflect.Type, reg int8
 		return reg, false
 	}
 
+	// expr is an instance of a generic function.
+	if ti != nil {
+		if inst, ok := ti.value.(*funcInstance); ok {
+			fn, _ := em.fnStore.availableScriggoFn(inst.pkg, inst.decl.Ident.Name)
+			em.fb.emitLoadFunc(false, em.fnStore.scriggoFnIndex(fn), reg)
+			em.changeRegister(false, reg, reg, ti.Type, dstType)
+			return reg, false
+		}
+	}
+
 	switch expr := expr.(type) {
 
 	case *ast.BinaryOperator:
@@ -202,7 +212,7 @@ func (em *emitter) _emitExpr(expr ast.Expression, dstType reflect.Type, reg int8
 			return reg, false
 		}
 
-		var tmp int8
+		var tmp int16
 		if canEmitDirectly(reflect.Func, dstType.Kind()) {
 			tmp = reg
 		} else {
@@ -222,14 +232,18 @@ func (em *emitter) _emitExpr(expr ast.Expression, dstType reflect.Type, reg int8
 
 		funcLitBuilder := newBuilder(fn, em.fb.getPath())
 		currFB := em.fb
+		currBreakables := em.breakables
 		em.fb = funcLitBuilder
+		em.breakables = nil
 
 		em.fb.enterScope()
 		em.prepareFunctionBodyParameters(expr)
+		em.emitFuncCover(expr)
 		em.emitNodes(expr.Body.Nodes)
 		em.fb.exitScope()
 		em.fb.end()
 		em.fb = currFB
+		em.breakables = currBreakables
 
 		em.changeRegister(false, tmp, reg, ti.Type, dstType)
 
@@ -310,7 +324,7 @@ func (em *emitter) _emitExpr(expr ast.Expression, dstType reflect.Type, reg int8
 
 		exprType := em.typ(expr.Expr)
 		src := em.emitExpr(expr.Expr, exprType)
-		var low, high int8 = 0, -1
+		var low, high int16 = 0, -1
 		var kLow, kHigh = true, true
 		// emit low
 		if expr.Low != nil {
@@ -333,7 +347,7 @@ func (em *emitter) _emitExpr(expr ast.Expression, dstType reflect.Type, reg int8
 			}
 		} else {
 			// If necessary, emit max.
-			var max int8 = -1
+			var max int16 = -1
 			var kMax = true
 			if expr.Max != nil {
 				max, kMax = em.emitExprK(expr.Max, em.typ(expr.Max))
@@ -366,7 +380,7 @@ func (em *emitter) _emitExpr(expr ast.Expression, dstType reflect.Type, reg int8
 
 // emitBinaryOp emits the code for the binary expression expr and stores the
 // result in the register reg of type regType.
-func (em *emitter) emitBinaryOp(expr *ast.BinaryOperator, reg int8, regType reflect.Type) {
+func (em *emitter) emitBinaryOp(expr *ast.BinaryOperator, reg int16, regType reflect.Type) {
 
 	var (
 		ti   = em.ti(expr)
@@ -385,7 +399,7 @@ func (em *emitter) emitBinaryOp(expr *ast.BinaryOperator, reg int8, regType refl
 	// Emit code for the operators && and ||.
 	if op == ast.OperatorAnd || op == ast.OperatorOr {
 		x := reg
-		y := int8(0)
+		y := int16(0)
 		direct := canEmitDirectly(regType.Kind(), reflect.Bool)
 		if !direct {
 			em.fb.enterStack()
@@ -564,7 +578,7 @@ func (em *emitter) emitBinaryOp(expr *ast.BinaryOperator, reg int8, regType refl
 
 }
 
-func (em *emitter) emitCompositeLiteral(expr *ast.CompositeLiteral, reg int8, dstType reflect.Type) (int8, bool) {
+func (em *emitter) emitCompositeLiteral(expr *ast.CompositeLiteral, reg int16, dstType reflect.Type) (int16, bool) {
 	typ := em.typ(expr.Type)
 	switch typ.Kind() {
 	case reflect.Slice, reflect.Array:
@@ -588,7 +602,7 @@ func (em *emitter) emitCompositeLiteral(expr *ast.CompositeLiteral, reg int8, ds
 				em.fb.emitLoad(em.fb.makeIntValue(int64(length)), r, reflect.Int)
 				length = int(r)
 			}
-			em.fb.emitMakeSlice(k, k, typ, int8(length), int8(length), workingReg, expr.Pos())
+			em.fb.emitMakeSlice(k, k, typ, int16(length), int16(length), workingReg, expr.Pos())
 		} else {
 			em.fb.emitMakeArray(typ, workingReg)
 		}
@@ -620,7 +634,7 @@ func (em *emitter) emitCompositeLiteral(expr *ast.CompositeLiteral, reg int8, ds
 			if index > 127 {
 				em.fb.emitLoad(em.fb.makeIntValue(index), indexReg, reflect.Int)
 			} else {
-				em.fb.emitMove(true, int8(index), indexReg, reflect.Int)
+				em.fb.emitMove(true, int16(index), indexReg, reflect.Int)
 			}
 			elem, k := em.emitExprK(kv.Value, typ.Elem())
 			if workingReg != 0 {
@@ -655,7 +669,7 @@ func (em *emitter) emitCompositeLiteral(expr *ast.CompositeLiteral, reg int8, ds
 		}
 		// Assign key-value pairs to the struct fields.
 		em.fb.enterStack()
-		var structt int8
+		var structt int16
 		if canEmitDirectly(typ.Kind(), dstType.Kind()) {
 			structt = em.fb.newRegister(reflect.Struct)
 		} else {
@@ -694,7 +708,7 @@ func (em *emitter) emitCompositeLiteral(expr *ast.CompositeLiteral, reg int8, ds
 		tmp := em.fb.newRegister(reflect.Map)
 		size := len(expr.KeyValues)
 		if size <= 127 {
-			em.fb.emitMakeMap(typ, true, int8(size), tmp)
+			em.fb.emitMakeMap(typ, true, int16(size), tmp)
 		} else {
 			index := em.fb.makeIntValue(int64(size))
 			sizeReg := em.fb.newRegister(reflect.Int)
@@ -715,7 +729,7 @@ func (em *emitter) emitCompositeLiteral(expr *ast.CompositeLiteral, reg int8, ds
 }
 
 // emitSelector emits selector in register reg.
-func (em *emitter) emitSelector(v *ast.Selector, reg int8, dstType reflect.Type) {
+func (em *emitter) emitSelector(v *ast.Selector, reg int16, dstType reflect.Type) {
 
 	ti := em.ti(v)
 
@@ -739,6 +753,39 @@ func (em *emitter) emitSelector(v *ast.Selector, reg int8, dstType reflect.Type)
 		return
 	}
 
+	// Method value of a method declared in Scriggo. The receiver is wrapped
+	// in a proxy so that the method can be bound to it.
+	if ti.MethodType == methodValueScriggo {
+		typ := em.typ(v.Expr)
+		value := em.emitExpr(v.Expr, typ)
+		rcvr := em.fb.newRegister(reflect.Interface)
+		em.fb.emitTypify(false, typ, value, rcvr)
+		if kindToType(dstType.Kind()) == generalRegister {
+			s := em.fb.makeStringValue(v.Ident)
+			em.fb.emitMethodValue(s, rcvr, reg, v.Pos())
+		} else {
+			panic(internalError("not implemented"))
+		}
+		return
+	}
+
+	// Method expression of a method declared in Scriggo.
+	if ti.MethodType == methodExprScriggo {
+		if reg == 0 {
+			return
+		}
+		index := em.fnStore.scriggoFnIndex(ti.value.(*runtime.Method).Func)
+		em.fb.emitLoadFunc(false, index, reg)
+		em.changeRegister(false, reg, reg, em.typ(v), dstType)
+		return
+	}
+
+	// Method expression implemented with a function literal.
+	if ti.MethodType == methodExprFuncLit {
+		em.emitExprR(ti.value.(*ast.Func), dstType, reg)
+		return
+	}
+
 	// Predefined package variable or imported package variable.
 	if index, ok := em.varStore.nonLocalVarIndex(v); ok {
 		if reg == 0 {
@@ -794,7 +841,7 @@ func (em *emitter) emitSelector(v *ast.Selector, reg int8, dstType reflect.Type)
 
 // emitUnaryOp emits the code for the unary expression expr and stores the
 // result in the register reg of type regType.
-func (em *emitter) emitUnaryOp(expr *ast.UnaryOperator, reg int8, regType reflect.Type) {
+func (em *emitter) emitUnaryOp(expr *ast.UnaryOperator, reg int16, regType reflect.Type) {
 
 	var (
 		exprType    = em.typ(expr)
@@ -904,8 +951,16 @@ func (em *emitter) emitUnaryOp(expr *ast.UnaryOperator, reg int8, regType reflec
 		case *ast.Identifier:
 			if em.fb.declaredInFunc(operand.Name) {
 				r := em.fb.scopeLookup(operand.Name)
-				em.fb.emitNew(em.types.PtrTo(exprType), reg)
-				em.fb.emitMove(false, -r, reg, regType.Kind())
+				if canEmitDirectly(exprType.Kind(), regType.Kind()) {
+					em.fb.emitNew(em.types.PtrTo(exprType), reg)
+					em.fb.emitMove(false, -r, reg, regType.Kind())
+					return
+				}
+				em.fb.enterStack()
+				tmp := em.fb.newRegister(reflect.Ptr)
+				em.fb.emitMove(false, -r, tmp, reflect.Ptr)
+				em.changeRegister(false, tmp, reg, exprType, regType)
+				em.fb.exitStack()
 				return
 			}
 			// Address of a non-local variable.
@@ -975,12 +1030,12 @@ func (em *emitter) emitUnaryOp(expr *ast.UnaryOperator, reg int8, regType reflec
 			index := em.fb.makeFieldIndex(field.Index)
 			pos := operand.Expr.Pos()
 			if canEmitDirectly(em.types.PtrTo(field.Type).Kind(), regType.Kind()) {
-				em.fb.emitAddr(exprReg, index, reg, pos)
+				em.fb.emitAddr(exprReg, int16(index), reg, pos)
 				return
 			}
 			em.fb.enterStack()
 			dest := em.fb.newRegister(reflect.Ptr)
-			em.fb.emitAddr(exprReg, index, dest, pos)
+			em.fb.emitAddr(exprReg, int16(index), dest, pos)
 			em.changeRegister(false, dest, reg, em.types.PtrTo(field.Type), regType)
 			em.fb.exitStack()
 
@@ -16,6 +16,7 @@ import (
 func (em *emitter) emitNodes(nodes []ast.Node) {
 
 	for _, node := range nodes {
+		addr := em.fb.currentAddr()
 		switch node := node.(type) {
 
 		case *ast.Assignment:
@@ -27,19 +28,7 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 			em.fb.exitScope()
 
 		case *ast.Break:
-			if em.breakable {
-				if em.breakLabel == nil {
-					label := em.fb.newLabel()
-					em.breakLabel = &label
-				}
-				em.fb.emitGoto(*em.breakLabel)
-			} else {
-				if node.Label != nil {
-					panic(internalError("not implemented"))
-				}
-				em.fb.emitBreak(em.rangeLabels[len(em.rangeLabels)-1][0])
-				em.fb.emitGoto(em.rangeLabels[len(em.rangeLabels)-1][1])
-			}
+			em.emitBranch(node.Label, true)
 
 		case *ast.Comment:
 			// Nothing to do.
@@ -48,15 +37,7 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 			// Nothing to do.
 
 		case *ast.Continue:
-			if node.Label != nil {
-				panic(internalError("not implemented"))
-			}
-			forHead := em.rangeLabels[len(em.rangeLabels)-1][0]
-			if em.inForRange {
-				em.fb.emitContinue(forHead)
-			} else {
-				em.fb.emitGoto(forHead)
-			}
+			em.emitBranch(node.Label, false)
 
 		case *ast.Defer:
 			call := node.Call.(*ast.Call)
@@ -76,7 +57,7 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 				em.fb.emitReturn()
 				em.fb = backup
 				em.fb.emitDefer(fnReg, 0, stackShift, runtime.StackShift{0, 0, 0, 0}, fn.Type)
-				continue
+				break
 			}
 			em.fb.enterStack()
 			_, _ = em.emitCallNode(call, false, true, runtime.ReturnString)
@@ -104,56 +85,43 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 			// emitter.emitSwitch.
 
 		case *ast.For:
-			currentBreakable := em.breakable
-			currentBreakLabel := em.breakLabel
-			em.breakable = true
-			em.breakLabel = nil
 			em.fb.enterScope()
 			if node.Init != nil {
 				em.emitNodes([]ast.Node{node.Init})
 			}
+			forHead := em.fb.newLabel()
+			forPost := em.fb.newLabel()
+			endFor := em.fb.newLabel()
+			em.fb.setLabelAddr(forHead)
 			if node.Condition != nil {
-				forHead := em.fb.newLabel()
-				forPost := em.fb.newLabel()
-				em.fb.setLabelAddr(forHead)
 				em.emitCondition(node.Condition)
-				endForLabel := em.fb.newLabel()
-				em.fb.emitGoto(endForLabel)
-				em.rangeLabels = append(em.rangeLabels, [2]label{forPost, endForLabel})
-				em.emitNodes(node.Body)
-				em.rangeLabels = em.rangeLabels[:len(em.rangeLabels)-1]
-				em.fb.setLabelAddr(forPost)
-				if node.Post != nil {
-					em.emitNodes([]ast.Node{node.Post})
-				}
-				em.fb.emitGoto(forHead)
-				em.fb.setLabelAddr(endForLabel)
-			} else {
-				forLabel := em.fb.newLabel()
-				em.fb.setLabelAddr(forLabel)
-				endForLabel := em.fb.newLabel()
-				em.rangeLabels = append(em.rangeLabels, [2]label{forLabel, endForLabel})
-				em.emitNodes(node.Body)
-				if node.Post != nil {
-					em.emitNodes([]ast.Node{node.Post})
-				}
-				em.fb.emitGoto(forLabel)
-				em.fb.setLabelAddr(endForLabel)
+				em.fb.emitGoto(endFor)
 			}
-			em.fb.exitScope()
-			if em.breakLabel != nil {
-				em.fb.setLabelAddr(*em.breakLabel)
+			em.enterBreakable(node, forPost, endFor)
+			em.emitCover(node.Body)
+			em.emitNodes(node.Body)
+			em.exitBreakable()
+			em.fb.setLabelAddr(forPost)
+			if node.Post != nil {
+				em.emitNodes([]ast.Node{node.Post})
 			}
-			em.breakable = currentBreakable
-			em.breakLabel = currentBreakLabel
+			em.fb.emitGoto(forHead)
+			em.fb.setLabelAddr(endFor)
+			em.fb.exitScope()
 
 		case *ast.ForRange:
 			em.emitForRange(node)
 
 		case *ast.Go:
 			call := node.Call.(*ast.Call)
+			// In templates, the output of a called macro is rendered
+			// asynchronously in the context of the go statement.
+			toFormat := ast.Format(runtime.ReturnString)
+			if em.isTemplate && node.Context <= ast.ContextTOML {
+				toFormat = ast.Format(node.Context)
+			}
 			em.fb.enterStack()
-			_, _ = em.emitCallNode(call, true, false, runtime.ReturnString)
+			_, _ = em.emitCallNode(call, true, false, toFormat)
 			em.fb.exitStack()
 
 		case *ast.Goto:
@@ -178,6 +146,7 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 				endIfLabel := em.fb.newLabel()
 				em.fb.emitGoto(endIfLabel)
 				em.fb.enterScope()
+				em.emitCover(node.Then.Nodes)
 				em.emitNodes(node.Then.Nodes)
 				em.fb.exitScope()
 				em.fb.setLabelAddr(endIfLabel)
@@ -185,6 +154,7 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 				elseLabel := em.fb.newLabel()
 				em.fb.emitGoto(elseLabel)
 				em.fb.enterScope()
+				em.emitCover(node.Then.Nodes)
 				em.emitNodes(node.Then.Nodes)
 				em.fb.exitScope()
 				endIfLabel := em.fb.newLabel()
@@ -194,6 +164,7 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 				case *ast.If:
 					em.emitNodes([]ast.Node{els})
 				case *ast.Block:
+					em.emitCover(els.Nodes)
 					em.emitNodes(els.Nodes)
 				}
 				em.fb.setLabelAddr(endIfLabel)
@@ -212,6 +183,7 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 			}
 			em.fb.setLabelAddr(em.labels[em.fb.fn][node.Ident.Name])
 			if node.Statement != nil {
+				em.stmtLabels[node.Statement] = node.Ident.Name
 				em.emitNodes([]ast.Node{node.Statement})
 			}
 
@@ -219,12 +191,12 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 			if text := node.Text; text != nil {
 				txt := text.Text[node.Text.Cut.Left : len(text.Text)-text.Cut.Right]
 				if len(txt) != 0 {
-					em.fb.emitText(txt, em.inURL, em.isURLSet)
+					em.fb.emitText(txt, em.inURL, em.isURLSet, false)
 				}
 			}
 
 		case *ast.Return:
-			offset := [4]int8{}
+			offset := [4]int16{}
 			// Emit return statements with a function call that returns more
 			// than one value.
 			//
@@ -236,7 +208,7 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 			if len(node.Values) == 1 && fnType.NumOut() > 1 {
 				returnedRegs, types := em.emitCallNode(node.Values[0].(*ast.Call), false, false, runtime.ReturnString)
 				for i, typ := range types {
-					var dstReg int8
+					var dstReg int16
 					switch kindToType(typ.Kind()) {
 					case intRegister:
 						offset[0]++
@@ -254,11 +226,11 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 					em.changeRegister(false, returnedRegs[i], dstReg, typ, fnType.Out(i))
 				}
 				em.fb.emitReturn()
-				continue
+				break
 			}
 			for i, v := range node.Values {
 				typ := fnType.Out(i)
-				var reg int8
+				var reg int16
 				switch kindToType(typ.Kind()) {
 				case intRegister:
 					offset[0]++
@@ -278,13 +250,11 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 			em.fb.emitReturn()
 
 		case *ast.Select:
-			currentBreakable := em.breakable
-			currentBreakLabel := em.breakLabel
-			em.breakable = true
-			em.breakLabel = nil
+			end := em.fb.newLabel()
+			em.enterBreakable(node, 0, end)
 			em.emitSelect(node)
-			em.breakable = currentBreakable
-			em.breakLabel = currentBreakLabel
+			em.exitBreakable()
+			em.fb.setLabelAddr(end)
 
 		case *ast.Send:
 			chanType := em.typ(node.Channel)
@@ -315,37 +285,34 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 			}
 
 		case *ast.Switch:
-			currentBreakable := em.breakable
-			currentBreakLabel := em.breakLabel
-			em.breakable = true
-			em.breakLabel = nil
+			end := em.fb.newLabel()
+			em.enterBreakable(node, 0, end)
 			em.emitSwitch(node)
-			if em.breakLabel != nil {
-				em.fb.setLabelAddr(*em.breakLabel)
-			}
-			em.breakable = currentBreakable
-			em.breakLabel = currentBreakLabel
+			em.exitBreakable()
+			em.fb.setLabelAddr(end)
 
 		case *ast.Text:
-			txt := node.Text[node.Cut.Left : len(node.Text)-node.Cut.Right]
-			if len(txt) != 0 {
-				em.fb.emitText(txt, em.inURL, em.isURLSet)
+			start, end := node.Cut.Left, len(node.Text)-node.Cut.Right
+			// Split the text where a nonce attribute can be inserted.
+			for _, offset := range node.NonceOffsets {
+				if start < offset && offset <= end {
+					em.fb.emitText(node.Text[start:offset], em.inURL, em.isURLSet, true)
+					start = offset
+				}
+			}
+			if start < end {
+				em.fb.emitText(node.Text[start:end], em.inURL, em.isURLSet, false)
 			}
 
 		case *ast.TypeDeclaration:
 			// Nothing to do.
 
 		case *ast.TypeSwitch:
-			currentBreakable := em.breakable
-			currentBreakLabel := em.breakLabel
-			em.breakable = true
-			em.breakLabel = nil
+			end := em.fb.newLabel()
+			em.enterBreakable(node, 0, end)
 			em.emitTypeSwitch(node)
-			if em.breakLabel != nil {
-				em.fb.setLabelAddr(*em.breakLabel)
-			}
-			em.breakable = currentBreakable
-			em.breakLabel = currentBreakLabel
+			em.exitBreakable()
+			em.fb.setLabelAddr(end)
 
 		case *ast.URL:
 			if len(node.Value) == 1 {
@@ -367,13 +334,13 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 			// declaration of a variable on the left side of = would shadow a
 			// variable with the same name on the right (they are two different
 			// variables).
-			varsToBind := make(map[string]int8, len(node.Lhs))
+			varsToBind := make([]varToBind, 0, len(node.Lhs))
 			for i, v := range node.Lhs {
 				if isBlankIdentifier(v) {
 					addresses[i] = em.addressBlankIdent(v.Pos())
 				} else {
 					staticType := em.typ(v)
-					var varr int8
+					var varr int16
 					if em.varStore.mustBeDeclaredAsIndirect(v) {
 						varr = em.fb.newIndirectRegister()
 						addresses[i] = em.addressNewIndirectVar(varr, staticType, v.Pos(), 0)
@@ -381,12 +348,12 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 						varr = em.fb.newRegister(staticType.Kind())
 						addresses[i] = em.addressLocalVar(varr, staticType, v.Pos(), 0)
 					}
-					varsToBind[v.Name] = varr
+					varsToBind = append(varsToBind, varToBind{v.Name, varr, staticType})
 				}
 			}
 			em.assignValuesToAddresses(addresses, node.Rhs)
-			for name, reg := range varsToBind {
-				em.fb.bindVarReg(name, reg)
+			for _, v := range varsToBind {
+				em.fb.bindVarReg(v.name, v.reg, v.typ)
 			}
 
 		case ast.Expression:
@@ -399,6 +366,16 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 
 		}
 
+		// Mark the first instruction of the statement, so that a debugger
+		// can stop before executing it.
+		switch node.(type) {
+		case *ast.Block, *ast.Comment, *ast.Label, *ast.Raw, *ast.Statements, *ast.Text, *ast.URL:
+		default:
+			if em.fb.currentAddr() > addr {
+				em.fb.addStatement(addr, node.Pos())
+			}
+		}
+
 	}
 
 }
@@ -406,7 +383,7 @@ func (em *emitter) emitNodes(nodes []ast.Node) {
 // canOptimizeShowMacro reports whether expr is a call to a macro and if it
 // can be optimized if used in the show statement with context ctx.
 func (em *emitter) canOptimizeShowMacro(expr ast.Expression, ctx ast.Context) bool {
-	if ctx > ast.ContextMarkdown {
+	if ctx > ast.ContextTOML {
 		return false
 	}
 	call, ok := expr.(*ast.Call)
@@ -429,13 +406,21 @@ func (em *emitter) canOptimizeShowMacro(expr ast.Expression, ctx ast.Context) bo
 	return from == to || from == ast.FormatMarkdown && to == ast.FormatHTML
 }
 
+// varToBind holds a variable declared in an assignment that must be bound to
+// its register after the assignment has been emitted.
+type varToBind struct {
+	name string
+	reg  int16
+	typ  reflect.Type
+}
+
 // emitAssignmentNode emits the instructions for an assignment node.
 func (em *emitter) emitAssignmentNode(node *ast.Assignment) {
 
 	// Emit a short declaration.
 	if node.Type == ast.AssignmentDeclaration {
 		addresses := make([]address, len(node.Lhs))
-		varsToBind := make(map[string]int8, len(node.Lhs))
+		varsToBind := make([]varToBind, 0, len(node.Lhs))
 		for i, v := range node.Lhs {
 			pos := v.Pos()
 			if isBlankIdentifier(v) {
@@ -447,7 +432,7 @@ func (em *emitter) emitAssignmentNode(node *ast.Assignment) {
 			// Declare an indirect local variable.
 			if em.varStore.mustBeDeclaredAsIndirect(v) {
 				varr := em.fb.newIndirectRegister()
-				varsToBind[v.Name] = varr
+				varsToBind = append(varsToBind, varToBind{v.Name, varr, varType})
 				addresses[i] = em.addressNewIndirectVar(varr, varType, pos, node.Type)
 				continue
 			}
@@ -457,13 +442,13 @@ func (em *emitter) emitAssignmentNode(node *ast.Assignment) {
 			} else {
 				// Declare a local variable.
 				varr := em.fb.newRegister(varType.Kind())
-				varsToBind[v.Name] = varr
+				varsToBind = append(varsToBind, varToBind{v.Name, varr, varType})
 				addresses[i] = em.addressLocalVar(varr, varType, pos, node.Type)
 			}
 		}
 		em.assignValuesToAddresses(addresses, node.Rhs)
-		for name, reg := range varsToBind {
-			em.fb.bindVarReg(name, reg)
+		for _, v := range varsToBind {
+			em.fb.bindVarReg(v.name, v.reg, v.typ)
 		}
 		return
 	}
@@ -679,9 +664,9 @@ func (em *emitter) emitSelect(selectNode *ast.Select) {
 	// the 'select' statement will be released at the end of it.
 	em.fb.enterStack()
 
-	chs := make([]int8, len(selectNode.Cases))
+	chs := make([]int16, len(selectNode.Cases))
 	ok := em.fb.newRegister(reflect.Bool)
-	value := [4]int8{
+	value := [4]int16{
 		intRegister:     em.fb.newRegister(reflect.Int),
 		floatRegister:   em.fb.newRegister(reflect.Float64),
 		stringRegister:  em.fb.newRegister(reflect.String),
@@ -751,7 +736,7 @@ func (em *emitter) emitSelect(selectNode *ast.Select) {
 			chExpr := receiveExpr.Expr
 			elemType := em.typ(chExpr).Elem()
 			// Split the assignment in the received value and the ok value if this exists.
-			em.fb.bindVarReg("$chanElem", value[kindToType(elemType.Kind())])
+			em.fb.bindVarReg("$chanElem", value[kindToType(elemType.Kind())], nil)
 			pos := chExpr.Pos()
 			valueExpr := ast.NewIdentifier(pos, "$chanElem")
 			em.typeInfos[valueExpr] = em.typeInfos[receiveExpr]
@@ -765,12 +750,13 @@ func (em *emitter) emitSelect(selectNode *ast.Select) {
 				em.typeInfos[okExpr] = &typeInfo{
 					Type: boolType,
 				}
-				em.fb.bindVarReg("$ok", ok)
+				em.fb.bindVarReg("$ok", ok, nil)
 				okAssignment := ast.NewAssignment(pos, assignment.Lhs[1:2], assignment.Type, []ast.Expression{okExpr})
 				em.emitAssignmentNode(okAssignment)
 			}
 		}
 		// Emit the nodes of the body of the case.
+		em.emitCover(cas.Body)
 		em.emitNodes(cas.Body)
 		// All 'case' bodies jump to the end of the 'select' bodies, except for the last one.
 		if i < len(selectNode.Cases)-1 {
@@ -794,7 +780,7 @@ func (em *emitter) emitSwitch(node *ast.Switch) {
 		em.emitNodes([]ast.Node{node.Init})
 	}
 
-	var expr int8
+	var expr int16
 	var typ reflect.Type
 
 	if node.Expr == nil {
@@ -849,6 +835,7 @@ func (em *emitter) emitSwitch(node *ast.Switch) {
 		}
 		em.fb.setLabelAddr(bodyLabels[i])
 		em.fb.enterScope()
+		em.emitCover(cas.Body)
 		em.emitNodes(cas.Body)
 		hasFallthrough := false
 		for i := len(cas.Body) - 1; i >= 0; i-- {
@@ -889,10 +876,10 @@ func (em *emitter) emitTypeSwitch(node *ast.TypeSwitch) {
 		guardNewVar = node.Assignment.Lhs[0].(*ast.Identifier).Name
 	}
 
-	var intReg int8
-	var floatReg int8
-	var stringReg int8
-	var generalReg int8
+	var intReg int16
+	var floatReg int16
+	var stringReg int16
+	var generalReg int16
 
 	// Allocate only the necessary register.
 	// Note that 'expr' has already been allocated; these registers are
@@ -938,7 +925,7 @@ func (em *emitter) emitTypeSwitch(node *ast.TypeSwitch) {
 				em.fb.emitIf(false, expr, runtime.ConditionInterfaceNil, 0, reflect.Interface, clause.Expressions[0].Pos())
 			} else {
 				typ := em.ti(clause.Expressions[0]).Type
-				var reg int8
+				var reg int16
 				switch kindToType(typ.Kind()) {
 				case intRegister:
 					reg = intReg
@@ -989,20 +976,22 @@ func (em *emitter) emitTypeSwitch(node *ast.TypeSwitch) {
 		em.fb.enterScope()
 		if guardNewVar != "" {
 			if len(clause.Expressions) == 1 && !em.isPredeclNil(clause.Expressions[0]) {
-				switch kindToType(em.ti(clause.Expressions[0]).Type.Kind()) {
+				guardType := em.ti(clause.Expressions[0]).Type
+				switch kindToType(guardType.Kind()) {
 				case intRegister:
-					em.fb.bindVarReg(guardNewVar, intReg)
+					em.fb.bindVarReg(guardNewVar, intReg, guardType)
 				case floatRegister:
-					em.fb.bindVarReg(guardNewVar, floatReg)
+					em.fb.bindVarReg(guardNewVar, floatReg, guardType)
 				case stringRegister:
-					em.fb.bindVarReg(guardNewVar, stringReg)
+					em.fb.bindVarReg(guardNewVar, stringReg, guardType)
 				case generalRegister:
-					em.fb.bindVarReg(guardNewVar, generalReg)
+					em.fb.bindVarReg(guardNewVar, generalReg, guardType)
 				}
 			} else {
-				em.fb.bindVarReg(guardNewVar, expr)
+				em.fb.bindVarReg(guardNewVar, expr, em.typ(guardExpr))
 			}
 		}
+		em.emitCover(clause.Body)
 		em.emitNodes(clause.Body)
 		em.fb.exitScope()
 		em.fb.emitGoto(end)
@@ -1016,9 +1005,6 @@ func (em *emitter) emitTypeSwitch(node *ast.TypeSwitch) {
 // emitForRange emits a for range statement.
 func (em *emitter) emitForRange(node *ast.ForRange) {
 
-	inForRange := em.inForRange
-	em.inForRange = true
-
 	em.fb.enterScope()
 
 	vars := node.Assignment.Lhs
@@ -1035,8 +1021,8 @@ func (em *emitter) emitForRange(node *ast.ForRange) {
 	// indirect and move values between them before executing the instructions
 	// of the for statement's body.
 
-	var index, elem int8
-	var indirectIndex, indirectElem int8
+	var index, elem int16
+	var indirectIndex, indirectElem int16
 	var indexType, elemType reflect.Type
 
 	if len(vars) >= 1 && !isBlankIdentifier(vars[0]) {
@@ -1047,9 +1033,9 @@ func (em *emitter) emitForRange(node *ast.ForRange) {
 			if em.varStore.mustBeDeclaredAsIndirect(vars[0].(*ast.Identifier)) {
 				indirectIndex = em.fb.newIndirectRegister()
 				em.fb.emitNew(indexType, -indirectIndex)
-				em.fb.bindVarReg(name, indirectIndex)
+				em.fb.bindVarReg(name, indirectIndex, indexType)
 			} else {
-				em.fb.bindVarReg(name, index)
+				em.fb.bindVarReg(name, index, indexType)
 			}
 		} else {
 			index = em.fb.scopeLookup(name)
@@ -1064,9 +1050,9 @@ func (em *emitter) emitForRange(node *ast.ForRange) {
 			if em.varStore.mustBeDeclaredAsIndirect(vars[1].(*ast.Identifier)) {
 				indirectElem = em.fb.newIndirectRegister()
 				em.fb.emitNew(elemType, -indirectElem)
-				em.fb.bindVarReg(name, indirectElem)
+				em.fb.bindVarReg(name, indirectElem, elemType)
 			} else {
-				em.fb.bindVarReg(name, elem)
+				em.fb.bindVarReg(name, elem, elemType)
 			}
 		} else {
 			elem = em.fb.scopeLookup(name)
@@ -1076,7 +1062,6 @@ func (em *emitter) emitForRange(node *ast.ForRange) {
 	rangeLabel := em.fb.newLabel()
 	em.fb.setLabelAddr(rangeLabel)
 	endRange := em.fb.newLabel()
-	em.rangeLabels = append(em.rangeLabels, [2]label{rangeLabel, endRange})
 	em.fb.emitRange(kExpr, exprReg, index, elem, exprType.Kind())
 	em.fb.emitGoto(endRange)
 	em.fb.enterScope()
@@ -1088,12 +1073,13 @@ func (em *emitter) emitForRange(node *ast.ForRange) {
 		em.changeRegister(false, elem, indirectElem, elemType, elemType)
 	}
 
+	em.enterBreakable(node, rangeLabel, endRange)
+	em.emitCover(node.Body)
 	em.emitNodes(node.Body)
+	em.exitBreakable()
 	em.fb.emitContinue(rangeLabel)
 	em.fb.setLabelAddr(endRange)
-	em.rangeLabels = em.rangeLabels[:len(em.rangeLabels)-1]
 	em.fb.exitScope()
 	em.fb.exitScope()
-	em.inForRange = inForRange
 
 }
@@ -14,19 +14,19 @@ import (
 
 // changeRegister emits the code that move the content of register src to
 // register dst, making a conversion if necessary.
-func (em *emitter) changeRegister(k bool, src, dst int8, srcType reflect.Type, dstType reflect.Type) {
+func (em *emitter) changeRegister(k bool, src, dst int16, srcType reflect.Type, dstType reflect.Type) {
 	em._changeRegister(k, src, dst, srcType, dstType, false)
 }
 
 // changeRegisterConvertFormat behaves like changeRegister but handles a format
 // conversion from a value with type 'markdown' to 'html'.
-func (em *emitter) changeRegisterConvertFormat(k bool, src, dst int8, srcType reflect.Type, dstType reflect.Type) {
+func (em *emitter) changeRegisterConvertFormat(k bool, src, dst int16, srcType reflect.Type, dstType reflect.Type) {
 	em._changeRegister(k, src, dst, srcType, dstType, true)
 }
 
 // _changeRegister should be called only by 'changeRegister' and
 // 'changeRegisterMDToHTML'.
-func (em *emitter) _changeRegister(k bool, src, dst int8, srcType reflect.Type, dstType reflect.Type, mdToHTML bool) {
+func (em *emitter) _changeRegister(k bool, src, dst int16, srcType reflect.Type, dstType reflect.Type, mdToHTML bool) {
 
 	// dst is indirect, so the value must be "typed" to its true (original) type
 	// before putting it into general.
@@ -293,7 +293,7 @@ func (em *emitter) setFunctionVarRefs(fn *runtime.Function, closureVars []ast.Up
 	fn.VarRefs = refs
 }
 
-func (em *emitter) emitValueNotPredefined(ti *typeInfo, reg int8, dstType reflect.Type) (int8, bool) {
+func (em *emitter) emitValueNotPredefined(ti *typeInfo, reg int16, dstType reflect.Type) (int16, bool) {
 	typ := ti.Type
 	if reg == 0 {
 		return reg, false
@@ -301,7 +301,7 @@ func (em *emitter) emitValueNotPredefined(ti *typeInfo, reg int8, dstType reflec
 	// Handle nil values.
 	if ti.value == nil {
 		c := em.fb.makeGeneralValue(reflect.ValueOf(nil))
-		em.changeRegister(true, c, reg, typ, dstType)
+		em.changeRegister(true, int16(c), reg, typ, dstType)
 		return reg, false
 	}
 	switch v := ti.value.(type) {
@@ -334,7 +334,7 @@ func (em *emitter) emitValueNotPredefined(ti *typeInfo, reg int8, dstType reflec
 		return reg, false
 	case string:
 		c := em.fb.makeStringValue(v)
-		em.changeRegister(true, c, reg, typ, dstType)
+		em.changeRegister(true, int16(c), reg, typ, dstType)
 		return reg, false
 	}
 	v := reflect.ValueOf(ti.value)
@@ -369,7 +369,7 @@ func (em *emitter) emitValueNotPredefined(ti *typeInfo, reg int8, dstType reflec
 		reflect.Map,
 		reflect.Ptr:
 		c := em.fb.makeGeneralValue(v)
-		em.changeRegister(true, c, reg, typ, dstType)
+		em.changeRegister(true, int16(c), reg, typ, dstType)
 	case reflect.UnsafePointer:
 		panic(internalError("not implemented"))
 	default:
@@ -381,7 +381,7 @@ func (em *emitter) emitValueNotPredefined(ti *typeInfo, reg int8, dstType reflec
 // emitComparison emits the comparison expression x op y as a sequence of
 // instructions where the last one is an 'if' instruction. ky indicates if y
 // is a constant.
-func (em *emitter) emitComparison(op ast.OperatorType, ky bool, x, y int8, tx, ty reflect.Type, pos *ast.Position) {
+func (em *emitter) emitComparison(op ast.OperatorType, ky bool, x, y int16, tx, ty reflect.Type, pos *ast.Position) {
 	xKind := tx.Kind()
 	yKind := ty.Kind()
 	var condition runtime.Condition
@@ -453,7 +453,7 @@ func (em *emitter) emitComparison(op ast.OperatorType, ky bool, x, y int8, tx, t
 // it emits 'x not contains y'. ky indicates if y is a constant.
 //
 // ty is nil if the expression is 'x contains nil' or 'x not contains nil'.
-func (em *emitter) emitContains(not, ky bool, x, y int8, tx, ty reflect.Type, pos *ast.Position) {
+func (em *emitter) emitContains(not, ky bool, x, y int16, tx, ty reflect.Type, pos *ast.Position) {
 	var condition runtime.Condition
 	var t reflect.Type
 	switch tx.Kind() {
@@ -66,8 +66,9 @@ func scanTemplate(text []byte, format ast.Format, parseShebang, noParseShow, dol
 		noParseShow:      noParseShow,
 	}
 	lex.tag.ctx = ast.ContextHTML
-	if lex.ctx == ast.ContextMarkdown {
-		lex.tag.ctx = ast.ContextMarkdown
+	switch lex.ctx {
+	case ast.ContextMarkdown, ast.ContextXML:
+		lex.tag.ctx = lex.ctx
 	}
 	go lex.scan()
 	return lex
@@ -108,6 +109,13 @@ type lexer struct {
 		index int         // index of first byte of the current attribute value in src
 		ctx   ast.Context // context of the tag's content
 	}
+	yaml struct { // YAML state
+		indent int  // indentation of the current line
+		block  int  // indentation of the line with the indicator of the current block scalar
+		header bool // reports whether the current line contains a block scalar indicator
+		flow   int  // depth of the current flow collection
+	}
+	nonces           []int      // offsets, in the text to emit, where a nonce attribute can be inserted
 	rawMarker        []byte     // raw marker, not nil when a raw statement has been lexed
 	tokens           chan token // tokens, is closed at the end of the scan
 	lastTokenType    tokenTyp   // type of the last non-empty emitted token
@@ -118,6 +126,10 @@ type lexer struct {
 	parseShebang     bool       // parse the shebang line.
 	dollarIdentifier bool       // support the dollar identifier, only if 'extendedSyntax' is true
 	noParseShow      bool       // do not parse the short show statement.
+
+	// comment is the position of the first comment in code, or nil if there
+	// are no comments in code.
+	comment *ast.Position
 }
 
 // newline is called when the lexer encounters a new line.
@@ -165,7 +177,7 @@ func (l *lexer) emitAtLineColumn(line, column int, typ tokenTyp, length int) {
 		}
 		end = start
 	}
-	l.tokens <- token{
+	tok := token{
 		typ: typ,
 		pos: &ast.Position{
 			Line:   line,
@@ -179,6 +191,11 @@ func (l *lexer) emitAtLineColumn(line, column int, typ tokenTyp, length int) {
 		tag: l.tag.name,
 		att: l.tag.attr,
 	}
+	if typ == tokenText && l.nonces != nil {
+		tok.non = l.nonces
+		l.nonces = nil
+	}
+	l.tokens <- tok
 	if l.templateSyntax {
 		switch typ {
 		case tokenRaw:
@@ -228,6 +245,7 @@ func (l *lexer) scan() {
 		col := l.column // token column
 
 		var quote = byte(0)
+		var strQuote = byte(0) // quote of a string in an event handler or style attribute
 		var emittedURL bool
 
 		fileContext := l.ctx
@@ -236,9 +254,17 @@ func (l *lexer) scan() {
 		spacesOnlyLine := true
 
 		isHTML := l.ctx == ast.ContextHTML || l.ctx == ast.ContextMarkdown
+		isXML := l.ctx == ast.ContextXML
 
-		if l.ctx == ast.ContextMarkdown {
+		// Indicate if it is in a YAML or TOML comment, in a YAML tag, anchor
+		// or alias and in a TOML multi-line string.
+		var comment, property, multiline bool
+
+		switch l.ctx {
+		case ast.ContextMarkdown:
 			p, l.ctx = l.scanCodeBlock(0)
+		case ast.ContextYAML:
+			l.yaml.indent, _ = yamlLineIndent(l.src)
 		}
 
 	LOOP:
@@ -377,6 +403,33 @@ func (l *lexer) scan() {
 					p++
 					l.column++
 					l.tag.name, p = l.scanTag(p)
+					if l.tag.name != "" {
+						l.ctx = ast.ContextTag
+						switch l.tag.name {
+						case "script":
+							l.tag.ctx = ast.ContextJS
+							l.nonces = append(l.nonces, p)
+						case "style":
+							l.tag.ctx = ast.ContextCSS
+							l.nonces = append(l.nonces, p)
+						}
+					}
+					continue
+				}
+
+			case ast.ContextXML:
+				if c == '<' {
+					// <![CDATA[
+					if bytes.HasPrefix(l.src[p:], cdataStart) {
+						l.ctx = ast.ContextXMLCDATA
+						p += 9
+						l.column += 9
+						continue
+					}
+					// Start tag.
+					p++
+					l.column++
+					l.tag.name, p = l.scanTag(p)
 					if l.tag.name != "" {
 						l.ctx = ast.ContextTag
 						switch l.tag.name {
@@ -389,16 +442,29 @@ func (l *lexer) scan() {
 					continue
 				}
 
+			case ast.ContextXMLCDATA:
+				if c == ']' && bytes.HasPrefix(l.src[p:], cdataEnd) {
+					// ]]>
+					l.ctx = ast.ContextXML
+					p += 3
+					l.column += 3
+					continue
+				}
+
 			case ast.ContextTag:
-				if c == '>' || c == '/' && p < len(l.src) && l.src[p] == '>' {
+				if c == '>' || c == '/' && p+1 < len(l.src) && l.src[p+1] == '>' {
 					// End tag.
 					l.ctx = l.tag.ctx
-					l.tag.name = ""
-					l.tag.ctx = fileContext
 					if c == '/' {
+						if isXML {
+							// Self-closing tag.
+							l.ctx = fileContext
+						}
 						p++
 						l.column++
 					}
+					l.tag.name = ""
+					l.tag.ctx = fileContext
 				} else if !isASCIISpace(c) {
 					// Check if it is an attribute.
 					var next int
@@ -411,7 +477,16 @@ func (l *lexer) scan() {
 								p++
 								l.column++
 							}
-							if containsURL(l.tag.name, l.tag.attr) {
+							if isXML {
+								switch {
+								case isEventHandler(l.tag.attr):
+									l.ctx = ast.ContextJSAttr
+								case l.tag.attr == "style":
+									l.ctx = ast.ContextCSSAttr
+								default:
+									l.ctx = ast.ContextXMLAttr
+								}
+							} else if containsURL(l.tag.name, l.tag.attr) {
 								l.emitAtLineColumn(lin, col, tokenText, p)
 								if quote == 0 {
 									l.ctx = ast.ContextUnquotedAttr
@@ -423,6 +498,10 @@ func (l *lexer) scan() {
 								p = 0
 								lin = l.line
 								col = l.column
+							} else if isEventHandler(l.tag.attr) {
+								l.ctx = ast.ContextJSAttr
+							} else if l.tag.attr == "style" {
+								l.ctx = ast.ContextCSSAttr
 							} else {
 								l.tag.index = p
 								if quote == 0 {
@@ -481,8 +560,58 @@ func (l *lexer) scan() {
 					}
 				}
 
+			case ast.ContextXMLAttr:
+				if quote != 0 && c == quote || quote == 0 && (c == '>' || isASCIISpace(c)) {
+					// End attribute.
+					quote = 0
+					l.ctx = ast.ContextTag
+					l.tag.attr = ""
+					if c == '>' {
+						continue
+					}
+				}
+
+			case ast.ContextJSAttr, ast.ContextJSStringAttr, ast.ContextCSSAttr, ast.ContextCSSStringAttr:
+				if quote != 0 && c == quote || quote == 0 && (c == '>' || isASCIISpace(c)) {
+					// End attribute.
+					quote = 0
+					strQuote = 0
+					l.ctx = ast.ContextTag
+					l.tag.attr = ""
+					if c == '>' {
+						continue
+					}
+					break
+				}
+				switch l.ctx {
+				case ast.ContextJSAttr, ast.ContextCSSAttr:
+					if c == '"' || c == '\'' {
+						if l.ctx == ast.ContextJSAttr {
+							l.ctx = ast.ContextJSStringAttr
+						} else {
+							l.ctx = ast.ContextCSSStringAttr
+						}
+						strQuote = c
+					}
+				default:
+					switch c {
+					case '\\':
+						if p+1 < len(l.src) && l.src[p+1] == strQuote {
+							p++
+							l.column++
+						}
+					case strQuote:
+						if l.ctx == ast.ContextJSStringAttr {
+							l.ctx = ast.ContextJSAttr
+						} else {
+							l.ctx = ast.ContextCSSAttr
+						}
+						strQuote = 0
+					}
+				}
+
 			case ast.ContextCSS:
-				if isHTML && c == '<' && isEndStyle(l.src[p:]) {
+				if (isHTML || isXML) && c == '<' && isEndStyle(l.src[p:]) {
 					// </style>
 					l.ctx = fileContext
 					p += 7
@@ -503,7 +632,7 @@ func (l *lexer) scan() {
 					l.ctx = ast.ContextCSS
 					quote = 0
 				case '<':
-					if isHTML && isEndStyle(l.src[p:]) {
+					if (isHTML || isXML) && isEndStyle(l.src[p:]) {
 						l.ctx = fileContext
 						quote = 0
 						p += 7
@@ -512,7 +641,7 @@ func (l *lexer) scan() {
 				}
 
 			case ast.ContextJS:
-				if isHTML && c == '<' && isEndScript(l.src[p:]) {
+				if (isHTML || isXML) && c == '<' && isEndScript(l.src[p:]) {
 					// </script>
 					l.ctx = fileContext
 					p += 8
@@ -533,7 +662,7 @@ func (l *lexer) scan() {
 					l.ctx = ast.ContextJS
 					quote = 0
 				case '<':
-					if isHTML && isEndScript(l.src[p:]) {
+					if (isHTML || isXML) && isEndScript(l.src[p:]) {
 						l.ctx = fileContext
 						quote = 0
 						p += 8
@@ -541,6 +670,118 @@ func (l *lexer) scan() {
 					}
 				}
 
+			case ast.ContextYAML, ast.ContextYAMLPlain, ast.ContextYAMLFlowPlain:
+				if comment || c == '\n' {
+					break
+				}
+				if property {
+					property = !isSpace(c)
+					break
+				}
+				if l.ctx != ast.ContextYAML {
+					switch {
+					case c == ':' && isYAMLSeparator(l.src, p+1, l.yaml.flow > 0):
+						l.ctx = ast.ContextYAML
+					case isSpace(c) && p+1 < len(l.src) && l.src[p+1] == '#':
+						l.ctx = ast.ContextYAML
+					case l.yaml.flow > 0 && (c == ',' || c == ']' || c == '}'):
+						l.ctx = ast.ContextYAML
+						if c != ',' {
+							l.yaml.flow--
+						}
+					}
+					break
+				}
+				switch c {
+				case ' ', '\t', '\r', ',':
+				case '#':
+					comment = true
+				case '"':
+					l.ctx = ast.ContextYAMLDoubleQuoted
+				case '\'':
+					l.ctx = ast.ContextYAMLSingleQuoted
+				case '[', '{':
+					l.yaml.flow++
+				case ']', '}':
+					if l.yaml.flow > 0 {
+						l.yaml.flow--
+					}
+				case '|', '>':
+					// Block scalar indicator. The rest of the line is the
+					// block header.
+					if l.yaml.flow == 0 {
+						l.yaml.header = true
+						comment = true
+					}
+				case '-', '?', ':':
+					if !isYAMLSeparator(l.src, p+1, l.yaml.flow > 0) {
+						l.ctx = l.yamlPlain()
+					}
+				case '!', '&', '*':
+					property = true
+				default:
+					l.ctx = l.yamlPlain()
+				}
+
+			case ast.ContextYAMLDoubleQuoted:
+				switch c {
+				case '\\':
+					if p+1 < len(l.src) && (l.src[p+1] == '"' || l.src[p+1] == '\\') {
+						p++
+						l.column++
+					}
+				case '"':
+					l.ctx = ast.ContextYAML
+				}
+
+			case ast.ContextYAMLSingleQuoted:
+				if c == '\'' {
+					if p+1 < len(l.src) && l.src[p+1] == '\'' {
+						p++
+						l.column++
+					} else {
+						l.ctx = ast.ContextYAML
+					}
+				}
+
+			case ast.ContextTOML:
+				if comment {
+					break
+				}
+				switch c {
+				case '#':
+					comment = true
+				case '"', '\'':
+					if c == '"' {
+						l.ctx = ast.ContextTOMLString
+					} else {
+						l.ctx = ast.ContextTOMLLiteralString
+					}
+					multiline = isTripleQuote(l.src[p:], c)
+					if multiline {
+						p += 2
+						l.column += 2
+					}
+				}
+
+			case ast.ContextTOMLString, ast.ContextTOMLLiteralString:
+				switch {
+				case c == '\\' && l.ctx == ast.ContextTOMLString:
+					if p+1 < len(l.src) && (l.src[p+1] == '"' || l.src[p+1] == '\\') {
+						p++
+						l.column++
+					}
+				case c == '"' && l.ctx == ast.ContextTOMLString, c == '\'' && l.ctx == ast.ContextTOMLLiteralString:
+					if !multiline {
+						l.ctx = ast.ContextTOML
+					} else if isTripleQuote(l.src[p:], c) {
+						l.ctx = ast.ContextTOML
+						multiline = false
+						p += 2
+						l.column += 2
+					}
+				}
+
 			case ast.ContextJSON:
 				if isHTML && c == '<' && isEndScript(l.src[p:]) {
 					// </script>
@@ -582,6 +823,12 @@ func (l *lexer) scan() {
 				switch l.ctx {
 				case ast.ContextTabCodeBlock, ast.ContextSpacesCodeBlock:
 					p, l.ctx = l.scanCodeBlock(p)
+				case ast.ContextYAML, ast.ContextYAMLPlain, ast.ContextYAMLFlowPlain, ast.ContextYAMLBlock:
+					comment = false
+					property = false
+					l.yamlLine(p)
+				case ast.ContextTOML:
+					comment = false
 				case ast.ContextMarkdown:
 					if spacesOnlyLine {
 						p, l.ctx = l.scanCodeBlock(p)
@@ -642,6 +889,12 @@ func (l *lexer) scanCodeBlock(p int) (int, ast.Context) {
 	return p, ast.ContextMarkdown
 }
 
+// isEventHandler reports whether the attribute attr is an event handler
+// attribute, as "onclick", whose value is JavaScript code.
+func isEventHandler(attr string) bool {
+	return len(attr) > 2 && attr[0] == 'o' && attr[1] == 'n'
+}
+
 // containsURL reports whether the attribute attr of tag contains an URL or a
 // comma-separated list of URL.
 //
@@ -1052,6 +1305,10 @@ LOOP:
 				endLineAsSemicolon = false
 			}
 		case '/':
+			if len(l.src) > 1 && (l.src[1] == '/' || l.src[1] == '*') && l.comment == nil {
+				start := len(l.text) - len(l.src)
+				l.comment = &ast.Position{Line: l.line, Column: l.column, Start: start, End: start + 1}
+			}
 			if len(l.src) > 1 && l.src[1] == '/' {
 				p := bytes.IndexAny(l.src, "\n"+string(BOM))
 				if p == -1 {
@@ -1282,6 +1539,10 @@ LOOP:
 				l.column++
 			}
 			endLineAsSemicolon = false
+		case '~':
+			l.emit(tokenTilde, 1)
+			l.column++
+			endLineAsSemicolon = false
 		case ':':
 			if len(l.src) > 1 && l.src[1] == '=' {
 				l.emit(tokenDeclaration, 2)
@@ -1378,6 +1639,68 @@ LOOP:
 	return nil
 }
 
+// yamlLine is called at the beginning of a line of a YAML file, with p the
+// index of the line in l.src. It ends the current block scalar, if the line
+// is not indented more than the line of its indicator, or starts a new block
+// scalar if the previous line has a block scalar indicator.
+func (l *lexer) yamlLine(p int) {
+	indent, empty := yamlLineIndent(l.src[p:])
+	if l.yaml.header {
+		l.ctx = ast.ContextYAMLBlock
+		l.yaml.block = l.yaml.indent
+		l.yaml.header = false
+	}
+	if l.ctx == ast.ContextYAMLBlock && (empty || indent > l.yaml.block) {
+		return
+	}
+	l.ctx = ast.ContextYAML
+	l.yaml.indent = indent
+}
+
+// yamlPlain returns the context of a YAML plain scalar starting at the
+// current position: ContextYAMLFlowPlain if it is in a flow collection,
+// ContextYAMLPlain otherwise.
+func (l *lexer) yamlPlain() ast.Context {
+	if l.yaml.flow > 0 {
+		return ast.ContextYAMLFlowPlain
+	}
+	return ast.ContextYAMLPlain
+}
+
+// yamlLineIndent returns the indentation of the YAML line at the beginning of
+// src. empty reports whether the line is empty or if it starts, after the
+// indentation, with a statement or a comment.
+func yamlLineIndent(src []byte) (indent int, empty bool) {
+	for indent < len(src) && src[indent] == ' ' {
+		indent++
+	}
+	src = src[indent:]
+	empty = len(src) == 0 || src[0] == '\n' || src[0] == '\r' ||
+		len(src) > 1 && src[0] == '{' && (src[1] == '%' || src[1] == '#')
+	return indent, empty
+}
+
+// isYAMLSeparator reports whether the byte at index p of src, if it exists,
+// is a separator after a YAML indicator. inFlow reports whether it is in a
+// flow collection.
+func isYAMLSeparator(src []byte, p int, inFlow bool) bool {
+	if p == len(src) {
+		return true
+	}
+	switch src[p] {
+	case ' ', '\t', '\n', '\r':
+		return true
+	case ',', ']', '}':
+		return inFlow
+	}
+	return false
+}
+
+// isTripleQuote reports whether s starts with three quote characters.
+func isTripleQuote(s []byte, quote byte) bool {
+	return len(s) > 2 && s[0] == quote && s[1] == quote && s[2] == quote
+}
+
 // isSpace reports whether s is a space.
 func isSpace(s byte) bool {
 	return s == ' ' || s == '\t' || s == '\n' || s == '\r'
@@ -338,6 +338,19 @@ var contextTests = map[ast.Context]map[string][]ast.Context{
 		`<a class=c>{{ a }}`:                           {ast.ContextText, ast.ContextHTML, ast.ContextHTML, ast.ContextHTML},
 		`<input type="text" disabled class="{{ a }}">`: {ast.ContextText, ast.ContextQuotedAttr, ast.ContextQuotedAttr, ast.ContextQuotedAttr, ast.ContextText},
 		`<input type="text" data-value="{{ a }}">`:     {ast.ContextText, ast.ContextQuotedAttr, ast.ContextQuotedAttr, ast.ContextQuotedAttr, ast.ContextText},
+		`<a onclick="{{ a }}">`:                        {ast.ContextText, ast.ContextJSAttr, ast.ContextJSAttr, ast.ContextJSAttr, ast.ContextText},
+		`<a OnClick="f({{ a }})">`:                     {ast.ContextText, ast.ContextJSAttr, ast.ContextJSAttr, ast.ContextJSAttr, ast.ContextText},
+		`<a onclick={{ a }}>{{ a }}`:                   {ast.ContextText, ast.ContextJSAttr, ast.ContextJSAttr, ast.ContextJSAttr, ast.ContextText, ast.ContextHTML, ast.ContextHTML, ast.ContextHTML},
+		`<a onclick="f('{{ a }}')">`:                   {ast.ContextText, ast.ContextJSStringAttr, ast.ContextJSStringAttr, ast.ContextJSStringAttr, ast.ContextText},
+		`<a onclick='f("{{ a }}")'>`:                   {ast.ContextText, ast.ContextJSStringAttr, ast.ContextJSStringAttr, ast.ContextJSStringAttr, ast.ContextText},
+		`<a onclick="f('\\'{{ a }}')">`:                {ast.ContextText, ast.ContextJSStringAttr, ast.ContextJSStringAttr, ast.ContextJSStringAttr, ast.ContextText},
+		`<a onclick="f('a'){{ a }}">`:                  {ast.ContextText, ast.ContextJSAttr, ast.ContextJSAttr, ast.ContextJSAttr, ast.ContextText},
+		`<a onclick="f('{{ a }}">{{ a }}`:              {ast.ContextText, ast.ContextJSStringAttr, ast.ContextJSStringAttr, ast.ContextJSStringAttr, ast.ContextText, ast.ContextHTML, ast.ContextHTML, ast.ContextHTML},
+		`<a on="{{ a }}">`:                             {ast.ContextText, ast.ContextQuotedAttr, ast.ContextQuotedAttr, ast.ContextQuotedAttr, ast.ContextText},
+		`<div style="{{ a }}">`:                        {ast.ContextText, ast.ContextCSSAttr, ast.ContextCSSAttr, ast.ContextCSSAttr, ast.ContextText},
+		`<div style="color: {{ a }}">{{ a }}`:          {ast.ContextText, ast.ContextCSSAttr, ast.ContextCSSAttr, ast.ContextCSSAttr, ast.ContextText, ast.ContextHTML, ast.ContextHTML, ast.ContextHTML},
+		`<div style="font-family: '{{ a }}'">`:         {ast.ContextText, ast.ContextCSSStringAttr, ast.ContextCSSStringAttr, ast.ContextCSSStringAttr, ast.ContextText},
+		`<div style={{ a }} class="{{ a }}">`:          {ast.ContextText, ast.ContextCSSAttr, ast.ContextCSSAttr, ast.ContextCSSAttr, ast.ContextText, ast.ContextQuotedAttr, ast.ContextQuotedAttr, ast.ContextQuotedAttr, ast.ContextText},
 		"<style>s{{a}}t</style>{{a}}":                  {ast.ContextText, ast.ContextCSS, ast.ContextCSS, ast.ContextCSS, ast.ContextText, ast.ContextHTML, ast.ContextHTML, ast.ContextHTML},
 		`<style>{{a}}"{{a}}"</style>`:                  {ast.ContextText, ast.ContextCSS, ast.ContextCSS, ast.ContextCSS, ast.ContextText, ast.ContextCSSString, ast.ContextCSSString, ast.ContextCSSString, ast.ContextText},
 		`<style>"{{a}}"{{a}}</style>`:                  {ast.ContextText, ast.ContextCSSString, ast.ContextCSSString, ast.ContextCSSString, ast.ContextText, ast.ContextCSS, ast.ContextCSS, ast.ContextCSS, ast.ContextText},
@@ -461,6 +474,49 @@ var contextTests = map[ast.Context]map[string][]ast.Context{
 		`a{{a}}a`: {ast.ContextText, ast.ContextSpacesCodeBlock, ast.ContextSpacesCodeBlock, ast.ContextSpacesCodeBlock, ast.ContextText},
 		`\{{a}}`:  {ast.ContextText, ast.ContextSpacesCodeBlock, ast.ContextSpacesCodeBlock, ast.ContextSpacesCodeBlock},
 	},
+	ast.ContextXML: {
+		`a`:                                {ast.ContextText},
+		`a{{a}}a`:                          {ast.ContextText, ast.ContextXML, ast.ContextXML, ast.ContextXML, ast.ContextText},
+		`<a b="{{a}}">{{a}}</a>`:           {ast.ContextText, ast.ContextXMLAttr, ast.ContextXMLAttr, ast.ContextXMLAttr, ast.ContextText, ast.ContextXML, ast.ContextXML, ast.ContextXML, ast.ContextText},
+		`<a b='{{a}}' c="d">{{a}}`:         {ast.ContextText, ast.ContextXMLAttr, ast.ContextXMLAttr, ast.ContextXMLAttr, ast.ContextText, ast.ContextXML, ast.ContextXML, ast.ContextXML},
+		`<a {{a}}>`:                        {ast.ContextText, ast.ContextTag, ast.ContextTag, ast.ContextTag, ast.ContextText},
+		`<a/>{{a}}`:                        {ast.ContextText, ast.ContextXML, ast.ContextXML, ast.ContextXML},
+		`<![CDATA[{{a}}]]>{{a}}`:           {ast.ContextText, ast.ContextXMLCDATA, ast.ContextXMLCDATA, ast.ContextXMLCDATA, ast.ContextText, ast.ContextXML, ast.ContextXML, ast.ContextXML},
+		`<![CDATA[<a b="{{a}}">]]>`:        {ast.ContextText, ast.ContextXMLCDATA, ast.ContextXMLCDATA, ast.ContextXMLCDATA, ast.ContextText},
+		`<script>{{a}}</script>{{a}}`:      {ast.ContextText, ast.ContextJS, ast.ContextJS, ast.ContextJS, ast.ContextText, ast.ContextXML, ast.ContextXML, ast.ContextXML},
+		`<script>"{{a}}"</script>`:         {ast.ContextText, ast.ContextJSString, ast.ContextJSString, ast.ContextJSString, ast.ContextText},
+		`<script/>{{a}}`:                   {ast.ContextText, ast.ContextXML, ast.ContextXML, ast.ContextXML},
+		`<style>{{a}}</style>{{a}}`:        {ast.ContextText, ast.ContextCSS, ast.ContextCSS, ast.ContextCSS, ast.ContextText, ast.ContextXML, ast.ContextXML, ast.ContextXML},
+		`<a href="{{a}}" onclick="{{a}}">`: {ast.ContextText, ast.ContextXMLAttr, ast.ContextXMLAttr, ast.ContextXMLAttr, ast.ContextText, ast.ContextJSAttr, ast.ContextJSAttr, ast.ContextJSAttr, ast.ContextText},
+		`<a onclick="f('{{a}}')">`:         {ast.ContextText, ast.ContextJSStringAttr, ast.ContextJSStringAttr, ast.ContextJSStringAttr, ast.ContextText},
+		`<a style="{{a}}">`:                {ast.ContextText, ast.ContextCSSAttr, ast.ContextCSSAttr, ast.ContextCSSAttr, ast.ContextText},
+	},
+	ast.ContextYAML: {
+		`a`:                              {ast.ContextText},
+		`{{a}}`:                          {ast.ContextYAML, ast.ContextYAML, ast.ContextYAML},
+		`a: {{a}}`:                       {ast.ContextText, ast.ContextYAML, ast.ContextYAML, ast.ContextYAML},
+		`{{a}}: {{a}}`:                   {ast.ContextYAML, ast.ContextYAML, ast.ContextYAML, ast.ContextText, ast.ContextYAML, ast.ContextYAML, ast.ContextYAML},
+		"- {{a}}\n- b{{a}}":              {ast.ContextText, ast.ContextYAML, ast.ContextYAML, ast.ContextYAML, ast.ContextText, ast.ContextYAMLPlain, ast.ContextYAMLPlain, ast.ContextYAMLPlain},
+		`a: b:{{a}}`:                     {ast.ContextText, ast.ContextYAMLPlain, ast.ContextYAMLPlain, ast.ContextYAMLPlain},
+		`a: b {{a}} # {{a}}`:             {ast.ContextText, ast.ContextYAMLPlain, ast.ContextYAMLPlain, ast.ContextYAMLPlain, ast.ContextText, ast.ContextYAML, ast.ContextYAML, ast.ContextYAML},
+		`a: "b{{a}}\"{{a}}" # '{{a}}`:    {ast.ContextText, ast.ContextYAMLDoubleQuoted, ast.ContextYAMLDoubleQuoted, ast.ContextYAMLDoubleQuoted, ast.ContextText, ast.ContextYAMLDoubleQuoted, ast.ContextYAMLDoubleQuoted, ast.ContextYAMLDoubleQuoted, ast.ContextText, ast.ContextYAML, ast.ContextYAML, ast.ContextYAML},
+		"a: 'b''{{a}}'\nc: {{a}}":        {ast.ContextText, ast.ContextYAMLSingleQuoted, ast.ContextYAMLSingleQuoted, ast.ContextYAMLSingleQuoted, ast.ContextText, ast.ContextYAML, ast.ContextYAML, ast.ContextYAML},
+		`a: [{{a}}, b{{a}}, {c: {{a}}}]`: {ast.ContextText, ast.ContextYAML, ast.ContextYAML, ast.ContextYAML, ast.ContextText, ast.ContextYAMLFlowPlain, ast.ContextYAMLFlowPlain, ast.ContextYAMLFlowPlain, ast.ContextText, ast.ContextYAML, ast.ContextYAML, ast.ContextYAML, ast.ContextText},
+		`a: {b{{a}}: c{{a}}}, d{{a}}`:    {ast.ContextText, ast.ContextYAMLFlowPlain, ast.ContextYAMLFlowPlain, ast.ContextYAMLFlowPlain, ast.ContextText, ast.ContextYAMLFlowPlain, ast.ContextYAMLFlowPlain, ast.ContextYAMLFlowPlain, ast.ContextText, ast.ContextYAMLPlain, ast.ContextYAMLPlain, ast.ContextYAMLPlain},
+		`a: !!str {{a}}`:                 {ast.ContextText, ast.ContextYAML, ast.ContextYAML, ast.ContextYAML},
+		`a: &b {{a}}`:                    {ast.ContextText, ast.ContextYAML, ast.ContextYAML, ast.ContextYAML},
+		"a: |\n  {{a}}\n\n  b\nc: {{a}}": {ast.ContextText, ast.ContextYAMLBlock, ast.ContextYAMLBlock, ast.ContextYAMLBlock, ast.ContextText, ast.ContextYAML, ast.ContextYAML, ast.ContextYAML},
+		"a:\n  b: >-\n    c\n    {{a}}\n  d: {{a}}": {ast.ContextText, ast.ContextYAMLBlock, ast.ContextYAMLBlock, ast.ContextYAMLBlock, ast.ContextText, ast.ContextYAML, ast.ContextYAML, ast.ContextYAML},
+		"a: |\nb: {{a}}": {ast.ContextText, ast.ContextYAML, ast.ContextYAML, ast.ContextYAML},
+	},
+	ast.ContextTOML: {
+		`a`:                               {ast.ContextText},
+		`a = {{a}}`:                       {ast.ContextText, ast.ContextTOML, ast.ContextTOML, ast.ContextTOML},
+		`{{a}} = "{{a}}\"{{a}}"`:          {ast.ContextTOML, ast.ContextTOML, ast.ContextTOML, ast.ContextText, ast.ContextTOMLString, ast.ContextTOMLString, ast.ContextTOMLString, ast.ContextText, ast.ContextTOMLString, ast.ContextTOMLString, ast.ContextTOMLString, ast.ContextText},
+		"a = '{{a}}' # '{{a}}\nb = {{a}}": {ast.ContextText, ast.ContextTOMLLiteralString, ast.ContextTOMLLiteralString, ast.ContextTOMLLiteralString, ast.ContextText, ast.ContextTOML, ast.ContextTOML, ast.ContextTOML, ast.ContextText, ast.ContextTOML, ast.ContextTOML, ast.ContextTOML},
+		"a = \"\"\"\n\"{{a}}\"\"\n{{a}}\"\"\"\nb = {{a}}": {ast.ContextText, ast.ContextTOMLString, ast.ContextTOMLString, ast.ContextTOMLString, ast.ContextText, ast.ContextTOMLString, ast.ContextTOMLString, ast.ContextTOMLString, ast.ContextText, ast.ContextTOML, ast.ContextTOML, ast.ContextTOML},
+		"a = '''{{a}}\n'{{a}}'''{{a}}":                    {ast.ContextText, ast.ContextTOMLLiteralString, ast.ContextTOMLLiteralString, ast.ContextTOMLLiteralString, ast.ContextText, ast.ContextTOMLLiteralString, ast.ContextTOMLLiteralString, ast.ContextTOMLLiteralString, ast.ContextText, ast.ContextTOML, ast.ContextTOML, ast.ContextTOML},
+	},
 }
 
 var macroAndUsingContextTests = map[string][]ast.Context{
@@ -658,6 +714,9 @@ CONTEXTS:
 				extendedSyntax: true,
 			}
 			lex.tag.ctx = ast.ContextHTML
+			if ctx == ast.ContextXML {
+				lex.tag.ctx = ast.ContextXML
+			}
 			go lex.scan()
 			var i int
 			for tok := range lex.Tokens() {
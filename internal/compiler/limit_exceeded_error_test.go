@@ -48,7 +48,7 @@ func TestRegistersLimit(t *testing.T) {
 			}()
 
 			fb := newTestBuilder()
-			for i = 0; i < 1000; i++ {
+			for i = 0; i < maxRegistersCount+1; i++ {
 				fb.newRegister(kind)
 			}
 
@@ -78,7 +78,7 @@ func TestFunctionsLimit(t *testing.T) {
 	}()
 
 	fb := newTestBuilder()
-	for i = 0; i < 1000; i++ {
+	for i = 0; i < maxScriggoFunctionsCount+1; i++ {
 		fn := &runtime.Function{
 			Pkg:    fb.fn.Pkg,
 			File:   fb.fn.File,
@@ -112,7 +112,7 @@ func TestTypesLimit(t *testing.T) {
 	}()
 
 	fb := newTestBuilder()
-	for i = 0; i < 1000; i++ {
+	for i = 0; i < maxTypesCount+1; i++ {
 		typ := reflect.ArrayOf(i, intType)
 		fb.emitNew(typ, 0)
 	}
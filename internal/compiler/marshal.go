@@ -0,0 +1,1442 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compiler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"unsafe"
+
+	"github.com/open2b/scriggo/ast"
+	"github.com/open2b/scriggo/internal/compiler/types"
+	"github.com/open2b/scriggo/internal/runtime"
+	"github.com/open2b/scriggo/native"
+)
+
+// The binary encoding of a code starts with marshalMagic followed by the
+// version of the encoding. The version must be incremented every time the
+// encoding, or the meaning of the encoded instructions, changes.
+const (
+	marshalMagic   = "scriggo\x00"
+	marshalVersion = 1
+)
+
+// errInvalidCode is the error returned by Unmarshal when data is not a valid
+// encoding of a code.
+var errInvalidCode = errors.New("scriggo: invalid marshaled code")
+
+// Sources of the native declarations.
+const (
+	globalsSource       = iota // globals
+	globalPackageSource        // package declared in the globals
+	importedSource             // imported package
+)
+
+// Encoding of the types.
+const (
+	typeBasic     = iota // predeclared type, except error
+	typeError            // error type
+	typeEnv              // native.Env type
+	typeFormat           // format type
+	typeNative           // type reachable from a native declaration
+	typeDefined          // type defined in Scriggo
+	typeArray            // array type
+	typeChan             // channel type
+	typeFunc             // function type
+	typeInterface        // empty interface type
+	typeMap              // map type
+	typePtr              // pointer type
+	typeSlice            // slice type
+	typeStruct           // struct type
+)
+
+// Steps to reach a type from another type.
+const (
+	stepElem   = iota // t.Elem()
+	stepKey           // t.Key()
+	stepIn            // t.In(i)
+	stepOut           // t.Out(i)
+	stepField         // t.Field(i).Type
+	stepMethod        // t.Method(i).Type
+)
+
+// Encoding of the native functions.
+const (
+	nativeNil         = iota // nil function
+	nativeComplex            // function of a complex operation
+	nativeBuiltin            // builtin called by a defer or go statement
+	nativeDecl               // function declared in the globals or in a native package
+	nativeMethod             // method of a concrete type
+	nativeIfaceMethod        // method expression of an interface type
+)
+
+// Encoding of the general values.
+const (
+	generalInvalid = iota // invalid value
+	generalZero           // zero value
+	generalComplex        // complex value
+)
+
+// basicTypes contains the predeclared types, except error, indexed by kind.
+var basicTypes = [...]reflect.Type{
+	reflect.Bool:          reflect.TypeOf(false),
+	reflect.Int:           reflect.TypeOf(0),
+	reflect.Int8:          reflect.TypeOf(int8(0)),
+	reflect.Int16:         reflect.TypeOf(int16(0)),
+	reflect.Int32:         reflect.TypeOf(int32(0)),
+	reflect.Int64:         reflect.TypeOf(int64(0)),
+	reflect.Uint:          reflect.TypeOf(uint(0)),
+	reflect.Uint8:         reflect.TypeOf(uint8(0)),
+	reflect.Uint16:        reflect.TypeOf(uint16(0)),
+	reflect.Uint32:        reflect.TypeOf(uint32(0)),
+	reflect.Uint64:        reflect.TypeOf(uint64(0)),
+	reflect.Uintptr:       reflect.TypeOf(uintptr(0)),
+	reflect.Float32:       reflect.TypeOf(float32(0)),
+	reflect.Float64:       reflect.TypeOf(float64(0)),
+	reflect.Complex64:     reflect.TypeOf(complex64(0)),
+	reflect.Complex128:    reflect.TypeOf(complex128(0)),
+	reflect.String:        reflect.TypeOf(""),
+	reflect.UnsafePointer: reflect.TypeOf(unsafe.Pointer(nil)),
+}
+
+// deferGoBuiltins contains the builtins that, called by a defer or go
+// statement, are emitted as native functions.
+var deferGoBuiltins = map[string]bool{
+	"close":   true,
+	"copy":    true,
+	"delete":  true,
+	"panic":   true,
+	"print":   true,
+	"println": true,
+	"recover": true,
+}
+
+// declRef is a reference to a native declaration.
+type declRef struct {
+	source byte   // source of the declaration.
+	pkg    string // name of the global package or path of the imported package.
+	name   string // name of the declaration.
+}
+
+// typeStep is a step to reach a type from another type.
+type typeStep struct {
+	op byte
+	i  int
+}
+
+// typePath is the path to reach a type starting from the type of a native
+// declaration.
+type typePath struct {
+	ref   declRef
+	steps []typeStep
+	typ   reflect.Type
+}
+
+// indexedDecl is a native declaration with its value.
+type indexedDecl struct {
+	ref   declRef
+	value reflect.Value
+}
+
+// namedFunc is the key of a native function indexed by code pointer and
+// name.
+type namedFunc struct {
+	ptr  uintptr
+	name string
+}
+
+// nativeIndex indexes the native declarations used in a code.
+type nativeIndex struct {
+	funcs map[uintptr][]indexedDecl   // functions indexed by code pointer.
+	named map[namedFunc][]indexedDecl // functions indexed by code pointer and name.
+	vars  map[uintptr][]indexedDecl   // variables indexed by address.
+	types map[reflect.Type]typePath   // types reachable from the declarations.
+}
+
+// buffer is a buffer in which a code is encoded.
+type buffer []byte
+
+func (b *buffer) putByte(c byte) {
+	*b = append(*b, c)
+}
+
+func (b *buffer) putBool(v bool) {
+	if v {
+		*b = append(*b, 1)
+	} else {
+		*b = append(*b, 0)
+	}
+}
+
+func (b *buffer) putUint(n uint64) {
+	var s [binary.MaxVarintLen64]byte
+	i := binary.PutUvarint(s[:], n)
+	*b = append(*b, s[:i]...)
+}
+
+func (b *buffer) putInt(n int64) {
+	var s [binary.MaxVarintLen64]byte
+	i := binary.PutVarint(s[:], n)
+	*b = append(*b, s[:i]...)
+}
+
+func (b *buffer) putString(s string) {
+	b.putUint(uint64(len(s)))
+	*b = append(*b, s...)
+}
+
+func (b *buffer) putBytes(s []byte) {
+	b.putUint(uint64(len(s)))
+	*b = append(*b, s...)
+}
+
+func (b *buffer) putPosition(pos runtime.Position) {
+	b.putUint(uint64(pos.Line))
+	b.putUint(uint64(pos.Column))
+	b.putUint(uint64(pos.Start))
+	b.putUint(uint64(pos.End))
+}
+
+func (b *buffer) putDeclRef(ref declRef) {
+	b.putByte(ref.source)
+	b.putString(ref.pkg)
+	b.putString(ref.name)
+}
+
+// marshalError is the type of the errors with which the marshaler and the
+// unmarshaler panic.
+type marshalError struct {
+	err error
+}
+
+// Marshal returns the binary encoding of code. opts must have the same
+// globals, importer and format types used to build code; they are used to
+// encode the references to the native declarations.
+//
+// The encoding can be decoded with Unmarshal.
+func Marshal(code *Code, opts Options) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(marshalError); ok {
+				data = nil
+				err = e.err
+				return
+			}
+			panic(r)
+		}
+	}()
+	m := &marshaler{
+		opts:      opts,
+		packages:  code.NativePackages,
+		typeIndex: map[reflect.Type]int{},
+		fnIndex:   map[*runtime.Function]int{},
+	}
+	body := m.marshal(code)
+	b := buffer(marshalMagic)
+	b.putUint(marshalVersion)
+	b.putUint(uint64(len(code.NativePackages)))
+	for _, path := range code.NativePackages {
+		b.putString(path)
+	}
+	b.putUint(uint64(len(m.typeIndex)))
+	b = append(b, m.types...)
+	b = append(b, body...)
+	return b, nil
+}
+
+// marshaler encodes a code.
+type marshaler struct {
+	opts      Options
+	packages  []string
+	types     buffer
+	typeIndex map[reflect.Type]int
+	fnIndex   map[*runtime.Function]int
+	functions []*runtime.Function
+	natives   *nativeIndex
+
+	// withMethods are the encoded defined types with methods declared in
+	// Scriggo code.
+	withMethods []reflect.Type
+}
+
+// errorf panics with a marshaling error.
+func (m *marshaler) errorf(format string, a ...interface{}) {
+	panic(marshalError{fmt.Errorf("scriggo: cannot marshal code: "+format, a...)})
+}
+
+// marshal encodes the functions, the globals, the methods and the coverage
+// blocks of code and returns the encoding. The types used are added to m.types.
+//
+// Encoding a type can add new functions, the methods of a defined type, so
+// the globals are encoded before the functions and the functions are
+// encoded until there are no more functions to encode.
+func (m *marshaler) marshal(code *Code) buffer {
+	var globals buffer
+	globals.putUint(uint64(len(code.Globals)))
+	for _, global := range code.Globals {
+		globals.putString(global.Pkg)
+		globals.putString(global.Name)
+		m.putTypeOrNil(&globals, global.Type)
+		if !global.Value.IsValid() {
+			globals.putBool(false)
+			continue
+		}
+		globals.putBool(true)
+		globals.putDeclRef(m.varRef(global))
+	}
+	var functions buffer
+	m.addFunction(code.Main)
+	for i := 0; i < len(m.functions); i++ {
+		m.putFunction(&functions, m.functions[i])
+	}
+	var b buffer
+	b.putUint(uint64(len(m.functions)))
+	b = append(b, functions...)
+	b = append(b, globals...)
+	b.putUint(uint64(len(m.withMethods)))
+	for _, t := range m.withMethods {
+		methods := types.Methods(t)
+		b.putUint(uint64(m.typeIndex[t]))
+		b.putUint(uint64(len(methods)))
+		for _, method := range methods {
+			b.putString(method.Name)
+			b.putUint(uint64(m.typeIndex[method.Type]))
+			b.putBool(method.Pointer)
+			b.putUint(uint64(m.fnIndex[method.Func]))
+		}
+	}
+	b.putUint(uint64(len(code.Coverage)))
+	for _, block := range code.Coverage {
+		b.putString(block.Path)
+		b.putUint(uint64(block.StartLine))
+		b.putUint(uint64(block.StartCol))
+		b.putUint(uint64(block.EndLine))
+		b.putUint(uint64(block.EndCol))
+		b.putUint(uint64(block.NumStmt))
+	}
+	return b
+}
+
+// addFunction adds fn, and all the functions reachable from fn, to the
+// functions to encode.
+func (m *marshaler) addFunction(fn *runtime.Function) {
+	if _, ok := m.fnIndex[fn]; ok {
+		return
+	}
+	m.fnIndex[fn] = len(m.functions)
+	m.functions = append(m.functions, fn)
+	if fn.Parent != nil {
+		m.addFunction(fn.Parent)
+	}
+	for _, f := range fn.Functions {
+		m.addFunction(f)
+	}
+}
+
+// putFunction encodes the function fn.
+func (m *marshaler) putFunction(b *buffer, fn *runtime.Function) {
+	b.putString(fn.Pkg)
+	b.putString(fn.Name)
+	b.putString(fn.File)
+	if fn.Pos == nil {
+		b.putBool(false)
+	} else {
+		b.putBool(true)
+		b.putPosition(*fn.Pos)
+	}
+	m.putTypeOrNil(b, fn.Type)
+	if fn.Parent == nil {
+		b.putUint(0)
+	} else {
+		b.putUint(uint64(m.fnIndex[fn.Parent]) + 1)
+	}
+	b.putUint(uint64(len(fn.VarRefs)))
+	for _, ref := range fn.VarRefs {
+		b.putInt(int64(ref))
+	}
+	b.putUint(uint64(len(fn.Types)))
+	for _, t := range fn.Types {
+		b.putUint(uint64(m.typ(t)))
+	}
+	for _, n := range fn.NumReg {
+		b.putInt(int64(n))
+	}
+	b.putUint(uint64(len(fn.FinalRegs)))
+	for _, regs := range fn.FinalRegs {
+		b.putInt(int64(regs[0]))
+		b.putInt(int64(regs[1]))
+	}
+	b.putBool(fn.Macro)
+	b.putUint(uint64(fn.Format))
+	b.putUint(uint64(len(fn.Values.Int)))
+	for _, v := range fn.Values.Int {
+		b.putInt(v)
+	}
+	b.putUint(uint64(len(fn.Values.Float)))
+	for _, v := range fn.Values.Float {
+		b.putUint(math.Float64bits(v))
+	}
+	b.putUint(uint64(len(fn.Values.String)))
+	for _, v := range fn.Values.String {
+		b.putString(v)
+	}
+	b.putUint(uint64(len(fn.Values.General)))
+	for _, v := range fn.Values.General {
+		m.putGeneralValue(b, v)
+	}
+	b.putUint(uint64(len(fn.FieldIndexes)))
+	for _, index := range fn.FieldIndexes {
+		b.putUint(uint64(len(index)))
+		for _, i := range index {
+			b.putUint(uint64(i))
+		}
+	}
+	b.putUint(uint64(len(fn.Functions)))
+	for _, f := range fn.Functions {
+		b.putUint(uint64(m.fnIndex[f]))
+	}
+	b.putUint(uint64(len(fn.NativeFunctions)))
+	for _, f := range fn.NativeFunctions {
+		m.putNativeFunction(b, f)
+	}
+	b.putUint(uint64(len(fn.Body)))
+	for _, in := range fn.Body {
+		var s [8]byte
+		binary.LittleEndian.PutUint16(s[0:], uint16(in.Op))
+		binary.LittleEndian.PutUint16(s[2:], uint16(in.A))
+		binary.LittleEndian.PutUint16(s[4:], uint16(in.B))
+		binary.LittleEndian.PutUint16(s[6:], uint16(in.C))
+		*b = append(*b, s[:]...)
+	}
+	b.putUint(uint64(len(fn.Text)))
+	for _, txt := range fn.Text {
+		b.putBytes(txt)
+	}
+	addrs := make([]runtime.Addr, 0, len(fn.DebugInfo))
+	for addr := range fn.DebugInfo {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+	b.putUint(uint64(len(addrs)))
+	for _, addr := range addrs {
+		info := fn.DebugInfo[addr]
+		b.putUint(uint64(addr))
+		b.putPosition(info.Position)
+		b.putString(info.Path)
+		for _, k := range info.OperandKind {
+			b.putByte(byte(k))
+		}
+		m.putTypeOrNil(b, info.FuncType)
+		b.putBool(info.Statement)
+	}
+	b.putUint(uint64(len(fn.Locals)))
+	for _, local := range fn.Locals {
+		b.putString(local.Name)
+		b.putUint(uint64(m.typ(local.Type)))
+		b.putInt(int64(local.Reg))
+		b.putUint(uint64(local.Start))
+		b.putUint(uint64(local.End))
+	}
+}
+
+// putGeneralValue encodes the general value v.
+func (m *marshaler) putGeneralValue(b *buffer, v reflect.Value) {
+	switch {
+	case !v.IsValid():
+		b.putByte(generalInvalid)
+	case v.IsZero():
+		b.putByte(generalZero)
+		b.putUint(uint64(m.typ(v.Type())))
+	case v.Kind() == reflect.Complex64 || v.Kind() == reflect.Complex128:
+		b.putByte(generalComplex)
+		b.putUint(uint64(m.typ(v.Type())))
+		c := v.Complex()
+		b.putUint(math.Float64bits(real(c)))
+		b.putUint(math.Float64bits(imag(c)))
+	default:
+		m.errorf("unexpected value of type %s", v.Type())
+	}
+}
+
+// putNativeFunction encodes the native function fn.
+func (m *marshaler) putNativeFunction(b *buffer, fn *runtime.NativeFunction) {
+	pkg, name := fn.Package(), fn.Name()
+	b.putString(pkg)
+	b.putString(name)
+	if pkg == "scriggo.complex" {
+		b.putByte(nativeComplex)
+		return
+	}
+	rv := reflect.ValueOf(fn.Func())
+	typ := rv.Type()
+	if rv.IsNil() {
+		b.putByte(nativeNil)
+		b.putUint(uint64(m.typ(typ)))
+		return
+	}
+	ptr := rv.Pointer()
+	if pkg == "" && deferGoBuiltins[name] && deferGoBuiltin(name).value.(reflect.Value).Pointer() == ptr {
+		b.putByte(nativeBuiltin)
+		return
+	}
+	// Look for a declaration, giving precedence to one with the same name.
+	// Different closures can have the same code pointer, so the functions
+	// are also indexed by name.
+	var ref declRef
+	var found bool
+	for _, decls := range [][]indexedDecl{m.index().named[namedFunc{ptr, name}], m.index().funcs[ptr]} {
+		for _, decl := range decls {
+			if decl.value.Type() == typ {
+				ref = decl.ref
+				found = true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+	if found {
+		b.putByte(nativeDecl)
+		b.putDeclRef(ref)
+		b.putUint(uint64(m.typ(typ)))
+		return
+	}
+	// Look for a method. The name of the native function can be empty if
+	// the method has been called on an expression.
+	if typ.NumIn() > 0 {
+		recv := typ.In(0)
+		if recv.Kind() == reflect.Interface {
+			if method, ok := recv.MethodByName(name); ok && interfaceMethodExpr(recv, method).Type() == typ {
+				b.putByte(nativeIfaceMethod)
+				b.putUint(uint64(m.typ(recv)))
+				b.putString(method.Name)
+				return
+			}
+		} else {
+			for i := 0; i < recv.NumMethod(); i++ {
+				method := recv.Method(i)
+				if method.Func.Type() == typ && method.Func.Pointer() == ptr {
+					b.putByte(nativeMethod)
+					b.putUint(uint64(m.typ(recv)))
+					b.putString(method.Name)
+					return
+				}
+			}
+		}
+	}
+	if pkg != "" && pkg != "main" {
+		name = pkg + "." + name
+	}
+	m.errorf("native function %s is not declared in globals or in an imported package", name)
+}
+
+// varRef returns the reference to the native variable of global.
+func (m *marshaler) varRef(global Global) declRef {
+	if global.Value.CanAddr() {
+		typ := global.Value.Type()
+		var ref declRef
+		var found bool
+		for _, decl := range m.index().vars[global.Value.UnsafeAddr()] {
+			if decl.value.Type().Elem() == typ && (!found || decl.ref.name == global.Name) {
+				ref = decl.ref
+				found = true
+			}
+		}
+		if found {
+			return ref
+		}
+	}
+	name := global.Name
+	if global.Pkg != "main" {
+		name = global.Pkg + "." + name
+	}
+	m.errorf("variable %s is not declared in globals or in an imported package", name)
+	return declRef{}
+}
+
+// putTypeOrNil encodes the type t, that can be nil.
+func (m *marshaler) putTypeOrNil(b *buffer, t reflect.Type) {
+	if t == nil {
+		b.putUint(0)
+		return
+	}
+	b.putUint(uint64(m.typ(t)) + 1)
+}
+
+// typ encodes the type t, if it has not already been encoded, and returns
+// its index.
+func (m *marshaler) typ(t reflect.Type) int {
+	if i, ok := m.typeIndex[t]; ok {
+		return i
+	}
+	var b buffer
+	if _, ok := t.(runtime.ScriggoType); ok {
+		if u, ok := types.Definition(t); ok {
+			u := m.typ(u)
+			b.putByte(typeDefined)
+			b.putString(t.Name())
+			b.putUint(uint64(u))
+		} else {
+			m.putComposite(&b, t)
+		}
+	} else if t == errorType {
+		b.putByte(typeError)
+	} else if t == envType {
+		b.putByte(typeEnv)
+	} else if format, ok := m.format(t); ok {
+		b.putByte(typeFormat)
+		b.putUint(uint64(format))
+	} else if k := t.Kind(); int(k) < len(basicTypes) && basicTypes[k] == t {
+		b.putByte(typeBasic)
+		b.putByte(byte(k))
+	} else if t.Name() != "" || k == reflect.Interface && t.NumMethod() > 0 || k == reflect.Struct && hasUnexportedField(t) {
+		path, ok := m.index().types[t]
+		if !ok {
+			m.errorf("type %s is not declared in globals or in an imported package", t)
+		}
+		b.putByte(typeNative)
+		b.putDeclRef(path.ref)
+		b.putUint(uint64(len(path.steps)))
+		for _, step := range path.steps {
+			b.putByte(step.op)
+			b.putUint(uint64(step.i))
+		}
+	} else {
+		m.putComposite(&b, t)
+	}
+	i := len(m.typeIndex)
+	m.typeIndex[t] = i
+	m.types = append(m.types, b...)
+	if methods := types.Methods(t); len(methods) > 0 {
+		m.withMethods = append(m.withMethods, t)
+		for _, method := range methods {
+			m.typ(method.Type)
+			m.addFunction(method.Func)
+		}
+	}
+	return i
+}
+
+// putComposite encodes the composite type t.
+func (m *marshaler) putComposite(b *buffer, t reflect.Type) {
+	switch t.Kind() {
+	case reflect.Array:
+		elem := m.typ(t.Elem())
+		b.putByte(typeArray)
+		b.putUint(uint64(t.Len()))
+		b.putUint(uint64(elem))
+	case reflect.Chan:
+		elem := m.typ(t.Elem())
+		b.putByte(typeChan)
+		b.putUint(uint64(t.ChanDir()))
+		b.putUint(uint64(elem))
+	case reflect.Func:
+		in := make([]int, t.NumIn())
+		for i := range in {
+			in[i] = m.typ(t.In(i))
+		}
+		out := make([]int, t.NumOut())
+		for i := range out {
+			out[i] = m.typ(t.Out(i))
+		}
+		b.putByte(typeFunc)
+		b.putUint(uint64(len(in)))
+		for _, i := range in {
+			b.putUint(uint64(i))
+		}
+		b.putUint(uint64(len(out)))
+		for _, i := range out {
+			b.putUint(uint64(i))
+		}
+		b.putBool(t.IsVariadic())
+	case reflect.Interface:
+		if _, ok := t.(runtime.ScriggoType); !ok && t.NumMethod() > 0 {
+			m.errorf("unexpected interface type %s", t)
+		}
+		methods := make([]int, t.NumMethod())
+		for i := range methods {
+			methods[i] = m.typ(t.Method(i).Type)
+		}
+		b.putByte(typeInterface)
+		b.putUint(uint64(len(methods)))
+		for i, typ := range methods {
+			method := t.Method(i)
+			b.putString(method.Name)
+			b.putString(method.PkgPath)
+			b.putUint(uint64(typ))
+		}
+	case reflect.Map:
+		key := m.typ(t.Key())
+		elem := m.typ(t.Elem())
+		b.putByte(typeMap)
+		b.putUint(uint64(key))
+		b.putUint(uint64(elem))
+	case reflect.Ptr:
+		elem := m.typ(t.Elem())
+		b.putByte(typePtr)
+		b.putUint(uint64(elem))
+	case reflect.Slice:
+		elem := m.typ(t.Elem())
+		b.putByte(typeSlice)
+		b.putUint(uint64(elem))
+	case reflect.Struct:
+		fields := make([]int, t.NumField())
+		for i := range fields {
+			fields[i] = m.typ(t.Field(i).Type)
+		}
+		b.putByte(typeStruct)
+		b.putUint(uint64(len(fields)))
+		for i, typ := range fields {
+			field := t.Field(i)
+			b.putString(field.Name)
+			b.putString(field.PkgPath)
+			b.putString(string(field.Tag))
+			b.putBool(field.Anonymous)
+			b.putUint(uint64(typ))
+		}
+	default:
+		m.errorf("unexpected type %s", t)
+	}
+}
+
+// format returns the format of the format type t, if t is a format type.
+func (m *marshaler) format(t reflect.Type) (ast.Format, bool) {
+	for format, typ := range m.opts.FormatTypes {
+		if typ == t {
+			return format, true
+		}
+	}
+	return 0, false
+}
+
+// hasUnexportedField reports whether the struct type t has an unexported
+// field.
+func hasUnexportedField(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// index returns the index of the native declarations available to the code.
+func (m *marshaler) index() *nativeIndex {
+	if m.natives != nil {
+		return m.natives
+	}
+	m.natives = &nativeIndex{
+		funcs: map[uintptr][]indexedDecl{},
+		named: map[namedFunc][]indexedDecl{},
+		vars:  map[uintptr][]indexedDecl{},
+		types: map[reflect.Type]typePath{},
+	}
+	var queue []typePath
+	var add func(source byte, pkgName string, pkg native.ImportablePackage)
+	add = func(source byte, pkgName string, pkg native.ImportablePackage) {
+		var names []string
+		decls := map[string]native.Declaration{}
+		_ = pkg.LookupFunc(func(name string, decl native.Declaration) error {
+			names = append(names, name)
+			decls[name] = decl
+			return nil
+		})
+		sort.Strings(names)
+		for _, name := range names {
+			ref := declRef{source: source, pkg: pkgName, name: name}
+			var typ reflect.Type
+			switch decl := decls[name].(type) {
+			case nil, native.UntypedBooleanConst, native.UntypedStringConst, native.UntypedNumericConst:
+				continue
+			case native.ImportablePackage:
+				if source == globalsSource {
+					add(globalPackageSource, name, decl)
+				}
+				continue
+			case reflect.Type:
+				typ = decl
+			default:
+				rv := reflect.ValueOf(decl)
+				switch rv.Kind() {
+				case reflect.Func:
+					if !rv.IsNil() {
+						ptr := rv.Pointer()
+						m.natives.funcs[ptr] = append(m.natives.funcs[ptr], indexedDecl{ref, rv})
+						key := namedFunc{ptr, ref.name}
+						m.natives.named[key] = append(m.natives.named[key], indexedDecl{ref, rv})
+					}
+				case reflect.Ptr:
+					if !rv.IsNil() {
+						m.natives.vars[rv.Pointer()] = append(m.natives.vars[rv.Pointer()], indexedDecl{ref, rv})
+					}
+				}
+				typ = rv.Type()
+			}
+			queue = append(queue, typePath{ref: ref, typ: typ})
+		}
+	}
+	if m.opts.Globals != nil {
+		add(globalsSource, "", native.Package{Name: "main", Declarations: m.opts.Globals})
+	}
+	if m.opts.Importer != nil {
+		for _, path := range m.packages {
+			pkg, err := m.opts.Importer.Import(path)
+			if err != nil {
+				m.errorf("cannot import package %q: %s", path, err)
+			}
+			if pkg == nil {
+				m.errorf("cannot find package %q", path)
+			}
+			add(importedSource, path, pkg)
+		}
+	}
+	// Index the types reachable from the declarations.
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if _, ok := m.natives.types[p.typ]; ok {
+			continue
+		}
+		m.natives.types[p.typ] = p
+		next := func(op byte, i int, t reflect.Type) {
+			if _, ok := m.natives.types[t]; ok {
+				return
+			}
+			steps := make([]typeStep, len(p.steps)+1)
+			copy(steps, p.steps)
+			steps[len(p.steps)] = typeStep{op: op, i: i}
+			queue = append(queue, typePath{ref: p.ref, steps: steps, typ: t})
+		}
+		switch t := p.typ; t.Kind() {
+		case reflect.Array, reflect.Chan, reflect.Ptr, reflect.Slice:
+			next(stepElem, 0, t.Elem())
+		case reflect.Map:
+			next(stepKey, 0, t.Key())
+			next(stepElem, 0, t.Elem())
+		case reflect.Func:
+			for i := 0; i < t.NumIn(); i++ {
+				next(stepIn, i, t.In(i))
+			}
+			for i := 0; i < t.NumOut(); i++ {
+				next(stepOut, i, t.Out(i))
+			}
+		case reflect.Struct:
+			for i := 0; i < t.NumField(); i++ {
+				next(stepField, i, t.Field(i).Type)
+			}
+		}
+		for i := 0; i < p.typ.NumMethod(); i++ {
+			next(stepMethod, i, p.typ.Method(i).Type)
+		}
+	}
+	return m.natives
+}
+
+// Unmarshal decodes a code encoded with Marshal. opts must have the globals,
+// the importer and the format types used to resolve the native declarations
+// referenced by the code.
+func Unmarshal(data []byte, opts Options) (code *Code, err error) {
+	if len(data) < len(marshalMagic) || string(data[:len(marshalMagic)]) != marshalMagic {
+		return nil, errInvalidCode
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			code = nil
+			if e, ok := r.(marshalError); ok {
+				err = e.err
+				return
+			}
+			err = errInvalidCode
+		}
+	}()
+	d := &unmarshaler{
+		data:     data[len(marshalMagic):],
+		opts:     opts,
+		types:    types.NewTypes(),
+		packages: map[string]native.ImportablePackage{},
+	}
+	if version := d.uint(); version != marshalVersion {
+		return nil, fmt.Errorf("scriggo: unsupported marshaled code version %d", version)
+	}
+	return d.unmarshal(), nil
+}
+
+// unmarshaler decodes a code.
+type unmarshaler struct {
+	data      []byte
+	opts      Options
+	types     *types.Types
+	typeList  []reflect.Type
+	functions []*runtime.Function
+	packages  map[string]native.ImportablePackage
+}
+
+// errorf panics with an unmarshaling error.
+func (d *unmarshaler) errorf(format string, a ...interface{}) {
+	panic(marshalError{fmt.Errorf("scriggo: cannot load code: "+format, a...)})
+}
+
+// unmarshal decodes the code.
+func (d *unmarshaler) unmarshal() *Code {
+	code := &Code{TypeOf: d.types.TypeOf}
+	if n := d.len(); n > 0 {
+		code.NativePackages = make([]string, n)
+		for i := range code.NativePackages {
+			code.NativePackages[i] = d.string()
+		}
+	}
+	d.typeList = make([]reflect.Type, d.len())
+	for i := range d.typeList {
+		d.typeList[i] = d.decodeType()
+	}
+	d.functions = make([]*runtime.Function, d.len())
+	if len(d.functions) == 0 {
+		panic(errInvalidCode)
+	}
+	for i := range d.functions {
+		d.functions[i] = &runtime.Function{}
+	}
+	for _, fn := range d.functions {
+		d.decodeFunction(fn)
+	}
+	code.Main = d.functions[0]
+	if n := d.len(); n > 0 {
+		code.Globals = make([]Global, n)
+		for i := range code.Globals {
+			global := &code.Globals[i]
+			global.Pkg = d.string()
+			global.Name = d.string()
+			global.Type = d.typeOrNil()
+			if d.bool() {
+				global.Value = d.variable(d.declRef(), global.Type)
+			}
+		}
+	}
+	for i, n := 0, d.len(); i < n; i++ {
+		t := d.typ()
+		if _, ok := types.Definition(t); !ok {
+			panic(errInvalidCode)
+		}
+		for j, m := 0, d.len(); j < m; j++ {
+			method := &runtime.Method{Name: d.string()}
+			method.Type = d.typ()
+			method.Pointer = d.bool()
+			method.Func = d.function(d.uint())
+			d.types.AddMethod(t, method)
+		}
+	}
+	if n := d.len(); n > 0 {
+		code.Coverage = make([]runtime.CoverBlock, n)
+		for i := range code.Coverage {
+			code.Coverage[i] = runtime.CoverBlock{
+				Path:      d.string(),
+				StartLine: int(d.uint()),
+				StartCol:  int(d.uint()),
+				EndLine:   int(d.uint()),
+				EndCol:    int(d.uint()),
+				NumStmt:   int(d.uint()),
+			}
+		}
+	}
+	if len(d.data) > 0 {
+		panic(errInvalidCode)
+	}
+	return code
+}
+
+// decodeFunction decodes a function into fn.
+func (d *unmarshaler) decodeFunction(fn *runtime.Function) {
+	fn.Pkg = d.string()
+	fn.Name = d.string()
+	fn.File = d.string()
+	if d.bool() {
+		pos := d.position()
+		fn.Pos = &pos
+	}
+	fn.Type = d.typeOrNil()
+	if i := d.uint(); i > 0 {
+		fn.Parent = d.function(i - 1)
+	}
+	if n := d.len(); n > 0 {
+		fn.VarRefs = make([]int16, n)
+		for i := range fn.VarRefs {
+			fn.VarRefs[i] = int16(d.int())
+		}
+	}
+	if n := d.len(); n > 0 {
+		fn.Types = make([]reflect.Type, n)
+		for i := range fn.Types {
+			fn.Types[i] = d.typ()
+		}
+	}
+	for i := range fn.NumReg {
+		fn.NumReg[i] = int16(d.int())
+	}
+	if n := d.len(); n > 0 {
+		fn.FinalRegs = make([][2]int16, n)
+		for i := range fn.FinalRegs {
+			fn.FinalRegs[i] = [2]int16{int16(d.int()), int16(d.int())}
+		}
+	}
+	fn.Macro = d.bool()
+	fn.Format = ast.Format(d.uint())
+	if n := d.len(); n > 0 {
+		fn.Values.Int = make([]int64, n)
+		for i := range fn.Values.Int {
+			fn.Values.Int[i] = d.int()
+		}
+	}
+	if n := d.len(); n > 0 {
+		fn.Values.Float = make([]float64, n)
+		for i := range fn.Values.Float {
+			fn.Values.Float[i] = math.Float64frombits(d.uint())
+		}
+	}
+	if n := d.len(); n > 0 {
+		fn.Values.String = make([]string, n)
+		for i := range fn.Values.String {
+			fn.Values.String[i] = d.string()
+		}
+	}
+	if n := d.len(); n > 0 {
+		fn.Values.General = make([]reflect.Value, n)
+		for i := range fn.Values.General {
+			fn.Values.General[i] = d.generalValue()
+		}
+	}
+	if n := d.len(); n > 0 {
+		fn.FieldIndexes = make([][]int, n)
+		for i := range fn.FieldIndexes {
+			index := make([]int, d.len())
+			for j := range index {
+				index[j] = int(d.uint())
+			}
+			fn.FieldIndexes[i] = index
+		}
+	}
+	if n := d.len(); n > 0 {
+		fn.Functions = make([]*runtime.Function, n)
+		for i := range fn.Functions {
+			fn.Functions[i] = d.function(d.uint())
+		}
+	}
+	if n := d.len(); n > 0 {
+		fn.NativeFunctions = make([]*runtime.NativeFunction, n)
+		for i := range fn.NativeFunctions {
+			fn.NativeFunctions[i] = d.nativeFunction()
+		}
+	}
+	if n := d.len(); n > 0 {
+		if len(d.data) < 8*n {
+			panic(errInvalidCode)
+		}
+		fn.Body = make([]runtime.Instruction, n)
+		for i := range fn.Body {
+			b := d.data[8*i:]
+			fn.Body[i] = runtime.Instruction{
+				Op: runtime.Operation(binary.LittleEndian.Uint16(b[0:])),
+				A:  int16(binary.LittleEndian.Uint16(b[2:])),
+				B:  int16(binary.LittleEndian.Uint16(b[4:])),
+				C:  int16(binary.LittleEndian.Uint16(b[6:])),
+			}
+		}
+		d.data = d.data[8*n:]
+	}
+	if n := d.len(); n > 0 {
+		fn.Text = make([][]byte, n)
+		for i := range fn.Text {
+			fn.Text[i] = d.bytes()
+		}
+	}
+	if n := d.len(); n > 0 {
+		fn.DebugInfo = make(map[runtime.Addr]runtime.DebugInfo, n)
+		for i := 0; i < n; i++ {
+			addr := runtime.Addr(d.uint())
+			var info runtime.DebugInfo
+			info.Position = d.position()
+			info.Path = d.string()
+			for j := range info.OperandKind {
+				info.OperandKind[j] = reflect.Kind(d.byte())
+			}
+			info.FuncType = d.typeOrNil()
+			info.Statement = d.bool()
+			fn.DebugInfo[addr] = info
+		}
+	}
+	if n := d.len(); n > 0 {
+		fn.Locals = make([]runtime.Local, n)
+		for i := range fn.Locals {
+			fn.Locals[i] = runtime.Local{
+				Name:  d.string(),
+				Type:  d.typ(),
+				Reg:   int16(d.int()),
+				Start: runtime.Addr(d.uint()),
+				End:   runtime.Addr(d.uint()),
+			}
+		}
+	}
+}
+
+// generalValue decodes a general value.
+func (d *unmarshaler) generalValue() reflect.Value {
+	switch d.byte() {
+	case generalInvalid:
+		return reflect.Value{}
+	case generalZero:
+		return reflect.Zero(d.typ())
+	case generalComplex:
+		t := d.typ()
+		r := math.Float64frombits(d.uint())
+		i := math.Float64frombits(d.uint())
+		v := reflect.New(t).Elem()
+		v.SetComplex(complex(r, i))
+		return v
+	}
+	panic(errInvalidCode)
+}
+
+// nativeFunction decodes a native function.
+func (d *unmarshaler) nativeFunction() *runtime.NativeFunction {
+	pkg := d.string()
+	name := d.string()
+	var fn interface{}
+	switch d.byte() {
+	case nativeNil:
+		fn = reflect.Zero(d.typ())
+	case nativeComplex:
+		switch name {
+		case "neg":
+			fn = negComplex
+		case "add":
+			fn = addComplex
+		case "sub":
+			fn = subComplex
+		case "mul":
+			fn = mulComplex
+		case "div":
+			fn = divComplex
+		default:
+			panic(errInvalidCode)
+		}
+	case nativeBuiltin:
+		if !deferGoBuiltins[name] {
+			panic(errInvalidCode)
+		}
+		fn = deferGoBuiltin(name).value.(reflect.Value)
+	case nativeDecl:
+		ref := d.declRef()
+		typ := d.typ()
+		rv := reflect.ValueOf(d.decl(ref))
+		if rv.Kind() != reflect.Func || rv.IsNil() || rv.Type() != typ {
+			d.errorf("%s is not a function of type %s", ref, typ)
+		}
+		fn = rv
+	case nativeMethod, nativeIfaceMethod:
+		recv := d.typ()
+		methodName := d.string()
+		method, ok := recv.MethodByName(methodName)
+		if !ok {
+			d.errorf("type %s has no method %s", recv, methodName)
+		}
+		if recv.Kind() == reflect.Interface {
+			fn = interfaceMethodExpr(recv, method)
+		} else {
+			fn = method.Func
+		}
+	default:
+		panic(errInvalidCode)
+	}
+	return newNativeFunction(pkg, name, fn)
+}
+
+// variable decodes the variable with the given reference and type.
+func (d *unmarshaler) variable(ref declRef, typ reflect.Type) reflect.Value {
+	rv := reflect.ValueOf(d.decl(ref))
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Type().Elem() != typ {
+		d.errorf("%s is not a variable of type %s", ref, typ)
+	}
+	return rv.Elem()
+}
+
+// decl returns the native declaration with the given reference.
+func (d *unmarshaler) decl(ref declRef) native.Declaration {
+	var pkg native.ImportablePackage
+	switch ref.source {
+	case globalsSource:
+		if decl, ok := d.opts.Globals[ref.name]; ok {
+			return decl
+		}
+	case globalPackageSource:
+		pkg, _ = d.opts.Globals[ref.pkg].(native.ImportablePackage)
+	case importedSource:
+		var ok bool
+		pkg, ok = d.packages[ref.pkg]
+		if !ok && d.opts.Importer != nil {
+			var err error
+			pkg, err = d.opts.Importer.Import(ref.pkg)
+			if err != nil {
+				d.errorf("cannot import package %q: %s", ref.pkg, err)
+			}
+			d.packages[ref.pkg] = pkg
+		}
+	default:
+		panic(errInvalidCode)
+	}
+	if pkg != nil {
+		if decl := pkg.Lookup(ref.name); decl != nil {
+			return decl
+		}
+	}
+	d.errorf("%s is not declared", ref)
+	return nil
+}
+
+// decodeType decodes a type.
+func (d *unmarshaler) decodeType() reflect.Type {
+	switch d.byte() {
+	case typeBasic:
+		k := d.byte()
+		if int(k) >= len(basicTypes) || basicTypes[k] == nil {
+			panic(errInvalidCode)
+		}
+		return basicTypes[k]
+	case typeError:
+		return errorType
+	case typeEnv:
+		return envType
+	case typeFormat:
+		format := ast.Format(d.uint())
+		t, ok := d.opts.FormatTypes[format]
+		if !ok {
+			panic(errInvalidCode)
+		}
+		return t
+	case typeNative:
+		ref := d.declRef()
+		var t reflect.Type
+		switch decl := d.decl(ref).(type) {
+		case native.ImportablePackage, native.UntypedBooleanConst, native.UntypedStringConst, native.UntypedNumericConst:
+		case reflect.Type:
+			t = decl
+		default:
+			t = reflect.TypeOf(decl)
+		}
+		if t == nil {
+			d.errorf("%s does not have a type", ref)
+		}
+		n := d.len()
+		for i := 0; i < n; i++ {
+			op := d.byte()
+			j := int(d.uint())
+			t = step(t, op, j)
+			if t == nil {
+				d.errorf("type referenced by %s does not exist", ref)
+			}
+		}
+		return t
+	case typeDefined:
+		name := d.string()
+		if name == "" {
+			panic(errInvalidCode)
+		}
+		return d.types.DefinedOf(name, d.typ())
+	case typeArray:
+		n := int(d.uint())
+		return d.types.ArrayOf(n, d.typ())
+	case typeChan:
+		dir := reflect.ChanDir(d.uint())
+		return d.types.ChanOf(dir, d.typ())
+	case typeFunc:
+		in := make([]reflect.Type, d.len())
+		for i := range in {
+			in[i] = d.typ()
+		}
+		out := make([]reflect.Type, d.len())
+		for i := range out {
+			out[i] = d.typ()
+		}
+		return d.types.FuncOf(in, out, d.bool())
+	case typeInterface:
+		n := d.len()
+		if n == 0 {
+			return emptyInterfaceType
+		}
+		methods := make([]reflect.Method, n)
+		for i := range methods {
+			methods[i].Name = d.string()
+			methods[i].PkgPath = d.string()
+			methods[i].Type = d.typ()
+		}
+		return d.types.InterfaceOf(methods)
+	case typeMap:
+		key := d.typ()
+		return d.types.MapOf(key, d.typ())
+	case typePtr:
+		return d.types.PtrTo(d.typ())
+	case typeSlice:
+		return d.types.SliceOf(d.typ())
+	case typeStruct:
+		fields := make([]reflect.StructField, d.len())
+		for i := range fields {
+			fields[i].Name = d.string()
+			fields[i].PkgPath = d.string()
+			fields[i].Tag = reflect.StructTag(d.string())
+			fields[i].Anonymous = d.bool()
+			fields[i].Type = d.typ()
+		}
+		return d.types.StructOf(fields)
+	}
+	panic(errInvalidCode)
+}
+
+// step returns the type reached from t with the given step, or nil if the
+// step is not valid for t.
+func step(t reflect.Type, op byte, i int) reflect.Type {
+	k := t.Kind()
+	switch op {
+	case stepElem:
+		if k == reflect.Array || k == reflect.Chan || k == reflect.Map || k == reflect.Ptr || k == reflect.Slice {
+			return t.Elem()
+		}
+	case stepKey:
+		if k == reflect.Map {
+			return t.Key()
+		}
+	case stepIn:
+		if k == reflect.Func && i < t.NumIn() {
+			return t.In(i)
+		}
+	case stepOut:
+		if k == reflect.Func && i < t.NumOut() {
+			return t.Out(i)
+		}
+	case stepField:
+		if k == reflect.Struct && i < t.NumField() {
+			return t.Field(i).Type
+		}
+	case stepMethod:
+		if i < t.NumMethod() {
+			return t.Method(i).Type
+		}
+	}
+	return nil
+}
+
+// function returns the i-th function.
+func (d *unmarshaler) function(i uint64) *runtime.Function {
+	if i >= uint64(len(d.functions)) {
+		panic(errInvalidCode)
+	}
+	return d.functions[i]
+}
+
+// typ decodes a reference to a type.
+func (d *unmarshaler) typ() reflect.Type {
+	i := d.uint()
+	if i >= uint64(len(d.typeList)) || d.typeList[i] == nil {
+		panic(errInvalidCode)
+	}
+	return d.typeList[i]
+}
+
+// typeOrNil decodes a reference to a type that can be nil.
+func (d *unmarshaler) typeOrNil() reflect.Type {
+	i := d.uint()
+	if i == 0 {
+		return nil
+	}
+	if i > uint64(len(d.typeList)) || d.typeList[i-1] == nil {
+		panic(errInvalidCode)
+	}
+	return d.typeList[i-1]
+}
+
+func (d *unmarshaler) declRef() declRef {
+	return declRef{source: d.byte(), pkg: d.string(), name: d.string()}
+}
+
+func (d *unmarshaler) position() runtime.Position {
+	return runtime.Position{
+		Line:   int(d.uint()),
+		Column: int(d.uint()),
+		Start:  int(d.uint()),
+		End:    int(d.uint()),
+	}
+}
+
+func (d *unmarshaler) byte() byte {
+	if len(d.data) == 0 {
+		panic(errInvalidCode)
+	}
+	c := d.data[0]
+	d.data = d.data[1:]
+	return c
+}
+
+func (d *unmarshaler) bool() bool {
+	switch d.byte() {
+	case 0:
+		return false
+	case 1:
+		return true
+	}
+	panic(errInvalidCode)
+}
+
+func (d *unmarshaler) uint() uint64 {
+	n, i := binary.Uvarint(d.data)
+	if i <= 0 {
+		panic(errInvalidCode)
+	}
+	d.data = d.data[i:]
+	return n
+}
+
+func (d *unmarshaler) int() int64 {
+	n, i := binary.Varint(d.data)
+	if i <= 0 {
+		panic(errInvalidCode)
+	}
+	d.data = d.data[i:]
+	return n
+}
+
+// len decodes a length. As every element is encoded with at least one byte,
+// a length cannot be greater than the number of bytes left to decode.
+func (d *unmarshaler) len() int {
+	n := d.uint()
+	if n > uint64(len(d.data)) {
+		panic(errInvalidCode)
+	}
+	return int(n)
+}
+
+func (d *unmarshaler) string() string {
+	return string(d.bytes())
+}
+
+func (d *unmarshaler) bytes() []byte {
+	n := d.len()
+	b := make([]byte, n)
+	copy(b, d.data)
+	d.data = d.data[n:]
+	return b
+}
+
+// String returns the representation of ref used in the error messages.
+func (ref declRef) String() string {
+	switch ref.source {
+	case globalPackageSource:
+		return "global " + ref.pkg + "." + ref.name
+	case importedSource:
+		return fmt.Sprintf("%s in package %q", ref.name, ref.pkg)
+	}
+	return "global " + ref.name
+}
@@ -0,0 +1,585 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compiler
+
+import (
+	"math"
+
+	"github.com/open2b/scriggo/internal/runtime"
+)
+
+// maxOptimizationPasses is the maximum number of times that the optimization
+// passes are executed on a function body.
+const maxOptimizationPasses = 10
+
+// optimize optimizes the bytecode of the functions and macros of code.
+//
+// The calls to small functions and macros are inlined, then the following
+// passes are repeated until the body does not change anymore:
+//
+//   - propagation and folding of constants and copies of registers, also
+//     across statements, including the conditions of If instructions
+//   - removal of the stores to registers that are not read, and fusion of an
+//     instruction with the Move of its result into another register
+//   - fusion of the conditions stored into registers and then tested
+//   - threading of jumps, and removal of unreachable code and of jumps to
+//     the next instruction
+//
+// An optimized function has the same behavior of the non-optimized one, but
+// the values of the local variables seen by a debugger may be out of date.
+func optimize(code *Code) {
+	optimized := map[*runtime.Function]bool{}
+	optimizeFunction(code.Main, optimized)
+	for _, fn := range code.Functions {
+		optimizeFunction(fn, optimized)
+	}
+}
+
+// optimizeFunction optimizes fn after the functions it refers to, so that
+// the calls to them can be inlined in their optimized form.
+func optimizeFunction(fn *runtime.Function, optimized map[*runtime.Function]bool) {
+	if optimized[fn] {
+		return
+	}
+	optimized[fn] = true
+	for _, f := range fn.Functions {
+		optimizeFunction(f, optimized)
+	}
+	o, ok := newOptimizer(fn)
+	if !ok {
+		return
+	}
+	o.inline()
+	for i := 0; i < maxOptimizationPasses; i++ {
+		o.changed = false
+		o.propagate()
+		o.removeDeadStores()
+		o.fuseConditions()
+		o.simplifyFlow()
+		if !o.changed {
+			break
+		}
+	}
+	o.encode()
+}
+
+// optNode is an instruction of a function body that is being optimized.
+type optNode struct {
+	in      runtime.Instruction
+	extra   []runtime.Instruction // instructions that are part of in, as the stack shift of a call.
+	target  *optNode              // target of a Goto, Break or Continue instruction.
+	debug   *runtime.DebugInfo    // debug information; nil if there is no debug information.
+	index   int                   // index in the nodes of the optimizer.
+	addr    runtime.Addr          // address in the optimized body.
+	deleted bool                  // reports whether the instruction has been deleted.
+}
+
+// optimizer optimizes the body of a function.
+//
+// The instructions of the body are kept as nodes so that instructions can be
+// deleted and inserted without changing the targets of the jumps, the debug
+// information and the scopes of the local variables. A deleted node stays in
+// the nodes and, as jump target, stands for the next node not deleted.
+type optimizer struct {
+	fn      *runtime.Function
+	nodes   []*optNode
+	locals  [][2]*optNode // nodes of the Start and End addresses of fn.Locals; nil is the end of the body.
+	results [4]int16      // number of result parameters for each register type.
+	strings map[string]int16
+	changed bool
+}
+
+// newOptimizer returns an optimizer for fn. If the body of fn cannot be
+// optimized, it returns false.
+func newOptimizer(fn *runtime.Function) (*optimizer, bool) {
+	body := fn.Body
+	at := make([]*optNode, len(body)+1)
+	nodes := make([]*optNode, 0, len(body))
+	for addr := 0; addr < len(body); {
+		in := body[addr]
+		if in.Op == runtime.OpTailCall {
+			return nil, false
+		}
+		size := instructionSize(in)
+		if addr+size > len(body) {
+			return nil, false
+		}
+		n := &optNode{in: in, index: len(nodes)}
+		if size > 1 {
+			n.extra = append([]runtime.Instruction(nil), body[addr+1:addr+size]...)
+		}
+		if info, ok := fn.DebugInfo[runtime.Addr(addr)]; ok {
+			n.debug = &info
+		}
+		for i := addr; i < addr+size; i++ {
+			at[i] = n
+		}
+		nodes = append(nodes, n)
+		addr += size
+	}
+	if len(nodes) == 0 {
+		return nil, false
+	}
+	for _, n := range nodes {
+		switch n.in.Op {
+		case runtime.OpGoto, runtime.OpBreak, runtime.OpContinue:
+			addr := decodeUint24(n.in.A, n.in.B, n.in.C)
+			if int(addr) >= len(body) {
+				return nil, false
+			}
+			n.target = at[addr]
+		}
+	}
+	o := &optimizer{fn: fn, nodes: nodes}
+	if fn.Locals != nil {
+		o.locals = make([][2]*optNode, len(fn.Locals))
+		for i, local := range fn.Locals {
+			start, end := int(local.Start), int(local.End)
+			if start > len(body) {
+				start = len(body)
+			}
+			if end > len(body) {
+				end = len(body)
+			}
+			o.locals[i] = [2]*optNode{at[start], at[end]}
+		}
+	}
+	if fn.Type != nil {
+		for i := 0; i < fn.Type.NumOut(); i++ {
+			o.results[kindToType(fn.Type.Out(i).Kind())]++
+		}
+	}
+	return o, true
+}
+
+// encode encodes the nodes in the body of the function, updating the
+// addresses of the jumps, of the debug information and of the local
+// variables.
+func (o *optimizer) encode() {
+	fn := o.fn
+	var end runtime.Addr
+	for _, n := range o.nodes {
+		if !n.deleted {
+			n.addr = end
+			end += runtime.Addr(1 + len(n.extra))
+		}
+	}
+	// A deleted node takes the address of the next node not deleted, that
+	// becomes the first instruction of the statement of the deleted node.
+	var next *optNode
+	for i := len(o.nodes) - 1; i >= 0; i-- {
+		n := o.nodes[i]
+		if !n.deleted {
+			next = n
+			continue
+		}
+		if next == nil {
+			n.addr = end
+			continue
+		}
+		n.addr = next.addr
+		if n.debug != nil && n.debug.Statement {
+			if next.debug == nil {
+				next.debug = &runtime.DebugInfo{}
+			}
+			if !next.debug.Statement {
+				next.debug.Statement = true
+				if next.debug.Position.Line == 0 {
+					next.debug.Position = n.debug.Position
+					next.debug.Path = n.debug.Path
+				}
+			}
+		}
+	}
+	body := make([]runtime.Instruction, 0, end)
+	var debugInfo map[runtime.Addr]runtime.DebugInfo
+	if fn.DebugInfo != nil {
+		debugInfo = map[runtime.Addr]runtime.DebugInfo{}
+	}
+	for _, n := range o.nodes {
+		if n.deleted {
+			continue
+		}
+		in := n.in
+		if n.target != nil {
+			in.A, in.B, in.C = encodeUint24(uint32(n.target.addr))
+		}
+		if n.debug != nil {
+			if debugInfo == nil {
+				debugInfo = map[runtime.Addr]runtime.DebugInfo{}
+			}
+			debugInfo[n.addr] = *n.debug
+		}
+		body = append(body, in)
+		body = append(body, n.extra...)
+	}
+	for i, nodes := range o.locals {
+		fn.Locals[i].Start, fn.Locals[i].End = end, end
+		if nodes[0] != nil {
+			fn.Locals[i].Start = nodes[0].addr
+		}
+		if nodes[1] != nil {
+			fn.Locals[i].End = nodes[1].addr
+		}
+	}
+	fn.Body = body
+	fn.DebugInfo = debugInfo
+}
+
+// instructionSize returns the number of instructions that make up the
+// instruction in, including the following ones that are part of it.
+func instructionSize(in runtime.Instruction) int {
+	switch in.Op {
+	case runtime.OpCallFunc, runtime.OpCallMacro, runtime.OpCallIndirect, runtime.OpCallNative,
+		runtime.OpSlice, runtime.OpStringSlice:
+		return 2
+	case runtime.OpDefer:
+		return 3
+	case runtime.OpMakeSlice:
+		if in.B > 0 {
+			return 2
+		}
+	}
+	return 1
+}
+
+// isBranch reports whether an instruction with operation op can skip the
+// next instruction.
+func isBranch(op runtime.Operation) bool {
+	if op < 0 {
+		op = -op
+	}
+	switch op {
+	case runtime.OpIf, runtime.OpIfInt, runtime.OpIfFloat, runtime.OpIfString,
+		runtime.OpAssert, runtime.OpCase, runtime.OpRange, runtime.OpRangeString:
+		return true
+	}
+	return false
+}
+
+// next returns the index of the first node not deleted after the node with
+// index i. If there is no such node, it returns -1.
+func (o *optimizer) next(i int) int {
+	for i++; i < len(o.nodes); i++ {
+		if !o.nodes[i].deleted {
+			return i
+		}
+	}
+	return -1
+}
+
+// prev returns the index of the last node not deleted before the node with
+// index i. If there is no such node, it returns -1.
+func (o *optimizer) prev(i int) int {
+	for i--; i >= 0; i-- {
+		if !o.nodes[i].deleted {
+			return i
+		}
+	}
+	return -1
+}
+
+// resolve returns the index of the node that n, as jump target, stands for.
+func (o *optimizer) resolve(n *optNode) int {
+	if !n.deleted {
+		return n.index
+	}
+	return o.next(n.index)
+}
+
+// pinned reports whether the node with index i must be neither deleted nor
+// moved, because the previous instruction can skip it.
+func (o *optimizer) pinned(i int) bool {
+	p := o.prev(i)
+	return p >= 0 && isBranch(o.nodes[p].in.Op)
+}
+
+// successors appends to succ the indexes of the nodes that can be executed
+// after the node with index i and returns the extended slice.
+func (o *optimizer) successors(i int, succ []int) []int {
+	n := o.nodes[i]
+	switch n.in.Op {
+	case runtime.OpGoto, runtime.OpContinue:
+		// Continue returns to its Range instruction that continues the loop.
+		// Break instead terminates the loop and continues with the next
+		// instruction.
+		if t := o.resolve(n.target); t >= 0 {
+			succ = append(succ, t)
+		}
+		return succ
+	case runtime.OpReturn, runtime.OpSelect:
+		return succ
+	}
+	next := o.next(i)
+	if next < 0 {
+		return succ
+	}
+	succ = append(succ, next)
+	if isBranch(n.in.Op) {
+		if s := o.next(next); s >= 0 {
+			succ = append(succ, s)
+		}
+	}
+	return succ
+}
+
+// optBlock is a basic block of a function body.
+type optBlock struct {
+	first, last int   // indexes of the first and the last node.
+	succs       []int // indexes of the successor blocks.
+}
+
+// blocks returns the basic blocks of the body in the order of their nodes.
+func (o *optimizer) blocks() []optBlock {
+	leader := make([]bool, len(o.nodes))
+	first := o.next(-1)
+	leader[first] = true
+	var succ []int
+	for i := first; i >= 0; i = o.next(i) {
+		next := o.next(i)
+		succ = o.successors(i, succ[:0])
+		if len(succ) == 1 && succ[0] == next {
+			continue
+		}
+		for _, s := range succ {
+			leader[s] = true
+		}
+		if next >= 0 {
+			leader[next] = true
+		}
+	}
+	var blocks []optBlock
+	blockOf := make([]int, len(o.nodes))
+	for i := first; i >= 0; i = o.next(i) {
+		if leader[i] {
+			blocks = append(blocks, optBlock{first: i})
+		}
+		b := len(blocks) - 1
+		blocks[b].last = i
+		blockOf[i] = b
+	}
+	for b := range blocks {
+		succ = o.successors(blocks[b].last, succ[:0])
+		for _, s := range succ {
+			blocks[b].succs = append(blocks[b].succs, blockOf[s])
+		}
+	}
+	return blocks
+}
+
+// simplifyFlow threads the jumps and removes the unreachable nodes, the
+// jumps to the next node and the conditions that do not change the flow.
+func (o *optimizer) simplifyFlow() {
+
+	// Thread the jumps.
+	for _, n := range o.nodes {
+		if n.deleted || n.in.Op != runtime.OpGoto {
+			continue
+		}
+		t := o.resolve(n.target)
+		if t < 0 {
+			continue
+		}
+		for steps := 0; o.nodes[t].in.Op == runtime.OpGoto && steps < len(o.nodes); steps++ {
+			s := o.resolve(o.nodes[t].target)
+			if s < 0 || s == t {
+				break
+			}
+			t = s
+		}
+		if o.nodes[t].in.Op == runtime.OpReturn {
+			n.in = runtime.Instruction{Op: runtime.OpReturn}
+			n.target = nil
+			o.changed = true
+		} else if n.target != o.nodes[t] {
+			n.target = o.nodes[t]
+			o.changed = true
+		}
+	}
+
+	// Remove the unreachable nodes.
+	reachable := make([]bool, len(o.nodes))
+	stack := []int{o.next(-1)}
+	reachable[stack[0]] = true
+	var succ []int
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		succ = o.successors(i, succ[:0])
+		for _, s := range succ {
+			if !reachable[s] {
+				reachable[s] = true
+				stack = append(stack, s)
+			}
+		}
+	}
+	for i, n := range o.nodes {
+		if !n.deleted && !reachable[i] {
+			n.deleted = true
+			o.changed = true
+		}
+	}
+
+	// Remove the jumps to the next node, the conditions that jump to the
+	// same node whether they are true or false, and the Nop instructions.
+	for i := o.next(-1); i >= 0; i = o.next(i) {
+		n := o.nodes[i]
+		switch op := n.in.Op; op {
+		case runtime.OpGoto, runtime.OpNone:
+			if !o.pinned(i) && (op == runtime.OpNone || o.resolve(n.target) == o.next(i)) {
+				n.deleted = true
+				o.changed = true
+			}
+		case runtime.OpIfInt, -runtime.OpIfInt, runtime.OpIfFloat, -runtime.OpIfFloat, runtime.OpIfString, -runtime.OpIfString:
+			next := o.next(i)
+			if o.pinned(i) || next < 0 || o.nodes[next].in.Op != runtime.OpGoto || o.info(n.in).readsAll {
+				continue
+			}
+			if o.resolve(o.nodes[next].target) == o.next(next) {
+				n.deleted = true
+				o.changed = true
+				continue
+			}
+			// Replace
+			//
+			//       If cond
+			//       Goto 1
+			//       Goto 2
+			//    1: ...
+			//
+			// with
+			//
+			//       If !cond
+			//       Goto 2
+			//    1: ...
+			//
+			other := o.next(next)
+			if other < 0 || o.nodes[other].in.Op != runtime.OpGoto ||
+				o.resolve(o.nodes[next].target) != o.next(other) {
+				continue
+			}
+			if cond, ok := negateCondition(n.in.Op, runtime.Condition(n.in.B)); ok {
+				n.in.B = int16(cond)
+				o.nodes[next].deleted = true
+				o.changed = true
+			}
+		}
+	}
+
+}
+
+// negateCondition returns the negation of the condition cond of an If
+// instruction with operation op. It returns false if the condition cannot be
+// negated.
+func negateCondition(op runtime.Operation, cond runtime.Condition) (runtime.Condition, bool) {
+	if op < 0 {
+		op = -op
+	}
+	if op == runtime.OpIfFloat && cond != runtime.ConditionEqual && cond != runtime.ConditionNotEqual {
+		// A comparison with NaN is always false.
+		return 0, false
+	}
+	switch cond {
+	case runtime.ConditionZero:
+		return runtime.ConditionNotZero, true
+	case runtime.ConditionNotZero:
+		return runtime.ConditionZero, true
+	case runtime.ConditionEqual:
+		return runtime.ConditionNotEqual, true
+	case runtime.ConditionNotEqual:
+		return runtime.ConditionEqual, true
+	case runtime.ConditionLess:
+		return runtime.ConditionGreaterEqual, true
+	case runtime.ConditionLessEqual:
+		return runtime.ConditionGreater, true
+	case runtime.ConditionGreater:
+		return runtime.ConditionLessEqual, true
+	case runtime.ConditionGreaterEqual:
+		return runtime.ConditionLess, true
+	case runtime.ConditionLessU:
+		return runtime.ConditionGreaterEqualU, true
+	case runtime.ConditionLessEqualU:
+		return runtime.ConditionGreaterU, true
+	case runtime.ConditionGreaterU:
+		return runtime.ConditionLessEqualU, true
+	case runtime.ConditionGreaterEqualU:
+		return runtime.ConditionLessU, true
+	case runtime.ConditionLenEqual:
+		return runtime.ConditionLenNotEqual, true
+	case runtime.ConditionLenNotEqual:
+		return runtime.ConditionLenEqual, true
+	case runtime.ConditionLenLess:
+		return runtime.ConditionLenGreaterEqual, true
+	case runtime.ConditionLenLessEqual:
+		return runtime.ConditionLenGreater, true
+	case runtime.ConditionLenGreater:
+		return runtime.ConditionLenLessEqual, true
+	case runtime.ConditionLenGreaterEqual:
+		return runtime.ConditionLenLess, true
+	case runtime.ConditionContainsSubstring:
+		return runtime.ConditionNotContainsSubstring, true
+	case runtime.ConditionNotContainsSubstring:
+		return runtime.ConditionContainsSubstring, true
+	case runtime.ConditionContainsRune:
+		return runtime.ConditionNotContainsRune, true
+	case runtime.ConditionNotContainsRune:
+		return runtime.ConditionContainsRune, true
+	}
+	return 0, false
+}
+
+// intValue returns the index of v in the int values of the function, adding
+// it if it is not present. It returns false if the limit of int values has
+// been reached.
+func (o *optimizer) intValue(v int64) (int, bool) {
+	values := o.fn.Values.Int
+	for i, w := range values {
+		if w == v {
+			return i, true
+		}
+	}
+	if len(values) == maxIntValuesCount {
+		return 0, false
+	}
+	o.fn.Values.Int = append(values, v)
+	return len(values), true
+}
+
+// floatValue is like intValue but for float values.
+func (o *optimizer) floatValue(v float64) (int, bool) {
+	values := o.fn.Values.Float
+	for i, w := range values {
+		if math.Float64bits(w) == math.Float64bits(v) {
+			return i, true
+		}
+	}
+	if len(values) == maxFloatValuesCount {
+		return 0, false
+	}
+	o.fn.Values.Float = append(values, v)
+	return len(values), true
+}
+
+// stringValue is like intValue but for string values.
+func (o *optimizer) stringValue(v string) (int16, bool) {
+	if o.strings == nil {
+		o.strings = make(map[string]int16, len(o.fn.Values.String))
+		for i, s := range o.fn.Values.String {
+			if _, ok := o.strings[s]; !ok {
+				o.strings[s] = int16(i)
+			}
+		}
+	}
+	if i, ok := o.strings[v]; ok {
+		return i, true
+	}
+	i := len(o.fn.Values.String)
+	if i == maxStringValuesCount {
+		return 0, false
+	}
+	o.fn.Values.String = append(o.fn.Values.String, v)
+	o.strings[v] = int16(i)
+	return int16(i), true
+}
@@ -0,0 +1,1221 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compiler
+
+import (
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/open2b/scriggo/internal/runtime"
+)
+
+// maxFoldedStringLen is the maximum length of a string resulting from the
+// folding of a concatenation.
+const maxFoldedStringLen = 1024
+
+// operandKind indicates how an instruction uses an operand.
+type operandKind uint8
+
+const (
+	noOperand        operandKind = iota // not a register
+	readOperand                         // read register
+	writeOperand                        // written register
+	readWriteOperand                    // read and written register
+)
+
+// instrInfo describes how an instruction uses the registers.
+//
+// A negative operand, that is a register, refers to the value pointed by
+// the general register with the opposite value, so it is a read of such
+// general register.
+type instrInfo struct {
+	kinds     [3]operandKind  // kinds of the operands A, B and C.
+	types     [3]registerType // register types of the operands A, B and C.
+	k         int             // index of the operand that is a constant if the k flag is set; -1 if none.
+	pure      bool            // it has no side effects and cannot panic.
+	readsAll  bool            // it can read any register.
+	writesAll bool            // it can write any register.
+	call      bool            // it is a call that writes the registers after frame and reads the arguments.
+	frame     [4]int16        // stack shift of a call.
+	args      [4][2]int16     // first and last registers of the arguments of a call; last is -1 if unknown.
+}
+
+// operand sets the kind and the type of the operand with index i.
+func (info *instrInfo) operand(i int, kind operandKind, t registerType) {
+	info.kinds[i] = kind
+	info.types[i] = t
+}
+
+// isRegister reports whether the operand with index i of in is a register.
+func (info *instrInfo) isRegister(in runtime.Instruction, i int) bool {
+	return info.kinds[i] != noOperand && !(i == info.k && in.Op < 0) && operandOf(in, i) != 0
+}
+
+// operandOf returns the operand with index i of in.
+func operandOf(in runtime.Instruction, i int) int16 {
+	switch i {
+	case 0:
+		return in.A
+	case 1:
+		return in.B
+	}
+	return in.C
+}
+
+// setOperand sets the operand with index i of in to v.
+func setOperand(in *runtime.Instruction, i int, v int16) {
+	switch i {
+	case 0:
+		in.A = v
+	case 1:
+		in.B = v
+	default:
+		in.C = v
+	}
+}
+
+// info returns the information about the use of the registers of in. The
+// instructions that are not described read and write any register.
+func (o *optimizer) info(in runtime.Instruction) instrInfo {
+	info := instrInfo{k: -1}
+	op := in.Op
+	if op < 0 {
+		op = -op
+	}
+	switch op {
+	case runtime.OpNone, runtime.OpGoto, runtime.OpBreak, runtime.OpContinue:
+		info.pure = true
+	case runtime.OpMove:
+		t := registerType(in.A)
+		info.operand(1, readOperand, t)
+		info.operand(2, writeOperand, t)
+		info.k = 1
+		info.pure = true
+	case runtime.OpLoad:
+		t, _ := decodeValueIndex(in.A, in.B)
+		info.operand(2, writeOperand, t)
+		info.pure = true
+	case runtime.OpAddInt, runtime.OpSubInt, runtime.OpMulInt, runtime.OpSubInvInt, runtime.OpDivInt, runtime.OpRemInt,
+		runtime.OpAnd, runtime.OpAndNot, runtime.OpOr, runtime.OpXor:
+		info.operand(0, readOperand, intRegister)
+		info.operand(1, readOperand, intRegister)
+		info.operand(2, writeOperand, intRegister)
+		info.k = 1
+		info.pure = op != runtime.OpDivInt && op != runtime.OpRemInt || in.Op < 0 && in.B != 0
+	case runtime.OpAddFloat64, runtime.OpSubFloat64, runtime.OpMulFloat64, runtime.OpSubInvFloat64, runtime.OpDivFloat64:
+		info.operand(0, readOperand, floatRegister)
+		info.operand(1, readOperand, floatRegister)
+		info.operand(2, writeOperand, floatRegister)
+		info.k = 1
+		info.pure = true
+	case runtime.OpAdd, runtime.OpSub, runtime.OpMul, runtime.OpSubInv:
+		t := kindToType(reflect.Kind(in.A))
+		info.operand(1, readOperand, t)
+		info.operand(2, readWriteOperand, t)
+		info.k = 1
+		info.pure = true
+	case runtime.OpNeg:
+		t := kindToType(reflect.Kind(in.A))
+		info.operand(1, readOperand, t)
+		info.operand(2, writeOperand, t)
+		info.pure = true
+	case runtime.OpConcat:
+		info.operand(0, readOperand, stringRegister)
+		info.operand(1, readOperand, stringRegister)
+		info.operand(2, writeOperand, stringRegister)
+		info.pure = true
+	case runtime.OpLen:
+		if registerType(in.A) == stringRegister {
+			info.operand(1, readOperand, stringRegister)
+			info.pure = true
+		} else {
+			info.operand(1, readOperand, generalRegister)
+		}
+		info.operand(2, writeOperand, intRegister)
+	case runtime.OpTypify:
+		info.operand(1, readOperand, o.typeRegister(in.A))
+		info.operand(2, writeOperand, generalRegister)
+		info.k = 1
+		info.pure = true
+	case runtime.OpShow:
+		info.operand(1, readOperand, o.typeRegister(in.A))
+	case runtime.OpText, runtime.OpCover:
+	case runtime.OpPrint:
+		info.operand(0, readOperand, generalRegister)
+	case runtime.OpIfInt:
+		switch runtime.Condition(in.B) {
+		case runtime.ConditionZero, runtime.ConditionNotZero:
+			info.operand(0, readOperand, intRegister)
+		case runtime.ConditionEqual, runtime.ConditionNotEqual, runtime.ConditionLess, runtime.ConditionLessEqual,
+			runtime.ConditionGreater, runtime.ConditionGreaterEqual, runtime.ConditionLessU, runtime.ConditionLessEqualU,
+			runtime.ConditionGreaterU, runtime.ConditionGreaterEqualU:
+			info.operand(0, readOperand, intRegister)
+			info.operand(2, readOperand, intRegister)
+			info.k = 2
+		case runtime.ConditionContainsRune, runtime.ConditionNotContainsRune:
+			info.operand(0, readOperand, stringRegister)
+			info.operand(2, readOperand, intRegister)
+			info.k = 2
+		default:
+			info.readsAll = true
+		}
+		info.pure = !info.readsAll
+	case runtime.OpIfFloat:
+		switch runtime.Condition(in.B) {
+		case runtime.ConditionEqual, runtime.ConditionNotEqual, runtime.ConditionLess, runtime.ConditionLessEqual,
+			runtime.ConditionGreater, runtime.ConditionGreaterEqual:
+			info.operand(0, readOperand, floatRegister)
+			info.operand(2, readOperand, floatRegister)
+			info.k = 2
+		default:
+			info.readsAll = true
+		}
+		info.pure = !info.readsAll
+	case runtime.OpIfString:
+		switch runtime.Condition(in.B) {
+		case runtime.ConditionEqual, runtime.ConditionNotEqual, runtime.ConditionLess, runtime.ConditionLessEqual,
+			runtime.ConditionGreater, runtime.ConditionGreaterEqual,
+			runtime.ConditionContainsSubstring, runtime.ConditionNotContainsSubstring:
+			info.operand(0, readOperand, stringRegister)
+			info.operand(2, readOperand, stringRegister)
+			info.k = 2
+		case runtime.ConditionLenEqual, runtime.ConditionLenNotEqual, runtime.ConditionLenLess,
+			runtime.ConditionLenLessEqual, runtime.ConditionLenGreater, runtime.ConditionLenGreaterEqual:
+			info.operand(0, readOperand, stringRegister)
+			info.operand(2, readOperand, intRegister)
+			info.k = 2
+		default:
+			info.readsAll = true
+		}
+		info.pure = !info.readsAll
+	case runtime.OpCallFunc, runtime.OpCallMacro, runtime.OpCallNative:
+		info.call = true
+	case runtime.OpCallIndirect:
+		info.operand(0, readOperand, generalRegister)
+		info.call = true
+	case runtime.OpGetVar:
+		info.writesAll = true
+	case runtime.OpReturn:
+		// Return is not pure, so it reads the result parameters, but it also
+		// reads the indirect variables of the final registers.
+		info.readsAll = o.fn.FinalRegs != nil
+	case runtime.OpIf:
+		info.readsAll = true
+	default:
+		info.readsAll = true
+		info.writesAll = true
+	}
+	// An indirect register can be a nil pointer.
+	for j := 0; j < 3; j++ {
+		if info.isRegister(in, j) && operandOf(in, j) < 0 {
+			info.pure = false
+		}
+	}
+	return info
+}
+
+// nodeInfo is like info but returns the information about the instruction
+// of the node n, including the stack shift if it is a call.
+func (o *optimizer) nodeInfo(n *optNode) instrInfo {
+	info := o.info(n.in)
+	if !info.call {
+		return info
+	}
+	shift := n.extra[0]
+	info.frame = [4]int16{int16(shift.Op), shift.A, shift.B, shift.C}
+	for t := range info.args {
+		info.args[t] = [2]int16{info.frame[t] + 1, -1}
+	}
+	// The arguments follow the results in the frame of the function.
+	var typ reflect.Type
+	switch n.in.Op {
+	case runtime.OpCallFunc, runtime.OpCallMacro:
+		typ = o.fn.Functions[uint16(n.in.A)].Type
+	case runtime.OpCallNative:
+		typ = reflect.TypeOf(o.fn.NativeFunctions[uint16(n.in.A)].Func())
+	case runtime.OpCallIndirect:
+		// The called function can be a native function, so the variadic
+		// arguments are not known.
+		if n.debug != nil && n.debug.FuncType != nil && !n.debug.FuncType.IsVariadic() {
+			typ = n.debug.FuncType
+		}
+	}
+	if typ == nil || typ.Kind() != reflect.Func {
+		return info
+	}
+	var outs, ins [4]int16
+	for i := 0; i < typ.NumOut(); i++ {
+		outs[kindToType(typ.Out(i).Kind())]++
+	}
+	numIn := typ.NumIn()
+	for i := 0; i < numIn; i++ {
+		in := typ.In(i)
+		if n.in.Op == runtime.OpCallNative {
+			if i == numIn-1 && typ.IsVariadic() && n.in.C != runtime.NoVariadicArgs {
+				ins[kindToType(in.Elem().Kind())] += n.in.C
+				continue
+			}
+			if i < 2 && in == envType {
+				continue
+			}
+		}
+		ins[kindToType(in.Kind())]++
+	}
+	for t := range info.args {
+		first := info.frame[t] + outs[t] + 1
+		info.args[t] = [2]int16{first, first + ins[t] - 1}
+	}
+	return info
+}
+
+// typeRegister returns the register type of the values of the type with
+// index i in the types of the function.
+func (o *optimizer) typeRegister(i int16) registerType {
+	return kindToType(o.fn.Types[uint16(i)].Kind())
+}
+
+// regKey is the key of a register in a regState.
+type regKey int32
+
+// newRegKey returns the key of the register r with type t.
+func newRegKey(t registerType, r int16) regKey {
+	return regKey(t)<<16 | regKey(uint16(r))
+}
+
+// regValue is the value of a register in a regState. It is a copy of
+// another register, if copyOf is not zero, or a constant.
+type regValue struct {
+	copyOf int16
+	i      int64
+	f      float64
+	s      string
+}
+
+// equal reports whether v and w are the same value.
+func (v regValue) equal(w regValue) bool {
+	return v.copyOf == w.copyOf && v.i == w.i && math.Float64bits(v.f) == math.Float64bits(w.f) && v.s == w.s
+}
+
+// regState is the state of the registers, with known values, at a point of
+// the execution.
+type regState map[regKey]regValue
+
+// copy returns a copy of st.
+func (st regState) copy() regState {
+	c := make(regState, len(st))
+	for k, v := range st {
+		c[k] = v
+	}
+	return c
+}
+
+// meet keeps in st only the values that are also in other. It reports
+// whether st has been changed.
+func (st regState) meet(other regState) bool {
+	changed := false
+	for k, v := range st {
+		if w, ok := other[k]; !ok || !v.equal(w) {
+			delete(st, k)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// kill removes the value of the register r with type t and the values of
+// the registers that are copies of it.
+func (st regState) kill(t registerType, r int16) {
+	delete(st, newRegKey(t, r))
+	for k, v := range st {
+		if v.copyOf == r && registerType(k>>16) == t {
+			delete(st, k)
+		}
+	}
+}
+
+// killFrame removes the values of the registers after frame and the values
+// of the registers that are copies of them.
+func (st regState) killFrame(frame [4]int16) {
+	for k, v := range st {
+		t := registerType(k >> 16)
+		if r := int16(uint16(k)); r > frame[t] || v.copyOf > frame[t] {
+			delete(st, k)
+		}
+	}
+}
+
+// propagate propagates the constant values and the copies of the registers,
+// folding the instructions with constant operands.
+func (o *optimizer) propagate() {
+	blocks := o.blocks()
+	entries := make([]regState, len(blocks))
+	entries[0] = regState{}
+	for changed := true; changed; {
+		changed = false
+		for b, block := range blocks {
+			if entries[b] == nil {
+				continue
+			}
+			st := entries[b].copy()
+			o.transferBlock(block, st, false)
+			for _, s := range block.succs {
+				if entries[s] == nil {
+					entries[s] = st.copy()
+					changed = true
+				} else if entries[s].meet(st) {
+					changed = true
+				}
+			}
+		}
+	}
+	for b, block := range blocks {
+		if entries[b] != nil {
+			o.transferBlock(block, entries[b], true)
+		}
+	}
+}
+
+// transferBlock updates st executing the nodes of block. If rewrite is true,
+// it also rewrites the nodes based on the known values.
+func (o *optimizer) transferBlock(block optBlock, st regState, rewrite bool) {
+	for i := block.first; i >= 0 && i <= block.last; i = o.next(i) {
+		n := o.nodes[i]
+		info := o.nodeInfo(n)
+		if info.writesAll {
+			for k := range st {
+				delete(st, k)
+			}
+			continue
+		}
+		if rewrite {
+			o.rewrite(i, info, st)
+			if n.deleted {
+				continue
+			}
+			info = o.nodeInfo(n)
+		}
+		if info.call {
+			st.killFrame(info.frame)
+		}
+		in := n.in
+		var v regValue
+		var known bool
+		if info.kinds[2] == writeOperand || info.kinds[2] == readWriteOperand {
+			v, known = o.evaluate(in, info, st)
+			if !known && (in.Op == runtime.OpMove && in.B > 0 && info.types[2] != generalRegister) {
+				v, known = regValue{copyOf: in.B}, true
+				if w, ok := st[newRegKey(info.types[1], in.B)]; ok {
+					v = w
+				}
+			}
+		}
+		for j := 0; j < 3; j++ {
+			if k := info.kinds[j]; k == writeOperand || k == readWriteOperand {
+				if r := operandOf(in, j); r > 0 {
+					st.kill(info.types[j], r)
+				}
+			}
+		}
+		if known && in.C > 0 && v.copyOf != in.C {
+			st[newRegKey(info.types[2], in.C)] = v
+		}
+	}
+}
+
+// rewrite rewrites the node with index i, with information info, based on
+// the known values in st.
+func (o *optimizer) rewrite(i int, info instrInfo, st regState) {
+	n := o.nodes[i]
+	in := n.in
+	defer func() {
+		if !n.deleted && n.in != in {
+			n.in = in
+			o.changed = true
+		}
+	}()
+
+	// Read the registers of which the read registers are copies.
+	for j := 0; j < 3; j++ {
+		if info.kinds[j] != readOperand || !info.isRegister(in, j) {
+			continue
+		}
+		if r := operandOf(in, j); r > 0 {
+			if v, ok := st[newRegKey(info.types[j], r)]; ok && v.copyOf != 0 {
+				setOperand(&in, j, v.copyOf)
+			}
+		}
+	}
+
+	// Replace the instruction with a constant, if its result is constant.
+	if in.Op != -runtime.OpMove && in.Op != runtime.OpLoad && info.kinds[2] != readOperand && in.C > 0 {
+		if v, ok := o.evaluate(in, info, st); ok {
+			if c, ok := o.constInstruction(info.types[2], v, in.C); ok {
+				in = c
+				return
+			}
+		}
+	}
+
+	// Replace a register operand with a constant, swapping the operands if
+	// the constant is the other operand.
+	if info.k >= 0 && in.Op > 0 && !o.inlineConstant(&in, info, st) {
+		if _, ok := o.value(in, info, 0, st); ok {
+			swapped := in
+			if swapOperands(&swapped) && o.inlineConstant(&swapped, info, st) {
+				in = swapped
+			}
+		}
+	}
+
+	// Replace a condition with a jump, if it is always true, or remove it,
+	// if it is always false.
+	switch in.Op {
+	case runtime.OpIfInt, -runtime.OpIfInt, runtime.OpIfFloat, -runtime.OpIfFloat, runtime.OpIfString, -runtime.OpIfString:
+		if cond, ok := o.condition(in, info, st); ok {
+			next := o.next(i)
+			if !cond {
+				if !o.pinned(i) {
+					n.deleted = true
+					o.changed = true
+				}
+			} else if next >= 0 && o.next(next) >= 0 {
+				in = runtime.Instruction{Op: runtime.OpGoto}
+				n.target = o.nodes[o.next(next)]
+			}
+		}
+	}
+}
+
+// inlineConstant replaces the register operand of in, that can be a
+// constant, with its value if it is known and can be represented as an
+// operand. It reports whether the operand has been replaced.
+func (o *optimizer) inlineConstant(in *runtime.Instruction, info instrInfo, st regState) bool {
+	v, ok := o.value(*in, info, info.k, st)
+	if !ok {
+		return false
+	}
+	// A division by a constant zero is left as is, so it panics as a
+	// division by a register.
+	if (in.Op == runtime.OpDivInt || in.Op == runtime.OpRemInt) && v.i == 0 {
+		return false
+	}
+	k, ok := o.inlineOperand(info.types[info.k], v)
+	if !ok {
+		return false
+	}
+	setOperand(in, info.k, k)
+	in.Op = -in.Op
+	return true
+}
+
+// swapOperands swaps the operands A and B, or A and C for conditions, of in
+// if the result does not change, changing its operation if necessary. It
+// reports whether the operands have been swapped.
+func swapOperands(in *runtime.Instruction) bool {
+	switch in.Op {
+	case runtime.OpAddInt, runtime.OpMulInt, runtime.OpAnd, runtime.OpOr, runtime.OpXor,
+		runtime.OpAddFloat64, runtime.OpMulFloat64:
+	case runtime.OpSubInt:
+		in.Op = runtime.OpSubInvInt
+	case runtime.OpSubInvInt:
+		in.Op = runtime.OpSubInt
+	case runtime.OpSubFloat64:
+		in.Op = runtime.OpSubInvFloat64
+	case runtime.OpSubInvFloat64:
+		in.Op = runtime.OpSubFloat64
+	case runtime.OpIfInt, runtime.OpIfFloat, runtime.OpIfString:
+		var cond runtime.Condition
+		switch c := runtime.Condition(in.B); c {
+		case runtime.ConditionEqual, runtime.ConditionNotEqual:
+			cond = c
+		case runtime.ConditionLess:
+			cond = runtime.ConditionGreater
+		case runtime.ConditionLessEqual:
+			cond = runtime.ConditionGreaterEqual
+		case runtime.ConditionGreater:
+			cond = runtime.ConditionLess
+		case runtime.ConditionGreaterEqual:
+			cond = runtime.ConditionLessEqual
+		case runtime.ConditionLessU:
+			cond = runtime.ConditionGreaterU
+		case runtime.ConditionLessEqualU:
+			cond = runtime.ConditionGreaterEqualU
+		case runtime.ConditionGreaterU:
+			cond = runtime.ConditionLessU
+		case runtime.ConditionGreaterEqualU:
+			cond = runtime.ConditionLessEqualU
+		default:
+			return false
+		}
+		in.A, in.B, in.C = in.C, int16(cond), in.A
+		return true
+	default:
+		return false
+	}
+	in.A, in.B = in.B, in.A
+	return true
+}
+
+// value returns the value of the operand with index i of in, if it is known.
+func (o *optimizer) value(in runtime.Instruction, info instrInfo, i int, st regState) (regValue, bool) {
+	r := operandOf(in, i)
+	if i == info.k && in.Op < 0 {
+		switch info.types[i] {
+		case intRegister:
+			return regValue{i: int64(r)}, true
+		case floatRegister:
+			return regValue{f: float64(r)}, true
+		case stringRegister:
+			return regValue{s: o.fn.Values.String[uint16(r)]}, true
+		}
+		return regValue{}, false
+	}
+	if info.kinds[i] == noOperand || r <= 0 || info.types[i] == generalRegister {
+		return regValue{}, false
+	}
+	v, ok := st[newRegKey(info.types[i], r)]
+	return v, ok && v.copyOf == 0
+}
+
+// inlineOperand returns the operand, with the k flag set, that represents
+// the constant v with type t. It returns false if v cannot be represented as
+// an operand.
+func (o *optimizer) inlineOperand(t registerType, v regValue) (int16, bool) {
+	switch t {
+	case intRegister:
+		if -128 <= v.i && v.i <= 127 {
+			return int16(v.i), true
+		}
+	case floatRegister:
+		if math.Trunc(v.f) == v.f && -128 <= v.f && v.f <= 127 && !(v.f == 0 && math.Signbit(v.f)) {
+			return int16(v.f), true
+		}
+	case stringRegister:
+		return o.stringValue(v.s)
+	}
+	return 0, false
+}
+
+// constInstruction returns an instruction that stores the constant v, with
+// type t, into the register dst. It returns false if such instruction
+// cannot be created.
+func (o *optimizer) constInstruction(t registerType, v regValue, dst int16) (runtime.Instruction, bool) {
+	if k, ok := o.inlineOperand(t, v); ok {
+		return runtime.Instruction{Op: -runtime.OpMove, A: int16(t), B: k, C: dst}, true
+	}
+	var i int
+	var ok bool
+	switch t {
+	case intRegister:
+		i, ok = o.intValue(v.i)
+	case floatRegister:
+		i, ok = o.floatValue(v.f)
+	}
+	if !ok {
+		return runtime.Instruction{}, false
+	}
+	a, b := encodeValueIndex(t, i)
+	return runtime.Instruction{Op: runtime.OpLoad, A: a, B: b, C: dst}, true
+}
+
+// evaluate returns the value stored by in into the register C, if it is a
+// constant that can be computed from the known values in st.
+func (o *optimizer) evaluate(in runtime.Instruction, info instrInfo, st regState) (regValue, bool) {
+	op := in.Op
+	if op < 0 {
+		op = -op
+	}
+	var x, y regValue
+	var ok bool
+	switch op {
+	case runtime.OpMove:
+		if info.types[2] == generalRegister {
+			return regValue{}, false
+		}
+		return o.value(in, info, 1, st)
+	case runtime.OpLoad:
+		t, i := decodeValueIndex(in.A, in.B)
+		switch t {
+		case intRegister:
+			return regValue{i: o.fn.Values.Int[i]}, true
+		case floatRegister:
+			return regValue{f: o.fn.Values.Float[i]}, true
+		case stringRegister:
+			return regValue{s: o.fn.Values.String[i]}, true
+		}
+		return regValue{}, false
+	case runtime.OpAdd, runtime.OpSub, runtime.OpMul, runtime.OpSubInv:
+		if x, ok = o.value(in, info, 2, st); !ok {
+			return regValue{}, false
+		}
+		y, ok = o.value(in, info, 1, st)
+	case runtime.OpNeg, runtime.OpLen:
+		if info.kinds[1] == noOperand || info.types[1] == generalRegister {
+			return regValue{}, false
+		}
+		y, ok = o.value(in, info, 1, st)
+	default:
+		if info.kinds[0] != readOperand || info.kinds[1] != readOperand || info.kinds[2] != writeOperand {
+			return regValue{}, false
+		}
+		if x, ok = o.value(in, info, 0, st); !ok {
+			return regValue{}, false
+		}
+		y, ok = o.value(in, info, 1, st)
+	}
+	if !ok {
+		return regValue{}, false
+	}
+	var v regValue
+	switch op {
+	case runtime.OpAddInt:
+		v.i = x.i + y.i
+	case runtime.OpSubInt:
+		v.i = x.i - y.i
+	case runtime.OpMulInt:
+		v.i = x.i * y.i
+	case runtime.OpSubInvInt:
+		v.i = y.i - x.i
+	case runtime.OpDivInt:
+		if y.i == 0 {
+			return regValue{}, false
+		}
+		v.i = x.i / y.i
+	case runtime.OpRemInt:
+		if y.i == 0 {
+			return regValue{}, false
+		}
+		v.i = x.i % y.i
+	case runtime.OpAnd:
+		v.i = x.i & y.i
+	case runtime.OpAndNot:
+		v.i = x.i &^ y.i
+	case runtime.OpOr:
+		v.i = x.i | y.i
+	case runtime.OpXor:
+		v.i = x.i ^ y.i
+	case runtime.OpAddFloat64:
+		v.f = x.f + y.f
+	case runtime.OpSubFloat64:
+		v.f = x.f - y.f
+	case runtime.OpMulFloat64:
+		v.f = x.f * y.f
+	case runtime.OpSubInvFloat64:
+		v.f = y.f - x.f
+	case runtime.OpDivFloat64:
+		v.f = x.f / y.f
+	case runtime.OpAdd, runtime.OpSub, runtime.OpMul, runtime.OpSubInv:
+		kind := reflect.Kind(in.A)
+		if kind == reflect.Float32 || kind == reflect.Float64 {
+			switch op {
+			case runtime.OpAdd:
+				v.f = y.f + x.f
+			case runtime.OpSub:
+				v.f = x.f - y.f
+			case runtime.OpMul:
+				v.f = x.f * y.f
+			case runtime.OpSubInv:
+				v.f = y.f - x.f
+			}
+			if kind == reflect.Float32 {
+				v.f = float64(float32(v.f))
+			}
+			break
+		}
+		switch op {
+		case runtime.OpAdd:
+			v.i = y.i + x.i
+		case runtime.OpSub:
+			v.i = x.i - y.i
+		case runtime.OpMul:
+			v.i = x.i * y.i
+		case runtime.OpSubInv:
+			v.i = y.i - x.i
+		}
+		v.i = truncInt(kind, v.i)
+	case runtime.OpNeg:
+		switch kind := reflect.Kind(in.A); kind {
+		case reflect.Float32:
+			v.f = float64(-float32(y.f))
+		case reflect.Float64:
+			v.f = -y.f
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			v.i = truncInt(kind, -y.i)
+		default:
+			return regValue{}, false
+		}
+	case runtime.OpConcat:
+		if len(x.s)+len(y.s) > maxFoldedStringLen {
+			return regValue{}, false
+		}
+		v.s = x.s + y.s
+	case runtime.OpLen:
+		v.i = int64(len(y.s))
+	default:
+		return regValue{}, false
+	}
+	return v, true
+}
+
+// truncInt truncates v to the size of the integer kind k, as the VM does.
+func truncInt(k reflect.Kind, v int64) int64 {
+	switch k {
+	case reflect.Int8:
+		return int64(int8(v))
+	case reflect.Int16:
+		return int64(int16(v))
+	case reflect.Int32:
+		return int64(int32(v))
+	case reflect.Uint8:
+		return int64(uint8(v))
+	case reflect.Uint16:
+		return int64(uint16(v))
+	case reflect.Uint32:
+		return int64(uint32(v))
+	}
+	return v
+}
+
+// condition returns the result of the condition in, if it can be computed
+// from the known values in st.
+func (o *optimizer) condition(in runtime.Instruction, info instrInfo, st regState) (bool, bool) {
+	if info.readsAll {
+		return false, false
+	}
+	x, ok := o.value(in, info, 0, st)
+	if !ok {
+		return false, false
+	}
+	cond := runtime.Condition(in.B)
+	switch cond {
+	case runtime.ConditionZero:
+		return x.i == 0, true
+	case runtime.ConditionNotZero:
+		return x.i != 0, true
+	}
+	y, ok := o.value(in, info, 2, st)
+	if !ok {
+		return false, false
+	}
+	op := in.Op
+	if op < 0 {
+		op = -op
+	}
+	switch op {
+	case runtime.OpIfInt:
+		switch cond {
+		case runtime.ConditionContainsRune:
+			return strings.ContainsRune(x.s, rune(y.i)), true
+		case runtime.ConditionNotContainsRune:
+			return !strings.ContainsRune(x.s, rune(y.i)), true
+		case runtime.ConditionLessU:
+			return uint64(x.i) < uint64(y.i), true
+		case runtime.ConditionLessEqualU:
+			return uint64(x.i) <= uint64(y.i), true
+		case runtime.ConditionGreaterU:
+			return uint64(x.i) > uint64(y.i), true
+		case runtime.ConditionGreaterEqualU:
+			return uint64(x.i) >= uint64(y.i), true
+		}
+		return compare(cond, x.i, y.i)
+	case runtime.OpIfFloat:
+		return compare(cond, x.f, y.f)
+	case runtime.OpIfString:
+		switch cond {
+		case runtime.ConditionContainsSubstring:
+			return strings.Contains(x.s, y.s), true
+		case runtime.ConditionNotContainsSubstring:
+			return !strings.Contains(x.s, y.s), true
+		case runtime.ConditionLenEqual:
+			return compare(runtime.ConditionEqual, int64(len(x.s)), y.i)
+		case runtime.ConditionLenNotEqual:
+			return compare(runtime.ConditionNotEqual, int64(len(x.s)), y.i)
+		case runtime.ConditionLenLess:
+			return compare(runtime.ConditionLess, int64(len(x.s)), y.i)
+		case runtime.ConditionLenLessEqual:
+			return compare(runtime.ConditionLessEqual, int64(len(x.s)), y.i)
+		case runtime.ConditionLenGreater:
+			return compare(runtime.ConditionGreater, int64(len(x.s)), y.i)
+		case runtime.ConditionLenGreaterEqual:
+			return compare(runtime.ConditionGreaterEqual, int64(len(x.s)), y.i)
+		}
+		return compare(cond, x.s, y.s)
+	}
+	return false, false
+}
+
+// compare returns the result of the comparison cond between x and y, that
+// have both type int64, float64 or string. It returns false if cond is not a
+// comparison.
+func compare(cond runtime.Condition, x, y interface{}) (bool, bool) {
+	var c int
+	switch x := x.(type) {
+	case int64:
+		c = compareOrdered(x < y.(int64), x > y.(int64))
+	case float64:
+		y := y.(float64)
+		if math.IsNaN(x) || math.IsNaN(y) {
+			// Every comparison with NaN is false, except not equal.
+			switch cond {
+			case runtime.ConditionNotEqual:
+				return true, true
+			case runtime.ConditionEqual, runtime.ConditionLess, runtime.ConditionLessEqual,
+				runtime.ConditionGreater, runtime.ConditionGreaterEqual:
+				return false, true
+			}
+			return false, false
+		}
+		c = compareOrdered(x < y, x > y)
+	case string:
+		c = compareOrdered(x < y.(string), x > y.(string))
+	}
+	switch cond {
+	case runtime.ConditionEqual:
+		return c == 0, true
+	case runtime.ConditionNotEqual:
+		return c != 0, true
+	case runtime.ConditionLess:
+		return c < 0, true
+	case runtime.ConditionLessEqual:
+		return c <= 0, true
+	case runtime.ConditionGreater:
+		return c > 0, true
+	case runtime.ConditionGreaterEqual:
+		return c >= 0, true
+	}
+	return false, false
+}
+
+// compareOrdered returns -1 if less is true, 1 if greater is true and 0
+// otherwise.
+func compareOrdered(less, greater bool) int {
+	if less {
+		return -1
+	}
+	if greater {
+		return 1
+	}
+	return 0
+}
+
+// regSet is a set of registers.
+type regSet []uint64
+
+func newRegSet(size int) regSet {
+	return make(regSet, (size+63)/64)
+}
+
+func (s regSet) add(i int)      { s[i/64] |= 1 << (i % 64) }
+func (s regSet) remove(i int)   { s[i/64] &^= 1 << (i % 64) }
+func (s regSet) has(i int) bool { return s[i/64]&(1<<(i%64)) != 0 }
+
+// fill adds all the registers to s.
+func (s regSet) fill() {
+	for i := range s {
+		s[i] = math.MaxUint64
+	}
+}
+
+// union adds the registers of t to s and reports whether s has been changed.
+func (s regSet) union(t regSet) bool {
+	changed := false
+	for i, w := range t {
+		if s[i]|w != s[i] {
+			s[i] |= w
+			changed = true
+		}
+	}
+	return changed
+}
+
+// removeDeadStores removes the pure instructions that store values into
+// registers that are not read afterwards, and fuses an instruction with the
+// following Move of its result into another register.
+func (o *optimizer) removeDeadStores() {
+	lr := o.liveness()
+	for _, block := range lr.blocks {
+		lr.block(block, lr.out(block), true)
+	}
+}
+
+// fuseConditions replaces the sequences of instructions that store the
+// result of a condition into a register and then test the register, as in
+//
+//	Move 1 r
+//	If cond
+//	Move 0 r
+//	If r NotZero
+//	Goto 1
+//
+// with a single condition, negated if necessary, if the register is not
+// read afterwards
+//
+//	If cond
+//	Goto 1
+func (o *optimizer) fuseConditions() {
+	lr := o.liveness()
+	blocks := lr.blocks
+	preds := make([]int, len(blocks))
+	for _, block := range blocks {
+		for _, s := range block.succs {
+			preds[s]++
+		}
+	}
+	for b := 0; b+2 < len(blocks); b++ {
+		// The first two instructions end the block b, the third one is the
+		// block b+1 and the fourth one is the block b+2. The condition can
+		// skip the third instruction, so b+2 has also b as predecessor.
+		cond := blocks[b].last
+		set := o.prev(cond)
+		if set < blocks[b].first || o.pinned(set) || preds[b+1] != 1 || preds[b+2] != 2 {
+			continue
+		}
+		reset, test := blocks[b+1].first, blocks[b+2].first
+		if blocks[b+1].last != reset || blocks[b+2].last != test {
+			continue
+		}
+		s, c, r, t := o.nodes[set].in, o.nodes[cond].in, o.nodes[reset].in, o.nodes[test].in
+		if s.Op != -runtime.OpMove || s.A != int16(intRegister) || s.B != 1 || s.C <= 0 ||
+			r.Op != -runtime.OpMove || r.A != int16(intRegister) || r.B != 0 || r.C != s.C ||
+			t.Op != runtime.OpIfInt && t.Op != -runtime.OpIfInt || t.A != s.C {
+			continue
+		}
+		switch o.nodes[cond].in.Op {
+		case runtime.OpIf, -runtime.OpIf, runtime.OpIfInt, -runtime.OpIfInt,
+			runtime.OpIfFloat, -runtime.OpIfFloat, runtime.OpIfString, -runtime.OpIfString:
+		default:
+			continue
+		}
+		// The register must not be read by the condition and afterwards.
+		info := o.info(c)
+		if info.readsAll || lr.out(blocks[b+2]).has(lr.base[intRegister]+int(s.C)) {
+			continue
+		}
+		readsRegister := false
+		for j := 0; j < 3; j++ {
+			if info.isRegister(c, j) && info.types[j] == intRegister && operandOf(c, j) == s.C {
+				readsRegister = true
+			}
+		}
+		if readsRegister {
+			continue
+		}
+		// Determine if the test is true when the condition is true.
+		var same bool
+		switch runtime.Condition(t.B) {
+		case runtime.ConditionNotZero:
+			same = true
+		case runtime.ConditionZero:
+			same = false
+		case runtime.ConditionEqual, runtime.ConditionNotEqual:
+			if t.Op > 0 || t.C != 0 && t.C != 1 {
+				continue
+			}
+			same = (t.C == 1) == (runtime.Condition(t.B) == runtime.ConditionEqual)
+		default:
+			continue
+		}
+		if !same {
+			negated, ok := negateCondition(c.Op, runtime.Condition(c.B))
+			if !ok {
+				continue
+			}
+			o.nodes[cond].in.B = int16(negated)
+		}
+		o.nodes[set].deleted = true
+		o.nodes[reset].deleted = true
+		o.nodes[test].deleted = true
+		o.changed = true
+		b += 2
+	}
+}
+
+// liveness returns the live registers at the beginning of each block.
+func (o *optimizer) liveness() *liveRegs {
+	// Registers are counted also from the operands because the number of
+	// registers of a function is not required to include the registers used
+	// only to pass the arguments to the called functions.
+	var count [4]int
+	for t, n := range o.fn.NumReg {
+		count[t] = int(n)
+	}
+	for _, n := range o.nodes {
+		if n.deleted {
+			continue
+		}
+		info := o.info(n.in)
+		for j := 0; j < 3; j++ {
+			if info.isRegister(n.in, j) {
+				t, r := info.types[j], int(operandOf(n.in, j))
+				if r < 0 {
+					t, r = generalRegister, -r
+				}
+				if r > count[t] {
+					count[t] = r
+				}
+			}
+		}
+	}
+	lr := &liveRegs{o: o, count: count, blocks: o.blocks()}
+	for t := range lr.base {
+		lr.base[t] = lr.size
+		lr.size += count[t] + 1
+	}
+	lr.ins = make([]regSet, len(lr.blocks))
+	for b := range lr.blocks {
+		lr.ins[b] = newRegSet(lr.size)
+	}
+	for changed := true; changed; {
+		changed = false
+		for b := len(lr.blocks) - 1; b >= 0; b-- {
+			live := lr.out(lr.blocks[b])
+			lr.block(lr.blocks[b], live, false)
+			if lr.ins[b].union(live) {
+				changed = true
+			}
+		}
+	}
+	return lr
+}
+
+// liveRegs contains the live registers of the blocks of a function.
+type liveRegs struct {
+	o      *optimizer
+	count  [4]int // number of registers of each type.
+	base   [4]int // index in a regSet of the register 0 of each type.
+	size   int
+	blocks []optBlock
+	ins    []regSet // live registers at the beginning of each block.
+}
+
+// out returns the registers that are live at the end of block.
+func (lr *liveRegs) out(block optBlock) regSet {
+	live := newRegSet(lr.size)
+	for _, s := range block.succs {
+		live.union(lr.ins[s])
+	}
+	return live
+}
+
+// block updates live, that contains the live registers at the end of block,
+// with the live registers at the beginning of block. If rewrite is true, it
+// also removes the dead stores and fuses the moves.
+func (lr *liveRegs) block(block optBlock, live regSet, rewrite bool) {
+	o := lr.o
+	move := -1
+	var afterMove regSet
+	for i := block.last; i >= block.first; i = o.prev(i) {
+		n := o.nodes[i]
+		info := o.nodeInfo(n)
+		if rewrite {
+			if lr.isDeadStore(i, info, live) {
+				n.deleted = true
+				o.changed = true
+				move = -1
+				continue
+			}
+			if move >= 0 && lr.fuse(n, info, o.nodes[move]) {
+				copy(live, afterMove)
+				info = o.nodeInfo(n)
+			}
+			move = -1
+			if in := n.in; in.Op == runtime.OpMove && in.A != int16(generalRegister) && in.B > 0 && in.C != 0 && in.B != in.C {
+				if !live.has(lr.base[in.A] + int(in.B)) {
+					move = i
+					afterMove = append(afterMove[:0], live...)
+				}
+			}
+		}
+		lr.transfer(n.in, info, live)
+	}
+}
+
+// isDeadStore reports whether the node with index i, with information info,
+// only stores values into registers that are not in live.
+func (lr *liveRegs) isDeadStore(i int, info instrInfo, live regSet) bool {
+	o := lr.o
+	if !info.pure || o.pinned(i) {
+		return false
+	}
+	in := o.nodes[i].in
+	if in.Op == runtime.OpNone {
+		return true
+	}
+	stores := false
+	for j := 0; j < 3; j++ {
+		if k := info.kinds[j]; k == writeOperand || k == readWriteOperand {
+			r := operandOf(in, j)
+			if r < 0 || r > 0 && live.has(lr.base[info.types[j]]+int(r)) {
+				return false
+			}
+			stores = stores || r > 0
+		}
+	}
+	return stores
+}
+
+// fuse fuses the node n, with information info, with the following Move
+// node m, that moves the result of n into another register, if possible.
+// It reports whether the nodes have been fused.
+func (lr *liveRegs) fuse(n *optNode, info instrInfo, m *optNode) bool {
+	if info.readsAll || info.writesAll || info.kinds[2] != writeOperand ||
+		info.types[2] != registerType(m.in.A) || n.in.C != m.in.B {
+		return false
+	}
+	n.in.C = m.in.C
+	m.deleted = true
+	lr.o.changed = true
+	return true
+}
+
+// transfer updates live, that contains the live registers after in, with
+// the live registers before in.
+func (lr *liveRegs) transfer(in runtime.Instruction, info instrInfo, live regSet) {
+	if info.readsAll {
+		live.fill()
+		return
+	}
+	for j := 0; j < 3; j++ {
+		if info.kinds[j] == writeOperand {
+			if r := operandOf(in, j); r > 0 {
+				live.remove(lr.base[info.types[j]] + int(r))
+			}
+		}
+	}
+	for j := 0; j < 3; j++ {
+		if !info.isRegister(in, j) {
+			continue
+		}
+		r := operandOf(in, j)
+		if r < 0 {
+			live.add(lr.base[generalRegister] - int(r))
+		} else if info.kinds[j] != writeOperand {
+			live.add(lr.base[info.types[j]] + int(r))
+		}
+	}
+	if info.call {
+		// The called function can read its arguments.
+		for t, args := range info.args {
+			last := int(args[1])
+			if last == -1 || last > lr.count[t] {
+				last = lr.count[t]
+			}
+			for r := int(args[0]); r <= last; r++ {
+				live.add(lr.base[t] + r)
+			}
+		}
+	}
+	if !info.pure {
+		// The instruction can panic and a deferred function can recover
+		// the panic, so the values of the result parameters can be read.
+		for t, n := range lr.o.results {
+			for r := 1; r <= int(n); r++ {
+				live.add(lr.base[t] + r)
+			}
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compiler
+
+import (
+	"reflect"
+
+	"github.com/open2b/scriggo/ast"
+	"github.com/open2b/scriggo/internal/runtime"
+)
+
+// maxInlinedBodyLen is the maximum number of instructions of the body of a
+// function or macro whose calls are inlined.
+const maxInlinedBodyLen = 12
+
+// inline replaces the calls to small functions and macros with their bodies.
+//
+// A body can be inlined only if its instructions do not depend on the call
+// frame: it can only move, load and compute values, show values and text,
+// and branch. The registers of the inlined body are the registers of the
+// called function shifted by the stack shift of the call.
+func (o *optimizer) inline() {
+	var nodes []*optNode
+	for i, n := range o.nodes {
+		nodes = append(nodes, n)
+		callee, ok := o.inlinable(i)
+		if !ok {
+			continue
+		}
+		body, ok := o.inlineBody(n, callee, o.nodes[i+1])
+		if !ok {
+			continue
+		}
+		// The call node is kept, as deleted, so the jumps to the call and
+		// the scopes of the local variables refer to the inlined body.
+		n.deleted = true
+		nodes = append(nodes, body...)
+		o.changed = true
+	}
+	if len(nodes) == len(o.nodes) {
+		return
+	}
+	for i, n := range nodes {
+		n.index = i
+	}
+	o.nodes = nodes
+}
+
+// inlinable returns the function called by the node with index i, if it is
+// a call that can be inlined.
+func (o *optimizer) inlinable(i int) (*runtime.Function, bool) {
+	n := o.nodes[i]
+	if n.in.Op != runtime.OpCallFunc && n.in.Op != runtime.OpCallMacro {
+		return nil, false
+	}
+	// A call after a Go instruction is executed in a new goroutine.
+	if i+1 == len(o.nodes) || o.pinned(i) || i > 0 && o.nodes[i-1].in.Op == runtime.OpGo {
+		return nil, false
+	}
+	callee := o.fn.Functions[uint16(n.in.A)]
+	if callee == o.fn || callee.Parent != nil || callee.VarRefs != nil || callee.FinalRegs != nil {
+		return nil, false
+	}
+	if n.in.Op == runtime.OpCallMacro && ast.Format(n.in.B) != callee.Format {
+		return nil, false
+	}
+	if len(callee.Body) > maxInlinedBodyLen {
+		return nil, false
+	}
+	for _, in := range callee.Body {
+		op := in.Op
+		if op < 0 {
+			op = -op
+		}
+		switch op {
+		case runtime.OpNone, runtime.OpMove, runtime.OpLoad,
+			runtime.OpAddInt, runtime.OpSubInt, runtime.OpMulInt, runtime.OpSubInvInt,
+			runtime.OpAnd, runtime.OpAndNot, runtime.OpOr, runtime.OpXor,
+			runtime.OpAddFloat64, runtime.OpSubFloat64, runtime.OpMulFloat64, runtime.OpSubInvFloat64, runtime.OpDivFloat64,
+			runtime.OpAdd, runtime.OpSub, runtime.OpMul, runtime.OpSubInv, runtime.OpNeg, runtime.OpConcat,
+			runtime.OpTypify, runtime.OpShow, runtime.OpText, runtime.OpGoto, runtime.OpReturn:
+		case runtime.OpLen:
+			if registerType(in.A) != stringRegister {
+				return nil, false
+			}
+		case runtime.OpIfInt, runtime.OpIfFloat, runtime.OpIfString:
+			if o.info(in).readsAll {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+	return callee, true
+}
+
+// inlineBody returns the nodes of the body of callee inlined in place of the
+// call node. The Return instructions jump to the node after. It returns
+// false if the body cannot be inlined.
+func (o *optimizer) inlineBody(call *optNode, callee *runtime.Function, after *optNode) ([]*optNode, bool) {
+	co, ok := newOptimizer(callee)
+	if !ok {
+		return nil, false
+	}
+	shift := call.extra[0]
+	shifts := [4]int16{int16(shift.Op), shift.A, shift.B, shift.C}
+	var numReg [4]int16
+	for t, s := range shifts {
+		n := int(s) + int(callee.NumReg[t])
+		if n > maxRegistersCount {
+			return nil, false
+		}
+		numReg[t] = o.fn.NumReg[t]
+		if int16(n) > numReg[t] {
+			numReg[t] = int16(n)
+		}
+	}
+	// Copy the values of the caller that are changed by the remapping, so
+	// that they can be restored if the body cannot be inlined.
+	values, types, text, strings := o.fn.Values, o.fn.Types, o.fn.Text, o.strings
+	o.strings = nil
+	for _, n := range co.nodes {
+		if !o.remap(co, n, shifts, after) {
+			o.fn.Values, o.fn.Types, o.fn.Text, o.strings = values, types, text, strings
+			return nil, false
+		}
+	}
+	o.fn.NumReg = numReg
+	return co.nodes, true
+}
+
+// remap remaps the node n of the body of the function optimized by co, so
+// that it can be executed in the body of the function optimized by o.
+func (o *optimizer) remap(co *optimizer, n *optNode, shifts [4]int16, after *optNode) bool {
+	in := n.in
+	info := co.info(in)
+	for j := 0; j < 3; j++ {
+		if !info.isRegister(in, j) {
+			continue
+		}
+		r := operandOf(in, j)
+		if r < 0 {
+			return false
+		}
+		setOperand(&in, j, r+shifts[info.types[j]])
+	}
+	if k := info.k; k >= 0 && in.Op < 0 {
+		var ok bool
+		switch v := uint16(operandOf(in, k)); info.types[k] {
+		case stringRegister:
+			var i int16
+			i, ok = o.stringValue(co.fn.Values.String[v])
+			setOperand(&in, k, i)
+		case generalRegister:
+			var i int
+			i, ok = o.generalValue(co.fn.Values.General[v])
+			setOperand(&in, k, int16(i))
+		default:
+			ok = true
+		}
+		if !ok {
+			return false
+		}
+	}
+	switch in.Op {
+	case runtime.OpLoad:
+		t, i := decodeValueIndex(in.A, in.B)
+		var ok bool
+		switch t {
+		case intRegister:
+			i, ok = o.intValue(co.fn.Values.Int[i])
+		case floatRegister:
+			i, ok = o.floatValue(co.fn.Values.Float[i])
+		case stringRegister:
+			var s int16
+			s, ok = o.stringValue(co.fn.Values.String[i])
+			i = int(uint16(s))
+		case generalRegister:
+			i, ok = o.generalValue(co.fn.Values.General[i])
+		}
+		if !ok || i >= 1<<14 {
+			return false
+		}
+		in.A, in.B = encodeValueIndex(t, i)
+	case runtime.OpTypify, -runtime.OpTypify, runtime.OpShow, -runtime.OpShow:
+		i, ok := o.typeIndex(co.fn.Types[uint16(in.A)])
+		if !ok {
+			return false
+		}
+		in.A = int16(i)
+	case runtime.OpText:
+		i := len(o.fn.Text)
+		if i == 1<<16 {
+			return false
+		}
+		o.fn.Text = append(o.fn.Text, co.fn.Text[decodeUint16(in.A, in.B)])
+		in.A, in.B = encodeUint16(uint16(i))
+	case runtime.OpReturn:
+		in = runtime.Instruction{Op: runtime.OpGoto}
+		n.target = after
+	}
+	n.in = in
+	return true
+}
+
+// generalValue returns the index of v in the general values of the
+// function, adding it. It returns false if the limit of general values has
+// been reached.
+func (o *optimizer) generalValue(v reflect.Value) (int, bool) {
+	values := o.fn.Values.General
+	if len(values) == maxGeneralValuesCount {
+		return 0, false
+	}
+	o.fn.Values.General = append(values, v)
+	return len(values), true
+}
+
+// typeIndex returns the index of typ in the types of the function, adding
+// it if it is not present. It returns false if the limit of types has been
+// reached.
+func (o *optimizer) typeIndex(typ reflect.Type) (int, bool) {
+	for i, t := range o.fn.Types {
+		if t == typ {
+			return i, true
+		}
+	}
+	if len(o.fn.Types) == maxTypesCount {
+		return 0, false
+	}
+	o.fn.Types = append(o.fn.Types, typ)
+	return len(o.fn.Types) - 1, true
+}
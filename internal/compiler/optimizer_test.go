@@ -0,0 +1,164 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/open2b/scriggo/internal/fstest"
+	"github.com/open2b/scriggo/internal/runtime"
+)
+
+var optimizerProgramTests = []struct {
+	name     string
+	src      string
+	expected string
+}{
+	{"constant folding across statements", `
+		x := 3
+		y := x * 2
+		z := y + 1
+		print(z)`, `
+	Typify int 7 g1
+	Print g1`},
+
+	{"string concatenation", `
+		s := "a"
+		s = s + "b"
+		print(s)`, `
+	Typify string "ab" g1
+	Print g1`},
+
+	{"dead stores", `
+		a := 1
+		a = 2
+		b := a
+		_ = b
+		print(a)`, `
+	Typify int 2 g1
+	Print g1`},
+
+	{"constant conditions", `
+		a := 5
+		if a > 3 {
+			print(1)
+		} else {
+			print(2)
+		}`, `
+	Typify int 1 g1
+	Print g1`},
+
+	{"dead code", `
+		print(1)
+		return
+		print(2)`, `
+	Typify int 1 g1
+	Print g1`},
+
+	{"fusion of moves", `
+		a := 0
+		for i := 0; i < 10; i++ {
+			a += i
+		}
+		print(a)`, `
+	Move 0 i1
+	Move 0 i2
+1:	If i2 Less 10
+	Goto 2
+	Add i1 i2 i1
+	Add i2 1 i2
+	Goto 1
+2:	Typify int i1 g1
+	Print g1`},
+
+	{"fusion of conditions", `
+		a := 0
+		for i := 0; i < 10; i++ {
+			switch {
+			case i < 3:
+				a++
+			case i < 6:
+				a--
+			}
+		}
+		print(a)`, `
+	Move 0 i1
+	Move 0 i2
+1:	If i2 Less 10
+	Goto 5
+	If i2 GreaterEqual 3
+	Goto 2
+	If i2 GreaterEqual 6
+	Goto 3
+	Goto 4
+2:	Add i1 1 i1
+	Goto 4
+3:	Sub i1 1 i1
+4:	Add i2 1 i2
+	Goto 1
+5:	Typify int i1 g1
+	Print g1`},
+
+	{"inlining", `
+		a := add(3, 4)
+		print(a)
+	}
+	func add(a, b int) int {
+		return a + b`, `
+	Typify int 7 g1
+	Print g1`},
+}
+
+func TestOptimizerPrograms(t *testing.T) {
+	for _, test := range optimizerProgramTests {
+		t.Run(test.name, func(t *testing.T) {
+			src := "package main\n\nfunc main() {" + test.src + "\n}\n"
+			code, err := BuildProgram(fstest.Files{"main.go": src}, Options{Optimize: true})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got := disassembleOptimizedBody(code.Main)
+			if got != test.expected {
+				t.Fatalf("expected:\n%s\ngot:\n%s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestOptimizerTemplates(t *testing.T) {
+	files := fstest.Files{
+		"index.html":  `{% extends "layout.html" %}{% macro Item(s string) %}<li>{{ s }}</li>{% end %}`,
+		"layout.html": `{% for i := 0; i < 2; i++ %}{{ Item("a") }}{% end %}`,
+	}
+	code, err := BuildTemplate(files, "index.html", Options{FormatTypes: formatTypes, Optimize: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := `
+	Move 0 i1
+1:	If i1 Less 2
+	Return
+	Move "a" s2
+	Text "<li>"
+	Show string s2 (HTML)
+	Text "</li>"
+	Add i1 1 i1
+	Goto 1`
+	got := disassembleOptimizedBody(code.Main)
+	if got != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+// disassembleOptimizedBody returns the disassembled body of fn, without a
+// final Return instruction.
+func disassembleOptimizedBody(fn *runtime.Function) string {
+	asm := string(DisassembleFunction(fn, nil, -1))
+	// Skip the function name and the registers.
+	asm = strings.SplitN(asm, "\n", 3)[2]
+	asm = strings.TrimSuffix(asm, "\n")
+	return "\n" + strings.TrimSuffix(asm, "\n\tReturn")
+}
@@ -134,6 +134,13 @@ type parsing struct {
 
 	// Unexpanded Extends, Import and Render nodes.
 	unexpanded []ast.Node
+
+	// Tokens given back with the unreadTokens method, in reverse order.
+	unread []token
+
+	// Tokens read while recording, see the recordTokens method.
+	recording int
+	recorded  []token
 }
 
 // addToAncestors adds node to the ancestors.
@@ -164,16 +171,59 @@ func (p *parsing) inFunction() bool {
 // next returns the next token from the lexer. Panics if the lexer channel is
 // closed.
 func (p *parsing) next() token {
-	tok, ok := <-p.lex.Tokens()
-	if !ok {
-		if p.lex.err == nil {
-			panic("next called after EOF")
+	var tok token
+	if n := len(p.unread); n > 0 {
+		tok = p.unread[n-1]
+		p.unread = p.unread[:n-1]
+	} else {
+		var ok bool
+		tok, ok = <-p.lex.Tokens()
+		if !ok {
+			if p.lex.err == nil {
+				panic("next called after EOF")
+			}
+			panic(p.lex.err)
 		}
-		panic(p.lex.err)
+	}
+	if p.recording > 0 {
+		p.recorded = append(p.recorded, tok)
 	}
 	return tok
 }
 
+// unreadTokens gives back the tokens toks so that the next calls to the next
+// method return them, in the same order, before reading from the lexer.
+func (p *parsing) unreadTokens(toks ...token) {
+	for i := len(toks) - 1; i >= 0; i-- {
+		p.unread = append(p.unread, toks[i])
+	}
+}
+
+// recordTokens starts recording the tokens returned by the next method and
+// returns a function that stops the recording and returns the recorded
+// tokens. If rewind is true, the returned function also gives back the
+// recorded tokens so that they can be parsed again.
+//
+// Recordings can be nested.
+func (p *parsing) recordTokens() func(rewind bool) []token {
+	start := len(p.recorded)
+	p.recording++
+	return func(rewind bool) []token {
+		p.recording--
+		toks := make([]token, len(p.recorded)-start)
+		copy(toks, p.recorded[start:])
+		if rewind {
+			// The tokens will be recorded again, if an outer recording is in
+			// progress, when they are read again.
+			p.recorded = p.recorded[:start]
+			p.unreadTokens(toks...)
+		} else if p.recording == 0 {
+			p.recorded = p.recorded[:0]
+		}
+		return toks
+	}
+}
+
 // parseSource parses a program or a script and returns its tree.
 // script reports whether it is a script.
 func parseSource(src []byte, script bool) (tree *ast.Tree, err error) {
@@ -230,6 +280,15 @@ func parseSource(src []byte, script bool) (tree *ast.Tree, err error) {
 	return tree, nil
 }
 
+// CodeComment returns the position of the first comment, as // or /* */, in
+// the code of the template src in the given format, or nil if the code has
+// no comments. These comments are discarded by the parser.
+func CodeComment(src []byte, format ast.Format, dollarIdentifier bool) *ast.Position {
+	lex := scanTemplate(src, format, false, false, dollarIdentifier)
+	lex.Stop()
+	return lex.comment
+}
+
 // ParseTemplateSource parses a template with content src in the given format
 // and returns its tree and the unexpanded Extends, Import, Render and
 // Assignment nodes.
@@ -237,11 +296,11 @@ func parseSource(src []byte, script bool) (tree *ast.Tree, err error) {
 // If parseShebang is true, the shebang line is parsed.
 // If noParseShow is true, short show statements are not parsed.
 //
-// format can be Text, HTML, CSS, JS, JSON and Markdown. imported indicates
-// whether it is imported.
+// format can be Text, HTML, CSS, JS, JSON, Markdown, XML, YAML and TOML.
+// imported indicates whether it is imported.
 func ParseTemplateSource(src []byte, format ast.Format, parseShebang, imported, noParseShow, dollarIdentifier bool) (tree *ast.Tree, unexpanded []ast.Node, err error) {
 
-	if format < ast.FormatText || format > ast.FormatMarkdown {
+	if format < ast.FormatText || format > ast.FormatTOML {
 		return nil, nil, errors.New("scriggo: invalid format")
 	}
 
@@ -296,6 +355,7 @@ func ParseTemplateSource(src []byte, format ast.Format, parseShebang, imported,
 				return nil, nil, syntaxError(pos, "unexpected text in file with extends")
 			}
 			text = ast.NewText(tok.pos, tok.txt, ast.Cut{})
+			text.NonceOffsets = tok.non
 		}
 
 		if line < tok.lin || tok.pos.End == lastIndex {
@@ -739,6 +799,7 @@ LABEL:
 			panic(syntaxError(tok.pos, "case is not in a switch or select"))
 		}
 		p.addNode(node)
+		p.cutSpacesToken = true
 		tok = p.parseEnd(tok, tokenColon, end)
 		return tok
 
@@ -1108,7 +1169,7 @@ LABEL:
 		if end == tokenEndStatements {
 			panic(syntaxError(tok.pos, "unexpected macro in statement scope"))
 		}
-		if tok.ctx > ast.ContextMarkdown {
+		if tok.ctx > ast.ContextTOML {
 			panic(syntaxError(tok.pos, "macro declaration not allowed in %s", tok.ctx))
 		}
 		if tok.ctx != ast.Context(p.format) {
@@ -1118,7 +1179,7 @@ LABEL:
 		node, tok = p.parseFunc(tok, parseFuncDecl)
 		if tok.typ != tokenEndStatement {
 			if node.(*ast.Func).Type.Result == nil {
-				panic(syntaxError(tok.pos, "unexpected %s, expecting string, html, css, js, json, markdown or %%}", tok))
+				panic(syntaxError(tok.pos, "unexpected %s, expecting string, html, css, js, json, markdown, xml, yaml, toml or %%}", tok))
 			}
 			panic(syntaxError(tok.pos, "unexpected %s, expecting %%}", tok))
 		}
@@ -1231,6 +1292,7 @@ LABEL:
 	case tokenDefer, tokenGo:
 		pos := tok.pos
 		keyword := tok.typ
+		ctx := tok.ctx
 		tok = p.next()
 		var expr ast.Expression
 		expr, tok = p.parseExpr(tok, false, false, false, false)
@@ -1242,7 +1304,10 @@ LABEL:
 		if keyword == tokenDefer {
 			node = ast.NewDefer(pos, expr)
 		} else {
-			node = ast.NewGo(pos, expr)
+			node = ast.NewGo(pos, expr, ctx)
+			if end == tokenEndStatement && tok.typ == tokenSemicolon {
+				node, tok = p.parseUsing(node, tok)
+			}
 		}
 		p.addNode(node)
 		tok = p.parseEnd(tok, tokenSemicolon, end)
@@ -1270,7 +1335,7 @@ LABEL:
 		if end == tokenEndStatements {
 			panic(syntaxError(tok.pos, "cannot use raw between {%%%% and %%%%}"))
 		}
-		if tok.ctx > ast.ContextMarkdown {
+		if tok.ctx > ast.ContextTOML {
 			panic(syntaxError(tok.pos, "cannot use raw in %s", tok.ctx))
 		}
 		pos := tok.pos
@@ -1435,7 +1500,7 @@ func (p *parsing) parseUsing(stmt ast.Node, tok token) (ast.Node, token) {
 		}
 		panic(syntaxError(tok.pos, "unexpected %s, expecting using", tok))
 	}
-	if tok.ctx > ast.ContextMarkdown {
+	if tok.ctx > ast.ContextTOML {
 		panic(syntaxError(tok.pos, "using not allowed in %s", tok.ctx))
 	}
 	block := ast.NewBlock(nil, []ast.Node{})
@@ -1457,7 +1522,7 @@ func (p *parsing) parseUsing(stmt ast.Node, tok token) (ast.Node, token) {
 			}
 		}
 		if using.Type == nil {
-			panic(syntaxError(tok.pos, "unexpected %s, expecting string, html, css, js, json, markdown, macro or %%}", ident.Name))
+			panic(syntaxError(tok.pos, "unexpected %s, expecting string, html, css, js, json, markdown, xml, yaml, toml, macro or %%}", ident.Name))
 		}
 		tok = p.next()
 	case tokenSemicolon, tokenEndStatement:
@@ -1550,8 +1615,30 @@ func (p *parsing) parseTypeDecl(tok token) (*ast.TypeDeclaration, token) {
 	}
 	ident := ast.NewIdentifier(tok.pos, string(tok.txt))
 	tok = p.next()
+	var typeParams []*ast.Parameter
+	if tok.typ == tokenLeftBracket {
+		// Distinguish a generic type, as in 'type A[T any] ...', from an
+		// array type, as in 'type A [N]int'.
+		first := p.next()
+		if first.typ == tokenIdentifier {
+			second := p.next()
+			switch second.typ {
+			case tokenIdentifier, tokenComma, tokenInterface, tokenTilde, tokenMap, tokenChan,
+				tokenFunc, tokenStruct, tokenLeftBracket:
+				p.unreadTokens(first, second)
+				typeParams, tok = p.parseTypeParams(tok)
+			default:
+				p.unreadTokens(first, second)
+			}
+		} else {
+			p.unreadTokens(first)
+		}
+	}
 	alias := tok.typ == tokenSimpleAssignment
 	if alias {
+		if typeParams != nil {
+			panic(syntaxError(tok.pos, "generic type cannot be alias"))
+		}
 		tok = p.next()
 	}
 	var typ ast.Expression
@@ -1560,9 +1647,47 @@ func (p *parsing) parseTypeDecl(tok token) (*ast.TypeDeclaration, token) {
 		panic(syntaxError(tok.pos, "unexpected %s in type declaration", tok))
 	}
 	node := ast.NewTypeDeclaration(pos, ident, typ, alias)
+	node.TypeParams = typeParams
 	return node, tok
 }
 
+// parseTypeParams parses a type parameter list, as in '[K comparable, V any]',
+// and returns the type parameters and the next token. tok must be the token
+// '['. As for function parameters, the type of a parameter is nil if the
+// parameter has the same constraint as the following parameter.
+func (p *parsing) parseTypeParams(tok token) ([]*ast.Parameter, token) {
+	var params []*ast.Parameter
+	tok = p.next()
+	if tok.typ == tokenRightBracket {
+		panic(syntaxError(tok.pos, "empty type parameter list"))
+	}
+	for {
+		if tok.typ != tokenIdentifier {
+			panic(syntaxError(tok.pos, "unexpected %s, expecting name", tok))
+		}
+		param := ast.NewParameter(p.parseIdentifierNode(tok), nil)
+		params = append(params, param)
+		tok = p.next()
+		if tok.typ == tokenComma {
+			tok = p.next()
+			continue
+		}
+		param.Type, tok = p.parseConstraintTerms(tok, nil)
+		if tok.typ == tokenComma {
+			tok = p.next()
+			if tok.typ == tokenRightBracket {
+				break
+			}
+			continue
+		}
+		if tok.typ != tokenRightBracket {
+			panic(syntaxError(tok.pos, "unexpected %s, expecting comma or ]", tok))
+		}
+		break
+	}
+	return params, p.next()
+}
+
 // group is nil if parseVarOrConst is called when not in a declaration group.
 func (p *parsing) parseVarOrConst(tok token, pos *ast.Position, decType tokenTyp, iotaValue int) (ast.Node, token) {
 	if tok.typ != tokenIdentifier {
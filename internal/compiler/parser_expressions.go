@@ -91,21 +91,21 @@ func (p *parsing) parseExpr(tok token, canBeSwitchGuard, canElideType, mustBeTyp
 			}
 			operand = ast.NewStructType(pos.WithEnd(tok.pos.End), fields)
 			tok = p.next()
-		case tokenInterface: // interface{}
+		case tokenInterface: // interface{ ... }
 			pos := tok.pos
 			tok = p.next()
 			if tok.typ != tokenLeftBrace {
 				panic(syntaxError(tok.pos, "unexpected %s, expecting {", tok))
 			}
 			tok = p.next()
-			if tok.typ != tokenRightBrace {
-				if tok.typ == tokenIdentifier {
-					panic(syntaxError(tok.pos, "non-empty interfaces are not supported in this release of Scriggo"))
-				}
-				panic(syntaxError(tok.pos, "unexpected %s, expecting }", tok))
+			var methods []*ast.Field
+			for tok.typ != tokenRightBrace {
+				var method *ast.Field
+				method, tok = p.parseInterfaceMethod(tok)
+				methods = append(methods, method)
 			}
 			pos.End = tok.pos.End
-			operand = ast.NewInterface(pos)
+			operand = ast.NewInterface(pos, methods)
 			tok = p.next()
 		case tokenFunc: // func
 			var node ast.Node
@@ -156,7 +156,8 @@ func (p *parsing) parseExpr(tok token, canBeSwitchGuard, canElideType, mustBeTyp
 			tokenExtendedNot,    // not e
 			tokenXor,            // ^e
 			tokenMultiplication, // *t, *T
-			tokenAmpersand:      // &e
+			tokenAmpersand,      // &e
+			tokenTilde:          // ~T
 			operator = ast.NewUnaryOperator(tok.pos, operatorFromTokenType(tok.typ, false), nil)
 			if mustBeType && tok.typ != tokenMultiplication {
 				panic(syntaxError(tok.pos, "unexpected %s, expecting type", tok.txt))
@@ -206,6 +207,9 @@ func (p *parsing) parseExpr(tok token, canBeSwitchGuard, canElideType, mustBeTyp
 					operand = ast.NewSelector(tok.pos, operand, ident.Name)
 					tok = p.next()
 				}
+				if tok.typ == tokenLeftBracket {
+					operand, tok = p.parseTypeArgs(operand, tok)
+				}
 			}
 		case tokenLeftBracket: // [
 			canCompositeLiteral = true
@@ -349,7 +353,30 @@ func (p *parsing) parseExpr(tok token, canBeSwitchGuard, canElideType, mustBeTyp
 				pos.Start = operand.Pos().Start
 				var index ast.Expression
 				index, tok = p.parseExpr(p.next(), false, false, false, false)
-				if tok.typ == tokenColon {
+				if tok.typ == tokenComma && index != nil {
+					// e[T1, T2, ...]
+					typeArgs := []ast.Expression{index}
+					for tok.typ == tokenComma {
+						var arg ast.Expression
+						arg, tok = p.parseExpr(p.next(), false, false, false, false)
+						if arg == nil {
+							if tok.typ == tokenRightBracket {
+								break
+							}
+							panic(syntaxError(tok.pos, "unexpected %s, expecting type", tok))
+						}
+						typeArgs = append(typeArgs, arg)
+					}
+					if tok.typ != tokenRightBracket {
+						panic(syntaxError(tok.pos, "unexpected %s, expecting comma or ]", tok))
+					}
+					pos.End = tok.pos.End
+					if len(typeArgs) == 1 {
+						operand = ast.NewIndex(pos, operand, index)
+					} else {
+						operand = ast.NewInstantiation(pos, operand, typeArgs)
+					}
+				} else if tok.typ == tokenColon {
 					low := index
 					isFull := false
 					var high, max ast.Expression
@@ -594,6 +621,47 @@ func addLastOperand(op ast.Expression, path []ast.Operator) ast.Expression {
 	return path[0]
 }
 
+// parseTypeArgs parses the type arguments of the generic type expr, as in
+// 'T[int]' or 'T[K, V]', and returns the instantiated type and the next
+// token. tok must be the token '['. If what follows expr is instead an array
+// or slice type, as in a parameter declaration 'a [N]int', it returns expr
+// and tok, and the tokens after tok can be read again.
+func (p *parsing) parseTypeArgs(expr ast.Expression, tok token) (ast.Expression, token) {
+	bracket := tok
+	stop := p.recordTokens()
+	tok = p.next()
+	if tok.typ == tokenRightBracket || tok.typ == tokenEllipsis {
+		stop(true)
+		return expr, bracket
+	}
+	var typeArgs []ast.Expression
+	typeArgs, tok = p.parseExprList(tok, false, false, false)
+	if tok.typ == tokenComma && typeArgs != nil {
+		tok = p.next()
+	}
+	if typeArgs == nil || tok.typ != tokenRightBracket {
+		stop(false)
+		panic(syntaxError(tok.pos, "unexpected %s, expecting ]", tok))
+	}
+	end := tok.pos.End
+	tok = p.next()
+	if len(typeArgs) == 1 {
+		switch tok.typ {
+		case tokenIdentifier, tokenLeftBracket, tokenMultiplication, tokenLeftParenthesis, tokenFunc,
+			tokenMap, tokenChan, tokenStruct, tokenInterface, tokenArrow:
+			// 'expr' is followed by an array type.
+			stop(true)
+			return expr, bracket
+		}
+	}
+	stop(false)
+	pos := expr.Pos().WithEnd(end)
+	if len(typeArgs) == 1 {
+		return ast.NewIndex(pos, expr, typeArgs[0]), tok
+	}
+	return ast.NewInstantiation(pos, expr, typeArgs), tok
+}
+
 // parseExprList parses a list of expressions separated by a comma and returns
 // the list and the last token read that does not belong to the expressions.
 //
@@ -708,6 +776,16 @@ func (p *parsing) parseField(tok token) (*ast.Field, token) {
 			}
 		case tokenRawString, tokenInterpretedString:
 			field.Type = ident
+		case tokenLeftBracket:
+			// T[...] or name [...]T
+			var typ ast.Expression
+			typ, tok = p.parseTypeArgs(ident, tok)
+			if typ != ident {
+				field.Type = typ
+				break
+			}
+			field.Idents = []*ast.Identifier{ident}
+			field.Type, tok = p.parseExpr(tok, false, false, true, false)
 		default:
 			field.Type, tok = p.parseExpr(tok, false, false, true, false)
 			if field.Type == nil {
@@ -736,6 +814,113 @@ func (p *parsing) parseField(tok token) (*ast.Field, token) {
 	return field, tok
 }
 
+// parseInterfaceMethod parses a method or an embedded interface of an
+// interface type and returns the parsed element, as a field, and the next
+// token. The next token is the first token of the next element or a
+// tokenRightBrace token. tok is the first token of the element.
+func (p *parsing) parseInterfaceMethod(tok token) (*ast.Field, token) {
+	switch tok.typ {
+	case tokenIdentifier:
+	case tokenTilde, tokenLeftBracket, tokenMultiplication, tokenMap, tokenChan, tokenFunc, tokenStruct,
+		tokenInterface:
+		// ~T, []T, ... | ...
+		field := ast.NewField(nil, nil, "")
+		field.Type, tok = p.parseConstraintTerms(tok, nil)
+		return field, p.endOfInterfaceElement(tok)
+	case tokenLeftParenthesis:
+		panic(syntaxError(tok.pos, "cannot parenthesize embedded type"))
+	default:
+		panic(syntaxError(tok.pos, "unexpected %s, expecting method or interface name", tok))
+	}
+	pos := tok.pos
+	field := ast.NewField(nil, nil, "")
+	ident := ast.NewIdentifier(tok.pos, string(tok.txt))
+	tok = p.next()
+	switch tok.typ {
+	case tokenLeftParenthesis:
+		// M(...) ...
+		var parameters, result []*ast.Parameter
+		var isVariadic bool
+		var last *ast.Position
+		parameters, isVariadic, last, tok = p.parseFuncParameters(tok, false, false)
+		pos = pos.WithEnd(last.End)
+		result, _, last, tok = p.parseFuncParameters(tok, false, true)
+		if result != nil {
+			pos = pos.WithEnd(last.End)
+		}
+		field.Idents = []*ast.Identifier{ident}
+		field.Type = ast.NewFuncType(pos, false, parameters, result, isVariadic)
+	case tokenPeriod:
+		// p.I
+		tok = p.next()
+		if tok.typ != tokenIdentifier {
+			panic(syntaxError(tok.pos, "unexpected %s, expecting name", tok))
+		}
+		field.Type = ast.NewSelector(pos.WithEnd(tok.pos.End), ident, string(tok.txt))
+		tok = p.next()
+	default:
+		// I
+		field.Type = ident
+	}
+	if field.Idents == nil {
+		// I[T], I | T
+		if tok.typ == tokenLeftBracket {
+			field.Type, tok = p.parseTypeArgs(field.Type, tok)
+		}
+		if tok.typ == tokenVerticalBar {
+			field.Type, tok = p.parseConstraintTerms(tok, field.Type)
+		}
+	}
+	return field, p.endOfInterfaceElement(tok)
+}
+
+// endOfInterfaceElement checks that tok ends an element of an interface type
+// and returns the next token.
+func (p *parsing) endOfInterfaceElement(tok token) token {
+	switch tok.typ {
+	case tokenSemicolon:
+		tok = p.next()
+	case tokenRightBrace:
+	default:
+		panic(syntaxError(tok.pos, "unexpected %s, expecting semicolon or newline or }", tok))
+	}
+	return tok
+}
+
+// parseConstraintTerms parses a union of terms of a constraint, as in
+// '~int | ~string', and returns the union and the next token. If first is not
+// nil, it is the first term and tok must be the token '|'.
+func (p *parsing) parseConstraintTerms(tok token, first ast.Expression) (ast.Expression, token) {
+	union := first
+	for {
+		if union != nil {
+			if tok.typ != tokenVerticalBar {
+				return union, tok
+			}
+			tok = p.next()
+		}
+		pos := tok.pos
+		tilde := tok.typ == tokenTilde
+		if tilde {
+			tok = p.next()
+		}
+		var term ast.Expression
+		term, tok = p.parseExpr(tok, false, false, true, false)
+		if term == nil {
+			panic(syntaxError(tok.pos, "unexpected %s, expecting type", tok))
+		}
+		if tilde {
+			term = ast.NewUnaryOperator(pos.WithEnd(term.Pos().End), ast.OperatorTilde, term)
+		}
+		if union == nil {
+			union = term
+		} else {
+			pos := union.Pos().WithEnd(term.Pos().End)
+			union = ast.NewBinaryOperator(pos, ast.OperatorBitOr, union, term)
+		}
+	}
+}
+
 // literalType returns a literal type from a token type.
 func literalType(typ tokenTyp) ast.LiteralType {
 	switch typ {
@@ -814,6 +999,8 @@ func operatorFromTokenType(typ tokenTyp, binary bool) ast.OperatorType {
 		return ast.OperatorLeftShift
 	case tokenRightShift:
 		return ast.OperatorRightShift
+	case tokenTilde:
+		return ast.OperatorTilde
 	default:
 		panic("invalid token type")
 	}
@@ -277,7 +277,7 @@ var exprTests = []struct {
 			p(1, 20, 0, 35),
 			ast.NewMapType(p(1, 1, 0, 18),
 				ast.NewIdentifier(p(1, 5, 4, 6), "int"),
-				ast.NewInterface(p(1, 9, 8, 18))),
+				ast.NewInterface(p(1, 9, 8, 18), nil)),
 			[]ast.KeyValue{
 				{
 					ast.NewIdentifier(p(1, 21, 20, 20), "a"),
@@ -545,14 +545,14 @@ var exprTests = []struct {
 		),
 	},
 	{`interface{}`,
-		ast.NewInterface(p(1, 1, 0, 10)),
+		ast.NewInterface(p(1, 1, 0, 10), nil),
 	},
 	{`[]interface{}{1,2,3}`,
 		ast.NewCompositeLiteral(
 			p(1, 14, 0, 19),
 			ast.NewSliceType(
 				p(1, 1, 0, 12),
-				ast.NewInterface(p(1, 3, 2, 12)),
+				ast.NewInterface(p(1, 3, 2, 12), nil),
 			),
 			[]ast.KeyValue{
 				{nil, ast.NewBasicLiteral(p(1, 15, 14, 14), ast.IntLiteral, "1")},
@@ -569,7 +569,7 @@ var exprTests = []struct {
 				ast.NewBasicLiteral(p(1, 1, 0, 0), ast.IntLiteral, "1"),
 				ast.NewCall(
 					p(1, 16, 4, 17),
-					ast.NewInterface(p(1, 5, 4, 14)),
+					ast.NewInterface(p(1, 5, 4, 14), nil),
 					[]ast.Expression{ast.NewBasicLiteral(p(1, 17, 16, 16), ast.IntLiteral, "2")}, false,
 				)),
 			ast.NewBasicLiteral(p(1, 22, 21, 21), ast.IntLiteral, "4"),
@@ -22,9 +22,29 @@ const (
 func (p *parsing) parseFunc(tok token, kind funcKindToParse) (ast.Node, token) {
 	isMacro := tok.typ == tokenMacro
 	pos := tok.pos
+	tok = p.next()
+	// Parses the method receiver if present.
+	var recv *ast.Parameter
+	if kind == parseFuncDecl && !isMacro && tok.typ == tokenLeftParenthesis {
+		if _, ok := p.parent().(*ast.Package); !ok {
+			panic(syntaxError(tok.pos, "method declarations are only allowed in packages"))
+		}
+		var params []*ast.Parameter
+		var isVariadic bool
+		recvPos := tok.pos
+		params, isVariadic, _, tok = p.parseFuncParameters(tok, false, false)
+		switch {
+		case len(params) == 0:
+			panic(syntaxError(recvPos, "method has no receiver"))
+		case len(params) > 1:
+			panic(syntaxError(recvPos, "method has multiple receivers"))
+		case isVariadic:
+			panic(syntaxError(params[0].Type.Pos(), "cannot use ... in receiver or result parameter list"))
+		}
+		recv = params[0]
+	}
 	// Parses the function name if present.
 	var ident *ast.Identifier
-	tok = p.next()
 	if tok.typ == tokenIdentifier {
 		if kind&parseFuncDecl == 0 {
 			panic(syntaxError(tok.pos, "unexpected %s, expecting (", tok))
@@ -32,14 +52,20 @@ func (p *parsing) parseFunc(tok token, kind funcKindToParse) (ast.Node, token) {
 		ident = ast.NewIdentifier(tok.pos, string(tok.txt))
 		tok = p.next()
 	} else if kind == parseFuncDecl {
-		// This check could be avoided (the code panics anyway) but improves the
-		// readability of the error message.
-		if !isMacro && tok.typ == tokenLeftParenthesis {
-			panic(syntaxError(tok.pos, "method declarations are not supported in this release of Scriggo"))
-		}
 		// Node to parse must be a function declaration.
 		panic(syntaxError(tok.pos, "unexpected %s, expecting name", tok.txt))
 	}
+	// Parses the type parameters if present.
+	var typeParams []*ast.Parameter
+	if ident != nil && tok.typ == tokenLeftBracket {
+		if recv != nil {
+			panic(syntaxError(tok.pos, "method must have no type parameters"))
+		}
+		if isMacro {
+			panic(syntaxError(tok.pos, "macro must have no type parameters"))
+		}
+		typeParams, tok = p.parseTypeParams(tok)
+	}
 	// Parses the input parameters.
 	var parameters []*ast.Parameter
 	var isVariadic bool
@@ -56,7 +82,7 @@ func (p *parsing) parseFunc(tok token, kind funcKindToParse) (ast.Node, token) {
 	if result != nil {
 		pos.End = last.End
 	} else if isMacro && kind == parseFuncType {
-		panic(syntaxError(tok.pos, "unexpected %s, expecting string, html, css, js, json or markdown", tok))
+		panic(syntaxError(tok.pos, "unexpected %s, expecting string, html, css, js, json, markdown, xml, yaml or toml", tok))
 	}
 
 	// Make the nodes.
@@ -66,6 +92,8 @@ func (p *parsing) parseFunc(tok token, kind funcKindToParse) (ast.Node, token) {
 		return typ, tok
 	}
 	node := ast.NewFunc(pos, ident, typ, nil, false, ast.Format(tok.ctx))
+	node.Recv = recv
+	node.TypeParams = typeParams
 	if !isMacro && tok.typ != tokenLeftBrace {
 		return node, tok
 	}
@@ -116,7 +144,7 @@ func (p *parsing) parseFuncParameters(tok token, isMacro, isResult bool) ([]*ast
 	if isResult {
 		if isMacro {
 			switch name := string(tok.txt); name {
-			case "string", "html", "css", "js", "json", "markdown":
+			case "string", "html", "css", "js", "json", "markdown", "xml", "yaml", "toml":
 				return []*ast.Parameter{{nil, ast.NewIdentifier(tok.pos, name)}}, false, tok.pos, p.next()
 			}
 			return nil, false, nil, tok
@@ -7,18 +7,18 @@ package compiler
 import (
 	"errors"
 	"fmt"
+	"go/build"
 	"io"
 	"io/fs"
+	"path"
+	"runtime"
 	"strings"
 
 	"github.com/open2b/scriggo/ast"
 	"github.com/open2b/scriggo/native"
 )
 
-var (
-	ErrNoGoFiles      = errors.New("no Go files")
-	ErrTooManyGoFiles = errors.New("too many Go files")
-)
+var ErrNoGoFiles = errors.New("no Go files")
 
 // ParseProgram parses a program.
 func ParseProgram(fsys fs.FS) (*ast.Tree, error) {
@@ -67,12 +67,14 @@ func ParseProgram(fsys fs.FS) (*ast.Tree, error) {
 			return main.Tree, nil
 		}
 
-		// Parse the import declarations within the module.
+		// Parse the import declarations within the module. As the
+		// declarations of the files of the package are merged, the import
+		// declarations are not only at the beginning.
 		declarations := n.Tree.Nodes[0].(*ast.Package).Declarations
 		for _, decl := range declarations {
 			imp, ok := decl.(*ast.Import)
 			if !ok {
-				break
+				continue
 			}
 			if tree, ok := trees[imp.Path]; ok {
 				// Check if there is a cycle.
@@ -119,7 +121,10 @@ func ParseProgram(fsys fs.FS) (*ast.Tree, error) {
 	return main.Tree, nil
 }
 
-// parsePackage parses a package at the given directory in fsys.
+// parsePackage parses a package at the given directory in fsys. It parses
+// all the Go files in the directory, except the test files and the files
+// excluded by the build constraints, and merges their declarations into a
+// single package node. If dir does not exist, it returns nil and nil.
 func parsePackage(fsys fs.FS, dir string) (*ast.Tree, error) {
 	files, err := fs.ReadDir(fsys, dir)
 	if err != nil {
@@ -128,37 +133,71 @@ func parsePackage(fsys fs.FS, dir string) (*ast.Tree, error) {
 		}
 		return nil, err
 	}
-	var name string
+	ctx := buildContext(fsys)
+	var tree *ast.Tree
+	var pkg *ast.Package
 	for _, file := range files {
-		if file.Type().IsRegular() && strings.HasSuffix(file.Name(), ".go") {
-			if name != "" {
-				return nil, ErrTooManyGoFiles
-			}
-			if dir != "." {
-				name = dir + "/"
+		name := file.Name()
+		if !file.Type().IsRegular() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		match, err := ctx.MatchFile(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+		if dir != "." {
+			name = dir + "/" + name
+		}
+		src, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		t, err := parseSource(src, false)
+		if err != nil {
+			if e, ok := err.(*SyntaxError); ok {
+				e.path = name
 			}
-			name += file.Name()
+			return nil, err
+		}
+		p := t.Nodes[0].(*ast.Package)
+		if pkg == nil {
+			tree = t
+			pkg = p
+			pkg.Files = map[ast.Node]string{}
+		} else if p.Name != pkg.Name {
+			return nil, &CheckingError{path: name, pos: *p.Pos(), err: fmt.Errorf("package %s; expected %s", p.Name, pkg.Name)}
+		} else {
+			pkg.Declarations = append(pkg.Declarations, p.Declarations...)
+		}
+		for _, decl := range p.Declarations {
+			pkg.Files[decl] = name
 		}
 	}
-	if name == "" {
+	if tree == nil {
 		return nil, ErrNoGoFiles
 	}
-	fi, err := fsys.Open(name)
-	if err != nil {
-		return nil, err
-	}
-	src, err := io.ReadAll(fi)
-	_ = fi.Close()
-	if err != nil {
-		return nil, err
-	}
-	tree, err := parseSource(src, false)
-	if err != nil {
-		return nil, err
-	}
 	return tree, nil
 }
 
+// buildContext returns the build context used to evaluate the build
+// constraints of the files in fsys. The constraints are evaluated for the
+// operating system and architecture of the running program, with cgo
+// disabled.
+func buildContext(fsys fs.FS) *build.Context {
+	ctx := build.Default
+	ctx.GOOS = runtime.GOOS
+	ctx.GOARCH = runtime.GOARCH
+	ctx.CgoEnabled = false
+	ctx.JoinPath = path.Join
+	ctx.OpenFile = func(name string) (io.ReadCloser, error) {
+		return fsys.Open(name)
+	}
+	return &ctx
+}
+
 // ParseScript parses a script reading its source from src and the imported
 // packages form the importer.
 func ParseScript(src io.Reader, importer native.Importer) (*ast.Tree, error) {
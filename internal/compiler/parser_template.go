@@ -37,7 +37,7 @@ func ParseTemplate(fsys fs.FS, name string, noParseShow, dollarIdentifier bool)
 		return nil, os.ErrInvalid
 	}
 
-	src, format, err := readFileAndFormat(fsys, name)
+	src, format, err := ReadTemplateFile(fsys, name)
 	if err != nil {
 		return nil, err
 	}
@@ -161,7 +161,7 @@ func (pp *templateExpansion) parseNodeFile(node ast.Node) (*ast.Tree, error) {
 
 	if tree == nil {
 		// Parse the file.
-		src, format, err := readFileAndFormat(pp.fsys, name)
+		src, format, err := ReadTemplateFile(pp.fsys, name)
 		if err != nil {
 			return nil, err
 		}
@@ -301,11 +301,11 @@ func (pp *templateExpansion) expand(nodes []ast.Node) error {
 	return nil
 }
 
-// readFileAndFormat reads the file with the given path name from fsys and
+// ReadTemplateFile reads the file with the given path name from fsys and
 // returns its content and format. If fsys implements FormatFS, it calls
 // its Format method, otherwise it determines the format from the file name
 // extension.
-func readFileAndFormat(fsys fs.FS, name string) ([]byte, ast.Format, error) {
+func ReadTemplateFile(fsys fs.FS, name string) ([]byte, ast.Format, error) {
 	src, err := fs.ReadFile(fsys, name)
 	if err != nil {
 		return nil, 0, err
@@ -316,7 +316,7 @@ func readFileAndFormat(fsys fs.FS, name string) ([]byte, ast.Format, error) {
 		if err != nil {
 			return nil, 0, err
 		}
-		if format < ast.FormatText || format > ast.FormatMarkdown {
+		if format < ast.FormatText || format > ast.FormatTOML {
 			return nil, 0, fmt.Errorf("unknown format %d", format)
 		}
 	} else {
@@ -331,6 +331,12 @@ func readFileAndFormat(fsys fs.FS, name string) ([]byte, ast.Format, error) {
 			format = ast.FormatJSON
 		case ".md", ".mkd", ".mkdn", ".mdown", ".markdown":
 			format = ast.FormatMarkdown
+		case ".xml", ".svg", ".rss", ".atom":
+			format = ast.FormatXML
+		case ".yaml", ".yml":
+			format = ast.FormatYAML
+		case ".toml":
+			format = ast.FormatTOML
 		}
 	}
 	return src, format, nil
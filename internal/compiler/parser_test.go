@@ -318,7 +318,7 @@ var goContextTreeTests = []struct {
 				ast.NewMapType(
 					p(1, 14, 13, 34),
 					ast.NewIdentifier(p(1, 18, 17, 22), "string"),
-					ast.NewInterface(p(1, 25, 24, 34)),
+					ast.NewInterface(p(1, 25, 24, 34), nil),
 				),
 				true,
 			),
@@ -529,7 +529,7 @@ var goContextTreeTests = []struct {
 				p(1, 1, 0, 5),
 				ast.NewCall(
 					p(1, 5, 3, 5),
-					ast.NewIdentifier(p(1, 4, 3, 3), "f"), nil, false)),
+					ast.NewIdentifier(p(1, 4, 3, 3), "f"), nil, false), ast.ContextText),
 		}, ast.FormatText)},
 	{"ch <- 5",
 		ast.NewTree("", []ast.Node{
@@ -684,6 +684,32 @@ var goContextTreeTests = []struct {
 	{"import boo \"foo\"", ast.NewTree("", []ast.Node{
 		ast.NewImport(p(1, 8, 7, 15),
 			ast.NewIdentifier(p(1, 8, 7, 9), "boo"), "foo", nil)}, ast.FormatText)},
+	{"type List[T any] []T", ast.NewTree("", []ast.Node{
+		func() ast.Node {
+			td := ast.NewTypeDeclaration(p(1, 1, 0, 19), ast.NewIdentifier(p(1, 6, 5, 8), "List"),
+				ast.NewSliceType(p(1, 18, 17, 19), ast.NewIdentifier(p(1, 20, 19, 19), "T")), false)
+			td.TypeParams = []*ast.Parameter{
+				ast.NewParameter(ast.NewIdentifier(p(1, 11, 10, 10), "T"), ast.NewIdentifier(p(1, 13, 12, 14), "any")),
+			}
+			return td
+		}()}, ast.FormatText)},
+	{"x := F[int, string](a)", ast.NewTree("", []ast.Node{
+		ast.NewAssignment(p(1, 1, 0, 21), []ast.Expression{ast.NewIdentifier(p(1, 1, 0, 0), "x")},
+			ast.AssignmentDeclaration, []ast.Expression{
+				ast.NewCall(p(1, 20, 5, 21),
+					ast.NewInstantiation(p(1, 7, 5, 18), ast.NewIdentifier(p(1, 6, 5, 5), "F"), []ast.Expression{
+						ast.NewIdentifier(p(1, 8, 7, 9), "int"),
+						ast.NewIdentifier(p(1, 13, 12, 17), "string"),
+					}),
+					[]ast.Expression{ast.NewIdentifier(p(1, 21, 20, 20), "a")}, false),
+			})}, ast.FormatText)},
+	{"type N interface { ~int | string }", ast.NewTree("", []ast.Node{
+		ast.NewTypeDeclaration(p(1, 1, 0, 33), ast.NewIdentifier(p(1, 6, 5, 5), "N"),
+			ast.NewInterface(p(1, 8, 7, 33), []*ast.Field{
+				ast.NewField(nil, ast.NewBinaryOperator(p(1, 20, 19, 31), ast.OperatorBitOr,
+					ast.NewUnaryOperator(p(1, 20, 19, 22), ast.OperatorTilde, ast.NewIdentifier(p(1, 21, 20, 22), "int")),
+					ast.NewIdentifier(p(1, 27, 26, 31), "string")), ""),
+			}), false)}, ast.FormatText)},
 }
 
 var treeTests = []struct {
@@ -1825,10 +1851,30 @@ func equals(n1, n2 ast.Node, p int) error {
 		}
 
 	case *ast.Interface:
-		_, ok := n2.(*ast.Interface)
+		nn2, ok := n2.(*ast.Interface)
 		if !ok {
 			return fmt.Errorf("unexpected %#v, expecting %#v", n1, n2)
 		}
+		if len(nn1.Methods) != len(nn2.Methods) {
+			return fmt.Errorf("interface type: unexpected methods len %#v, expecting %#v", len(nn1.Methods), len(nn2.Methods))
+		}
+		for i := range nn1.Methods {
+			m1 := nn1.Methods[i]
+			m2 := nn2.Methods[i]
+			if len(m1.Idents) != len(m2.Idents) {
+				return fmt.Errorf("interface type: method %d: expecting %d identifiers, got %d", i, len(m2.Idents), len(m1.Idents))
+			}
+			for j := range m1.Idents {
+				err := equals(m1.Idents[j], m2.Idents[j], p)
+				if err != nil {
+					return fmt.Errorf("interface type: method %d: %s", i, err)
+				}
+			}
+			err := equals(m1.Type, m2.Type, p)
+			if err != nil {
+				return fmt.Errorf("interface type: method %d: %s", i, err)
+			}
+		}
 
 	case *ast.ArrayType:
 		nn2, ok := n2.(*ast.ArrayType)
@@ -1921,6 +1967,20 @@ func equals(n1, n2 ast.Node, p int) error {
 		if !nn1.IsAliasDeclaration && nn2.IsAliasDeclaration {
 			return fmt.Errorf("expecting alias declaration, got type definition")
 		}
+		if len(nn1.TypeParams) != len(nn2.TypeParams) {
+			return fmt.Errorf("unexpected type parameters len %d, expecting %d", len(nn1.TypeParams), len(nn2.TypeParams))
+		}
+		for i, p1 := range nn1.TypeParams {
+			p2 := nn2.TypeParams[i]
+			err := equals(p1.Ident, p2.Ident, p)
+			if err != nil {
+				return err
+			}
+			err = equals(p1.Type, p2.Type, p)
+			if err != nil {
+				return err
+			}
+		}
 
 	case *ast.Call:
 		nn2, ok := n2.(*ast.Call)
@@ -1988,6 +2048,25 @@ func equals(n1, n2 ast.Node, p int) error {
 			return err
 		}
 
+	case *ast.Instantiation:
+		nn2, ok := n2.(*ast.Instantiation)
+		if !ok {
+			return fmt.Errorf("unexpected %#v, expecting %#v", n1, n2)
+		}
+		err := equals(nn1.Expr, nn2.Expr, p)
+		if err != nil {
+			return err
+		}
+		if len(nn1.TypeArgs) != len(nn2.TypeArgs) {
+			return fmt.Errorf("unexpected type arguments len %d, expecting %d", len(nn1.TypeArgs), len(nn2.TypeArgs))
+		}
+		for i, arg := range nn1.TypeArgs {
+			err = equals(arg, nn2.TypeArgs[i], p)
+			if err != nil {
+				return err
+			}
+		}
+
 	case *ast.Slicing:
 		nn2, ok := n2.(*ast.Slicing)
 		if !ok {
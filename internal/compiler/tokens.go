@@ -118,6 +118,7 @@ const (
 	tokenContains                          // contains
 	tokenRaw                               // raw
 	tokenUsing                             // using
+	tokenTilde                             // ~
 )
 
 var tokenString = map[tokenTyp]string{
@@ -225,6 +226,7 @@ var tokenString = map[tokenTyp]string{
 	tokenContains:                 "contains",
 	tokenRaw:                      "raw",
 	tokenUsing:                    "using",
+	tokenTilde:                    "~",
 }
 
 func (tt tokenTyp) String() string {
@@ -243,6 +245,7 @@ type token struct {
 	tag string        // tag name
 	att string        // attribute
 	lin int           // line of the lexer when the token was emitted
+	non []int         // offsets in text where a nonce attribute can be inserted
 }
 
 // String returns the string that represents the token.
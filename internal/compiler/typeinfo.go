@@ -51,6 +51,10 @@ const (
 	methodValueInterface                   // Method value on an interface receiver.
 	methodCallConcrete                     // Method call on concrete receiver.
 	methodCallInterface                    // Method call on interface receiver.
+	methodValueScriggo                     // Method value of a method declared in Scriggo.
+	methodCallScriggo                      // Method call of a method declared in Scriggo.
+	methodExprScriggo                      // Method expression of a method declared in Scriggo.
+	methodExprFuncLit                      // Method expression implemented with a function literal.
 )
 
 // Nil reports whether it is the predeclared nil.
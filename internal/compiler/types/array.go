@@ -65,4 +65,9 @@ func (x arrayType) GoType() reflect.Type {
 func (x arrayType) Unwrap(v reflect.Value) (reflect.Value, bool) { return unwrap(x, v) }
 
 // Wrap implements the interface runtime.ScriggoType.
-func (x arrayType) Wrap(v reflect.Value) reflect.Value { return wrap(x, v) }
+func (x arrayType) Wrap(v reflect.Value, caller runtime.MethodCaller) reflect.Value {
+	return wrap(x, v, caller)
+}
+
+// ScriggoMethod implements the interface runtime.ScriggoType.
+func (x arrayType) ScriggoMethod(string) (*runtime.Method, bool) { return nil, false }
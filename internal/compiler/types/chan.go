@@ -73,4 +73,9 @@ func (x chanType) GoType() reflect.Type {
 func (x chanType) Unwrap(v reflect.Value) (reflect.Value, bool) { return unwrap(x, v) }
 
 // Wrap implements the interface runtime.ScriggoType.
-func (x chanType) Wrap(v reflect.Value) reflect.Value { return wrap(x, v) }
+func (x chanType) Wrap(v reflect.Value, caller runtime.MethodCaller) reflect.Value {
+	return wrap(x, v, caller)
+}
+
+// ScriggoMethod implements the interface runtime.ScriggoType.
+func (x chanType) ScriggoMethod(string) (*runtime.Method, bool) { return nil, false }
@@ -27,6 +27,10 @@ type definedType struct {
 	// represents are identical (every defined type, in Go, is different from
 	// every other type).
 	sign *byte
+
+	// methods is the set of the methods declared in Scriggo code with the
+	// defined type as receiver base type.
+	methods *methodSet
 }
 
 // DefinedOf returns the defined type with the given name and underlying type.
@@ -36,7 +40,17 @@ func (types *Types) DefinedOf(name string, underlyingType reflect.Type) reflect.
 	if name == "" {
 		panic(internalError("name cannot be empty"))
 	}
-	return definedType{Type: underlyingType, name: name, sign: new(byte)}
+	return definedType{Type: underlyingType, name: name, sign: new(byte), methods: &methodSet{}}
+}
+
+// Definition returns the type used in the definition of t, that is the
+// underlying type passed to DefinedOf, and true. If t has not been returned
+// by DefinedOf, it returns nil and false.
+func Definition(t reflect.Type) (reflect.Type, bool) {
+	if x, ok := t.(definedType); ok {
+		return x.Type, true
+	}
+	return nil, false
 }
 
 func (x definedType) Name() string {
@@ -55,7 +69,10 @@ func (x definedType) Implements(y reflect.Type) bool {
 	return Implements(x, y)
 }
 
-func (x definedType) MethodByName(string) (reflect.Method, bool) {
+func (x definedType) MethodByName(name string) (reflect.Method, bool) {
+	if x.Kind() == reflect.Interface {
+		return x.Type.MethodByName(name)
+	}
 	// TODO.
 	return reflect.Method{}, false
 }
@@ -80,4 +97,18 @@ func (x definedType) GoType() reflect.Type {
 func (x definedType) Unwrap(v reflect.Value) (reflect.Value, bool) { return unwrap(x, v) }
 
 // Wrap implements the interface runtime.ScriggoType.
-func (x definedType) Wrap(v reflect.Value) reflect.Value { return wrap(x, v) }
+func (x definedType) Wrap(v reflect.Value, caller runtime.MethodCaller) reflect.Value {
+	return wrap(x, v, caller)
+}
+
+// ScriggoMethod implements the interface runtime.ScriggoType.
+func (x definedType) ScriggoMethod(name string) (*runtime.Method, bool) {
+	m, ok := x.methods.byName[name]
+	if !ok {
+		m, ok = promotedMethod(x, name)
+	}
+	if ok && !m.Pointer {
+		return m, true
+	}
+	return nil, false
+}
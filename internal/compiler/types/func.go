@@ -181,4 +181,9 @@ func (x funcType) GoType() reflect.Type {
 func (x funcType) Unwrap(v reflect.Value) (reflect.Value, bool) { return unwrap(x, v) }
 
 // Wrap implements the interface runtime.ScriggoType.
-func (x funcType) Wrap(v reflect.Value) reflect.Value { return wrap(x, v) }
+func (x funcType) Wrap(v reflect.Value, caller runtime.MethodCaller) reflect.Value {
+	return wrap(x, v, caller)
+}
+
+// ScriggoMethod implements the interface runtime.ScriggoType.
+func (x funcType) ScriggoMethod(string) (*runtime.Method, bool) { return nil, false }
@@ -0,0 +1,184 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package types
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/open2b/scriggo/internal/runtime"
+)
+
+var emptyInterfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// InterfaceOf returns the interface type with the given methods. The types
+// of the methods must not have the receiver. If methods is empty, it returns
+// the empty interface type.
+//
+// As the reflect package cannot create interface types, InterfaceOf returns
+// a Scriggo interface type whose Go type is the empty interface type.
+func (types *Types) InterfaceOf(methods []reflect.Method) reflect.Type {
+	if len(methods) == 0 {
+		return emptyInterfaceType
+	}
+	ms := make([]reflect.Method, len(methods))
+	copy(ms, methods)
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Name < ms[j].Name })
+	for i := range ms {
+		ms[i].Func = reflect.Value{}
+		ms[i].Index = i
+	}
+	return interfaceType{
+		Type:    emptyInterfaceType,
+		methods: types.addMethods(ms),
+	}
+}
+
+// addMethods adds a list of interface methods to the cache if not already
+// present or returns the found one. This avoids duplication of interface
+// methods by ensuring that every pointer to slice returned by this method is
+// equal if and only if the underlying slice is equal.
+func (types *Types) addMethods(methods []reflect.Method) *[]reflect.Method {
+	for _, stored := range types.interfaceMethodsLists {
+		if equalMethods(*stored, methods) {
+			return stored
+		}
+	}
+	types.interfaceMethodsLists = append(types.interfaceMethodsLists, &methods)
+	return &methods
+}
+
+// equalMethods reports whether ms1 and ms2 contain the same methods, in the
+// same order.
+func equalMethods(ms1, ms2 []reflect.Method) bool {
+	if len(ms1) != len(ms2) {
+		return false
+	}
+	for i := range ms1 {
+		if ms1[i].Name != ms2[i].Name || ms1[i].PkgPath != ms2[i].PkgPath || ms1[i].Type != ms2[i].Type {
+			return false
+		}
+	}
+	return true
+}
+
+// interfaceType represents an interface type, with at least one method,
+// declared in Scriggo code.
+type interfaceType struct {
+	reflect.Type // always the empty interface type.
+
+	// methods are the methods of the interface, sorted by name. It must be
+	// a pointer because an interfaceType value must be comparable.
+	methods *[]reflect.Method
+}
+
+func (x interfaceType) AssignableTo(y reflect.Type) bool {
+	return AssignableTo(x, y)
+}
+
+func (x interfaceType) ConvertibleTo(y reflect.Type) bool {
+	return ConvertibleTo(x, y)
+}
+
+func (x interfaceType) Implements(y reflect.Type) bool {
+	return Implements(x, y)
+}
+
+func (x interfaceType) Method(i int) reflect.Method {
+	return (*x.methods)[i]
+}
+
+func (x interfaceType) MethodByName(name string) (reflect.Method, bool) {
+	for _, m := range *x.methods {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return reflect.Method{}, false
+}
+
+func (x interfaceType) Name() string {
+	return "" // composite types do not have a name.
+}
+
+func (x interfaceType) NumMethod() int {
+	return len(*x.methods)
+}
+
+func (x interfaceType) String() string {
+	var b strings.Builder
+	b.WriteString("interface { ")
+	for i, m := range *x.methods {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(m.Name)
+		b.WriteString(strings.TrimRight(strings.TrimPrefix(m.Type.String(), "func"), " "))
+	}
+	b.WriteString(" }")
+	return b.String()
+}
+
+// GoType implements the interface runtime.ScriggoType.
+func (x interfaceType) GoType() reflect.Type {
+	return x.Type
+}
+
+// Unwrap implements the interface runtime.ScriggoType.
+func (x interfaceType) Unwrap(v reflect.Value) (reflect.Value, bool) { return unwrap(x, v) }
+
+// Wrap implements the interface runtime.ScriggoType.
+func (x interfaceType) Wrap(v reflect.Value, caller runtime.MethodCaller) reflect.Value {
+	return wrap(x, v, caller)
+}
+
+// ScriggoMethod implements the interface runtime.ScriggoType.
+func (x interfaceType) ScriggoMethod(string) (*runtime.Method, bool) { return nil, false }
+
+// MissingMethod returns the name of a method of the interface type iface
+// that is missing in the method set of t, or that has a different type. If
+// t implements iface, it returns an empty string.
+func MissingMethod(t, iface reflect.Type) string {
+	for i := 0; i < iface.NumMethod(); i++ {
+		im := iface.Method(i)
+		mt, ok := methodType(t, im.Name)
+		if !ok || !identical(mt, im.Type, false, false) {
+			return im.Name
+		}
+	}
+	return ""
+}
+
+// methodType returns the type, without the receiver, of the method of t
+// with the given name and true. For types declared in Scriggo code, the
+// method set is the set of the methods declared in Scriggo code. If there
+// is no such method, it returns nil and false.
+func methodType(t reflect.Type, name string) (reflect.Type, bool) {
+	if t.Kind() == reflect.Interface {
+		m, ok := t.MethodByName(name)
+		return m.Type, ok
+	}
+	if st, ok := t.(runtime.ScriggoType); ok {
+		m, ok := st.ScriggoMethod(name)
+		if !ok {
+			return nil, false
+		}
+		return m.Type, true
+	}
+	m, ok := t.MethodByName(name)
+	if !ok {
+		return nil, false
+	}
+	in := make([]reflect.Type, m.Type.NumIn()-1)
+	for i := range in {
+		in[i] = m.Type.In(i + 1)
+	}
+	out := make([]reflect.Type, m.Type.NumOut())
+	for i := range out {
+		out[i] = m.Type.Out(i)
+	}
+	return reflect.FuncOf(in, out, m.Type.IsVariadic()), true
+}
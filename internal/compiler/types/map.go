@@ -101,4 +101,9 @@ func (x mapType) GoType() reflect.Type {
 func (x mapType) Unwrap(v reflect.Value) (reflect.Value, bool) { return unwrap(x, v) }
 
 // Wrap implements the interface runtime.ScriggoType.
-func (x mapType) Wrap(v reflect.Value) reflect.Value { return wrap(x, v) }
+func (x mapType) Wrap(v reflect.Value, caller runtime.MethodCaller) reflect.Value {
+	return wrap(x, v, caller)
+}
+
+// ScriggoMethod implements the interface runtime.ScriggoType.
+func (x mapType) ScriggoMethod(string) (*runtime.Method, bool) { return nil, false }
@@ -0,0 +1,325 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/open2b/scriggo/internal/runtime"
+	"github.com/open2b/scriggo/native"
+)
+
+// methodSet is the set of the methods declared in Scriggo code for a defined
+// type.
+type methodSet struct {
+	list   []*runtime.Method
+	byName map[string]*runtime.Method
+
+	// value and pointer are the methods exposed to Go by the proxies of the
+	// values of the defined type and of the pointer to the defined type.
+	// They are computed the first time they are needed, see the proxies
+	// method, as they also depend on the methods promoted from the embedded
+	// fields.
+	once           sync.Once
+	value, pointer proxyMethods
+}
+
+// AddMethod adds the method m to the defined type t. t must have been
+// returned by DefinedOf.
+func (types *Types) AddMethod(t reflect.Type, m *runtime.Method) {
+	dt, ok := t.(definedType)
+	if !ok {
+		panic(internalError("%s is not a defined type", t))
+	}
+	ms := dt.methods
+	if ms.byName == nil {
+		ms.byName = map[string]*runtime.Method{}
+	}
+	ms.list = append(ms.list, m)
+	ms.byName[m.Name] = m
+	ms.once = sync.Once{}
+}
+
+// proxies returns the methods exposed to Go by the proxies of the values of
+// the defined type t and of the pointers to t. ms must be the method set of
+// t.
+func (ms *methodSet) proxies(t definedType) (value, pointer proxyMethods) {
+	ms.once.Do(func() {
+		ms.value = proxyMethodsOf(t)
+		ms.pointer = proxyMethodsOf(ptrType{Type: reflect.PtrTo(t.GoType()), elem: t})
+	})
+	return ms.value, ms.pointer
+}
+
+// Methods returns the methods declared in Scriggo code for the type t, in
+// the order they have been added, with both value and pointer receivers. If
+// t is not a defined type returned by DefinedOf, it returns nil.
+func Methods(t reflect.Type) []*runtime.Method {
+	if dt, ok := t.(definedType); ok {
+		return dt.methods.list
+	}
+	return nil
+}
+
+// MethodByName returns the method, declared in Scriggo code, with the given
+// name of the type t, or of the type pointed to by t if t is a pointer type,
+// and true. The method can also be promoted from an embedded field. Unlike
+// the ScriggoMethod method of a ScriggoType, it also returns the methods with
+// a pointer receiver when t is not a pointer type. If there is no such
+// method, it returns nil and false.
+func MethodByName(t reflect.Type, name string) (*runtime.Method, bool) {
+	if pt, ok := t.(ptrType); ok {
+		t = pt.elem
+	}
+	if dt, ok := t.(definedType); ok {
+		if m, ok := dt.methods.byName[name]; ok {
+			return m, true
+		}
+	}
+	return promotedMethod(t, name)
+}
+
+// promotedMethod returns the method with the given name promoted, in the
+// Scriggo struct type t, from an embedded field and true. The promoted
+// methods are the methods declared in Scriggo code and the methods of the
+// embedded fields with an interface type. If there is no such method, or if
+// the selector is ambiguous or selects a field, it returns nil and false.
+//
+// The returned method has a pointer receiver only if the method it promotes
+// has a pointer receiver and no embedded field in the path is a pointer.
+func promotedMethod(t reflect.Type, name string) (*runtime.Method, bool) {
+	m, found := lookupPromoted(t, name)
+	return m, found == 1 && m != nil
+}
+
+// AmbiguousMethod reports whether, in the Scriggo struct type t, the selector
+// with the given name is ambiguous because it selects more than one promoted
+// method, or promoted methods and fields, at the same depth.
+func AmbiguousMethod(t reflect.Type, name string) bool {
+	if pt, ok := t.(ptrType); ok {
+		t = pt.elem
+	}
+	m, found := lookupPromoted(t, name)
+	return found > 1 && m != nil
+}
+
+// lookupPromoted looks up the method with the given name promoted from an
+// embedded field of t, at the shallowest depth with fields or methods with
+// that name, and returns the last method found and the number of fields and
+// methods found.
+func lookupPromoted(t reflect.Type, name string) (*runtime.Method, int) {
+	if _, ok := t.(runtime.ScriggoType); !ok || t.Kind() != reflect.Struct {
+		return nil, 0
+	}
+	type embedded struct {
+		typ      reflect.Type
+		index    []int
+		indirect bool
+	}
+	current := []embedded{{typ: t}}
+	seen := map[reflect.Type]bool{}
+	for len(current) > 0 {
+		var next []embedded
+		var method *runtime.Method
+		found := 0
+		for _, e := range current {
+			if seen[e.typ] {
+				continue
+			}
+			if e.index != nil {
+				if dt, ok := e.typ.(definedType); ok {
+					if m, ok := dt.methods.byName[name]; ok {
+						method = &runtime.Method{Name: name, Type: m.Type, Pointer: m.Pointer && !e.indirect,
+							Index: e.index, Promoted: m}
+						found++
+						continue
+					}
+				}
+				if e.typ.Kind() == reflect.Interface {
+					if m, ok := e.typ.MethodByName(name); ok {
+						method = &runtime.Method{Name: name, Type: m.Type, Index: e.index}
+						found++
+					}
+					continue
+				}
+			}
+			if e.typ.Kind() != reflect.Struct {
+				continue
+			}
+			for i := 0; i < e.typ.NumField(); i++ {
+				f := e.typ.Field(i)
+				if fieldName(f) == name {
+					found++
+				}
+				if f.Anonymous {
+					typ, indirect := f.Type, e.indirect
+					if typ.Kind() == reflect.Ptr {
+						typ, indirect = typ.Elem(), true
+					}
+					index := append(e.index[:len(e.index):len(e.index)], i)
+					next = append(next, embedded{typ: typ, index: index, indirect: indirect})
+				}
+			}
+		}
+		if found > 0 {
+			return method, found
+		}
+		for _, e := range current {
+			seen[e.typ] = true
+		}
+		current = next
+	}
+	return nil, 0
+}
+
+// fieldName returns the name of the struct field f. The type checker encodes
+// the names of the unexported fields prefixing them with "𝗽" followed by a
+// package identifier.
+func fieldName(f reflect.StructField) string {
+	name := f.Name
+	if !strings.HasPrefix(name, "𝗽") {
+		return name
+	}
+	name = strings.TrimLeft(name[len("𝗽"):], "0123456789")
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// proxyMethods represents the methods exposed to Go by a proxy. The first
+// three bits report whether the String, Error and sort.Interface methods are
+// exposed and the remaining bits, if not zero, are the index plus one of the
+// exposed format method in formatInterfaces.
+//
+// Only one format method is exposed to Go, the first one, in the order of
+// formatInterfaces, implemented by the type.
+type proxyMethods int
+
+const (
+	proxyString proxyMethods = 1 << iota
+	proxyError
+	proxySort
+	proxyFormat
+)
+
+var (
+	stringerInterface = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	errorInterface    = reflect.TypeOf((*error)(nil)).Elem()
+	sortInterface     = reflect.TypeOf((*sort.Interface)(nil)).Elem()
+)
+
+// formatInterfaces are the interfaces with a format method that can be
+// exposed to Go by a proxy.
+var formatInterfaces = [...]reflect.Type{
+	reflect.TypeOf((*native.HTMLStringer)(nil)).Elem(),
+	reflect.TypeOf((*native.CSSStringer)(nil)).Elem(),
+	reflect.TypeOf((*native.JSStringer)(nil)).Elem(),
+	reflect.TypeOf((*native.JSONStringer)(nil)).Elem(),
+	reflect.TypeOf((*native.MarkdownStringer)(nil)).Elem(),
+	reflect.TypeOf((*native.XMLStringer)(nil)).Elem(),
+	reflect.TypeOf((*native.YAMLStringer)(nil)).Elem(),
+	reflect.TypeOf((*native.TOMLStringer)(nil)).Elem(),
+}
+
+// proxyMethodsOf returns the methods exposed to Go by the proxies of the
+// values of type t.
+func proxyMethodsOf(t runtime.ScriggoType) proxyMethods {
+	var methods proxyMethods
+	if hasMethods(t, stringerInterface) {
+		methods |= proxyString
+	}
+	if hasMethods(t, errorInterface) {
+		methods |= proxyError
+	}
+	if hasMethods(t, sortInterface) {
+		methods |= proxySort
+	}
+	for i, iface := range formatInterfaces {
+		if hasMethods(t, iface) {
+			methods |= proxyFormat * proxyMethods(i+1)
+			break
+		}
+	}
+	return methods
+}
+
+// exposes reports whether the proxies with methods p expose to Go the method
+// of the interface type iface with the given name.
+func (p proxyMethods) exposes(name string) bool {
+	switch name {
+	case "String":
+		return p&proxyString != 0
+	case "Error":
+		return p&proxyError != 0
+	case "Len", "Less", "Swap":
+		return p&proxySort != 0
+	}
+	if i := int(p / proxyFormat); i > 0 {
+		return formatInterfaces[i-1].Method(0).Name == name
+	}
+	return false
+}
+
+// hasMethods reports whether the method set of t, with methods declared in
+// Scriggo code if t is a Scriggo type, contains all the methods of the
+// interface type iface.
+func hasMethods(t reflect.Type, iface reflect.Type) bool {
+	return MissingMethod(t, iface) == ""
+}
+
+// implementsWithMethods reports whether the Scriggo type x implements the
+// native interface type y with the methods declared in Scriggo code. The
+// methods of y must be exposed to Go by the proxies of x.
+//
+// If x is an interface type, the methods of y must be exposable to Go by a
+// proxy, as the dynamic value could have a Scriggo type.
+func implementsWithMethods(x runtime.ScriggoType, y reflect.Type) bool {
+	if x.Kind() == reflect.Interface {
+		if g := x.GoType(); g.NumMethod() > 0 {
+			return g.Implements(y)
+		}
+		for i := 0; i < y.NumMethod(); i++ {
+			if !exposable(y.Method(i).Name) {
+				return false
+			}
+		}
+		return hasMethods(x, y)
+	}
+	var exposed proxyMethods
+	switch t := x.(type) {
+	case definedType:
+		exposed, _ = t.methods.proxies(t)
+	case ptrType:
+		if elem, ok := t.elem.(definedType); ok {
+			_, exposed = elem.methods.proxies(elem)
+		}
+	}
+	for i := 0; i < y.NumMethod(); i++ {
+		if !exposed.exposes(y.Method(i).Name) {
+			return false
+		}
+	}
+	return hasMethods(x, y)
+}
+
+// exposable reports whether the method with the given name can be exposed
+// to Go by a proxy.
+func exposable(name string) bool {
+	switch name {
+	case "String", "Error", "Len", "Less", "Swap":
+		return true
+	}
+	for _, iface := range formatInterfaces {
+		if iface.Method(0).Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -63,4 +63,11 @@ func (x ptrType) GoType() reflect.Type {
 func (x ptrType) Unwrap(v reflect.Value) (reflect.Value, bool) { return unwrap(x, v) }
 
 // Wrap implements the interface runtime.ScriggoType.
-func (x ptrType) Wrap(v reflect.Value) reflect.Value { return wrap(x, v) }
+func (x ptrType) Wrap(v reflect.Value, caller runtime.MethodCaller) reflect.Value {
+	return wrap(x, v, caller)
+}
+
+// ScriggoMethod implements the interface runtime.ScriggoType.
+func (x ptrType) ScriggoMethod(name string) (*runtime.Method, bool) {
+	return MethodByName(x, name)
+}
@@ -63,4 +63,9 @@ func (x sliceType) GoType() reflect.Type {
 func (x sliceType) Unwrap(v reflect.Value) (reflect.Value, bool) { return unwrap(x, v) }
 
 // Wrap implements the interface runtime.ScriggoType.
-func (x sliceType) Wrap(v reflect.Value) reflect.Value { return wrap(x, v) }
+func (x sliceType) Wrap(v reflect.Value, caller runtime.MethodCaller) reflect.Value {
+	return wrap(x, v, caller)
+}
+
+// ScriggoMethod implements the interface runtime.ScriggoType.
+func (x sliceType) ScriggoMethod(string) (*runtime.Method, bool) { return nil, false }
@@ -155,4 +155,14 @@ func (x structType) GoType() reflect.Type {
 func (x structType) Unwrap(v reflect.Value) (reflect.Value, bool) { return unwrap(x, v) }
 
 // Wrap implements the interface runtime.ScriggoType.
-func (x structType) Wrap(v reflect.Value) reflect.Value { return wrap(x, v) }
+func (x structType) Wrap(v reflect.Value, caller runtime.MethodCaller) reflect.Value {
+	return wrap(x, v, caller)
+}
+
+// ScriggoMethod implements the interface runtime.ScriggoType.
+func (x structType) ScriggoMethod(name string) (*runtime.Method, bool) {
+	if m, ok := promotedMethod(x, name); ok && !m.Pointer {
+		return m, true
+	}
+	return nil, false
+}
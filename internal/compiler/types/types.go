@@ -24,6 +24,10 @@ type Types struct {
 	// structFieldsLists avoid the creation of two different structTypes with
 	// the same struct fields.
 	structFieldsLists []*map[int]reflect.StructField
+
+	// interfaceMethodsLists avoid the creation of two different
+	// interfaceTypes with the same methods.
+	interfaceMethodsLists []*[]reflect.Method
 }
 
 // NewTypes returns a new instance of Types.
@@ -160,11 +164,17 @@ func ConvertibleTo(x, y reflect.Type) bool {
 
 // Implements reports whether x implements the interface type y.
 func Implements(x, y reflect.Type) bool {
-	if _, ok := x.(runtime.ScriggoType); ok {
-		return y.NumMethod() == 0
+	if st, ok := y.(runtime.ScriggoType); ok {
+		// y is an interface type declared in Scriggo code. If its Go type
+		// is a native interface type with methods, the dynamic values of y
+		// must implement the native interface type.
+		if g := st.GoType(); g.NumMethod() > 0 {
+			return Implements(x, g)
+		}
+		return hasMethods(x, y)
 	}
-	if _, ok := y.(runtime.ScriggoType); ok {
-		return true
+	if st, ok := x.(runtime.ScriggoType); ok {
+		return implementsWithMethods(st, y)
 	}
 	// If y has unexported methods, and x is not an interface type, it is not possible to check
 	// if x implements y using the x.NumMethod and x.Method methods, because they do not return
@@ -288,8 +298,9 @@ func (types *Types) TypeOf(v reflect.Value) reflect.Type {
 	if !v.IsValid() {
 		return nil
 	}
-	if p, ok := v.Interface().(emptyInterfaceProxy); ok {
-		return p.sign
+	if p, ok := v.Interface().(proxy); ok {
+		_, sign := p.proxied()
+		return sign
 	}
 	return v.Type()
 }
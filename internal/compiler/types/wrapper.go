@@ -8,37 +8,707 @@ import (
 	"reflect"
 
 	"github.com/open2b/scriggo/internal/runtime"
+	"github.com/open2b/scriggo/native"
 )
 
 // wrap and unwrap are called by the methods Wrap and Unwrap of the types
 // defined in this package. These two methods and the GoType method
 // implement the runtime.ScriggoType interface.
 
-func wrap(t runtime.ScriggoType, v reflect.Value) reflect.Value {
-	return reflect.ValueOf(emptyInterfaceProxy{
-		value: v,
-		sign:  t,
-	})
+func wrap(t runtime.ScriggoType, v reflect.Value, caller runtime.MethodCaller) reflect.Value {
+	// The values of interface types are already wrapped, if necessary.
+	if t.Kind() == reflect.Interface {
+		return v
+	}
+	var methods proxyMethods
+	switch t := t.(type) {
+	case definedType:
+		methods, _ = t.methods.proxies(t)
+	case ptrType:
+		if elem, ok := t.elem.(definedType); ok {
+			_, methods = elem.methods.proxies(elem)
+		}
+	}
+	if methods == 0 {
+		return reflect.ValueOf(emptyInterfaceProxy{
+			value: v,
+			sign:  t,
+		})
+	}
+	p := &methodsProxy{
+		value:  v,
+		sign:   t,
+		caller: caller,
+	}
+	return reflect.ValueOf(proxies[methods](p))
 }
 
-// TODO: currently unwrap always returns an empty interface wrapper. This will
-//  change when methods declaration will be implemented in Scriggo.
 func unwrap(x runtime.ScriggoType, v reflect.Value) (reflect.Value, bool) {
-	p, ok := v.Interface().(emptyInterfaceProxy)
+	// For interface types, v is returned as is if its dynamic type
+	// implements x.
+	if x.Kind() == reflect.Interface {
+		t := v.Type()
+		if p, ok := v.Interface().(proxy); ok {
+			_, t = p.proxied()
+		}
+		if !Implements(t, x) {
+			return reflect.Value{}, false
+		}
+		return v, true
+	}
+	p, ok := v.Interface().(proxy)
 	// Not a proxy.
 	if !ok {
 		return reflect.Value{}, false
 	}
+	value, sign := p.proxied()
 	// v is a proxy but it has a different Scriggo type.
-	if p.sign != x {
+	if sign != x {
 		return reflect.Value{}, false
 	}
-	return p.value, true
+	return value, true
+}
+
+// proxy is implemented by the proxies returned by wrap.
+type proxy interface {
+	proxied() (reflect.Value, runtime.ScriggoType)
 }
 
-// emptyInterfaceProxy is a proxy for values of types that have an empty
-// method set.
+// emptyInterfaceProxy is a proxy for values of types that do not expose
+// methods to Go.
 type emptyInterfaceProxy struct {
 	value reflect.Value
 	sign  runtime.ScriggoType
 }
+
+func (p emptyInterfaceProxy) proxied() (reflect.Value, runtime.ScriggoType) {
+	return p.value, p.sign
+}
+
+// methodsProxy is a proxy for values of types that expose to Go methods
+// declared in Scriggo code. It is embedded, together with the types that
+// implement the exposed methods, in the struct types returned by the
+// functions in proxies.
+type methodsProxy struct {
+	value  reflect.Value
+	sign   runtime.ScriggoType
+	caller runtime.MethodCaller
+}
+
+func (p *methodsProxy) proxied() (reflect.Value, runtime.ScriggoType) {
+	return p.value, p.sign
+}
+
+// call calls the method with the given name and arguments.
+func (p *methodsProxy) call(name string, args ...reflect.Value) []reflect.Value {
+	m, _ := p.sign.ScriggoMethod(name)
+	return p.caller.CallMethod(m, p.value, args)
+}
+
+// proxies contains, for every combination of exposed methods, a function
+// that returns a new proxy exposing the methods. proxies is indexed by
+// proxyMethods.
+var proxies = [int(proxyFormat) * (len(formatInterfaces) + 1)]func(p *methodsProxy) interface{}{
+	nil,
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+		}{p, stringMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+		}{p, errorMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+		}{p, stringMethod{p}, errorMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			sortMethods
+		}{p, sortMethods{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			sortMethods
+		}{p, stringMethod{p}, sortMethods{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			sortMethods
+		}{p, errorMethod{p}, sortMethods{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			sortMethods
+		}{p, stringMethod{p}, errorMethod{p}, sortMethods{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			htmlMethod
+		}{p, htmlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			htmlMethod
+		}{p, stringMethod{p}, htmlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			htmlMethod
+		}{p, errorMethod{p}, htmlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			htmlMethod
+		}{p, stringMethod{p}, errorMethod{p}, htmlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			sortMethods
+			htmlMethod
+		}{p, sortMethods{p}, htmlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			sortMethods
+			htmlMethod
+		}{p, stringMethod{p}, sortMethods{p}, htmlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			sortMethods
+			htmlMethod
+		}{p, errorMethod{p}, sortMethods{p}, htmlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			sortMethods
+			htmlMethod
+		}{p, stringMethod{p}, errorMethod{p}, sortMethods{p}, htmlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			cssMethod
+		}{p, cssMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			cssMethod
+		}{p, stringMethod{p}, cssMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			cssMethod
+		}{p, errorMethod{p}, cssMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			cssMethod
+		}{p, stringMethod{p}, errorMethod{p}, cssMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			sortMethods
+			cssMethod
+		}{p, sortMethods{p}, cssMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			sortMethods
+			cssMethod
+		}{p, stringMethod{p}, sortMethods{p}, cssMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			sortMethods
+			cssMethod
+		}{p, errorMethod{p}, sortMethods{p}, cssMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			sortMethods
+			cssMethod
+		}{p, stringMethod{p}, errorMethod{p}, sortMethods{p}, cssMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			jsMethod
+		}{p, jsMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			jsMethod
+		}{p, stringMethod{p}, jsMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			jsMethod
+		}{p, errorMethod{p}, jsMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			jsMethod
+		}{p, stringMethod{p}, errorMethod{p}, jsMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			sortMethods
+			jsMethod
+		}{p, sortMethods{p}, jsMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			sortMethods
+			jsMethod
+		}{p, stringMethod{p}, sortMethods{p}, jsMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			sortMethods
+			jsMethod
+		}{p, errorMethod{p}, sortMethods{p}, jsMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			sortMethods
+			jsMethod
+		}{p, stringMethod{p}, errorMethod{p}, sortMethods{p}, jsMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			jsonMethod
+		}{p, jsonMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			jsonMethod
+		}{p, stringMethod{p}, jsonMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			jsonMethod
+		}{p, errorMethod{p}, jsonMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			jsonMethod
+		}{p, stringMethod{p}, errorMethod{p}, jsonMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			sortMethods
+			jsonMethod
+		}{p, sortMethods{p}, jsonMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			sortMethods
+			jsonMethod
+		}{p, stringMethod{p}, sortMethods{p}, jsonMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			sortMethods
+			jsonMethod
+		}{p, errorMethod{p}, sortMethods{p}, jsonMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			sortMethods
+			jsonMethod
+		}{p, stringMethod{p}, errorMethod{p}, sortMethods{p}, jsonMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			markdownMethod
+		}{p, markdownMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			markdownMethod
+		}{p, stringMethod{p}, markdownMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			markdownMethod
+		}{p, errorMethod{p}, markdownMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			markdownMethod
+		}{p, stringMethod{p}, errorMethod{p}, markdownMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			sortMethods
+			markdownMethod
+		}{p, sortMethods{p}, markdownMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			sortMethods
+			markdownMethod
+		}{p, stringMethod{p}, sortMethods{p}, markdownMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			sortMethods
+			markdownMethod
+		}{p, errorMethod{p}, sortMethods{p}, markdownMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			sortMethods
+			markdownMethod
+		}{p, stringMethod{p}, errorMethod{p}, sortMethods{p}, markdownMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			xmlMethod
+		}{p, xmlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			xmlMethod
+		}{p, stringMethod{p}, xmlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			xmlMethod
+		}{p, errorMethod{p}, xmlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			xmlMethod
+		}{p, stringMethod{p}, errorMethod{p}, xmlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			sortMethods
+			xmlMethod
+		}{p, sortMethods{p}, xmlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			sortMethods
+			xmlMethod
+		}{p, stringMethod{p}, sortMethods{p}, xmlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			sortMethods
+			xmlMethod
+		}{p, errorMethod{p}, sortMethods{p}, xmlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			sortMethods
+			xmlMethod
+		}{p, stringMethod{p}, errorMethod{p}, sortMethods{p}, xmlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			yamlMethod
+		}{p, yamlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			yamlMethod
+		}{p, stringMethod{p}, yamlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			yamlMethod
+		}{p, errorMethod{p}, yamlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			yamlMethod
+		}{p, stringMethod{p}, errorMethod{p}, yamlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			sortMethods
+			yamlMethod
+		}{p, sortMethods{p}, yamlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			sortMethods
+			yamlMethod
+		}{p, stringMethod{p}, sortMethods{p}, yamlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			sortMethods
+			yamlMethod
+		}{p, errorMethod{p}, sortMethods{p}, yamlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			sortMethods
+			yamlMethod
+		}{p, stringMethod{p}, errorMethod{p}, sortMethods{p}, yamlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			tomlMethod
+		}{p, tomlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			tomlMethod
+		}{p, stringMethod{p}, tomlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			tomlMethod
+		}{p, errorMethod{p}, tomlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			tomlMethod
+		}{p, stringMethod{p}, errorMethod{p}, tomlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			sortMethods
+			tomlMethod
+		}{p, sortMethods{p}, tomlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			sortMethods
+			tomlMethod
+		}{p, stringMethod{p}, sortMethods{p}, tomlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			errorMethod
+			sortMethods
+			tomlMethod
+		}{p, errorMethod{p}, sortMethods{p}, tomlMethod{p}}
+	},
+	func(p *methodsProxy) interface{} {
+		return struct {
+			*methodsProxy
+			stringMethod
+			errorMethod
+			sortMethods
+			tomlMethod
+		}{p, stringMethod{p}, errorMethod{p}, sortMethods{p}, tomlMethod{p}}
+	},
+}
+
+type stringMethod struct{ p *methodsProxy }
+
+func (m stringMethod) String() string {
+	return m.p.call("String")[0].String()
+}
+
+type errorMethod struct{ p *methodsProxy }
+
+func (m errorMethod) Error() string {
+	return m.p.call("Error")[0].String()
+}
+
+type sortMethods struct{ p *methodsProxy }
+
+func (m sortMethods) Len() int {
+	return int(m.p.call("Len")[0].Int())
+}
+
+func (m sortMethods) Less(i, j int) bool {
+	return m.p.call("Less", reflect.ValueOf(i), reflect.ValueOf(j))[0].Bool()
+}
+
+func (m sortMethods) Swap(i, j int) {
+	m.p.call("Swap", reflect.ValueOf(i), reflect.ValueOf(j))
+}
+
+type htmlMethod struct{ p *methodsProxy }
+
+func (m htmlMethod) HTML() native.HTML {
+	return native.HTML(m.p.call("HTML")[0].String())
+}
+
+type cssMethod struct{ p *methodsProxy }
+
+func (m cssMethod) CSS() native.CSS {
+	return native.CSS(m.p.call("CSS")[0].String())
+}
+
+type jsMethod struct{ p *methodsProxy }
+
+func (m jsMethod) JS() native.JS {
+	return native.JS(m.p.call("JS")[0].String())
+}
+
+type jsonMethod struct{ p *methodsProxy }
+
+func (m jsonMethod) JSON() native.JSON {
+	return native.JSON(m.p.call("JSON")[0].String())
+}
+
+type markdownMethod struct{ p *methodsProxy }
+
+func (m markdownMethod) Markdown() native.Markdown {
+	return native.Markdown(m.p.call("Markdown")[0].String())
+}
+
+type xmlMethod struct{ p *methodsProxy }
+
+func (m xmlMethod) XML() native.XML {
+	return native.XML(m.p.call("XML")[0].String())
+}
+
+type yamlMethod struct{ p *methodsProxy }
+
+func (m yamlMethod) YAML() native.YAML {
+	return native.YAML(m.p.call("YAML")[0].String())
+}
+
+type tomlMethod struct{ p *methodsProxy }
+
+func (m tomlMethod) TOML() native.TOML {
+	return native.TOML(m.p.call("TOML")[0].String())
+}
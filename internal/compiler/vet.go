@@ -0,0 +1,561 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/open2b/scriggo/ast"
+	"github.com/open2b/scriggo/ast/astutil"
+	"github.com/open2b/scriggo/native"
+)
+
+// VetTemplate type checks the named template file rooted at the given file
+// system, and the files it extends, imports and renders, without emitting
+// it, and reports the constructs that the compiler accepts but that are
+// probably mistakes:
+//
+//  - macros declared and not used
+//  - imports whose names are not used
+//  - declarations that shadow a global
+//  - show of values rendered as %!verb(...) or rendered as nothing
+//  - unreachable code after a return statement in macros
+//  - URLs built by string concatenation
+//  - raw statements containing template syntax
+//
+// The problems are returned as checking errors, sorted by path and position.
+// If the template cannot be built, it returns the build error.
+func VetTemplate(fsys fs.FS, name string, opts Options) ([]Error, error) {
+
+	// Parse the source code.
+	tree, err := ParseTemplate(fsys, name, opts.NoParseShortShowStmt, opts.DollarIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	// Transform the tree.
+	if opts.TreeTransformer != nil {
+		err := opts.TreeTransformer(tree)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Visit the files before the type checking, because the type checker
+	// transforms the trees.
+	v := &vetter{globals: opts.Globals, files: map[string]bool{}, macros: map[*ast.Identifier]vetMacro{}}
+	v.file(tree)
+
+	// Type check the tree.
+	checkerOpts := checkerOptions{
+		allowGoStmt: opts.AllowGoStmt,
+		formatTypes: opts.FormatTypes,
+		globals:     opts.Globals,
+		mdConverter: opts.MDConverter,
+		mod:         templateMod,
+		recordUses:  true,
+	}
+	tci, err := typecheck(tree, opts.Importer, checkerOpts)
+	if err != nil {
+		return nil, err
+	}
+	v.check(tci["main"])
+
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		e1, e2 := v.errors[i], v.errors[j]
+		if e1.path != e2.path {
+			return e1.path < e2.path
+		}
+		return e1.pos.Start < e2.pos.Start
+	})
+	errors := make([]Error, len(v.errors))
+	for i, err := range v.errors {
+		errors[i] = err
+	}
+
+	return errors, nil
+}
+
+// vetMacro is a macro declaration visited by the vetter.
+type vetMacro struct {
+	path string
+	// extending reports whether the macro is declared in a file that
+	// extends another file.
+	extending bool
+	// empty reports whether the body of the macro renders nothing. It is
+	// determined before the type checking, which adds nodes to the body.
+	empty bool
+}
+
+// vetImport is an import declaration visited by the vetter.
+type vetImport struct {
+	path string
+	node *ast.Import
+}
+
+// vetShow is a show statement visited by the vetter.
+type vetShow struct {
+	path string
+	node *ast.Show
+	// inURL reports whether the show statement is in an attribute value
+	// containing a URL.
+	inURL bool
+}
+
+// vetCall is a function call visited by the vetter.
+type vetCall struct {
+	path string
+	node *ast.Call
+}
+
+// vetter vets a template. The problems that depend only on the tree are
+// reported visiting the files, before the type checking, the others are
+// reported by the check method after the type checking.
+type vetter struct {
+	globals native.Declarations
+	files   map[string]bool
+	path    string
+	macros  map[*ast.Identifier]vetMacro
+	imports []vetImport
+	shows   []vetShow
+	calls   []vetCall
+	errors  []*CheckingError
+}
+
+// errorf reports a problem at the position of nodeOrPos in the file with
+// the given path.
+func (v *vetter) errorf(path string, nodeOrPos interface{}, format string, args ...interface{}) {
+	if err, ok := checkError(path, nodeOrPos, format, args...).(*CheckingError); ok {
+		v.errors = append(v.errors, err)
+	}
+}
+
+// file visits the file with the given tree, if it has not already been
+// visited.
+func (v *vetter) file(tree *ast.Tree) {
+	if tree == nil || v.files[tree.Path] {
+		return
+	}
+	v.files[tree.Path] = true
+	path := v.path
+	v.path = tree.Path
+	_, extending := getExtends(tree.Nodes)
+	for _, node := range tree.Nodes {
+		if fn, ok := node.(*ast.Func); ok && fn.Ident != nil && fn.Type.Macro {
+			v.macros[fn.Ident] = vetMacro{path: tree.Path, extending: extending, empty: isEmptyBody(fn.Body)}
+		}
+	}
+	v.nodes(tree.Nodes, false, false)
+	v.path = path
+}
+
+// nodes visits nodes. inMacro reports whether nodes are in the body of a
+// macro and inURL whether they are in an attribute value containing a URL.
+// It returns true if the nodes contain a return statement, not nested in
+// other statements.
+func (v *vetter) nodes(nodes []ast.Node, inMacro, inURL bool) bool {
+	returned, reported := false, false
+	for _, node := range nodes {
+		if returned && inMacro && !reported && !isBlankNode(node) {
+			v.errorf(v.path, node, "unreachable code")
+			reported = true
+		}
+		if v.node(node, inMacro, inURL) {
+			returned = true
+		}
+	}
+	return returned
+}
+
+// node visits node and returns true if it is a return statement or contains
+// a return statement not nested in other statements.
+func (v *vetter) node(node ast.Node, inMacro, inURL bool) bool {
+	switch n := node.(type) {
+	case *ast.Text, *ast.Comment, *ast.Break, *ast.Continue, *ast.Fallthrough, *ast.Goto:
+	case *ast.Raw:
+		v.raw(n)
+	case *ast.Show:
+		v.shows = append(v.shows, vetShow{path: v.path, node: n, inURL: inURL})
+		v.exprs(n.Expressions)
+	case *ast.URL:
+		v.nodes(n.Value, inMacro, true)
+	case *ast.Return:
+		v.exprs(n.Values)
+		return true
+	case *ast.Block:
+		v.nodes(n.Nodes, inMacro, inURL)
+	case *ast.Statements:
+		return v.nodes(n.Nodes, inMacro, inURL)
+	case *ast.If:
+		v.stmt(n.Init)
+		v.expr(n.Condition)
+		if n.Then != nil {
+			v.nodes(n.Then.Nodes, inMacro, inURL)
+		}
+		if n.Else != nil {
+			v.node(n.Else, inMacro, inURL)
+		}
+	case *ast.For:
+		v.stmt(n.Init)
+		v.expr(n.Condition)
+		v.stmt(n.Post)
+		v.nodes(n.Body, inMacro, inURL)
+	case *ast.ForIn:
+		v.declared(n.Ident)
+		v.expr(n.Expr)
+		v.nodes(n.Body, inMacro, inURL)
+	case *ast.ForRange:
+		v.stmt(n.Assignment)
+		v.nodes(n.Body, inMacro, inURL)
+	case *ast.Switch:
+		v.stmt(n.Init)
+		v.expr(n.Expr)
+		for _, c := range n.Cases {
+			v.exprs(c.Expressions)
+			v.nodes(c.Body, inMacro, inURL)
+		}
+	case *ast.TypeSwitch:
+		v.stmt(n.Init)
+		v.stmt(n.Assignment)
+		for _, c := range n.Cases {
+			v.nodes(c.Body, inMacro, inURL)
+		}
+	case *ast.Select:
+		for _, c := range n.Cases {
+			v.stmt(c.Comm)
+			v.nodes(c.Body, inMacro, inURL)
+		}
+	case *ast.Label:
+		v.node(n.Statement, inMacro, inURL)
+	case *ast.Using:
+		v.node(n.Statement, inMacro, inURL)
+		v.expr(n.Type)
+		if n.Body != nil {
+			v.nodes(n.Body.Nodes, inMacro, inURL)
+		}
+	case *ast.Extends:
+		v.file(n.Tree)
+	case *ast.Import:
+		if n.Ident == nil || n.Ident.Name != "_" {
+			v.imports = append(v.imports, vetImport{path: v.path, node: n})
+		}
+		if n.Ident != nil && n.Ident.Name != "." {
+			v.declared(n.Ident)
+		}
+		for _, ident := range n.For {
+			v.declared(ident)
+		}
+		v.file(n.Tree)
+	default:
+		v.stmt(node)
+	}
+	return false
+}
+
+// stmt visits a simple statement or a declaration. node can be nil.
+func (v *vetter) stmt(node ast.Node) {
+	switch n := node.(type) {
+	case nil:
+	case *ast.Assignment:
+		if n.Type == ast.AssignmentDeclaration {
+			for _, lh := range n.Lhs {
+				if ident, ok := lh.(*ast.Identifier); ok {
+					v.declared(ident)
+				}
+			}
+		}
+		v.exprs(n.Lhs)
+		v.exprs(n.Rhs)
+	case *ast.Var:
+		for _, ident := range n.Lhs {
+			v.declared(ident)
+		}
+		v.expr(n.Type)
+		v.exprs(n.Rhs)
+	case *ast.Const:
+		for _, ident := range n.Lhs {
+			v.declared(ident)
+		}
+		v.expr(n.Type)
+		v.exprs(n.Rhs)
+	case *ast.TypeDeclaration:
+		v.declared(n.Ident)
+		v.expr(n.Type)
+	case *ast.Defer:
+		v.expr(n.Call)
+	case *ast.Go:
+		v.expr(n.Call)
+	case *ast.Send:
+		v.expr(n.Channel)
+		v.expr(n.Value)
+	case ast.Expression:
+		v.expr(n)
+	}
+}
+
+// exprs visits the expressions exprs.
+func (v *vetter) exprs(exprs []ast.Expression) {
+	for _, expr := range exprs {
+		v.expr(expr)
+	}
+}
+
+// expr visits the expression expr. expr can be nil.
+func (v *vetter) expr(expr ast.Expression) {
+	if expr == nil {
+		return
+	}
+	astutil.Inspect(expr, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Call:
+			v.calls = append(v.calls, vetCall{path: v.path, node: n})
+			v.expr(n.Func)
+		case *ast.Func:
+			v.function(n)
+			return false
+		case *ast.Render:
+			v.file(n.Tree)
+		}
+		return true
+	})
+}
+
+// function visits a function or macro declaration or a function literal.
+func (v *vetter) function(fn *ast.Func) {
+	if fn.Ident != nil {
+		v.declared(fn.Ident)
+	}
+	for _, params := range [][]*ast.Parameter{fn.Type.Parameters, fn.Type.Result} {
+		for _, param := range params {
+			if param.Ident != nil {
+				v.declared(param.Ident)
+			}
+			v.expr(param.Type)
+		}
+	}
+	if fn.Body != nil {
+		v.nodes(fn.Body.Nodes, fn.Type.Macro, false)
+	}
+}
+
+// declared checks the declaration of ident and reports if it shadows a
+// global.
+func (v *vetter) declared(ident *ast.Identifier) {
+	if ident == nil || ident.Name == "_" {
+		return
+	}
+	if _, ok := v.globals[ident.Name]; ok {
+		v.errorf(v.path, ident, "declaration of %s shadows a global", ident.Name)
+	}
+}
+
+// raw reports if the text of a raw statement contains template syntax.
+func (v *vetter) raw(raw *ast.Raw) {
+	if raw.Text == nil {
+		return
+	}
+	text := raw.Text.Text
+	for i := 0; i+1 < len(text); i++ {
+		if text[i] != '{' || text[i+1] != '{' && text[i+1] != '%' && text[i+1] != '#' {
+			continue
+		}
+		pos := *raw.Text.Position
+		for j := 0; j < i; {
+			r, size := utf8.DecodeRune(text[j:])
+			if r == '\n' {
+				pos.Line++
+				pos.Column = 1
+			} else {
+				pos.Column++
+			}
+			j += size
+		}
+		pos.Start += i
+		pos.End = pos.Start + 1
+		v.errorf(v.path, &pos, "template syntax %s in raw statement is not executed", text[i:i+2])
+		return
+	}
+}
+
+// check reports the problems that depend on the information recorded by
+// the type checker.
+func (v *vetter) check(info *packageInfo) {
+
+	// Report the macros declared and not used. An exported macro is reported
+	// only if it is declared in a file that extends another file, because
+	// otherwise it could be used by a file that imports its file.
+	used := map[*ast.Identifier]bool{}
+	for _, decl := range info.Uses {
+		used[decl] = true
+	}
+	for ident, macro := range v.macros {
+		if !used[ident] && (macro.extending || !isExported(ident.Name)) {
+			v.errorf(macro.path, ident, "macro %s declared but not used", ident.Name)
+		}
+	}
+
+	// Report the imports whose names are not used.
+	for _, im := range v.imports {
+		if !info.UsedImports[im.node] {
+			if im.node.Ident == nil || im.node.Ident.Name == "." {
+				v.errorf(im.path, im.node, "imported and not used: %q", im.node.Path)
+			} else {
+				v.errorf(im.path, im.node, "imported and not used: %q as %s", im.node.Path, im.node.Ident)
+			}
+		}
+	}
+
+	// Report the show of values rendered as nothing and the URLs built by
+	// string concatenation.
+	for _, show := range v.shows {
+		for _, expr := range show.node.Expressions {
+			ti, ok := info.TypeInfos[expr]
+			if !ok {
+				continue
+			}
+			if c, ok := ti.Constant.(stringConst); ok && c == "" {
+				v.errorf(show.path, expr, "show of %s renders nothing", expr)
+				continue
+			}
+			if call, ok := expr.(*ast.Call); ok {
+				decl := info.Uses[call.Func]
+				if macro, ok := v.macros[decl]; ok && macro.empty {
+					v.errorf(show.path, expr, "show of %s renders nothing, macro %s has an empty body", expr, decl.Name)
+					continue
+				}
+			}
+			if show.inURL && isStringConcatenation(expr, info.TypeInfos) {
+				v.errorf(show.path, expr, "URL built by string concatenation, show its parts separately to escape them")
+			}
+		}
+	}
+
+	// Report the calls to sprintf whose result contains %!verb(...).
+	for _, call := range v.calls {
+		v.sprintf(call, info.TypeInfos)
+	}
+
+}
+
+// sprintf checks a call to the global sprintf and reports if the format
+// does not match the arguments, and then the result contains %!verb(...).
+func (v *vetter) sprintf(call vetCall, typeInfos map[ast.Node]*typeInfo) {
+	ident, ok := call.node.Func.(*ast.Identifier)
+	if !ok || ident.Name != "sprintf" || call.node.IsVariadic || len(call.node.Args) == 0 {
+		return
+	}
+	if ti, ok := typeInfos[ident]; !ok || !ti.Global() || ti.Type != sprintfType {
+		return
+	}
+	ti, ok := typeInfos[call.node.Args[0]]
+	if !ok {
+		return
+	}
+	format, ok := ti.Constant.(stringConst)
+	if !ok {
+		return
+	}
+	args := len(call.node.Args) - 1
+	n := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		start := i
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+		// Explicit argument indexes are not checked.
+		if i < len(format) && format[i] == '[' {
+			return
+		}
+		for j := 0; j < 2; j++ {
+			if i < len(format) && format[i] == '*' {
+				n++
+				i++
+			} else {
+				for i < len(format) && '0' <= format[i] && format[i] <= '9' {
+					i++
+				}
+			}
+			if j == 0 && i < len(format) && format[i] == '.' {
+				i++
+			} else {
+				break
+			}
+		}
+		if i == len(format) {
+			v.errorf(call.path, call.node, "sprintf format %s is missing verb at end of string", format[start:])
+			return
+		}
+		verb, size := utf8.DecodeRuneInString(string(format[i:]))
+		i += size - 1
+		if verb == '%' {
+			continue
+		}
+		if !strings.ContainsRune("bcdeEfFgGopqstTUvxX", verb) {
+			v.errorf(call.path, call.node, "sprintf format %s has unknown verb %c", format[start:i+1], verb)
+			return
+		}
+		n++
+		if n > args {
+			v.errorf(call.path, call.node, "sprintf format %s reads arg #%d, but call has %s", format[start:i+1], n, countArgs(args))
+			return
+		}
+	}
+	if n < args {
+		v.errorf(call.path, call.node, "sprintf call needs %s but has %s", countArgs(n), countArgs(args))
+	}
+}
+
+// sprintfType is the type of the sprintf builtin.
+var sprintfType = reflect.TypeOf(func(string, ...interface{}) string { return "" })
+
+// countArgs returns "1 arg" if n is 1, otherwise "n args".
+func countArgs(n int) string {
+	if n == 1 {
+		return "1 arg"
+	}
+	return fmt.Sprintf("%d args", n)
+}
+
+// isBlankNode reports whether node is a comment or a text with only spaces.
+func isBlankNode(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.Comment:
+		return true
+	case *ast.Text:
+		return len(bytes.TrimSpace(n.Text)) == 0
+	}
+	return false
+}
+
+// isEmptyBody reports whether the body of a macro renders nothing.
+func isEmptyBody(body *ast.Block) bool {
+	for _, node := range body.Nodes {
+		if _, ok := node.(*ast.Comment); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// isStringConcatenation reports whether expr is a concatenation of strings
+// with non-constant operands.
+func isStringConcatenation(expr ast.Expression, typeInfos map[ast.Node]*typeInfo) bool {
+	op, ok := expr.(*ast.BinaryOperator)
+	if !ok || op.Op != ast.OperatorAddition {
+		return false
+	}
+	ti, ok := typeInfos[op]
+	return ok && !ti.IsConstant() && ti.Type != nil && ti.Type.Kind() == reflect.String
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package compiler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/open2b/scriggo/internal/fstest"
+	"github.com/open2b/scriggo/native"
+)
+
+var vetTemplateTests = []struct {
+	files    fstest.Files
+	expected []string
+}{
+	// Unused macros.
+	{fstest.Files{"index.html": `{% macro m %}{% end %}{% macro M %}{% end %}`},
+		[]string{"index.html:1:10: macro m declared but not used"}},
+	{fstest.Files{"index.html": `{% macro m %}a{% end %}{{ m() }}`}, nil},
+	{fstest.Files{"index.html": `{% macro m %}{% m() %}{% end %}`}, nil},
+	{fstest.Files{"index.html": `{% extends "layout.html" %}{% macro Title %}a{% end %}{% macro Body %}b{% end %}`,
+		"layout.html": `{{ Title() }}`},
+		[]string{"index.html:1:64: macro Body declared but not used"}},
+	{fstest.Files{"index.html": `{% import "imp.html" %}{{ M() }}`,
+		"imp.html": `{% macro M %}{{ m() }}{% end %}{% macro m %}a{% end %}{% macro n %}b{% end %}{% macro N %}c{% end %}`},
+		[]string{"imp.html:1:64: macro n declared but not used"}},
+
+	// Unused imports.
+	{fstest.Files{"index.html": `{% import "imp.html" %}{% import i "imp.html" %}{% import _ "imp.html" %}`,
+		"imp.html": `{% macro M %}a{% end %}`},
+		[]string{`index.html:1:11: imported and not used: "imp.html"`, `index.html:1:34: imported and not used: "imp.html" as i`}},
+	{fstest.Files{"index.html": `{% import i "imp.html" %}{% import "imp.html" for M %}{{ i.M() }}{{ M() }}`,
+		"imp.html": `{% macro M %}a{% end %}`}, nil},
+
+	// Shadowed globals.
+	{fstest.Files{"index.html": `{% var title = "a" %}{% for _, sprintf := range []int{} %}{% end %}{% macro M(title string) %}{% end %}`},
+		[]string{"index.html:1:8: declaration of title shadows a global",
+			"index.html:1:32: declaration of sprintf shadows a global",
+			"index.html:1:79: declaration of title shadows a global"}},
+
+	// Show of values rendered as %!verb(...) or as nothing.
+	{fstest.Files{"index.html": `{{ "" }}{{ sprintf("%d") }}{{ sprintf("%s", 1, 2) }}{{ sprintf("%z", 1) }}{{ sprintf("%5.*f%%", 2, 3.0) }}`},
+		[]string{`index.html:1:4: show of "" renders nothing`,
+			`index.html:1:19: sprintf format %d reads arg #1, but call has 0 args`,
+			`index.html:1:38: sprintf call needs 1 arg but has 2 args`,
+			`index.html:1:63: sprintf format %z has unknown verb z`}},
+	{fstest.Files{"index.html": `{% macro M %}{# nothing #}{% end %}{{ M() }}`},
+		[]string{`index.html:1:40: show of M() renders nothing, macro M has an empty body`}},
+
+	// Unreachable code.
+	{fstest.Files{"index.html": "{% macro M %}a{% return %}\n{# c #}b{% if true %}{% end %}{% end %}{{ M() }}"},
+		[]string{"index.html:2:8: unreachable code"}},
+	{fstest.Files{"index.html": "{% macro M %}{% if true %}{% return %}{% end %}a{% end %}{{ M() }}"}, nil},
+
+	// URLs built by string concatenation.
+	{fstest.Files{"index.html": `<a href="{{ "/search?q=" + title }}">{{ "a" + title }}</a><a href="/search?q={{ title }}">`},
+		[]string{"index.html:1:26: URL built by string concatenation, show its parts separately to escape them"}},
+
+	// Raw statements with template syntax.
+	{fstest.Files{"index.html": "{% raw %}a\n  b {{ c }}{% end raw %}"},
+		[]string{"index.html:2:5: template syntax {{ in raw statement is not executed"}},
+}
+
+func TestVetTemplate(t *testing.T) {
+	title := "title"
+	options := Options{
+		FormatTypes: formatTypes,
+		Globals: native.Declarations{
+			"sprintf": fmt.Sprintf,
+			"title":   &title,
+		},
+	}
+	for _, test := range vetTemplateTests {
+		t.Run(test.files["index.html"], func(t *testing.T) {
+			errs, err := VetTemplate(test.files, "index.html", options)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(errs) != len(test.expected) {
+				t.Fatalf("expecting %d problems, got %d: %v", len(test.expected), len(errs), errs)
+			}
+			for i, err := range errs {
+				if err.Error() != test.expected[i] {
+					t.Errorf("expecting %q, got %q", test.expected[i], err)
+				}
+			}
+		})
+	}
+}
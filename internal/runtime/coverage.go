@@ -0,0 +1,28 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// CoverBlock is a block of code whose executions are counted by a Cover
+// instruction when the code is compiled for coverage. Lines and columns
+// start from 1 and columns are in bytes, as in a Go coverage profile. The
+// end position is the position immediately after the last byte of the
+// block.
+type CoverBlock struct {
+	Path      string // path of the file where the block is located in.
+	StartLine int
+	StartCol  int
+	EndLine   int
+	EndCol    int
+	NumStmt   int // number of statements in the block.
+}
+
+// SetCoverage sets the counters of the coverage blocks. The Cover instruction
+// of the block with index i increments counters[i]. counters are shared with
+// the goroutines started by the execution and are incremented atomically.
+//
+// SetCoverage must not be called after vm has been started.
+func (vm *VM) SetCoverage(counters []uint32) {
+	vm.env.coverage = counters
+}
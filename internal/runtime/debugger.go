@@ -0,0 +1,220 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import (
+	"reflect"
+)
+
+// A Debugger controls the execution of a virtual machine. It is set with the
+// SetDebugger method of VM.
+type Debugger interface {
+
+	// Breakpoint reports whether the execution must stop before executing the
+	// statement at the given line of the file with the given path. It is
+	// called only when the execution reaches a new line.
+	Breakpoint(path string, line int) bool
+
+	// Stop is called when the execution stops. The execution is suspended
+	// until Stop returns and then it is resumed as indicated by the returned
+	// step mode.
+	Stop(state *DebugState) StepMode
+}
+
+// StepMode indicates how the execution is resumed after a stop.
+type StepMode int8
+
+const (
+	StepContinue StepMode = iota // continue until the next breakpoint.
+	StepIn                       // stop at the next line, also in a called function.
+	StepOver                     // stop at the next line of the current function.
+	StepOut                      // stop at the next line of the calling function.
+)
+
+// Local represents a local variable of a function. It is used by debuggers
+// to read the value of the variable by its name.
+type Local struct {
+	Name  string
+	Type  reflect.Type
+	Reg   int16 // register; negative if the variable is indirect.
+	Start Addr  // address of the first instruction where the variable is in scope.
+	End   Addr  // address of the first instruction where the variable is out of scope.
+}
+
+// debugger holds the state of a debugger during the execution.
+type debugger struct {
+	Debugger
+	mode      StepMode  // current step mode.
+	stepDepth int       // call depth when the current step has been started.
+	fn        *Function // function of the last executed statement.
+	depth     int       // call depth of the last executed statement.
+	line      int       // line of the last executed statement.
+}
+
+// SetDebugger sets the debugger. Only the main goroutine is debugged.
+//
+// SetDebugger must not be called after vm has been started.
+func (vm *VM) SetDebugger(d Debugger) {
+	if d == nil {
+		vm.debug = nil
+		return
+	}
+	vm.debug = &debugger{Debugger: d}
+}
+
+// debugStatement is called by the run method, when a debugger is set, before
+// executing the instruction at vm.pc. If the instruction is the first of a
+// statement on a new line, it stops the execution if it is required by the
+// step mode or by a breakpoint.
+func (vm *VM) debugStatement() {
+	info, ok := vm.fn.DebugInfo[vm.pc]
+	if !ok || !info.Statement {
+		return
+	}
+	d := vm.debug
+	depth := len(vm.calls)
+	line := info.Position.Line
+	if vm.fn == d.fn && depth == d.depth && line == d.line {
+		return
+	}
+	d.fn, d.depth, d.line = vm.fn, depth, line
+	var stop bool
+	switch d.mode {
+	case StepIn:
+		stop = true
+	case StepOver:
+		stop = depth <= d.stepDepth
+	case StepOut:
+		stop = depth < d.stepDepth
+	}
+	breakpoint := false
+	if !stop {
+		breakpoint = d.Breakpoint(info.Path, line)
+		stop = breakpoint
+	}
+	if stop {
+		d.mode = d.Stop(&DebugState{vm: vm, breakpoint: breakpoint})
+		d.stepDepth = depth
+	}
+}
+
+// DebugState represents the state of a virtual machine stopped by a debugger.
+// It is valid only until the Stop method of the debugger returns.
+type DebugState struct {
+	vm         *VM
+	breakpoint bool
+}
+
+// Breakpoint reports whether the execution is stopped at a breakpoint.
+func (s *DebugState) Breakpoint() bool {
+	return s.breakpoint
+}
+
+// DebugFrame represents a frame of the call stack of a stopped execution.
+type DebugFrame struct {
+	Func     *Function // called function.
+	Path     string    // path of the file where the execution is in.
+	Position Position  // position in the file where the execution is in.
+	fp       [4]Addr
+	pc       Addr
+}
+
+// Frames returns the frames of the call stack, starting from the frame of the
+// running function. Frames of native functions are not returned.
+func (s *DebugState) Frames() []DebugFrame {
+	vm := s.vm
+	frames := []DebugFrame{{Func: vm.fn, fp: vm.fp, pc: vm.pc}}
+	for i := len(vm.calls) - 1; i >= 0; i-- {
+		call := vm.calls[i]
+		if call.cl.fn == nil || call.status == panicked {
+			continue
+		}
+		frame := DebugFrame{Func: call.cl.fn, fp: call.fp}
+		if call.status == tailed {
+			frame.pc = call.pc - 1
+		} else {
+			frame.pc = call.pc - 2
+		}
+		frames = append(frames, frame)
+	}
+	for i, frame := range frames {
+		info := frame.Func.DebugInfo[frame.pc]
+		frames[i].Path = info.Path
+		if frames[i].Path == "" {
+			frames[i].Path = frame.Func.File
+		}
+		frames[i].Position = info.Position
+	}
+	return frames
+}
+
+// Locals returns the local variables in scope in the frame, with their
+// values. If more variables have the same name, only the innermost is
+// returned.
+func (s *DebugState) Locals(frame DebugFrame) ([]Local, []reflect.Value) {
+	var locals []Local
+	for _, local := range frame.Func.Locals {
+		if frame.pc < local.Start || frame.pc >= local.End {
+			continue
+		}
+		shadowed := false
+		for i, l := range locals {
+			if l.Name == local.Name {
+				locals[i] = local
+				shadowed = true
+				break
+			}
+		}
+		if !shadowed {
+			locals = append(locals, local)
+		}
+	}
+	values := make([]reflect.Value, len(locals))
+	for i, local := range locals {
+		values[i] = s.localValue(frame.fp, local)
+	}
+	return locals, values
+}
+
+// Globals returns the values of the global variables.
+func (s *DebugState) Globals() []reflect.Value {
+	return s.vm.env.globals
+}
+
+// localValue returns the value of the local variable l in the frame with
+// frame pointers fp.
+func (s *DebugState) localValue(fp [4]Addr, l Local) reflect.Value {
+	regs := &s.vm.regs
+	t := l.Type
+	if st, ok := t.(ScriggoType); ok {
+		t = st.GoType()
+	}
+	if l.Reg < 0 {
+		v := regs.general[fp[3]+Addr(-l.Reg)]
+		if !v.IsValid() || v.IsNil() {
+			return reflect.Zero(t)
+		}
+		return v.Elem()
+	}
+	r := Addr(l.Reg)
+	v := reflect.New(t).Elem()
+	switch k := t.Kind(); {
+	case k == reflect.Bool:
+		v.SetBool(regs.int[fp[0]+r] > 0)
+	case reflect.Int <= k && k <= reflect.Int64:
+		v.SetInt(regs.int[fp[0]+r])
+	case reflect.Uint <= k && k <= reflect.Uintptr:
+		v.SetUint(uint64(regs.int[fp[0]+r]))
+	case k == reflect.Float32 || k == reflect.Float64:
+		v.SetFloat(regs.float[fp[1]+r])
+	case k == reflect.String:
+		v.SetString(regs.string[fp[2]+r])
+	default:
+		if g := regs.general[fp[3]+r]; g.IsValid() && g.Type().AssignableTo(t) {
+			v.Set(g)
+		}
+	}
+	return v
+}
@@ -8,6 +8,8 @@ import (
 	"context"
 	"reflect"
 	"sync"
+
+	"github.com/open2b/scriggo/native"
 )
 
 type PrintFunc func(interface{})
@@ -15,10 +17,13 @@ type PrintFunc func(interface{})
 // Context represents a context in Show and Text instructions.
 type Context byte
 
-// The env type implements the native.Env interface.
+// The env type implements the native.RenderEnv interface.
 type env struct {
 	ctx     context.Context // context.
 	globals []reflect.Value // global variables.
+	nonce   string          // nonce of the script and style elements.
+	locale  string          // locale.
+	catalog native.Catalog  // message catalog.
 	print   PrintFunc       // custom print builtin.
 	typeof  TypeOfFunc      // typeof function.
 
@@ -26,10 +31,15 @@ type env struct {
 	doneChan <-chan struct{}
 	doneCase reflect.SelectCase
 
-	// Only the callPath field can be changed after the vm has been started
-	// and access to this field must be done with this mutex.
+	limits *limits // execution limits; nil if there are no limits.
+
+	coverage []uint32 // coverage counters; nil if the coverage is not collected.
+
+	// Only the callPath and renderer fields can be changed after the vm has
+	// been started and access to these fields must be done with this mutex.
 	mu       sync.Mutex
-	callPath string // path of the file where the main goroutine is in.
+	callPath string    // path of the file where the main goroutine is in.
+	renderer *renderer // renderer of the main goroutine.
 }
 
 func (env *env) CallPath() string {
@@ -39,6 +49,10 @@ func (env *env) CallPath() string {
 	return callPath
 }
 
+func (env *env) Catalog() native.Catalog {
+	return env.catalog
+}
+
 func (env *env) Context() context.Context {
 	return env.ctx
 }
@@ -47,6 +61,27 @@ func (env *env) Fatal(v interface{}) {
 	panic(&fatalError{env: env, msg: v})
 }
 
+func (env *env) Flush() {
+	env.mu.Lock()
+	r := env.renderer
+	env.mu.Unlock()
+	if r == nil {
+		return
+	}
+	err := r.Flush()
+	if err != nil {
+		panic(outError{err})
+	}
+}
+
+func (env *env) Locale() string {
+	return env.locale
+}
+
+func (env *env) Nonce() string {
+	return env.nonce
+}
+
 func (env *env) Print(args ...interface{}) {
 	for _, arg := range args {
 		env.doPrint(arg)
@@ -104,3 +139,13 @@ func (env *env) doPrint(arg interface{}) {
 		print(r.String())
 	}
 }
+
+// goroutineEnv is the execution environment passed to the native functions
+// called in a goroutine started by the go statement.
+type goroutineEnv struct {
+	*env
+}
+
+// Flush does nothing. Only the main goroutine writes to the output, so it
+// is the only one that can flush it.
+func (env goroutineEnv) Flush() {}
@@ -118,6 +118,8 @@ func (vm *VM) convertPanic(msg interface{}) error {
 	switch err := msg.(type) {
 	case stopError:
 		return err
+	case exceededLimit:
+		return vm.newLimitError(string(err))
 	case outError:
 		return vm.newPanic(err)
 	}
@@ -398,6 +400,13 @@ func missingMethod(typ reflect.Type, iface reflect.Type) string {
 	num := iface.NumMethod()
 	for i := 0; i < num; i++ {
 		mi := iface.Method(i)
+		if st, ok := typ.(ScriggoType); ok {
+			m, ok := st.ScriggoMethod(mi.Name)
+			if !ok || m.Type != mi.Type {
+				return mi.Name
+			}
+			continue
+		}
 		mt, ok := typ.MethodByName(mi.Name)
 		if !ok {
 			return mi.Name
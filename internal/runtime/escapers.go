@@ -8,6 +8,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"strings"
+	"unicode/utf8"
 )
 
 const hexchars = "0123456789abcdef"
@@ -638,3 +639,402 @@ func markdownCodeBlockEscape(w strWriter, s string, spaces bool) error {
 	}
 	return nil
 }
+
+// xmlEscape escapes the string s, so it can be placed inside XML, and writes
+// it on w. attr indicates if s is placed in an attribute value, so also tabs
+// and newlines are escaped, and escapeEntities indicates if the ampersand
+// character has to be escaped. Characters not allowed in XML are replaced
+// with the Unicode replacement character.
+func xmlEscape(w strWriter, s string, attr, escapeEntities bool) error {
+	last := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		size := 1
+		var esc string
+		switch c {
+		case '"':
+			esc = "&#34;"
+		case '\'':
+			esc = "&#39;"
+		case '&':
+			if escapeEntities {
+				esc = "&amp;"
+			}
+		case '<':
+			esc = "&lt;"
+		case '>':
+			esc = "&gt;"
+		case '\t':
+			if attr {
+				esc = "&#9;"
+			}
+		case '\n':
+			if attr {
+				esc = "&#10;"
+			}
+		case '\r':
+			esc = "&#13;"
+		default:
+			if c < 0x20 {
+				esc = "\uFFFD"
+			} else if c >= utf8.RuneSelf {
+				var r rune
+				r, size = utf8.DecodeRuneInString(s[i:])
+				if !isXMLChar(r, size) {
+					esc = "\uFFFD"
+				}
+			}
+		}
+		if esc == "" {
+			i += size
+			continue
+		}
+		if last != i {
+			_, err := w.WriteString(s[last:i])
+			if err != nil {
+				return err
+			}
+		}
+		_, err := w.WriteString(esc)
+		if err != nil {
+			return err
+		}
+		i += size
+		last = i
+	}
+	if last != len(s) {
+		_, err := w.WriteString(s[last:])
+		return err
+	}
+	return nil
+}
+
+// cdataEscape escapes the string s, so it can be placed inside an XML CDATA
+// section, and writes it on w. The "]]>" sequences are split between two
+// CDATA sections and the characters not allowed in XML are replaced with the
+// Unicode replacement character.
+func cdataEscape(w strWriter, s string) error {
+	last := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		size := 1
+		var esc string
+		switch {
+		case c == ']':
+			if strings.HasPrefix(s[i:], "]]>") {
+				esc = "]]]]><![CDATA[>"
+				size = 3
+			}
+		case c < 0x20:
+			if c != '\t' && c != '\n' && c != '\r' {
+				esc = "\uFFFD"
+			}
+		case c >= utf8.RuneSelf:
+			var r rune
+			r, size = utf8.DecodeRuneInString(s[i:])
+			if !isXMLChar(r, size) {
+				esc = "\uFFFD"
+			}
+		}
+		if esc == "" {
+			i += size
+			continue
+		}
+		if last != i {
+			_, err := w.WriteString(s[last:i])
+			if err != nil {
+				return err
+			}
+		}
+		_, err := w.WriteString(esc)
+		if err != nil {
+			return err
+		}
+		i += size
+		last = i
+	}
+	if last != len(s) {
+		_, err := w.WriteString(s[last:])
+		return err
+	}
+	return nil
+}
+
+// isXMLChar reports whether the non-ASCII rune r, decoded from size bytes, is
+// a character allowed in XML.
+//
+// See https://www.w3.org/TR/xml/#charsets.
+func isXMLChar(r rune, size int) bool {
+	if r == utf8.RuneError {
+		return size > 1
+	}
+	return r <= 0xD7FF || 0xE000 <= r && r <= 0xFFFD || 0x10000 <= r && r <= 0x10FFFF
+}
+
+// yamlStringEscape escapes the string s so it can be placed within a YAML
+// double-quoted scalar, and writes it to w. Line breaks and non-printable
+// characters are escaped and invalid UTF-8 bytes are replaced with the
+// escaped Unicode replacement character.
+func yamlStringEscape(w strWriter, s string) error {
+	last := 0
+	for i := 0; i < len(s); {
+		c, size := utf8.DecodeRuneInString(s[i:])
+		var esc string
+		switch c {
+		case '"':
+			esc = `\"`
+		case '\\':
+			esc = `\\`
+		case '\b':
+			esc = `\b`
+		case '\t':
+			esc = `\t`
+		case '\n':
+			esc = `\n`
+		case '\f':
+			esc = `\f`
+		case '\r':
+			esc = `\r`
+		case 0x85, '\u2028', '\u2029', '\ufeff':
+			esc = unicodeEscape(c)
+		default:
+			if 0x20 <= c && c != 0x7f && isYAMLPrintable(c, size) {
+				i += size
+				continue
+			}
+			if c == utf8.RuneError {
+				esc = `\ufffd`
+			} else {
+				esc = unicodeEscape(c)
+			}
+		}
+		if last != i {
+			_, err := w.WriteString(s[last:i])
+			if err != nil {
+				return err
+			}
+		}
+		_, err := w.WriteString(esc)
+		if err != nil {
+			return err
+		}
+		i += size
+		last = i
+	}
+	if last != len(s) {
+		_, err := w.WriteString(s[last:])
+		return err
+	}
+	return nil
+}
+
+// tomlStringEscape escapes the string s so it can be placed within a TOML
+// basic string, and writes it to w. Control characters are escaped and
+// invalid UTF-8 bytes are replaced with the escaped Unicode replacement
+// character.
+func tomlStringEscape(w strWriter, s string) error {
+	last := 0
+	for i := 0; i < len(s); {
+		c, size := utf8.DecodeRuneInString(s[i:])
+		var esc string
+		switch c {
+		case '"':
+			esc = `\"`
+		case '\\':
+			esc = `\\`
+		case '\b':
+			esc = `\b`
+		case '\t':
+			esc = `\t`
+		case '\n':
+			esc = `\n`
+		case '\f':
+			esc = `\f`
+		case '\r':
+			esc = `\r`
+		default:
+			if c < 0x20 || c == 0x7f {
+				esc = unicodeEscape(c)
+			} else if c == utf8.RuneError && size == 1 {
+				esc = `\ufffd`
+			} else {
+				i += size
+				continue
+			}
+		}
+		if last != i {
+			_, err := w.WriteString(s[last:i])
+			if err != nil {
+				return err
+			}
+		}
+		_, err := w.WriteString(esc)
+		if err != nil {
+			return err
+		}
+		i += size
+		last = i
+	}
+	if last != len(s) {
+		_, err := w.WriteString(s[last:])
+		return err
+	}
+	return nil
+}
+
+// yamlPlainEscape writes the string s to w so it can be placed within a YAML
+// plain scalar. As a plain scalar cannot contain escape sequences, it returns
+// an error if s contains line breaks, non-printable characters or sequences
+// that would end the scalar.
+func yamlPlainEscape(w strWriter, s string) error {
+	return yamlPlainEscapeIn(w, s, false)
+}
+
+// yamlFlowPlainEscape writes the string s to w so it can be placed within a
+// YAML plain scalar in a flow collection. As yamlPlainEscape, it returns an
+// error if s contains line breaks, non-printable characters or sequences
+// that would end the scalar, and also if s contains a flow indicator, that
+// is one of ',', '[', ']', '{' and '}', that would change the structure of
+// the collection.
+func yamlFlowPlainEscape(w strWriter, s string) error {
+	return yamlPlainEscapeIn(w, s, true)
+}
+
+// yamlPlainEscapeIn implements yamlPlainEscape and yamlFlowPlainEscape.
+// inFlow reports whether the scalar is in a flow collection.
+func yamlPlainEscapeIn(w strWriter, s string, inFlow bool) error {
+	scalar := "YAML plain scalar"
+	if inFlow {
+		scalar = "YAML flow plain scalar"
+	}
+	for i := 0; i < len(s); {
+		c, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case c == '\n' || c == '\r' || c == 0x85 || c == '\u2028' || c == '\u2029':
+			return errors.New("cannot show a line break in a " + scalar)
+		case inFlow && (c == ',' || c == '[' || c == ']' || c == '{' || c == '}'):
+			return errors.New(`cannot show "` + string(c) + `" in a ` + scalar)
+		case c == ':' && (i+1 == len(s) || s[i+1] == ' ' || s[i+1] == '\t'):
+			return errors.New(`cannot show ": " in a ` + scalar)
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return errors.New(`cannot show " #" in a ` + scalar)
+		case c != '\t' && !isYAMLPrintable(c, size):
+			return errors.New("cannot show a non-printable character in a " + scalar)
+		}
+		i += size
+	}
+	_, err := w.WriteString(s)
+	return err
+}
+
+// yamlSingleQuotedEscape escapes the string s so it can be placed within a
+// YAML single-quoted scalar, and writes it to w. It returns an error if s
+// contains line breaks or non-printable characters.
+func yamlSingleQuotedEscape(w strWriter, s string) error {
+	last := 0
+	for i := 0; i < len(s); {
+		c, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case c == '\n' || c == '\r' || c == 0x85 || c == '\u2028' || c == '\u2029':
+			return errors.New("cannot show a line break in a YAML single-quoted scalar")
+		case c != '\t' && !isYAMLPrintable(c, size):
+			return errors.New("cannot show a non-printable character in a YAML single-quoted scalar")
+		}
+		i += size
+		if c == '\'' {
+			_, err := w.WriteString(s[last:i])
+			if err != nil {
+				return err
+			}
+			last = i - 1
+		}
+	}
+	if last != len(s) {
+		_, err := w.WriteString(s[last:])
+		return err
+	}
+	return nil
+}
+
+// yamlBlockEscape writes the string s to w so it can be placed within a YAML
+// block scalar, indenting each line after the first with indent spaces. It
+// returns an error if s contains non-printable characters.
+func yamlBlockEscape(w strWriter, s string, indent int) error {
+	last := 0
+	for i := 0; i < len(s); {
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c != '\n' && c != '\r' {
+			if c != '\t' && !isYAMLPrintable(c, size) {
+				return errors.New("cannot show a non-printable character in a YAML block scalar")
+			}
+			i += size
+			continue
+		}
+		_, err := w.WriteString(s[last:i])
+		if err == nil {
+			_, err = w.WriteString("\n")
+		}
+		for j := 0; j < indent && err == nil; j++ {
+			_, err = w.WriteString(" ")
+		}
+		if err != nil {
+			return err
+		}
+		i++
+		if c == '\r' && i < len(s) && s[i] == '\n' {
+			i++
+		}
+		last = i
+	}
+	if last != len(s) {
+		_, err := w.WriteString(s[last:])
+		return err
+	}
+	return nil
+}
+
+// tomlLiteralStringEscape writes the string s to w so it can be placed within
+// a TOML literal string. As a literal string cannot contain escape sequences,
+// it returns an error if s contains single quotes, line breaks or control
+// characters other than tab.
+func tomlLiteralStringEscape(w strWriter, s string) error {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'':
+			return errors.New("cannot show ' in a TOML literal string")
+		case c == '\n' || c == '\r':
+			return errors.New("cannot show a line break in a TOML literal string")
+		case c < 0x20 && c != '\t' || c == 0x7f:
+			return errors.New("cannot show a control character in a TOML literal string")
+		}
+	}
+	if !utf8.ValidString(s) {
+		return errors.New("cannot show invalid UTF-8 in a TOML literal string")
+	}
+	_, err := w.WriteString(s)
+	return err
+}
+
+// isYAMLPrintable reports whether the rune r, decoded from size bytes, is a
+// printable character in YAML.
+//
+// See https://yaml.org/spec/1.2.2/#51-character-set.
+func isYAMLPrintable(r rune, size int) bool {
+	switch {
+	case r == utf8.RuneError:
+		return size > 1
+	case r < 0x20:
+		return r == '\t' || r == '\n' || r == '\r'
+	case r < 0x7f:
+		return true
+	case r < 0xa0:
+		return r == 0x85
+	}
+	return r <= 0xD7FF || 0xE000 <= r && r <= 0xFFFD || 0x10000 <= r && r <= 0x10FFFF
+}
+
+// unicodeEscape returns the \uXXXX escape sequence of the rune r, that must
+// be in the Basic Multilingual Plane.
+func unicodeEscape(r rune) string {
+	return string([]byte{'\\', 'u', hexchars[r>>12&0xf], hexchars[r>>8&0xf], hexchars[r>>4&0xf], hexchars[r&0xf]})
+}
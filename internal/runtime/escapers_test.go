@@ -72,3 +72,143 @@ func TestJSStringEscape(t *testing.T) {
 		t.Errorf("unexpected %q, expecting %q\n", b.String(), s)
 	}
 }
+
+var xmlEscapeCases = []struct {
+	src            string
+	attr           bool
+	escapeEntities bool
+	expected       string
+}{
+	{``, false, true, ``},
+	{`a`, false, true, `a`},
+	{`<a b="c">'&'</a>`, false, true, `&lt;a b=&#34;c&#34;&gt;&#39;&amp;&#39;&lt;/a&gt;`},
+	{`&amp; &`, false, false, `&amp; &`},
+	{"a\tb\nc\rd", false, true, "a\tb\nc&#13;d"},
+	{"a\tb\nc\rd", true, true, "a&#9;b&#10;c&#13;d"},
+	{"a\x00b\x1fc", false, true, "a\uFFFDb\uFFFDc"},
+	{"a\xffb", false, true, "a\uFFFDb"},
+	{"a\uFFFEb\uFFFFc", false, true, "a\uFFFDb\uFFFDc"},
+	{"è\uFFFD\U0001F600", false, true, "è\uFFFD\U0001F600"},
+}
+
+func TestXMLEscape(t *testing.T) {
+	for _, cas := range xmlEscapeCases {
+		out := &strings.Builder{}
+		err := xmlEscape(out, cas.src, cas.attr, cas.escapeEntities)
+		if err != nil {
+			t.Fatalf("escape error: %s", err)
+		}
+		if out.String() != cas.expected {
+			t.Fatalf("src: %q: expecting %q, got %q", cas.src, cas.expected, out.String())
+		}
+	}
+}
+
+var cdataEscapeCases = []struct {
+	src      string
+	expected string
+}{
+	{``, ``},
+	{`<a>&amp;</a>`, `<a>&amp;</a>`},
+	{`]]>`, `]]]]><![CDATA[>`},
+	{`a]]]>b]]>`, `a]]]]]><![CDATA[>b]]]]><![CDATA[>`},
+	{"a\tb\nc\rd", "a\tb\nc\rd"},
+	{"a\x00b\xffc", "a\uFFFDb\uFFFDc"},
+}
+
+func TestCDATAEscape(t *testing.T) {
+	for _, cas := range cdataEscapeCases {
+		out := &strings.Builder{}
+		err := cdataEscape(out, cas.src)
+		if err != nil {
+			t.Fatalf("escape error: %s", err)
+		}
+		if out.String() != cas.expected {
+			t.Fatalf("src: %q: expecting %q, got %q", cas.src, cas.expected, out.String())
+		}
+	}
+}
+
+var yamlEscapeCases = []struct {
+	src      string
+	escape   func(strWriter, string) error
+	expected string
+	err      string
+}{
+	{`a"b\c`, yamlStringEscape, `a\"b\\c`, ``},
+	{"a\tb\nc\rd\x00\x7f\u0085\u2028", yamlStringEscape, `a\tb\nc\rd\u0000\u007f\u0085\u2028`, ``},
+	{"<&'è\xff", yamlStringEscape, `<&'è\ufffd`, ``},
+	{`a b:c#d`, yamlPlainEscape, `a b:c#d`, ``},
+	{`a: b`, yamlPlainEscape, ``, `cannot show ": " in a YAML plain scalar`},
+	{`a:`, yamlPlainEscape, ``, `cannot show ": " in a YAML plain scalar`},
+	{`a #b`, yamlPlainEscape, ``, `cannot show " #" in a YAML plain scalar`},
+	{`#a`, yamlPlainEscape, ``, `cannot show " #" in a YAML plain scalar`},
+	{"a\nb", yamlPlainEscape, ``, `cannot show a line break in a YAML plain scalar`},
+	{`a, [b] {c}`, yamlPlainEscape, `a, [b] {c}`, ``},
+	{`a b:c#d`, yamlFlowPlainEscape, `a b:c#d`, ``},
+	{`b, c, d`, yamlFlowPlainEscape, ``, `cannot show "," in a YAML flow plain scalar`},
+	{`], evil, [x`, yamlFlowPlainEscape, ``, `cannot show "]" in a YAML flow plain scalar`},
+	{`a[b`, yamlFlowPlainEscape, ``, `cannot show "[" in a YAML flow plain scalar`},
+	{`{a`, yamlFlowPlainEscape, ``, `cannot show "{" in a YAML flow plain scalar`},
+	{`a}`, yamlFlowPlainEscape, ``, `cannot show "}" in a YAML flow plain scalar`},
+	{`a: b`, yamlFlowPlainEscape, ``, `cannot show ": " in a YAML flow plain scalar`},
+	{"a\nb", yamlFlowPlainEscape, ``, `cannot show a line break in a YAML flow plain scalar`},
+	{`it's ''`, yamlSingleQuotedEscape, `it''s ''''`, ``},
+	{"a\x00", yamlSingleQuotedEscape, ``, `cannot show a non-printable character in a YAML single-quoted scalar`},
+	{"a\r\nb", yamlSingleQuotedEscape, ``, `cannot show a line break in a YAML single-quoted scalar`},
+	{`a"b\c`, tomlStringEscape, `a\"b\\c`, ``},
+	{"a\tb\nc\rd\be\ff", tomlStringEscape, `a\tb\nc\rd\be\ff`, ``},
+	{"\x00\x01\x1b\x1f\x7f", tomlStringEscape, `\u0000\u0001\u001b\u001f\u007f`, ``},
+	{"\u0080\u0085\u2028\ufeff\ufffd\U0001F600\U0010FFFF", tomlStringEscape, "\u0080\u0085\u2028\ufeff\ufffd\U0001F600\U0010FFFF", ``},
+	{"<&'è\xff", tomlStringEscape, `<&'è\ufffd`, ``},
+	{`a"'b`, tomlLiteralStringEscape, `a"'b`, `cannot show ' in a TOML literal string`},
+	{"a\"b\\", tomlLiteralStringEscape, "a\"b\\", ``},
+	{"a\nb", tomlLiteralStringEscape, ``, `cannot show a line break in a TOML literal string`},
+}
+
+func TestYAMLEscape(t *testing.T) {
+	for _, cas := range yamlEscapeCases {
+		out := &strings.Builder{}
+		err := cas.escape(out, cas.src)
+		if err != nil {
+			if cas.err == "" {
+				t.Fatalf("src: %q: unexpected error %q", cas.src, err)
+			}
+			if err.Error() != cas.err {
+				t.Fatalf("src: %q: expecting error %q, got %q", cas.src, cas.err, err)
+			}
+			continue
+		}
+		if cas.err != "" {
+			t.Fatalf("src: %q: expecting error %q, got no error", cas.src, cas.err)
+		}
+		if out.String() != cas.expected {
+			t.Fatalf("src: %q: expecting %q, got %q", cas.src, cas.expected, out.String())
+		}
+	}
+}
+
+var yamlBlockEscapeCases = []struct {
+	src      string
+	indent   int
+	expected string
+}{
+	{``, 2, ``},
+	{`a`, 2, `a`},
+	{"a\nb", 2, "a\n  b"},
+	{"a\r\n\rb\n", 4, "a\n    \n    b\n    "},
+	{"a\tb", 0, "a\tb"},
+}
+
+func TestYAMLBlockEscape(t *testing.T) {
+	for _, cas := range yamlBlockEscapeCases {
+		out := &strings.Builder{}
+		err := yamlBlockEscape(out, cas.src, cas.indent)
+		if err != nil {
+			t.Fatalf("escape error: %s", err)
+		}
+		if out.String() != cas.expected {
+			t.Fatalf("src: %q: expecting %q, got %q", cas.src, cas.expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Names of the limits, as returned by the Limit method of LimitError.
+const (
+	InstructionsLimit = "instructions"
+	CallDepthLimit    = "call depth"
+	AllocsLimit       = "allocated bytes"
+	OutputLimit       = "output bytes"
+)
+
+// Limits are the limits of an execution. A zero value means no limit.
+type Limits struct {
+	Instructions int64 // maximum number of executed instructions.
+	CallDepth    int   // maximum depth of the call stack of a goroutine.
+	Allocs       int64 // maximum number of bytes allocated by make, append and string concatenation.
+	Output       int64 // maximum number of bytes written to the template output.
+}
+
+// limits holds the limits of an execution and the resources used. Resources
+// are shared by all the goroutines and are accessed atomically.
+type limits struct {
+	Limits
+	instructions int64
+	allocs       int64
+	output       int64
+	cancel       context.CancelFunc
+
+	mu  sync.Mutex
+	err *LimitError // first exceeded limit.
+}
+
+// exceededLimit is the panic message used to terminate the execution when a
+// limit is exceeded. The convertPanic method converts it to a *LimitError.
+type exceededLimit string
+
+// LimitError represents the error that occurs when an execution exceeds a
+// limit.
+type LimitError struct {
+	limit    string
+	path     string
+	position Position
+}
+
+func (err *LimitError) Error() string {
+	return err.path + ":" + strconv.Itoa(err.position.Line) + ":" + strconv.Itoa(err.position.Column) +
+		": " + err.limit + " limit exceeded"
+}
+
+// Limit returns the name of the exceeded limit.
+func (err *LimitError) Limit() string {
+	return err.limit
+}
+
+// Path returns the path of the file where the limit has been exceeded.
+func (err *LimitError) Path() string {
+	return err.path
+}
+
+// Position returns the position where the limit has been exceeded.
+func (err *LimitError) Position() Position {
+	return err.position
+}
+
+// SetLimits sets the limits of the execution.
+//
+// SetLimits must not be called after vm has been started.
+func (vm *VM) SetLimits(l Limits) {
+	if l == (Limits{}) {
+		vm.env.limits = nil
+		return
+	}
+	vm.env.limits = &limits{Limits: l}
+}
+
+// startLimits is called by the Run method, before starting the execution, if
+// there are limits. It makes the context cancelable, so that all goroutines
+// are terminated when a limit is exceeded, and limits the template output.
+// The returned function must be called when the execution is terminated.
+func (vm *VM) startLimits() context.CancelFunc {
+	l := vm.env.limits
+	ctx := vm.env.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, l.cancel = context.WithCancel(ctx)
+	vm.SetContext(ctx)
+	if l.Output > 0 && vm.renderer != nil {
+		vm.renderer.out = &limitedWriter{w: vm.renderer.out, l: l}
+	}
+	return l.cancel
+}
+
+// newLimitError returns a new *LimitError for the limit with the given name,
+// exceeded by the currently running instruction, and terminates the other
+// goroutines.
+func (vm *VM) newLimitError(limit string) *LimitError {
+	err := &LimitError{limit: limit, path: vm.fn.File}
+	for pc := vm.pc - 1; pc >= 0; pc-- {
+		if info, ok := vm.fn.DebugInfo[pc]; ok && info.Position.Line > 0 {
+			err.path = info.Path
+			err.position = info.Position
+			break
+		}
+	}
+	l := vm.env.limits
+	l.mu.Lock()
+	if l.err == nil {
+		l.err = err
+	}
+	l.mu.Unlock()
+	l.cancel()
+	return err
+}
+
+// limitError returns the error of the first exceeded limit, if a limit has
+// been exceeded, otherwise it returns nil.
+func (env *env) limitError() *LimitError {
+	l := env.limits
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	err := l.err
+	l.mu.Unlock()
+	return err
+}
+
+// alloc accounts the allocation of n values with the given size. It panics
+// if the allocated bytes exceed the limit.
+func (vm *VM) alloc(n int, size uintptr) {
+	if vm.profile != nil && n > 0 {
+		vm.profile.alloc(int64(n) * int64(size))
+	}
+	l := vm.env.limits
+	if l == nil || l.Allocs == 0 || n <= 0 {
+		return
+	}
+	if s := int64(size); s > 0 && int64(n) > l.Allocs/s {
+		panic(exceededLimit(AllocsLimit))
+	}
+	if atomic.AddInt64(&l.allocs, int64(n)*int64(size)) > l.Allocs {
+		panic(exceededLimit(AllocsLimit))
+	}
+}
+
+// allocAppend accounts the allocation, if any, of appending n elements to
+// the slice s. It panics if the allocated bytes exceed the limit.
+func (vm *VM) allocAppend(s reflect.Value, n int) {
+	if nl := s.Len() + n; nl > s.Cap() {
+		vm.alloc(appendCap(s.Cap(), nl), s.Type().Elem().Size())
+	}
+}
+
+// checkCallDepth panics if a call to a function exceeds the call depth limit.
+func (vm *VM) checkCallDepth() {
+	if l := vm.env.limits; l != nil && l.CallDepth > 0 && len(vm.calls)+1 >= l.CallDepth {
+		panic(exceededLimit(CallDepthLimit))
+	}
+}
+
+// limitedWriter is an io.Writer that panics if the number of bytes written
+// exceeds the output limit.
+type limitedWriter struct {
+	w io.Writer
+	l *limits
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if atomic.AddInt64(&w.l.output, int64(len(p))) > w.l.Output {
+		panic(exceededLimit(OutputLimit))
+	}
+	return w.w.Write(p)
+}
+
+func (w *limitedWriter) WriteString(s string) (int, error) {
+	if atomic.AddInt64(&w.l.output, int64(len(s))) > w.l.Output {
+		panic(exceededLimit(OutputLimit))
+	}
+	return io.WriteString(w.w, s)
+}
+
+// Flush flushes the underlying writer, if it implements a Flush method.
+func (w *limitedWriter) Flush() error {
+	return flush(w.w)
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import (
+	"reflect"
+)
+
+// Method represents a method declared in Scriggo code.
+type Method struct {
+	Name    string
+	Type    reflect.Type // type of the method, without the receiver.
+	Pointer bool         // reports whether the receiver is a pointer.
+	Func    *Function    // function with the receiver as first parameter.
+
+	// Index is the index sequence of the embedded field if the method is
+	// promoted from an embedded field. Promoted is the method of the type of
+	// the embedded field or, if the embedded field has an interface type,
+	// it is nil.
+	Index    []int
+	Promoted *Method
+}
+
+// A MethodCaller calls the methods declared in Scriggo code. It is used by
+// the proxies returned by the Wrap method of ScriggoType.
+type MethodCaller interface {
+
+	// CallMethod calls the method m with the receiver rcv and the arguments
+	// args and returns the results. If m has a value receiver and rcv is a
+	// pointer, CallMethod calls the method with the value pointed to by rcv.
+	CallMethod(m *Method, rcv reflect.Value, args []reflect.Value) []reflect.Value
+}
+
+// CallMethod implements the MethodCaller interface.
+func (env *env) CallMethod(m *Method, rcv reflect.Value, args []reflect.Value) []reflect.Value {
+	if m.Index != nil {
+		return env.callPromotedMethod(m, rcv, args)
+	}
+	if !m.Pointer && rcv.Kind() == reflect.Ptr {
+		if rcv.IsNil() {
+			panic(errNilPointer)
+		}
+		rcv = rcv.Elem()
+	}
+	in := make([]reflect.Value, len(args)+1)
+	in[0] = rcv
+	copy(in[1:], args)
+	return env.callFunc(m.Func, env.globals, nil, in)
+}
+
+// callPromotedMethod calls the method m, promoted from an embedded field,
+// with the receiver rcv and the arguments args and returns the results.
+func (env *env) callPromotedMethod(m *Method, rcv reflect.Value, args []reflect.Value) []reflect.Value {
+	for _, i := range m.Index {
+		if rcv.Kind() == reflect.Ptr {
+			if rcv.IsNil() {
+				panic(errNilPointer)
+			}
+			rcv = rcv.Elem()
+		}
+		rcv = rcv.Field(i)
+	}
+	if m.Promoted == nil {
+		// The embedded field has an interface type.
+		if rcv.IsNil() {
+			panic(errNilPointer)
+		}
+		rcv = rcv.Elem()
+		if pm, rv, ok := env.scriggoMethod(rcv, m.Name); ok {
+			return env.CallMethod(pm, rv, args)
+		}
+		return rcv.MethodByName(m.Name).Call(args)
+	}
+	if m.Promoted.Pointer && rcv.Kind() != reflect.Ptr {
+		rcv = rcv.Addr()
+	}
+	return env.CallMethod(m.Promoted, rcv, args)
+}
+
+// methodValue returns the method value of the method m bound to the
+// receiver rcv.
+func (env *env) methodValue(m *Method, rcv reflect.Value) reflect.Value {
+	typ := m.Type
+	if st, ok := typ.(ScriggoType); ok {
+		typ = st.GoType()
+	}
+	return reflect.MakeFunc(typ, func(args []reflect.Value) []reflect.Value {
+		return env.CallMethod(m, rcv, args)
+	})
+}
+
+// scriggoMethod returns the method, declared in Scriggo code, with the given
+// name of the value v, if v is a proxy of a value with a Scriggo type, and
+// the value wrapped by the proxy.
+func (env *env) scriggoMethod(v reflect.Value, name string) (*Method, reflect.Value, bool) {
+	st, ok := env.TypeOf(v).(ScriggoType)
+	if !ok {
+		return nil, reflect.Value{}, false
+	}
+	m, ok := st.ScriggoMethod(name)
+	if !ok {
+		return nil, reflect.Value{}, false
+	}
+	rv, _ := st.Unwrap(v)
+	return m, rv, true
+}
@@ -0,0 +1,358 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// Profile is a profile of an execution. For each executed instruction, it
+// records the call stack, the elapsed time and the bytes allocated by make,
+// append and string concatenation. Only the main goroutine is profiled.
+//
+// A Profile is set with the SetProfile method of VM and, when the execution
+// is terminated, it can be written in the pprof format with its Write
+// method.
+type Profile struct {
+	start     time.Time                  // start time of the execution.
+	end       time.Time                  // end time of the execution.
+	last      time.Time                  // time of the last executed instruction.
+	current   *profileSample             // sample of the running instruction.
+	locations map[profileLocation]uint64 // identifiers of the locations.
+	frames    []profileLocation          // locations, indexed by identifier minus one.
+	samples   map[string]*profileSample  // samples, indexed by call stack.
+	list      []*profileSample           // samples in the order in which they have been created.
+	stack     []uint64                   // buffer for the call stack of a sample.
+	key       []byte                     // buffer for the keys of samples.
+}
+
+// profileLocation is a location in the code, as an instruction address in
+// a function.
+type profileLocation struct {
+	fn *Function
+	pc Addr
+}
+
+// profileSample is the sample of the instructions executed with a given call
+// stack.
+type profileSample struct {
+	stack        []uint64 // identifiers of the locations, from the innermost.
+	instructions int64    // number of executed instructions.
+	time         int64    // elapsed time in nanoseconds.
+	allocs       int64    // allocated bytes.
+}
+
+// NewProfile returns a new empty profile.
+func NewProfile() *Profile {
+	return &Profile{
+		locations: map[profileLocation]uint64{},
+		samples:   map[string]*profileSample{},
+	}
+}
+
+// SetProfile sets the profile that records the execution.
+//
+// SetProfile must not be called after vm has been started.
+func (vm *VM) SetProfile(p *Profile) {
+	vm.profile = p
+}
+
+// begin is called by the Run method of VM when the execution starts.
+func (p *Profile) begin() {
+	p.start = time.Now()
+	p.last = p.start
+}
+
+// finish is called by the Run method of VM when the execution terminates.
+func (p *Profile) finish() {
+	p.end = time.Now()
+	if p.current != nil {
+		p.current.time += int64(p.end.Sub(p.last))
+		p.current = nil
+	}
+}
+
+// instruction is called by the run method of VM, when a profile is set,
+// before executing the instruction at vm.pc. It accounts the time elapsed
+// since the previous instruction to the sample of that instruction and
+// makes the sample of the instruction at vm.pc the current sample.
+func (p *Profile) instruction(vm *VM) {
+	now := time.Now()
+	if p.current != nil {
+		p.current.time += int64(now.Sub(p.last))
+	}
+	p.last = now
+	stack := append(p.stack[:0], p.location(vm.fn, vm.pc))
+	for i := len(vm.calls) - 1; i >= 0; i-- {
+		call := vm.calls[i]
+		if call.cl.fn == nil || call.status == panicked {
+			continue
+		}
+		pc := call.pc - 2
+		if call.status == tailed {
+			pc = call.pc - 1
+		}
+		stack = append(stack, p.location(call.cl.fn, pc))
+	}
+	p.stack = stack
+	key := p.key[:0]
+	for _, id := range stack {
+		key = appendVarint(key, id)
+	}
+	p.key = key
+	s, ok := p.samples[string(key)]
+	if !ok {
+		s = &profileSample{stack: append([]uint64(nil), stack...)}
+		p.samples[string(key)] = s
+		p.list = append(p.list, s)
+	}
+	s.instructions++
+	p.current = s
+}
+
+// alloc accounts n allocated bytes to the current sample.
+func (p *Profile) alloc(n int64) {
+	if p.current != nil {
+		p.current.allocs += n
+	}
+}
+
+// location returns the identifier of the location of the instruction at
+// address pc of fn.
+func (p *Profile) location(fn *Function, pc Addr) uint64 {
+	loc := profileLocation{fn, pc}
+	id, ok := p.locations[loc]
+	if !ok {
+		p.frames = append(p.frames, loc)
+		id = uint64(len(p.frames))
+		p.locations[loc] = id
+	}
+	return id
+}
+
+// Write writes the profile to w in the gzip-compressed protocol buffer
+// format read by the pprof tool. It must be called only after the execution
+// is terminated.
+//
+// The profile has three sample types: "instructions", the number of executed
+// instructions, "time", the elapsed time in nanoseconds, and "alloc_space",
+// the allocated bytes. The default sample type is "time".
+func (p *Profile) Write(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	_, err := zw.Write(p.encode())
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Field numbers of the messages of the profile.proto file of pprof.
+const (
+	// Profile.
+	pbProfileSampleType        = 1
+	pbProfileSample            = 2
+	pbProfileLocation          = 4
+	pbProfileFunction          = 5
+	pbProfileStringTable       = 6
+	pbProfileTimeNanos         = 9
+	pbProfileDurationNanos     = 10
+	pbProfilePeriodType        = 11
+	pbProfilePeriod            = 12
+	pbProfileDefaultSampleType = 14
+
+	// ValueType.
+	pbValueTypeType = 1
+	pbValueTypeUnit = 2
+
+	// Sample.
+	pbSampleLocationID = 1
+	pbSampleValue      = 2
+
+	// Location.
+	pbLocationID   = 1
+	pbLocationLine = 4
+
+	// Line.
+	pbLineFunctionID = 1
+	pbLineLine       = 2
+
+	// Function.
+	pbFunctionID         = 1
+	pbFunctionName       = 2
+	pbFunctionSystemName = 3
+	pbFunctionFilename   = 4
+	pbFunctionStartLine  = 5
+)
+
+// encode encodes the profile in the protocol buffer format.
+func (p *Profile) encode() []byte {
+
+	var b protoBuffer
+
+	strings := map[string]int64{"": 0}
+	table := []string{""}
+	str := func(s string) int64 {
+		i, ok := strings[s]
+		if !ok {
+			i = int64(len(table))
+			strings[s] = i
+			table = append(table, s)
+		}
+		return i
+	}
+	valueType := func(field int, typ, unit string) {
+		var vt protoBuffer
+		vt.int64(pbValueTypeType, str(typ))
+		vt.int64(pbValueTypeUnit, str(unit))
+		b.message(field, &vt)
+	}
+
+	// Sample types.
+	valueType(pbProfileSampleType, "instructions", "count")
+	valueType(pbProfileSampleType, "time", "nanoseconds")
+	valueType(pbProfileSampleType, "alloc_space", "bytes")
+
+	// Samples.
+	for _, s := range p.list {
+		var sample protoBuffer
+		sample.packedUint64(pbSampleLocationID, s.stack)
+		sample.packedInt64(pbSampleValue, []int64{s.instructions, s.time, s.allocs})
+		b.message(pbProfileSample, &sample)
+	}
+
+	// Locations and functions. A function of the profile is a function, or
+	// a macro, in a file; the code of a file rendered or shown in a template
+	// is in the function of the file that renders or shows it.
+	type function struct {
+		name, file string
+	}
+	functions := map[function]uint64{}
+	var funcs protoBuffer
+	for i, loc := range p.frames {
+		path, line := loc.fn.File, 0
+		for pc := int(loc.pc); pc >= 0; pc-- {
+			if info, ok := loc.fn.DebugInfo[Addr(pc)]; ok && info.Position.Line > 0 {
+				if info.Path != "" {
+					path = info.Path
+				}
+				line = info.Position.Line
+				break
+			}
+		}
+		f := function{name: loc.fn.Name, file: path}
+		if loc.fn.Pkg != "" {
+			f.name = loc.fn.Pkg + "." + f.name
+		}
+		id, ok := functions[f]
+		if !ok {
+			id = uint64(len(functions) + 1)
+			functions[f] = id
+			var fn protoBuffer
+			fn.uint64(pbFunctionID, id)
+			fn.int64(pbFunctionName, str(f.name))
+			fn.int64(pbFunctionSystemName, str(f.name))
+			fn.int64(pbFunctionFilename, str(f.file))
+			if loc.fn.Pos != nil && path == loc.fn.File {
+				fn.int64(pbFunctionStartLine, int64(loc.fn.Pos.Line))
+			}
+			funcs.message(pbProfileFunction, &fn)
+		}
+		var ln protoBuffer
+		ln.uint64(pbLineFunctionID, id)
+		ln.int64(pbLineLine, int64(line))
+		var location protoBuffer
+		location.uint64(pbLocationID, uint64(i+1))
+		location.message(pbLocationLine, &ln)
+		b.message(pbProfileLocation, &location)
+	}
+	b.data = append(b.data, funcs.data...)
+
+	b.int64(pbProfileTimeNanos, p.start.UnixNano())
+	b.int64(pbProfileDurationNanos, int64(p.end.Sub(p.start)))
+	valueType(pbProfilePeriodType, "instructions", "count")
+	b.int64(pbProfilePeriod, 1)
+	b.int64(pbProfileDefaultSampleType, str("time"))
+
+	// String table. It must be encoded last, as the previous fields add
+	// strings to the table.
+	for _, s := range table {
+		b.string(pbProfileStringTable, s)
+	}
+
+	return b.data
+}
+
+// protoBuffer is a buffer that encodes the fields of a protocol buffer
+// message.
+type protoBuffer struct {
+	data []byte
+}
+
+// key encodes the key of a field with the given wire type.
+func (b *protoBuffer) key(field int, wireType uint64) {
+	b.data = appendVarint(b.data, uint64(field)<<3|wireType)
+}
+
+// uint64 encodes a uint64 field. A zero value is not encoded.
+func (b *protoBuffer) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.key(field, 0)
+	b.data = appendVarint(b.data, x)
+}
+
+// int64 encodes an int64 field. A zero value is not encoded.
+func (b *protoBuffer) int64(field int, x int64) {
+	b.uint64(field, uint64(x))
+}
+
+// packedUint64 encodes a repeated uint64 field in the packed format.
+func (b *protoBuffer) packedUint64(field int, xs []uint64) {
+	var data []byte
+	for _, x := range xs {
+		data = appendVarint(data, x)
+	}
+	b.bytes(field, data)
+}
+
+// packedInt64 encodes a repeated int64 field in the packed format.
+func (b *protoBuffer) packedInt64(field int, xs []int64) {
+	var data []byte
+	for _, x := range xs {
+		data = appendVarint(data, uint64(x))
+	}
+	b.bytes(field, data)
+}
+
+// string encodes a string field. Also an empty string is encoded, as it can
+// be an element of a repeated field.
+func (b *protoBuffer) string(field int, s string) {
+	b.key(field, 2)
+	b.data = appendVarint(b.data, uint64(len(s)))
+	b.data = append(b.data, s...)
+}
+
+// bytes encodes a length-delimited field.
+func (b *protoBuffer) bytes(field int, data []byte) {
+	b.key(field, 2)
+	b.data = appendVarint(b.data, uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+// message encodes an embedded message field.
+func (b *protoBuffer) message(field int, m *protoBuffer) {
+	b.bytes(field, m.data)
+}
+
+// appendVarint appends x, encoded as a varint, to data.
+func appendVarint(data []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], x)
+	return append(data, buf[:n]...)
+}
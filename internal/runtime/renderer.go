@@ -10,10 +10,12 @@ import (
 	"fmt"
 	"html"
 	"io"
+	"math"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 	"unicode/utf8"
@@ -49,11 +51,19 @@ type renderer struct {
 	// removeQuestionMark reports whether a question mark must be removed
 	// before the next written text. It can be true only if it is in a URL.
 	removeQuestionMark bool
+
+	// indent is the number of spaces that indent the current line. It is
+	// used to indent the lines of values shown in a YAML block scalar.
+	indent int
+
+	// indenting reports whether the current line contains only the spaces
+	// of its indentation.
+	indenting bool
 }
 
 // newRenderer returns a new renderer.
 func newRenderer(env *env, out io.Writer, conv Converter) *renderer {
-	return &renderer{env: env, out: out, conv: conv}
+	return &renderer{env: env, out: out, conv: conv, indenting: true}
 }
 
 func (r *renderer) Close() error {
@@ -63,11 +73,24 @@ func (r *renderer) Close() error {
 	return nil
 }
 
+// Wait waits for the asynchronous renderings started with the Async method
+// to end and writes their output. It returns the first error occurred
+// rendering or writing the output.
+func (r *renderer) Wait() error {
+	if w, ok := r.out.(*asyncWriter); ok {
+		r.out = w.out
+		return w.Close()
+	}
+	return nil
+}
+
 // Show shows v in the given context.
 func (r *renderer) Show(v interface{}, context Context) error {
 
 	ctx, inURL, _ := decodeRenderContext(context)
 
+	r.indenting = false
+
 	// Check and eventually change the URL state.
 	if r.inURL != inURL {
 		if !inURL {
@@ -111,6 +134,38 @@ func (r *renderer) Show(v interface{}, context Context) error {
 		err = showInMarkdownCodeBlock(r.env, r.out, v, false)
 	case ast.ContextSpacesCodeBlock:
 		err = showInMarkdownCodeBlock(r.env, r.out, v, true)
+	case ast.ContextJSAttr:
+		err = showInScriptAttribute(r.env, r.out, v, showInJS)
+	case ast.ContextJSStringAttr:
+		err = showInScriptAttribute(r.env, r.out, v, showInJSString)
+	case ast.ContextCSSAttr:
+		err = showInScriptAttribute(r.env, r.out, v, showInCSS)
+	case ast.ContextCSSStringAttr:
+		err = showInScriptAttribute(r.env, r.out, v, showInCSSString)
+	case ast.ContextXML:
+		err = showInXML(r.env, r.out, v)
+	case ast.ContextXMLAttr:
+		err = showInXMLAttribute(r.env, r.out, v)
+	case ast.ContextXMLCDATA:
+		err = showInXMLCDATA(r.env, r.out, v)
+	case ast.ContextYAML:
+		err = showInYAML(r.env, r.out, v)
+	case ast.ContextYAMLPlain:
+		err = showEscaped(r.env, r.out, v, yamlPlainEscape)
+	case ast.ContextYAMLFlowPlain:
+		err = showEscaped(r.env, r.out, v, yamlFlowPlainEscape)
+	case ast.ContextYAMLDoubleQuoted:
+		err = showEscaped(r.env, r.out, v, yamlStringEscape)
+	case ast.ContextYAMLSingleQuoted:
+		err = showEscaped(r.env, r.out, v, yamlSingleQuotedEscape)
+	case ast.ContextYAMLBlock:
+		err = showInYAMLBlock(r.env, r.out, v, r.indent)
+	case ast.ContextTOML:
+		err = showInTOML(r.env, r.out, v)
+	case ast.ContextTOMLString:
+		err = showEscaped(r.env, r.out, v, tomlStringEscape)
+	case ast.ContextTOMLLiteralString:
+		err = showEscaped(r.env, r.out, v, tomlLiteralStringEscape)
 	default:
 		panic("scriggo: unknown context")
 	}
@@ -118,6 +173,13 @@ func (r *renderer) Show(v interface{}, context Context) error {
 	return err
 }
 
+// Flush flushes the output written so far, if the out writer, or the writer
+// it writes to, implements a Flush method. Markdown code to convert is
+// converted and flushed up to the last complete block.
+func (r *renderer) Flush() error {
+	return flush(r.out)
+}
+
 // Out returns the out writer.
 func (r *renderer) Out() io.Writer {
 	return r.out
@@ -156,10 +218,53 @@ func (r *renderer) Text(txt []byte, inURL, isSet bool) error {
 		return err
 	}
 
+	// Track the indentation of the current line.
+	line := txt
+	if i := bytes.LastIndexByte(txt, '\n'); i >= 0 {
+		line = txt[i+1:]
+		r.indent = 0
+		r.indenting = true
+	}
+	if r.indenting {
+		for i, c := range line {
+			if c != ' ' {
+				r.indent += i
+				r.indenting = false
+				break
+			}
+		}
+		if r.indenting {
+			r.indent += len(line)
+		}
+	}
+
 	_, err := r.out.Write(txt)
 	return err
 }
 
+// Nonce writes the nonce attribute, if a nonce has been set.
+func (r *renderer) Nonce() error {
+	if r.env.nonce == "" {
+		return nil
+	}
+	_, err := io.WriteString(r.out, ` nonce="`+html.EscapeString(r.env.nonce)+`"`)
+	return err
+}
+
+// Async starts an asynchronous rendering and returns the writer where the
+// output of the rendering must be written. This output will be written to
+// the out writer of r at the current position, after the output written so
+// far and before the output that r will write next. The End method of the
+// returned writer must be called when the rendering ends.
+func (r *renderer) Async() *asyncPart {
+	w, ok := r.out.(*asyncWriter)
+	if !ok {
+		w = &asyncWriter{out: r.out}
+		r.out = w
+	}
+	return w.start()
+}
+
 func (r *renderer) WithConversion(from, to ast.Format) *renderer {
 	if from == ast.FormatMarkdown && to == ast.FormatHTML {
 		out := newMarkdownWriter(r.out, r.conv)
@@ -242,6 +347,190 @@ func (w *markdownWriter) Close() error {
 	return w.convert(w.buf.Bytes(), w.out)
 }
 
+// Flush converts the written Markdown code up to the last complete block,
+// writes it to the underlying writer and then flushes it. The rest of the
+// code is converted with the next blocks.
+func (w *markdownWriter) Flush() error {
+	if n := markdownBlocksEnd(w.buf.Bytes()); n > 0 {
+		if w.convert == nil {
+			return errors.New("no Markdown convert available")
+		}
+		err := w.convert(w.buf.Next(n), w.out)
+		if err != nil {
+			return err
+		}
+	}
+	return flush(w.out)
+}
+
+// markdownBlocksEnd returns the length of the longest prefix of the Markdown
+// code src that contains only complete blocks, that is the index after the
+// last blank line that is not in a fenced code block.
+func markdownBlocksEnd(src []byte) int {
+	end := 0
+	var fence []byte // fence of the current fenced code block
+	for i := 0; i < len(src); {
+		n := bytes.IndexByte(src[i:], '\n')
+		if n < 0 {
+			break
+		}
+		line := src[i : i+n]
+		i += n + 1
+		trimmed := bytes.TrimLeft(line, " ")
+		isFence := len(line)-len(trimmed) < 4 &&
+			(bytes.HasPrefix(trimmed, backtickFence) || bytes.HasPrefix(trimmed, tildeFence))
+		if fence != nil {
+			if isFence && bytes.HasPrefix(trimmed, fence) && len(bytes.TrimSpace(bytes.TrimLeft(trimmed, string(fence[:1])))) == 0 {
+				fence = nil
+			}
+			continue
+		}
+		if isFence {
+			j := 0
+			for j < len(trimmed) && trimmed[j] == trimmed[0] {
+				j++
+			}
+			fence = trimmed[:j]
+			continue
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			end = i
+		}
+	}
+	return end
+}
+
+var backtickFence = []byte("```")
+var tildeFence = []byte("~~~")
+
+// asyncWriter is the out writer of a renderer that has started asynchronous
+// renderings. It keeps the parts of the output that cannot be written yet, as
+// they follow the output of an asynchronous rendering not yet ended, and
+// writes them to out, in order, as soon as possible.
+type asyncWriter struct {
+	out   io.Writer
+	mu    sync.Mutex
+	parts []*asyncPart // parts not yet written; the last one is written by the renderer.
+	err   error        // first error occurred.
+}
+
+// asyncPart is a part of the output of an asyncWriter.
+type asyncPart struct {
+	w       *asyncWriter
+	buf     bytes.Buffer
+	pending bool          // reports whether it is written by a rendering not yet ended.
+	done    chan struct{} // closed when the rendering ends.
+	err     error         // error occurred during the rendering.
+}
+
+// start starts an asynchronous rendering and returns its part.
+func (w *asyncWriter) start() *asyncPart {
+	part := &asyncPart{w: w, pending: true, done: make(chan struct{})}
+	w.mu.Lock()
+	w.parts = append(w.parts, part, &asyncPart{w: w})
+	w.mu.Unlock()
+	return part
+}
+
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+	if len(w.parts) == 0 {
+		n, err := w.out.Write(p)
+		if err != nil {
+			w.err = err
+		}
+		return n, err
+	}
+	return w.parts[len(w.parts)-1].buf.Write(p)
+}
+
+// Flush writes the parts that can be written and then flushes the underlying
+// writer.
+func (w *asyncWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writeParts()
+	if w.err != nil {
+		return w.err
+	}
+	return flush(w.out)
+}
+
+// Close waits for the asynchronous renderings to end and writes the parts.
+func (w *asyncWriter) Close() error {
+	var done []chan struct{}
+	w.mu.Lock()
+	for _, part := range w.parts {
+		if part.pending {
+			done = append(done, part.done)
+		}
+	}
+	w.mu.Unlock()
+	for _, d := range done {
+		<-d
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writeParts()
+	return w.err
+}
+
+// writeParts writes, in order, the parts that precede the first part of a
+// rendering not yet ended. It is called with w.mu locked.
+func (w *asyncWriter) writeParts() {
+	for len(w.parts) > 0 && w.err == nil {
+		part := w.parts[0]
+		if part.pending {
+			return
+		}
+		if part.err != nil {
+			w.err = part.err
+			return
+		}
+		_, err := w.out.Write(part.buf.Bytes())
+		if err != nil {
+			w.err = err
+			return
+		}
+		w.parts[0] = nil
+		w.parts = w.parts[1:]
+	}
+}
+
+// Write writes p to the part. It is called only by the asynchronous
+// rendering, so it does not need to be synchronized.
+func (part *asyncPart) Write(p []byte) (int, error) {
+	return part.buf.Write(p)
+}
+
+// End ends the asynchronous rendering of the part with the error err, if
+// not nil, and writes the parts that can now be written.
+func (part *asyncPart) End(err error) {
+	w := part.w
+	w.mu.Lock()
+	part.pending = false
+	part.err = err
+	w.writeParts()
+	w.mu.Unlock()
+	close(part.done)
+}
+
+// flush flushes w, if it implements a Flush method as the http.Flusher or
+// the bufio.Writer types.
+func flush(w io.Writer) error {
+	switch w := w.(type) {
+	case interface{ Flush() error }:
+		return w.Flush()
+	case interface{ Flush() }:
+		w.Flush()
+	}
+	return nil
+}
+
 type strWriterWrapper struct {
 	w io.Writer
 }
@@ -447,6 +736,20 @@ func showInAttribute(env *env, out io.Writer, value interface{}, quoted bool) er
 	return attributeEscape(newStringWriter(out), s, escapeEntities, quoted)
 }
 
+// showInScriptAttribute shows value in the JavaScript or CSS code of an
+// event handler or style attribute. The value is first shown with the show
+// function and then escaped as an attribute value. The value is escaped as an
+// unquoted attribute value, so it is safe whether the attribute is quoted or
+// not.
+func showInScriptAttribute(env *env, out io.Writer, value interface{}, show func(*env, io.Writer, interface{}) error) error {
+	var b strings.Builder
+	err := show(env, &b, value)
+	if err != nil {
+		return err
+	}
+	return attributeEscape(newStringWriter(out), b.String(), true, false)
+}
+
 // showInCSS shows value in CSS context.
 func showInCSS(env *env, out io.Writer, value interface{}) error {
 	w := newStringWriter(out)
@@ -973,6 +1276,442 @@ func showInMarkdown(env *env, out io.Writer, value interface{}) error {
 	}
 }
 
+// showInXML shows value in XML context.
+func showInXML(env *env, out io.Writer, value interface{}) error {
+	w := newStringWriter(out)
+	switch v := value.(type) {
+	case native.XML:
+		_, err := w.WriteString(string(v))
+		return err
+	case native.XMLStringer:
+		_, err := w.WriteString(string(v.XML()))
+		return err
+	case native.XMLEnvStringer:
+		_, err := w.WriteString(string(v.XML(env)))
+		return err
+	case fmt.Stringer:
+		return xmlEscape(w, v.String(), false, true)
+	case native.EnvStringer:
+		return xmlEscape(w, v.String(env), false, true)
+	case error:
+		return xmlEscape(w, v.Error(), false, true)
+	}
+	s, err := toString(env, value)
+	if err != nil {
+		return err
+	}
+	return xmlEscape(w, s, false, true)
+}
+
+// showInXMLAttribute shows value in the XML attribute context.
+func showInXMLAttribute(env *env, out io.Writer, value interface{}) error {
+	var s string
+	var escapeEntities bool
+	switch v := value.(type) {
+	case native.XML:
+		s = string(v)
+	case native.XMLStringer:
+		s = string(v.XML())
+	case native.XMLEnvStringer:
+		s = string(v.XML(env))
+	case fmt.Stringer:
+		s = v.String()
+		escapeEntities = true
+	case native.EnvStringer:
+		s = v.String(env)
+		escapeEntities = true
+	case error:
+		s = v.Error()
+		escapeEntities = true
+	default:
+		var err error
+		s, err = toString(env, value)
+		if err != nil {
+			return err
+		}
+		escapeEntities = true
+	}
+	return xmlEscape(newStringWriter(out), s, true, escapeEntities)
+}
+
+// showInXMLCDATA shows value in the XML CDATA section context.
+func showInXMLCDATA(env *env, out io.Writer, value interface{}) error {
+	var b strings.Builder
+	err := showInText(env, &b, value)
+	if err != nil {
+		return err
+	}
+	return cdataEscape(newStringWriter(out), b.String())
+}
+
+// showInYAML shows value in the YAML context, where a key or a value node is
+// expected. Strings are shown as double-quoted scalars and collections in
+// the flow style.
+func showInYAML(env *env, out io.Writer, value interface{}) error {
+
+	w := newStringWriter(out)
+
+	switch v := value.(type) {
+	case nil:
+		_, err := w.WriteString("null")
+		return err
+	case native.YAML:
+		_, err := w.WriteString(string(v))
+		return err
+	case native.YAMLStringer:
+		_, err := w.WriteString(string(v.YAML()))
+		return err
+	case native.YAMLEnvStringer:
+		_, err := w.WriteString(string(v.YAML(env)))
+		return err
+	case time.Time:
+		_, err := w.WriteString(v.Format(time.RFC3339))
+		return err
+	case error:
+		value = v.Error()
+	}
+
+	v := reflect.ValueOf(value)
+
+	var s string
+
+	switch v.Kind() {
+	case reflect.Bool:
+		s = "false"
+		if v.Bool() {
+			s = "true"
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s = strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		switch {
+		case math.IsNaN(f):
+			s = ".nan"
+		case math.IsInf(f, 1):
+			s = ".inf"
+		case math.IsInf(f, -1):
+			s = "-.inf"
+		default:
+			s = strconv.FormatFloat(f, 'f', -1, v.Type().Bits())
+		}
+	case reflect.String:
+		_, err := w.WriteString(`"`)
+		if err == nil {
+			err = yamlStringEscape(w, v.String())
+		}
+		if err == nil {
+			_, err = w.WriteString(`"`)
+		}
+		return err
+	case reflect.Slice:
+		if b, ok := value.([]byte); ok {
+			return escapeBytes(w, b, true)
+		}
+		if v.IsNil() {
+			s = "null"
+			break
+		}
+		fallthrough
+	case reflect.Array:
+		_, err := w.WriteString("[")
+		for i := 0; i < v.Len() && err == nil; i++ {
+			if i > 0 {
+				_, err = w.WriteString(", ")
+			}
+			if err == nil {
+				err = showInYAML(env, out, v.Index(i).Interface())
+			}
+		}
+		if err == nil {
+			_, err = w.WriteString("]")
+		}
+		return err
+	case reflect.Ptr, reflect.UnsafePointer:
+		if v.IsNil() {
+			s = "null"
+			break
+		}
+		return showInYAML(env, out, v.Elem().Interface())
+	case reflect.Struct, reflect.Map:
+		if v.Kind() == reflect.Map && v.IsNil() {
+			s = "null"
+			break
+		}
+		pairs, err := keyValuePairs(env, v, "yaml")
+		if err != nil {
+			return err
+		}
+		_, err = w.WriteString("{")
+		for i, pair := range pairs {
+			if err != nil {
+				return err
+			}
+			if i == 0 {
+				_, err = w.WriteString(`"`)
+			} else {
+				_, err = w.WriteString(`, "`)
+			}
+			if err == nil {
+				err = yamlStringEscape(w, pair.key)
+			}
+			if err == nil {
+				_, err = w.WriteString(`": `)
+			}
+			if err == nil {
+				err = showInYAML(env, out, pair.value)
+			}
+		}
+		if err == nil {
+			_, err = w.WriteString("}")
+		}
+		return err
+	default:
+		s = "null"
+	}
+
+	_, err := w.WriteString(s)
+	return err
+}
+
+// showInYAMLBlock shows value in the YAML block scalar context, indenting
+// each line after the first with indent spaces.
+func showInYAMLBlock(env *env, out io.Writer, value interface{}, indent int) error {
+	s, err := textOf(env, value)
+	if err != nil {
+		return err
+	}
+	return yamlBlockEscape(newStringWriter(out), s, indent)
+}
+
+// showInTOML shows value in the TOML context, where a key or a value is
+// expected. Strings are shown as basic strings, slices and arrays as arrays,
+// and maps and structs as inline tables.
+func showInTOML(env *env, out io.Writer, value interface{}) error {
+
+	w := newStringWriter(out)
+
+	switch v := value.(type) {
+	case nil:
+		return errors.New("cannot show nil as TOML")
+	case native.TOML:
+		_, err := w.WriteString(string(v))
+		return err
+	case native.TOMLStringer:
+		_, err := w.WriteString(string(v.TOML()))
+		return err
+	case native.TOMLEnvStringer:
+		_, err := w.WriteString(string(v.TOML(env)))
+		return err
+	case time.Time:
+		_, err := w.WriteString(v.Format(time.RFC3339))
+		return err
+	case error:
+		value = v.Error()
+	}
+
+	v := reflect.ValueOf(value)
+
+	var s string
+
+	switch v.Kind() {
+	case reflect.Bool:
+		s = "false"
+		if v.Bool() {
+			s = "true"
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s = strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		switch {
+		case math.IsNaN(f):
+			s = "nan"
+		case math.IsInf(f, 1):
+			s = "inf"
+		case math.IsInf(f, -1):
+			s = "-inf"
+		default:
+			s = strconv.FormatFloat(f, 'f', -1, v.Type().Bits())
+			if !strings.Contains(s, ".") {
+				s += ".0"
+			}
+		}
+	case reflect.String:
+		_, err := w.WriteString(`"`)
+		if err == nil {
+			err = tomlStringEscape(w, v.String())
+		}
+		if err == nil {
+			_, err = w.WriteString(`"`)
+		}
+		return err
+	case reflect.Slice, reflect.Array:
+		if b, ok := value.([]byte); ok {
+			return escapeBytes(w, b, true)
+		}
+		_, err := w.WriteString("[")
+		for i := 0; i < v.Len() && err == nil; i++ {
+			if i > 0 {
+				_, err = w.WriteString(", ")
+			}
+			if err == nil {
+				err = showInTOML(env, out, v.Index(i).Interface())
+			}
+		}
+		if err == nil {
+			_, err = w.WriteString("]")
+		}
+		return err
+	case reflect.Ptr, reflect.UnsafePointer:
+		if v.IsNil() {
+			return errors.New("cannot show nil as TOML")
+		}
+		return showInTOML(env, out, v.Elem().Interface())
+	case reflect.Struct, reflect.Map:
+		pairs, err := keyValuePairs(env, v, "toml")
+		if err != nil {
+			return err
+		}
+		if len(pairs) == 0 {
+			s = "{}"
+			break
+		}
+		_, err = w.WriteString("{ ")
+		for i, pair := range pairs {
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				_, err = w.WriteString(", ")
+			}
+			if err == nil {
+				if isTOMLBareKey(pair.key) {
+					_, err = w.WriteString(pair.key)
+				} else {
+					_, err = w.WriteString(`"`)
+					if err == nil {
+						err = tomlStringEscape(w, pair.key)
+					}
+					if err == nil {
+						_, err = w.WriteString(`"`)
+					}
+				}
+			}
+			if err == nil {
+				_, err = w.WriteString(" = ")
+			}
+			if err == nil {
+				err = showInTOML(env, out, pair.value)
+			}
+		}
+		if err == nil {
+			_, err = w.WriteString(" }")
+		}
+		return err
+	default:
+		t := env.TypeOf(reflect.ValueOf(value))
+		return fmt.Errorf("cannot show a %s value as TOML", t)
+	}
+
+	_, err := w.WriteString(s)
+	return err
+}
+
+// showEscaped shows value, as in the Text context, escaping it with escape.
+func showEscaped(env *env, out io.Writer, value interface{}, escape func(strWriter, string) error) error {
+	s, err := textOf(env, value)
+	if err != nil {
+		return err
+	}
+	return escape(newStringWriter(out), s)
+}
+
+// textOf returns the string representation of value, as it is shown in the
+// Text context.
+func textOf(env *env, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case fmt.Stringer:
+		return v.String(), nil
+	case native.EnvStringer:
+		return v.String(env), nil
+	case error:
+		return v.Error(), nil
+	}
+	return toString(env, value)
+}
+
+// keyValuePair is a key-value pair of a map or a struct.
+type keyValuePair struct {
+	key   string
+	value interface{}
+}
+
+// keyValuePairs returns the key-value pairs of the map or struct v, sorted
+// by key for maps. For structs, only the exported fields are returned and
+// tag is the name of the field tag to read the key name and the omitempty
+// option from.
+func keyValuePairs(env *env, v reflect.Value, tag string) ([]keyValuePair, error) {
+	if v.Kind() == reflect.Struct {
+		t := v.Type()
+		n := t.NumField()
+		pairs := make([]keyValuePair, 0, n)
+		for i := 0; i < n; i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := field.Name
+			value := v.Field(i)
+			if tag := field.Tag.Get(tag); tag != "" {
+				if tag == "-" {
+					continue
+				}
+				tagName, omitempty := parseTagValue(tag)
+				if omitempty && isEmptyValue(value) {
+					continue
+				}
+				if tagName != "" {
+					name = tagName
+				}
+			}
+			pairs = append(pairs, keyValuePair{name, value.Interface()})
+		}
+		return pairs, nil
+	}
+	pairs := make([]keyValuePair, v.Len())
+	iter := v.MapRange()
+	for i := 0; iter.Next(); i++ {
+		key, err := textOf(env, iter.Key().Interface())
+		if err != nil {
+			return nil, err
+		}
+		pairs[i] = keyValuePair{key, iter.Value().Interface()}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].key < pairs[j].key
+	})
+	return pairs, nil
+}
+
+// isTOMLBareKey reports whether key can be written as a TOML bare key.
+func isTOMLBareKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
 // showInMarkdownCodeBlock shows value in the Markdown code block context.
 func showInMarkdownCodeBlock(env *env, out io.Writer, value interface{}, spaces bool) error {
 	var s string
@@ -1078,7 +1817,7 @@ func valueOf(env *env, i interface{}) reflect.Value {
 // decodeRenderContext decodes a runtime.Context.
 // Keep in sync with the compiler.decodeRenderContext.
 func decodeRenderContext(c Context) (ast.Context, bool, bool) {
-	ctx := ast.Context(c & 0b00001111)
+	ctx := ast.Context(c & 0b00111111)
 	inURL := c&0b10000000 != 0
 	isURLSet := false
 	if inURL {
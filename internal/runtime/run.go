@@ -17,6 +17,7 @@ import (
 func (vm *VM) runFunc(fn *Function, vars []reflect.Value) error {
 	vm.fn = fn
 	vm.vars = vars
+	vm.growStacks(fn)
 	var stop chan struct{}
 	if vm.env.doneChan != nil {
 		stop = make(chan struct{})
@@ -51,6 +52,9 @@ func (vm *VM) runFunc(fn *Function, vars []reflect.Value) error {
 	if stop != nil {
 		close(stop)
 		if atomic.LoadInt32(&vm.env.done) == 1 {
+			if err := vm.env.limitError(); err != nil {
+				return err
+			}
 			return vm.env.ctx.Err()
 		}
 	}
@@ -81,9 +85,11 @@ func (vm *VM) run() (Addr, bool) {
 	var startNativeGoroutine bool
 
 	var op Operation
-	var a, b, c int8
+	var a, b, c int16
 
 	done := vm.env.doneChan
+	limits := vm.env.limits
+	profile := vm.profile
 
 	for {
 
@@ -91,11 +97,25 @@ func (vm *VM) run() (Addr, bool) {
 			return vm.stop()
 		}
 
+		if vm.debug != nil {
+			vm.debugStatement()
+		}
+
+		if profile != nil {
+			profile.instruction(vm)
+		}
+
 		in := vm.fn.Body[vm.pc]
 
 		vm.pc++
 		op, a, b, c = in.Op, in.A, in.B, in.C
 
+		if limits != nil && limits.Instructions > 0 {
+			if atomic.AddInt64(&limits.instructions, 1) > limits.Instructions {
+				panic(exceededLimit(InstructionsLimit))
+			}
+		}
+
 		// If an instruction needs to change the program counter,
 		// it must be changed, if possible, at the end of the instruction execution.
 
@@ -141,7 +161,7 @@ func (vm *VM) run() (Addr, bool) {
 				i := int(vm.int(b))
 				vm.setGeneral(c, v.Index(i).Addr())
 			case reflect.Struct, reflect.Ptr:
-				vm.setGeneral(c, vm.fieldByIndex(v, uint8(b)).Addr())
+				vm.setGeneral(c, vm.fieldByIndex(v, uint16(b)).Addr())
 			}
 
 		// And
@@ -154,20 +174,29 @@ func (vm *VM) run() (Addr, bool) {
 
 		// Append
 		case OpAppend:
+			if limits != nil || profile != nil {
+				vm.allocAppend(vm.general(c), int(b-a))
+			}
 			vm.setGeneral(c, vm.appendSlice(a, int(b-a), vm.general(c)))
 
 		// AppendSlice
 		case OpAppendSlice:
+			if limits != nil || profile != nil {
+				vm.allocAppend(vm.general(c), vm.general(a).Len())
+			}
 			vm.setGeneral(c, reflect.AppendSlice(vm.general(c), vm.general(a)))
 
 		// Assert
 		case OpAssert:
 			v := vm.general(a)
-			t := vm.fn.Types[uint8(b)]
+			t := vm.fn.Types[uint16(b)]
 			var ok bool
 			if v.IsValid() {
 				if w, isScriggoType := t.(ScriggoType); isScriggoType {
-					v, ok = w.Unwrap(v)
+					var rv reflect.Value
+					if rv, ok = w.Unwrap(v); ok {
+						v = rv
+					}
 				} else {
 					if t.Kind() == reflect.Interface {
 						ok = v.Type().Implements(t)
@@ -183,12 +212,12 @@ func (vm *VM) run() (Addr, bool) {
 					var concrete reflect.Type
 					var method string
 					if v.IsValid() {
-						concrete = v.Type()
+						concrete = vm.env.TypeOf(v)
 						if t.Kind() == reflect.Interface {
 							method = missingMethod(concrete, t)
 						}
 					}
-					panic(errTypeAssertion(vm.fn.Types[uint8(in.C)], concrete, t, method))
+					panic(errTypeAssertion(vm.fn.Types[uint16(in.C)], concrete, t, method))
 				}
 			}
 			if c != 0 {
@@ -238,24 +267,27 @@ func (vm *VM) run() (Addr, bool) {
 
 		// Call
 		case OpCallFunc:
+			if limits != nil {
+				vm.checkCallDepth()
+			}
 			call := callFrame{cl: callable{fn: vm.fn, vars: vm.vars}, fp: vm.fp, pc: vm.pc + 1}
-			fn := vm.fn.Functions[uint8(a)]
+			fn := vm.fn.Functions[uint16(a)]
 			off := vm.fn.Body[vm.pc]
 			vm.fp[0] += Addr(off.Op)
-			if vm.fp[0]+Addr(fn.NumReg[0]) > vm.st[0] {
-				vm.moreIntStack()
+			if top := vm.fp[0] + Addr(fn.NumReg[0]); top >= vm.st[0] {
+				vm.moreIntStack(top)
 			}
 			vm.fp[1] += Addr(off.A)
-			if vm.fp[1]+Addr(fn.NumReg[1]) > vm.st[1] {
-				vm.moreFloatStack()
+			if top := vm.fp[1] + Addr(fn.NumReg[1]); top >= vm.st[1] {
+				vm.moreFloatStack(top)
 			}
 			vm.fp[2] += Addr(off.B)
-			if vm.fp[2]+Addr(fn.NumReg[2]) > vm.st[2] {
-				vm.moreStringStack()
+			if top := vm.fp[2] + Addr(fn.NumReg[2]); top >= vm.st[2] {
+				vm.moreStringStack(top)
 			}
 			vm.fp[3] += Addr(off.C)
-			if vm.fp[3]+Addr(fn.NumReg[3]) > vm.st[3] {
-				vm.moreGeneralStack()
+			if top := vm.fp[3] + Addr(fn.NumReg[3]); top >= vm.st[3] {
+				vm.moreGeneralStack(top)
 			}
 			vm.fn = fn
 			vm.vars = vm.env.globals
@@ -265,28 +297,40 @@ func (vm *VM) run() (Addr, bool) {
 			f := vm.general(a).Interface().(*callable)
 			if f.fn == nil {
 				off := vm.fn.Body[vm.pc]
-				vm.callNative(f.Native(), c, StackShift{int8(off.Op), off.A, off.B, off.C}, startNativeGoroutine)
+				shift := StackShift{int16(off.Op), off.A, off.B, off.C}
+				if b == ReturnString || startNativeGoroutine {
+					vm.callNative(f.Native(), c, shift, startNativeGoroutine)
+				} else {
+					// The result is rendered, as it is for a macro.
+					err := vm.renderNative(f.Native(), c, shift, ast.Format(b))
+					if err != nil {
+						panic(outError{err})
+					}
+				}
 				startNativeGoroutine = false
 				vm.pc++
 			} else {
+				if limits != nil {
+					vm.checkCallDepth()
+				}
 				call := callFrame{cl: callable{fn: vm.fn, vars: vm.vars}, fp: vm.fp, pc: vm.pc + 1}
 				fn := f.fn
 				off := vm.fn.Body[vm.pc]
 				vm.fp[0] += Addr(off.Op)
-				if vm.fp[0]+Addr(fn.NumReg[0]) > vm.st[0] {
-					vm.moreIntStack()
+				if top := vm.fp[0] + Addr(fn.NumReg[0]); top >= vm.st[0] {
+					vm.moreIntStack(top)
 				}
 				vm.fp[1] += Addr(off.A)
-				if vm.fp[1]+Addr(fn.NumReg[1]) > vm.st[1] {
-					vm.moreFloatStack()
+				if top := vm.fp[1] + Addr(fn.NumReg[1]); top >= vm.st[1] {
+					vm.moreFloatStack(top)
 				}
 				vm.fp[2] += Addr(off.B)
-				if vm.fp[2]+Addr(fn.NumReg[2]) > vm.st[2] {
-					vm.moreStringStack()
+				if top := vm.fp[2] + Addr(fn.NumReg[2]); top >= vm.st[2] {
+					vm.moreStringStack(top)
 				}
 				vm.fp[3] += Addr(off.C)
-				if vm.fp[3]+Addr(fn.NumReg[3]) > vm.st[3] {
-					vm.moreGeneralStack()
+				if top := vm.fp[3] + Addr(fn.NumReg[3]); top >= vm.st[3] {
+					vm.moreGeneralStack(top)
 				}
 				if fn.Macro {
 					call.renderer = vm.renderer
@@ -302,24 +346,27 @@ func (vm *VM) run() (Addr, bool) {
 				vm.pc = 0
 			}
 		case OpCallMacro:
+			if limits != nil {
+				vm.checkCallDepth()
+			}
 			call := callFrame{cl: callable{fn: vm.fn, vars: vm.vars}, renderer: vm.renderer, fp: vm.fp, pc: vm.pc + 1}
-			fn := vm.fn.Functions[uint8(a)]
+			fn := vm.fn.Functions[uint16(a)]
 			off := vm.fn.Body[vm.pc]
 			vm.fp[0] += Addr(off.Op)
-			if vm.fp[0]+Addr(fn.NumReg[0]) > vm.st[0] {
-				vm.moreIntStack()
+			if top := vm.fp[0] + Addr(fn.NumReg[0]); top >= vm.st[0] {
+				vm.moreIntStack(top)
 			}
 			vm.fp[1] += Addr(off.A)
-			if vm.fp[1]+Addr(fn.NumReg[1]) > vm.st[1] {
-				vm.moreFloatStack()
+			if top := vm.fp[1] + Addr(fn.NumReg[1]); top >= vm.st[1] {
+				vm.moreFloatStack(top)
 			}
 			vm.fp[2] += Addr(off.B)
-			if vm.fp[2]+Addr(fn.NumReg[2]) > vm.st[2] {
-				vm.moreStringStack()
+			if top := vm.fp[2] + Addr(fn.NumReg[2]); top >= vm.st[2] {
+				vm.moreStringStack(top)
 			}
 			vm.fp[3] += Addr(off.C)
-			if vm.fp[3]+Addr(fn.NumReg[3]) > vm.st[3] {
-				vm.moreGeneralStack()
+			if top := vm.fp[3] + Addr(fn.NumReg[3]); top >= vm.st[3] {
+				vm.moreGeneralStack(top)
 			}
 			if b == ReturnString {
 				vm.renderer = vm.renderer.WithOut(&macroOutBuffer{})
@@ -331,9 +378,9 @@ func (vm *VM) run() (Addr, bool) {
 			vm.calls = append(vm.calls, call)
 			vm.pc = 0
 		case OpCallNative:
-			fn := vm.fn.NativeFunctions[uint8(a)]
+			fn := vm.fn.NativeFunctions[uint16(a)]
 			off := vm.fn.Body[vm.pc]
-			vm.callNative(fn, c, StackShift{int8(off.Op), off.A, off.B, off.C}, startNativeGoroutine)
+			vm.callNative(fn, c, StackShift{int16(off.Op), off.A, off.B, off.C}, startNativeGoroutine)
 			startNativeGoroutine = false
 			vm.pc++
 
@@ -400,7 +447,7 @@ func (vm *VM) run() (Addr, bool) {
 
 		// Convert
 		case OpConvert:
-			t := vm.fn.Types[uint8(b)]
+			t := vm.fn.Types[uint16(b)]
 			switch t.Kind() {
 			case reflect.String:
 				vm.setString(c, vm.general(a).Convert(t).String())
@@ -408,7 +455,7 @@ func (vm *VM) run() (Addr, bool) {
 				vm.setGeneral(c, vm.general(a).Convert(t))
 			}
 		case OpConvertInt:
-			t := vm.fn.Types[uint8(b)]
+			t := vm.fn.Types[uint16(b)]
 			v := vm.int(a)
 			switch t.Kind() {
 			case reflect.Int:
@@ -443,7 +490,7 @@ func (vm *VM) run() (Addr, bool) {
 				vm.setString(c, s)
 			}
 		case OpConvertUint:
-			t := vm.fn.Types[uint8(b)]
+			t := vm.fn.Types[uint16(b)]
 			v := uint64(vm.int(a))
 			switch t.Kind() {
 			case reflect.Int:
@@ -478,7 +525,7 @@ func (vm *VM) run() (Addr, bool) {
 				vm.setString(c, s)
 			}
 		case OpConvertFloat:
-			t := vm.fn.Types[uint8(b)]
+			t := vm.fn.Types[uint16(b)]
 			v := vm.float(a)
 			switch t.Kind() {
 			case reflect.Int:
@@ -507,7 +554,7 @@ func (vm *VM) run() (Addr, bool) {
 				vm.setFloat(c, v)
 			}
 		case OpConvertString:
-			t := vm.fn.Types[uint8(b)]
+			t := vm.fn.Types[uint16(b)]
 			v := reflect.ValueOf(vm.string(a))
 			if t.Kind() == reflect.Slice {
 				vm.setGeneral(c, v.Convert(t))
@@ -523,6 +570,9 @@ func (vm *VM) run() (Addr, bool) {
 
 		// Concat
 		case OpConcat:
+			if limits != nil || profile != nil {
+				vm.alloc(len(vm.string(a))+len(vm.string(b)), 1)
+			}
 			vm.setString(c, vm.string(a)+vm.string(b))
 
 		// Copy
@@ -532,6 +582,12 @@ func (vm *VM) run() (Addr, bool) {
 				vm.setInt(b, int64(n))
 			}
 
+		// Cover
+		case OpCover:
+			if counters := vm.env.coverage; counters != nil {
+				atomic.AddUint32(&counters[decodeUint24(a, b, c)], 1)
+			}
+
 		// Defer
 		case OpDefer:
 			cl := vm.general(a).Interface().(*callable)
@@ -543,7 +599,7 @@ func (vm *VM) run() (Addr, bool) {
 				vm.fp[2] + Addr(off.B),
 				vm.fp[3] + Addr(off.C),
 			}
-			vm.swapStack(&vm.fp, &fp, StackShift{int8(arg.Op), arg.A, arg.B, arg.C})
+			vm.swapStack(&vm.fp, &fp, StackShift{int16(arg.Op), arg.A, arg.B, arg.C})
 			vm.calls = append(vm.calls, callFrame{cl: *cl, renderer: vm.renderer, fp: fp, pc: 0, status: deferred, numVariadic: c})
 			vm.pc += 2
 
@@ -593,7 +649,7 @@ func (vm *VM) run() (Addr, bool) {
 		// Field
 		case OpField:
 			v := vm.general(a)
-			vm.setFromReflectValue(c, vm.fieldByIndex(v, uint8(b)))
+			vm.setFromReflectValue(c, vm.fieldByIndex(v, uint16(b)))
 
 		// GetVar
 		case OpGetVar:
@@ -971,10 +1027,10 @@ func (vm *VM) run() (Addr, bool) {
 		case OpLoadFunc:
 			if a == 1 {
 				fn := callable{}
-				fn.native = vm.fn.NativeFunctions[uint8(b)]
+				fn.native = vm.fn.NativeFunctions[uint16(b)]
 				vm.setGeneral(c, reflect.ValueOf(&fn))
 			} else {
-				fn := vm.fn.Functions[uint8(b)]
+				fn := vm.fn.Functions[uint16(b)]
 				var vars []reflect.Value
 				if fn.VarRefs != nil {
 					vars = make([]reflect.Value, len(fn.VarRefs))
@@ -983,7 +1039,7 @@ func (vm *VM) run() (Addr, bool) {
 							// Calling Elem() is necessary because the general
 							// register contains an indirect value, that is
 							// stored as a pointer to a value.
-							vars[i] = vm.general(int8(-ref)).Elem()
+							vars[i] = vm.general(int16(-ref)).Elem()
 						} else {
 							vars[i] = vm.vars[ref]
 						}
@@ -996,12 +1052,12 @@ func (vm *VM) run() (Addr, bool) {
 
 		// MakeArray
 		case OpMakeArray:
-			t := vm.fn.Types[uint8(b)]
+			t := vm.fn.Types[uint16(b)]
 			vm.setGeneral(c, reflect.New(t).Elem())
 
 		// MakeChan
 		case OpMakeChan, -OpMakeChan:
-			typ := vm.fn.Types[uint8(a)]
+			typ := vm.fn.Types[uint16(a)]
 			buffer := int(vm.intk(b, op < 0))
 			var ch reflect.Value
 			if typ.ChanDir() == reflect.BothDir {
@@ -1014,8 +1070,11 @@ func (vm *VM) run() (Addr, bool) {
 
 		// MakeMap
 		case OpMakeMap, -OpMakeMap:
-			typ := vm.fn.Types[uint8(a)]
+			typ := vm.fn.Types[uint16(a)]
 			n := int(vm.intk(b, op < 0))
+			if limits != nil || profile != nil {
+				vm.alloc(n, typ.Key().Size()+typ.Elem().Size())
+			}
 			if n > 0 {
 				vm.setGeneral(c, reflect.MakeMapWithSize(typ, n))
 			} else {
@@ -1024,7 +1083,7 @@ func (vm *VM) run() (Addr, bool) {
 
 		// MakeSlice
 		case OpMakeSlice:
-			typ := vm.fn.Types[uint8(a)]
+			typ := vm.fn.Types[uint16(a)]
 			var len, cap int
 			if b > 0 {
 				next := vm.fn.Body[vm.pc]
@@ -1033,6 +1092,9 @@ func (vm *VM) run() (Addr, bool) {
 				capIsConst := (b & (1 << 2)) != 0
 				cap = int(vm.intk(next.B, capIsConst))
 			}
+			if limits != nil || profile != nil {
+				vm.alloc(cap, typ.Elem().Size())
+			}
 			vm.setGeneral(c, reflect.MakeSlice(typ, len, cap))
 			if b > 0 {
 				vm.pc++
@@ -1040,7 +1102,7 @@ func (vm *VM) run() (Addr, bool) {
 
 		// MakeStruct
 		case OpMakeStruct:
-			t := vm.fn.Types[uint8(b)]
+			t := vm.fn.Types[uint16(b)]
 			vm.setGeneral(c, reflect.New(t).Elem())
 
 		// MapIndex
@@ -1064,6 +1126,10 @@ func (vm *VM) run() (Addr, bool) {
 				panic(errNilPointer)
 			}
 			method := vm.stringk(b, true)
+			if m, rv, ok := vm.env.scriggoMethod(receiver, method); ok {
+				vm.setGeneral(c, reflect.ValueOf(&callable{value: vm.env.methodValue(m, rv)}))
+				break
+			}
 			vm.setGeneral(c, reflect.ValueOf(&callable{value: receiver.MethodByName(method)}))
 
 		// Move
@@ -1149,7 +1215,7 @@ func (vm *VM) run() (Addr, bool) {
 
 		// New
 		case OpNew:
-			t := vm.fn.Types[uint8(b)]
+			t := vm.fn.Types[uint16(b)]
 			vm.setGeneral(c, reflect.New(t))
 
 		// Or
@@ -1525,14 +1591,18 @@ func (vm *VM) run() (Addr, bool) {
 			if call.status == started {
 				if vm.fn.Macro {
 					if call.renderer != vm.renderer {
-						out := vm.renderer.Out()
-						if b, ok := out.(*macroOutBuffer); ok {
-							vm.setString(1, b.String())
+						err := vm.renderer.Wait()
+						if err != nil {
+							panic(outError{err})
 						}
-						err := vm.renderer.Close()
+						err = vm.renderer.Close()
 						if err != nil {
 							panic(&fatalError{env: vm.env, msg: err})
 						}
+						out := vm.renderer.Out()
+						if b, ok := out.(*macroOutBuffer); ok {
+							vm.setString(1, b.String())
+						}
 					}
 					vm.renderer = call.renderer
 				} else if regs := vm.fn.FinalRegs; regs != nil {
@@ -1608,7 +1678,7 @@ func (vm *VM) run() (Addr, bool) {
 		// SetField
 		case OpSetField, -OpSetField:
 			v := vm.general(b)
-			vm.getIntoReflectValue(a, vm.fieldByIndex(v, uint8(c)), op < 0)
+			vm.getIntoReflectValue(a, vm.fieldByIndex(v, uint16(c)), op < 0)
 
 		// SetMap
 		case OpSetMap, -OpSetMap:
@@ -1730,7 +1800,7 @@ func (vm *VM) run() (Addr, bool) {
 
 		// Show
 		case OpShow:
-			t := vm.fn.Types[uint8(a)]
+			t := vm.fn.Types[uint16(a)]
 			st, ok := t.(ScriggoType)
 			if ok {
 				t = st.GoType()
@@ -1738,7 +1808,7 @@ func (vm *VM) run() (Addr, bool) {
 			rv := reflect.New(t).Elem()
 			vm.getIntoReflectValue(b, rv, op < 0)
 			if st != nil {
-				rv = st.Wrap(rv)
+				rv = st.Wrap(rv, vm.env)
 			}
 			var v interface{}
 			if rv.IsValid() {
@@ -1855,20 +1925,20 @@ func (vm *VM) run() (Addr, bool) {
 					fn = closure.fn
 					vm.vars = closure.vars
 				} else {
-					fn = vm.fn.Functions[uint8(b)]
+					fn = vm.fn.Functions[uint16(b)]
 					vm.vars = vm.env.globals
 				}
-				if vm.fp[0]+Addr(fn.NumReg[0]) > vm.st[0] {
-					vm.moreIntStack()
+				if top := vm.fp[0] + Addr(fn.NumReg[0]); top >= vm.st[0] {
+					vm.moreIntStack(top)
 				}
-				if vm.fp[1]+Addr(fn.NumReg[1]) > vm.st[1] {
-					vm.moreFloatStack()
+				if top := vm.fp[1] + Addr(fn.NumReg[1]); top >= vm.st[1] {
+					vm.moreFloatStack(top)
 				}
-				if vm.fp[2]+Addr(fn.NumReg[2]) > vm.st[2] {
-					vm.moreStringStack()
+				if top := vm.fp[2] + Addr(fn.NumReg[2]); top >= vm.st[2] {
+					vm.moreStringStack(top)
 				}
-				if vm.fp[3]+Addr(fn.NumReg[3]) > vm.st[3] {
-					vm.moreGeneralStack()
+				if top := vm.fp[3] + Addr(fn.NumReg[3]); top >= vm.st[3] {
+					vm.moreGeneralStack(top)
 				}
 				vm.fn = fn
 			}
@@ -1877,15 +1947,18 @@ func (vm *VM) run() (Addr, bool) {
 		// Text
 		case OpText:
 			txt := vm.fn.Text[decodeUint16(a, b)]
-			inURL, isSet := c > 0, c == 2
+			inURL, isSet := c&3 > 0, c&3 == 2
 			err := vm.renderer.Text(txt, inURL, isSet)
+			if err == nil && c&4 != 0 {
+				err = vm.renderer.Nonce()
+			}
 			if err != nil {
 				panic(outError{err})
 			}
 
 		// Typify
 		case OpTypify, -OpTypify:
-			t := vm.fn.Types[uint8(a)]
+			t := vm.fn.Types[uint16(a)]
 			st, ok := t.(ScriggoType)
 			if ok {
 				t = st.GoType()
@@ -1893,7 +1966,7 @@ func (vm *VM) run() (Addr, bool) {
 			v := reflect.New(t).Elem()
 			vm.getIntoReflectValue(b, v, op < 0)
 			if st != nil {
-				v = st.Wrap(v)
+				v = st.Wrap(v, vm.env)
 			}
 			vm.setGeneral(c, v)
 
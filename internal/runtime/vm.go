@@ -42,8 +42,9 @@ type ScriggoType interface {
 	reflect.Type
 
 	// Wrap wraps a value with a Scriggo type putting into a proxy that exposes
-	// methods to Go.
-	Wrap(reflect.Value) reflect.Value
+	// methods to Go. The proxy calls the methods declared in Scriggo code
+	// through caller.
+	Wrap(v reflect.Value, caller MethodCaller) reflect.Value
 
 	// Unwrap unwraps a value that has been read from Go. If the value given as
 	// parameter can be unwrapped using the unwrapper's type, the unwrapped
@@ -55,28 +56,33 @@ type ScriggoType interface {
 	// implementation of the package 'reflect', so it's safe to pass the
 	// returned value to reflect functions and methods as argument.
 	GoType() reflect.Type
+
+	// ScriggoMethod returns the method, declared in Scriggo code, with the
+	// given name in the method set of the type and true. If there is no such
+	// method, it returns nil and false.
+	ScriggoMethod(name string) (*Method, bool)
 }
 
-type StackShift [4]int8
+type StackShift [4]int16
 
 type Instruction struct {
 	Op      Operation
-	A, B, C int8
+	A, B, C int16
 }
 
-func decodeInt16(a, b int8) int16 {
+func decodeInt16(a, b int16) int16 {
 	return int16(int(a)<<8 | int(uint8(b)))
 }
 
-func decodeUint16(a, b int8) uint16 {
+func decodeUint16(a, b int16) uint16 {
 	return uint16(uint8(a))<<8 | uint16(uint8(b))
 }
 
-func decodeUint24(a, b, c int8) uint32 {
+func decodeUint24(a, b, c int16) uint32 {
 	return uint32(uint8(a))<<16 | uint32(uint8(b))<<8 | uint32(uint8(c))
 }
 
-func decodeValueIndex(a, b int8) (t registerType, i int) {
+func decodeValueIndex(a, b int16) (t registerType, i int) {
 	return registerType(uint8(a) >> 6), int(decodeUint16(a, b) &^ (3 << 14))
 }
 
@@ -96,6 +102,8 @@ type VM struct {
 	cases    []reflect.SelectCase // select cases.
 	panic    *PanicError          // panic.
 	main     bool                 // reports whether this VM is executing the main goroutine.
+	debug    *debugger            // debugger.
+	profile  *Profile             // profile.
 }
 
 // NewVM returns a new virtual machine.
@@ -126,6 +134,8 @@ func (vm *VM) Reset() {
 		vm.cases = vm.cases[:0]
 	}
 	vm.panic = nil
+	vm.debug = nil
+	vm.profile = nil
 }
 
 // stop is called in the vm.run method to stop the execution.
@@ -144,13 +154,28 @@ func (vm *VM) stop() (Addr, bool) {
 // If a context has been set and the context is canceled, Run returns
 // as soon as possible with the error returned by the Err method of the
 // context.
+//
+// If limits have been set and a limit is exceeded, Run returns a *LimitError.
 func (vm *VM) Run(fn *Function, typeof TypeOfFunc, globals []reflect.Value) error {
 	if typeof == nil {
 		typeof = typeOfFunc
 	}
 	vm.env.typeof = typeof
 	vm.env.globals = globals
+	if vm.env.limits != nil {
+		cancel := vm.startLimits()
+		defer cancel()
+	}
+	if vm.profile != nil {
+		vm.profile.begin()
+	}
 	err := vm.runFunc(fn, globals)
+	if vm.profile != nil {
+		vm.profile.finish()
+	}
+	if err == nil && vm.renderer != nil {
+		err = vm.renderer.Wait()
+	}
 	if err != nil {
 		switch e := err.(type) {
 		case *PanicError:
@@ -193,6 +218,23 @@ func (vm *VM) SetRenderer(out io.Writer, conv Converter) {
 	vm.renderer = newRenderer(vm.env, out, conv)
 }
 
+// SetNonce sets the nonce added, as the value of the nonce attribute, to the
+// script and style start tags rendered by the Text instruction.
+//
+// SetNonce must not be called after vm has been started.
+func (vm *VM) SetNonce(nonce string) {
+	vm.env.nonce = nonce
+}
+
+// SetLocale sets the locale and the message catalog used to translate the
+// messages.
+//
+// SetLocale must not be called after vm has been started.
+func (vm *VM) SetLocale(locale string, catalog native.Catalog) {
+	vm.env.locale = locale
+	vm.env.catalog = catalog
+}
+
 // SetPrint sets the "print" builtin function.
 //
 // SetPrint must not be called after vm has been started.
@@ -257,7 +299,7 @@ func (vm *VM) Stack(buf []byte, all bool) int {
 //
 // When callNative is called, vm.pc must be the address of the call
 // instruction plus one.
-func (vm *VM) callNative(fn *NativeFunction, numVariadic int8, shift StackShift, asGoroutine bool) {
+func (vm *VM) callNative(fn *NativeFunction, numVariadic int16, shift StackShift, asGoroutine bool) {
 
 	if fn.value.IsNil() {
 		panic(errNilPointer)
@@ -309,6 +351,13 @@ func (vm *VM) callNative(fn *NativeFunction, numVariadic int8, shift StackShift,
 		return
 	}
 
+	// Call the function with its trampoline.
+	if fn.trampoline != nil && !asGoroutine {
+		fn.trampoline(fn.function, vm.trampolineRegisters())
+		vm.fp = fp
+		return
+	}
+
 	// Call the function with reflect.
 	var args []reflect.Value
 
@@ -335,11 +384,13 @@ func (vm *VM) callNative(fn *NativeFunction, numVariadic int8, shift StackShift,
 		for i := 0; i < nunIn; i++ {
 			if i < lastNonVariadic {
 				if i < 2 && typ.In(i) == envType {
-					// Set the path of the file that contains the call.
+					// Set the path of the file that contains the call and
+					// the renderer.
 					if vm.main {
 						env := vm.env
 						env.mu.Lock()
 						env.callPath = vm.fn.DebugInfo[vm.pc-1].Path
+						env.renderer = vm.renderer
 						env.mu.Unlock()
 					}
 					args[i].Set(vm.envArg)
@@ -354,32 +405,32 @@ func (vm *VM) callNative(fn *NativeFunction, numVariadic int8, shift StackShift,
 				switch k {
 				case reflect.Bool:
 					for j := 0; j < int(numVariadic); j++ {
-						slice.Index(j).SetBool(vm.bool(int8(j + 1)))
+						slice.Index(j).SetBool(vm.bool(int16(j + 1)))
 					}
 				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 					for j := 0; j < int(numVariadic); j++ {
-						slice.Index(j).SetInt(vm.int(int8(j + 1)))
+						slice.Index(j).SetInt(vm.int(int16(j + 1)))
 					}
 				case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
 					for j := 0; j < int(numVariadic); j++ {
-						slice.Index(j).SetUint(uint64(vm.int(int8(j + 1))))
+						slice.Index(j).SetUint(uint64(vm.int(int16(j + 1))))
 					}
 				case reflect.Float32, reflect.Float64:
 					for j := 0; j < int(numVariadic); j++ {
-						slice.Index(j).SetFloat(vm.float(int8(j + 1)))
+						slice.Index(j).SetFloat(vm.float(int16(j + 1)))
 					}
 				case reflect.Func:
 					for j := 0; j < int(numVariadic); j++ {
-						f := vm.general(int8(j + 1)).Interface().(*callable)
+						f := vm.general(int16(j + 1)).Interface().(*callable)
 						slice.Index(j).Set(f.Value(vm.renderer, vm.env))
 					}
 				case reflect.String:
 					for j := 0; j < int(numVariadic); j++ {
-						slice.Index(j).SetString(vm.string(int8(j + 1)))
+						slice.Index(j).SetString(vm.string(int16(j + 1)))
 					}
 				case reflect.Interface:
 					for j := 0; j < int(numVariadic); j++ {
-						if v := vm.general(int8(j + 1)); !v.IsValid() {
+						if v := vm.general(int16(j + 1)); !v.IsValid() {
 							if t := slice.Index(j).Type(); t == emptyInterfaceType {
 								slice.Index(j).Set(emptyInterfaceNil)
 							} else {
@@ -391,7 +442,7 @@ func (vm *VM) callNative(fn *NativeFunction, numVariadic int8, shift StackShift,
 					}
 				default:
 					for j := 0; j < int(numVariadic); j++ {
-						slice.Index(j).Set(vm.general(int8(j + 1)))
+						slice.Index(j).Set(vm.general(int16(j + 1)))
 					}
 				}
 				args[i].Set(slice)
@@ -440,6 +491,26 @@ func (vm *VM) callNative(fn *NativeFunction, numVariadic int8, shift StackShift,
 	return
 }
 
+// trampolineRegisters returns the registers of a call to a native function
+// with a trampoline. The frame pointer must point to the registers before
+// the results of the call.
+func (vm *VM) trampolineRegisters() native.Registers {
+	var regs native.Registers
+	if r := int(vm.fp[0]) + 1; r <= len(vm.regs.int) {
+		regs.Int = vm.regs.int[r:]
+	}
+	if r := int(vm.fp[1]) + 1; r <= len(vm.regs.float) {
+		regs.Float = vm.regs.float[r:]
+	}
+	if r := int(vm.fp[2]) + 1; r <= len(vm.regs.string) {
+		regs.String = vm.regs.string[r:]
+	}
+	if r := int(vm.fp[3]) + 1; r <= len(vm.regs.general) {
+		regs.General = vm.regs.general[r:]
+	}
+	return regs
+}
+
 // equals reports whether x and y are equal.
 // It panics if x and y are not comparable.
 //
@@ -472,7 +543,7 @@ func (vm *VM) equals(x, y reflect.Value) bool {
 //
 // It panics with errNilPointer if s represents a nil pointer or accessing
 // to a nil embedded struct.
-func (vm *VM) fieldByIndex(s reflect.Value, i uint8) reflect.Value {
+func (vm *VM) fieldByIndex(s reflect.Value, i uint16) reflect.Value {
 	v := s
 	for _, x := range vm.fn.FieldIndexes[i] {
 		if v.Kind() == reflect.Ptr {
@@ -486,42 +557,71 @@ func (vm *VM) fieldByIndex(s reflect.Value, i uint8) reflect.Value {
 	return v
 }
 
-func (vm *VM) finalize(regs [][2]int8) {
+func (vm *VM) finalize(regs [][2]int16) {
 	for _, reg := range regs {
 		vm.setFromReflectValue(reg[1], vm.generalIndirect(reg[0]))
 	}
 }
 
-func (vm *VM) moreIntStack() {
-	top := len(vm.regs.int) * 2
-	stack := make([]int64, top)
+// growStacks grows the stacks, if necessary, so that the registers of fn can
+// be addressed in the current frame.
+func (vm *VM) growStacks(fn *Function) {
+	if top := vm.fp[0] + Addr(fn.NumReg[0]); top >= vm.st[0] {
+		vm.moreIntStack(top)
+	}
+	if top := vm.fp[1] + Addr(fn.NumReg[1]); top >= vm.st[1] {
+		vm.moreFloatStack(top)
+	}
+	if top := vm.fp[2] + Addr(fn.NumReg[2]); top >= vm.st[2] {
+		vm.moreStringStack(top)
+	}
+	if top := vm.fp[3] + Addr(fn.NumReg[3]); top >= vm.st[3] {
+		vm.moreGeneralStack(top)
+	}
+}
+
+func (vm *VM) moreIntStack(top Addr) {
+	size := len(vm.regs.int) * 2
+	for Addr(size) <= top {
+		size *= 2
+	}
+	stack := make([]int64, size)
 	copy(stack, vm.regs.int)
 	vm.regs.int = stack
-	vm.st[0] = Addr(top)
+	vm.st[0] = Addr(size)
 }
 
-func (vm *VM) moreFloatStack() {
-	top := len(vm.regs.float) * 2
-	stack := make([]float64, top)
+func (vm *VM) moreFloatStack(top Addr) {
+	size := len(vm.regs.float) * 2
+	for Addr(size) <= top {
+		size *= 2
+	}
+	stack := make([]float64, size)
 	copy(stack, vm.regs.float)
 	vm.regs.float = stack
-	vm.st[1] = Addr(top)
+	vm.st[1] = Addr(size)
 }
 
-func (vm *VM) moreStringStack() {
-	top := len(vm.regs.string) * 2
-	stack := make([]string, top)
+func (vm *VM) moreStringStack(top Addr) {
+	size := len(vm.regs.string) * 2
+	for Addr(size) <= top {
+		size *= 2
+	}
+	stack := make([]string, size)
 	copy(stack, vm.regs.string)
 	vm.regs.string = stack
-	vm.st[2] = Addr(top)
+	vm.st[2] = Addr(size)
 }
 
-func (vm *VM) moreGeneralStack() {
-	top := len(vm.regs.general) * 2
-	stack := make([]reflect.Value, top)
+func (vm *VM) moreGeneralStack(top Addr) {
+	size := len(vm.regs.general) * 2
+	for Addr(size) <= top {
+		size *= 2
+	}
+	stack := make([]reflect.Value, size)
 	copy(stack, vm.regs.general)
 	vm.regs.general = stack
-	vm.st[3] = Addr(top)
+	vm.st[3] = Addr(size)
 }
 
 func (vm *VM) nextCall() bool {
@@ -625,13 +725,18 @@ func create(env *env) *VM {
 
 // startGoroutine starts a new goroutine to execute a function call at program
 // counter pc. If the function is native, returns true.
+//
+// In templates, if the function is a macro, or a native function that
+// returns a value of a format type, and the call instruction has a format,
+// its output is rendered asynchronously by the new goroutine.
 func (vm *VM) startGoroutine() bool {
 	var fn *Function
+	var native *NativeFunction
 	var vars []reflect.Value
 	call := vm.fn.Body[vm.pc]
 	switch call.Op {
 	case OpCallFunc:
-		fn = vm.fn.Functions[uint8(call.A)]
+		fn = vm.fn.Functions[uint16(call.A)]
 		vars = vm.env.globals
 	case OpCallIndirect:
 		f := vm.general(call.A).Interface().(*callable)
@@ -639,25 +744,96 @@ func (vm *VM) startGoroutine() bool {
 			if f.native.value.IsNil() {
 				panic(errors.New("fatal error: go of nil func value"))
 			}
-			return true
+			if call.B == ReturnString {
+				return true
+			}
+			// It is a native function, or a macro called through a native
+			// function value, whose result has to be rendered.
+			native = f.Native()
 		}
 		fn = f.fn
 		vars = f.vars
+	case OpCallMacro:
+		fn = vm.fn.Functions[uint16(call.A)]
+		vars = vm.env.globals
 	default:
 		return true
 	}
 	nvm := create(vm.env)
+	nvm.envArg = reflect.ValueOf(goroutineEnv{vm.env})
+	nvm.growStacks(vm.fn)
 	vm.pc++
 	off := vm.fn.Body[vm.pc]
-	copy(nvm.regs.int, vm.regs.int[vm.fp[0]+Addr(off.Op):vm.fp[0]+127])
-	copy(nvm.regs.float, vm.regs.float[vm.fp[1]+Addr(off.A):vm.fp[1]+127])
-	copy(nvm.regs.string, vm.regs.string[vm.fp[2]+Addr(off.B):vm.fp[2]+127])
-	copy(nvm.regs.general, vm.regs.general[vm.fp[3]+Addr(off.C):vm.fp[3]+127])
-	go nvm.runFunc(fn, vars)
+	n := vm.fn.NumReg
+	copy(nvm.regs.int, vm.regs.int[vm.fp[0]+Addr(off.Op):vm.fp[0]+Addr(n[0])+1])
+	copy(nvm.regs.float, vm.regs.float[vm.fp[1]+Addr(off.A):vm.fp[1]+Addr(n[1])+1])
+	copy(nvm.regs.string, vm.regs.string[vm.fp[2]+Addr(off.B):vm.fp[2]+Addr(n[2])+1])
+	copy(nvm.regs.general, vm.regs.general[vm.fp[3]+Addr(off.C):vm.fp[3]+Addr(n[3])+1])
+	to := ast.Format(call.B)
+	switch {
+	case native != nil:
+		part := vm.renderer.Async()
+		nvm.renderer = vm.renderer.WithOut(part)
+		info := vm.fn.DebugInfo[vm.pc-1]
+		go func() {
+			defer func() {
+				if msg := recover(); msg != nil {
+					part.End(asyncError(msg, info))
+				}
+			}()
+			part.End(nvm.renderNative(native, call.C, StackShift{}, to))
+		}()
+	case fn.Macro && call.B == ReturnString:
+		nvm.renderer = vm.renderer.WithOut(io.Discard)
+		go nvm.runFunc(fn, vars)
+	case fn.Macro:
+		part := vm.renderer.Async()
+		nvm.renderer = vm.renderer.WithOut(part)
+		if to != fn.Format {
+			nvm.renderer = nvm.renderer.WithConversion(fn.Format, to)
+		}
+		go func() {
+			err := nvm.runFunc(fn, vars)
+			if err == nil {
+				err = nvm.renderer.Wait()
+			}
+			if err == nil {
+				err = nvm.renderer.Close()
+			}
+			part.End(err)
+		}()
+	default:
+		go nvm.runFunc(fn, vars)
+	}
 	vm.pc++
 	return false
 }
 
+// renderNative calls the native function fn, that returns a value of a
+// format type, and renders the returned value in the format to. numVariadic
+// is the number of variadic arguments and shift is the stack shift.
+func (vm *VM) renderNative(fn *NativeFunction, numVariadic int16, shift StackShift, to ast.Format) error {
+	vm.callNative(fn, numVariadic, shift, false)
+	v := reflect.New(fn.value.Type().Out(0)).Elem()
+	v.SetString(vm.regs.string[vm.fp[2]+Addr(shift[2])+1])
+	return vm.renderer.Show(v.Interface(), Context(to))
+}
+
+// asyncError returns the error of an asynchronous rendering that panicked
+// with message msg. info is the debug information of the call instruction
+// that started the rendering.
+func asyncError(msg interface{}, info DebugInfo) error {
+	switch err := msg.(type) {
+	case *fatalError:
+		return err
+	case stopError:
+		return err
+	case outError:
+		return err.err
+	}
+	return &PanicError{message: msg, path: info.Path, position: info.Position}
+}
+
 // swapStack swaps the stacks pointed by a and b. bSize is the size of the
 // stack pointed by b. The stacks must be consecutive and a must precede b.
 //
@@ -669,8 +845,8 @@ func (vm *VM) swapStack(a, b *[4]Addr, bSize StackShift) {
 	bs := Addr(bSize[0])
 	if as > 0 && bs > 0 {
 		tot := as + bs
-		if a[0]+tot+bs > vm.st[0] {
-			vm.moreIntStack()
+		if top := a[0] + tot + bs; top >= vm.st[0] {
+			vm.moreIntStack(top)
 		}
 		s := vm.regs.int[a[0]+1:]
 		copy(s[bs:], s[:tot])
@@ -684,8 +860,8 @@ func (vm *VM) swapStack(a, b *[4]Addr, bSize StackShift) {
 	bs = Addr(bSize[1])
 	if as > 0 && bs > 0 {
 		tot := as + bs
-		if a[1]+tot+bs > vm.st[1] {
-			vm.moreFloatStack()
+		if top := a[1] + tot + bs; top >= vm.st[1] {
+			vm.moreFloatStack(top)
 		}
 		s := vm.regs.float[a[1]+1:]
 		copy(s[bs:], s[:tot])
@@ -699,8 +875,8 @@ func (vm *VM) swapStack(a, b *[4]Addr, bSize StackShift) {
 	bs = Addr(bSize[2])
 	if as > 0 && bs > 0 {
 		tot := as + bs
-		if a[2]+tot+bs > vm.st[2] {
-			vm.moreStringStack()
+		if top := a[2] + tot + bs; top >= vm.st[2] {
+			vm.moreStringStack(top)
 		}
 		s := vm.regs.string[a[2]+1:]
 		copy(s[bs:], s[:tot])
@@ -714,8 +890,8 @@ func (vm *VM) swapStack(a, b *[4]Addr, bSize StackShift) {
 	bs = Addr(bSize[3])
 	if as > 0 && bs > 0 {
 		tot := as + bs
-		if a[3]+tot+bs > vm.st[3] {
-			vm.moreGeneralStack()
+		if top := a[3] + tot + bs; top >= vm.st[3] {
+			vm.moreGeneralStack(top)
 		}
 		s := vm.regs.general[a[3]+1:]
 		copy(s[bs:], s[:tot])
@@ -740,13 +916,14 @@ type macroOutBuffer struct {
 }
 
 type NativeFunction struct {
-	pkg         string        // package.
-	name        string        // name.
-	function    interface{}   // value.
-	outOff      [4]int8       // offset of out arguments.
-	value       reflect.Value // reflect value.
-	reflectCall bool          // reports whether it can be called only with reflect.
-	argsPool    *sync.Pool    // pool of arguments for reflect.Call and reflect.CallSlice.
+	pkg         string            // package.
+	name        string            // name.
+	function    interface{}       // value.
+	outOff      [4]int16          // offset of out arguments.
+	value       reflect.Value     // reflect value.
+	reflectCall bool              // reports whether it can be called only with reflect.
+	trampoline  native.Trampoline // trampoline to call it without reflect; nil if there is no trampoline.
+	argsPool    *sync.Pool        // pool of arguments for reflect.Call and reflect.CallSlice.
 }
 
 // NewNativeFunction returns a new native function given its package and name
@@ -778,6 +955,7 @@ func NewNativeFunction(pkg, name string, function interface{}) *NativeFunction {
 	case func(string, string) bool:
 	default:
 		fn.reflectCall = true
+		fn.trampoline = native.LookupTrampoline(typ)
 		if numIn := typ.NumIn(); numIn > 0 {
 			fn.argsPool = &sync.Pool{
 				New: func() interface{} {
@@ -816,8 +994,8 @@ type Function struct {
 	Parent          *Function
 	VarRefs         []int16
 	Types           []reflect.Type
-	NumReg          [4]int8
-	FinalRegs       [][2]int8 // [indirect -> return parameter registers]
+	NumReg          [4]int16
+	FinalRegs       [][2]int16 // [indirect -> return parameter registers]
 	Macro           bool
 	Format          ast.Format
 	Values          Registers
@@ -827,6 +1005,7 @@ type Function struct {
 	Body            []Instruction
 	Text            [][]byte
 	DebugInfo       map[Addr]DebugInfo
+	Locals          []Local
 }
 
 // Position represents a source position.
@@ -848,6 +1027,7 @@ type DebugInfo struct {
 	Path        string          // path of the source code where the instruction is located in.
 	OperandKind [3]reflect.Kind // kind of operands A, B and C.
 	FuncType    reflect.Type    // type of the function that is called; only for call instructions.
+	Statement   bool            // reports whether it is the first instruction of a statement.
 }
 
 type Addr uint32
@@ -870,7 +1050,7 @@ type callFrame struct {
 	fp          [4]Addr    // frame pointers.
 	pc          Addr       // program counter.
 	status      callStatus // status.
-	numVariadic int8       // number of variadic arguments.
+	numVariadic int16      // number of variadic arguments.
 }
 
 type callable struct {
@@ -904,60 +1084,75 @@ func (c *callable) Value(renderer *renderer, env *env) reflect.Value {
 	fn := c.fn
 	vars := c.vars
 	c.value = reflect.MakeFunc(fn.Type, func(args []reflect.Value) []reflect.Value {
-		nvm := create(env)
-		if fn.Macro {
-			renderer = renderer.WithOut(&macroOutBuffer{})
-		}
-		nvm.renderer = renderer
-		nOut := fn.Type.NumOut()
-		results := make([]reflect.Value, nOut)
-		var r = [4]int8{1, 1, 1, 1}
-		for i := 0; i < nOut; i++ {
-			typ := fn.Type.Out(i)
-			results[i] = reflect.New(typ).Elem()
-			t := kindToType[typ.Kind()]
-			r[t]++
-		}
-		for _, arg := range args {
-			t := kindToType[arg.Kind()]
-			nvm.setFromReflectValue(r[t], arg)
-			r[t]++
+		return env.callFunc(fn, vars, renderer, args)
+	})
+	return c.value
+}
+
+// callFunc calls the Scriggo function fn, with non-local variables vars and
+// the given arguments, in a new VM and returns the results. It is used to
+// call Scriggo functions from native code.
+func (env *env) callFunc(fn *Function, vars []reflect.Value, renderer *renderer, args []reflect.Value) []reflect.Value {
+	nvm := create(env)
+	nvm.growStacks(fn)
+	if fn.Macro {
+		renderer = renderer.WithOut(&macroOutBuffer{})
+	}
+	nvm.renderer = renderer
+	nOut := fn.Type.NumOut()
+	results := make([]reflect.Value, nOut)
+	var r = [4]int16{1, 1, 1, 1}
+	for i := 0; i < nOut; i++ {
+		typ := fn.Type.Out(i)
+		if st, ok := typ.(ScriggoType); ok {
+			typ = st.GoType()
 		}
-		err := nvm.runFunc(fn, vars)
-		if err != nil {
-			if p, ok := err.(*PanicError); ok {
-				var msg string
-				for ; p != nil; p = p.next {
-					msg = "\n" + msg
-					if p.recovered {
-						msg = " [recovered]" + msg
-					}
-					msg = p.String() + msg
-					if p.next != nil {
-						msg = "\tpanic: " + msg
-					}
+		results[i] = reflect.New(typ).Elem()
+		t := kindToType[typ.Kind()]
+		r[t]++
+	}
+	for _, arg := range args {
+		t := kindToType[arg.Kind()]
+		nvm.setFromReflectValue(r[t], arg)
+		r[t]++
+	}
+	err := nvm.runFunc(fn, vars)
+	if err != nil {
+		if p, ok := err.(*PanicError); ok {
+			var msg string
+			for ; p != nil; p = p.next {
+				msg = "\n" + msg
+				if p.recovered {
+					msg = " [recovered]" + msg
+				}
+				msg = p.String() + msg
+				if p.next != nil {
+					msg = "\tpanic: " + msg
 				}
-				err = &fatalError{msg: msg}
 			}
-			panic(err)
+			err = &fatalError{msg: msg}
 		}
-		if fn.Macro {
-			b := renderer.Out().(*macroOutBuffer)
-			nvm.setString(1, b.String())
-			err := renderer.Close()
-			if err != nil {
-				panic(&fatalError{env: env, msg: err})
-			}
+		panic(err)
+	}
+	if fn.Macro {
+		err := renderer.Wait()
+		if err != nil {
+			panic(outError{err})
 		}
-		r = [4]int8{1, 1, 1, 1}
-		for _, result := range results {
-			t := kindToType[result.Kind()]
-			nvm.getIntoReflectValue(r[t], result, false)
-			r[t]++
+		err = renderer.Close()
+		if err != nil {
+			panic(&fatalError{env: env, msg: err})
 		}
-		return results
-	})
-	return c.value
+		b := renderer.Out().(*macroOutBuffer)
+		nvm.setString(1, b.String())
+	}
+	r = [4]int16{1, 1, 1, 1}
+	for _, result := range results {
+		t := kindToType[result.Kind()]
+		nvm.getIntoReflectValue(r[t], result, false)
+		r[t]++
+	}
+	return results
 }
 
 func packageName(pkg string) string {
@@ -1037,7 +1232,7 @@ const (
 	ConditionNotOK                                 // ![vm.ok]
 )
 
-type Operation int8
+type Operation int16
 
 const (
 	OpNone Operation = iota
@@ -1086,6 +1281,8 @@ const (
 
 	OpCopy
 
+	OpCover
+
 	OpDefer
 
 	OpDelete
@@ -0,0 +1,105 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scriggo
+
+import (
+	"io"
+	"testing"
+
+	"github.com/open2b/scriggo/internal/fstest"
+)
+
+var limitsProgramTests = []struct {
+	src      string
+	options  RunOptions
+	limit    string
+	position string
+}{
+	{
+		src:      "package main\n\nfunc main() {\n\tfor {\n\t}\n}",
+		options:  RunOptions{MaxInstructions: 1000},
+		limit:    "instructions",
+		position: "4:2",
+	},
+	{
+		src:      "package main\n\nfunc f(n int) int {\n\treturn f(n + 1)\n}\n\nfunc main() {\n\tf(0)\n}",
+		options:  RunOptions{MaxCallDepth: 50},
+		limit:    "call depth",
+		position: "4:10",
+	},
+	{
+		src:      "package main\n\nfunc main() {\n\ts := make([]int, 10)\n\t_ = make([]int, 1000)\n\t_ = s\n}",
+		options:  RunOptions{MaxAllocBytes: 1024},
+		limit:    "allocated bytes",
+		position: "5:10",
+	},
+	{
+		src:      "package main\n\nfunc main() {\n\tvar s []int\n\tfor i := 0; i < 1000; i++ {\n\t\ts = append(s, i)\n\t}\n}",
+		options:  RunOptions{MaxAllocBytes: 1024},
+		limit:    "allocated bytes",
+		position: "6:3",
+	},
+	{
+		src:      "package main\n\nfunc main() {\n\ts := \"a\"\n\tfor {\n\t\ts = s + s\n\t}\n}",
+		options:  RunOptions{MaxAllocBytes: 1 << 20},
+		limit:    "allocated bytes",
+		position: "6:3",
+	},
+	{
+		src:      "package main\n\nfunc main() {\n\tc := make(chan int)\n\tgo func() {\n\t\tfor {\n\t\t}\n\t}()\n\t<-c\n}",
+		options:  RunOptions{MaxInstructions: 10000},
+		limit:    "instructions",
+		position: "6:3",
+	},
+}
+
+func TestLimitsProgram(t *testing.T) {
+	for _, test := range limitsProgramTests {
+		program, err := Build(fstest.Files{"main.go": test.src}, &BuildOptions{AllowGoStmt: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = program.Run(&test.options)
+		if err == nil {
+			t.Fatalf("expected limit %q exceeded, got no error", test.limit)
+		}
+		e, ok := err.(*LimitError)
+		if !ok {
+			t.Fatalf("expected *LimitError, got %T: %s", err, err)
+		}
+		if e.Limit() != test.limit {
+			t.Fatalf("expected limit %q, got %q", test.limit, e.Limit())
+		}
+		if e.Path() != "main.go" {
+			t.Fatalf("expected path %q, got %q", "main.go", e.Path())
+		}
+		if pos := e.Position().String(); pos != test.position {
+			t.Fatalf("%s: expected position %s, got %s", test.limit, test.position, pos)
+		}
+	}
+}
+
+func TestLimitsTemplate(t *testing.T) {
+	files := fstest.Files{"index.html": "{% for i := 0; i < 100; i++ %}\n{{ i }}\n{% end %}"}
+	template, err := BuildTemplate(files, "index.html", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = template.Run(io.Discard, nil, &RunOptions{MaxOutputBytes: 1000})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = template.Run(io.Discard, nil, &RunOptions{MaxOutputBytes: 100})
+	e, ok := err.(*LimitError)
+	if !ok {
+		t.Fatalf("expected *LimitError, got %T: %v", err, err)
+	}
+	if e.Limit() != "output bytes" {
+		t.Fatalf("expected limit %q, got %q", "output bytes", e.Limit())
+	}
+	if e.Error() != "index.html:2:1: output bytes limit exceeded" {
+		t.Fatalf("unexpected error %q", e.Error())
+	}
+}
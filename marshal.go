@@ -0,0 +1,120 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scriggo
+
+import (
+	"errors"
+
+	"github.com/open2b/scriggo/internal/compiler"
+	"github.com/open2b/scriggo/internal/runtime"
+	"github.com/open2b/scriggo/native"
+)
+
+// natives contains the native declarations available to a compiled template
+// or program. They are used to encode the references to the native
+// declarations when the template or the program is marshaled.
+type natives struct {
+	globals  native.Declarations
+	packages native.Importer
+	imported []string // paths of the imported packages.
+}
+
+// MarshalBinary returns the binary encoding of the template. It implements
+// the encoding.BinaryMarshaler interface.
+//
+// The native declarations, the ones in the globals and in the imported
+// packages, are not encoded but only referenced; they are resolved when the
+// template is loaded with LoadTemplate.
+func (t *Template) MarshalBinary() ([]byte, error) {
+	code := &compiler.Code{
+		Globals:        t.globals,
+		Main:           t.fn,
+		NativePackages: t.natives.imported,
+		Coverage:       t.cover,
+	}
+	return compiler.Marshal(code, compiler.Options{
+		FormatTypes: formatTypes,
+		Globals:     t.natives.globals,
+		Importer:    t.natives.packages,
+	})
+}
+
+// LoadTemplate loads a template from its binary encoding, as returned by
+// the MarshalBinary method of Template.
+//
+// The native declarations referenced by the template are resolved using the
+// Globals and Packages options, that must declare them with the same names
+// and types used when the template has been built. MarkdownConverter is used
+// as markdown converter. Other options are ignored.
+func LoadTemplate(data []byte, options *BuildOptions) (*Template, error) {
+	co := compiler.Options{
+		FormatTypes: formatTypes,
+	}
+	var conv Converter
+	if options != nil {
+		co.Globals = options.Globals
+		co.Importer = options.Packages
+		conv = options.MarkdownConverter
+	}
+	code, err := compiler.Unmarshal(data, co)
+	if err != nil {
+		return nil, err
+	}
+	if !code.Main.Macro {
+		return nil, errors.New("scriggo: data does not contain a template")
+	}
+	t := &Template{
+		fn:      code.Main,
+		typeof:  code.TypeOf,
+		globals: code.Globals,
+		conv:    runtime.Converter(conv),
+		natives: natives{globals: co.Globals, packages: co.Importer, imported: code.NativePackages},
+		cover:   code.Coverage,
+	}
+	return t, nil
+}
+
+// MarshalBinary returns the binary encoding of the program. It implements
+// the encoding.BinaryMarshaler interface.
+//
+// The declarations of the imported native packages are not encoded but only
+// referenced; they are resolved when the program is loaded with LoadProgram.
+func (p *Program) MarshalBinary() ([]byte, error) {
+	code := &compiler.Code{
+		Globals:        p.globals,
+		Main:           p.fn,
+		NativePackages: p.natives.imported,
+		Coverage:       p.cover,
+	}
+	return compiler.Marshal(code, compiler.Options{Importer: p.natives.packages})
+}
+
+// LoadProgram loads a program from its binary encoding, as returned by the
+// MarshalBinary method of Program.
+//
+// The native declarations referenced by the program are resolved using the
+// Packages option, that must declare them with the same names and types used
+// when the program has been built. Other options are ignored.
+func LoadProgram(data []byte, options *BuildOptions) (*Program, error) {
+	co := compiler.Options{}
+	if options != nil {
+		co.Importer = options.Packages
+	}
+	code, err := compiler.Unmarshal(data, co)
+	if err != nil {
+		return nil, err
+	}
+	if code.Main.Macro {
+		return nil, errors.New("scriggo: data does not contain a program")
+	}
+	p := &Program{
+		fn:      code.Main,
+		globals: code.Globals,
+		typeof:  code.TypeOf,
+		natives: natives{packages: co.Importer, imported: code.NativePackages},
+		cover:   code.Coverage,
+	}
+	return p, nil
+}
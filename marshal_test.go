@@ -0,0 +1,235 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scriggo
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/open2b/scriggo/internal/fstest"
+	"github.com/open2b/scriggo/native"
+)
+
+type marshalPoint struct {
+	X, Y int
+}
+
+var marshalCount = 5
+
+var marshalTemplateOptions = &BuildOptions{
+	Globals: native.Declarations{
+		"title": (*string)(nil),
+		"count": &marshalCount,
+		"upper": strings.ToUpper,
+		"Point": reflect.TypeOf(marshalPoint{}),
+		"strconv": native.Package{
+			Name: "strconv",
+			Declarations: native.Declarations{
+				"Itoa": strconv.Itoa,
+			},
+		},
+	},
+	Packages: native.Packages{
+		"strings": native.Package{
+			Name: "strings",
+			Declarations: native.Declarations{
+				"Builder": reflect.TypeOf(strings.Builder{}),
+				"Repeat":  strings.Repeat,
+			},
+		},
+	},
+}
+
+var marshalTemplateFiles = fstest.Files{
+	"index.html": `{% import "imp.html" %}{% import "strings" %}` +
+		`{% macro M(s string) %}<b>{{ s }}</b>{% end %}` +
+		`{{ title }} {{ upper("a") }} {{ strconv.Itoa(count) }} {{ strings.Repeat("x", 3) }} ` +
+		`{% var b strings.Builder %}{% b.WriteString("w") %}{{ b.String() }} {{ M("m") }} {{ Imp() }} ` +
+		`{% c := 2i %}{{ real(c * c) }} {% f := func(n int) int { return n * 2 } %}{{ f(count) }} ` +
+		`{% type Pair struct { A, B int } %}{% p := Pair{1, 2} %}{{ p.A + p.B }} ` +
+		`{% for _, pt := range []Point{{X: 1, Y: 2}} %}{{ pt.X + pt.Y }}{% end %}`,
+	"imp.html": `{% macro Imp %}<i>imp</i>{% end %}`,
+}
+
+func TestTemplateMarshalBinary(t *testing.T) {
+	template, err := BuildTemplate(marshalTemplateFiles, "index.html", marshalTemplateOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := template.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadTemplate(data, marshalTemplateOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]interface{}{"title": "<title>"}
+	var expected, got strings.Builder
+	err = template.Run(&expected, vars, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = loaded.Run(&got, vars, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != expected.String() {
+		t.Fatalf("expected %q, got %q", expected.String(), got.String())
+	}
+	// Marshal the loaded template.
+	data2, err := loaded.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data2) != string(data) {
+		t.Fatalf("unexpected different encoding after loading")
+	}
+}
+
+func TestLoadTemplateErrors(t *testing.T) {
+	template, err := BuildTemplate(marshalTemplateFiles, "index.html", marshalTemplateOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := template.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		data     []byte
+		options  *BuildOptions
+		expected string
+	}{
+		{nil, nil, "scriggo: invalid marshaled code"},
+		{[]byte("scriggo"), nil, "scriggo: invalid marshaled code"},
+		{data[:len(data)-1], marshalTemplateOptions, "scriggo: invalid marshaled code"},
+		{append(data, 0), marshalTemplateOptions, "scriggo: invalid marshaled code"},
+		{data, &BuildOptions{Globals: marshalTemplateOptions.Globals}, "scriggo: cannot load code: Builder in package \"strings\" is not declared"},
+		{data, &BuildOptions{Packages: marshalTemplateOptions.Packages}, "scriggo: cannot load code: global Point is not declared"},
+	}
+	for _, test := range tests {
+		_, err := LoadTemplate(test.data, test.options)
+		if err == nil {
+			t.Fatalf("expected error %q, got no error", test.expected)
+		}
+		if err.Error() != test.expected {
+			t.Fatalf("expected error %q, got %q", test.expected, err)
+		}
+	}
+	template, err = BuildTemplate(fstest.Files{"index.txt": "a"}, "index.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = template.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadProgram(data, nil)
+	if err == nil || err.Error() != "scriggo: data does not contain a program" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestTemplateMarshalBinaryError(t *testing.T) {
+	globals := native.Declarations{"upper": strings.ToUpper}
+	files := fstest.Files{"index.txt": `{{ upper("a") }}`}
+	template, err := BuildTemplate(files, "index.txt", &BuildOptions{Globals: globals})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(globals, "upper")
+	_, err = template.MarshalBinary()
+	expected := "scriggo: cannot marshal code: native function upper is not declared in globals or in an imported package"
+	if err == nil {
+		t.Fatalf("expected error %q, got no error", expected)
+	}
+	if err.Error() != expected {
+		t.Fatalf("expected error %q, got %q", expected, err)
+	}
+}
+
+func TestProgramMarshalBinary(t *testing.T) {
+	packages := native.Packages{
+		"fmt": native.Package{
+			Name: "fmt",
+			Declarations: native.Declarations{
+				"Sprint": fmt.Sprint,
+			},
+		},
+		"strings": native.Package{
+			Name: "strings",
+			Declarations: native.Declarations{
+				"Repeat": strings.Repeat,
+			},
+		},
+	}
+	files := fstest.Files{
+		"go.mod": "module example.com/main",
+		"main.go": `package main
+
+		import (
+			"fmt"
+			"strings"
+		)
+
+		type T struct{ s string }
+
+		func (t T) String() string { return "<" + t.s + ">" }
+
+		func (t *T) Set(s string) { t.s = s }
+
+		type Setter interface{ Set(string) }
+
+		type N struct{ *T }
+
+		func main() {
+			defer print("!")
+			t := T{strings.Repeat("a", 2)}
+			print(t.s, len([]T{t}))
+			var s Setter = &t
+			s.Set("b")
+			print(fmt.Sprint(t, &t))
+			n := N{&t}
+			s = n
+			s.Set("c")
+			N.Set(n, "d")
+			print(fmt.Sprint(n))
+			var c complex128 = 3i
+			print(-c)
+		}`,
+	}
+	program, err := Build(files, &BuildOptions{Packages: packages})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := program.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadProgram(data, &BuildOptions{Packages: packages})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected, got strings.Builder
+	err = program.Run(&RunOptions{Print: func(v interface{}) { fmt.Fprint(&expected, v) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = loaded.Run(&RunOptions{Print: func(v interface{}) { fmt.Fprint(&got, v) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != expected.String() {
+		t.Fatalf("expected %q, got %q", expected.String(), got.String())
+	}
+	_, err = LoadTemplate(data, &BuildOptions{Packages: packages})
+	if err == nil || err.Error() != "scriggo: data does not contain a template" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
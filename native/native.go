@@ -28,6 +28,15 @@ type (
 
 	// Markdown is the markdown type in templates.
 	Markdown string
+
+	// XML is the xml type in templates.
+	XML string
+
+	// YAML is the yaml type in templates.
+	YAML string
+
+	// TOML is the toml type in templates.
+	TOML string
 )
 
 // Env represents an execution environment.
@@ -65,6 +74,66 @@ type Env interface {
 	TypeOf(v reflect.Value) reflect.Type
 }
 
+// RenderEnv is implemented by the Env value of the executions of templates
+// and programs. It adds to Env the methods to flush the output and to read
+// the nonce, the locale and the message catalog passed as options for
+// execution.
+//
+// These methods are not part of Env so that the existing implementations
+// of Env continue to implement it. A native function that calls them has to
+// type-assert its Env parameter, for example
+//
+//	func Locale(env native.Env) string {
+//		if env, ok := env.(native.RenderEnv); ok {
+//			return env.Locale()
+//		}
+//		return ""
+//	}
+//
+type RenderEnv interface {
+	Env
+
+	// Catalog returns the message catalog used to translate the messages.
+	// It is the catalog passed as an option for execution, nil if no
+	// catalog has been passed.
+	Catalog() Catalog
+
+	// Flush flushes the output written so far, if the template output has a
+	// Flush method, as an http.ResponseWriter implementing http.Flusher or a
+	// *bufio.Writer. The Markdown code converted to HTML is flushed up to the
+	// last complete block. It does nothing if it is called by a function
+	// executed in a goroutine started by the go statement, as only the main
+	// goroutine can write to the output.
+	//
+	// If the Flush method of the output returns an error, a panic occurs as
+	// if the output Write method returned the error.
+	Flush()
+
+	// Locale returns the locale, as a BCP 47 language tag as "en-US", used to
+	// translate the messages and to format numbers and dates. It is the
+	// locale passed as an option for execution.
+	Locale() string
+
+	// Nonce returns the nonce of the script and style elements. It is the
+	// nonce passed as an option for execution.
+	Nonce() string
+}
+
+// Catalog is implemented by the message catalogs that translate the messages
+// of a program or template in a locale.
+type Catalog interface {
+
+	// Translate returns the translation of the message msg in the context
+	// ctx, that is empty for messages without a context. If plural is not
+	// empty, msg and plural are the singular and the plural form of the
+	// message in the source language and n selects the plural form of the
+	// translation.
+	//
+	// If the message has no translation, Translate returns msg, or plural
+	// if it is not empty and n is not 1.
+	Translate(ctx, msg, plural string, n int) string
+}
+
 type (
 
 	// EnvStringer is like fmt.Stringer where the String method takes an native.Env
@@ -130,6 +199,42 @@ type (
 	MarkdownEnvStringer interface {
 		Markdown(Env) Markdown
 	}
+
+	// XMLStringer is implemented by values that are not escaped in XML
+	// context.
+	XMLStringer interface {
+		XML() XML
+	}
+
+	// XMLEnvStringer is like XMLStringer where the XML method takes an Env
+	// parameter.
+	XMLEnvStringer interface {
+		XML(Env) XML
+	}
+
+	// YAMLStringer is implemented by values that are not escaped in YAML
+	// context.
+	YAMLStringer interface {
+		YAML() YAML
+	}
+
+	// YAMLEnvStringer is like YAMLStringer where the YAML method takes an
+	// Env parameter.
+	YAMLEnvStringer interface {
+		YAML(Env) YAML
+	}
+
+	// TOMLStringer is implemented by values that are not escaped in TOML
+	// context.
+	TOMLStringer interface {
+		TOML() TOML
+	}
+
+	// TOMLEnvStringer is like TOMLStringer where the TOML method takes an
+	// Env parameter.
+	TOMLEnvStringer interface {
+		TOML(Env) TOML
+	}
 )
 
 // Declaration represents a declaration.
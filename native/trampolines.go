@@ -0,0 +1,78 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package native
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Registers represents the registers of a call to a native function made
+// with a Trampoline.
+//
+// The registers of each kind hold first the results of the call and then its
+// arguments, in the order in which they are declared. For example, for a
+// function of type func(string, int) (string, error), the first argument is
+// String[1], the second argument is Int[0], the first result is String[0] and
+// the second result is General[0].
+type Registers struct {
+	Int     []int64         // bool, integer and unsigned integer values.
+	Float   []float64       // floating-point values.
+	String  []string        // string values.
+	General []reflect.Value // values of any other type.
+}
+
+// Trampoline calls the native function fn, without using the reflection,
+// reading the arguments from regs and writing the results to regs. fn has
+// always the type for which the Trampoline has been registered.
+//
+// A nil interface value is stored in a general register as the zero Value.
+type Trampoline func(fn interface{}, regs Registers)
+
+var trampolines = struct {
+	sync.RWMutex
+	m map[reflect.Type]Trampoline
+}{m: map[reflect.Type]Trampoline{}}
+
+var envType = reflect.TypeOf((*Env)(nil)).Elem()
+
+// RegisterTrampoline registers t as the trampoline for the native functions
+// of type typ. If a trampoline for typ is already registered, it is replaced.
+// The scriggo import command generates the calls to RegisterTrampoline for
+// the signatures of the imported functions.
+//
+// RegisterTrampoline panics if typ is not a function type, if it is variadic
+// or if one of its parameters or results has a function type or the Env
+// type.
+func RegisterTrampoline(typ reflect.Type, t Trampoline) {
+	if typ.Kind() != reflect.Func {
+		panic("native: trampoline type is not a function type")
+	}
+	if typ.IsVariadic() {
+		panic("native: trampoline type is variadic")
+	}
+	for i := 0; i < typ.NumIn(); i++ {
+		if in := typ.In(i); in.Kind() == reflect.Func || in == envType {
+			panic("native: trampoline type has a parameter of type " + in.String())
+		}
+	}
+	for i := 0; i < typ.NumOut(); i++ {
+		if out := typ.Out(i); out.Kind() == reflect.Func || out == envType {
+			panic("native: trampoline type has a result of type " + out.String())
+		}
+	}
+	trampolines.Lock()
+	trampolines.m[typ] = t
+	trampolines.Unlock()
+}
+
+// LookupTrampoline returns the trampoline registered for the native functions
+// of type typ, or nil if no trampoline has been registered.
+func LookupTrampoline(typ reflect.Type) Trampoline {
+	trampolines.RLock()
+	t := trampolines.m[typ]
+	trampolines.RUnlock()
+	return t
+}
@@ -0,0 +1,222 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scriggo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/open2b/scriggo/internal/fstest"
+)
+
+// testProfile is a decoded profile. values contains, for each call stack
+// formatted as "function file:line" frames separated by ";" and starting from
+// the outermost frame, the values of the samples.
+type testProfile struct {
+	sampleTypes []string
+	values      map[string][3]int64
+}
+
+// protoField is a field of a protocol buffer message.
+type protoField struct {
+	num   int
+	value uint64 // value of a varint field.
+	data  []byte // value of a length-delimited field.
+}
+
+// decodeProto decodes the fields of a protocol buffer message with only
+// varint and length-delimited fields.
+func decodeProto(t *testing.T, data []byte) []protoField {
+	var fields []protoField
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		data = data[n:]
+		f := protoField{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.value, n = binary.Uvarint(data)
+			data = data[n:]
+		case 2:
+			size, n := binary.Uvarint(data)
+			f.data = data[n : n+int(size)]
+			data = data[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// decodePacked decodes a packed repeated varint field.
+func decodePacked(data []byte) []uint64 {
+	var values []uint64
+	for len(data) > 0 {
+		v, n := binary.Uvarint(data)
+		values = append(values, v)
+		data = data[n:]
+	}
+	return values
+}
+
+// decodeTestProfile decodes a gzip-compressed profile in the pprof format.
+func decodeTestProfile(t *testing.T, data []byte) testProfile {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := decodeProto(t, data)
+	var table []string
+	for _, f := range fields {
+		if f.num == 6 {
+			table = append(table, string(f.data))
+		}
+	}
+	p := testProfile{values: map[string][3]int64{}}
+	functions := map[uint64]string{}
+	locations := map[uint64]string{}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			p.sampleTypes = append(p.sampleTypes, table[decodeProto(t, f.data)[0].value])
+		case 5:
+			var id uint64
+			var name, file string
+			for _, ff := range decodeProto(t, f.data) {
+				switch ff.num {
+				case 1:
+					id = ff.value
+				case 2:
+					name = table[ff.value]
+				case 4:
+					file = table[ff.value]
+				}
+			}
+			functions[id] = name + " " + file
+		}
+	}
+	for _, f := range fields {
+		if f.num != 4 {
+			continue
+		}
+		var id, fn, line uint64
+		for _, ff := range decodeProto(t, f.data) {
+			switch ff.num {
+			case 1:
+				id = ff.value
+			case 4:
+				for _, lf := range decodeProto(t, ff.data) {
+					switch lf.num {
+					case 1:
+						fn = lf.value
+					case 2:
+						line = lf.value
+					}
+				}
+			}
+		}
+		locations[id] = fmt.Sprintf("%s:%d", functions[fn], line)
+	}
+	for _, f := range fields {
+		if f.num != 2 {
+			continue
+		}
+		var stack []string
+		var values []uint64
+		for _, ff := range decodeProto(t, f.data) {
+			switch ff.num {
+			case 1:
+				for _, id := range decodePacked(ff.data) {
+					stack = append([]string{locations[id]}, stack...)
+				}
+			case 2:
+				values = decodePacked(ff.data)
+			}
+		}
+		key := strings.Join(stack, ";")
+		v := p.values[key]
+		for i := range v {
+			v[i] += int64(values[i])
+		}
+		p.values[key] = v
+	}
+	return p
+}
+
+// total returns the sum of the values of the sample type with index i of the
+// call stacks that contain frame.
+func (p testProfile) total(i int, frame string) int64 {
+	var n int64
+	for stack, values := range p.values {
+		if strings.Contains(";"+stack+";", ";"+frame+";") {
+			n += values[i]
+		}
+	}
+	return n
+}
+
+func TestProfileProgram(t *testing.T) {
+	src := "package main\n\nfunc f(n int) []int {\n\treturn make([]int, n)\n}\n\nfunc main() {\n\tfor i := 0; i < 10; i++ {\n\t\t_ = f(100)\n\t}\n}\n"
+	program, err := Build(fstest.Files{"main.go": src}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	err = program.Run(&RunOptions{Profile: &b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := decodeTestProfile(t, b.Bytes())
+	if got := strings.Join(p.sampleTypes, ","); got != "instructions,time,alloc_space" {
+		t.Fatalf("unexpected sample types %q", got)
+	}
+	// The allocations of f are attributed to the line of make.
+	if allocs := p.total(2, "main.f main.go:4"); allocs != 10*100*8 {
+		t.Fatalf("expected 8000 allocated bytes in f, got %d", allocs)
+	}
+	if allocs := p.total(2, "main.main main.go:9"); allocs != 10*100*8 {
+		t.Fatalf("expected 8000 allocated bytes in the calls to f, got %d", allocs)
+	}
+	instructions := p.total(0, "main.f main.go:4")
+	if instructions == 0 || instructions%10 != 0 {
+		t.Fatalf("unexpected %d instructions in f", instructions)
+	}
+	if p.total(1, "main.f main.go:4") <= 0 {
+		t.Fatal("expected elapsed time in f")
+	}
+}
+
+func TestProfileTemplate(t *testing.T) {
+	files := fstest.Files{
+		"index.html":  "{% extends \"layout.html\" %}\n{% macro Item(i int) %}\n<li>{{ i }}</li>\n{% end %}",
+		"layout.html": "<ul>\n{% for i := 0; i < 5; i++ %}\n{{ Item(i) }}\n{% end %}\n</ul>",
+	}
+	template, err := BuildTemplate(files, "index.html", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	err = template.Run(io.Discard, nil, &RunOptions{Profile: &b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := decodeTestProfile(t, b.Bytes())
+	instructions := p.total(0, "main.Item index.html:3")
+	if instructions == 0 || instructions%5 != 0 {
+		t.Fatalf("unexpected %d instructions in Item", instructions)
+	}
+	if got := p.total(0, "main.main layout.html:3"); got < instructions {
+		t.Fatalf("expected at least %d instructions in the calls to Item, got %d", instructions, got)
+	}
+}
@@ -7,6 +7,7 @@ package scriggo
 import (
 	"context"
 	"errors"
+	"io"
 	"io/fs"
 	"reflect"
 
@@ -20,6 +21,16 @@ import (
 type BuildOptions struct {
 
 	// AllowGoStmt, when true, allows the use of the go statement.
+	//
+	// In templates, a go statement that calls a macro renders the macro
+	// concurrently and writes its output in place of the statement, when
+	// ready, keeping the order of the output. For example
+	//
+	//   {% go Sidebar() %}{% go Content() %}
+	//
+	// renders Sidebar and Content in parallel. With a using statement, as in
+	// {% go itea(); using macro %}...{% end using %}, the content of the
+	// using is rendered concurrently.
 	AllowGoStmt bool
 
 	// Packages is a package importer that makes native packages available
@@ -56,6 +67,20 @@ type BuildOptions struct {
 	//
 	// Used for templates only.
 	DollarIdentifier bool
+
+	// Optimize, when true, optimizes the compiled code. The optimization
+	// inlines the calls to small functions and macros, folds the constant
+	// expressions and removes the code that has no effect.
+	//
+	// An optimized program or template behaves as the non-optimized one,
+	// but its build takes longer.
+	Optimize bool
+
+	// Coverage, when true, instruments the code so that its coverage can be
+	// collected running it with the Coverage run option. The bodies of the
+	// if, switch, select and for statements and of the functions and macros
+	// are instrumented. The instrumented code runs slower.
+	Coverage bool
 }
 
 // PrintFunc represents a function that prints the arguments of the print and
@@ -74,6 +99,74 @@ type RunOptions struct {
 	// If it is nil, the print and println builtins format their arguments as
 	// expected and write the result to standard error.
 	Print PrintFunc
+
+	// Debugger, if not nil, is the debugger that controls the execution.
+	Debugger Debugger
+
+	// MaxInstructions, if greater than zero, is the maximum number of
+	// instructions that can be executed.
+	MaxInstructions int64
+
+	// MaxCallDepth, if greater than zero, is the maximum depth of the call
+	// stack of a goroutine.
+	MaxCallDepth int
+
+	// MaxAllocBytes, if greater than zero, is the maximum number of bytes
+	// that can be allocated making slices and maps, appending to slices and
+	// concatenating strings.
+	MaxAllocBytes int64
+
+	// MaxOutputBytes, if greater than zero, is the maximum number of bytes
+	// that can be written to the output.
+	//
+	// Used for templates only.
+	MaxOutputBytes int64
+
+	// Nonce, if not empty, is added as the value of the nonce attribute to the
+	// script and style start tags of an HTML template, as required by a
+	// Content Security Policy. Native functions can read it via the Nonce
+	// method of native.RenderEnv.
+	//
+	// Used for templates only.
+	Nonce string
+
+	// Locale is the locale, as a BCP 47 language tag as "en-US", in which
+	// the messages are translated and the numbers and dates are formatted.
+	// Native functions can read it via the Locale method of
+	// native.RenderEnv.
+	Locale string
+
+	// Catalog, if not nil, is the catalog that translates the messages in
+	// the locale. Native functions can read it via the Catalog method of
+	// native.RenderEnv. The i18n package implements a catalog read from a
+	// gettext PO file.
+	Catalog native.Catalog
+
+	// Profile, if not nil, is where the profile of the execution is written
+	// when the execution terminates, also if it terminates with an error.
+	// The profile attributes the executed instructions, the elapsed time and
+	// the bytes allocated by make, append and string concatenation to the
+	// functions, macros and source lines. Only the main goroutine is
+	// profiled and profiling slows down the execution.
+	//
+	// The profile is in the pprof format and can be read with the
+	// 'go tool pprof' command.
+	Profile io.Writer
+
+	// Coverage, if not nil, collects the coverage of the execution. The
+	// coverage is collected only if the program or template has been built
+	// with the Coverage option.
+	Coverage *Coverage
+}
+
+// limits returns the limits of the execution.
+func (options *RunOptions) limits() runtime.Limits {
+	return runtime.Limits{
+		Instructions: options.MaxInstructions,
+		CallDepth:    options.MaxCallDepth,
+		Allocs:       options.MaxAllocBytes,
+		Output:       options.MaxOutputBytes,
+	}
 }
 
 // Program is a program compiled with the Build function.
@@ -81,12 +174,17 @@ type Program struct {
 	fn      *runtime.Function
 	typeof  runtime.TypeOfFunc
 	globals []compiler.Global
+	natives natives
+	cover   []runtime.CoverBlock
 }
 
 // Build builds a program from the package in the root of fsys with the given
 // options.
 //
-// Current limitation: fsys can contain only one Go file in its root.
+// The package is made of all the Go files in the root of fsys, except the
+// test files and the files excluded by the build constraints. The build
+// constraints are evaluated for the operating system and architecture of the
+// running program, with cgo disabled.
 //
 // If a build error occurs, it returns a *BuildError.
 func Build(fsys fs.FS, options *BuildOptions) (*Program, error) {
@@ -94,6 +192,8 @@ func Build(fsys fs.FS, options *BuildOptions) (*Program, error) {
 	if options != nil {
 		co.AllowGoStmt = options.AllowGoStmt
 		co.Importer = options.Packages
+		co.Optimize = options.Optimize
+		co.Coverage = options.Coverage
 	}
 	code, err := compiler.BuildProgram(fsys, co)
 	if err != nil {
@@ -102,7 +202,14 @@ func Build(fsys fs.FS, options *BuildOptions) (*Program, error) {
 		}
 		return nil, err
 	}
-	return &Program{fn: code.Main, globals: code.Globals, typeof: code.TypeOf}, nil
+	p := &Program{
+		fn:      code.Main,
+		globals: code.Globals,
+		typeof:  code.TypeOf,
+		natives: natives{packages: co.Importer, imported: code.NativePackages},
+		cover:   code.Coverage,
+	}
+	return p, nil
 }
 
 // Disassemble disassembles the package with the given path and returns its
@@ -130,8 +237,15 @@ func (p *Program) Disassemble(pkgPath string) ([]byte, error) {
 //
 // If the context has been canceled, Run returns the error returned by the Err
 // method of the context.
+//
+// If a limit set in the options is exceeded, Run returns a *LimitError.
+//
+// If the profile cannot be written, Run returns the error returned by the
+// Write method of Profile in the options.
 func (p *Program) Run(options *RunOptions) error {
 	vm := runtime.NewVM()
+	var profile *runtime.Profile
+	var counters []uint32
 	if options != nil {
 		if options.Context != nil {
 			vm.SetContext(options.Context)
@@ -139,11 +253,35 @@ func (p *Program) Run(options *RunOptions) error {
 		if options.Print != nil {
 			vm.SetPrint(runtime.PrintFunc(options.Print))
 		}
+		if options.Debugger != nil {
+			vm.SetDebugger(newDebugger(options.Debugger, p.globals))
+		}
+		vm.SetLimits(options.limits())
+		vm.SetLocale(options.Locale, options.Catalog)
+		if options.Profile != nil {
+			profile = runtime.NewProfile()
+			vm.SetProfile(profile)
+		}
+		if options.Coverage != nil && p.cover != nil {
+			counters = make([]uint32, len(p.cover))
+			vm.SetCoverage(counters)
+		}
 	}
 	err := vm.Run(p.fn, p.typeof, initPackageLevelVariables(p.globals))
+	if profile != nil {
+		if err2 := profile.Write(options.Profile); err == nil {
+			err = err2
+		}
+	}
+	if counters != nil {
+		options.Coverage.add(p.cover, counters)
+	}
 	if err != nil {
-		if p, ok := err.(*runtime.PanicError); ok {
-			err = &PanicError{p}
+		switch e := err.(type) {
+		case *runtime.PanicError:
+			err = &PanicError{e}
+		case *runtime.LimitError:
+			err = &LimitError{e}
 		}
 		return err
 	}
@@ -10,6 +10,7 @@ package scripts
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
@@ -33,6 +34,9 @@ type BuildOptions struct {
 	// Globals declares constants, types, variables, functions and packages
 	// that are accessible from the code in the script.
 	Globals native.Declarations
+
+	// Optimize, when true, optimizes the compiled code.
+	Optimize bool
 }
 
 // RunOptions are the run options.
@@ -54,6 +58,8 @@ type Script struct {
 	fn      *runtime.Function
 	typeof  runtime.TypeOfFunc
 	globals []compiler.Global
+	options compiler.Options // globals and importer, used by MarshalBinary.
+	imports []string         // paths of the imported native packages.
 }
 
 // Build builds a script reading the source code from src.
@@ -65,6 +71,7 @@ func Build(src io.Reader, options *BuildOptions) (*Script, error) {
 		co.Globals = options.Globals
 		co.AllowGoStmt = options.AllowGoStmt
 		co.Importer = options.Packages
+		co.Optimize = options.Optimize
 	}
 	code, err := compiler.BuildScript(src, co)
 	if err != nil {
@@ -73,7 +80,58 @@ func Build(src io.Reader, options *BuildOptions) (*Script, error) {
 		}
 		return nil, err
 	}
-	return &Script{fn: code.Main, globals: code.Globals, typeof: code.TypeOf}, nil
+	script := &Script{
+		fn:      code.Main,
+		globals: code.Globals,
+		typeof:  code.TypeOf,
+		options: compiler.Options{Globals: co.Globals, Importer: co.Importer},
+		imports: code.NativePackages,
+	}
+	return script, nil
+}
+
+// Load loads a script from its binary encoding, as returned by the
+// MarshalBinary method of Script.
+//
+// The native declarations referenced by the script are resolved using the
+// Globals and Packages options, that must declare them with the same names
+// and types used when the script has been built. Other options are ignored.
+func Load(data []byte, options *BuildOptions) (*Script, error) {
+	co := compiler.Options{}
+	if options != nil {
+		co.Globals = options.Globals
+		co.Importer = options.Packages
+	}
+	code, err := compiler.Unmarshal(data, co)
+	if err != nil {
+		return nil, err
+	}
+	if code.Main.Macro {
+		return nil, errors.New("scriggo: data does not contain a script")
+	}
+	script := &Script{
+		fn:      code.Main,
+		globals: code.Globals,
+		typeof:  code.TypeOf,
+		options: co,
+		imports: code.NativePackages,
+	}
+	return script, nil
+}
+
+// MarshalBinary returns the binary encoding of the script. It implements the
+// encoding.BinaryMarshaler interface.
+//
+// The native declarations, the ones in the globals and in the imported
+// packages, are not encoded but only referenced; they are resolved when the
+// script is loaded with Load.
+func (p *Script) MarshalBinary() ([]byte, error) {
+	code := &compiler.Code{
+		Globals:        p.globals,
+		Main:           p.fn,
+		NativePackages: p.imports,
+	}
+	return compiler.Marshal(code, p.options)
 }
 
 // Disassemble disassembles the script and returns its assembly code.
@@ -0,0 +1,346 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package templatecache provides a concurrency-safe cache of templates built
+// with the scriggo.BuildTemplate function.
+//
+// A template is built the first time it is requested and then it is kept in
+// the cache until a file that it depends on, the template file itself or a
+// file that it imports, renders or extends, is invalidated.
+//
+// For example, to build and cache the templates in the "templates" directory,
+// and rebuild them when they are changed
+//
+//    cache, err := templatecache.NewDir("templates", nil)
+//    if err != nil {
+//        log.Fatal(err)
+//    }
+//    defer cache.Close()
+//
+//    template, err := cache.Get("index.html")
+//    if err != nil {
+//        return err
+//    }
+//    err = template.Run(w, nil, nil)
+//
+package templatecache
+
+import (
+	"container/list"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/open2b/scriggo"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Options contains options for a cache.
+type Options struct {
+
+	// BuildOptions are the options used to build the templates.
+	BuildOptions *scriggo.BuildOptions
+
+	// MaxTemplates is the maximum number of templates kept in the cache. When
+	// the limit is reached, the least recently used template is evicted. Zero
+	// means no limit.
+	MaxTemplates int
+}
+
+// Cache is a cache of templates. It builds the templates on first use and
+// keeps them until they are invalidated or evicted. Its methods can be
+// called concurrently by multiple goroutines.
+type Cache struct {
+	fsys    fs.FS
+	options scriggo.BuildOptions
+	max     int
+	watcher *fsnotify.Watcher
+	dir     string
+
+	mu         sync.Mutex
+	templates  map[string]*list.Element // templates by name.
+	lru        *list.List               // cached templates, most recently used first.
+	dependents map[string]map[string]struct{}
+	building   map[string]*build
+	version    int // incremented on each invalidation.
+	watched    map[string]bool
+}
+
+// entry is a cached template.
+type entry struct {
+	name         string
+	template     *scriggo.Template
+	dependencies []string
+}
+
+// build represents a build in progress. Get calls for a template that is
+// being built wait for the build in progress and share its result.
+type build struct {
+	done     chan struct{}
+	template *scriggo.Template
+	err      error
+}
+
+// New returns a new cache that builds the templates from the files in fsys.
+// Files are not watched for changes; call the Invalidate method when a file
+// changes.
+//
+// If fsys implements scriggo.FormatFS, file formats are read with its Format
+// method.
+func New(fsys fs.FS, options *Options) *Cache {
+	c := &Cache{
+		fsys:       fsys,
+		templates:  map[string]*list.Element{},
+		lru:        list.New(),
+		dependents: map[string]map[string]struct{}{},
+		building:   map[string]*build{},
+	}
+	if options != nil {
+		if options.BuildOptions != nil {
+			c.options = *options.BuildOptions
+		}
+		c.max = options.MaxTemplates
+	}
+	return c
+}
+
+// NewDir returns a new cache that builds the templates from the files in the
+// directory dir. The files read to build the templates are watched for
+// changes and, when a file changes, the templates that depend on it are
+// invalidated.
+//
+// The Close method must be called to stop watching the files.
+func NewDir(dir string, options *Options) (*Cache, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	c := New(os.DirFS(dir), options)
+	c.watcher = watcher
+	c.dir = dir
+	c.watched = map[string]bool{}
+	go c.watch()
+	return c, nil
+}
+
+// Get returns the named template, building it if it is not in the cache.
+// Concurrent calls to Get for the same template build it only once.
+//
+// If the named file does not exist, Get returns an error satisfying
+// errors.Is(err, fs.ErrNotExist). If a build error occurs, it returns a
+// *scriggo.BuildError. Errors are not cached.
+func (c *Cache) Get(name string) (*scriggo.Template, error) {
+	c.mu.Lock()
+	if e, ok := c.templates[name]; ok {
+		c.lru.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*entry).template, nil
+	}
+	if b, ok := c.building[name]; ok {
+		c.mu.Unlock()
+		<-b.done
+		return b.template, b.err
+	}
+	b := &build{done: make(chan struct{})}
+	c.building[name] = b
+	version := c.version
+	c.mu.Unlock()
+
+	fsys := newRecorderFS(c.fsys)
+	b.template, b.err = scriggo.BuildTemplate(fsys, name, &c.options)
+	dependencies := fsys.names()
+
+	c.mu.Lock()
+	delete(c.building, name)
+	// Do not cache the template if a file has been invalidated during the
+	// build, as the template could have been built from a stale file.
+	if b.err == nil && version == c.version {
+		c.add(name, b.template, dependencies)
+	}
+	if c.watcher != nil {
+		c.watchFiles(dependencies)
+	}
+	c.mu.Unlock()
+	close(b.done)
+
+	return b.template, b.err
+}
+
+// Invalidate invalidates the named file, removing from the cache the
+// templates that depend on it.
+func (c *Cache) Invalidate(name string) {
+	c.mu.Lock()
+	c.invalidate(name)
+	c.mu.Unlock()
+}
+
+// InvalidateAll removes all the templates from the cache.
+func (c *Cache) InvalidateAll() {
+	c.mu.Lock()
+	c.templates = map[string]*list.Element{}
+	c.lru.Init()
+	c.dependents = map[string]map[string]struct{}{}
+	c.version++
+	c.mu.Unlock()
+}
+
+// Len returns the number of templates in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	n := c.lru.Len()
+	c.mu.Unlock()
+	return n
+}
+
+// Close stops watching the files, if the cache has been created with NewDir.
+// The cache can still be used after Close, but the files are no longer
+// watched.
+func (c *Cache) Close() error {
+	if c.watcher == nil {
+		return nil
+	}
+	return c.watcher.Close()
+}
+
+// add adds a template to the cache, evicting the least recently used
+// template if the maximum number of templates is exceeded.
+func (c *Cache) add(name string, template *scriggo.Template, dependencies []string) {
+	e := &entry{name: name, template: template, dependencies: dependencies}
+	c.templates[name] = c.lru.PushFront(e)
+	for _, dep := range dependencies {
+		dependents, ok := c.dependents[dep]
+		if !ok {
+			dependents = map[string]struct{}{}
+			c.dependents[dep] = dependents
+		}
+		dependents[name] = struct{}{}
+	}
+	if c.max > 0 && c.lru.Len() > c.max {
+		c.remove(c.lru.Back().Value.(*entry))
+	}
+}
+
+// remove removes the entry e from the cache.
+func (c *Cache) remove(e *entry) {
+	c.lru.Remove(c.templates[e.name])
+	delete(c.templates, e.name)
+	for _, dep := range e.dependencies {
+		dependents := c.dependents[dep]
+		delete(dependents, e.name)
+		if len(dependents) == 0 {
+			delete(c.dependents, dep)
+		}
+	}
+}
+
+// invalidate invalidates the named file.
+func (c *Cache) invalidate(name string) {
+	c.version++
+	for dependent := range c.dependents[name] {
+		c.remove(c.templates[dependent].Value.(*entry))
+	}
+}
+
+// watch receives the events from the watcher and invalidates the changed
+// files. It returns when the watcher is closed.
+func (c *Cache) watch() {
+	for {
+		select {
+		case event, ok := <-c.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			name, err := filepath.Rel(c.dir, event.Name)
+			if err != nil {
+				continue
+			}
+			c.Invalidate(filepath.ToSlash(name))
+		case _, ok := <-c.watcher.Errors:
+			if !ok {
+				return
+			}
+			// Some events could have been lost.
+			c.InvalidateAll()
+		}
+	}
+}
+
+// watchFiles watches the directories of the given files. Directories are
+// watched, instead of files, to be notified also when a file is replaced or
+// created.
+func (c *Cache) watchFiles(names []string) {
+	for _, name := range names {
+		dir := path.Dir(name)
+		if c.watched[dir] {
+			continue
+		}
+		err := c.watcher.Add(filepath.Join(c.dir, filepath.FromSlash(dir)))
+		if err == nil {
+			c.watched[dir] = true
+		}
+	}
+}
+
+// recorderFS wraps a file system and records the names of the files that are
+// read.
+type recorderFS struct {
+	fsys fs.FS
+	mu   sync.Mutex
+	read map[string]struct{}
+}
+
+// recorderFormatFS is a recorderFS that implements scriggo.FormatFS.
+type recorderFormatFS struct {
+	*recorderFS
+}
+
+func (fsys recorderFormatFS) Format(name string) (scriggo.Format, error) {
+	return fsys.fsys.(scriggo.FormatFS).Format(name)
+}
+
+// newRecorderFS returns a recorder file system that wraps fsys.
+func newRecorderFS(fsys fs.FS) interface {
+	fs.ReadFileFS
+	names() []string
+} {
+	r := &recorderFS{fsys: fsys, read: map[string]struct{}{}}
+	if _, ok := fsys.(scriggo.FormatFS); ok {
+		return recorderFormatFS{r}
+	}
+	return r
+}
+
+func (fsys *recorderFS) Open(name string) (fs.File, error) {
+	fsys.record(name)
+	return fsys.fsys.Open(name)
+}
+
+func (fsys *recorderFS) ReadFile(name string) ([]byte, error) {
+	fsys.record(name)
+	return fs.ReadFile(fsys.fsys, name)
+}
+
+// record records the file with the given name.
+func (fsys *recorderFS) record(name string) {
+	fsys.mu.Lock()
+	fsys.read[name] = struct{}{}
+	fsys.mu.Unlock()
+}
+
+// names returns the names of the read files.
+func (fsys *recorderFS) names() []string {
+	fsys.mu.Lock()
+	names := make([]string, 0, len(fsys.read))
+	for name := range fsys.read {
+		names = append(names, name)
+	}
+	fsys.mu.Unlock()
+	return names
+}
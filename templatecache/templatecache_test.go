@@ -0,0 +1,185 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package templatecache
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/open2b/scriggo"
+	"github.com/open2b/scriggo/ast"
+	"github.com/open2b/scriggo/internal/fstest"
+)
+
+// countBuilds returns options that count the builds in n.
+func countBuilds(n *int32) *scriggo.BuildOptions {
+	return &scriggo.BuildOptions{
+		TreeTransformer: func(*ast.Tree) error {
+			atomic.AddInt32(n, 1)
+			return nil
+		},
+	}
+}
+
+// render gets the named template from c and renders it.
+func render(t *testing.T, c *Cache, name string) string {
+	t.Helper()
+	template, err := c.Get(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var b strings.Builder
+	err = template.Run(&b, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return b.String()
+}
+
+func TestCache(t *testing.T) {
+	fsys := fstest.Files{
+		"index.html":   `a{{ render "partial.html" }}c`,
+		"partial.html": `b`,
+		"other.html":   `d`,
+	}
+	var builds int32
+	c := New(fsys, &Options{BuildOptions: countBuilds(&builds)})
+	if out := render(t, c, "index.html"); out != "abc" {
+		t.Fatalf("expected %q, got %q", "abc", out)
+	}
+	if out := render(t, c, "index.html"); out != "abc" {
+		t.Fatalf("expected %q, got %q", "abc", out)
+	}
+	if builds != 1 {
+		t.Fatalf("expected 1 build, got %d", builds)
+	}
+	c.Invalidate("other.html")
+	if c.Len() != 1 {
+		t.Fatalf("expected 1 template, got %d", c.Len())
+	}
+	fsys["partial.html"] = `B`
+	c.Invalidate("partial.html")
+	if c.Len() != 0 {
+		t.Fatalf("expected no templates, got %d", c.Len())
+	}
+	if out := render(t, c, "index.html"); out != "aBc" {
+		t.Fatalf("expected %q, got %q", "aBc", out)
+	}
+	if builds != 2 {
+		t.Fatalf("expected 2 builds, got %d", builds)
+	}
+	c.InvalidateAll()
+	if c.Len() != 0 {
+		t.Fatalf("expected no templates, got %d", c.Len())
+	}
+}
+
+func TestCacheErrors(t *testing.T) {
+	fsys := fstest.Files{
+		"index.html": `{{ a }}`,
+	}
+	c := New(fsys, nil)
+	_, err := c.Get("missing.html")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected a not exist error, got %v", err)
+	}
+	_, err = c.Get("index.html")
+	if _, ok := err.(*scriggo.BuildError); !ok {
+		t.Fatalf("expected a build error, got %v", err)
+	}
+	if c.Len() != 0 {
+		t.Fatalf("expected no templates, got %d", c.Len())
+	}
+}
+
+func TestCacheSingleFlight(t *testing.T) {
+	fsys := fstest.Files{
+		"index.html": `a`,
+	}
+	var builds int32
+	c := New(fsys, &Options{BuildOptions: countBuilds(&builds)})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Get("index.html")
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if builds != 1 {
+		t.Fatalf("expected 1 build, got %d", builds)
+	}
+}
+
+func TestCacheMaxTemplates(t *testing.T) {
+	fsys := fstest.Files{
+		"a.html": `a`,
+		"b.html": `b`,
+		"c.html": `c`,
+	}
+	var builds int32
+	c := New(fsys, &Options{BuildOptions: countBuilds(&builds), MaxTemplates: 2})
+	render(t, c, "a.html")
+	render(t, c, "b.html")
+	render(t, c, "a.html")
+	render(t, c, "c.html") // evicts b.html.
+	if c.Len() != 2 {
+		t.Fatalf("expected 2 templates, got %d", c.Len())
+	}
+	render(t, c, "a.html")
+	if builds != 3 {
+		t.Fatalf("expected 3 builds, got %d", builds)
+	}
+	render(t, c, "b.html")
+	if builds != 4 {
+		t.Fatalf("expected 4 builds, got %d", builds)
+	}
+}
+
+func TestNewDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.Mkdir(filepath.Join(dir, "partials"), 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("index.html", `a{{ render "partials/b.html" }}`)
+	write("partials/b.html", `b`)
+	c, err := NewDir(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if out := render(t, c, "index.html"); out != "ab" {
+		t.Fatalf("expected %q, got %q", "ab", out)
+	}
+	write("partials/b.html", `B`)
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("template has not been invalidated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if out := render(t, c, "index.html"); out != "aB" {
+		t.Fatalf("expected %q, got %q", "aB", out)
+	}
+}
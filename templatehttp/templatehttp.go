@@ -0,0 +1,372 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package templatehttp provides an HTTP handler that serves the templates of
+// a file system.
+//
+// For example, to serve the templates in the "site" directory
+//
+//    h := templatehttp.New(os.DirFS("site"), &templatehttp.Options{
+//        BuildOptions: &scriggo.BuildOptions{
+//            Globals: native.Declarations{
+//                "form": (*builtin.FormData)(nil),
+//            },
+//        },
+//        Static: http.FileServer(http.Dir("site")),
+//    })
+//    log.Fatal(http.ListenAndServe(":8080", h))
+//
+package templatehttp
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/open2b/scriggo"
+	"github.com/open2b/scriggo/builtin"
+	"github.com/open2b/scriggo/native"
+	"github.com/open2b/scriggo/templatecache"
+)
+
+// defaultMaxMemory is the maximum memory passed to builtin.NewFormData by
+// the default vars.
+const defaultMaxMemory = 32 << 20
+
+// Options contains options for a handler.
+type Options struct {
+
+	// BuildOptions are the options used to build the templates.
+	BuildOptions *scriggo.BuildOptions
+
+	// RunOptions are the options used to run the templates.
+	RunOptions *scriggo.RunOptions
+
+	// MaxTemplates is the maximum number of templates kept in the cache.
+	// Zero means no limit.
+	MaxTemplates int
+
+	// Vars returns the values of the global variables for the request r.
+	// If it is nil, the "form" variable is set to the value returned by
+	// builtin.NewFormData.
+	Vars func(r *http.Request) map[string]interface{}
+
+	// ErrorTemplate, if not empty, is the name of the template rendered when
+	// an error occurs. The template can use, in addition to the globals in
+	// BuildOptions, the global variables "status", with the HTTP status code,
+	// and "statusText", with its text.
+	ErrorTemplate string
+
+	// ContentTypes contains the Content-Type headers of the responses for
+	// the template formats. It overrides the default content types.
+	ContentTypes map[scriggo.Format]string
+
+	// Static, if not nil, serves the requests for files that are not
+	// templates.
+	Static http.Handler
+
+	// ErrorLog specifies an optional logger for errors that occur while
+	// building and running templates. If nil, errors are logged using the
+	// standard logger of the log package.
+	ErrorLog *log.Logger
+}
+
+// defaultContentTypes contains the default Content-Type headers. Markdown
+// templates are served as HTML as they usually extend an HTML layout.
+var defaultContentTypes = map[scriggo.Format]string{
+	scriggo.FormatText:     "text/plain; charset=utf-8",
+	scriggo.FormatHTML:     "text/html; charset=utf-8",
+	scriggo.FormatCSS:      "text/css; charset=utf-8",
+	scriggo.FormatJS:       "text/javascript; charset=utf-8",
+	scriggo.FormatJSON:     "application/json",
+	scriggo.FormatMarkdown: "text/html; charset=utf-8",
+	scriggo.FormatXML:      "application/xml; charset=utf-8",
+	scriggo.FormatYAML:     "application/yaml; charset=utf-8",
+	scriggo.FormatTOML:     "application/toml; charset=utf-8",
+}
+
+// Handler is an HTTP handler that serves the templates of a file system.
+//
+// A request path is mapped to the template with the same name. If the path
+// ends with a slash, "index" is appended to it and, if the path has no
+// extension, the template with the extension ".html" is tried first and then
+// the one with extension ".md". If the path has the extension of a template
+// format, such as ".xml" for "sitemap.xml" or ".atom" for "feed.atom", the
+// template is rendered or, if it does not exist, the request is served by the
+// static handler, if any. Requests for files with other extensions are served
+// by the static handler, if any.
+//
+// The rendered template is sent when its execution ends, so that an error
+// page can be served if an error occurs, unless the template flushes the
+// output with the Flush method of native.RenderEnv, for example calling the
+// builtin.Flush function. In this case, the output rendered so far is sent
+// immediately and the errors that occur later are only logged.
+type Handler struct {
+	fsys         fs.FS
+	templates    *templatecache.Cache
+	errors       *templatecache.Cache
+	runOptions   *scriggo.RunOptions
+	vars         func(r *http.Request) map[string]interface{}
+	errTemplate  string
+	contentTypes map[scriggo.Format]string
+	static       http.Handler
+	errorLog     *log.Logger
+}
+
+// New returns a new handler that serves the templates in fsys.
+//
+// Templates are built on first request and cached. Call the Invalidate
+// method when a file in fsys changes.
+func New(fsys fs.FS, options *Options) *Handler {
+	h := &Handler{
+		fsys:         fsys,
+		contentTypes: defaultContentTypes,
+	}
+	var cacheOptions templatecache.Options
+	if options != nil {
+		cacheOptions.BuildOptions = options.BuildOptions
+		cacheOptions.MaxTemplates = options.MaxTemplates
+		h.runOptions = options.RunOptions
+		h.vars = options.Vars
+		h.errTemplate = options.ErrorTemplate
+		if options.ContentTypes != nil {
+			h.contentTypes = make(map[scriggo.Format]string, len(defaultContentTypes))
+			for format, typ := range defaultContentTypes {
+				h.contentTypes[format] = typ
+			}
+			for format, typ := range options.ContentTypes {
+				h.contentTypes[format] = typ
+			}
+		}
+		h.static = options.Static
+		h.errorLog = options.ErrorLog
+	}
+	h.templates = templatecache.New(fsys, &cacheOptions)
+	if h.errTemplate != "" {
+		// The error template is built with the additional globals "status"
+		// and "statusText".
+		var buildOptions scriggo.BuildOptions
+		if cacheOptions.BuildOptions != nil {
+			buildOptions = *cacheOptions.BuildOptions
+		}
+		globals := make(native.Declarations, len(buildOptions.Globals)+2)
+		for name, value := range buildOptions.Globals {
+			globals[name] = value
+		}
+		globals["status"] = (*int)(nil)
+		globals["statusText"] = (*string)(nil)
+		buildOptions.Globals = globals
+		h.errors = templatecache.New(fsys, &templatecache.Options{BuildOptions: &buildOptions})
+	}
+	return h
+}
+
+// Invalidate invalidates the named file, so that the templates that depend on
+// it are built again on the next request.
+func (h *Handler) Invalidate(name string) {
+	h.templates.Invalidate(name)
+	if h.errors != nil {
+		h.errors.Invalidate(name)
+	}
+}
+
+// ServeHTTP serves the HTTP request r.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	name := path.Clean("/" + r.URL.Path)[1:]
+	if name == "" || strings.HasSuffix(r.URL.Path, "/") {
+		name = path.Join(name, "index")
+	}
+
+	var template *scriggo.Template
+	var err error
+	ext := path.Ext(name)
+	switch ext {
+	case "":
+		template, err = h.templates.Get(name + ".html")
+		if errors.Is(err, fs.ErrNotExist) {
+			template, err = h.templates.Get(name + ".md")
+			name += ".md"
+		} else {
+			name += ".html"
+		}
+	default:
+		if _, ok := extFormat(ext); ok {
+			template, err = h.templates.Get(name)
+		} else {
+			err = fs.ErrNotExist
+		}
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			if h.static != nil && ext != "" {
+				h.static.ServeHTTP(w, r)
+				return
+			}
+			h.serveError(w, r, http.StatusNotFound)
+			return
+		}
+		h.logf("%s", err)
+		h.serveError(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	out := &responseWriter{h: h, w: w, name: name}
+	err = template.Run(out, h.varsOf(r), h.runOptions)
+	if err != nil {
+		if out.flushed {
+			// The response has already been partially sent.
+			h.logf("%s", err)
+			return
+		}
+		switch err {
+		case builtin.ErrBadRequest:
+			h.serveError(w, r, http.StatusBadRequest)
+		case builtin.ErrRequestEntityTooLarge:
+			h.serveError(w, r, http.StatusRequestEntityTooLarge)
+		default:
+			h.logf("%s", err)
+			h.serveError(w, r, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if out.flushed {
+		_ = out.Flush()
+		return
+	}
+	h.write(w, name, http.StatusOK, &out.buf)
+
+}
+
+// serveError serves an error page with the given status code.
+func (h *Handler) serveError(w http.ResponseWriter, r *http.Request, status int) {
+	if h.errors != nil {
+		template, err := h.errors.Get(h.errTemplate)
+		if err == nil {
+			vars := map[string]interface{}{}
+			for name, value := range h.varsOf(r) {
+				vars[name] = value
+			}
+			vars["status"] = status
+			vars["statusText"] = http.StatusText(status)
+			var b bytes.Buffer
+			err = template.Run(&b, vars, h.runOptions)
+			if err == nil {
+				h.write(w, h.errTemplate, status, &b)
+				return
+			}
+		}
+		h.logf("%s", err)
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// write writes the rendered template b with the given name and status code
+// to w.
+func (h *Handler) write(w http.ResponseWriter, name string, status int, b *bytes.Buffer) {
+	if typ, ok := h.contentTypes[h.format(name)]; ok {
+		w.Header().Set("Content-Type", typ)
+	}
+	w.WriteHeader(status)
+	_, err := b.WriteTo(w)
+	if err != nil {
+		h.logf("%s", err)
+	}
+}
+
+// responseWriter is the output of a served template. It buffers the output
+// so that an error page can be served if an error occurs, but writes it to
+// the response when the template flushes the output, for example calling
+// the builtin.Flush function.
+type responseWriter struct {
+	h       *Handler
+	w       http.ResponseWriter
+	name    string
+	buf     bytes.Buffer
+	flushed bool // reports whether the output has been flushed
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	return rw.buf.Write(p)
+}
+
+// Flush writes the buffered output to the response and flushes it. The first
+// time it is called, it also writes the header with the StatusOK status code.
+func (rw *responseWriter) Flush() error {
+	if !rw.flushed {
+		if typ, ok := rw.h.contentTypes[rw.h.format(rw.name)]; ok {
+			rw.w.Header().Set("Content-Type", typ)
+		}
+		rw.w.WriteHeader(http.StatusOK)
+		rw.flushed = true
+	}
+	_, err := rw.buf.WriteTo(rw.w)
+	if err != nil {
+		return err
+	}
+	if f, ok := rw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// varsOf returns the global variables for the request r.
+func (h *Handler) varsOf(r *http.Request) map[string]interface{} {
+	if h.vars != nil {
+		return h.vars(r)
+	}
+	return map[string]interface{}{"form": builtin.NewFormData(r, defaultMaxMemory)}
+}
+
+// format returns the format of the named template file.
+func (h *Handler) format(name string) scriggo.Format {
+	if fsys, ok := h.fsys.(scriggo.FormatFS); ok {
+		format, err := fsys.Format(name)
+		if err == nil {
+			return format
+		}
+	}
+	if format, ok := extFormat(path.Ext(name)); ok {
+		return format
+	}
+	return scriggo.FormatText
+}
+
+// extFormat returns the template format of the file extension ext and true,
+// or false if ext is not the extension of a template format.
+func extFormat(ext string) (scriggo.Format, bool) {
+	switch ext {
+	case ".html":
+		return scriggo.FormatHTML, true
+	case ".css":
+		return scriggo.FormatCSS, true
+	case ".js":
+		return scriggo.FormatJS, true
+	case ".json":
+		return scriggo.FormatJSON, true
+	case ".md", ".mkd", ".mkdn", ".mdown", ".markdown":
+		return scriggo.FormatMarkdown, true
+	case ".xml", ".svg", ".rss", ".atom":
+		return scriggo.FormatXML, true
+	case ".yaml", ".yml":
+		return scriggo.FormatYAML, true
+	case ".toml":
+		return scriggo.FormatTOML, true
+	}
+	return scriggo.FormatText, false
+}
+
+// logf logs an error.
+func (h *Handler) logf(format string, a ...interface{}) {
+	if h.errorLog != nil {
+		h.errorLog.Printf(format, a...)
+	} else {
+		log.Printf(format, a...)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2021 The Scriggo Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package templatehttp
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/open2b/scriggo"
+	"github.com/open2b/scriggo/builtin"
+	"github.com/open2b/scriggo/internal/fstest"
+	"github.com/open2b/scriggo/native"
+)
+
+var handlerTests = []struct {
+	path        string
+	status      int
+	contentType string
+	body        string
+}{
+	{"/", 200, "text/html; charset=utf-8", "<p>home</p>"},
+	{"/index", 200, "text/html; charset=utf-8", "<p>home</p>"},
+	{"/index.html", 200, "text/html; charset=utf-8", "<p>home</p>"},
+	{"/docs/", 200, "text/html; charset=utf-8", "<p>docs</p>"},
+	{"/docs/intro", 200, "text/html; charset=utf-8", "# intro"},
+	{"/docs/../index", 200, "text/html; charset=utf-8", "<p>home</p>"},
+	{"/form?name=Ada", 200, "text/html; charset=utf-8", "Hello Ada"},
+	{"/style.css", 200, "text/plain; charset=utf-8", "static style.css"},
+	{"/sitemap.xml", 200, "application/xml; charset=utf-8", "<urlset>3</urlset>"},
+	{"/feed.atom", 200, "application/xml; charset=utf-8", "<feed>Ada</feed>"},
+	{"/app.js", 200, "text/javascript; charset=utf-8", `var name = "Ada";`},
+	{"/image.png", 200, "text/plain; charset=utf-8", "static image.png"},
+	{"/missing", 404, "text/html; charset=utf-8", "404 Not Found"},
+	{"/broken", 500, "text/html; charset=utf-8", "500 Internal Server Error"},
+}
+
+func TestHandler(t *testing.T) {
+	fsys := fstest.Files{
+		"index.html":      `<p>home</p>`,
+		"docs/index.html": `<p>docs</p>`,
+		"docs/intro.md":   `# intro`,
+		"form.html":       `Hello {{ form.Value("name") }}`,
+		"broken.html":     `{{ undefined }}`,
+		"error.html":      `{{ status }} {{ statusText }}`,
+		"sitemap.xml":     `<urlset>{{ 1 + 2 }}</urlset>`,
+		"feed.atom":       `<feed>{{ "Ada" }}</feed>`,
+		"app.js":          `var name = {{ "Ada" }};`,
+		"image.png":       `{{ "not a template" }}`,
+	}
+	static := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = io.WriteString(w, "static "+r.URL.Path[1:])
+	})
+	h := New(fsys, &Options{
+		BuildOptions: &scriggo.BuildOptions{
+			Globals: native.Declarations{
+				"form": (*builtin.FormData)(nil),
+			},
+		},
+		ErrorTemplate: "error.html",
+		Static:        static,
+		ErrorLog:      log.New(io.Discard, "", 0),
+	})
+	for _, test := range handlerTests {
+		t.Run(test.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+			if w.Code != test.status {
+				t.Fatalf("expected status %d, got %d", test.status, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != test.contentType {
+				t.Fatalf("expected content type %q, got %q", test.contentType, ct)
+			}
+			if body := w.Body.String(); body != test.body {
+				t.Fatalf("expected body %q, got %q", test.body, body)
+			}
+		})
+	}
+}
+
+func TestHandlerOptions(t *testing.T) {
+	fsys := fstest.Files{
+		"index.html": `{{ title }}`,
+	}
+	h := New(fsys, &Options{
+		BuildOptions: &scriggo.BuildOptions{
+			Globals: native.Declarations{
+				"title": (*string)(nil),
+			},
+		},
+		Vars: func(r *http.Request) map[string]interface{} {
+			return map[string]interface{}{"title": r.Host}
+		},
+		ContentTypes: map[scriggo.Format]string{
+			scriggo.FormatHTML: "text/html",
+		},
+	})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "text/html" {
+		t.Fatalf("expected content type %q, got %q", "text/html", ct)
+	}
+	if body := w.Body.String(); body != "example.com" {
+		t.Fatalf("expected body %q, got %q", "example.com", body)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/style.css", nil))
+	if w.Code != 404 {
+		t.Fatalf("expected status 404, got %d", w.Code)
+	}
+	if body := w.Body.String(); body != "Not Found\n" {
+		t.Fatalf("expected body %q, got %q", "Not Found\n", body)
+	}
+}
+
+func TestHandlerInvalidate(t *testing.T) {
+	fsys := fstest.Files{
+		"index.html":   `{{ render "partial.html" }}`,
+		"partial.html": `a`,
+	}
+	h := New(fsys, nil)
+	get := func() string {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		return w.Body.String()
+	}
+	if body := get(); body != "a" {
+		t.Fatalf("expected body %q, got %q", "a", body)
+	}
+	fsys["partial.html"] = `b`
+	if body := get(); body != "a" {
+		t.Fatalf("expected body %q, got %q", "a", body)
+	}
+	h.Invalidate("partial.html")
+	if body := get(); body != "b" {
+		t.Fatalf("expected body %q, got %q", "b", body)
+	}
+}
+
+func TestHandlerFlush(t *testing.T) {
+	fsys := fstest.Files{
+		"index.html":  `<head></head>{% flush() %}<body></body>`,
+		"broken.html": `<head></head>{% flush() %}{% var z = 0 %}{{ 1 / z }}`,
+	}
+	h := New(fsys, &Options{
+		BuildOptions: &scriggo.BuildOptions{
+			Globals: native.Declarations{
+				"flush": builtin.Flush,
+			},
+		},
+		ErrorLog: log.New(io.Discard, "", 0),
+	})
+	for path, body := range map[string]string{"/": "<head></head><body></body>", "/broken": "<head></head>"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != 200 {
+			t.Fatalf("%s: expected status 200, got %d", path, w.Code)
+		}
+		if !w.Flushed {
+			t.Fatalf("%s: expected flushed response", path)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Fatalf("%s: expected content type %q, got %q", path, "text/html; charset=utf-8", ct)
+		}
+		if b := w.Body.String(); b != body {
+			t.Fatalf("%s: expected body %q, got %q", path, body, b)
+		}
+	}
+}
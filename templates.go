@@ -29,6 +29,9 @@ const (
 	FormatJS
 	FormatJSON
 	FormatMarkdown
+	FormatXML
+	FormatYAML
+	FormatTOML
 )
 
 // String returns the name of the format.
@@ -45,6 +48,8 @@ type Template struct {
 	typeof  runtime.TypeOfFunc
 	globals []compiler.Global
 	conv    runtime.Converter
+	natives natives
+	cover   []runtime.CoverBlock
 }
 
 // FormatFS is the interface implemented by a file system that can determine
@@ -72,6 +77,9 @@ var formatTypes = map[ast.Format]reflect.Type{
 	ast.FormatJS:       reflect.TypeOf((*native.JS)(nil)).Elem(),
 	ast.FormatJSON:     reflect.TypeOf((*native.JSON)(nil)).Elem(),
 	ast.FormatMarkdown: reflect.TypeOf((*native.Markdown)(nil)).Elem(),
+	ast.FormatXML:      reflect.TypeOf((*native.XML)(nil)).Elem(),
+	ast.FormatYAML:     reflect.TypeOf((*native.YAML)(nil)).Elem(),
+	ast.FormatTOML:     reflect.TypeOf((*native.TOML)(nil)).Elem(),
 }
 
 // BuildTemplate builds the named template file rooted at the given file
@@ -85,6 +93,9 @@ var formatTypes = map[ast.Format]reflect.Type{
 //   JavaScript : .js
 //   JSON       : .json
 //   Markdown   : .md .mkd .mkdn .mdown .markdown
+//   XML        : .xml .svg .rss .atom
+//   YAML       : .yaml .yml
+//   TOML       : .toml
 //   Text       : all other extensions
 //
 // If the named file does not exist, BuildTemplate returns an error satisfying
@@ -95,10 +106,61 @@ func BuildTemplate(fsys fs.FS, name string, options *BuildOptions) (*Template, e
 	if f, ok := fsys.(FormatFS); ok {
 		fsys = formatFS{f}
 	}
+	co := templateOptions(options)
+	var conv Converter
+	if options != nil {
+		conv = options.MarkdownConverter
+	}
+	code, err := compiler.BuildTemplate(fsys, name, co)
+	if err != nil {
+		if e, ok := err.(compiler.Error); ok {
+			err = &BuildError{err: e}
+		}
+		return nil, err
+	}
+	t := &Template{
+		fn:      code.Main,
+		typeof:  code.TypeOf,
+		globals: code.Globals,
+		conv:    runtime.Converter(conv),
+		natives: natives{globals: co.Globals, packages: co.Importer, imported: code.NativePackages},
+		cover:   code.Coverage,
+	}
+	return t, nil
+}
+
+// VetTemplate type checks the named template file rooted at the given file
+// system, as BuildTemplate does, and reports the code that is valid but is
+// probably a mistake, as macros and imports not used, declarations that
+// shadow a global, unreachable code in macros and URLs built by string
+// concatenation. The returned errors are sorted by path and position.
+//
+// If the template cannot be built, it returns the error that BuildTemplate
+// would return.
+func VetTemplate(fsys fs.FS, name string, options *BuildOptions) ([]*BuildError, error) {
+	if f, ok := fsys.(FormatFS); ok {
+		fsys = formatFS{f}
+	}
+	errs, err := compiler.VetTemplate(fsys, name, templateOptions(options))
+	if err != nil {
+		if e, ok := err.(compiler.Error); ok {
+			err = &BuildError{err: e}
+		}
+		return nil, err
+	}
+	buildErrors := make([]*BuildError, len(errs))
+	for i, e := range errs {
+		buildErrors[i] = &BuildError{err: e}
+	}
+	return buildErrors, nil
+}
+
+// templateOptions returns the compiler options to build a template with the
+// given build options.
+func templateOptions(options *BuildOptions) compiler.Options {
 	co := compiler.Options{
 		FormatTypes: formatTypes,
 	}
-	var conv Converter
 	if options != nil {
 		co.Globals = options.Globals
 		co.TreeTransformer = options.TreeTransformer
@@ -107,16 +169,10 @@ func BuildTemplate(fsys fs.FS, name string, options *BuildOptions) (*Template, e
 		co.DollarIdentifier = options.DollarIdentifier
 		co.Importer = options.Packages
 		co.MDConverter = compiler.Converter(options.MarkdownConverter)
-		conv = options.MarkdownConverter
-	}
-	code, err := compiler.BuildTemplate(fsys, name, co)
-	if err != nil {
-		if e, ok := err.(compiler.Error); ok {
-			err = &BuildError{err: e}
-		}
-		return nil, err
+		co.Optimize = options.Optimize
+		co.Coverage = options.Coverage
 	}
-	return &Template{fn: code.Main, typeof: code.TypeOf, globals: code.Globals, conv: runtime.Converter(conv)}, nil
+	return co
 }
 
 // Run runs the template and write the rendered code to out. vars contains
@@ -138,11 +194,22 @@ func BuildTemplate(fsys fs.FS, name string, options *BuildOptions) (*Template, e
 // If a call to out.Write returns an error, a panic occurs. If the executed
 // code does not recover the panic, Run returns the error returned by
 // out.Write.
+//
+// If out has a Flush method, as an http.ResponseWriter implementing
+// http.Flusher, the executed code can flush the output rendered so far
+// calling the Flush method of native.RenderEnv.
+//
+// If a limit set in the options is exceeded, Run returns a *LimitError.
+//
+// If the profile cannot be written, Run returns the error returned by the
+// Write method of Profile in the options.
 func (t *Template) Run(out io.Writer, vars map[string]interface{}, options *RunOptions) error {
 	if out == nil {
 		return errors.New("invalid nil out")
 	}
 	vm := runtime.NewVM()
+	var profile *runtime.Profile
+	var counters []uint32
 	if options != nil {
 		if options.Context != nil {
 			vm.SetContext(options.Context)
@@ -150,12 +217,37 @@ func (t *Template) Run(out io.Writer, vars map[string]interface{}, options *RunO
 		if options.Print != nil {
 			vm.SetPrint(runtime.PrintFunc(options.Print))
 		}
+		if options.Debugger != nil {
+			vm.SetDebugger(newDebugger(options.Debugger, t.globals))
+		}
+		vm.SetLimits(options.limits())
+		vm.SetNonce(options.Nonce)
+		vm.SetLocale(options.Locale, options.Catalog)
+		if options.Profile != nil {
+			profile = runtime.NewProfile()
+			vm.SetProfile(profile)
+		}
+		if options.Coverage != nil && t.cover != nil {
+			counters = make([]uint32, len(t.cover))
+			vm.SetCoverage(counters)
+		}
 	}
 	vm.SetRenderer(out, t.conv)
 	err := vm.Run(t.fn, t.typeof, initGlobalVariables(t.globals, vars))
+	if profile != nil {
+		if err2 := profile.Write(options.Profile); err == nil {
+			err = err2
+		}
+	}
+	if counters != nil {
+		options.Coverage.add(t.cover, counters)
+	}
 	if err != nil {
-		if p, ok := err.(*runtime.PanicError); ok {
-			err = &PanicError{p}
+		switch e := err.(type) {
+		case *runtime.PanicError:
+			err = &PanicError{e}
+		case *runtime.LimitError:
+			err = &LimitError{e}
 		}
 		return err
 	}
@@ -9,7 +9,15 @@ import (
 )
 
 func BenchmarkRun(b *testing.B) {
-	programs, err := build()
+	benchmarkRun(b, false)
+}
+
+func BenchmarkRunOptimized(b *testing.B) {
+	benchmarkRun(b, true)
+}
+
+func benchmarkRun(b *testing.B, optimize bool) {
+	programs, err := build(optimize)
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -6,6 +6,7 @@ package main
 
 import (
 	"embed"
+	"flag"
 	"fmt"
 	"io/fs"
 	"os"
@@ -27,7 +28,9 @@ type programToRun struct {
 }
 
 func main() {
-	programs, err := build()
+	optimize := flag.Bool("optimize", false, "optimize the programs")
+	flag.Parse()
+	programs, err := build(*optimize)
 	if err != nil {
 		_, _ = fmt.Fprint(os.Stderr, err)
 		os.Exit(1)
@@ -51,8 +54,10 @@ func main() {
 }
 
 // build builds the programs in *.tests files and in the *_test directories.
-func build() ([]programToRun, error) {
+// If optimize is true, the programs are built with the Optimize option.
+func build(optimize bool) ([]programToRun, error) {
 	var programs []programToRun
+	options := &scriggo.BuildOptions{Optimize: optimize}
 	err := fs.WalkDir(tests, ".", func(path string, d fs.DirEntry, err error) error {
 		if d.IsDir() {
 			if path != "." && !strings.HasSuffix(path, "_test") {
@@ -66,7 +71,7 @@ func build() ([]programToRun, error) {
 			if err != nil {
 				return err
 			}
-			program, err := scriggo.Build(fsys, nil)
+			program, err := scriggo.Build(fsys, options)
 			if err != nil {
 				return fmt.Errorf("cannot build %s: %s", path, err)
 			}
@@ -84,7 +89,7 @@ func build() ([]programToRun, error) {
 		arch := txtar.Parse(tests)
 		for _, file := range arch.Files {
 			fsys := scriggo.Files{"main.go": file.Data}
-			program, err := scriggo.Build(fsys, nil)
+			program, err := scriggo.Build(fsys, options)
 			if err != nil {
 				return fmt.Errorf("cannot build %s/%s: %s", path, file.Name, err)
 			}
@@ -1,9 +1,9 @@
-// errorcheck
+// compile
 
 package main
 
 type I interface {
-	M() // ERROR `non-empty interfaces are not supported in this release of Scriggo`
+	M()
 }
 
 func main() {}
@@ -1,5 +1,3 @@
-// skip : method declaration https://github.com/open2b/scriggo/issues/458
-
 // run
 
 // Copyright 2010 The Go Authors. All rights reserved.
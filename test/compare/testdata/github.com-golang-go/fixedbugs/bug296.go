@@ -1,5 +1,3 @@
-// skip : interface definition https://github.com/open2b/scriggo/issues/218
-
 // run
 
 // Copyright 2010 The Go Authors. All rights reserved.
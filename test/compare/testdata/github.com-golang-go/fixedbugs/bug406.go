@@ -1,5 +1,3 @@
-// skip : method declaration
-
 // run
 
 // Copyright 2012 The Go Authors. All rights reserved.
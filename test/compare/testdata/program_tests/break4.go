@@ -0,0 +1,38 @@
+// run
+
+package main
+
+import "fmt"
+
+func main() {
+	// break inside range nested in for.
+	for i := 0; i < 2; i++ {
+		for _, v := range []int{1, 2, 3} {
+			if v == 2 {
+				break
+			}
+			fmt.Println("in", i, v)
+		}
+	}
+	// continue inside for nested in range.
+	for _, v := range []int{1, 2} {
+		for j := 0; j < 3; j++ {
+			if j == 1 {
+				continue
+			}
+			fmt.Println("range-for", v, j)
+		}
+	}
+	// continue without condition runs post.
+	n := 0
+	for i := 0; ; i++ {
+		if i > 4 {
+			break
+		}
+		if i%2 == 0 {
+			continue
+		}
+		n += i
+	}
+	fmt.Println("n", n)
+}
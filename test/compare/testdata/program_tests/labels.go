@@ -0,0 +1,128 @@
+// run
+
+package main
+
+import "fmt"
+
+func main() {
+outer:
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			if j == 1 {
+				continue outer
+			}
+			if i == 2 {
+				break outer
+			}
+			fmt.Println("for-for", i, j)
+		}
+	}
+rng:
+	for _, a := range []string{"a", "b", "c"} {
+		for _, b := range "xyz" {
+			if b == 'y' {
+				continue rng
+			}
+			if a == "c" {
+				break rng
+			}
+			fmt.Println("range-range", a, string(b))
+		}
+	}
+mixed:
+	for i := 0; i < 3; i++ {
+		for _, b := range []int{1, 2, 3} {
+			for k := 0; k < 2; k++ {
+				if b == 2 {
+					continue mixed
+				}
+				if i == 2 {
+					break mixed
+				}
+				fmt.Println("mixed", i, b, k)
+			}
+		}
+	}
+rmixed:
+	for _, a := range []int{1, 2, 3} {
+		for j := 0; j < 2; j++ {
+			for _, c := range []int{7, 8} {
+				if j == 1 {
+					continue rmixed
+				}
+				if a == 3 {
+					break rmixed
+				}
+				fmt.Println("rmixed", a, j, c)
+			}
+		}
+	}
+sw:
+	switch x := 2; x {
+	case 2:
+		for i := 0; i < 3; i++ {
+			if i == 1 {
+				break sw
+			}
+			fmt.Println("sw", i)
+		}
+		fmt.Println("not reached")
+	}
+	var iface interface{} = 3
+ts:
+	switch iface.(type) {
+	case int:
+		for _, v := range []int{1, 2} {
+			if v == 2 {
+				break ts
+			}
+			fmt.Println("ts", v)
+		}
+		fmt.Println("not reached")
+	}
+	ch := make(chan int, 1)
+	ch <- 1
+sel:
+	select {
+	case v := <-ch:
+		for {
+			if v > 0 {
+				break sel
+			}
+		}
+		fmt.Println("not reached")
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		default:
+			if i == 1 {
+				break
+			}
+			fmt.Println("select", i)
+		}
+	}
+	for _, v := range []int{1, 2, 3} {
+		switch v {
+		case 2:
+			continue
+		case 3:
+			break
+		}
+		fmt.Println("range-switch", v)
+	}
+loop:
+	for i := 0; i < 3; i++ {
+		func() {
+			for j := 0; j < 3; j++ {
+				if j == 1 {
+					break
+				}
+				fmt.Println("func", i, j)
+			}
+		}()
+		if i == 1 {
+			break loop
+		}
+	}
+	fmt.Println("end")
+}
@@ -0,0 +1,130 @@
+// run
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+type Celsius float64
+
+func Sum[T Number](values ...T) T {
+	var s T
+	for _, v := range values {
+		s += v
+	}
+	return s
+}
+
+func Map[T, U any](s []T, f func(T) U) []U {
+	r := make([]U, 0, len(s))
+	for _, v := range s {
+		r = append(r, f(v))
+	}
+	return r
+}
+
+func Keys[K comparable, V any](m map[K]V) int {
+	n := 0
+	for range m {
+		n++
+	}
+	return n
+}
+
+func Index[S ~[]E, E comparable](s S, v E) int {
+	for i, x := range s {
+		if x == v {
+			return i
+		}
+	}
+	return -1
+}
+
+type Stack[T any] struct {
+	items []T
+}
+
+func (s *Stack[T]) Push(v T) {
+	s.items = append(s.items, v)
+}
+
+func (s *Stack[T]) Pop() (T, bool) {
+	var zero T
+	if len(s.items) == 0 {
+		return zero, false
+	}
+	v := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return v, true
+}
+
+func (s Stack[T]) Len() int { return len(s.items) }
+
+type Pair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+func (p Pair[K, V]) String() string {
+	return fmt.Sprintf("%v=%v", p.Key, p.Value)
+}
+
+type Names []string
+
+func Max[T int | float64 | string](a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func Apply[T any](v T, fs ...func(T) T) T {
+	for _, f := range fs {
+		v = f(v)
+	}
+	return v
+}
+
+func Zero[T any]() T {
+	var z T
+	return z
+}
+
+func main() {
+	fmt.Println(Sum(1, 2, 3))
+	fmt.Println(Sum(1.5, 2))
+	fmt.Println(float64(Sum[Celsius](10, 20.5)))
+	fmt.Println(Sum[int]())
+	strs := Map([]int{1, 2, 3}, func(i int) string { return strings.Join(make([]string, i+1), "x") })
+	fmt.Println(strs, len(strs))
+	fmt.Println(Keys(map[string]bool{"a": true, "b": false}))
+	fmt.Println(Index(Names{"a", "b", "c"}, "c"))
+	fmt.Println(Index([]int{4, 5}, 6))
+	var s Stack[string]
+	s.Push("a")
+	s.Push("b")
+	fmt.Println(s.Len())
+	v, ok := s.Pop()
+	fmt.Println(v, ok)
+	st := &Stack[int]{}
+	st.Push(3)
+	fmt.Println(st.Len())
+	p := Pair[string, int]{"x", 1}
+	fmt.Println(p.String())
+	fmt.Println(p)
+	fmt.Println(Max(3, 7), Max("a", "b"), Max(2.5, 1))
+	f := Map[int, int]
+	fmt.Println(f([]int{1, 2}, func(i int) int { return i * 10 }))
+	fmt.Println(Apply(2, func(i int) int { return i + 1 }, func(i int) int { return i * 2 }))
+	fmt.Println(Zero[int](), Zero[string]() == "", Zero[*int]() == nil)
+	var ps []Pair[int, bool]
+	ps = append(ps, Pair[int, bool]{1, true})
+	fmt.Println(len(ps), ps[0].Key)
+	defer fmt.Println(Sum(4, 5))
+}
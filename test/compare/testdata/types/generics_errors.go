@@ -0,0 +1,40 @@
+// errorcheck
+
+package main
+
+type Number interface {
+	~int | ~float64
+}
+
+func F[T any](v T) T { return v }
+
+func Sum[T Number](values ...T) T {
+	var s T
+	for _, v := range values {
+		s += v
+	}
+	return s
+}
+
+func Map[T, U any](s []T) []U { return nil }
+
+type L[T any] []T
+
+var _ = F // ERROR `cannot use generic function F without instantiation`
+
+var _ = Sum("a") // ERROR `string does not satisfy Number`
+
+var _ = Map([]int{1}) // ERROR `in call to Map, cannot infer U`
+
+var _ L // ERROR `cannot use generic type L without instantiation`
+
+var _ = F[int, string](1) // ERROR `got 2 type arguments but F has 1 type parameters`
+
+var _ Number // ERROR `cannot use type Number outside a type constraint: interface contains type constraints`
+
+func main() {
+	_ = F(1)
+	_ = Sum(1, 2)
+	_ = Map[int, string]([]int{1})
+	var _ L[int]
+}
@@ -0,0 +1,87 @@
+// run
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Shape interface {
+	Area() float64
+	Name() string
+}
+
+type Named interface {
+	Name() string
+}
+
+type Solid interface {
+	Shape
+	fmt.Stringer
+	Volume() float64
+}
+
+type Rect struct{ W, H float64 }
+
+func (r Rect) Area() float64 { return r.W * r.H }
+func (r Rect) Name() string  { return "rect" }
+
+type Circle struct{ R float64 }
+
+func (c *Circle) Area() float64 { return 3 * c.R * c.R }
+func (c *Circle) Name() string  { return "circle" }
+
+type Cube struct{ S float64 }
+
+func (c Cube) Area() float64   { return 6 * c.S * c.S }
+func (c Cube) Name() string    { return "cube" }
+func (c Cube) Volume() float64 { return c.S * c.S * c.S }
+func (c Cube) String() string  { return fmt.Sprintf("cube(%v)", c.S) }
+
+func describe(s Shape) string {
+	return s.Name() + ":" + fmt.Sprint(s.Area())
+}
+
+func main() {
+	shapes := []Shape{Rect{2, 3}, &Circle{1}, Cube{2}}
+	for _, s := range shapes {
+		fmt.Println(describe(s))
+		if n, ok := s.(Named); ok {
+			fmt.Println("named", n.Name())
+		}
+		switch v := s.(type) {
+		case Solid:
+			fmt.Println("solid", v.Volume(), v)
+		case Rect:
+			fmt.Println("rect", v.W)
+		default:
+			fmt.Println("other")
+		}
+	}
+	var b strings.Builder
+	var w interface{ WriteString(string) (int, error) } = &b
+	w.WriteString("hello")
+	fmt.Println(b.String())
+	var i interface{} = Rect{1, 1}
+	_, ok := i.(Solid)
+	fmt.Println(ok)
+	var st fmt.Stringer = Solid(Cube{1})
+	fmt.Println(st.String())
+	f := shapes[0].Area
+	fmt.Println(f())
+	area := Shape.Area
+	for _, s := range shapes {
+		fmt.Println(area(s))
+	}
+	str := Solid.String
+	fmt.Println(str(Cube{3}))
+	var n Named
+	fmt.Println(n == nil)
+	n = Rect{}
+	fmt.Println(n != nil)
+	defer func() {
+		fmt.Println("recovered:", recover() != nil)
+	}()
+	_ = i.(Shape).(Solid)
+}
@@ -0,0 +1,35 @@
+// errorcheck
+
+package main
+
+type I interface {
+	M()
+	M() // ERROR `duplicate method M`
+}
+
+type J interface {
+	int
+}
+
+var _ J // ERROR `cannot use type J outside a type constraint: interface contains type constraints`
+
+type A interface{ F() int }
+type B interface{ F() string }
+
+type C interface {
+	A
+	B // ERROR `duplicate method F`
+}
+
+type T struct{}
+
+func (t *T) F() int { return 0 }
+
+func main() {
+	var a A
+	a = T{} // ERROR `cannot use T{} (type T) as type A in assignment`
+	a = &T{}
+	_ = a
+	_ = a.(T) // ERROR `impossible type assertion:`
+	_ = A.G // ERROR `A.G undefined (type A has no method G)`
+}
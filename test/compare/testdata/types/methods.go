@@ -0,0 +1,78 @@
+// run
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Celsius float64
+
+func (c Celsius) String() string {
+	return fmt.Sprintf("%.1f°C", float64(c))
+}
+
+type Counter struct {
+	n    int
+	name string
+}
+
+func (c *Counter) Inc() { c.n++ }
+
+func (c Counter) Value() int { return c.n }
+
+type ByLen []string
+
+func (s ByLen) Len() int           { return len(s) }
+func (s ByLen) Less(i, j int) bool { return len(s[i]) < len(s[j]) }
+func (s ByLen) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type MyErr struct{ msg string }
+
+func (e *MyErr) Error() string { return "myerr: " + e.msg }
+
+func fail() error {
+	return &MyErr{"boom"}
+}
+
+func main() {
+	c := Celsius(21.5)
+	fmt.Println(c.String())
+	fmt.Println(c)
+	var s fmt.Stringer = c
+	fmt.Println(s.String())
+	f := c.String
+	fmt.Println(f())
+	g := Celsius.String
+	fmt.Println(g(3))
+
+	var k Counter
+	k.Inc()
+	k.Inc()
+	p := &k
+	p.Inc()
+	fmt.Println(k.Value(), p.Value())
+	inc := k.Inc
+	inc()
+	fmt.Println(k.n)
+	h := (*Counter).Inc
+	h(p)
+	fmt.Println(k.n)
+
+	words := ByLen{"banana", "kiwi", "apple", "fig"}
+	sort.Sort(words)
+	fmt.Println(strings.Join(words, ","))
+
+	err := fail()
+	fmt.Println(err)
+	if e, ok := err.(*MyErr); ok {
+		fmt.Println("is MyErr", e.msg)
+	}
+	var i interface{} = c
+	if st, ok := i.(fmt.Stringer); ok {
+		fmt.Println("stringer", st)
+	}
+	defer k.Inc()
+}
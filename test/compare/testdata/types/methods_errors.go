@@ -0,0 +1,48 @@
+// errorcheck
+
+package main
+
+import "fmt"
+
+var _ = fmt.Sprint
+
+type T struct{ M int }
+
+func (t T) M() {} // ERROR `field and method with the same name M`
+
+type S int
+
+func (s S) A() {}
+
+func (s S) A() {} // ERROR `method S.A already declared`
+
+func (s *S) B() {}
+
+type P *int
+
+func (p P) C() {} // ERROR `invalid receiver type P (pointer or interface type)`
+
+func (s fmt.Stringer) D() {} // ERROR `cannot define new methods on non-local type fmt.Stringer`
+
+type E struct{ S }
+
+type L struct{}
+
+func (L) F() {}
+
+type R struct{}
+
+func (R) F() {}
+
+type LR struct {
+	L
+	R
+}
+
+func main() {
+	S.B(0) // ERROR `invalid method expression S.B (needs pointer receiver: (*S).B)`
+	S(0).B() // ERROR `cannot call pointer method on S(0)`
+	E.B(E{}) // ERROR `invalid method expression E.B (needs pointer receiver: (*E).B)`
+	E{}.B() // ERROR `cannot call pointer method on E{}`
+	LR{}.F() // ERROR `ambiguous selector LR{}.F`
+}
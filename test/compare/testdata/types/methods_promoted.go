@@ -0,0 +1,132 @@
+// run
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Counter struct{ n int }
+
+func (c *Counter) Inc() { c.n++ }
+
+func (c Counter) Value() int { return c.n }
+
+func (c Counter) String() string { return fmt.Sprintf("counter(%d)", c.n) }
+
+type Named struct {
+	Counter
+	name string
+}
+
+type Shared struct {
+	*Counter
+}
+
+type Deep struct {
+	Named
+}
+
+type Valuer interface {
+	Value() int
+}
+
+type Wrapper struct {
+	Valuer
+}
+
+type ByLen []string
+
+func (s ByLen) Len() int           { return len(s) }
+func (s ByLen) Less(i, j int) bool { return len(s[i]) < len(s[j]) }
+func (s ByLen) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type Words struct {
+	ByLen
+}
+
+type Left struct{}
+
+func (Left) Side() string { return "left" }
+
+type Right struct{}
+
+func (Right) Side() string { return "right" }
+
+type Both struct {
+	Left
+	Right
+}
+
+type Nearest struct {
+	Both
+	Right
+}
+
+type Shadowed struct {
+	Counter
+	Value int
+}
+
+func main() {
+
+	// Calls and method values.
+	var n Named
+	n.Inc()
+	n.Inc()
+	fmt.Println(n.Value(), n.n)
+	inc := n.Inc
+	inc()
+	fmt.Println(n.Value())
+	p := &n
+	p.Inc()
+	fmt.Println(p.Value())
+
+	// Pointer embedded field.
+	s := Shared{&Counter{}}
+	s.Inc()
+	fmt.Println(s.Value(), s)
+
+	// Deeply embedded field.
+	d := &Deep{}
+	d.Inc()
+	fmt.Println(d.Value(), d)
+
+	// Method expressions.
+	value := Named.Value
+	fmt.Println(value(n))
+	incp := (*Named).Inc
+	incp(&n)
+	fmt.Println(n.Value())
+
+	// Interfaces.
+	var v Valuer = n
+	fmt.Println(v.Value())
+	var st fmt.Stringer = Deep{}
+	fmt.Println(st.String(), st)
+	var i interface{} = &n
+	if _, ok := i.(interface{ Inc() }); ok {
+		fmt.Println("*Named has Inc")
+	}
+	if _, ok := interface{}(n).(interface{ Inc() }); !ok {
+		fmt.Println("Named has not Inc")
+	}
+
+	// Methods of embedded interfaces.
+	w := Wrapper{n}
+	fmt.Println(w.Value())
+	v = w
+	fmt.Println(v.Value())
+
+	// Native interfaces.
+	words := Words{ByLen{"banana", "kiwi", "apple", "fig"}}
+	sort.Sort(words)
+	fmt.Println(strings.Join(words.ByLen, ","))
+
+	// Depth.
+	fmt.Println(Nearest{}.Side())
+	fmt.Println(Shadowed{Value: 5}.Value)
+
+}
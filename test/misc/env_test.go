@@ -219,3 +219,28 @@ func TestStopWithExit(t *testing.T) {
 		t.Fatalf("expected exit error, got %q", err)
 	}
 }
+
+// TestRenderEnv tests that the Env value passed to native functions, also
+// when called in a goroutine, implements native.RenderEnv.
+func TestRenderEnv(t *testing.T) {
+	fsys := fstest.Files{"index": "{% macro M %}{{ locale() }}{% end %}{{ locale() }}{% go M() %}{{ nonce() }}"}
+	opts := &scriggo.BuildOptions{
+		AllowGoStmt: true,
+		Globals: native.Declarations{
+			"locale": func(env native.Env) string { return env.(native.RenderEnv).Locale() },
+			"nonce":  func(env native.Env) string { return env.(native.RenderEnv).Nonce() },
+		},
+	}
+	template, err := scriggo.BuildTemplate(fsys, "index", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &bytes.Buffer{}
+	err = template.Run(b, nil, &scriggo.RunOptions{Locale: "it-IT", Nonce: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "it-ITit-ITabc"; b.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, b.String())
+	}
+}
@@ -223,3 +223,124 @@ func TestURLEscape(t *testing.T) {
 
 	}
 }
+
+var scriptAttributeEscapeCases = []struct {
+	src      string
+	expected string
+}{
+	{
+		src:      `<a onclick="f({{ 5 }})">`,
+		expected: `<a onclick="f(5)">`,
+	},
+	{
+		src:      "<a onclick=\"f({{ `a` }})\">",
+		expected: `<a onclick="f(&#34;a&#34;)">`,
+	},
+	{
+		src:      "<a onclick=\"f({{ `\"); alert(1); (\"` }})\">",
+		expected: `<a onclick="f(&#34;\&#34;);&#32;alert(1);&#32;(\&#34;&#34;)">`,
+	},
+	{
+		src:      "<a onclick=\"f('{{ `'); alert(1); ('` }}')\">",
+		expected: `<a onclick="f('\u0027);&#32;alert(1);&#32;(\u0027')">`,
+	},
+	{
+		src:      "<a onclick=f({{ `a b` }})>",
+		expected: `<a onclick=f(&#34;a&#32;b&#34;)>`,
+	},
+	{
+		src:      "<a onclick=\"f({{ js(`a && b`) }})\">",
+		expected: `<a onclick="f(a&#32;&amp;&amp;&#32;b)">`,
+	},
+	{
+		src:      "<div style=\"color: {{ `red; background: url(x)` }}\">",
+		expected: `<div style="color: &#34;red\3b&#32;&#32;background\3a&#32;&#32;url\28x\29&#32;&#34;">`,
+	},
+	{
+		src:      "<div style=\"font-family: '{{ `a'b` }}'\">",
+		expected: `<div style="font-family: 'a\27&#32;b'">`,
+	},
+	{
+		src:      "<div style=\"width: {{ 10 }}px\" title=\"{{ `a b` }}\">",
+		expected: `<div style="width: 10px" title="a b">`,
+	},
+}
+
+func TestScriptAttributeEscape(t *testing.T) {
+	for _, cas := range scriptAttributeEscapeCases {
+		t.Run("", func(t *testing.T) {
+			fsys := fstest.Files{"index.html": cas.src}
+			opts := &scriggo.BuildOptions{
+				Globals: globals(),
+			}
+			template, err := scriggo.BuildTemplate(fsys, "index.html", opts)
+			if err != nil {
+				t.Fatalf("compilation error: %s", err)
+			}
+			out := &strings.Builder{}
+			err = template.Run(out, nil, nil)
+			if err != nil {
+				t.Fatalf("run error: %s", err)
+			}
+			got := out.String()
+			if got != cas.expected {
+				t.Fatalf("src: %q: expecting %q, got %q", cas.src, cas.expected, got)
+			}
+		})
+	}
+}
+
+var svgEscapeCases = []struct {
+	src      string
+	expected string
+}{
+	{
+		src:      "<rect onclick=\"f('{{ `');alert(1);//` }}')\"/>",
+		expected: `<rect onclick="f('\u0027);alert(1);//')"/>`,
+	},
+	{
+		src:      "<rect onclick=\"f({{ `a b` }})\"/>",
+		expected: `<rect onclick="f(&#34;a&#32;b&#34;)"/>`,
+	},
+	{
+		src:      "<rect style=\"fill: {{ `red; x: url(y)` }}\" id=\"{{ `a b` }}\"/>",
+		expected: `<rect style="fill: &#34;red\3b&#32;&#32;x\3a&#32;&#32;url\28y\29&#32;&#34;" id="a b"/>`,
+	},
+	{
+		src:      "<script>f({{ `</script><script>alert(1)` }})</script>",
+		expected: `<script>f("\u003c/script\u003e\u003cscript\u003ealert(1)")</script>`,
+	},
+	{
+		src:      "<script>f('{{ `');alert(1);//` }}')</script>{{ `<a>` }}",
+		expected: `<script>f('\u0027);alert(1);//')</script>&lt;a&gt;`,
+	},
+	{
+		src:      "<style>text { font-family: {{ `a</style>` }} }</style>",
+		expected: `<style>text { font-family: "a\3c\2fstyle\3e " }</style>`,
+	},
+	{
+		src:      "<script/>{{ `<a>` }}",
+		expected: `<script/>&lt;a&gt;`,
+	},
+}
+
+func TestSVGEscape(t *testing.T) {
+	for _, cas := range svgEscapeCases {
+		t.Run("", func(t *testing.T) {
+			fsys := fstest.Files{"index.svg": cas.src}
+			template, err := scriggo.BuildTemplate(fsys, "index.svg", nil)
+			if err != nil {
+				t.Fatalf("compilation error: %s", err)
+			}
+			out := &strings.Builder{}
+			err = template.Run(out, nil, nil)
+			if err != nil {
+				t.Fatalf("run error: %s", err)
+			}
+			got := out.String()
+			if got != cas.expected {
+				t.Fatalf("src: %q: expecting %q, got %q", cas.src, cas.expected, got)
+			}
+		})
+	}
+}
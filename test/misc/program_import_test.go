@@ -93,6 +93,26 @@ func TestProgramImport(t *testing.T) {
 			"pkg1/pkg1.go": `package pkg1
 				var V = 10`,
 		},
+
+		`"main" with multiple files importing "pkg1" with multiple files`: {
+			"go.mod": "module a.b",
+			"main.go": `package main
+				import "a.b/pkg1"
+				func main() {
+					pkg1.F(G())
+				}`,
+			"g.go": `package main
+				import "a.b/pkg1"
+				func G() int { return pkg1.V }`,
+			"pkg1/f.go": `package pkg1
+				func F(n int) {
+					print("called pkg1.F(", n, ")")
+				}`,
+			"pkg1/v.go": `package pkg1
+				var V = w + 1`,
+			"pkg1/w.go": `package pkg1
+				var w = 9`,
+		},
 	}
 	for name, fsys := range cases {
 		t.Run(name, func(t *testing.T) {
@@ -6,6 +6,8 @@ package misc
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io/fs"
 	"reflect"
 	"strings"
@@ -216,7 +218,7 @@ func TestIssue881(t *testing.T) {
 	if err == nil {
 		t.Fatalf("expected build error, got no error")
 	}
-	const expected = "main:6:4: pkg redeclared as imported package name\n\tmain:5:4: previous declaration"
+	const expected = "main.go:6:4: pkg redeclared as imported package name\n\tmain.go:5:4: previous declaration"
 	if s := err.Error(); s != expected {
 		t.Fatalf("expected error %q, got %q", expected, s)
 	}
@@ -239,14 +241,14 @@ var compositeStructLiteralTests = []struct {
 		"main.go": `package main; import "a.b/p"; func main() { _ = p.S{3, 5} }`,
 		"p/p.go":  `package p; type S struct{ F, f int }`,
 	},
-	err: "main:1:56: implicit assignment of unexported field 'f' in p.S literal",
+	err: "main.go:1:56: implicit assignment of unexported field 'f' in p.S literal",
 }, {
 	fsys: fstest.Files{
 		"go.mod":  "module a.b\ngo 1.16",
 		"main.go": `package main; import "a.b/p"; func main() { _ = p.S{F: 3} }`,
 		"p/p.go":  `package p; type T struct { F int }; type S struct{ T }`,
 	},
-	err: "main:1:53: cannot use promoted field T.F in struct literal of type p.S",
+	err: "main.go:1:53: cannot use promoted field T.F in struct literal of type p.S",
 }}
 
 // TestCompositeStructLiterals tests composite struct literals when the struct
@@ -286,21 +288,21 @@ var structFieldSelectorTests = []struct {
 		"main.go": `package main; import "a.b/p"; func main() { _ = p.S{}.f }`,
 		"p/p.go":  `package p; type S struct{ f int }`,
 	},
-	err: "main:1:54: p.S{}.f undefined (cannot refer to unexported field or method f)",
+	err: "main.go:1:54: p.S{}.f undefined (cannot refer to unexported field or method f)",
 }, {
 	fsys: fstest.Files{
 		"go.mod":  "module a.b\ngo 1.16",
 		"main.go": `package main; import "a.b/p"; func main() { _ = p.S{}.G }`,
 		"p/p.go":  `package p; type S struct{ F int }`,
 	},
-	err: "main:1:54: p.S{}.G undefined (type S has no field or method G)",
+	err: "main.go:1:54: p.S{}.G undefined (type S has no field or method G)",
 }, {
 	fsys: fstest.Files{
 		"go.mod":  "module a.b\ngo 1.16",
 		"main.go": `package main; import "a.b/p"; func main() { _ = p.S{}.g }`,
 		"p/p.go":  `package p; type S struct{ F int }`,
 	},
-	err: "main:1:54: p.S{}.g undefined (type S has no field or method g)",
+	err: "main.go:1:54: p.S{}.g undefined (type S has no field or method g)",
 }, {
 	fsys: fstest.Files{
 		"go.mod":  "module a.b\ngo 1.16",
@@ -314,21 +316,21 @@ var structFieldSelectorTests = []struct {
 		"main.go": `package main; import "a.b/p"; func main() { _ = p.S{}.F }`,
 		"p/p.go":  `package p; type ( T struct { F int }; V struct { F int }; S struct{ T; V } )`,
 	},
-	err: "main:1:54: ambiguous selector p.S{}.F",
+	err: "main.go:1:54: ambiguous selector p.S{}.F",
 }, {
 	fsys: fstest.Files{
 		"go.mod":  "module a.b\ngo 1.16",
 		"main.go": `package main; import "a.b/p"; func main() { _ = p.S{}.F }`,
 		"p/p.go":  `package p; type ( T struct { F int }; V struct { F int }; S struct{ T; V } )`,
 	},
-	err: "main:1:54: ambiguous selector p.S{}.F",
+	err: "main.go:1:54: ambiguous selector p.S{}.F",
 }, {
 	fsys: fstest.Files{
 		"go.mod":  "module a.b\ngo 1.16",
 		"main.go": `package main; import "a.b/p"; func main() { _ = p.S{}.f }`,
 		"p/p.go":  `package p; type ( T struct { f int }; V struct { f int }; S struct{ T; V } )`,
 	},
-	err: "main:1:54: p.S{}.f undefined (type S has no field or method f)", // TODO(marco): S should be p.S
+	err: "main.go:1:54: p.S{}.f undefined (type S has no field or method f)", // TODO(marco): S should be p.S
 }, {
 	fsys: fstest.Files{
 		"go.mod":  "module a.b\ngo 1.16",
@@ -342,7 +344,7 @@ var structFieldSelectorTests = []struct {
 		"main.go": `package main; import "a.b/p"; func main() { _ = p.S{}.t.F }`,
 		"p/p.go":  `package p; type ( t struct { F int }; S struct{ t } )`,
 	},
-	err: "main:1:54: p.S{}.t undefined (cannot refer to unexported field or method t)",
+	err: "main.go:1:54: p.S{}.t undefined (cannot refer to unexported field or method t)",
 }, {
 	fsys: fstest.Files{
 		"go.mod":  "module a.b\ngo 1.16",
@@ -541,8 +543,271 @@ func TestIssue855(t *testing.T) {
 	if err != nil {
 		gotErr = err.Error()
 	}
-	expectedErr := "main:7:9: undefined: V2"
+	expectedErr := "index.go:7:9: undefined: V2"
 	if gotErr != expectedErr {
 		t.Fatalf("expected error %q, got %q", expectedErr, gotErr)
 	}
 }
+
+// TestMethodsToNative tests that the values of types defined in Scriggo code
+// implement the native interfaces with the methods declared in Scriggo code
+// when they are passed to native code.
+func TestMethodsToNative(t *testing.T) {
+	var got []string
+	packages := native.Packages{
+		"pkg": native.Package{
+			Name: "pkg",
+			Declarations: native.Declarations{
+				"Got": func(v interface{}) {
+					var s string
+					switch v := v.(type) {
+					case native.HTMLStringer:
+						s = "html:" + string(v.HTML())
+					case fmt.Stringer:
+						s = "string:" + v.String()
+					case error:
+						s = "error:" + v.Error()
+					}
+					got = append(got, s)
+				},
+				"HTML": reflect.TypeOf(native.HTML("")),
+			},
+		},
+	}
+	main := `
+	package main
+
+	import "pkg"
+
+	type Name string
+
+	func (n Name) String() string { return "name " + string(n) }
+
+	type Link struct{ URL string }
+
+	func (l *Link) HTML() pkg.HTML { return pkg.HTML("<a href=\"" + l.URL + "\">") }
+
+	type Fail int
+
+	func (f Fail) Error() string { return "fail" }
+
+	type Plain int
+
+	func (p *Plain) String() string { return "plain" }
+
+	func main() {
+		pkg.Got(Name("a"))
+		l := Link{"/b"}
+		pkg.Got(&l)
+		pkg.Got(Fail(1))
+		p := Plain(3)
+		pkg.Got(&p)
+	}`
+	program, err := scriggo.Build(fstest.Files{"main.go": main}, &scriggo.BuildOptions{Packages: packages})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = program.Run(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"string:name a", `html:<a href="/b">`, "error:fail", "string:plain"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+// trampolineString and trampolineFloat are used in the signatures of the
+// native functions of TestTrampolines. As the trampolines are registered for
+// the whole process, the signatures must not be used by other tests.
+type trampolineString string
+type trampolineFloat float32
+
+// TestTrampolines tests that the native functions with a registered
+// trampoline are called with the trampoline.
+func TestTrampolines(t *testing.T) {
+	var calls int
+	native.RegisterTrampoline(reflect.TypeOf((func(trampolineString, int) (string, error))(nil)), func(f interface{}, r native.Registers) {
+		calls++
+		r0, r1 := f.(func(trampolineString, int) (string, error))(trampolineString(r.String[1]), int(r.Int[0]))
+		r.String[0] = r0
+		r.General[0] = reflect.ValueOf(r1)
+	})
+	native.RegisterTrampoline(reflect.TypeOf((func(bool, trampolineFloat, interface{}) (uint8, []byte))(nil)), func(f interface{}, r native.Registers) {
+		calls++
+		var a2 interface{}
+		if v := r.General[1]; v.IsValid() {
+			a2 = v.Interface()
+		}
+		r0, r1 := f.(func(bool, trampolineFloat, interface{}) (uint8, []byte))(r.Int[1] > 0, trampolineFloat(r.Float[0]), a2)
+		r.Int[0] = int64(r0)
+		r.General[0] = reflect.ValueOf(r1)
+	})
+	packages := native.Packages{
+		"pkg": native.Package{
+			Name: "pkg",
+			Declarations: native.Declarations{
+				"Repeat": func(s trampolineString, n int) (string, error) {
+					if n < 0 {
+						return "", errors.New("negative count")
+					}
+					return strings.Repeat(string(s), n), nil
+				},
+				"Bytes": func(b bool, f trampolineFloat, v interface{}) (uint8, []byte) {
+					if !b || v == nil {
+						return 0, nil
+					}
+					return uint8(f), []byte(fmt.Sprint(v))
+				},
+			},
+		},
+	}
+	main := `
+	package main
+
+	import "pkg"
+
+	func main() {
+		s, err := pkg.Repeat("ab", 3)
+		if s != "ababab" || err != nil {
+			panic("unexpected Repeat result")
+		}
+		_, err = pkg.Repeat("ab", -1)
+		if err == nil || err.Error() != "negative count" {
+			panic("unexpected Repeat error")
+		}
+		n, b := pkg.Bytes(true, 2.5, 15)
+		if n != 2 || string(b) != "15" {
+			panic("unexpected Bytes result")
+		}
+		n, b = pkg.Bytes(true, 2.5, nil)
+		if n != 0 || b != nil {
+			panic("unexpected Bytes result with nil")
+		}
+	}`
+	program, err := scriggo.Build(fstest.Files{"main.go": main}, &scriggo.BuildOptions{Packages: packages})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = program.Run(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 4 {
+		t.Fatalf("expected 4 calls with the trampolines, got %d", calls)
+	}
+}
+
+// TestMultipleFiles tests that the declarations of all the files of a package
+// are merged, excluding the test files and the files excluded by the build
+// constraints.
+func TestMultipleFiles(t *testing.T) {
+	fsys := fstest.Files{
+		"main.go": `package main
+
+		import "fmt"
+
+		func main() {
+			var s Set
+			s.Add(Name)
+			print(fmt.Sprint(s.Len(), " ", platform()))
+		}`,
+		"set.go": `package main
+
+		const Name = "a"
+
+		type Set map[string]bool
+
+		func (s *Set) Add(v string) {
+			if *s == nil {
+				*s = Set{}
+			}
+			(*s)[v] = true
+		}
+
+		func (s Set) Len() int { return len(s) }`,
+		"platform.go": "//go:build !never\n\npackage main\n\nfunc platform() string { return \"all\" }",
+		"ignored.go":  "//go:build never\n\npackage main\n\nfunc platform() string { return \"never\" }",
+		"main_test.go": `package main
+
+		func platform() string { return "test" }`,
+	}
+	program, err := scriggo.Build(fsys, &scriggo.BuildOptions{Packages: native.Packages{
+		"fmt": native.Package{
+			Name:         "fmt",
+			Declarations: native.Declarations{"Sprint": fmt.Sprint},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out string
+	err = program.Run(&scriggo.RunOptions{Print: func(v interface{}) { out += fmt.Sprint(v) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "1 all"; out != expected {
+		t.Fatalf("expected output %q, got %q", expected, out)
+	}
+}
+
+var multipleFilesErrorTests = []struct {
+	files fstest.Files
+	err   string
+}{{
+	files: fstest.Files{
+		"main.go": `package main; import "fmt"; func main() { fmt.Println() }`,
+		"f.go":    `package main; func f() { fmt.Println() }`,
+	},
+	err: "f.go:1:26: undefined: fmt",
+}, {
+	files: fstest.Files{
+		"main.go": `package main; import "fmt"; func main() { fmt.Println() }`,
+		"f.go":    `package main; import "fmt"; func f() {}`,
+	},
+	err: `f.go:1:22: imported and not used: "fmt"`,
+}, {
+	files: fstest.Files{
+		"main.go": `package main; import "fmt"; func main() { fmt.Println() }`,
+		"f.go":    `package main; var fmt = 5`,
+	},
+	err: "f.go:1:19: fmt redeclared in this block\n\tmain.go:1:22: previous declaration during import \"fmt\"",
+}, {
+	files: fstest.Files{
+		"main.go": `package main; func main() { f() }`,
+		"f.go":    `package main; func f() { _ = 1 + "a" }`,
+	},
+	err: `f.go:1:32: invalid operation: 1 + "a" (mismatched types int and string)`,
+}, {
+	files: fstest.Files{
+		"main.go": `package main; func main() { }`,
+		"f.go":    `package main; func f( { }`,
+	},
+	err: "f.go:1:23: syntax error: unexpected {, expecting )",
+}, {
+	files: fstest.Files{
+		"main.go": `package main; func main() { }`,
+		"pkg.go":  `package pkg`,
+	},
+	err: "pkg.go:1:1: package pkg; expected main",
+}}
+
+// TestMultipleFilesErrors tests that the errors in packages with multiple
+// files are reported with the path of the file.
+func TestMultipleFilesErrors(t *testing.T) {
+	options := &scriggo.BuildOptions{Packages: native.Packages{
+		"fmt": native.Package{
+			Name:         "fmt",
+			Declarations: native.Declarations{"Println": fmt.Println},
+		},
+	}}
+	for _, test := range multipleFilesErrorTests {
+		_, err := scriggo.Build(test.files, options)
+		if err == nil {
+			t.Fatalf("expected error %q, got no error", test.err)
+		}
+		if err.Error() != test.err {
+			t.Fatalf("expected error %q, got %q", test.err, err)
+		}
+	}
+}
@@ -6,11 +6,15 @@ package misc
 
 import (
 	"bytes"
+	"io"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/open2b/scriggo"
+	"github.com/open2b/scriggo/builtin"
+	"github.com/open2b/scriggo/i18n"
 	"github.com/open2b/scriggo/internal/fstest"
 	"github.com/open2b/scriggo/native"
 )
@@ -448,6 +452,182 @@ func TestCSSStringContext(t *testing.T) {
 	}
 }
 
+type testXMLStringer struct{}
+
+func (testXMLStringer) XML() native.XML { return "<b/>" }
+
+var xmlContextTests = []struct {
+	src  string
+	res  string
+	vars Vars
+}{
+	{`<a>{{ "<b>&amp;" }}</a>`, `<a>&lt;b&gt;&amp;amp;</a>`, nil},
+	{`<a>{{ a }}</a>`, `<a><b>&amp;</b></a>`, Vars{"a": native.XML("<b>&amp;</b>")}},
+	{`<a>{{ a }}</a>`, `<a><b/></a>`, Vars{"a": testXMLStringer{}}},
+	{`<a>{{ xml("<b/>") }}</a>`, `<a><b/></a>`, nil},
+	{`<a>{{ 5 }} {{ true }}</a>`, `<a>5 true</a>`, nil},
+	{`<a b="{{ "\"'<&\n" }}"/>`, `<a b="&#34;&#39;&lt;&amp;&#10;"/>`, nil},
+	{`<a b='{{ a }}'/>`, `<a b='&lt;&amp;'/>`, Vars{"a": native.XML("<&amp;")}},
+	{`<a><![CDATA[{{ "<b>]]>" }}]]></a>`, `<a><![CDATA[<b>]]]]><![CDATA[>]]></a>`, nil},
+	{`{% macro M xml %}<b>{{ "<" }}</b>{% end %}<a>{{ M() }}</a>`, `<a><b>&lt;</b></a>`, nil},
+	{`<a>{{ render "icon.svg" }}</a>`, `<a><svg width="10"/></a>`, nil},
+}
+
+func TestXMLContext(t *testing.T) {
+	for _, expr := range xmlContextTests {
+		fsys := fstest.Files{
+			"index.xml": expr.src,
+			"icon.svg":  `<svg width="{{ 10 }}"/>`,
+		}
+		opts := &scriggo.BuildOptions{
+			Globals: asDeclarations(expr.vars),
+		}
+		template, err := scriggo.BuildTemplate(fsys, "index.xml", opts)
+		if err != nil {
+			t.Errorf("source: %q, %s\n", expr.src, err)
+			continue
+		}
+		var b = &bytes.Buffer{}
+		err = template.Run(b, expr.vars, nil)
+		if err != nil {
+			t.Errorf("source: %q, %s\n", expr.src, err)
+			continue
+		}
+		if res := b.String(); res != expr.res {
+			t.Errorf("source: %q, unexpected %q, expecting %q\n", expr.src, res, expr.res)
+		}
+	}
+}
+
+type testYAMLStringer struct{}
+
+func (testYAMLStringer) YAML() native.YAML { return "[a, b]" }
+
+type testDataStruct struct {
+	Name  string `yaml:"name" toml:"name"`
+	Ports []int  `yaml:"ports,omitempty" toml:"ports,omitempty"`
+	Debug bool   `yaml:"-"`
+}
+
+var yamlContextTests = []struct {
+	src  string
+	res  string
+	vars Vars
+}{
+	{`a: {{ "b: c" }}`, `a: "b: c"`, nil},
+	{`{{ "a\"" }}: {{ 5 }}`, `"a\"": 5`, nil},
+	{`a: {{ 1.5 }} {{ true }}`, `a: 1.5 true`, nil},
+	{`a: {{ s }}`, `a: ["x", "y"]`, Vars{"s": []string{"x", "y"}}},
+	{`a: {{ m }}`, `a: {"b": 1, "c": null}`, Vars{"m": map[string]interface{}{"c": nil, "b": 1}}},
+	{`a: {{ v }}`, `a: {"name": "web", "ports": [80]}`, Vars{"v": testDataStruct{Name: "web", Ports: []int{80}}}},
+	{`a: {{ v }}`, `a: [a, b]`, Vars{"v": testYAMLStringer{}}},
+	{`a: {{ yaml("{b: c}") }}`, `a: {b: c}`, nil},
+	{`image: nginx:{{ "1.21" }}`, `image: nginx:1.21`, nil},
+	{`a: "{{ "b\"\n" }}"`, `a: "b\"\n"`, nil},
+	{`a: '{{ "it's" }}'`, `a: 'it''s'`, nil},
+	{"a: |\n  {{ \"b\\nc\" }}\nd: 1", "a: |\n  b\n  c\nd: 1", nil},
+	{"a:\n  - |\n    {% if true %}\n    {{ \"b\\nc\" }}\n    {% end %}\nd: 1", "a:\n  - |\n    b\n    c\nd: 1", nil},
+	{`{% macro M yaml %}[{{ "a" }}]{% end %}a: {{ M() }}`, `a: ["a"]`, nil},
+	{`list: [a{{ "b c" }}, {{ "d, e" }}]`, `list: [ab c, "d, e"]`, nil},
+	{`map: {a: b{{ "c" }}, d{{ "e" }}: f}`, `map: {a: bc, de: f}`, nil},
+}
+
+func TestYAMLContext(t *testing.T) {
+	for _, expr := range yamlContextTests {
+		fsys := fstest.Files{"index.yaml": expr.src}
+		opts := &scriggo.BuildOptions{
+			Globals: asDeclarations(expr.vars),
+		}
+		template, err := scriggo.BuildTemplate(fsys, "index.yaml", opts)
+		if err != nil {
+			t.Errorf("source: %q, %s\n", expr.src, err)
+			continue
+		}
+		var b = &bytes.Buffer{}
+		err = template.Run(b, expr.vars, nil)
+		if err != nil {
+			t.Errorf("source: %q, %s\n", expr.src, err)
+			continue
+		}
+		if res := b.String(); res != expr.res {
+			t.Errorf("source: %q, unexpected %q, expecting %q\n", expr.src, res, expr.res)
+		}
+	}
+}
+
+var tomlContextTests = []struct {
+	src  string
+	res  string
+	vars Vars
+}{
+	{`a = {{ "b" }}`, `a = "b"`, nil},
+	{`{{ "a b" }} = {{ 5 }}`, `"a b" = 5`, nil},
+	{`a = {{ 2.0 }}`, `a = 2.0`, nil},
+	{`a = {{ s }}`, `a = ["x", "y"]`, Vars{"s": []string{"x", "y"}}},
+	{`a = {{ m }}`, `a = { "b c" = 1, d = true }`, Vars{"m": map[string]interface{}{"d": true, "b c": 1}}},
+	{`a = {{ v }}`, `a = { name = "web", Debug = true }`, Vars{"v": testDataStruct{Name: "web", Debug: true}}},
+	{`a = {{ toml("[1]") }}`, `a = [1]`, nil},
+	{`a = "{{ "b\"\n" }}" # {{ "c" }}`, `a = "b\"\n" # "c"`, nil},
+	{`a = '{{ "b\\" }}'`, `a = 'b\'`, nil},
+	{`a = "{{ "\x00\x1b\x7f\u0085\U0001F600" }}"`, "a = \"\\u0000\\u001b\\u007f\u0085\U0001F600\"", nil},
+}
+
+func TestTOMLContext(t *testing.T) {
+	for _, expr := range tomlContextTests {
+		fsys := fstest.Files{"index.toml": expr.src}
+		opts := &scriggo.BuildOptions{
+			Globals: asDeclarations(expr.vars),
+		}
+		template, err := scriggo.BuildTemplate(fsys, "index.toml", opts)
+		if err != nil {
+			t.Errorf("source: %q, %s\n", expr.src, err)
+			continue
+		}
+		var b = &bytes.Buffer{}
+		err = template.Run(b, expr.vars, nil)
+		if err != nil {
+			t.Errorf("source: %q, %s\n", expr.src, err)
+			continue
+		}
+		if res := b.String(); res != expr.res {
+			t.Errorf("source: %q, unexpected %q, expecting %q\n", expr.src, res, expr.res)
+		}
+	}
+}
+
+var dataContextErrorTests = []struct {
+	name string
+	src  string
+	err  string
+}{
+	{"index.yaml", `a: b{{ ": c" }}`, `cannot show ": " in a YAML plain scalar`},
+	{"index.yaml", `list: [a{{ "b, c, d" }}]`, `cannot show "," in a YAML flow plain scalar`},
+	{"index.yaml", `list: [a{{ "], evil, [x" }}]`, `cannot show "]" in a YAML flow plain scalar`},
+	{"index.yaml", `map: {a: b{{ "{c" }} }`, `cannot show "{" in a YAML flow plain scalar`},
+	{"index.yaml", `a: '{{ "b\nc" }}'`, `cannot show a line break in a YAML single-quoted scalar`},
+	{"index.toml", `a = '{{ "it's" }}'`, `cannot show ' in a TOML literal string`},
+	{"index.toml", `a = {{ []*int{nil} }}`, `cannot show nil as TOML`},
+}
+
+func TestDataContextErrors(t *testing.T) {
+	for _, expr := range dataContextErrorTests {
+		fsys := fstest.Files{expr.name: expr.src}
+		template, err := scriggo.BuildTemplate(fsys, expr.name, nil)
+		if err != nil {
+			t.Errorf("source: %q, %s\n", expr.src, err)
+			continue
+		}
+		err = template.Run(io.Discard, nil, nil)
+		if err == nil {
+			t.Errorf("source: %q, expecting error %q, got no error\n", expr.src, expr.err)
+			continue
+		}
+		if err.Error() != expr.err {
+			t.Errorf("source: %q, unexpected error %q, expecting %q\n", expr.src, err, expr.err)
+		}
+	}
+}
+
 func asDeclarations(vars Vars) native.Declarations {
 	declarations := globals()
 	for name, value := range vars {
@@ -455,3 +635,303 @@ func asDeclarations(vars Vars) native.Declarations {
 	}
 	return declarations
 }
+
+var nonceTests = []struct {
+	src   string
+	nonce string
+	res   string
+}{
+	{`<script></script>`, "", `<script></script>`},
+	{`<script></script>`, "r4nd", `<script nonce="r4nd"></script>`},
+	{`<style></style>`, "r4nd", `<style nonce="r4nd"></style>`},
+	{`<SCRIPT type="module"></SCRIPT>`, "r4nd", `<SCRIPT nonce="r4nd" type="module"></SCRIPT>`},
+	{`<script/>`, "r4nd", `<script nonce="r4nd"/>`},
+	{`<p>a</p><script>var s = "<script>";</script><style>a{}</style>`, "r4nd", `<p>a</p><script nonce="r4nd">var s = "<script>";</script><style nonce="r4nd">a{}</style>`},
+	{`<scripts></scripts><div>&lt;script</div>`, "r4nd", `<scripts></scripts><div>&lt;script</div>`},
+	{`<script src="{{ "a.js" }}"></script>`, "r4nd", `<script nonce="r4nd" src="a.js"></script>`},
+	{`{% if true %}<script>{% end %}</script>`, "r4nd", `<script nonce="r4nd"></script>`},
+	{`{% for i := 0; i < 2; i++ %}<style></style>{% end %}`, "r4nd", `<style nonce="r4nd"></style><style nonce="r4nd"></style>`},
+	{`{% macro M %}<script></script>{% end %}{{ M() }}`, "r4nd", `<script nonce="r4nd"></script>`},
+	{`{{ render "partial.html" }}`, "r4nd", `<script nonce="r4nd"></script>`},
+	{`<script></script>`, `a"b`, `<script nonce="a&#34;b"></script>`},
+	{`<link rel="preload" as="script" nonce="{{ nonce() }}">`, "r4nd", `<link rel="preload" as="script" nonce="r4nd">`},
+}
+
+func TestNonce(t *testing.T) {
+	for _, test := range nonceTests {
+		fsys := fstest.Files{
+			"index.html":   test.src,
+			"partial.html": `<script></script>`,
+		}
+		opts := &scriggo.BuildOptions{
+			Globals: native.Declarations{
+				"nonce": builtin.Nonce,
+			},
+		}
+		template, err := scriggo.BuildTemplate(fsys, "index.html", opts)
+		if err != nil {
+			t.Errorf("source: %q, %s\n", test.src, err)
+			continue
+		}
+		var b = &bytes.Buffer{}
+		err = template.Run(b, nil, &scriggo.RunOptions{Nonce: test.nonce})
+		if err != nil {
+			t.Errorf("source: %q, %s\n", test.src, err)
+			continue
+		}
+		if res := b.String(); res != test.res {
+			t.Errorf("source: %q, unexpected %q, expecting %q\n", test.src, res, test.res)
+		}
+	}
+}
+
+const i18nTestPO = `msgid ""
+msgstr "Plural-Forms: nplurals=2; plural=n != 1;\n"
+
+msgid "Hello"
+msgstr "Ciao"
+
+msgid "%d apple"
+msgid_plural "%d apples"
+msgstr[0] "%d mela"
+msgstr[1] "%d mele"
+`
+
+var i18nTests = []struct {
+	src    string
+	locale string
+	res    string
+}{
+	{`{{ translate("Hello") }}`, "", "Hello"},
+	{`{{ translate("Hello") }}`, "it-IT", "Ciao"},
+	{`{{ sprintf(translatePlural("%d apple", "%d apples", 3), 3) }}`, "it-IT", "3 mele"},
+	{`{{ formatNumber(1234.5, 2) }}`, "", "1,234.50"},
+	{`{{ formatNumber(1234.5, 2) }}`, "it-IT", "1.234,50"},
+	{`{{ formatDate(unixTime(1620000000, 0).UTC(), "long") }}`, "it-IT", "3 maggio 2021"},
+	{`{{ formatDate(unixTime(1620000000, 0).UTC(), "long") }}`, "ja-JP", "2021年5月3日"},
+	{`{{ render "partial.html" }}`, "it-IT", "Ciao"},
+	{`{% macro M %}{{ translate("Hello") }}{% end %}{{ M() }}`, "it-IT", "Ciao"},
+}
+
+func TestI18n(t *testing.T) {
+	catalog, err := i18n.ParsePO(strings.NewReader(i18nTestPO))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range i18nTests {
+		fsys := fstest.Files{
+			"index.html":   test.src,
+			"partial.html": `{{ translate("Hello") }}`,
+		}
+		opts := &scriggo.BuildOptions{
+			Globals: native.Declarations{
+				"formatDate":      builtin.FormatDate,
+				"formatNumber":    builtin.FormatNumber,
+				"sprintf":         builtin.Sprintf,
+				"translate":       builtin.Translate,
+				"translatePlural": builtin.TranslatePlural,
+				"unixTime":        builtin.UnixTime,
+			},
+		}
+		template, err := scriggo.BuildTemplate(fsys, "index.html", opts)
+		if err != nil {
+			t.Errorf("source: %q, %s\n", test.src, err)
+			continue
+		}
+		var b = &bytes.Buffer{}
+		runOpts := &scriggo.RunOptions{Locale: test.locale}
+		if test.locale != "" {
+			runOpts.Catalog = catalog
+		}
+		err = template.Run(b, nil, runOpts)
+		if err != nil {
+			t.Errorf("source: %q, %s\n", test.src, err)
+			continue
+		}
+		if res := b.String(); res != test.res {
+			t.Errorf("source: %q, unexpected %q, expecting %q\n", test.src, res, test.res)
+		}
+	}
+}
+
+// flushRecorder records the output written at each call of its Flush method.
+type flushRecorder struct {
+	buf     bytes.Buffer
+	flushes []string
+}
+
+func (r *flushRecorder) Write(p []byte) (int, error) {
+	return r.buf.Write(p)
+}
+
+func (r *flushRecorder) Flush() {
+	r.flushes = append(r.flushes, r.buf.String())
+}
+
+var flushTests = []struct {
+	src     string
+	flushes []string
+	res     string
+}{
+	{`a`, nil, `a`},
+	{`a{% flush() %}b{% flush() %}`, []string{`a`, `ab`}, `ab`},
+	{`{% macro M %}b{% flush() %}c{% end %}a{{ M() }}d`, []string{`ab`}, `abcd`},
+	{`a{{ render "doc.md" }}b`, []string{`a--- start Markdown ---
+# title
+
+--- end Markdown ---
+`}, `a--- start Markdown ---
+# title
+
+--- end Markdown ---
+--- start Markdown ---
+text--- end Markdown ---
+b`},
+	{`a{{ render "code.md" }}`, []string{`a`}, `a--- start Markdown ---
+` + "```" + `
+
+` + "```" + `--- end Markdown ---
+`},
+}
+
+func TestFlush(t *testing.T) {
+	for _, test := range flushTests {
+		fsys := fstest.Files{
+			"index.html": test.src,
+			"doc.md":     "# title\n\n{% flush() %}text",
+			"code.md":    "```\n\n{% flush() %}```",
+		}
+		opts := &scriggo.BuildOptions{
+			Globals: native.Declarations{
+				"flush": builtin.Flush,
+			},
+			MarkdownConverter: markdownConverter,
+		}
+		template, err := scriggo.BuildTemplate(fsys, "index.html", opts)
+		if err != nil {
+			t.Errorf("source: %q, %s\n", test.src, err)
+			continue
+		}
+		// Run with and without an output limit, as the limit wraps the writer.
+		for _, runOpts := range []*scriggo.RunOptions{nil, {MaxOutputBytes: 1000}} {
+			out := &flushRecorder{}
+			err = template.Run(out, nil, runOpts)
+			if err != nil {
+				t.Errorf("source: %q, %s\n", test.src, err)
+				continue
+			}
+			if !reflect.DeepEqual(out.flushes, test.flushes) {
+				t.Errorf("source: %q, unexpected flushes %q, expecting %q\n", test.src, out.flushes, test.flushes)
+			}
+			if res := out.buf.String(); res != test.res {
+				t.Errorf("source: %q, unexpected %q, expecting %q\n", test.src, res, test.res)
+			}
+		}
+	}
+}
+
+var asyncTests = []struct {
+	src     string
+	flushes []string
+	res     string
+}{
+	{`{% macro M %}m{% end %}a{% go M() %}b`, nil, `amb`},
+	{`{% macro M(s string) %}{{ s }}{% end %}{% go M("<a>") %}`, nil, `&lt;a&gt;`},
+	{`{% var ch = make(chan int) %}{% macro A %}{% <-ch %}a{% end %}{% macro B %}b{% close(ch) %}{% end %}[{% go A() %}][{% go B() %}]`, nil, `[a][b]`},
+	{`{% var ch = make(chan int) %}{% macro A %}{% <-ch %}a{% end %}{% macro B(n int) %}{{ n }}{% if n == 1 %}{% close(ch) %}{% else %}({% go B(n-1) %}){% end %}{% end %}{% go A() %}-{% go B(3) %}`, nil, `a-3(2(1))`},
+	{`{% macro M %}m{% end %}{% macro C %}[{% go M() %}]{% end %}{% var s = C() %}{{ len(s) }}{{ s }}`, nil, `3[m]`},
+	{`{% macro M %}m{% end %}{% macro C %}[{{ M() }}{% go M() %}]{% end %}{{ C() }}`, nil, `[mm]`},
+	{`{% macro M markdown %}# title{% end %}<div>{% go M() %}</div>`, nil, "<div>--- start Markdown ---\n# title--- end Markdown ---\n</div>"},
+	{`{% go itea(); using macro %}<b>a</b>{% end using %}b`, nil, `<b>a</b>b`},
+	{`{% var ch = make(chan int) %}{% macro A %}{% <-ch %}a{% end %}x{% go A() %}y{% flush() %}{% close(ch) %}z`, []string{`x`}, `xayz`},
+	{`{% import "imported.html" %}{% go I() %}`, nil, `i`},
+}
+
+func TestAsync(t *testing.T) {
+	for _, test := range asyncTests {
+		fsys := fstest.Files{
+			"index.html":    test.src,
+			"imported.html": `{% macro I %}i{% end %}`,
+		}
+		opts := &scriggo.BuildOptions{
+			AllowGoStmt: true,
+			Globals: native.Declarations{
+				"flush": builtin.Flush,
+			},
+			MarkdownConverter: markdownConverter,
+		}
+		template, err := scriggo.BuildTemplate(fsys, "index.html", opts)
+		if err != nil {
+			t.Errorf("source: %q, %s\n", test.src, err)
+			continue
+		}
+		out := &flushRecorder{}
+		err = template.Run(out, nil, nil)
+		if err != nil {
+			t.Errorf("source: %q, %s\n", test.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(out.flushes, test.flushes) {
+			t.Errorf("source: %q, unexpected flushes %q, expecting %q\n", test.src, out.flushes, test.flushes)
+		}
+		if res := out.buf.String(); res != test.res {
+			t.Errorf("source: %q, unexpected %q, expecting %q\n", test.src, res, test.res)
+		}
+	}
+}
+
+// TestAsyncFlush tests that flushing in a goroutine started by the go
+// statement does not race with the main goroutine. Run it with the -race
+// flag.
+func TestAsyncFlush(t *testing.T) {
+	src := `{% macro A %}{% for i := 0; i < 200; i++ %}a{% flush() %}{% end %}{% end %}` +
+		`{% go A() %}{% for i := 0; i < 200; i++ %}b{% flush() %}{% end %}`
+	fsys := fstest.Files{"index.html": src}
+	opts := &scriggo.BuildOptions{
+		AllowGoStmt: true,
+		Globals: native.Declarations{
+			"flush": builtin.Flush,
+		},
+	}
+	template, err := scriggo.BuildTemplate(fsys, "index.html", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &flushRecorder{}
+	err = template.Run(out, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.flushes) != 200 {
+		t.Fatalf("expecting 200 flushes, got %d", len(out.flushes))
+	}
+	if expected := strings.Repeat("a", 200) + strings.Repeat("b", 200); out.buf.String() != expected {
+		t.Fatalf("unexpected %q, expecting %q", out.buf.String(), expected)
+	}
+}
+
+var asyncErrorTests = []struct {
+	src string
+	err string
+}{
+	{`{% macro M %}{% var s []int %}{{ s[1] }}{% end %}a{% go M() %}b`, "runtime error: index out of range [1] with length 0\n"},
+	{`{% macro M %}m{% end %}<script>{% go M() %}</script>`, `index.html:1:35: cannot render M() (type html) asynchronously in JavaScript`},
+	{`{% macro M %}m{% end %}<a href="{% go M() %}">`, `index.html:1:36: cannot render M() (type html) asynchronously in quoted attribute`},
+}
+
+func TestAsyncErrors(t *testing.T) {
+	for _, test := range asyncErrorTests {
+		fsys := fstest.Files{"index.html": test.src}
+		template, err := scriggo.BuildTemplate(fsys, "index.html", &scriggo.BuildOptions{AllowGoStmt: true})
+		if err == nil {
+			err = template.Run(io.Discard, nil, nil)
+		}
+		if err == nil {
+			t.Errorf("source: %q, expecting error %q, got no error\n", test.src, test.err)
+			continue
+		}
+		if err.Error() != test.err {
+			t.Errorf("source: %q, unexpected error %q, expecting %q\n", test.src, err, test.err)
+		}
+	}
+}
@@ -165,6 +165,37 @@ var scriptTests = map[string]struct {
 		out: "42",
 	},
 
+	"Interface type definitions": {
+		src: `
+		import "pkg"
+		type Printer interface{ Print(...interface{}) }
+		type Named interface{ Name() string }
+		type NamedPrinter interface {
+			Printer
+			Named
+		}
+		var p Printer = pkg.NewWriter("w")
+		if np, ok := p.(NamedPrinter); ok {
+			np.Print(np.Name(), ":")
+		}
+		switch p.(type) {
+		case interface{ Missing() }:
+			Print("missing")
+		case Named:
+			Print("named")
+		}
+		`,
+		pkgs: native.Packages{
+			"pkg": native.Package{
+				Name: "pkg",
+				Declarations: native.Declarations{
+					"NewWriter": func(name string) *scriptWriter { return &scriptWriter{name} },
+				},
+			},
+		},
+		out: "w:named",
+	},
+
 	// https://github.com/open2b/scriggo/issues/659
 	"Accessing a global variable from a function literal": {
 		src: `
@@ -182,6 +213,19 @@ var scriptTests = map[string]struct {
 // Holds output of scriptTests.
 var scriptsStdout strings.Builder
 
+// scriptWriter is used by scriptTests to test interface types.
+type scriptWriter struct {
+	name string
+}
+
+func (w *scriptWriter) Name() string { return w.name }
+
+func (w *scriptWriter) Print(args ...interface{}) {
+	for _, a := range args {
+		scriptsStdout.WriteString(fmt.Sprint(a))
+	}
+}
+
 func TestScripts(t *testing.T) {
 	for name, cas := range scriptTests {
 		t.Run(name, func(t *testing.T) {
@@ -270,3 +314,77 @@ func TestScriptsChainMessages(t *testing.T) {
 		t.Fatalf("Message should be %q, got %q", "external,script1,script2", Message)
 	}
 }
+
+func TestScriptMarshalBinary(t *testing.T) {
+	src := `import "strings"; Message = strings.Repeat(Message, 2); f := func(n int) int { return n * 2 }; Sum = f(Sum)`
+	options := &scripts.BuildOptions{
+		Globals: native.Declarations{
+			"Message": (*string)(nil),
+			"Sum":     (*int)(nil),
+		},
+		Packages: native.Packages{
+			"strings": native.Package{
+				Name: "strings",
+				Declarations: native.Declarations{
+					"Repeat": strings.Repeat,
+				},
+			},
+		},
+	}
+	script, err := scripts.Build(strings.NewReader(src), options)
+	if err != nil {
+		t.Fatalf("unable to build script: %s", err)
+	}
+	data, err := script.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unable to marshal script: %s", err)
+	}
+	loaded, err := scripts.Load(data, options)
+	if err != nil {
+		t.Fatalf("unable to load script: %s", err)
+	}
+	Message := "ab"
+	Sum := 21
+	err = loaded.Run(map[string]interface{}{"Message": &Message, "Sum": &Sum}, nil)
+	if err != nil {
+		t.Fatalf("run: %s", err)
+	}
+	if Message != "abab" {
+		t.Fatalf("Message should be %q, got %q", "abab", Message)
+	}
+	if Sum != 42 {
+		t.Fatalf("Sum should be %d, got %d", 42, Sum)
+	}
+	// Marshal the loaded script.
+	data2, err := loaded.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unable to marshal loaded script: %s", err)
+	}
+	if string(data2) != string(data) {
+		t.Fatalf("unexpected different encoding after loading")
+	}
+}
+
+func TestScriptLoadVersionMismatch(t *testing.T) {
+	script, err := scripts.Build(strings.NewReader(`a := 1; _ = a`), nil)
+	if err != nil {
+		t.Fatalf("unable to build script: %s", err)
+	}
+	data, err := script.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unable to marshal script: %s", err)
+	}
+	// The version follows the magic "scriggo\x00" and is encoded in one byte.
+	if data[8] != 1 {
+		t.Fatalf("expected version 1, got %d", data[8])
+	}
+	data[8] = 2
+	_, err = scripts.Load(data, nil)
+	expected := "scriggo: unsupported marshaled code version 2"
+	if err == nil {
+		t.Fatalf("expected error %q, got no error", expected)
+	}
+	if err.Error() != expected {
+		t.Fatalf("expected error %q, got %q", expected, err)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/open2b/scriggo"
 	"github.com/open2b/scriggo/ast"
@@ -416,7 +417,7 @@ var rendererStmtTests = []struct {
 	{"{% for i := 0; i < 5; i++ %}{{ i }}{% end %}", "01234", nil},
 	{"{% for i := 0; i < 5; i++ %}{{ i }}{% break %}{% end %}", "0", nil},
 	{"{% for i := 0; ; i++ %}{{ i }}{% if i == 4 %}{% break %}{% end %}{% end %}", "01234", nil},
-	// {"{% for i := 0; i < 5; i++ %}{{ i }}{% if i == 4 %}{% continue %}{% end %},{% end %}", "0,1,2,3,4", nil},
+	{"{% for i := 0; i < 5; i++ %}{{ i }}{% if i == 4 %}{% continue %}{% end %},{% end %}", "0,1,2,3,4", nil},
 	{"{% switch %}{% end %}", "", nil},
 	{"{% switch %}{% case true %}ok{% end %}", "ok", nil},
 	{"{% switch ; %}{% case true %}ok{% end %}", "ok", nil},
@@ -427,6 +428,8 @@ var rendererStmtTests = []struct {
 	// {"{% switch a := 5; a := a.(type) %}{% case int %}ok{% end %}", "ok", nil},
 	{"{% switch 3 %}{% case 3 %}three{% end %}", "three", nil},
 	{"{% switch 4 + 5 %}{% case 4 %}{% case 9 %}nine{% end %}", "nine", nil},
+	{"{% switch 2 %}\n  {% case 1 %}\n  one\n  {% case 2 %}\n  two\n  {% default %}\n  other\n{% end %}", "  two\n", nil},
+	{"{% select %}\n  {% case <-make(chan int) %}\n  no\n  {% default %}\n  default\n{% end %}", "  default\n", nil},
 	{"{% switch x := 1; x + 1 %}{% case 1 %}one{% case 2 %}two{% end %}", "two", nil},
 	{"{% switch %}{% case 7 < 10 %}7 < 10{% default %}other{% end %}", "7 < 10", nil},
 	{"{% switch %}{% case 7 > 10 %}7 > 10{% default %}other{% end %}", "other", nil},
@@ -712,6 +715,21 @@ var templateMultiFileCases = map[string]struct {
 		expectedOut: `Hello, world!`,
 	},
 
+	"Interface type definitions": {
+		sources: fstest.Files{
+			"index.txt": `{% type Stringer interface{ String() string } %}` +
+				`{% var s Stringer = d %}{{ s.String() }} ` +
+				`{% if s, ok := interface{}(d).(interface{ Hours() float64; Stringer }); ok %}{{ s.Hours() }}{% end %} ` +
+				`{% if _, ok := interface{}(5).(Stringer); ok %}no{% else %}ok{% end %}`,
+		},
+		main: native.Package{
+			Declarations: native.Declarations{
+				"d": 90 * time.Minute,
+			},
+		},
+		expectedOut: "1h30m0s 1.5 ok",
+	},
+
 	"Template comments": {
 		sources: fstest.Files{
 			"index.txt": `{# this is a comment #}`,
@@ -1016,6 +1034,34 @@ var templateMultiFileCases = map[string]struct {
 		expectedOut: `a`,
 	},
 
+	"Label for in": {
+		sources: fstest.Files{
+			"index.txt": `{% L: for v in []int{1, 2, 3} %}{% for w in []int{1, 2} %}{% if v == 2 %}{% continue L %}{% else if v == 3 %}{% break L %}{% end if %}{{ v }}{{ w }},{% end for %}{% end for %}`,
+		},
+		expectedOut: `11,12,`,
+	},
+
+	"Label for range": {
+		sources: fstest.Files{
+			"index.txt": `{% L: for i := range []int{1, 2, 3} %}{% for j := 0; j < 3; j++ %}{% if j == 1 %}{% continue L %}{% end if %}{{ i }}{{ j }},{% end for %}{% end for %}`,
+		},
+		expectedOut: `00,10,20,`,
+	},
+
+	"Label type switch": {
+		sources: fstest.Files{
+			"index.txt": `{% L: switch interface{}(1).(type) %}{% case int %}{% for i := 0; i < 3; i++ %}{{ i }}{% break L %}{% end for %}b{% end switch %}`,
+		},
+		expectedOut: `0`,
+	},
+
+	"Label select": {
+		sources: fstest.Files{
+			"index.txt": `{% L: select %}{% default %}{% for s in "ab" %}{{ s }}{% break L %}{% end for %}b{% end select %}`,
+		},
+		expectedOut: `97`,
+	},
+
 	"Render - Only text": {
 		sources: fstest.Files{
 			"index.txt":   `a{{ render "/partial.txt" }}c`,
@@ -4021,6 +4067,15 @@ var functionReturningErrorPackage = native.Package{
 }
 
 func TestMultiFileTemplate(t *testing.T) {
+	testMultiFileTemplate(t, false)
+}
+
+func TestMultiFileTemplateOptimized(t *testing.T) {
+	testMultiFileTemplate(t, true)
+}
+
+func testMultiFileTemplate(t *testing.T, optimize bool) {
+	testGetValueCalled = false
 	for name, cas := range templateMultiFileCases {
 		if cas.expectedOut != "" && cas.expectedBuildErr != "" {
 			panic("invalid test: " + name)
@@ -4044,6 +4099,7 @@ func TestMultiFileTemplate(t *testing.T) {
 				MarkdownConverter:    markdownConverter,
 				NoParseShortShowStmt: cas.noParseShow,
 				DollarIdentifier:     cas.dollarIdentifier,
+				Optimize:             optimize,
 			}
 			template, err := scriggo.BuildTemplate(cas.sources, entryPoint, opts)
 			switch {